@@ -15,23 +15,34 @@ as well as the global.name setting.
 {{- end -}}
 {{- end -}}
 
+{{/*
+Returns the Vault path of a secret. If the secret sets vaultNamespace, the path
+is prefixed with it so that the secret is read from that Vault namespace,
+relative to the namespace of the Vault agent.
+
+Usage: {{ include "consul.vaultSecretPath" .Values.global.gossipEncryption }}
+*/}}
+{{- define "consul.vaultSecretPath" -}}
+{{- if .vaultNamespace }}{{ trimSuffix "/" .vaultNamespace }}/{{ end }}{{ .secretName }}
+{{- end -}}
+
 {{- define "consul.vaultSecretTemplate" -}}
  |
-            {{ "{{" }}- with secret "{{ .secretName }}" -{{ "}}" }}
+            {{ "{{" }}- with secret "{{ include "consul.vaultSecretPath" . }}" -{{ "}}" }}
             {{ "{{" }}- {{ printf ".Data.data.%s" .secretKey }} -{{ "}}" }}
             {{ "{{" }}- end -{{ "}}" }}
 {{- end -}}
 
 {{- define "consul.serverTLSCATemplate" -}}
  |
-            {{ "{{" }}- with secret "{{ .Values.global.tls.caCert.secretName }}" -{{ "}}" }}
+            {{ "{{" }}- with secret "{{ include "consul.vaultSecretPath" .Values.global.tls.caCert }}" -{{ "}}" }}
             {{ "{{" }}- .Data.certificate -{{ "}}" }}
             {{ "{{" }}- end -{{ "}}" }}
 {{- end -}}
 
 {{- define "consul.serverTLSCertTemplate" -}}
  |
-            {{ "{{" }}- with secret "{{ .Values.server.serverCert.secretName }}" "{{ printf "common_name=server.%s.%s" .Values.global.datacenter .Values.global.domain }}"
+            {{ "{{" }}- with secret "{{ include "consul.vaultSecretPath" .Values.server.serverCert }}" "{{ printf "common_name=server.%s.%s" .Values.global.datacenter .Values.global.domain }}"
             "alt_names={{ include "consul.serverTLSAltNames" . }}" "ip_sans=127.0.0.1{{ include "consul.serverAdditionalIPSANs" . }}" -{{ "}}" }}
             {{ "{{" }}- .Data.certificate -{{ "}}" }}
             {{ "{{" }}- end -{{ "}}" }}
@@ -39,7 +50,7 @@ as well as the global.name setting.
 
 {{- define "consul.serverTLSKeyTemplate" -}}
  |
-            {{ "{{" }}- with secret "{{ .Values.server.serverCert.secretName }}" "{{ printf "common_name=server.%s.%s" .Values.global.datacenter .Values.global.domain }}"
+            {{ "{{" }}- with secret "{{ include "consul.vaultSecretPath" .Values.server.serverCert }}" "{{ printf "common_name=server.%s.%s" .Values.global.datacenter .Values.global.domain }}"
             "alt_names={{ include "consul.serverTLSAltNames" . }}" "ip_sans=127.0.0.1{{ include "consul.serverAdditionalIPSANs" . }}" -{{ "}}" }}
             {{ "{{" }}- .Data.private_key -{{ "}}" }}
             {{ "{{" }}- end -{{ "}}" }}
@@ -59,6 +70,19 @@ as well as the global.name setting.
 {{- if .Values.global.tls -}}{{- if .Values.global.tls.serverAdditionalIPSANs -}}{{- range $ipsan := .Values.global.tls.serverAdditionalIPSANs }},{{ $ipsan }} {{- end -}}{{- end -}}{{- end -}}
 {{- end -}}
 
+{{/*
+Renders the issuerRef of a cert-manager Certificate from global.certManager.issuerRef.
+
+Usage: {{- include "consul.certManagerIssuerRef" . | nindent 2 }}
+*/}}
+{{- define "consul.certManagerIssuerRef" -}}
+{{- if not .Values.global.certManager.issuerRef.name }}{{ fail "global.certManager.issuerRef.name must be set if global.certManager.enabled=true" }}{{ end -}}
+issuerRef:
+  name: {{ .Values.global.certManager.issuerRef.name }}
+  kind: {{ .Values.global.certManager.issuerRef.kind }}
+  group: {{ .Values.global.certManager.issuerRef.group }}
+{{- end -}}
+
 {{- define "consul.vaultReplicationTokenTemplate" -}}
 |
           {{ "{{" }}- with secret "{{ .Values.global.acls.replicationToken.secretName }}" -{{ "}}" }}
@@ -75,7 +99,7 @@ as well as the global.name setting.
 
 {{- define "consul.vaultBootstrapTokenConfigTemplate" -}}
 |
-          {{ "{{" }}- with secret "{{ .Values.global.acls.bootstrapToken.secretName }}" -{{ "}}" }}
+          {{ "{{" }}- with secret "{{ include "consul.vaultSecretPath" .Values.global.acls.bootstrapToken }}" -{{ "}}" }}
           acl { tokens { initial_management = "{{ "{{" }}- {{ printf ".Data.data.%s" .Values.global.acls.bootstrapToken.secretKey }} -{{ "}}" }}" }}
           {{ "{{" }}- end -{{ "}}" }}
 {{- end -}}
@@ -93,6 +117,36 @@ is passed to consul as a -config-file param on command line.
               [ -n "${HOSTNAME}" ] && sed -Ei "s|HOSTNAME|${HOSTNAME?}|g" /consul/extra-config/extra-from-values.json
 {{- end -}}
 
+{{/*
+Renders the annotations that configure the cache, the retries and the token
+revocation of the Vault agent from global.secretsBackend.vault.agent. Nothing
+is rendered for the settings that are left to the defaults of the Vault agent
+injector.
+
+Usage: {{- with (include "consul.vaultAgentConfigAnnotations" .) }}{{ trim . | nindent 8 }}{{- end }}
+
+*/}}
+{{- define "consul.vaultAgentConfigAnnotations" -}}
+{{- with .Values.global.secretsBackend.vault.agent }}
+{{- if .cache.enabled }}
+"vault.hashicorp.com/agent-cache-enable": "true"
+"vault.hashicorp.com/agent-cache-use-auto-auth-token": "true"
+{{- end }}
+{{- if not (kindIs "invalid" .clientMaxRetries) }}
+"vault.hashicorp.com/client-max-retries": {{ .clientMaxRetries | quote }}
+{{- end }}
+{{- if .clientTimeout }}
+"vault.hashicorp.com/client-timeout": {{ .clientTimeout | quote }}
+{{- end }}
+{{- if not (kindIs "invalid" .exitOnRetryFailure) }}
+"vault.hashicorp.com/template-config-exit-on-retry-failure": {{ .exitOnRetryFailure | quote }}
+{{- end }}
+{{- if .revokeOnShutdown }}
+"vault.hashicorp.com/agent-revoke-on-shutdown": "true"
+{{- end }}
+{{- end }}
+{{- end -}}
+
 {{/*
 Sets up a list of recusor flags for Consul agents by iterating over the IPs of every nameserver
 in /etc/resolv.conf and concatenating them into a string of arguments that can be passed directly
@@ -153,6 +207,55 @@ Inject extra environment vars in the format key:value, if populated
 {{- end -}}
 {{- end -}}
 
+{{/*
+Entries of externalServers.hosts are either a host or a map with a host and
+optionally its own httpsPort and tlsServerName. These templates render the
+host, HTTPS port and TLS server name of an entry, falling back to
+externalServers.httpsPort and externalServers.tlsServerName.
+
+Usage: {{ template "consul.externalServerHost" $entry }}
+       {{ template "consul.externalServerHTTPSPort" (list $ $entry) }}
+*/}}
+{{- define "consul.externalServerHost" -}}
+{{- if kindIs "map" . }}{{ .host }}{{ else }}{{ . }}{{ end }}
+{{- end -}}
+
+{{- define "consul.externalServerHTTPSPort" -}}
+{{- $root := index . 0 }}{{- $entry := index . 1 }}
+{{- if and (kindIs "map" $entry) $entry.httpsPort }}{{ $entry.httpsPort }}{{ else }}{{ $root.Values.externalServers.httpsPort }}{{ end }}
+{{- end -}}
+
+{{- define "consul.externalServerTLSServerName" -}}
+{{- $root := index . 0 }}{{- $entry := index . 1 }}
+{{- if and (kindIs "map" $entry) $entry.tlsServerName }}{{ $entry.tlsServerName }}{{ else if $root.Values.externalServers.tlsServerName }}{{ $root.Values.externalServers.tlsServerName }}{{ end }}
+{{- end -}}
+
+{{/*
+Returns the name of the auth method that Consul components log in to with
+acl-init: the AWS IAM component auth method if global.acls.awsIAMAuthMethod
+is enabled and the Kubernetes component auth method otherwise.
+
+Usage: -acl-auth-method={{ template "consul.componentAuthMethod" . }} \
+*/}}
+{{- define "consul.componentAuthMethod" -}}
+{{ template "consul.fullname" . }}-{{ if .Values.global.acls.awsIAMAuthMethod.enabled }}aws-iam{{ else }}k8s{{ end }}-component-auth-method
+{{- end -}}
+
+{{/*
+Returns the flags to log in to an AWS IAM auth method configured with
+global.acls.awsIAMAuthMethod.
+
+Usage: {{ template "consul.awsIAMLoginFlags" . }} \
+*/}}
+{{- define "consul.awsIAMLoginFlags" -}}
+{{- with .Values.global.acls.awsIAMAuthMethod -}}
+-aws-iam-login
+{{- if .stsRegion }} -aws-sts-region={{ .stsRegion }}{{ end }}
+{{- if .stsEndpoint }} -aws-sts-endpoint={{ .stsEndpoint }}{{ end }}
+{{- if .serverIDHeaderValue }} -aws-iam-server-id-header-value={{ .serverIDHeaderValue }}{{ end }}
+{{- end -}}
+{{- end -}}
+
 {{/*
 Get Consul client CA to use when auto-encrypt is enabled.
 This template is for an init container.
@@ -169,10 +272,11 @@ This template is for an init container.
         -consul-api-timeout={{ .Values.global.consulAPITimeout }} \
         {{- if .Values.externalServers.enabled }}
         {{- if and .Values.externalServers.enabled (not .Values.externalServers.hosts) }}{{ fail "externalServers.hosts must be set if externalServers.enabled is true" }}{{ end -}}
-        -server-addr={{ quote (first .Values.externalServers.hosts) }} \
-        -server-port={{ .Values.externalServers.httpsPort }} \
-        {{- if .Values.externalServers.tlsServerName }}
-        -tls-server-name={{ .Values.externalServers.tlsServerName }} \
+        {{- $firstHost := first .Values.externalServers.hosts }}
+        -server-addr={{ quote (include "consul.externalServerHost" $firstHost) }} \
+        -server-port={{ include "consul.externalServerHTTPSPort" (list . $firstHost) }} \
+        {{- with (include "consul.externalServerTLSServerName" (list . $firstHost)) }}
+        -tls-server-name={{ . }} \
         {{- end }}
         {{- else }}
         -server-addr={{ template "consul.fullname" . }}-server \
@@ -220,3 +324,338 @@ Usage: {{ template "consul.reservedNamesFailer" (list .Values.key "key") }}
 {{- fail (cat "The name" $name "set for key" $key "is reserved by Consul for future use." ) }}
 {{- end }}
 {{- end -}}
+
+{{/*
+Fails if global.tls.minVersion isn't a TLS version that Consul and Envoy
+support, or if global.tls.cipherSuites is set with TLS 1.3, which doesn't
+support configuring cipher suites.
+
+Usage: {{ template "consul.tlsPolicyFailer" . }}
+
+*/}}
+{{- define "consul.tlsPolicyFailer" -}}
+{{- if .Values.global.tls.minVersion }}
+{{- if not (has .Values.global.tls.minVersion (list "TLSv1_0" "TLSv1_1" "TLSv1_2" "TLSv1_3")) }}
+{{- fail "global.tls.minVersion must be one of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3" }}
+{{- end }}
+{{- if (and (eq .Values.global.tls.minVersion "TLSv1_3") .Values.global.tls.cipherSuites) }}
+{{- fail "global.tls.cipherSuites can't be set when global.tls.minVersion is TLSv1_3" }}
+{{- end }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Renders the pod securityContext of a Consul workload that complies with the
+restricted Pod Security Standard. On OpenShift the user and group are left
+for the restricted-v2 SCC to assign.
+
+Usage: {{ include "consul.restrictedPodSecurityContext" . }}
+
+*/}}
+{{- define "consul.restrictedPodSecurityContext" -}}
+runAsNonRoot: true
+{{- if not .Values.global.openshift.enabled }}
+runAsUser: 100
+runAsGroup: 1000
+fsGroup: 1000
+{{- end }}
+seccompProfile:
+  type: RuntimeDefault
+{{- end -}}
+
+{{/*
+Renders the securityContext of a container of a Consul workload that complies
+with the restricted Pod Security Standard.
+
+Usage: {{ include "consul.restrictedContainerSecurityContext" . }}
+
+*/}}
+{{- define "consul.restrictedContainerSecurityContext" -}}
+allowPrivilegeEscalation: false
+capabilities:
+  drop:
+  - ALL
+{{- if .Values.global.restrictedPodSecurity.readOnlyRootFilesystem }}
+readOnlyRootFilesystem: true
+{{- end }}
+{{- end -}}
+
+{{/*
+Fails if global.ipFamilies.registration or global.ipFamilies.listener isn't an
+address family, or is IPv6 without global.ipFamilies.enableIPv6.
+
+Usage: {{ template "consul.ipFamiliesFailer" . }}
+
+*/}}
+{{- define "consul.ipFamiliesFailer" -}}
+{{- range $key := list "registration" "listener" }}
+{{- $family := get $.Values.global.ipFamilies $key }}
+{{- if not (has $family (list "IPv4" "IPv6")) }}
+{{- fail (printf "global.ipFamilies.%s must be one of IPv4 or IPv6" $key) }}
+{{- end }}
+{{- if (and (eq $family "IPv6") (not $.Values.global.ipFamilies.enableIPv6)) }}
+{{- fail (printf "global.ipFamilies.enableIPv6 must be true if global.ipFamilies.%s is IPv6" $key) }}
+{{- end }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Renders the reference to an IP environment variable for the host of a host:port
+address. If global.ipFamilies.enableIPv6 is true it's bracketed because the IP
+can be an IPv6 address. This template accepts an array that contains two
+elements: the root context and the reference to the environment variable.
+
+Usage: {{ include "consul.hostPortIP" (list . "$(HOST_IP)") }}
+
+*/}}
+{{- define "consul.hostPortIP" -}}
+{{- $root := index . 0 -}}
+{{- if $root.Values.global.ipFamilies.enableIPv6 -}}
+[{{ index . 1 }}]
+{{- else -}}
+{{ index . 1 }}
+{{- end -}}
+{{- end -}}
+
+{{/*
+Renders the shell commands that set POD_IP to the one of the POD_IPS
+environment variable, which is set from status.podIPs, of the
+global.ipFamilies.registration address family. POD_IP is kept if the pod
+has no IP of the family.
+
+Usage: {{ template "consul.registrationPodIP" . }}
+
+*/}}
+{{- define "consul.registrationPodIP" -}}
+REGISTRATION_POD_IP="$(echo "${POD_IPS}" | tr ',' '\n' | grep {{ if eq .Values.global.ipFamilies.registration "IPv4" }}-v {{ end }}':' | head -n 1)"
+POD_IP="${REGISTRATION_POD_IP:-${POD_IP}}"
+{{- end -}}
+
+{{/*
+Renders the spec of a HorizontalPodAutoscaler for a gateway Deployment.
+This template accepts an array that contains four elements: the autoscaling
+values of the gateway, the number of replicas of the gateway, the name of the
+values.yaml key of the autoscaling values, and the name of the Deployment.
+minReplicas defaults to the number of replicas of the gateway and the
+Kubernetes default of 80% CPU utilization is the target if no metrics are set.
+
+Usage: {{ template "consul.autoscalingSpec" (list .Values.key.autoscaling .Values.key.replicas "key.autoscaling" $name) }}
+
+*/}}
+{{- define "consul.autoscalingSpec" -}}
+{{- $autoscaling := index . 0 -}}
+{{- $minReplicas := default (index . 1) $autoscaling.minReplicas -}}
+{{- $key := index . 2 -}}
+{{- $name := index . 3 -}}
+{{- if not $autoscaling.maxReplicas }}{{ fail (printf "%s.maxReplicas must be set" $key) }}{{ end -}}
+{{- if lt (int $autoscaling.maxReplicas) (int $minReplicas) }}{{ fail (printf "%s.maxReplicas must be greater than or equal to %s.minReplicas" $key $key) }}{{ end -}}
+scaleTargetRef:
+  apiVersion: apps/v1
+  kind: Deployment
+  name: {{ $name }}
+minReplicas: {{ $minReplicas }}
+maxReplicas: {{ $autoscaling.maxReplicas }}
+{{- if (or $autoscaling.targetCPUUtilizationPercentage $autoscaling.targetMemoryUtilizationPercentage $autoscaling.metrics) }}
+metrics:
+{{- if $autoscaling.targetCPUUtilizationPercentage }}
+- type: Resource
+  resource:
+    name: cpu
+    target:
+      type: Utilization
+      averageUtilization: {{ $autoscaling.targetCPUUtilizationPercentage }}
+{{- end }}
+{{- if $autoscaling.targetMemoryUtilizationPercentage }}
+- type: Resource
+  resource:
+    name: memory
+    target:
+      type: Utilization
+      averageUtilization: {{ $autoscaling.targetMemoryUtilizationPercentage }}
+{{- end }}
+{{- with $autoscaling.metrics }}
+{{ toYaml . }}
+{{- end }}
+{{- end }}
+{{- with $autoscaling.behavior }}
+behavior:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Prints the secrets that are mounted into the server pods from the Secrets Store
+CSI driver if global.secretsBackend.csi.enabled is true, keyed by the name of the
+file they are mounted as. Prints nothing if no secrets are mounted.
+*/}}
+{{- define "consul.csiServerSecrets" -}}
+{{- if .Values.global.secretsBackend.csi.enabled }}
+{{- with .Values.global.gossipEncryption }}{{ if .secretName }}
+gossip.txt: {{ dict "secretName" .secretName "secretKey" (.secretKey | toString) | toJson }}
+{{- end }}{{ end }}
+{{- with .Values.global.enterpriseLicense }}{{ if (and .secretName .enableLicenseAutoload) }}
+enterpriselicense.txt: {{ dict "secretName" .secretName "secretKey" (.secretKey | toString) | toJson }}
+{{- end }}{{ end }}
+{{- with .Values.global.acls.bootstrapToken }}{{ if .secretName }}
+bootstrap-token: {{ dict "secretName" .secretName "secretKey" (.secretKey | toString) | toJson }}
+{{- end }}{{ end }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Prints the secrets that are mounted into the client pods from the Secrets Store
+CSI driver, like consul.csiServerSecrets.
+*/}}
+{{- define "consul.csiClientSecrets" -}}
+{{- if .Values.global.secretsBackend.csi.enabled }}
+{{- with .Values.global.gossipEncryption }}{{ if .secretName }}
+gossip.txt: {{ dict "secretName" .secretName "secretKey" (.secretKey | toString) | toJson }}
+{{- end }}{{ end }}
+{{- with .Values.global.enterpriseLicense }}{{ if (and .secretName .enableLicenseAutoload (not $.Values.global.acls.manageSystemACLs)) }}
+enterpriselicense.txt: {{ dict "secretName" .secretName "secretKey" (.secretKey | toString) | toJson }}
+{{- end }}{{ end }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Renders the parameters of a SecretProviderClass that mounts secrets from the
+provider in global.secretsBackend.csi.provider. The secrets are given as printed
+by consul.csiServerSecrets or consul.csiClientSecrets.
+
+Usage: {{ include "consul.csiSecretProviderClassParameters" (list . (include "consul.csiServerSecrets" .)) }}
+*/}}
+{{- define "consul.csiSecretProviderClassParameters" -}}
+{{- $csi := (index . 0).Values.global.secretsBackend.csi -}}
+{{- $secrets := (index . 1) | fromYaml -}}
+{{- range $key, $value := $csi.parameters }}
+{{ $key }}: {{ $value | quote }}
+{{- end }}
+{{- if eq $csi.provider "aws" }}
+{{- /* AWS requires a single object per secret, with a path for each of its keys. */}}
+{{- $objects := dict }}
+{{- range $file, $secret := $secrets }}
+{{- $_ := set $objects $secret.secretName (append (get $objects $secret.secretName | default list) (dict "path" $secret.secretKey "file" $file)) }}
+{{- end }}
+objects: |
+  {{- range $name, $paths := $objects }}
+  - objectName: {{ $name | quote }}
+    objectType: secretsmanager
+    objectAlias: {{ printf "aws-%s.json" ($name | replace "/" "-") | quote }}
+    jmesPath:
+      {{- range $paths }}
+      - path: {{ .path | quote }}
+        objectAlias: {{ .file | quote }}
+      {{- end }}
+  {{- end }}
+{{- else if eq $csi.provider "gcp" }}
+secrets: |
+  {{- range $file, $secret := $secrets }}
+  - resourceName: {{ printf "%s/versions/%s" $secret.secretName $secret.secretKey | quote }}
+    path: {{ $file | quote }}
+  {{- end }}
+{{- else if eq $csi.provider "azure" }}
+objects: |
+  array:
+  {{- range $file, $secret := $secrets }}
+    - |
+      objectName: {{ $secret.secretName }}
+      objectType: secret
+      objectAlias: {{ $file }}
+      {{- if ne $secret.secretKey "latest" }}
+      objectVersion: {{ $secret.secretKey | quote }}
+      {{- end }}
+  {{- end }}
+{{- else }}
+{{- fail "global.secretsBackend.csi.provider must be one of aws, gcp or azure" }}
+{{- end }}
+{{- end -}}
+
+{{/*
+Returns the labels of the PodMonitors with global.metrics.prometheusOperator.labels,
+which take precedence, e.g. to set the release label to the one the
+podMonitorSelector of the Prometheus resource matches.
+
+Usage: {{- include "consul.podMonitorLabels" (dict "root" . "component" "server") | nindent 4 }}
+*/}}
+{{- define "consul.podMonitorLabels" -}}
+{{- $labels := dict "app" (include "consul.name" .root) "chart" (include "consul.chart" .root) "heritage" .root.Release.Service "release" .root.Release.Name "component" .component -}}
+{{- toYaml (merge (deepCopy .root.Values.global.metrics.prometheusOperator.labels) $labels) }}
+{{- end -}}
+
+{{/*
+Returns the host that Consul and Envoy ship their metrics and traces to:
+telemetryCollector.existingCollectorHost if set and the Service of the
+chart's collector otherwise.
+
+Usage: -tracing-zipkin-address={{ template "consul.telemetryCollectorHost" . }}:9411
+*/}}
+{{- define "consul.telemetryCollectorHost" -}}
+{{- if .Values.telemetryCollector.existingCollectorHost }}{{ .Values.telemetryCollector.existingCollectorHost }}{{ else }}{{ template "consul.fullname" . }}-telemetry-collector.{{ .Release.Namespace }}.svc{{ end }}
+{{- end -}}
+
+{{/*
+Returns the address of DogStatsD of the Datadog Agent on the node, which the
+Consul servers and clients send their metrics to. The IP of the node is
+expanded from the HOST_IP environment variable by the shell.
+
+Usage: -hcl="telemetry { dogstatsd_addr = \"{{ template "consul.datadogDogstatsdAddr" . }}\" }"
+*/}}
+{{- define "consul.datadogDogstatsdAddr" -}}
+{{- $dogstatsd := .Values.global.metrics.datadog.dogstatsd -}}
+{{- if eq $dogstatsd.socketTransportType "UDS" -}}
+unix://{{ $dogstatsd.dogstatsdAddr }}
+{{- else if eq $dogstatsd.socketTransportType "UDP" -}}
+{{ include "consul.hostPortIP" (list . "${HOST_IP}") }}:{{ $dogstatsd.dogstatsdPort }}
+{{- else -}}
+{{ fail "global.metrics.datadog.dogstatsd.socketTransportType must be either UDS or UDP" }}
+{{- end -}}
+{{- end -}}
+
+{{/*
+Fails if client.snapshotAgent.destination has more than one destination or a
+destination is missing required settings.
+
+Usage: {{ template "consul.snapshotAgentValidateDestination" . }}
+*/}}
+{{- define "consul.snapshotAgentValidateDestination" -}}
+{{- $destination := .Values.client.snapshotAgent.destination -}}
+{{- $count := 0 -}}
+{{- range (list $destination.s3.bucket $destination.gcs.bucket $destination.azure.containerName) }}{{ if . }}{{ $count = add1 $count }}{{ end }}{{ end -}}
+{{- if gt $count 1 }}{{ fail "only one of client.snapshotAgent.destination.s3, client.snapshotAgent.destination.gcs and client.snapshotAgent.destination.azure can be configured" }}{{ end -}}
+{{- if (and $destination.gcs.bucket $destination.gcs.credentials.secretName (not $destination.gcs.credentials.secretKey)) }}{{ fail "client.snapshotAgent.destination.gcs.credentials.secretKey must be set with client.snapshotAgent.destination.gcs.credentials.secretName" }}{{ end -}}
+{{- if (and $destination.azure.containerName (not (and $destination.azure.accountName $destination.azure.credentials.secretName $destination.azure.credentials.secretKey))) }}{{ fail "client.snapshotAgent.destination.azure requires accountName, credentials.secretName and credentials.secretKey" }}{{ end -}}
+{{- end -}}
+
+{{/*
+Returns the environment variables with the storage credentials of
+client.snapshotAgent.destination for the snapshot agent and verification pods.
+
+Usage: {{- with (include "consul.snapshotAgentCredentialsEnv" .) }}{{ trim . | nindent 8 }}{{- end }}
+*/}}
+{{- define "consul.snapshotAgentCredentialsEnv" -}}
+{{- with .Values.client.snapshotAgent.destination }}
+{{- if (and .s3.bucket .s3.credentials.secretName) }}
+- name: AWS_ACCESS_KEY_ID
+  valueFrom:
+    secretKeyRef:
+      name: {{ .s3.credentials.secretName }}
+      key: {{ .s3.credentials.accessKeyIdKey }}
+- name: AWS_SECRET_ACCESS_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ .s3.credentials.secretName }}
+      key: {{ .s3.credentials.secretAccessKeyKey }}
+{{- end }}
+{{- if (and .gcs.bucket .gcs.credentials.secretName) }}
+- name: GOOGLE_APPLICATION_CREDENTIALS
+  value: /consul/gcs/credentials.json
+{{- end }}
+{{- if .azure.containerName }}
+- name: AZURE_BLOB_ACCOUNT_KEY
+  valueFrom:
+    secretKeyRef:
+      name: {{ .azure.credentials.secretName }}
+      key: {{ .azure.credentials.secretKey }}
+{{- end }}
+{{- end }}
+{{- end -}}
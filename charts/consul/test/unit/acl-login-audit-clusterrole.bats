@@ -0,0 +1,56 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclLoginAudit/ClusterRole: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-clusterrole.yaml  \
+      .
+}
+
+@test "aclLoginAudit/ClusterRole: enabled with global.acls.loginAudit.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-clusterrole.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/ClusterRole: can't patch service accounts by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-clusterrole.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq '[.rules[] | select(.resources[0] == "serviceaccounts")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "aclLoginAudit/ClusterRole: can patch service accounts with global.acls.loginAudit.annotateServiceAccounts=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-clusterrole.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.acls.loginAudit.annotateServiceAccounts=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[] | select(.resources[0] == "serviceaccounts") | .verbs[0]' | tee /dev/stderr)
+  [ "${actual}" = "patch" ]
+}
+
+@test "aclLoginAudit/ClusterRole: allows podsecuritypolicies access with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-clusterrole.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[] | select(.resources[0] == "podsecuritypolicies") | .resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-acl-login-audit" ]
+}
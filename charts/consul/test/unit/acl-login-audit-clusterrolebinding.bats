@@ -0,0 +1,21 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclLoginAudit/ClusterRoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-clusterrolebinding.yaml  \
+      .
+}
+
+@test "aclLoginAudit/ClusterRoleBinding: enabled with global.acls.loginAudit.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-clusterrolebinding.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,163 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclLoginAudit/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      .
+}
+
+@test "aclLoginAudit/Deployment: enabled with global.acls.loginAudit.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: fails without global.acls.manageSystemACLs=true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.loginAudit.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.loginAudit.enabled requires global.acls.manageSystemACLs to be true" ]]
+}
+
+@test "aclLoginAudit/Deployment: audits the component auth method" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-auth-method=release-name-consul-k8s-component-auth-method")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-auth-method=release-name-consul-k8s-auth-method ")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-k8s-namespace=default")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-check-interval=5m")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-annotate-service-accounts")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "aclLoginAudit/Deployment: audits the connect inject auth method with connectInject.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-auth-method=release-name-consul-k8s-auth-method ")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: audits the connect inject auth method in the destination namespace" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'connectInject.consulNamespaces.consulDestinationNamespace=foo' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-auth-method=release-name-consul-k8s-auth-method.foo ")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-enable-namespaces=true")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: audits connectInject.overrideAuthMethodName" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.overrideAuthMethodName=override' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-auth-method=override ")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: can set global.acls.loginAudit.checkInterval and annotateServiceAccounts" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.acls.loginAudit.checkInterval=1h' \
+      --set 'global.acls.loginAudit.annotateServiceAccounts=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-check-interval=1h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-annotate-service-accounts=true")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: logs in with the component auth method" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers[0].command[2] | contains("-component-name=acl-login-audit")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[0].env[] | select(.name == "CONSUL_HTTP_TOKEN_FILE") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/login/acl-token" ]
+}
+
+@test "aclLoginAudit/Deployment: adds Prometheus scrape annotations with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclLoginAudit/Deployment: no Prometheus scrape annotations with global.acls.loginAudit.metrics.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.acls.loginAudit.metrics.enabled=false' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
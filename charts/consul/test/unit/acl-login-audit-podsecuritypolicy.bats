@@ -0,0 +1,31 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclLoginAudit/PodSecurityPolicy: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-podsecuritypolicy.yaml  \
+      .
+}
+
+@test "aclLoginAudit/PodSecurityPolicy: disabled with global.enablePodSecurityPolicies=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-podsecuritypolicy.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      .
+}
+
+@test "aclLoginAudit/PodSecurityPolicy: enabled with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-podsecuritypolicy.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,21 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclLoginAudit/ServiceAccount: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-login-audit-serviceaccount.yaml  \
+      .
+}
+
+@test "aclLoginAudit/ServiceAccount: enabled with global.acls.loginAudit.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-login-audit-serviceaccount.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
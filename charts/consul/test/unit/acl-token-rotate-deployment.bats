@@ -0,0 +1,84 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclTokenRotate/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      .
+}
+
+@test "aclTokenRotate/Deployment: enabled with global.acls.tokenRotation.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclTokenRotate/Deployment: fails without global.acls.manageSystemACLs=true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.tokenRotation.enabled requires global.acls.manageSystemACLs to be true" ]]
+}
+
+@test "aclTokenRotate/Deployment: rotates the tokens of the component deployments" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-selector=\"app=consul,release=release-name,component in (api-gateway-controller,client-snapshot-agent,connect-injector,controller,ingress-gateway,mesh-gateway,sync-catalog,terminating-gateway)\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-rotation-period=168h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclTokenRotate/Deployment: can set global.acls.tokenRotation.period" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      --set 'global.acls.tokenRotation.period=24h' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2] | contains("-rotation-period=24h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclTokenRotate/Deployment: adds Prometheus scrape annotations with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclTokenRotate/Deployment: no Prometheus scrape annotations with global.acls.tokenRotation.metrics.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-deployment.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      --set 'global.acls.tokenRotation.metrics.enabled=false' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
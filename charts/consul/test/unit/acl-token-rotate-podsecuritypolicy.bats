@@ -0,0 +1,29 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclTokenRotate/PodSecurityPolicy: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-token-rotate-podsecuritypolicy.yaml  \
+      .
+}
+
+@test "aclTokenRotate/PodSecurityPolicy: disabled with global.enablePodSecurityPolicies=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-token-rotate-podsecuritypolicy.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      .
+}
+
+@test "aclTokenRotate/PodSecurityPolicy: enabled with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-podsecuritypolicy.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "aclTokenRotate/Role: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/acl-token-rotate-role.yaml  \
+      .
+}
+
+@test "aclTokenRotate/Role: enabled with global.acls.tokenRotation.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-role.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "aclTokenRotate/Role: allows listing and patching deployments" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-role.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "list,patch" ]
+}
+
+@test "aclTokenRotate/Role: allows using the pod security policy with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/acl-token-rotate-role.yaml  \
+      --set 'global.acls.tokenRotation.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[2].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-acl-token-rotate" ]
+}
@@ -0,0 +1,72 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "apiGateway/GatewayClassConfig: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      .
+}
+
+@test "apiGateway/GatewayClassConfig: disable with apiGateway.managedGatewayClass.enabled" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'apiGateway.managedGatewayClass.enabled=false' \
+      .
+}
+
+#--------------------------------------------------------------------
+# nodeSelector
+
+@test "apiGateway/GatewayClassConfig: no nodeSelector by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      . | tee /dev/stderr |
+      yq '.spec.nodeSelector' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "apiGateway/GatewayClassConfig: nodeSelector can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'apiGateway.managedGatewayClass.nodeSelector=node: edge' \
+      . | tee /dev/stderr |
+      yq -r '.spec.nodeSelector.node' | tee /dev/stderr)
+  [ "${actual}" = "edge" ]
+}
+
+#--------------------------------------------------------------------
+# tolerations
+
+@test "apiGateway/GatewayClassConfig: no tolerations by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      . | tee /dev/stderr |
+      yq '.spec.tolerations' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "apiGateway/GatewayClassConfig: tolerations can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/api-gateway-gatewayclassconfig.yaml  \
+      --set 'apiGateway.enabled=true' \
+      --set 'apiGateway.image=foo' \
+      --set 'apiGateway.managedGatewayClass.tolerations=- key: value' \
+      . | tee /dev/stderr |
+      yq -r '.spec.tolerations[0].key' | tee /dev/stderr)
+  [ "${actual}" = "value" ]
+}
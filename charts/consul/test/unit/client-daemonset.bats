@@ -142,6 +142,46 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "client/DaemonSet: retry join uses externalServers.hosts in a non-default admin partition" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0].host=foo' \
+      --set 'externalServers.hosts[1]=bar' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=test' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r ' . | any(contains("-retry-join=\"foo:8301\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r ' . | any(contains("-retry-join=\"bar:8301\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/DaemonSet: client.join takes precedence over externalServers.hosts in a non-default admin partition" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=foo' \
+      --set 'client.join[0]=1.1.1.1' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=test' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r ' . | any(contains("-retry-join=\"1.1.1.1\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r ' . | any(contains("-retry-join=\"foo:8301\""))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # grpc
 
@@ -528,6 +568,39 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "client/DaemonSet: dogstatsd_addr is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr")' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "client/DaemonSet: sets dogstatsd_addr to the chart's collector when telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'telemetryCollector.enabled=true'  \
+      --set 'telemetryCollector.clusterName=c1'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("telemetry { dogstatsd_addr = \"release-name-consul-telemetry-collector.default.svc:8125\" }")' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "client/DaemonSet: sets dogstatsd_addr to telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("telemetry { dogstatsd_addr = \"otel.observability.svc:8125\" }")' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
 @test "client/DaemonSet: when global.metrics.enableAgentMetrics=true, global.tls.enabled=true and global.tls.httpsOnly=true, fail" {
   cd `chart_dir`
   run helm template \
@@ -542,6 +615,75 @@ load _helpers
   [[ "$output" =~ "global.metrics.enableAgentMetrics cannot be enabled if TLS (HTTPS only) is enabled" ]]
 }
 
+#--------------------------------------------------------------------
+# global.metrics.datadog
+
+@test "client/DaemonSet: DogStatsD isn't configured by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "client/DaemonSet: sends telemetry to the DogStatsD socket when global.metrics.datadog.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.dogstatsdTags[0]=env:prod'  \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr = \\\"unix:///var/run/datadog/dsd.socket\\\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_tags = [\"source:consul\",\"consul_service:consul-client\",\"env:prod\"], disable_hostname = true")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.spec.template.spec.volumes[] | select(.name == "datadog-dsd-socket") | .hostPath.path' | tee /dev/stderr)
+  [ "${actual}" = "/var/run/datadog" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.spec.template.spec.containers[0].volumeMounts[] | select(.name == "datadog-dsd-socket") | .readOnly' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/DaemonSet: sends telemetry to DogStatsD over UDP when global.metrics.datadog.dogstatsd.socketTransportType=UDP" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=UDP'  \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr = \\\"${HOST_IP}:8125\\\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.volumes | map(select(.name == "datadog-dsd-socket")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "client/DaemonSet: fails when global.metrics.datadog.dogstatsd.socketTransportType is invalid" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=TCP'  \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.metrics.datadog.dogstatsd.socketTransportType must be" ]]
+}
+
 #--------------------------------------------------------------------
 # config-configmap
 
@@ -796,6 +938,46 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "client/DaemonSet: TLS minimum version and cipher suites are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("tls { defaults")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "client/DaemonSet: sets the TLS minimum version and cipher suites with global.tls.minVersion and global.tls.cipherSuites" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_2' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      --set 'global.tls.cipherSuites[1]=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo $command | jq -r '. | contains("tls { defaults { tls_min_version = \"TLSv1_2\" } }")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | contains("tls { defaults { tls_cipher_suites = \"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256\" } }")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/DaemonSet: fails with global.tls.cipherSuites and global.tls.minVersion=TLSv1_3" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_3' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.cipherSuites can't be set when global.tls.minVersion is TLSv1_3" ]]
+}
+
 @test "client/DaemonSet: init container is created when global.tls.enabled=true" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -1200,6 +1382,28 @@ local actual=$(echo $object |
   [ "${actual}" = "true" ]
 }
 
+@test "client/DaemonSet: init container logs in to the AWS IAM component auth method when global.acls.awsIAMAuthMethod.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/*' \
+      --set 'global.acls.awsIAMAuthMethod.stsRegion=us-west-2' \
+      --set 'global.acls.awsIAMAuthMethod.stsEndpoint=https://sts.us-west-2.amazonaws.com' \
+      --set 'global.acls.awsIAMAuthMethod.serverIDHeaderValue=consul.example.com' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[] | select(.name == "client-acl-init")' | tee /dev/stderr)
+
+  local actual=$(echo $object |
+      yq -r '.command | any(contains("acl-auth-method=\"release-name-consul-aws-iam-component-auth-method\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object |
+      yq -r '.command | any(contains("-aws-iam-login -aws-sts-region=us-west-2 -aws-sts-endpoint=https://sts.us-west-2.amazonaws.com -aws-iam-server-id-header-value=consul.example.com"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "client/DaemonSet: CONSUL_HTTP_TOKEN_FILE is not set when acls are disabled" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -1348,6 +1552,19 @@ local actual=$(echo $object |
   [ "${actual}" = "true" ]
 }
 
+@test "client/DaemonSet: server-address flag is set with the host of externalServers.hosts maps" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'externalServers.enabled=true'  \
+      --set 'server.enabled=false' \
+      --set 'externalServers.hosts[0].host=foo'  \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.initContainers[0].command | any(contains("-server-address=\"foo\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "client/DaemonSet: tls-server-name flag is set when externalServers.tlsServerName is provided" {
   cd `chart_dir`
   local command=$(helm template \
@@ -2411,6 +2628,23 @@ rollingUpdate:
   [ "${actual}" = "bar" ]
 }
 
+@test "client/DaemonSet: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 #--------------------------------------------------------------------
 # global.imageK8s
 
@@ -2424,3 +2658,83 @@ rollingUpdate:
   [ "$status" -eq 1 ]
   [[ "$output" =~ "global.imageK8s is not a valid key, use global.imageK8S (note the capital 'S')" ]]
 }
+
+#--------------------------------------------------------------------
+# CSI secrets backend
+
+@test "client/DaemonSet: gossip key and license are read from the CSI volume" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/client-daemonset.yaml  \
+    --set 'global.secretsBackend.csi.enabled=true' \
+    --set 'global.secretsBackend.csi.provider=aws' \
+    --set 'global.gossipEncryption.secretName=consul' \
+    --set 'global.gossipEncryption.secretKey=gossip' \
+    --set 'global.enterpriseLicense.secretName=consul' \
+    --set 'global.enterpriseLicense.secretKey=license' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "consul-secrets") | .csi.volumeAttributes.secretProviderClass' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-client" ]
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "consul-license")' | tee /dev/stderr)
+  [ "${actual}" = "" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .volumeMounts[] | select(.name == "consul-secrets") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/secrets" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | [.env[].name] | any(. == "GOSSIP_KEY")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .env[] | select(.name == "CONSUL_LICENSE_PATH") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/secrets/enterpriselicense.txt" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .command | any(contains("GOSSIP_KEY=`cat /consul/secrets/gossip.txt`"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "client/DaemonSet: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+
+  local actual=$(echo "$spec" | jq -r '[.containers[], .initContainers[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.initContainers | length')
+  [ "${actual}" = "2" ]
+}
+
+#--------------------------------------------------------------------
+# global.ipFamilies
+
+@test "client/DaemonSet: binds to the IPv4 addresses by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ") | contains("-bind=0.0.0.0") and contains("-client=0.0.0.0")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/DaemonSet: binds to the IPv6 addresses when global.ipFamilies.enableIPv6=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-daemonset.yaml  \
+      --set 'global.ipFamilies.enableIPv6=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ") | contains("-bind=:: ") and contains("-client=:: ")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
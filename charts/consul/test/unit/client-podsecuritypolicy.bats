@@ -164,3 +164,35 @@ load _helpers
       yq '.spec.hostNetwork == false' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# global.metrics.datadog
+
+@test "client/PodSecurityPolicy: allows the DogStatsD socket directory when global.metrics.datadog.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-podsecuritypolicy.yaml  \
+      --set 'global.enablePodSecurityPolicies=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq '.spec.volumes | any(contains("hostPath"))' | tee /dev/stderr)
+  [ "${actual}" = 'true' ]
+
+  local actual=$(echo "$object" | yq -c '.spec.allowedHostPaths' | tee /dev/stderr)
+  [ "${actual}" = '[{"pathPrefix":"/var/run/datadog","readOnly":true}]' ]
+}
+
+@test "client/PodSecurityPolicy: disallows hostPath volume when global.metrics.datadog.dogstatsd.socketTransportType=UDP" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-podsecuritypolicy.yaml  \
+      --set 'global.enablePodSecurityPolicies=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=UDP' \
+      . | tee /dev/stderr |
+      yq '.spec.volumes | any(contains("hostPath"))' | tee /dev/stderr)
+  [ "${actual}" = 'false' ]
+}
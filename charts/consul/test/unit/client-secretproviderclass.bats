@@ -0,0 +1,71 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "client/SecretProviderClass: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-secretproviderclass.yaml  \
+      --set 'global.gossipEncryption.secretName=consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' \
+      .
+}
+
+@test "client/SecretProviderClass: disabled with client.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-secretproviderclass.yaml  \
+      --set 'client.enabled=false' \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.gossipEncryption.secretName=consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' \
+      .
+}
+
+@test "client/SecretProviderClass: does not mount the bootstrap token" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.secretName=consul' \
+      --set 'global.acls.bootstrapToken.secretKey=token' \
+      .
+}
+
+@test "client/SecretProviderClass: mounts the gossip key and license" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=gcp' \
+      --set 'global.gossipEncryption.secretName=projects/consul/secrets/gossip' \
+      --set 'global.gossipEncryption.secretKey=latest' \
+      --set 'global.enterpriseLicense.secretName=projects/consul/secrets/license' \
+      --set 'global.enterpriseLicense.secretKey=2' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.metadata.name')
+  [ "${actual}" = "release-name-consul-client" ]
+
+  local actual=$(echo "$object" | yq -r '.spec.parameters.secrets' | yq -c '[.[].path]')
+  [ "${actual}" = '["enterpriselicense.txt","gossip.txt"]' ]
+}
+
+@test "client/SecretProviderClass: does not mount the license with manageSystemACLs" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=gcp' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.gossipEncryption.secretName=projects/consul/secrets/gossip' \
+      --set 'global.gossipEncryption.secretKey=latest' \
+      --set 'global.enterpriseLicense.secretName=projects/consul/secrets/license' \
+      --set 'global.enterpriseLicense.secretKey=2' \
+      . | tee /dev/stderr |
+      yq -r '.spec.parameters.secrets' | yq -c '[.[].path]')
+  [ "${actual}" = '["gossip.txt"]' ]
+}
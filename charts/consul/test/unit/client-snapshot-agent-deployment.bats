@@ -979,6 +979,28 @@ MIICFjCCAZsCCQCdwLtdjbzlYzAKBggqhkjOPQQDAjB0MQswCQYDVQQGEwJDQTEL' \
   [ "${actual}" = "bar" ]
 }
 
+@test "client/SnapshotAgentDeployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 
 @test "client/SnapshotAgentDeployment: vault properly sets vault role when global.secretsBackend.vault.consulCARole is set but global.secretsBackend.vault.consulSnapshotAgentRole is not set" {
   cd `chart_dir`
@@ -1041,3 +1063,155 @@ MIICFjCCAZsCCQCdwLtdjbzlYzAKBggqhkjOPQQDAjB0MQswCQYDVQQGEwJDQTEL' \
   actual=$(echo $object | jq -r '.metadata.annotations["vault.hashicorp.com/role"]' | tee /dev/stderr)
   [ "${actual}" = "sa-role" ]
 }
+
+@test "client/SnapshotAgentDeployment: license is read from the CSI volume" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.enterpriseLicense.secretName=consul' \
+      --set 'global.enterpriseLicense.secretKey=license' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "consul-license") | .csi.volumeAttributes.secretProviderClass' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-client" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].env[] | select(.name == "CONSUL_LICENSE_PATH") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/license/enterpriselicense.txt" ]
+}
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.interval and client.snapshotAgent.retain
+
+@test "client/SnapshotAgentDeployment: -interval and -retain are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2] | (contains("-interval") or contains("-retain"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "client/SnapshotAgentDeployment: -interval and -retain are set" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.interval=1h' \
+      --set 'client.snapshotAgent.retain=0' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$command" | yq 'contains("-interval=1h \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$command" | yq 'contains("-retain=0 \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.destination
+
+@test "client/SnapshotAgentDeployment: S3 destination" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'client.snapshotAgent.destination.s3.region=us-east-1' \
+      --set 'client.snapshotAgent.destination.s3.endpoint=https://minio:9000' \
+      --set 'client.snapshotAgent.destination.s3.serverSideEncryption=true' \
+      --set 'client.snapshotAgent.destination.s3.credentials.secretName=aws' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-aws-s3-bucket=backups \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-aws-s3-region=us-east-1 \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-aws-s3-key-prefix=consul \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-aws-s3-endpoint=https://minio:9000 \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-aws-s3-server-side-encryption \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.env[] | select(.name == "AWS_ACCESS_KEY_ID") | .valueFrom.secretKeyRef.name + "/" + .valueFrom.secretKeyRef.key' | tee /dev/stderr)
+  [ "${actual}" = "aws/accessKeyId" ]
+  local actual=$(echo "$object" | yq -r '.env[] | select(.name == "AWS_SECRET_ACCESS_KEY") | .valueFrom.secretKeyRef.name + "/" + .valueFrom.secretKeyRef.key' | tee /dev/stderr)
+  [ "${actual}" = "aws/secretAccessKey" ]
+}
+
+@test "client/SnapshotAgentDeployment: GCS destination" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.gcs.bucket=backups' \
+      --set 'client.snapshotAgent.destination.gcs.credentials.secretName=gcs' \
+      --set 'client.snapshotAgent.destination.gcs.credentials.secretKey=key.json' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.containers[0].command[2] | contains("-gcs-bucket=backups \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[0].env[] | select(.name == "GOOGLE_APPLICATION_CREDENTIALS") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/gcs/credentials.json" ]
+
+  local actual=$(echo "$object" | yq -r '.volumes[] | select(.name == "gcs-credentials") | .secret.secretName + "/" + .secret.items[0].key' | tee /dev/stderr)
+  [ "${actual}" = "gcs/key.json" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[0].volumeMounts[] | select(.name == "gcs-credentials") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/gcs" ]
+}
+
+@test "client/SnapshotAgentDeployment: Azure destination" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.azure.accountName=account' \
+      --set 'client.snapshotAgent.destination.azure.containerName=backups' \
+      --set 'client.snapshotAgent.destination.azure.credentials.secretName=azure' \
+      --set 'client.snapshotAgent.destination.azure.credentials.secretKey=key' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-azure-blob-account-name=account \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-azure-blob-account-key=\"${AZURE_BLOB_ACCOUNT_KEY}\" \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-azure-blob-container-name=backups \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.env[] | select(.name == "AZURE_BLOB_ACCOUNT_KEY") | .valueFrom.secretKeyRef.name + "/" + .valueFrom.secretKeyRef.key' | tee /dev/stderr)
+  [ "${actual}" = "azure/key" ]
+}
+
+@test "client/SnapshotAgentDeployment: fails with more than one destination" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'client.snapshotAgent.destination.gcs.bucket=backups' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "only one of client.snapshotAgent.destination.s3, client.snapshotAgent.destination.gcs and client.snapshotAgent.destination.azure can be configured" ]]
+}
+
+@test "client/SnapshotAgentDeployment: fails with an incomplete Azure destination" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-snapshot-agent-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.azure.containerName=backups' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "client.snapshotAgent.destination.azure requires accountName, credentials.secretName and credentials.secretKey" ]]
+}
@@ -53,3 +53,36 @@ load _helpers
       yq -r '.rules[0].resources[0]' | tee /dev/stderr)
   [ "${actual}" = "podsecuritypolicies" ]
 }
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.verification
+
+@test "client/SnapshotAgentRole: no rules by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-role.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "client/SnapshotAgentRole: allows patching the deployment and creating events with client.snapshotAgent.verification.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-role.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.[0].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "podsecuritypolicies" ]
+  local actual=$(echo "$object" | yq -r '.[1].resources[0] + "/" + .[1].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "deployments/release-name-consul-snapshot-agent" ]
+  local actual=$(echo "$object" | yq -r '.[1].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "get,patch" ]
+  local actual=$(echo "$object" | yq -r '.[2].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "events" ]
+}
@@ -0,0 +1,260 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "client/SnapshotAgentVerifyDeployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      .
+}
+
+@test "client/SnapshotAgentVerifyDeployment: disabled with client.snapshotAgent.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      .
+}
+
+@test "client/SnapshotAgentVerifyDeployment: disabled with client.snapshotAgent.enabled=false and client.snapshotAgent.verification.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      .
+}
+
+@test "client/SnapshotAgentVerifyDeployment: disabled with client.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.enabled=false' \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      .
+}
+
+@test "client/SnapshotAgentVerifyDeployment: enabled with client.snapshotAgent.verification.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: fails without a destination" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "client.snapshotAgent.verification.enabled requires client.snapshotAgent.destination to be configured" ]]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: uses the service account of the snapshot agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.serviceAccountName' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-snapshot-agent" ]
+}
+
+#--------------------------------------------------------------------
+# command
+
+@test "client/SnapshotAgentVerifyDeployment: default command" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$command" | yq 'contains("-namespace=default \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-deployment=release-name-consul-snapshot-agent \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-interval=24h \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-s3-bucket=backups \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-s3-key-prefix=consul \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-consul-binary=/consul/bin/consul \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: GCS destination" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.gcs.bucket=backups' \
+      --set 'client.snapshotAgent.destination.gcs.credentials.secretName=gcs' \
+      --set 'client.snapshotAgent.destination.gcs.credentials.secretKey=key.json' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.containers[0].command[2] | contains("-gcs-bucket=backups \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].env[] | select(.name == "GOOGLE_APPLICATION_CREDENTIALS") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/gcs/credentials.json" ]
+  local actual=$(echo "$object" | yq -r '.volumes[] | select(.name == "gcs-credentials") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "gcs" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].volumeMounts[] | select(.name == "gcs-credentials") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/gcs" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: Azure destination" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.azure.accountName=account' \
+      --set 'client.snapshotAgent.destination.azure.containerName=backups' \
+      --set 'client.snapshotAgent.destination.azure.credentials.secretName=azure' \
+      --set 'client.snapshotAgent.destination.azure.credentials.secretKey=key' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-azure-blob-account-name=account \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.command[2] | contains("-azure-blob-container-name=backups \\\n")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.env[] | select(.name == "AZURE_BLOB_ACCOUNT_KEY") | .valueFrom.secretKeyRef.name' | tee /dev/stderr)
+  [ "${actual}" = "azure" ]
+}
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.verification.restore
+
+@test "client/SnapshotAgentVerifyDeployment: copies the consul binary by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'global.image=consul:test' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers[0].image' | tee /dev/stderr)
+  [ "${actual}" = "consul:test" ]
+  local actual=$(echo "$object" | yq -r '.volumes[] | select(.name == "consul-bin") | .emptyDir' | tee /dev/stderr)
+  [ "${actual}" = "{}" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].volumeMounts[] | select(.name == "consul-bin") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/bin" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: does not restore with client.snapshotAgent.verification.restore=false" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.verification.restore=false' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+  local actual=$(echo "$object" | yq -r '.volumes' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].command[2] | contains("-consul-binary")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: sets the license from the secret" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'global.enterpriseLicense.secretName=consul' \
+      --set 'global.enterpriseLicense.secretKey=license' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_LICENSE") | .valueFrom.secretKeyRef.name + "/" + .valueFrom.secretKeyRef.key' | tee /dev/stderr)
+  [ "${actual}" = "consul/license" ]
+}
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.verification.metrics
+
+@test "client/SnapshotAgentVerifyDeployment: no prometheus annotations by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations["prometheus.io/scrape"]' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: prometheus annotations with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.["prometheus.io/scrape"]' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.["prometheus.io/port"]' | tee /dev/stderr)
+  [ "${actual}" = "8080" ]
+}
+
+@test "client/SnapshotAgentVerifyDeployment: no prometheus annotations with client.snapshotAgent.verification.metrics.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.verification.metrics.enabled=false' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations["prometheus.io/scrape"]' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+#--------------------------------------------------------------------
+# client.snapshotAgent.verification.resources
+
+@test "client/SnapshotAgentVerifyDeployment: default resources" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/client-snapshot-agent-verify-deployment.yaml  \
+      --set 'client.snapshotAgent.enabled=true' \
+      --set 'client.snapshotAgent.verification.enabled=true' \
+      --set 'client.snapshotAgent.destination.s3.bucket=backups' \
+      . | tee /dev/stderr |
+      yq -rc '.spec.template.spec.containers[0].resources' | tee /dev/stderr)
+  [ "${actual}" = '{"limits":{"cpu":"500m","memory":"500Mi"},"requests":{"cpu":"50m","memory":"100Mi"}}' ]
+}
@@ -0,0 +1,52 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "connectInject/Certificate: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-certificate.yaml  \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "connectInject/Certificate: disabled with connectInject.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-certificate.yaml  \
+      --set 'connectInject.enabled=false' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      .
+}
+
+@test "connectInject/Certificate: fails if global.certManager.issuerRef.name is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-certificate.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.certManager.issuerRef.name must be set if global.certManager.enabled=true" ]]
+}
+
+@test "connectInject/Certificate: issued to the webhook secret for the webhook service" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/connect-inject-certificate.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $spec | jq -r '.secretName' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-connect-inject-webhook-cert" ]
+
+  local actual=$(echo $spec | jq -r '.dnsNames | index("release-name-consul-connect-injector.default.svc") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $spec | jq -r '.issuerRef.name' | tee /dev/stderr)
+  [ "${actual}" = "consul-ca" ]
+}
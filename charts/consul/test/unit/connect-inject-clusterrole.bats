@@ -53,3 +53,69 @@ load _helpers
       yq -r '.rules | map(select(.resources[0] == "podsecuritypolicies")) | length' | tee /dev/stderr)
   [ "${actual}" = "1" ]
 }
+
+@test "connectInject/ClusterRole: allows creating events" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "events")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create,patch" ]
+}
+
+#--------------------------------------------------------------------
+# locality
+
+@test "connectInject/ClusterRole: no nodes access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "nodes")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows reading nodes with connectInject.locality.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.locality.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "nodes")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "get,list,watch" ]
+}
+
+@test "connectInject/ClusterRole: allows reading nodes with connectInject.copyMetadata.nodeLabels" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.copyMetadata.nodeLabels[0].name=node.kubernetes.io/instance-type' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "nodes")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "get,list,watch" ]
+}
+
+@test "connectInject/ClusterRole: can't delete leases by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "leases")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create,get,list,update" ]
+}
+
+@test "connectInject/ClusterRole: allows deleting leases with connectInject.sharding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.sharding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "leases")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create,get,list,update,delete" ]
+}
@@ -310,6 +310,161 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# telemetryCollector
+
+@test "connectInject/Deployment: -tracing-zipkin-address is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-zipkin-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sets -tracing-zipkin-address to the chart's collector when telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-zipkin-address=release-name-consul-telemetry-collector.default.svc:9411"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: sets -tracing-zipkin-address to telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-zipkin-address=otel.observability.svc:9411"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -tracing-zipkin-address is not set when telemetryCollector.traces.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      --set 'telemetryCollector.traces.enabled=false' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-zipkin-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -tracing-otlp-address is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sets -tracing-otlp-address to the chart's collector when telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address=release-name-consul-telemetry-collector.default.svc:4317"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -tracing-otlp-address is not set when telemetryCollector.traces.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      --set 'telemetryCollector.traces.enabled=false' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sets -tracing-otlp-address to the Datadog Agent of the node when global.metrics.datadog.otlp.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.otlp.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address=${HOST_IP}:4317"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.metrics.datadog
+
+@test "connectInject/Deployment: Datadog isn't enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-datadog"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sets the DogStatsD socket when global.metrics.datadog.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.env=prod' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-enable-datadog=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-dogstatsd-socket-path=/var/run/datadog/dsd.socket"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-dogstatsd-port"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-env=prod"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: sets the DogStatsD port when global.metrics.datadog.dogstatsd.socketTransportType=UDP" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=UDP' \
+      --set 'global.metrics.datadog.dogstatsd.dogstatsdPort=8126' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-dogstatsd-port=8126"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-dogstatsd-socket-path"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-datadog-env"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # consul and envoy images
 
@@ -535,6 +690,122 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# projectedServiceAccountToken
+
+@test "connectInject/Deployment: -enable-projected-service-account-token is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-projected-service-account-token"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: projected service account token flags are set when connectInject.projectedServiceAccountToken.enabled is true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-projected-service-account-token=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-expiration=1h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: projected service account token expiration can be configured" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.enabled=true' \
+      --set 'connectInject.projectedServiceAccountToken.expiration=2h' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-projected-service-account-token-expiration=2h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# jobWatcher
+
+@test "connectInject/Deployment: -enable-job-watcher is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-job-watcher"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-job-watcher is set when connectInject.jobWatcher.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.jobWatcher.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-job-watcher=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: AWS IAM login flags are set when global.acls.awsIAMAuthMethod.connectInject is true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/*' \
+      --set 'global.acls.awsIAMAuthMethod.connectInject=true' \
+      --set 'global.acls.awsIAMAuthMethod.stsRegion=us-west-2' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-acl-auth-method=\"release-name-consul-aws-iam-auth-method\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-aws-iam-login=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-sts-region=us-west-2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: AWS IAM login flags are not set when only global.acls.awsIAMAuthMethod.enabled is true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/*' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-acl-auth-method=\"release-name-consul-k8s-auth-method\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-aws-iam-login"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # DNS
 
@@ -1730,6 +2001,112 @@ EOF
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# locality
+
+@test "connectInject/Deployment: -enable-locality is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-locality"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-locality is set when connectInject.locality.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.locality.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-locality=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# copyMetadata
+
+@test "connectInject/Deployment: -copy-metadata is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-copy-metadata"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -copy-metadata is set for each rule" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.copyMetadata.nodeLabels[0].name=node.kubernetes.io/instance-type' \
+      --set 'connectInject.copyMetadata.podLabels[0].name=app.kubernetes.io/*' \
+      --set 'connectInject.copyMetadata.podLabels[0].key=app-{{ .Key }}' \
+      --set 'connectInject.copyMetadata.podAnnotations[0].name=example.com/team' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'contains("-copy-metadata=\"node-label:node.kubernetes.io/instance-type\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'contains("-copy-metadata=\"pod-label:app.kubernetes.io/*=app-{{ .Key }}\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'contains("-copy-metadata=\"pod-annotation:example.com/team\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails if a copyMetadata rule has no name" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.copyMetadata.podLabels[0].key=foo' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.copyMetadata.podLabels[].name must be set" ]]
+}
+
+#--------------------------------------------------------------------
+# sharding
+
+@test "connectInject/Deployment: -shards is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-shards"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -shards is set with connectInject.sharding.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.sharding.enabled=true' \
+      --set 'connectInject.sharding.shards=8' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shards=8"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shard-lease-name=release-name-consul-endpoints-controller"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # openshift
 
@@ -1978,6 +2355,28 @@ EOF
   [ "${actual}" = "bar" ]
 }
 
+@test "connectInject/Deployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 # consulDestinationNamespace reserved name
 
 @test "connectInject/Deployment: fails when consulDestinationNamespace=system" {
@@ -2009,3 +2408,248 @@ reservedNameTest() {
 		[ "$status" -eq 1 ]
 		[[ "$output" =~ "The name $name set for key connectInject.consulNamespaces.consulDestinationNamespace is reserved by Consul for future use" ]]
 }
+
+#--------------------------------------------------------------------
+# global.tls.minVersion and global.tls.cipherSuites
+
+@test "connectInject/Deployment: TLS minimum version and cipher suites are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tls-min-version"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sets the TLS minimum version and cipher suites with global.tls.minVersion and global.tls.cipherSuites" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_2' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      --set 'global.tls.cipherSuites[1]=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r '. | any(contains("-tls-min-version=TLSv1_2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | any(contains("-tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails with an invalid global.tls.minVersion" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.minVersion=1.2' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.minVersion must be one of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3" ]]
+}
+
+#--------------------------------------------------------------------
+# global.webhookAuditLog
+
+@test "connectInject/Deployment: webhook audit log is not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: webhook audit log is written to stdout with global.webhookAuditLog.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.webhookAuditLog.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log=stdout"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: webhook audit log sink can be set with global.webhookAuditLog.sink" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.webhookAuditLog.enabled=true' \
+      --set 'global.webhookAuditLog.sink=/audit/webhooks.log' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log=/audit/webhooks.log"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.metrics.enableLifecycleMetrics
+
+@test "connectInject/Deployment: lifecycle metrics are not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-lifecycle-metrics"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: lifecycle metrics can be enabled with connectInject.metrics.enableLifecycleMetrics=true" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.metrics.enableLifecycleMetrics=true' \
+      --set 'connectInject.metrics.lifecycleMetricsPort=20400' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r '. | any(contains("-enable-lifecycle-metrics=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | any(contains("-lifecycle-metrics-port=20400"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# probes
+
+@test "connectInject/Deployment: liveness and readiness probes use /healthz and /readyz" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/healthz" ]
+  actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "9445" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/readyz" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "9445" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "connectInject/Deployment: -enable-restricted-pod-security is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-restricted-pod-security"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-restricted-pod-security is set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.defaultEnabled=false' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.containers[0].command | any(contains("-enable-restricted-pod-security"))')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '.containers | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails if global.restrictedPodSecurity.enabled=true and transparent proxy is enabled by default" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.transparentProxy.defaultEnabled must be false if global.restrictedPodSecurity.enabled is true" ]]
+}
+
+@test "connectInject/Deployment: fails if global.restrictedPodSecurity.enabled=true and the DogStatsD socket is used" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.defaultEnabled=false' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.metrics.datadog.dogstatsd.socketTransportType must be UDP if global.restrictedPodSecurity.enabled is true" ]]
+}
+
+#--------------------------------------------------------------------
+# global.ipFamilies
+
+@test "connectInject/Deployment: -enable-ipv6 is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-ipv6"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: IPv6 flags are set when global.ipFamilies.enableIPv6=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.ipFamilies.enableIPv6=true' \
+      --set 'global.ipFamilies.registration=IPv6' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-enable-ipv6"))')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$cmd" | yq 'any(contains("-registration-ip-family=IPv6"))')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$cmd" | yq 'any(contains("-listener-ip-family=IPv4"))')
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: HOST_IP is bracketed in the Consul addresses when global.ipFamilies.enableIPv6=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.ipFamilies.enableIPv6=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = 'http://[$(HOST_IP)]:8500' ]
+}
+
+@test "connectInject/Deployment: fails if global.ipFamilies.registration is invalid" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.ipFamilies.registration=ipv6' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.ipFamilies.registration must be one of IPv4 or IPv6" ]]
+}
+
+@test "connectInject/Deployment: fails if global.ipFamilies.listener is IPv6 and global.ipFamilies.enableIPv6=false" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.ipFamilies.listener=IPv6' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.ipFamilies.enableIPv6 must be true if global.ipFamilies.listener is IPv6" ]]
+}
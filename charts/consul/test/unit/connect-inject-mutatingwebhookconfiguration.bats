@@ -47,3 +47,37 @@ load _helpers
       yq '.webhooks[0].clientConfig.service.namespace' | tee /dev/stderr)
   [ "${actual}" = "\"foo\"" ]
 }
+
+@test "connectInject/MutatingWebhookConfiguration: reinvocationPolicy is IfNeeded" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.webhooks[0].reinvocationPolicy' | tee /dev/stderr)
+  [ "${actual}" = "IfNeeded" ]
+}
+
+#--------------------------------------------------------------------
+# global.certManager
+
+@test "connectInject/MutatingWebhookConfiguration: CA is not injected by cert-manager by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: CA is injected by cert-manager with global.certManager.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "default/release-name-consul-connect-inject-webhook-cert" ]
+}
@@ -0,0 +1,147 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "connectInject/PodMonitor: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "connectInject/PodMonitor: disabled with global.metrics.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "connectInject/PodMonitor: disabled with global.metrics.prometheusOperator.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      .
+}
+
+@test "connectInject/PodMonitor: can set global.metrics.prometheusOperator.labels" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.labels.release=prometheus' \
+      --set 'global.metrics.prometheusOperator.labels.team=mesh' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.labels | [.release, .team, .app] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "prometheus,mesh,consul" ]
+}
+
+@test "connectInject/PodMonitor: can set global.metrics.prometheusOperator.interval and scrapeTimeout" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.interval=30s' \
+      --set 'global.metrics.prometheusOperator.scrapeTimeout=10s' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.interval, .scrapeTimeout] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "30s,10s" ]
+}
+
+@test "connectInject/PodMonitor: disabled with connectInject.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "connectInject/PodMonitor: selects the injected pods in all namespaces" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.selector.matchLabels."consul.hashicorp.com/connect-inject-status"' | tee /dev/stderr)
+  [ "${actual}" = "injected" ]
+
+  local actual=$(echo "$object" | yq -r '.namespaceSelector.any' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/PodMonitor: selects the pods in connectInject.k8sAllowNamespaces" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'connectInject.k8sAllowNamespaces={foo,bar}' \
+      . | tee /dev/stderr |
+      yq -r '.spec.namespaceSelector.matchNames | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "foo,bar" ]
+}
+
+@test "connectInject/PodMonitor: scrapes the port and path of the prometheus annotations" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0].relabelings' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.[] | select(.targetLabel == "__address__") | .sourceLabels | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "__meta_kubernetes_pod_ip,__meta_kubernetes_pod_annotation_prometheus_io_port" ]
+
+  local actual=$(echo "$object" | yq -r '.[] | select(.targetLabel == "__metrics_path__") | .sourceLabels[0]' | tee /dev/stderr)
+  [ "${actual}" = "__meta_kubernetes_pod_annotation_prometheus_io_path" ]
+}
+
+@test "connectInject/PodMonitor: does not scrape the lifecycle metrics port by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.podMetricsEndpoints | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+@test "connectInject/PodMonitor: scrapes the lifecycle metrics port with connectInject.metrics.enableLifecycleMetrics=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-podmonitor.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'connectInject.metrics.enableLifecycleMetrics=true' \
+      --set 'global.metrics.prometheusOperator.interval=15s' \
+      . | tee /dev/stderr |
+      yq '.spec.podMetricsEndpoints[1]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.port' | tee /dev/stderr)
+  [ "${actual}" = "lifecycle-metrics" ]
+
+  local actual=$(echo "$object" | yq -r '.path' | tee /dev/stderr)
+  [ "${actual}" = "/metrics" ]
+
+  local actual=$(echo "$object" | yq -r '.interval' | tee /dev/stderr)
+  [ "${actual}" = "15s" ]
+}
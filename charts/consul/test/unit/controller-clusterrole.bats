@@ -43,3 +43,65 @@ load _helpers
       yq '.rules | map(select(.resources[0] == "podsecuritypolicies")) | length' | tee /dev/stderr)
   [ "${actual}" = "1" ]
 }
+
+@test "controller/ClusterRole: allows creating events" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "events")) | .[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["create","patch"]' ]
+}
+
+@test "controller/ClusterRole: doesn't allow secrets access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules | map(select(.resources[0] == "secrets")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: allows secrets access with global.peering.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.peering.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "secrets")) | .[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["create","get","update"]' ]
+}
+
+@test "controller/ClusterRole: allows managing peeringconnections" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.rules[0].resources | any(. == "peeringconnections")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/ClusterRole: can't delete leases by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "leases")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create,get,list,update" ]
+}
+
+@test "controller/ClusterRole: allows deleting leases with controller.sharding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.sharding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "leases")) | .[0].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create,get,list,update,delete" ]
+}
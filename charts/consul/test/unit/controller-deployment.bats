@@ -74,6 +74,18 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "controller/Deployment: consul-logout preStop hook honors global.logJSON" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml \
+      --set 'controller.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.logJSON=true' \
+      . | tee /dev/stderr |
+      yq '[.spec.template.spec.containers[0].lifecycle.preStop.exec.command[2]] | any(contains("-log-json=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "controller/Deployment: CONSUL_HTTP_TOKEN_FILE is not set when acls are disabled" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -291,6 +303,32 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "controller/Deployment: init container logs in to the global AWS IAM component auth method when global.acls.awsIAMAuthMethod.enabled=true in non-primary datacenter" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-deployment.yaml \
+      --set 'controller.enabled=true' \
+      --set 'global.datacenter=dc2' \
+      --set 'global.federation.enabled=true' \
+      --set 'global.federation.primaryDatacenter=dc1' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/*' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[] | select(.name == "controller-acl-init")' | tee /dev/stderr)
+
+  local actual=$(echo $object |
+      yq -r '.command | any(contains("-acl-auth-method=release-name-consul-aws-iam-component-auth-method-dc2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object |
+      yq -r '.command | any(contains("-aws-iam-login"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # global.tls.enabled
 
@@ -775,6 +813,80 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# consulStateValidation
+
+@test "controller/Deployment: webhook Consul state validation is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-webhook-consul-state-validation"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: webhook Consul state validation can be enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.consulStateValidation=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-webhook-consul-state-validation=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# terminating gateway ACL roles
+
+@test "controller/Deployment: terminating gateway ACL roles are not managed by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'terminatingGateways.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-terminating-gateway-acl-role-prefix"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: terminating gateway ACL roles are managed when ACLs are managed" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-terminating-gateway-acl-role-prefix=release-name-consul"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# peering
+
+@test "controller/Deployment: peering controller is disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peering"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: peering controller is enabled with global.peering.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.peering.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peering=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # get-auto-encrypt-client-ca
 
@@ -970,4 +1082,289 @@ load _helpers
   [ "${actual}" = "bar" ]
 }
 
+@test "controller/Deployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
+
+
+#--------------------------------------------------------------------
+# global.tls.minVersion and global.tls.cipherSuites
+
+@test "controller/Deployment: TLS minimum version and cipher suites are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tls-min-version"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets the TLS minimum version and cipher suites with global.tls.minVersion and global.tls.cipherSuites" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_2' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      --set 'global.tls.cipherSuites[1]=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r '. | any(contains("-tls-min-version=TLSv1_2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | any(contains("-tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: fails with an invalid global.tls.minVersion" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.tls.minVersion=1.2' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.minVersion must be one of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3" ]]
+}
+
+#--------------------------------------------------------------------
+# global.metrics.prometheusOperator
+
+@test "controller/Deployment: no metrics port by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "metrics")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/Deployment: metrics port with global.metrics.prometheusOperator.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports[] | select(.name == "metrics") | .containerPort' | tee /dev/stderr)
+  [ "${actual}" = "8080" ]
+}
+
+#--------------------------------------------------------------------
+# global.webhookAuditLog
+
+@test "controller/Deployment: webhook audit log is not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: webhook audit log is written to stdout with global.webhookAuditLog.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.webhookAuditLog.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log=stdout"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: webhook audit log sink can be set with global.webhookAuditLog.sink" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.webhookAuditLog.enabled=true' \
+      --set 'global.webhookAuditLog.sink=/audit/webhooks.log' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-webhook-audit-log=/audit/webhooks.log"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# telemetryCollector
+
+@test "controller/Deployment: -tracing-otlp-address is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets -tracing-otlp-address to the chart's collector when telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address=release-name-consul-telemetry-collector.default.svc:4317"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: sets -tracing-otlp-address to telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address=otel.observability.svc:4317"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
 
+@test "controller/Deployment: -tracing-otlp-address is not set when telemetryCollector.traces.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      --set 'telemetryCollector.traces.enabled=false' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: sets -tracing-otlp-address to the Datadog Agent of the node when global.metrics.datadog.otlp.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.otlp.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-tracing-otlp-address=${HOST_IP}:4317"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "controller/Deployment: telemetryCollector takes precedence over the Datadog Agent for traces" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' \
+      --set 'global.metrics.datadog.otlp.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.spec.template.spec.containers[0].command | map(select(contains("-tracing-otlp-address")))' | tee /dev/stderr)
+  [[ "${actual}" =~ "otel.observability.svc:4317" ]]
+  [[ ! "${actual}" =~ "HOST_IP" ]]
+}
+
+#--------------------------------------------------------------------
+# probes
+
+@test "controller/Deployment: liveness and readiness probes use /healthz and /readyz" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/healthz" ]
+  actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "9445" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/readyz" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "9445" ]
+}
+
+#--------------------------------------------------------------------
+# sharding
+
+@test "controller/Deployment: leader election is enabled by default" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-enable-leader-election"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shards"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "controller/Deployment: shards replace leader election with controller.sharding.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.sharding.enabled=true' \
+      --set 'controller.sharding.shards=8' \
+      --namespace foo \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-enable-leader-election"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shards=8"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shard-lease-name=release-name-consul-controller"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'any(contains("-shard-lease-namespace=foo"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "controller/Deployment: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/controller-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsNonRoot')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '[.containers[], (.initContainers // [])[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
@@ -18,3 +18,27 @@ load _helpers
       yq 'length > 0' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# global.certManager
+
+@test "controller/MutatingWebhookConfiguration: CA is not injected by cert-manager by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-mutatingwebhookconfiguration.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "controller/MutatingWebhookConfiguration: CA is injected by cert-manager with global.certManager.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-mutatingwebhookconfiguration.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["cert-manager.io/inject-ca-from"]' | tee /dev/stderr)
+  [ "${actual}" = "default/release-name-consul-controller-webhook-cert" ]
+}
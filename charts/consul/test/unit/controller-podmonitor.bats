@@ -0,0 +1,90 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "controller/PodMonitor: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      .
+}
+
+@test "controller/PodMonitor: disabled with global.metrics.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "controller/PodMonitor: disabled with global.metrics.prometheusOperator.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      .
+}
+
+@test "controller/PodMonitor: can set global.metrics.prometheusOperator.labels" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.labels.release=prometheus' \
+      --set 'global.metrics.prometheusOperator.labels.team=mesh' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.labels | [.release, .team, .app] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "prometheus,mesh,consul" ]
+}
+
+@test "controller/PodMonitor: can set global.metrics.prometheusOperator.interval and scrapeTimeout" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.interval=30s' \
+      --set 'global.metrics.prometheusOperator.scrapeTimeout=10s' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.interval, .scrapeTimeout] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "30s,10s" ]
+}
+
+@test "controller/PodMonitor: selects the controller pods" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.selector.matchLabels.component' | tee /dev/stderr)
+  [ "${actual}" = "controller" ]
+}
+
+@test "controller/PodMonitor: disabled with controller.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "controller/PodMonitor: scrapes the metrics port" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-podmonitor.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.port, .path] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "metrics,/metrics" ]
+}
@@ -0,0 +1,100 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "createFederationSecret/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/create-federation-secret-deployment.yaml  \
+      .
+}
+
+@test "createFederationSecret/Deployment: disabled with global.federation.createFederationSecret=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/create-federation-secret-deployment.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      .
+}
+
+@test "createFederationSecret/Deployment: keeps the secret up to date with global.federation.secretSync.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/create-federation-secret-deployment.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secretSync.enabled=true' \
+      --set 'global.federation.secretSync.interval=1m' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-sync-interval=1m")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-export-")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "createFederationSecret/Deployment: exports the secret with global.federation.secretSync.exportKubeconfigs" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/create-federation-secret-deployment.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secretSync.enabled=true' \
+      --set 'global.federation.secretSync.exportKubeconfigs[0].secretName=dc2' \
+      --set 'global.federation.secretSync.exportKubeconfigs[0].secretKey=kubeconfig' \
+      --set 'global.federation.secretSync.exportKubeconfigs[1].secretName=dc3' \
+      --set 'global.federation.secretSync.exportKubeconfigs[1].secretKey=config' \
+      --set 'global.federation.secretSync.exportNamespace=consul' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq -r '.volumes[] | select(.name == "export-kubeconfig-1") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "dc3" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.volumes[] | select(.name == "export-kubeconfig-1") | .secret.items[0].key' | tee /dev/stderr)
+  [ "${actual}" = "config" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.containers[0].volumeMounts[] | select(.name == "export-kubeconfig-1") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/export-kubeconfigs/1" ]
+
+  local cmd=$(echo "$object" | yq -r '.containers[0].command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-export-kubeconfig-file=/consul/export-kubeconfigs/0/kubeconfig")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-export-kubeconfig-file=/consul/export-kubeconfigs/1/kubeconfig")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-export-namespace=consul")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "createFederationSecret/Deployment: fails when global.federation.secretSync.exportKubeconfigs has no secretKey" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/create-federation-secret-deployment.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secretSync.enabled=true' \
+      --set 'global.federation.secretSync.exportKubeconfigs[0].secretName=dc2' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "both secretName and secretKey must be set for global.federation.secretSync.exportKubeconfigs" ]]
+}
@@ -407,3 +407,42 @@ load _helpers
   actual=$(echo $command | jq ' . | contains("-consul-api-timeout=5s")')
   [ "${actual}" = "true" ]
 }
+
+@test "createFederationSecret/Job: gossip key is read from the CSI volume" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/create-federation-secret-job.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.gossipEncryption.secretName=consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "gossip-encryption-key") | .csi.volumeAttributes.secretProviderClass' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].command | any(contains("-gossip-key-file=/consul/gossip/gossip.txt"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.federation.secretSync
+
+@test "createFederationSecret/Job: disabled with global.federation.secretSync.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/create-federation-secret-job.yaml  \
+      --set 'global.federation.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.secretSync.enabled=true' \
+      .
+}
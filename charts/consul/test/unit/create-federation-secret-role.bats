@@ -59,3 +59,21 @@ load _helpers
       yq -r '.rules | map(select(.resources[0] == "podsecuritypolicies")) | length' | tee /dev/stderr)
   [ "${actual}" = "1" ]
 }
+
+#--------------------------------------------------------------------
+# global.federation.secretSync
+
+@test "createFederationSecret/Role: is not a Helm hook with global.federation.secretSync.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/create-federation-secret-role.yaml  \
+      --set 'global.federation.createFederationSecret=true' \
+      --set 'global.federation.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.federation.secretSync.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.metadata | has("annotations")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
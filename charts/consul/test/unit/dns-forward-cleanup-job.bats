@@ -0,0 +1,45 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForwardCleanup/Job: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-cleanup-job.yaml  \
+      .
+}
+
+@test "dnsForwardCleanup/Job: enabled with dns.forwarding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-cleanup-job.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.annotations["helm.sh/hook"]' | tee /dev/stderr)
+  [ "${actual}" = "pre-delete" ]
+}
+
+@test "dnsForwardCleanup/Job: disabled with dns.forwarding.rollbackOnUninstall=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-cleanup-job.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.rollbackOnUninstall=false' \
+      .
+}
+
+@test "dnsForwardCleanup/Job: scales down the deployment and removes the forwarding" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/dns-forward-cleanup-job.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.serviceAccountName' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-dns-forward" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].command[2] | contains("-uninstall \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].command[2] | contains("-deployment=release-name-consul-dns-forward \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,53 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/ConfigMapRole: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-configmap-role.yaml  \
+      .
+}
+
+@test "dnsForward/ConfigMapRole: allows updating the ConfigMap of CoreDNS" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/dns-forward-configmap-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "kube-system" ]
+  local actual=$(echo "$object" | yq -r '.rules | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+  local actual=$(echo "$object" | yq -r '.rules[0].resourceNames[0] + ":" + (.rules[0].verbs | join(","))' | tee /dev/stderr)
+  [ "${actual}" = "coredns:get,update" ]
+}
+
+@test "dnsForward/ConfigMapRole: allows creating the ConfigMap of kube-dns" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/dns-forward-configmap-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.clusterDNS=kube-dns' \
+      --set 'dns.forwarding.configMap.namespace=dns' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "dns" ]
+  local actual=$(echo "$object" | yq -r '.rules[0].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "kube-dns" ]
+  local actual=$(echo "$object" | yq -r '.rules[1].verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "create" ]
+}
+
+@test "dnsForward/ConfigMapRole: uses dns.forwarding.configMap.name" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-configmap-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.configMap.name=coredns-custom' \
+      . | tee /dev/stderr |
+      yq -r '.rules[0].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "coredns-custom" ]
+}
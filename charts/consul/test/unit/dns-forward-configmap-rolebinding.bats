@@ -0,0 +1,34 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/ConfigMapRoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-configmap-rolebinding.yaml  \
+      .
+}
+
+@test "dnsForward/ConfigMapRoleBinding: enabled with dns.forwarding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-configmap-rolebinding.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "dnsForward/ConfigMapRoleBinding: binds the service account in the namespace of the release" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/dns-forward-configmap-rolebinding.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --namespace consul \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.metadata.namespace' | tee /dev/stderr)
+  [ "${actual}" = "kube-system" ]
+  local actual=$(echo "$object" | yq -r '.subjects[0].namespace' | tee /dev/stderr)
+  [ "${actual}" = "consul" ]
+}
@@ -0,0 +1,83 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      .
+}
+
+@test "dnsForward/Deployment: enabled with dns.forwarding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "dnsForward/Deployment: fails with dns.enabled=false" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.enabled=false' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "dns.forwarding.enabled requires dns.enabled to be true" ]]
+}
+
+@test "dnsForward/Deployment: fails with an unknown dns.forwarding.clusterDNS" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.clusterDNS=bind' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "dns.forwarding.clusterDNS must be \"coredns\" or \"kube-dns\"" ]]
+}
+
+@test "dnsForward/Deployment: forwards the domain from CoreDNS by default" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$command" | yq 'contains("-dns-service=release-name-consul-dns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-domain=consul \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-cluster-dns=coredns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-configmap-name=coredns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-configmap-namespace=kube-system \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "dnsForward/Deployment: forwards global.domain from kube-dns" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/dns-forward-deployment.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.clusterDNS=kube-dns' \
+      --set 'dns.forwarding.configMap.namespace=dns' \
+      --set 'global.domain=example' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$command" | yq 'contains("-domain=example \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-cluster-dns=kube-dns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-configmap-name=kube-dns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$command" | yq 'contains("-configmap-namespace=dns \\")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,29 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/PodSecurityPolicy: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-podsecuritypolicy.yaml  \
+      .
+}
+
+@test "dnsForward/PodSecurityPolicy: enabled with dns.forwarding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-podsecuritypolicy.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "dnsForward/PodSecurityPolicy: disabled with global.enablePodSecurityPolicies=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-podsecuritypolicy.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      .
+}
@@ -0,0 +1,46 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/Role: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-role.yaml  \
+      .
+}
+
+@test "dnsForward/Role: allows getting the DNS service and scaling down the deployment" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/dns-forward-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.[0].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-dns" ]
+  local actual=$(echo "$object" | yq -r '.[1].resourceNames[0] + ":" + (.[1].verbs | join(","))' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-dns-forward:get,patch" ]
+}
+
+@test "dnsForward/Role: doesn't allow patching the deployment with dns.forwarding.rollbackOnUninstall=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'dns.forwarding.rollbackOnUninstall=false' \
+      . | tee /dev/stderr |
+      yq -r '.rules | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+@test "dnsForward/Role: allows using the pod security policy with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-role.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[2].resourceNames[0]' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-dns-forward" ]
+}
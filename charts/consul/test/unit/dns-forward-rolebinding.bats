@@ -0,0 +1,20 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "dnsForward/RoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/dns-forward-rolebinding.yaml  \
+      .
+}
+
+@test "dnsForward/RoleBinding: enabled with dns.forwarding.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-forward-rolebinding.yaml  \
+      --set 'dns.forwarding.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
@@ -118,3 +118,25 @@ load _helpers
       yq '.spec | .loadBalancerIP == "192.168.0.100"' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# global.ipFamilies
+
+@test "dns/Service: ipFamilyPolicy is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-service.yaml \
+      . | tee /dev/stderr |
+      yq '.spec.ipFamilyPolicy' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "dns/Service: ipFamilyPolicy is PreferDualStack when global.ipFamilies.enableIPv6=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/dns-service.yaml \
+      --set 'global.ipFamilies.enableIPv6=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.ipFamilyPolicy' | tee /dev/stderr)
+  [ "${actual}" = "PreferDualStack" ]
+}
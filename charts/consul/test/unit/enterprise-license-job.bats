@@ -94,6 +94,28 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "enterpriseLicense/Job: init container honors global.logLevel and global.logJSON" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/enterprise-license-job.yaml  \
+      --set 'global.enterpriseLicense.secretName=foo' \
+      --set 'global.enterpriseLicense.secretKey=bar' \
+      --set 'global.enterpriseLicense.enableLicenseAutoload=false' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.logLevel=debug' \
+      --set 'global.logJSON=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq 'any(contains("-log-level=debug"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+      yq 'any(contains("-log-json=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # global.tls.enabled
 
@@ -212,3 +234,15 @@ load _helpers
   actual=$(echo $ca_cert_volume | jq -r '.secret.items[0].key' | tee /dev/stderr)
   [ "${actual}" = "key" ]
 }
+
+@test "enterpriseLicense/Job: fails with the CSI secrets backend" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/enterprise-license-job.yaml  \
+      --set 'global.enterpriseLicense.secretName=foo' \
+      --set 'global.enterpriseLicense.secretKey=bar' \
+      --set 'global.enterpriseLicense.enableLicenseAutoload=false' \
+      --set 'global.secretsBackend.csi.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.enterpriseLicense.enableLicenseAutoload must be true if global.secretsBackend.csi.enabled=true" ]]
+}
@@ -0,0 +1,151 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "gossipEncryptionRotate/Deployment: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      .
+}
+
+@test "gossipEncryptionRotate/Deployment: enabled with global.gossipEncryption.rotation.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: fails without a gossip encryption key" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.gossipEncryption.rotation.enabled requires global.gossipEncryption.autoGenerate or global.gossipEncryption.secretName and secretKey to be set" ]]
+}
+
+@test "gossipEncryptionRotate/Deployment: fails with the Vault secrets backend" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.secretName=path/to/secret' \
+      --set 'global.gossipEncryption.secretKey=key' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.gossipEncryption.rotation.enabled can only be used with gossip encryption keys in Kubernetes secrets" ]]
+}
+
+@test "gossipEncryptionRotate/Deployment: fails with federation" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.federation.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.gossipEncryption.rotation.enabled can't be used with global.federation.enabled" ]]
+}
+
+@test "gossipEncryptionRotate/Deployment: rotates the autogenerated secret" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-secret-name=release-name-consul-gossip-encryption-key")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-secret-key=\"key\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-rotation-period=2160h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: rotates global.gossipEncryption.secretName every global.gossipEncryption.rotation.period" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.secretName=foo' \
+      --set 'global.gossipEncryption.secretKey=bar' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.gossipEncryption.rotation.period=720h' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command[2]' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" | yq 'contains("-secret-name=foo")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-secret-key=bar")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" | yq 'contains("-rotation-period=720h")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: logs in with the component auth method when global.acls.manageSystemACLs=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers[0].command[2] | contains("-component-name=gossip-encryption-rotate")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" | yq -r '.containers[0].env[] | select(.name == "CONSUL_HTTP_TOKEN_FILE") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/login/acl-token" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: uses the servers when clients are disabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'client.enabled=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+  [ "${actual}" = "https://release-name-consul-server:8501" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: adds Prometheus scrape annotations with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "gossipEncryptionRotate/Deployment: no Prometheus scrape annotations with global.gossipEncryption.rotation.metrics.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/gossip-encryption-rotate-deployment.yaml  \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      --set 'global.gossipEncryption.rotation.metrics.enabled=false' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations."prometheus.io/scrape"' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
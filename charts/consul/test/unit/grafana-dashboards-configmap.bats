@@ -0,0 +1,82 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "grafanaDashboards/ConfigMap: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      .
+}
+
+@test "grafanaDashboards/ConfigMap: creates a ConfigMap for each dashboard with global.metrics.grafanaDashboards.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -c '[.[] | select(. != null) | .metadata.name] | sort' | tee /dev/stderr)
+  [ "${actual}" = '["release-name-consul-grafana-dashboard-gateway-traffic","release-name-consul-grafana-dashboard-raft","release-name-consul-grafana-dashboard-server-health","release-name-consul-grafana-dashboard-sidecar-resources","release-name-consul-grafana-dashboard-xds"]' ]
+}
+
+@test "grafanaDashboards/ConfigMap: dashboards are valid JSON" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.data["consul-server-health.json"] // empty' | jq -r .uid | tee /dev/stderr)
+  [ "${actual}" = "consul-server-health" ]
+}
+
+@test "grafanaDashboards/ConfigMap: sets the grafana_dashboard label by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '[.[] | select(. != null) | .metadata.labels.grafana_dashboard] | unique | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+@test "grafanaDashboards/ConfigMap: can set labels and annotations" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      --set 'global.metrics.grafanaDashboards.labels.foo=bar' \
+      --set 'global.metrics.grafanaDashboards.annotations.grafana_folder=Consul' \
+      . | tee /dev/stderr |
+      yq -s '.[0].metadata' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.labels.foo' | tee /dev/stderr)
+  [ "${actual}" = "bar" ]
+
+  local actual=$(echo "$object" | yq -r '.labels.grafana_dashboard' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+
+  local actual=$(echo "$object" | yq -r '.annotations.grafana_folder' | tee /dev/stderr)
+  [ "${actual}" = "Consul" ]
+}
+
+@test "grafanaDashboards/ConfigMap: namespace defaults to the release namespace" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      --namespace foo \
+      . | tee /dev/stderr |
+      yq -s -r '[.[] | select(. != null) | .metadata.namespace] | unique | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "foo" ]
+}
+
+@test "grafanaDashboards/ConfigMap: can set the namespace" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/grafana-dashboards-configmap.yaml  \
+      --set 'global.metrics.grafanaDashboards.enabled=true' \
+      --set 'global.metrics.grafanaDashboards.namespace=monitoring' \
+      . | tee /dev/stderr |
+      yq -s -r '[.[] | select(. != null) | .metadata.namespace] | unique | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "monitoring" ]
+}
@@ -249,6 +249,30 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "helper/consul.getAutoEncryptClientCA: uses the port and TLS server name of the first externalServers.hosts entry" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/tests/test-runner.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0].host=consul.io' \
+      --set 'externalServers.hosts[0].httpsPort=443' \
+      --set 'externalServers.hosts[0].tlsServerName=custom-server-name' \
+      . | tee /dev/stderr |
+      yq '.spec.initContainers[] | select(.name == "get-auto-encrypt-client-ca").command | join(" ")' | tee /dev/stderr)
+
+  local actual=$(echo $command | jq -r '. | contains("-server-addr=\"consul.io\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | contains("-server-port=443")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | contains("-tls-server-name=custom-server-name")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "helper/consul.getAutoEncryptClientCA: doesn't provide the CA if externalServers.enabled is true and externalServers.useSystemRoots is true" {
   cd `chart_dir`
   local actual=$(helm template \
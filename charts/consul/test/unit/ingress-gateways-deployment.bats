@@ -558,6 +558,34 @@ load _helpers
   [ "${actual}" = "12" ]
 }
 
+@test "ingressGateways/Deployment: replicas is not set when autoscaling is enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.autoscaling.enabled=true' \
+      --set 'ingressGateways.defaults.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.replicas' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "ingressGateways/Deployment: replicas is set when autoscaling is disabled for a specific gateway" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.autoscaling.enabled=true' \
+      --set 'ingressGateways.defaults.autoscaling.maxReplicas=5' \
+      --set 'ingressGateways.gateways[0].name=gateway1' \
+      --set 'ingressGateways.gateways[0].autoscaling.enabled=false' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.replicas' | tee /dev/stderr)
+  [ "${actual}" = "2" ]
+}
+
 #--------------------------------------------------------------------
 # ports
 
@@ -942,6 +970,46 @@ load _helpers
   [ "${actual}" = "value2" ]
 }
 
+#--------------------------------------------------------------------
+# topologySpreadConstraints
+
+@test "ingressGateways/Deployment: no topologySpreadConstraints by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "ingressGateways/Deployment: topologySpreadConstraints can be set through defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.topologySpreadConstraints=- topologyKey: zone' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints[0].topologyKey' | tee /dev/stderr)
+  [ "${actual}" = "zone" ]
+}
+
+@test "ingressGateways/Deployment: topologySpreadConstraints can be set through specific gateway, overriding defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.topologySpreadConstraints=- topologyKey: zone' \
+      --set 'ingressGateways.gateways[0].name=gateway1' \
+      --set 'ingressGateways.gateways[0].topologySpreadConstraints=- topologyKey: rack' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints[0].topologyKey' | tee /dev/stderr)
+  [ "${actual}" = "rack" ]
+}
+
 #--------------------------------------------------------------------
 # nodeSelector
 
@@ -1845,6 +1913,29 @@ EOF
   [ "${actual}" = "bar" ]
 }
 
+@test "ingressGateway/Deployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 #--------------------------------------------------------------------
 # terminationGracePeriodSeconds
 
@@ -1884,3 +1975,118 @@ EOF
       yq -s -r '.[0].spec.template.spec.terminationGracePeriodSeconds' | tee /dev/stderr)
   [ "${actual}" = "30" ]
 }
+
+#--------------------------------------------------------------------
+# tlsSecrets
+
+@test "ingressGateways/Deployment: sds-server container is not added by default" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -s -r '.[0].spec.template.spec.containers | map(select(.name == "sds-server")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+
+  local actual=$(echo "$object" |
+      yq -s -r '.[0].spec.template.spec.initContainers | map(select(.name == "ingress-gateway-init"))[0] | .command[2] | contains("envoy_extra_static_clusters_json")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "ingressGateways/Deployment: sds-server container is added with tlsSecrets set through defaults" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.tlsSecrets[0]=cert1' \
+      --set 'ingressGateways.defaults.tlsSecrets[1]=cert2' \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -s -r '.[0].spec.template.spec.containers | map(select(.name == "sds-server"))[0].command | join(" ")' | tee /dev/stderr)
+  [ "${actual}" = "consul-k8s-control-plane sds-server -log-level=info -log-json=false -k8s-namespace=default -listen=127.0.0.1:20300 -secret=cert1 -secret=cert2" ]
+
+  local actual=$(echo "$object" |
+      yq -s -r '.[0].spec.template.spec.initContainers | map(select(.name == "ingress-gateway-init"))[0] | .command[2]' | tee /dev/stderr |
+      grep -F 'envoy_extra_static_clusters_json = "{\"name\":\"consul-k8s-sds\",\"type\":\"STATIC\",\"connect_timeout\":\"5s\",\"http2_protocol_options\":{},\"load_assignment\":{\"cluster_name\":\"consul-k8s-sds\",\"endpoints\":[{\"lb_endpoints\":[{\"endpoint\":{\"address\":{\"socket_address\":{\"address\":\"127.0.0.1\",\"port_value\":20300}}}}]}]}}"' | tee /dev/stderr)
+  [ "${actual}" != "" ]
+}
+
+@test "ingressGateways/Deployment: tlsSecrets can be set through specific gateway overriding defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.tlsSecrets[0]=cert1' \
+      --set 'ingressGateways.gateways[0].name=gateway1' \
+      --set 'ingressGateways.gateways[0].tlsSecrets[0]=cert2' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.containers | map(select(.name == "sds-server"))[0].command | map(select(startswith("-secret="))) | join(" ")' | tee /dev/stderr)
+  [ "${actual}" = "-secret=cert2" ]
+}
+
+#--------------------------------------------------------------------
+# prometheus port
+
+@test "ingressGateways/Deployment: no prometheus port by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "ingressGateways/Deployment: prometheus port with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports[] | select(.name == "prometheus") | .containerPort' | tee /dev/stderr)
+  [ "${actual}" = "20200" ]
+}
+
+@test "ingressGateways/Deployment: no prometheus port with global.metrics.enableGatewayMetrics=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.enableGatewayMetrics=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "ingressGateways/Deployment: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/ingress-gateways-deployment.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsNonRoot')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '[.containers[], (.initContainers // [])[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
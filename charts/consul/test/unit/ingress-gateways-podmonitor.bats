@@ -0,0 +1,110 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "ingressGateways/PodMonitor: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "ingressGateways/PodMonitor: disabled with global.metrics.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "ingressGateways/PodMonitor: disabled with global.metrics.prometheusOperator.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      .
+}
+
+@test "ingressGateways/PodMonitor: can set global.metrics.prometheusOperator.labels" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.labels.release=prometheus' \
+      --set 'global.metrics.prometheusOperator.labels.team=mesh' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.labels | [.release, .team, .app] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "prometheus,mesh,consul" ]
+}
+
+@test "ingressGateways/PodMonitor: can set global.metrics.prometheusOperator.interval and scrapeTimeout" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.interval=30s' \
+      --set 'global.metrics.prometheusOperator.scrapeTimeout=10s' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.interval, .scrapeTimeout] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "30s,10s" ]
+}
+
+@test "ingressGateways/PodMonitor: selects the ingress-gateway pods" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.selector.matchLabels.component' | tee /dev/stderr)
+  [ "${actual}" = "ingress-gateway" ]
+}
+
+@test "ingressGateways/PodMonitor: disabled with ingressGateways.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "ingressGateways/PodMonitor: disabled with global.metrics.enableGatewayMetrics=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.enableGatewayMetrics=false' \
+      .
+}
+
+@test "ingressGateways/PodMonitor: scrapes the prometheus port" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/ingress-gateways-podmonitor.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.port, .path] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "prometheus,/metrics" ]
+}
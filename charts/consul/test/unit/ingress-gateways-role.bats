@@ -101,3 +101,24 @@ load _helpers
   local actual=$(echo $object | yq '.[2] | length > 0' | tee /dev/stderr)
   [ "${actual}" = "false" ]
 }
+
+@test "ingressGateways/Role: rules for tlsSecrets" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/ingress-gateways-role.yaml  \
+      --set 'ingressGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'ingressGateways.defaults.tlsSecrets[0]=cert1' \
+      --set 'ingressGateways.defaults.tlsSecrets[1]=cert2' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].rules[1]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "secrets" ]
+
+  local actual=$(echo $object | yq -r '.resourceNames | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "cert1,cert2" ]
+
+  local actual=$(echo $object | yq -r '.verbs | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "get,list,watch" ]
+}
@@ -46,6 +46,18 @@ load _helpers
   [ "${actual}" = "services" ]
 }
 
+@test "meshGateway/ClusterRole: rules for meshGateway.wanAddress.source=ServiceAnnotation" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-clusterrole.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=ServiceAnnotation' \
+      . | tee /dev/stderr |
+      yq -r '.rules[0].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "services" ]
+}
+
 @test "meshGateway/ClusterRole: rules is empty if no ACLs, PSPs and meshGateway.source != Service" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -229,6 +229,19 @@ key2: value2' \
   [ "${actual}" = "3" ]
 }
 
+@test "meshGateway/Deployment: replicas is not set when autoscaling is enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.spec.replicas' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
 #--------------------------------------------------------------------
 # affinity
 
@@ -281,6 +294,32 @@ key2: value2' \
   [ "${actual}" = "value" ]
 }
 
+#--------------------------------------------------------------------
+# topologySpreadConstraints
+
+@test "meshGateway/Deployment: no topologySpreadConstraints by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.topologySpreadConstraints' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "meshGateway/Deployment: topologySpreadConstraints can be set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.topologySpreadConstraints=- topologyKey: zone' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.topologySpreadConstraints[0].topologyKey' | tee /dev/stderr)
+  [ "${actual}" = "zone" ]
+}
+
 #--------------------------------------------------------------------
 # hostNetwork
 
@@ -1453,6 +1492,136 @@ EOF
   [ "${actual}" = "${exp}" ]
 }
 
+@test "meshGateway/Deployment: mesh-gateway-init init container wanAddress.source=HostPort fails if hostPort is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=HostPort' \
+      .
+
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if meshGateway.wanAddress.source=HostPort then meshGateway.hostPort must be set" ]]
+}
+
+@test "meshGateway/Deployment: mesh-gateway-init init container wanAddress.source=HostPort" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=HostPort' \
+      --set 'meshGateway.wanAddress.port=ignored' \
+      --set 'meshGateway.hostPort=8443' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.initContainers | map(select(.name == "mesh-gateway-init"))[0] | .command[2]' | tee /dev/stderr)
+
+  exp='WAN_ADDR="${HOST_IP}"
+WAN_PORT="8443"
+
+cat > /consul/service/service.hcl << EOF
+service {
+  kind = "mesh-gateway"
+  name = "mesh-gateway"
+  port = 8443
+  address = "${POD_IP}"
+  tagged_addresses {
+    lan {
+      address = "${POD_IP}"
+      port = 8443
+    }
+    wan {
+      address = "${WAN_ADDR}"
+      port = ${WAN_PORT}
+    }
+  }
+  checks = [
+    {
+      name = "Mesh Gateway Listening"
+      interval = "10s"
+      tcp = "${POD_IP}:8443"
+      deregister_critical_service_after = "6h"
+    }
+  ]
+}
+EOF
+
+/consul-bin/consul services register \
+  /consul/service/service.hcl'
+
+  [ "${actual}" = "${exp}" ]
+}
+
+@test "meshGateway/Deployment: mesh-gateway-init init container wanAddress.source=ServiceAnnotation fails if service.enable is false" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=ServiceAnnotation' \
+      --set 'meshGateway.service.enabled=false' \
+      .
+
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if meshGateway.wanAddress.source=ServiceAnnotation then meshGateway.service.enabled must be set to true" ]]
+}
+
+@test "meshGateway/Deployment: mesh-gateway-init init container wanAddress.source=ServiceAnnotation" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=ServiceAnnotation' \
+      --set 'meshGateway.wanAddress.annotation=example.com/wan-address' \
+      --set 'meshGateway.wanAddress.port=9443' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.initContainers | map(select(.name == "mesh-gateway-init"))[0] | .command[2]' | tee /dev/stderr)
+
+  exp='consul-k8s-control-plane service-address \
+  -log-level=info \
+  -log-json=false \
+  -k8s-namespace=default \
+  -name=release-name-consul-mesh-gateway \
+  -annotation=example.com/wan-address \
+  -output-file=/tmp/address.txt
+WAN_ADDR="$(cat /tmp/address.txt)"
+WAN_PORT="9443"
+
+cat > /consul/service/service.hcl << EOF
+service {
+  kind = "mesh-gateway"
+  name = "mesh-gateway"
+  port = 8443
+  address = "${POD_IP}"
+  tagged_addresses {
+    lan {
+      address = "${POD_IP}"
+      port = 8443
+    }
+    wan {
+      address = "${WAN_ADDR}"
+      port = ${WAN_PORT}
+    }
+  }
+  checks = [
+    {
+      name = "Mesh Gateway Listening"
+      interval = "10s"
+      tcp = "${POD_IP}:8443"
+      deregister_critical_service_after = "6h"
+    }
+  ]
+}
+EOF
+
+/consul-bin/consul services register \
+  /consul/service/service.hcl'
+
+  [ "${actual}" = "${exp}" ]
+}
+
 @test "meshGateway/Deployment: mesh-gateway-init init container wanAddress.source=Service fails if service.enable is false" {
   cd `chart_dir`
   run helm template \
@@ -1965,3 +2134,221 @@ EOF
       yq -r '.spec.template.metadata.annotations.foo' | tee /dev/stderr)
   [ "${actual}" = "bar" ]
 }
+
+@test "meshGateway/Deployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
+#--------------------------------------------------------------------
+# wanAddress.watch
+
+@test "meshGateway/Deployment: service-address container is not added by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers | map(select(.name == "service-address")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "meshGateway/Deployment: wanAddress.watch fails if wanAddress.source is not Service or ServiceAnnotation" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=NodeIP' \
+      --set 'meshGateway.wanAddress.watch=true' \
+      .
+
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if meshGateway.wanAddress.watch=true then meshGateway.wanAddress.source must be ServiceAnnotation, or Service with meshGateway.service.type set to LoadBalancer or ClusterIP" ]]
+}
+
+@test "meshGateway/Deployment: wanAddress.watch fails if wanAddress.source=Service and service.type=NodePort" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.service.type=NodePort' \
+      --set 'meshGateway.service.nodePort=30000' \
+      --set 'meshGateway.wanAddress.watch=true' \
+      .
+
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "if meshGateway.wanAddress.watch=true then meshGateway.wanAddress.source must be ServiceAnnotation, or Service with meshGateway.service.type set to LoadBalancer or ClusterIP" ]]
+}
+
+@test "meshGateway/Deployment: service-address container is added with wanAddress.watch=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.watch=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers | map(select(.name == "service-address"))[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+      yq -r '.command | join(" ")' | tee /dev/stderr)
+  [ "${actual}" = "consul-k8s-control-plane service-address -log-level=info -log-json=false -k8s-namespace=default -name=release-name-consul-mesh-gateway -service-config=/consul/service/service.hcl -watch" ]
+
+  local actual=$(echo "$object" |
+      yq -r '.volumeMounts | map(select(.name == "consul-service"))[0].readOnly' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "meshGateway/Deployment: service-address container uses the annotation with wanAddress.source=ServiceAnnotation" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.wanAddress.source=ServiceAnnotation' \
+      --set 'meshGateway.wanAddress.watch=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers | map(select(.name == "service-address"))[0].command | any(. == "-annotation=consul.hashicorp.com/mesh-gateway-wan-address")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# prometheus port
+
+@test "meshGateway/Deployment: no prometheus port by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "meshGateway/Deployment: prometheus port with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports[] | select(.name == "prometheus") | .containerPort' | tee /dev/stderr)
+  [ "${actual}" = "20200" ]
+}
+
+@test "meshGateway/Deployment: no prometheus port with global.metrics.enableGatewayMetrics=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.enableGatewayMetrics=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "meshGateway/Deployment: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsUser')
+  [ "${actual}" = "100" ]
+  local actual=$(echo "$spec" | jq -r '[.containers[], .initContainers[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
+
+@test "meshGateway/Deployment: fails if global.restrictedPodSecurity.enabled=true and meshGateway.hostPort is set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'meshGateway.hostPort=443' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "meshGateway.hostPort and meshGateway.hostNetwork can't be set if global.restrictedPodSecurity.enabled is true" ]]
+}
+
+@test "meshGateway/Deployment: fails if global.restrictedPodSecurity.enabled=true and meshGateway.hostNetwork=true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'meshGateway.hostNetwork=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "meshGateway.hostPort and meshGateway.hostNetwork can't be set if global.restrictedPodSecurity.enabled is true" ]]
+}
+
+#--------------------------------------------------------------------
+# global.ipFamilies
+
+@test "meshGateway/Deployment: POD_IPS is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.initContainers[1].env | map(select(.name == "POD_IPS")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "meshGateway/Deployment: registers the IPv6 pod IP when global.ipFamilies.registration=IPv6" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/mesh-gateway-deployment.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.ipFamilies.enableIPv6=true' \
+      --set 'global.ipFamilies.registration=IPv6' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.initContainers[1].env[] | select(.name == "POD_IPS") | .valueFrom.fieldRef.fieldPath')
+  [ "${actual}" = "status.podIPs" ]
+  local actual=$(echo "$object" | yq -r '.initContainers[1].command | join(" ") | contains("grep '"'"':'"'"'")')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.initContainers[1].command | join(" ") | contains("tcp = \"[${POD_IP}]:8443\"")')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$object" | yq -r '.containers[0].env[] | select(.name == "CONSUL_GRPC_ADDR") | .value')
+  [ "${actual}" = '[$(HOST_IP)]:8502' ]
+}
@@ -0,0 +1,145 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "meshGateway/HorizontalPodAutoscaler: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: disabled when mesh gateways are disabled" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      .
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: fails if maxReplicas is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "meshGateway.autoscaling.maxReplicas must be set" ]]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: fails if maxReplicas is less than minReplicas" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=1' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "meshGateway.autoscaling.maxReplicas must be greater than or equal to meshGateway.autoscaling.minReplicas" ]]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: scales the mesh gateway Deployment" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $spec | yq -r '.scaleTargetRef.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-mesh-gateway" ]
+
+  # minReplicas defaults to replicas.
+  local actual=$(echo $spec | yq -r '.minReplicas' | tee /dev/stderr)
+  [ "${actual}" = "2" ]
+
+  local actual=$(echo $spec | yq -r '.maxReplicas' | tee /dev/stderr)
+  [ "${actual}" = "5" ]
+
+  local actual=$(echo $spec | yq -r '.metrics' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: can set minReplicas" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.minReplicas=3' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.spec.minReplicas' | tee /dev/stderr)
+  [ "${actual}" = "3" ]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: can set CPU, memory and custom metrics" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      --set 'meshGateway.autoscaling.targetCPUUtilizationPercentage=70' \
+      --set 'meshGateway.autoscaling.targetMemoryUtilizationPercentage=60' \
+      --set 'meshGateway.autoscaling.metrics[0].type=Pods' \
+      --set 'meshGateway.autoscaling.metrics[0].pods.metric.name=foo' \
+      --set 'meshGateway.autoscaling.metrics[0].pods.target.type=AverageValue' \
+      --set-string 'meshGateway.autoscaling.metrics[0].pods.target.averageValue=100' \
+      . | tee /dev/stderr |
+      yq -c '.spec.metrics' | tee /dev/stderr)
+  [ "${actual}" = '[{"type":"Resource","resource":{"name":"cpu","target":{"type":"Utilization","averageUtilization":70}}},{"type":"Resource","resource":{"name":"memory","target":{"type":"Utilization","averageUtilization":60}}},{"pods":{"metric":{"name":"foo"},"target":{"averageValue":"100","type":"AverageValue"}},"type":"Pods"}]' ]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: can set behavior" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      --set 'meshGateway.autoscaling.behavior.scaleDown.stabilizationWindowSeconds=600' \
+      . | tee /dev/stderr |
+      yq -r '.spec.behavior.scaleDown.stabilizationWindowSeconds' | tee /dev/stderr)
+  [ "${actual}" = "600" ]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: uses autoscaling/v2beta2 by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.apiVersion' | tee /dev/stderr)
+  [ "${actual}" = "autoscaling/v2beta2" ]
+}
+
+@test "meshGateway/HorizontalPodAutoscaler: uses autoscaling/v2 when it's available" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/mesh-gateway-horizontalpodautoscaler.yaml  \
+      --api-versions 'autoscaling/v2/HorizontalPodAutoscaler' \
+      --set 'meshGateway.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'meshGateway.autoscaling.enabled=true' \
+      --set 'meshGateway.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -r '.apiVersion' | tee /dev/stderr)
+  [ "${actual}" = "autoscaling/v2" ]
+}
@@ -0,0 +1,78 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "serverACLInit/ConfigMap: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-acl-init-configmap.yaml  \
+      .
+}
+
+@test "serverACLInit/ConfigMap: disabled with global.acls.manageSystemACLs=true and no auth methods" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-acl-init-configmap.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      .
+}
+
+@test "serverACLInit/ConfigMap: disabled with global.acls.authMethods and global.acls.manageSystemACLs=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-acl-init-configmap.yaml  \
+      --set 'global.acls.authMethods[0].name=ci' \
+      --set 'global.acls.authMethods[0].type=jwt' \
+      .
+}
+
+@test "serverACLInit/ConfigMap: renders global.acls.authMethods" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-configmap.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.authMethods[0].name=okta' \
+      --set 'global.acls.authMethods[0].type=oidc' \
+      --set 'global.acls.authMethods[0].config.OIDCClientID=client' \
+      --set 'global.acls.authMethods[0].oidcClientSecret.secretName=okta' \
+      --set 'global.acls.authMethods[0].oidcClientSecret.secretKey=secret' \
+      --set 'global.acls.authMethods[0].bindingRules[0].bindName=operator' \
+      --set 'global.acls.authMethods[1].name=ci' \
+      --set 'global.acls.authMethods[1].type=jwt' \
+      . | tee /dev/stderr |
+      yq -r '.data["auth-methods.json"]' | tee /dev/stderr)
+
+  local actual=$(echo $object | jq -r '.[0].config.OIDCClientID' | tee /dev/stderr)
+  [ "${actual}" = "client" ]
+
+  local actual=$(echo $object | jq -r '.[0].oidcClientSecretFile' | tee /dev/stderr)
+  [ "${actual}" = "/consul/auth-methods/okta/oidc-client-secret" ]
+
+  local actual=$(echo $object | jq -r '.[0].oidcClientSecret' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+
+  local actual=$(echo $object | jq -r '.[0].bindingRules[0].bindName' | tee /dev/stderr)
+  [ "${actual}" = "operator" ]
+
+  local actual=$(echo $object | jq -r '.[1].name' | tee /dev/stderr)
+  [ "${actual}" = "ci" ]
+
+  local actual=$(echo $object | jq -r '.[1].oidcClientSecretFile' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "serverACLInit/ConfigMap: renders global.acls.anonymousTokenPolicy.rules" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-configmap.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.anonymousTokenPolicy.rules=service "web" { policy = "read" }' \
+      . | tee /dev/stderr |
+      yq -r '.data' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.["anonymous-token-policy.hcl"]' | tee /dev/stderr)
+  [ "${actual}" = 'service "web" { policy = "read" }' ]
+
+  local actual=$(echo "$object" | yq 'has("auth-methods.json")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
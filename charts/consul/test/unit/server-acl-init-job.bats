@@ -251,6 +251,89 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# global.gossipEncryption.rotation
+
+@test "serverACLInit/Job: gossip encryption rotate acl option disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-gossip-encryption-rotate"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: gossip encryption rotate acl option enabled with global.gossipEncryption.rotation.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.gossipEncryption.autoGenerate=true' \
+      --set 'global.gossipEncryption.rotation.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-gossip-encryption-rotate"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.acls.loginAudit
+
+@test "serverACLInit/Job: acl login audit acl option disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-acl-login-audit"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: acl login audit acl option enabled with global.acls.loginAudit.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.loginAudit.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-acl-login-audit=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# server.connectCA
+
+@test "serverACLInit/Job: Connect CA TTLs are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-connect-ca-"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: Connect CA TTLs can be set with server.connectCA" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'server.connectCA.leafCertTTL=24h' \
+      --set 'server.connectCA.intermediateCertTTL=2160h' \
+      --set 'server.connectCA.rootCertTTL=43800h' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command')
+
+  local actual=$(echo $command | jq -r '. | any(contains("-connect-ca-leaf-cert-ttl=24h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | any(contains("-connect-ca-intermediate-cert-ttl=2160h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $command | jq -r '. | any(contains("-connect-ca-root-cert-ttl=43800h"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # syncCatalog.enabled
 
@@ -984,6 +1067,31 @@ load _helpers
   [ "${actual}" = "bar" ]
 }
 
+@test "serverACLInit/Job: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.secretName=foo' \
+      --set 'global.acls.bootstrapToken.secretKey=bar' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 #--------------------------------------------------------------------
 # namespaces
 
@@ -1406,6 +1514,39 @@ load _helpers
   [ "${actual}" = "false" ]
 }
 
+@test "serverACLInit/Job: fails in a non-default admin partition without externalServers.k8sAuthMethodHost" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=foo' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "externalServers.k8sAuthMethodHost must be set to an address of the Kubernetes API server that the Consul servers can reach when installing into a non-default admin partition" ]]
+}
+
+@test "serverACLInit/Job: sets the auth method host in a non-default admin partition" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=foo' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example' \
+      --set 'externalServers.k8sAuthMethodHost=https://kubernetes.example' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-auth-method-host=https://kubernetes.example"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 @test "serverACLInit/Job: admin partitions enabled when admin partitions are enabled" {
   cd `chart_dir`
   local object=$(helm template \
@@ -1425,6 +1566,30 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# peering
+
+@test "serverACLInit/Job: peering disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peering"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: peering enabled when global.peering.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.peering.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-peering=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # global.acls.createReplicationToken
 
@@ -1658,6 +1823,82 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "serverACLInit/Job: sets the port and TLS server name of externalServers.hosts entries" {
+  cd `chart_dir`
+  local command=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0].host=foo.com' \
+      --set 'externalServers.hosts[0].httpsPort=443' \
+      --set 'externalServers.hosts[0].tlsServerName=server.dc1.consul' \
+      --set 'externalServers.hosts[1]=bar.com' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$command" | yq 'any(contains("-server-address=\"foo.com:443\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$command" | yq 'any(contains("-server-tls-server-name=\"foo.com:443=server.dc1.consul\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$command" | yq 'any(contains("-server-address=\"bar.com\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$command" | yq 'any(contains("-server-tls-server-name=\"bar.com"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: uses externalServers.caCert to verify the external servers" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=foo.com' \
+      --set 'externalServers.caCert.secretName=lb-ca' \
+      --set 'externalServers.caCert.secretKey=ca.crt' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | yq -r '.volumes[] | select(.name=="external-servers-ca-cert") | .secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "lb-ca" ]
+
+  local actual=$(echo "$spec" | yq -r '.volumes[] | select(.name=="external-servers-ca-cert") | .secret.items[0].key' | tee /dev/stderr)
+  [ "${actual}" = "ca.crt" ]
+
+  local actual=$(echo "$spec" | yq -r '.containers[0].volumeMounts[] | select(.name=="external-servers-ca-cert") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/tls/external-servers-ca" ]
+
+  local actual=$(echo "$spec" | yq '.containers[0].command | any(contains("-consul-ca-cert=/consul/tls/external-servers-ca/tls.crt"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: externalServers.caCert is ignored with externalServers.useSystemRoots=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=foo.com' \
+      --set 'externalServers.caCert.secretName=lb-ca' \
+      --set 'externalServers.useSystemRoots=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | yq '[.volumes[] | select(.name=="external-servers-ca-cert")] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+
+  local actual=$(echo "$spec" | yq '.containers[0].command | any(contains("-consul-ca-cert"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # global.acls.bootstrapToken
 
@@ -1851,3 +2092,449 @@ load _helpers
       yq '.spec.template.spec.containers[0].command | any(contains("-federation"))' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# CSI secrets backend
+
+@test "serverACLInit/Job: bootstrap token is read from the CSI volume" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/server-acl-init-job.yaml  \
+    --set 'global.acls.manageSystemACLs=true' \
+    --set 'global.secretsBackend.csi.enabled=true' \
+    --set 'global.secretsBackend.csi.provider=aws' \
+    --set 'global.acls.bootstrapToken.secretName=consul' \
+    --set 'global.acls.bootstrapToken.secretKey=token' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "bootstrap-token") | .csi.volumeAttributes.secretProviderClass' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="post-install-job") | .volumeMounts[] | select(.name == "bootstrap-token") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/acl/tokens" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="post-install-job") | .command | any(contains("-bootstrap-token-file=/consul/acl/tokens/bootstrap-token"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.acls.bootstrapToken.vault
+
+@test "serverACLInit/Job: fails when bootstrapToken.vault.readPath is set without vault enabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.vault.address=https://vault:8200' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.bootstrapToken.vault.readPath requires global.secretsBackend.vault.enabled to be true" ]]
+}
+
+@test "serverACLInit/Job: fails when bootstrapToken.vault.readPath is set with bootstrapToken.secretName" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.secretName=foo' \
+      --set 'global.acls.bootstrapToken.secretKey=bar' \
+      --set 'global.acls.bootstrapToken.vault.address=https://vault:8200' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.bootstrapToken.vault.readPath can't be set with global.acls.bootstrapToken.secretName" ]]
+}
+
+@test "serverACLInit/Job: fails when bootstrapToken.vault.readPath is set without bootstrapToken.vault.address" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.bootstrapToken.vault.address is required when global.acls.bootstrapToken.vault.readPath is set" ]]
+}
+
+@test "serverACLInit/Job: reads the bootstrap token from Vault with bootstrapToken.vault.readPath" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.vault.address=https://vault:8200' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.metadata.annotations."vault.hashicorp.com/agent-inject-token"')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.metadata.annotations."vault.hashicorp.com/agent-inject-secret-bootstrap-token"')
+  [ "${actual}" = "null" ]
+
+  local actual=$(echo $object | yq '[.spec.volumes[]? | select(.name == "vault-ca-cert")] | length')
+  [ "${actual}" = "0" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-vault-address=https://vault:8200"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-vault-token-file=/vault/secrets/token"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-vault-ca-cert"))')
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-bootstrap-token-vault-read-path=consul/data/bootstrap-token"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-bootstrap-token-vault-write-path"))')
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-bootstrap-token-vault-key=token"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.spec.containers[0].command | any(contains("-bootstrap-token-file"))')
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: can set bootstrapToken.vault.writePath and bootstrapToken.vault.key" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.vault.address=https://vault:8200' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' \
+      --set 'global.acls.bootstrapToken.vault.writePath=consul-write/data/bootstrap-token' \
+      --set 'global.acls.bootstrapToken.vault.key=secret' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r 'any(contains("-bootstrap-token-vault-write-path=consul-write/data/bootstrap-token"))')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r 'any(contains("-bootstrap-token-vault-key=secret"))')
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: mounts the Vault CA with bootstrapToken.vault.readPath and vault.ca" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.bootstrapToken.vault.address=https://vault:8200' \
+      --set 'global.acls.bootstrapToken.vault.readPath=consul/data/bootstrap-token' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' \
+      --set 'global.secretsBackend.vault.ca.secretName=vault-ca' \
+      --set 'global.secretsBackend.vault.ca.secretKey=ca.crt' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local volume=$(echo $object | yq -r '.volumes[] | select(.name == "vault-ca-cert")')
+  local actual=$(echo $volume | yq -r '.secret.secretName')
+  [ "${actual}" = "vault-ca" ]
+  local actual=$(echo $volume | yq -r '.secret.items[0].key')
+  [ "${actual}" = "ca.crt" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].volumeMounts[] | select(.name == "vault-ca-cert") | .mountPath')
+  [ "${actual}" = "/consul/vault-ca" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].command | any(contains("-vault-ca-cert=/consul/vault-ca/tls.crt"))')
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.acls.authMethods
+
+@test "serverACLInit/Job: does not set -auth-methods-config-file by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].command | any(contains("-auth-methods-config-file"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: mounts the auth methods config with global.acls.authMethods" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.authMethods[0].name=ci' \
+      --set 'global.acls.authMethods[0].type=jwt' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "auth-methods-config") | .configMap.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server-acl-init" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].volumeMounts[] | select(.name == "auth-methods-config") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/auth-methods-config" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].command | any(contains("-auth-methods-config-file=/consul/auth-methods-config/auth-methods.json"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '[.volumes[] | select(.name | startswith("auth-method-oidc-client-secret"))] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "serverACLInit/Job: mounts the OIDC client secrets of global.acls.authMethods" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.authMethods[0].name=ci' \
+      --set 'global.acls.authMethods[0].type=jwt' \
+      --set 'global.acls.authMethods[1].name=okta' \
+      --set 'global.acls.authMethods[1].type=oidc' \
+      --set 'global.acls.authMethods[1].oidcClientSecret.secretName=okta-client' \
+      --set 'global.acls.authMethods[1].oidcClientSecret.secretKey=secret' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local volume=$(echo $object | yq -r '.volumes[] | select(.name == "auth-method-oidc-client-secret-1")' | tee /dev/stderr)
+  local actual=$(echo $volume | yq -r '.secret.secretName' | tee /dev/stderr)
+  [ "${actual}" = "okta-client" ]
+  local actual=$(echo $volume | yq -r '.secret.items[0].key' | tee /dev/stderr)
+  [ "${actual}" = "secret" ]
+  local actual=$(echo $volume | yq -r '.secret.items[0].path' | tee /dev/stderr)
+  [ "${actual}" = "oidc-client-secret" ]
+
+  local actual=$(echo $object | yq -r '.containers[0].volumeMounts[] | select(.name == "auth-method-oidc-client-secret-1") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/auth-methods/okta" ]
+}
+
+@test "serverACLInit/Job: fails when oidcClientSecret of global.acls.authMethods has no secretKey" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.authMethods[0].name=okta' \
+      --set 'global.acls.authMethods[0].type=oidc' \
+      --set 'global.acls.authMethods[0].oidcClientSecret.secretName=okta-client' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "both oidcClientSecret.secretName and oidcClientSecret.secretKey must be set for global.acls.authMethods" ]]
+}
+
+#--------------------------------------------------------------------
+# global.acls.awsIAMAuthMethod
+
+@test "serverACLInit/Job: AWS IAM auth method flags are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-aws-iam"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: AWS IAM auth method flags are set when global.acls.awsIAMAuthMethod.enabled=true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/consul-*' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[1]=arn:aws:iam::210987654321:role/consul-*' \
+      --set 'global.acls.awsIAMAuthMethod.serverIDHeaderValue=consul.example.com' \
+      --set 'global.acls.awsIAMAuthMethod.stsEndpoint=https://sts.us-west-2.amazonaws.com' \
+      --set 'global.acls.awsIAMAuthMethod.stsRegion=us-west-2' \
+      --set 'global.acls.awsIAMAuthMethod.connectInject=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-auth-method-bound-arn=\"arn:aws:iam::123456789012:role/consul-*\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-auth-method-bound-arn=\"arn:aws:iam::210987654321:role/consul-*\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-auth-method-server-id-header-value=consul.example.com"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-auth-method-sts-endpoint=https://sts.us-west-2.amazonaws.com"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-auth-method-sts-region=us-west-2"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-aws-iam-connect-inject=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: fails when global.acls.awsIAMAuthMethod.enabled=true without boundIAMPrincipalARNs" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs must be set if global.acls.awsIAMAuthMethod.enabled is true" ]]
+}
+
+@test "serverACLInit/Job: fails when global.acls.awsIAMAuthMethod.connectInject=true without enabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.awsIAMAuthMethod.connectInject=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.awsIAMAuthMethod.enabled must be true if global.acls.awsIAMAuthMethod.connectInject is true" ]]
+}
+
+@test "serverACLInit/Job: fails when global.acls.awsIAMAuthMethod.connectInject=true with namespace mirroring" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'connectInject.consulNamespaces.mirroringK8S=true' \
+      --set 'global.acls.awsIAMAuthMethod.enabled=true' \
+      --set 'global.acls.awsIAMAuthMethod.boundIAMPrincipalARNs[0]=arn:aws:iam::123456789012:role/*' \
+      --set 'global.acls.awsIAMAuthMethod.connectInject=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.awsIAMAuthMethod.connectInject is not supported with connectInject.consulNamespaces.mirroringK8S" ]]
+}
+
+#--------------------------------------------------------------------
+# global.acls.anonymousTokenPolicy
+
+@test "serverACLInit/Job: anonymous token flags are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-anonymous-token"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "serverACLInit/Job: anonymous token flags are set with global.acls.anonymousTokenPolicy" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.acls.anonymousTokenPolicy.servicePrefixes[0]=web' \
+      --set 'global.acls.anonymousTokenPolicy.servicePrefixes[1]=api-' \
+      --set 'global.acls.anonymousTokenPolicy.namespaces[0]=ns' \
+      --set 'global.acls.anonymousTokenPolicy.partitions[0]=default' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-anonymous-token-service-prefix=\"web\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-anonymous-token-service-prefix=\"api-\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-anonymous-token-namespace=\"ns\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-anonymous-token-partition=\"default\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: anonymous token policy file is mounted with global.acls.anonymousTokenPolicy.rules" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.anonymousTokenPolicy.rules=service "web" { policy = "read" }' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq -r '.volumes[] | select(.name == "anonymous-token-policy") | .configMap.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server-acl-init" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.containers[0].volumeMounts[] | select(.name == "anonymous-token-policy") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/anonymous-token-policy" ]
+
+  local actual=$(echo "$object" |
+    yq '.containers[0].command | any(contains("-anonymous-token-policy-file=/consul/anonymous-token-policy/anonymous-token-policy.hcl"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "serverACLInit/Job: fails when global.acls.anonymousTokenPolicy.rules is set with servicePrefixes" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.anonymousTokenPolicy.servicePrefixes[0]=web' \
+      --set 'global.acls.anonymousTokenPolicy.rules=service "web" { policy = "read" }' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.acls.anonymousTokenPolicy.rules can't be set with servicePrefixes, namespaces or partitions" ]]
+}
+
+@test "serverACLInit/Job: fails when global.acls.anonymousTokenPolicy.namespaces is set without global.enableConsulNamespaces" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.acls.anonymousTokenPolicy.namespaces[0]=ns' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.enableConsulNamespaces must be true if global.acls.anonymousTokenPolicy.namespaces is set" ]]
+}
+
+@test "serverACLInit/Job: fails when global.acls.anonymousTokenPolicy.partitions is set without global.adminPartitions.enabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'global.acls.anonymousTokenPolicy.partitions[0]=default' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.adminPartitions.enabled must be true if global.acls.anonymousTokenPolicy.partitions is set" ]]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "serverACLInit/Job: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-acl-init-job.yaml  \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsNonRoot')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '[.containers[], (.initContainers // [])[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
@@ -0,0 +1,142 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "server/Certificate: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-certificate.yaml  \
+      .
+}
+
+@test "server/Certificate: disabled with global.certManager.enabled=true and global.tls.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      .
+}
+
+@test "server/Certificate: disabled with server.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      --set 'server.enabled=false' \
+      .
+}
+
+@test "server/Certificate: fails if global.certManager.issuerRef.name is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.tls.caCert.secretName=consul-ca' \
+      --set 'global.certManager.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.certManager.issuerRef.name must be set if global.certManager.enabled=true" ]]
+}
+
+@test "server/Certificate: fails if global.tls.caCert.secretName is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.caCert.secretName must be set if global.certManager.enabled=true" ]]
+}
+
+@test "server/Certificate: fails if neither auto-encrypt nor the CA key is set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.caCert.secretName=consul-ca' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.enableAutoEncrypt must be true or global.tls.caKey.secretName must be set if global.certManager.enabled=true" ]]
+}
+
+@test "server/Certificate: fails if server.serverCert.secretName is set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.tls.caCert.secretName=consul-ca' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      --set 'server.serverCert.secretName=server-cert' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "server.serverCert.secretName cannot be set if global.certManager.enabled=true" ]]
+}
+
+@test "server/Certificate: issued to the server secret by the issuer" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.caKey.secretName=consul-ca' \
+      --set 'global.tls.caCert.secretName=consul-ca' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      --set 'global.certManager.issuerRef.kind=ClusterIssuer' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $spec | jq -r '.secretName' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server-cert" ]
+
+  local actual=$(echo $spec | jq -r '.commonName' | tee /dev/stderr)
+  [ "${actual}" = "server.dc1.consul" ]
+
+  local actual=$(echo $spec | jq -r '.issuerRef.name' | tee /dev/stderr)
+  [ "${actual}" = "consul-ca" ]
+
+  local actual=$(echo $spec | jq -r '.issuerRef.kind' | tee /dev/stderr)
+  [ "${actual}" = "ClusterIssuer" ]
+
+  local actual=$(echo $spec | jq -r '.issuerRef.group' | tee /dev/stderr)
+  [ "${actual}" = "cert-manager.io" ]
+
+  local actual=$(echo $spec | jq -r '.usages | index("client auth") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/Certificate: sets the SANs of the server certificate" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-certificate.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.tls.caCert.secretName=consul-ca' \
+      --set 'global.tls.serverAdditionalDNSSANs[0]=consul.example.com' \
+      --set 'global.tls.serverAdditionalIPSANs[0]=1.1.1.1' \
+      --set 'global.certManager.enabled=true' \
+      --set 'global.certManager.issuerRef.name=consul-ca' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $spec | jq -r '.dnsNames | index("*.release-name-consul-server.default.svc") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $spec | jq -r '.dnsNames | index("*.server.dc1.consul") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $spec | jq -r '.dnsNames | index("consul.example.com") != null' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $spec | jq -r '.ipAddresses | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "127.0.0.1,1.1.1.1" ]
+}
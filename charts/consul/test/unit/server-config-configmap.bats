@@ -497,6 +497,22 @@ load _helpers
   [ "${actual}" = "false" ]
 }
 
+@test "server/ConfigMap: sets the Vault namespace of the connect CA when global.secretsBackend.vault.connectCA.vaultNamespace is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.consulClientRole=foo' \
+      --set 'global.secretsBackend.vault.connectCA.address=example.com' \
+      --set 'global.secretsBackend.vault.connectCA.rootPKIPath=root' \
+      --set 'global.secretsBackend.vault.connectCA.intermediatePKIPath=int' \
+      --set 'global.secretsBackend.vault.connectCA.vaultNamespace=security' \
+      . | tee /dev/stderr |
+      yq -r '.data["connect-ca-config.json"]' | jq -r '.connect[0].ca_config[0].namespace' | tee /dev/stderr)
+  [ "${actual}" = "security" ]
+}
+
 @test "server/ConfigMap: adds connect CA config when vault is enabled and connect CA are configured" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -731,6 +747,97 @@ load _helpers
   [ "${actual}" = '{"https":8501}' ]
 }
 
+@test "server/ConfigMap: TLS minimum version and cipher suites are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.data["tls-config.json"]' | jq -r .tls | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "server/ConfigMap: sets the TLS minimum version and cipher suites with global.tls.minVersion and global.tls.cipherSuites" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_2' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      --set 'global.tls.cipherSuites[1]=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256' \
+      . | tee /dev/stderr |
+      yq -r '.data["tls-config.json"]' | jq -c .tls.defaults | tee /dev/stderr)
+  [ "${actual}" = '{"tls_cipher_suites":"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256","tls_min_version":"TLSv1_2"}' ]
+}
+
+@test "server/ConfigMap: sets only the TLS minimum version with global.tls.minVersion=TLSv1_3" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_3' \
+      . | tee /dev/stderr |
+      yq -r '.data["tls-config.json"]' | jq -c .tls.defaults | tee /dev/stderr)
+  [ "${actual}" = '{"tls_min_version":"TLSv1_3"}' ]
+}
+
+@test "server/ConfigMap: fails with an invalid global.tls.minVersion" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=tls12' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.minVersion must be one of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3" ]]
+}
+
+@test "server/ConfigMap: fails with global.tls.cipherSuites and global.tls.minVersion=TLSv1_3" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.minVersion=TLSv1_3' \
+      --set 'global.tls.cipherSuites[0]=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.tls.cipherSuites can't be set when global.tls.minVersion is TLSv1_3" ]]
+}
+
+#--------------------------------------------------------------------
+# server.connectCA
+
+@test "server/ConfigMap: Connect CA TTLs are not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | jq -c .connect | tee /dev/stderr)
+  [ "${actual}" = '{"enabled":true}' ]
+}
+
+@test "server/ConfigMap: Connect CA TTLs can be set with server.connectCA" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'server.connectCA.leafCertTTL=24h' \
+      --set 'server.connectCA.intermediateCertTTL=2160h' \
+      --set 'server.connectCA.rootCertTTL=43800h' \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | jq -c .connect.ca_config | tee /dev/stderr)
+  [ "${actual}" = '{"intermediate_cert_ttl":"2160h","leaf_cert_ttl":"24h","root_cert_ttl":"43800h"}' ]
+}
+
+@test "server/ConfigMap: only the Connect CA TTLs that are set are configured" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'server.connectCA.leafCertTTL=24h' \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | jq -c .connect.ca_config | tee /dev/stderr)
+  [ "${actual}" = '{"leaf_cert_ttl":"24h"}' ]
+}
+
 #--------------------------------------------------------------------
 # global.tls.enableAutoEncrypt
 
@@ -799,6 +906,54 @@ load _helpers
   [ "${actual}" = "5m" ]
 }
 
+#--------------------------------------------------------------------
+# telemetryCollector
+
+@test "server/ConfigMap: telemetry collector config is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      . | tee /dev/stderr |
+      yq '.data | has("telemetry-collector-config.json")' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "server/ConfigMap: sets dogstatsd_addr to the chart's collector when telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true'  \
+      --set 'telemetryCollector.clusterName=c1'  \
+      . | tee /dev/stderr |
+      yq -r '.data["telemetry-collector-config.json"]' | jq -r .telemetry.dogstatsd_addr | tee /dev/stderr)
+
+  [ "${actual}" = "release-name-consul-telemetry-collector.default.svc:8125" ]
+}
+
+@test "server/ConfigMap: sets dogstatsd_addr to telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc'  \
+      . | tee /dev/stderr |
+      yq -r '.data["telemetry-collector-config.json"]' | jq -r .telemetry.dogstatsd_addr | tee /dev/stderr)
+
+  [ "${actual}" = "otel.observability.svc:8125" ]
+}
+
+@test "server/ConfigMap: telemetry collector config is not set when telemetryCollector.metrics.enabled=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc'  \
+      --set 'telemetryCollector.metrics.enabled=false'  \
+      . | tee /dev/stderr |
+      yq '.data | has("telemetry-collector-config.json")' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
 #--------------------------------------------------------------------
 # auto_reload_config
 
@@ -816,6 +971,29 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "server/ConfigMap: auto reload config is set to true when TLS is enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true'  \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | jq -r .auto_reload_config | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "server/ConfigMap: auto reload config is set to true when certificates are issued by cert-manager" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-config-configmap.yaml  \
+      --set 'global.tls.enabled=true'  \
+      --set 'global.certManager.enabled=true'  \
+      . | tee /dev/stderr |
+      yq -r '.data["server.json"]' | jq -r .auto_reload_config | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
 @test "server/ConfigMap: auto reload config is not set by default" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -0,0 +1,153 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "server/PodMonitor: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      .
+}
+
+@test "server/PodMonitor: disabled with global.metrics.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "server/PodMonitor: disabled with global.metrics.prometheusOperator.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      .
+}
+
+@test "server/PodMonitor: can set global.metrics.prometheusOperator.labels" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.labels.release=prometheus' \
+      --set 'global.metrics.prometheusOperator.labels.team=mesh' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.labels | [.release, .team, .app] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "prometheus,mesh,consul" ]
+}
+
+@test "server/PodMonitor: can set global.metrics.prometheusOperator.interval and scrapeTimeout" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.metrics.prometheusOperator.interval=30s' \
+      --set 'global.metrics.prometheusOperator.scrapeTimeout=10s' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.interval, .scrapeTimeout] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "30s,10s" ]
+}
+
+@test "server/PodMonitor: selects the server pods" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.selector.matchLabels.component' | tee /dev/stderr)
+  [ "${actual}" = "server" ]
+}
+
+@test "server/PodMonitor: disabled with global.metrics.enableAgentMetrics=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      .
+}
+
+@test "server/PodMonitor: disabled with server.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'server.enabled=false' \
+      .
+}
+
+@test "server/PodMonitor: scrapes the agent metrics over HTTP" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0] | [.port, .path, .params.format[0]] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "http,/v1/agent/metrics,prometheus" ]
+}
+
+@test "server/PodMonitor: scrapes the agent metrics over HTTP with global.tls.httpsOnly=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.httpsOnly=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0].port' | tee /dev/stderr)
+  [ "${actual}" = "http" ]
+}
+
+@test "server/PodMonitor: scrapes the agent metrics over HTTPS with global.tls.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.httpsOnly=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '[.port, .scheme] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "https,https" ]
+
+  local actual=$(echo "$object" | yq -r '.tlsConfig.ca.secret | [.name, .key] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-ca-cert,tls.crt" ]
+
+  local actual=$(echo "$object" | yq -r '.tlsConfig.serverName' | tee /dev/stderr)
+  [ "${actual}" = "server.dc1.consul" ]
+}
+
+@test "server/PodMonitor: uses global.tls.caCert with global.tls.httpsOnly=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-podmonitor.yaml  \
+      --set 'global.metrics.enableAgentMetrics=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.prometheusOperator.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.httpsOnly=true' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.tls.caCert.secretKey=bar' \
+      . | tee /dev/stderr |
+      yq -r '.spec.podMetricsEndpoints[0].tlsConfig.ca.secret | [.name, .key] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "foo,bar" ]
+}
@@ -0,0 +1,125 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "server/SecretProviderClass: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.gossipEncryption.secretName=consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' \
+      .
+}
+
+@test "server/SecretProviderClass: disabled without secrets" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      .
+}
+
+@test "server/SecretProviderClass: enabled with external servers" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'server.enabled=false' \
+      --set 'externalServers.enabled=true' \
+      --set 'externalServers.hosts[0]=consul.example.com' \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.acls.bootstrapToken.secretName=consul' \
+      --set 'global.acls.bootstrapToken.secretKey=token' \
+      . | tee /dev/stderr |
+      yq -r '.metadata.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server" ]
+}
+
+@test "server/SecretProviderClass: fails with an unknown provider" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=foo' \
+      --set 'global.gossipEncryption.secretName=consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.secretsBackend.csi.provider must be one of aws, gcp or azure" ]]
+}
+
+@test "server/SecretProviderClass: aws objects with a path per key" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.secretsBackend.csi.parameters.region=us-east-1' \
+      --set 'global.gossipEncryption.secretName=prod/consul' \
+      --set 'global.gossipEncryption.secretKey=gossip' \
+      --set 'global.acls.bootstrapToken.secretName=prod/consul' \
+      --set 'global.acls.bootstrapToken.secretKey=token' \
+      --set 'global.enterpriseLicense.secretName=license' \
+      --set 'global.enterpriseLicense.secretKey=key' \
+      . | tee /dev/stderr |
+      yq -r '.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | jq -r '.provider')
+  [ "${actual}" = "aws" ]
+
+  local actual=$(echo $object | jq -r '.parameters.region')
+  [ "${actual}" = "us-east-1" ]
+
+  local objects=$(echo "$object" | jq -r '.parameters.objects' | yq -c '.')
+  local actual=$(echo $objects | jq -c '[.[] | {objectName, objectAlias, jmesPath}]')
+  [ "${actual}" = '[{"objectName":"license","objectAlias":"aws-license.json","jmesPath":[{"path":"key","objectAlias":"enterpriselicense.txt"}]},{"objectName":"prod/consul","objectAlias":"aws-prod-consul.json","jmesPath":[{"path":"token","objectAlias":"bootstrap-token"},{"path":"gossip","objectAlias":"gossip.txt"}]}]' ]
+}
+
+@test "server/SecretProviderClass: gcp secrets" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=gcp' \
+      --set 'global.gossipEncryption.secretName=projects/consul/secrets/gossip' \
+      --set 'global.gossipEncryption.secretKey=latest' \
+      . | tee /dev/stderr |
+      yq -r '.spec.parameters.secrets' | yq -c '.' | tee /dev/stderr)
+  [ "${actual}" = '[{"resourceName":"projects/consul/secrets/gossip/versions/latest","path":"gossip.txt"}]' ]
+}
+
+@test "server/SecretProviderClass: azure objects" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=azure' \
+      --set 'global.secretsBackend.csi.parameters.keyvaultName=consul' \
+      --set 'global.gossipEncryption.secretName=gossip' \
+      --set 'global.gossipEncryption.secretKey=latest' \
+      --set 'global.acls.bootstrapToken.secretName=token' \
+      --set 'global.acls.bootstrapToken.secretKey=1234' \
+      . | tee /dev/stderr |
+      yq -r '.spec.parameters' | tee /dev/stderr)
+
+  local actual=$(echo $object | jq -r '.keyvaultName')
+  [ "${actual}" = "consul" ]
+
+  local actual=$(echo "$object" | jq -r '.objects' | yq -r '.array[0]' | yq -c '.')
+  [ "${actual}" = '{"objectName":"token","objectType":"secret","objectAlias":"bootstrap-token","objectVersion":"1234"}' ]
+
+  local actual=$(echo "$object" | jq -r '.objects' | yq -r '.array[1]' | yq -c '.')
+  [ "${actual}" = '{"objectName":"gossip","objectType":"secret","objectAlias":"gossip.txt"}' ]
+}
+
+@test "server/SecretProviderClass: license requires enableLicenseAutoload" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/server-secretproviderclass.yaml  \
+      --set 'global.secretsBackend.csi.enabled=true' \
+      --set 'global.secretsBackend.csi.provider=aws' \
+      --set 'global.enterpriseLicense.secretName=license' \
+      --set 'global.enterpriseLicense.secretKey=key' \
+      --set 'global.enterpriseLicense.enableLicenseAutoload=false' \
+      .
+}
@@ -662,6 +662,75 @@ load _helpers
   [[ "$output" =~ "global.metrics.enableAgentMetrics cannot be enabled if TLS (HTTPS only) is enabled" ]]
 }
 
+#--------------------------------------------------------------------
+# global.metrics.datadog
+
+@test "server/StatefulSet: DogStatsD isn't configured by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "server/StatefulSet: sends telemetry to the DogStatsD socket when global.metrics.datadog.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.dogstatsdTags[0]=env:prod'  \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr = \\\"unix:///var/run/datadog/dsd.socket\\\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_tags = [\"source:consul\",\"consul_service:consul-server\",\"env:prod\"], disable_hostname = true")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.spec.template.spec.volumes[] | select(.name == "datadog-dsd-socket") | .hostPath.path' | tee /dev/stderr)
+  [ "${actual}" = "/var/run/datadog" ]
+
+  local actual=$(echo "$object" |
+    yq -r '.spec.template.spec.containers[0].volumeMounts[] | select(.name == "datadog-dsd-socket") | .readOnly' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/StatefulSet: sends telemetry to DogStatsD over UDP when global.metrics.datadog.dogstatsd.socketTransportType=UDP" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=UDP'  \
+      . | tee /dev/stderr)
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.containers[0].command | join(" ") | contains("dogstatsd_addr = \\\"${HOST_IP}:8125\\\"")' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$object" |
+    yq '.spec.template.spec.volumes | map(select(.name == "datadog-dsd-socket")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "server/StatefulSet: fails when global.metrics.datadog.dogstatsd.socketTransportType is invalid" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.metrics.enabled=true'  \
+      --set 'global.metrics.datadog.enabled=true'  \
+      --set 'global.metrics.datadog.dogstatsd.socketTransportType=TCP'  \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.metrics.datadog.dogstatsd.socketTransportType must be" ]]
+}
+
 #--------------------------------------------------------------------
 # config-configmap
 
@@ -1859,6 +1928,23 @@ load _helpers
   [ "${actual}" = "bar" ]
 }
 
+@test "server/StatefulSet: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
 #--------------------------------------------------------------------
 # Vault bootstrap token
 
@@ -1891,6 +1977,70 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+@test "server/StatefulSet: vault secrets are read from their Vault namespaces when set" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/server-statefulset.yaml  \
+    --set 'global.tls.enabled=true' \
+    --set 'global.tls.enableAutoEncrypt=true' \
+    --set 'global.secretsBackend.vault.enabled=true' \
+    --set 'global.secretsBackend.vault.consulClientRole=test' \
+    --set 'global.secretsBackend.vault.consulServerRole=foo' \
+    --set 'global.secretsBackend.vault.consulCARole=test' \
+    --set 'global.secretsBackend.vault.manageSystemACLsRole=aclrole' \
+    --set 'global.tls.caCert.secretName=pki_int/cert/ca' \
+    --set 'global.tls.caCert.vaultNamespace=security/' \
+    --set 'server.serverCert.secretName=pki_int/issue/test' \
+    --set 'server.serverCert.vaultNamespace=security' \
+    --set 'global.gossipEncryption.secretName=path/to/gossip' \
+    --set 'global.gossipEncryption.secretKey=gossip' \
+    --set 'global.gossipEncryption.vaultNamespace=platform' \
+    --set 'global.enterpriseLicense.secretName=path/to/license' \
+    --set 'global.enterpriseLicense.secretKey=license' \
+    --set 'global.enterpriseLicense.vaultNamespace=licensing' \
+    --set 'global.acls.manageSystemACLs=true' \
+    --set 'global.acls.bootstrapToken.secretName=path/to/bootstrap-token' \
+    --set 'global.acls.bootstrapToken.secretKey=token' \
+    --set 'global.acls.bootstrapToken.vaultNamespace=platform/consul' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations' | tee /dev/stderr)
+
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-secret-serverca.crt"]' | tee /dev/stderr)"
+  [ "${actual}" = "security/pki_int/cert/ca" ]
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-template-serverca.crt"]' | tee /dev/stderr)"
+  local expected=$'{{- with secret \"security/pki_int/cert/ca\" -}}\n{{- .Data.certificate -}}\n{{- end -}}'
+  [ "${actual}" = "${expected}" ]
+
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-secret-servercert.crt"]' | tee /dev/stderr)"
+  [ "${actual}" = "security/pki_int/issue/test" ]
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-template-servercert.key"] | startswith("{{- with secret \"security/pki_int/issue/test\"")' | tee /dev/stderr)"
+  [ "${actual}" = "true" ]
+
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-secret-gossip.txt"]' | tee /dev/stderr)"
+  [ "${actual}" = "platform/path/to/gossip" ]
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-template-gossip.txt"]' | tee /dev/stderr)"
+  local expected=$'{{- with secret \"platform/path/to/gossip\" -}}\n{{- .Data.data.gossip -}}\n{{- end -}}'
+  [ "${actual}" = "${expected}" ]
+
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-secret-enterpriselicense.txt"]' | tee /dev/stderr)"
+  [ "${actual}" = "licensing/path/to/license" ]
+
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-secret-bootstrap-token-config.hcl"]' | tee /dev/stderr)"
+  [ "${actual}" = "platform/consul/path/to/bootstrap-token" ]
+  local actual="$(echo $object |
+      yq -r '.["vault.hashicorp.com/agent-inject-template-bootstrap-token-config.hcl"]' | tee /dev/stderr)"
+  local expected=$'{{- with secret \"platform/consul/path/to/bootstrap-token\" -}}\nacl { tokens { initial_management = \"{{- .Data.data.token -}}\" }}\n{{- end -}}'
+  [ "${actual}" = "${expected}" ]
+}
+
 #--------------------------------------------------------------------
 # Vault replication token
 
@@ -1924,3 +2074,140 @@ load _helpers
   local actual="$(echo $object | yq -r '.spec.containers[] | select(.name=="consul").command | any(contains("-config-file=/vault/secrets/replication-token-config.hcl"))' | tee /dev/stderr)"
   [ "${actual}" = "true" ]
 }
+
+#--------------------------------------------------------------------
+# CSI secrets backend
+
+@test "server/StatefulSet: fails if both the vault and CSI secrets backends are enabled" {
+  cd `chart_dir`
+  run helm template \
+    -s templates/server-statefulset.yaml  \
+    --set 'global.secretsBackend.vault.enabled=true' \
+    --set 'global.secretsBackend.vault.consulClientRole=test' \
+    --set 'global.secretsBackend.vault.consulServerRole=foo' \
+    --set 'global.secretsBackend.csi.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "only one of global.secretsBackend.vault.enabled or global.secretsBackend.csi.enabled can be set" ]]
+}
+
+@test "server/StatefulSet: no CSI volume without secrets" {
+  cd `chart_dir`
+  local actual=$(helm template \
+    -s templates/server-statefulset.yaml  \
+    --set 'global.secretsBackend.csi.enabled=true' \
+    --set 'global.secretsBackend.csi.provider=aws' \
+    . | tee /dev/stderr |
+      yq '.spec.template.spec.volumes[] | select(.name == "consul-secrets")' | tee /dev/stderr)
+  [ "${actual}" = "" ]
+}
+
+@test "server/StatefulSet: gossip key, license and bootstrap token are read from the CSI volume" {
+  cd `chart_dir`
+  local object=$(helm template \
+    -s templates/server-statefulset.yaml  \
+    --set 'global.secretsBackend.csi.enabled=true' \
+    --set 'global.secretsBackend.csi.provider=aws' \
+    --set 'global.gossipEncryption.secretName=consul' \
+    --set 'global.gossipEncryption.secretKey=gossip' \
+    --set 'global.enterpriseLicense.secretName=consul' \
+    --set 'global.enterpriseLicense.secretKey=license' \
+    --set 'global.acls.manageSystemACLs=true' \
+    --set 'global.acls.bootstrapToken.secretName=consul' \
+    --set 'global.acls.bootstrapToken.secretKey=token' \
+    . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "consul-secrets") | .csi.volumeAttributes.secretProviderClass' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-server" ]
+
+  local actual=$(echo $object | yq -r '.volumes[] | select(.name == "consul-license")' | tee /dev/stderr)
+  [ "${actual}" = "" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .volumeMounts[] | select(.name == "consul-secrets") | .mountPath' | tee /dev/stderr)
+  [ "${actual}" = "/consul/secrets" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | [.env[].name] | any(. == "GOSSIP_KEY" or . == "ACL_BOOTSTRAP_TOKEN")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .env[] | select(.name == "CONSUL_LICENSE_PATH") | .value' | tee /dev/stderr)
+  [ "${actual}" = "/consul/secrets/enterpriselicense.txt" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .command | any(contains("GOSSIP_KEY=`cat /consul/secrets/gossip.txt`"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .command | any(contains("ACL_BOOTSTRAP_TOKEN=`cat /consul/secrets/bootstrap-token`"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $object | yq -r '.containers[] | select(.name=="consul") | .command | any(contains("initial_management"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "server/StatefulSet: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'server.securityContext.runAsUser=200' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsNonRoot')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsUser')
+  [ "${actual}" = "200" ]
+
+  local actual=$(echo "$spec" | jq -r '.containers[] | select(.name=="consul") | .securityContext.allowPrivilegeEscalation')
+  [ "${actual}" = "false" ]
+  local actual=$(echo "$spec" | jq -c '.containers[] | select(.name=="consul") | .securityContext.capabilities.drop')
+  [ "${actual}" = '["ALL"]' ]
+  local actual=$(echo "$spec" | jq -r '.containers[] | select(.name=="consul") | .securityContext.readOnlyRootFilesystem')
+  [ "${actual}" = "null" ]
+}
+
+@test "server/StatefulSet: can set readOnlyRootFilesystem with global.restrictedPodSecurity" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.restrictedPodSecurity.readOnlyRootFilesystem=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[] | select(.name=="consul") | .securityContext.readOnlyRootFilesystem' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "server/StatefulSet: user and group are not set with global.restrictedPodSecurity and global.openshift.enabled=true" {
+  cd `chart_dir`
+  local security_context=$(helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.openshift.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.spec.template.spec.securityContext' | tee /dev/stderr)
+  [ "${security_context}" = '{"runAsNonRoot":true,"seccompProfile":{"type":"RuntimeDefault"}}' ]
+}
+
+@test "server/StatefulSet: fails if global.restrictedPodSecurity.enabled=true and server.exposeGossipAndRPCPorts=true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'server.exposeGossipAndRPCPorts=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "server.exposeGossipAndRPCPorts must be false if global.restrictedPodSecurity.enabled is true" ]]
+}
+
+@test "server/StatefulSet: fails if global.restrictedPodSecurity.enabled=true and the DogStatsD socket is used" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/server-statefulset.yaml  \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.datadog.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "global.metrics.datadog.dogstatsd.socketTransportType must be UDP if global.restrictedPodSecurity.enabled is true" ]]
+}
@@ -46,6 +46,32 @@ load _helpers
   [ "${actual}" = "true" ]
 }
 
+#--------------------------------------------------------------------
+# pods
+
+@test "syncCatalog/ClusterRole: allows watching pods" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[1] | (.resources | join(",")) + ":" + (.verbs | join(","))' | tee /dev/stderr)
+  [ "${actual}" = "nodes,pods:get,list,watch" ]
+}
+
+#--------------------------------------------------------------------
+# endpointslices
+
+@test "syncCatalog/ClusterRole: allows endpointslices access" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[2] | .apiGroups[0] + "/" + .resources[0] + ":" + (.verbs | join(","))' | tee /dev/stderr)
+  [ "${actual}" = "discovery.k8s.io/endpointslices:get,list,watch" ]
+}
+
 #--------------------------------------------------------------------
 # global.enablePodSecurityPolicies
 
@@ -56,7 +82,7 @@ load _helpers
       --set 'syncCatalog.enabled=true' \
       --set 'global.enablePodSecurityPolicies=true' \
       . | tee /dev/stderr |
-      yq -r '.rules[2].resources[0]' | tee /dev/stderr)
+      yq -r '.rules[3].resources[0]' | tee /dev/stderr)
   [ "${actual}" = "podsecuritypolicies" ]
 }
 
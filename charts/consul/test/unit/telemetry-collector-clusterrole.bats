@@ -0,0 +1,42 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/ClusterRole: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-clusterrole.yaml  \
+      .
+}
+
+@test "telemetryCollector/ClusterRole: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-clusterrole.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/ClusterRole: disabled with telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-clusterrole.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      .
+}
+
+@test "telemetryCollector/ClusterRole: allows podsecuritypolicies access with global.enablePodSecurityPolicies=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-clusterrole.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules[1].resources[0]' | tee /dev/stderr)
+  [ "${actual}" = "podsecuritypolicies" ]
+}
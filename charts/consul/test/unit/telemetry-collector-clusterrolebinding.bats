@@ -0,0 +1,30 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/ClusterRoleBinding: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-clusterrolebinding.yaml  \
+      .
+}
+
+@test "telemetryCollector/ClusterRoleBinding: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-clusterrolebinding.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/ClusterRoleBinding: disabled with telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-clusterrolebinding.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      .
+}
@@ -0,0 +1,135 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/ConfigMap: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      .
+}
+
+@test "telemetryCollector/ConfigMap: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/ConfigMap: disabled with telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      .
+}
+
+@test "telemetryCollector/ConfigMap: fails without telemetryCollector.clusterName" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "telemetryCollector.clusterName must be set if telemetryCollector.enabled is true" ]]
+}
+
+@test "telemetryCollector/ConfigMap: fails with empty telemetryCollector.exporters" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'telemetryCollector.exporters=null' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "telemetryCollector.exporters must not be empty" ]]
+}
+
+@test "telemetryCollector/ConfigMap: sets the resource attributes" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'global.datacenter=dc2' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | yq -c '[.processors.resource.attributes[] | {(.key): .value}] | add' | tee /dev/stderr)
+  [ "${actual}" = '{"k8s.cluster.name":"c1","consul.datacenter":"dc2"}' ]
+}
+
+@test "telemetryCollector/ConfigMap: sets the consul.partition resource attribute with global.adminPartitions.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'global.adminPartitions.enabled=true' \
+      --set 'global.adminPartitions.name=default' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | yq -r '.processors.resource.attributes[] | select(.key == "consul.partition") | .value' | tee /dev/stderr)
+  [ "${actual}" = "default" ]
+}
+
+@test "telemetryCollector/ConfigMap: receives metrics and traces by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | yq -c '.service.pipelines | map_values(.receivers)' | tee /dev/stderr)
+  [ "${actual}" = '{"metrics":["statsd","prometheus","otlp"],"traces":["zipkin","otlp"]}' ]
+}
+
+@test "telemetryCollector/ConfigMap: only receives OTLP when metrics and traces are disabled" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'telemetryCollector.metrics.enabled=false' \
+      --set 'telemetryCollector.traces.enabled=false' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -c '.receivers | keys' | tee /dev/stderr)
+  [ "${actual}" = '["otlp"]' ]
+
+  local actual=$(echo "$object" | yq -c '.service.pipelines | map_values(.receivers)' | tee /dev/stderr)
+  [ "${actual}" = '{"metrics":["otlp"],"traces":["otlp"]}' ]
+}
+
+@test "telemetryCollector/ConfigMap: exports to the logging exporter by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | yq -c '.service.pipelines | map_values(.exporters)' | tee /dev/stderr)
+  [ "${actual}" = '{"metrics":["logging"],"traces":["logging"]}' ]
+}
+
+@test "telemetryCollector/ConfigMap: exports to telemetryCollector.exporters" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/telemetry-collector-configmap.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'telemetryCollector.exporters.otlp.endpoint=backend:4317' \
+      --set 'telemetryCollector.exporters.jaeger.endpoint=jaeger:14250' \
+      . | tee /dev/stderr |
+      yq -r '.data["collector.yaml"]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.exporters.otlp.endpoint' | tee /dev/stderr)
+  [ "${actual}" = "backend:4317" ]
+
+  local actual=$(echo "$object" | yq -c '.service.pipelines.traces.exporters' | tee /dev/stderr)
+  [ "${actual}" = '["jaeger","logging","otlp"]' ]
+}
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/PodSecurityPolicy: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-podsecuritypolicy.yaml  \
+      .
+}
+
+@test "telemetryCollector/PodSecurityPolicy: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-podsecuritypolicy.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/PodSecurityPolicy: disabled with telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-podsecuritypolicy.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      --set 'global.enablePodSecurityPolicies=true' \
+      .
+}
+
+@test "telemetryCollector/PodSecurityPolicy: disabled with global.enablePodSecurityPolicies=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-podsecuritypolicy.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      .
+}
@@ -0,0 +1,65 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "telemetryCollector/Service: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      .
+}
+
+@test "telemetryCollector/Service: enabled with telemetryCollector.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq 'length > 0' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "telemetryCollector/Service: disabled with telemetryCollector.existingCollectorHost" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.existingCollectorHost=otel.observability.svc' \
+      .
+}
+
+@test "telemetryCollector/Service: exposes the statsd and zipkin ports by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq -c '[.spec.ports[] | .name]' | tee /dev/stderr)
+  [ "${actual}" = '["otlp-grpc","otlp-http","statsd","zipkin"]' ]
+}
+
+@test "telemetryCollector/Service: the statsd port is UDP" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      . | tee /dev/stderr |
+      yq -r '.spec.ports[] | select(.name == "statsd") | .protocol' | tee /dev/stderr)
+  [ "${actual}" = "UDP" ]
+}
+
+@test "telemetryCollector/Service: does not expose the statsd and zipkin ports when metrics and traces are disabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/telemetry-collector-service.yaml  \
+      --set 'telemetryCollector.enabled=true' \
+      --set 'telemetryCollector.clusterName=c1' \
+      --set 'telemetryCollector.metrics.enabled=false' \
+      --set 'telemetryCollector.traces.enabled=false' \
+      . | tee /dev/stderr |
+      yq -c '[.spec.ports[] | .name]' | tee /dev/stderr)
+  [ "${actual}" = '["otlp-grpc","otlp-http"]' ]
+}
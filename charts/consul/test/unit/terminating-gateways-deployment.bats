@@ -562,6 +562,34 @@ load _helpers
   [ "${actual}" = "12" ]
 }
 
+@test "terminatingGateways/Deployment: replicas is not set when autoscaling is enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.maxReplicas=5' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.replicas' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "terminatingGateways/Deployment: replicas is set when autoscaling is disabled for a specific gateway" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.maxReplicas=5' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[0].autoscaling.enabled=false' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.replicas' | tee /dev/stderr)
+  [ "${actual}" = "2" ]
+}
+
 #--------------------------------------------------------------------
 # extraVolumes
 
@@ -707,6 +735,53 @@ load _helpers
   [ "${actual}" = "/consul/userconfig/foo" ]
 }
 
+#--------------------------------------------------------------------
+# caSecrets
+
+@test "terminatingGateways/Deployment: no CA secrets are mounted by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '[.[0].spec.template.spec.volumes[] | select(.name | startswith("external-ca-"))] | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "terminatingGateways/Deployment: mounts CA secrets" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.caSecrets[0]=example-ca' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo $object |
+      yq -c '.volumes[] | select(.name == "external-ca-example-ca")' | tee /dev/stderr)
+  [ "${actual}" = '{"name":"external-ca-example-ca","secret":{"secretName":"example-ca","items":[{"key":"ca.crt","path":"ca.crt"}]}}' ]
+
+  local actual=$(echo $object |
+      yq -c '.containers[0].volumeMounts[] | select(.name == "external-ca-example-ca")' | tee /dev/stderr)
+  [ "${actual}" = '{"name":"external-ca-example-ca","readOnly":true,"mountPath":"/consul/external-ca/example-ca"}' ]
+}
+
+@test "terminatingGateways/Deployment: CA secrets of a specific gateway override defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.caSecrets[0]=default-ca' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[0].caSecrets[0]=example-ca' \
+      . | tee /dev/stderr |
+      yq -s -c '[.[0].spec.template.spec.containers[0].volumeMounts[] | select(.name | startswith("external-ca-")) | .mountPath]' | tee /dev/stderr)
+  [ "${actual}" = '["/consul/external-ca/example-ca"]' ]
+}
+
 #--------------------------------------------------------------------
 # resources
 
@@ -1006,6 +1081,46 @@ load _helpers
   [ "${actual}" = "value2" ]
 }
 
+#--------------------------------------------------------------------
+# topologySpreadConstraints
+
+@test "terminatingGateways/Deployment: no topologySpreadConstraints by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints' | tee /dev/stderr)
+  [ "${actual}" = "null" ]
+}
+
+@test "terminatingGateways/Deployment: topologySpreadConstraints can be set through defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.topologySpreadConstraints=- topologyKey: zone' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints[0].topologyKey' | tee /dev/stderr)
+  [ "${actual}" = "zone" ]
+}
+
+@test "terminatingGateways/Deployment: topologySpreadConstraints can be set through specific gateway, overriding defaults" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.topologySpreadConstraints=- topologyKey: zone' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[0].topologySpreadConstraints=- topologyKey: rack' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec.topologySpreadConstraints[0].topologyKey' | tee /dev/stderr)
+  [ "${actual}" = "rack" ]
+}
+
 #--------------------------------------------------------------------
 # nodeSelector
 
@@ -1658,3 +1773,87 @@ EOF
       yq -r '.spec.template.metadata.annotations.foo' | tee /dev/stderr)
   [ "${actual}" = "bar" ]
 }
+
+@test "terminatingGateway/Deployment: vault agent is configured with global.secretsBackend.vault.agent" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.tls.enabled=true' \
+      --set 'global.tls.enableAutoEncrypt=true' \
+      --set 'global.secretsBackend.vault.enabled=true' \
+      --set 'global.secretsBackend.vault.consulClientRole=test' \
+      --set 'global.secretsBackend.vault.consulServerRole=foo' \
+      --set 'global.tls.caCert.secretName=foo' \
+      --set 'global.secretsBackend.vault.consulCARole=carole' \
+      --set 'global.secretsBackend.vault.agent.cache.enabled=true' \
+      --set 'global.secretsBackend.vault.agent.clientMaxRetries=10' \
+      --set 'global.secretsBackend.vault.agent.clientTimeout=60s' \
+      --set 'global.secretsBackend.vault.agent.exitOnRetryFailure=false' \
+      --set 'global.secretsBackend.vault.agent.revokeOnShutdown=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.metadata.annotations | [."vault.hashicorp.com/agent-cache-enable", ."vault.hashicorp.com/agent-cache-use-auto-auth-token", ."vault.hashicorp.com/client-max-retries", ."vault.hashicorp.com/client-timeout", ."vault.hashicorp.com/template-config-exit-on-retry-failure", ."vault.hashicorp.com/agent-revoke-on-shutdown"] | join(",")' | tee /dev/stderr)
+  [ "${actual}" = "true,true,10,60s,false,true" ]
+}
+
+#--------------------------------------------------------------------
+# prometheus port
+
+@test "terminatingGateways/Deployment: no prometheus port by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "terminatingGateways/Deployment: prometheus port with global.metrics.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports[] | select(.name == "prometheus") | .containerPort' | tee /dev/stderr)
+  [ "${actual}" = "20200" ]
+}
+
+@test "terminatingGateways/Deployment: no prometheus port with global.metrics.enableGatewayMetrics=false" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.metrics.enabled=true' \
+      --set 'global.metrics.enableGatewayMetrics=false' \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].ports | map(select(.name == "prometheus")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+#--------------------------------------------------------------------
+# global.restrictedPodSecurity
+
+@test "terminatingGateways/Deployment: restricted security contexts are set when global.restrictedPodSecurity.enabled=true" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/terminating-gateways-deployment.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'global.acls.manageSystemACLs=true' \
+      --set 'global.restrictedPodSecurity.enabled=true' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.template.spec' | tee /dev/stderr)
+
+  local actual=$(echo "$spec" | jq -r '.securityContext.runAsNonRoot')
+  [ "${actual}" = "true" ]
+  local actual=$(echo "$spec" | jq -r '.securityContext.seccompProfile.type')
+  [ "${actual}" = "RuntimeDefault" ]
+  local actual=$(echo "$spec" | jq -r '[.containers[], (.initContainers // [])[]] | map(.securityContext.allowPrivilegeEscalation == false and .securityContext.capabilities.drop == ["ALL"]) | all')
+  [ "${actual}" = "true" ]
+}
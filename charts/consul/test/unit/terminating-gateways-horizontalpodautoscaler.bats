@@ -0,0 +1,98 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "terminatingGateways/HorizontalPodAutoscaler: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "terminatingGateways/HorizontalPodAutoscaler: fails if maxReplicas is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "terminatingGateways.defaults.autoscaling.maxReplicas must be set" ]]
+}
+
+@test "terminatingGateways/HorizontalPodAutoscaler: fails if maxReplicas of a specific gateway is not set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[0].autoscaling.enabled=true' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "terminatingGateways.gateways[0].autoscaling.maxReplicas must be set" ]]
+}
+
+@test "terminatingGateways/HorizontalPodAutoscaler: scales the gateway Deployment" {
+  cd `chart_dir`
+  local spec=$(helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.maxReplicas=5' \
+      --set 'terminatingGateways.defaults.autoscaling.targetCPUUtilizationPercentage=70' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec' | tee /dev/stderr)
+
+  local actual=$(echo $spec | yq -r '.scaleTargetRef.name' | tee /dev/stderr)
+  [ "${actual}" = "release-name-consul-terminating-gateway" ]
+
+  # minReplicas defaults to replicas.
+  local actual=$(echo $spec | yq -r '.minReplicas' | tee /dev/stderr)
+  [ "${actual}" = "2" ]
+
+  local actual=$(echo $spec | yq -r '.maxReplicas' | tee /dev/stderr)
+  [ "${actual}" = "5" ]
+
+  local actual=$(echo $spec | yq -c '.metrics' | tee /dev/stderr)
+  [ "${actual}" = '[{"type":"Resource","resource":{"name":"cpu","target":{"type":"Utilization","averageUtilization":70}}}]' ]
+}
+
+@test "terminatingGateways/HorizontalPodAutoscaler: minReplicas defaults to the replicas of a specific gateway" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.maxReplicas=5' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[0].replicas=3' \
+      . | tee /dev/stderr |
+      yq -s -r '.[0].spec.minReplicas' | tee /dev/stderr)
+  [ "${actual}" = "3" ]
+}
+
+@test "terminatingGateways/HorizontalPodAutoscaler: autoscaling of a specific gateway overrides defaults" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/terminating-gateways-horizontalpodautoscaler.yaml  \
+      --set 'terminatingGateways.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.enabled=true' \
+      --set 'terminatingGateways.defaults.autoscaling.maxReplicas=5' \
+      --set 'terminatingGateways.defaults.autoscaling.targetCPUUtilizationPercentage=70' \
+      --set 'terminatingGateways.gateways[0].name=gateway1' \
+      --set 'terminatingGateways.gateways[1].name=gateway2' \
+      --set 'terminatingGateways.gateways[1].autoscaling.enabled=true' \
+      --set 'terminatingGateways.gateways[1].autoscaling.maxReplicas=10' \
+      --set 'terminatingGateways.gateways[2].name=gateway3' \
+      --set 'terminatingGateways.gateways[2].autoscaling.enabled=false' \
+      . | tee /dev/stderr |
+      yq -s -c '[.[] | select(. != null) | {name: .metadata.name, maxReplicas: .spec.maxReplicas, metrics: .spec.metrics}]' | tee /dev/stderr)
+  [ "${object}" = '[{"name":"release-name-consul-gateway1","maxReplicas":5,"metrics":[{"type":"Resource","resource":{"name":"cpu","target":{"type":"Utilization","averageUtilization":70}}}]},{"name":"release-name-consul-gateway2","maxReplicas":10,"metrics":null}]' ]
+}
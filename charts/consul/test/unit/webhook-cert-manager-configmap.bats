@@ -90,4 +90,61 @@ load _helpers
 
   local actual=$(echo $cfg | jq '.[1].name | contains("controller")')
   [ "${actual}" = "true" ]
-}
\ No newline at end of file
+}
+@test "webhookCertManager/Configmap: no CA secret or external mode by default" {
+  cd `chart_dir`
+  local cfg=$(helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.data["webhook-config.json"]' | tee /dev/stderr)
+
+  local actual=$(echo $cfg | jq '[.[] | has("caSecretName") or has("external")] | any')
+  [ "${actual}" = "false" ]
+}
+
+@test "webhookCertManager/Configmap: can set webhookCertManager.caSecretName" {
+  cd `chart_dir`
+  local cfg=$(helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.caSecretName=webhook-ca' \
+      . | tee /dev/stderr |
+      yq -r '.data["webhook-config.json"]' | tee /dev/stderr)
+
+  local actual=$(echo $cfg | jq -r '.[0].caSecretName')
+  [ "${actual}" = "webhook-ca" ]
+
+  local actual=$(echo $cfg | jq -r '.[1].caSecretName')
+  [ "${actual}" = "webhook-ca" ]
+}
+
+@test "webhookCertManager/Configmap: can set webhookCertManager.externalCertificates" {
+  cd `chart_dir`
+  local cfg=$(helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.externalCertificates=true' \
+      . | tee /dev/stderr |
+      yq -r '.data["webhook-config.json"]' | tee /dev/stderr)
+
+  local actual=$(echo $cfg | jq '.[0].external')
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo $cfg | jq '.[1].external')
+  [ "${actual}" = "true" ]
+}
+
+@test "webhookCertManager/Configmap: fails if both caSecretName and externalCertificates are set" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/webhook-cert-manager-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'webhookCertManager.caSecretName=webhook-ca' \
+      --set 'webhookCertManager.externalCertificates=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "webhookCertManager.caSecretName cannot be set if webhookCertManager.externalCertificates=true" ]]
+}
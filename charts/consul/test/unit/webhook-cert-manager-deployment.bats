@@ -9,6 +9,16 @@ load _helpers
       .
 }
 
+@test "webhookCertManager/Deployment: disabled with global.certManager.enabled=true" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'controller.enabled=true' \
+      --set 'global.certManager.enabled=true' \
+      .
+}
+
 @test "webhookCertManager/Deployment: enabled with controller.enabled=true and connectInject.enabled=false" {
   cd `chart_dir`
   local actual=$(helm template \
@@ -62,3 +72,24 @@ load _helpers
       yq -r '.spec.template.spec.tolerations[0].key' | tee /dev/stderr)
   [ "${actual}" = "value" ]
 }
+
+#--------------------------------------------------------------------
+# probes
+
+@test "webhookCertManager/Deployment: liveness and readiness probes use /healthz and /readyz" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/webhook-cert-manager-deployment.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0]' | tee /dev/stderr)
+
+  local actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/healthz" ]
+  actual=$(echo "$object" | yq -r '.livenessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "8080" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.path' | tee /dev/stderr)
+  [ "${actual}" = "/readyz" ]
+  actual=$(echo "$object" | yq -r '.readinessProbe.httpGet.port' | tee /dev/stderr)
+  [ "${actual}" = "8080" ]
+}
@@ -1,12 +1,16 @@
 package status
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
@@ -19,6 +23,9 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// serverCertVolume is the name of the volume of the server TLS certificate in the server stateful set.
+const serverCertVolume = "consul-server-cert"
+
 type Command struct {
 	*common.BaseCommand
 
@@ -116,6 +123,13 @@ func (c *Command) Run(args []string) int {
 		c.UI.Output(s, terminal.WithSuccessStyle())
 	}
 
+	if s, err := c.checkServerCertificate(namespace); err != nil {
+		c.UI.Output(err.Error(), terminal.WithErrorStyle())
+		return 1
+	} else if s != "" {
+		c.UI.Output(s, terminal.WithSuccessStyle())
+	}
+
 	if s, err := c.checkConsulClients(namespace); err != nil {
 		c.UI.Output(err.Error(), terminal.WithErrorStyle())
 		return 1
@@ -239,6 +253,50 @@ func (c *Command) checkConsulServers(namespace string) (string, error) {
 	return fmt.Sprintf("Consul servers healthy (%d/%d)", readyReplicas, desiredReplicas), nil
 }
 
+// checkServerCertificate reports when the server TLS certificate in the Kubernetes secret mounted by the consul
+// servers was issued and when it expires. Since the servers reload their certificate when the secret is rotated,
+// the issue date is when it was last rotated. It returns an empty string if the servers don't read their
+// certificate from a Kubernetes secret, e.g. if TLS is disabled or the certificates are stored in Vault.
+func (c *Command) checkServerCertificate(namespace string) (string, error) {
+	servers, err := c.kubernetes.AppsV1().StatefulSets(namespace).List(c.Ctx,
+		metav1.ListOptions{LabelSelector: "app=consul,chart=consul-helm,component=server"})
+	if err != nil {
+		return "", err
+	} else if len(servers.Items) != 1 {
+		return "", nil
+	}
+
+	var secretName string
+	for _, volume := range servers.Items[0].Spec.Template.Spec.Volumes {
+		if volume.Name == serverCertVolume && volume.Secret != nil {
+			secretName = volume.Secret.SecretName
+		}
+	}
+	if secretName == "" {
+		return "", nil
+	}
+
+	secret, err := c.kubernetes.CoreV1().Secrets(namespace).Get(c.Ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("couldn't get the server TLS certificate: %s", err)
+	}
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return "", fmt.Errorf("server TLS certificate secret %q has no PEM-encoded %s", secretName, corev1.TLSCertKey)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse the server TLS certificate: %s", err)
+	}
+
+	const timeFormat = "2006/01/02 15:04:05 MST"
+	if time.Now().After(cert.NotAfter) {
+		return "", fmt.Errorf("Server TLS certificate expired at %s", cert.NotAfter.Local().Format(timeFormat))
+	}
+	return fmt.Sprintf("Server TLS certificate rotated at %s, expires at %s",
+		cert.NotBefore.Local().Format(timeFormat), cert.NotAfter.Local().Format(timeFormat)), nil
+}
+
 // checkConsulClients uses the Kubernetes list function to report if the consul clients are healthy.
 func (c *Command) checkConsulClients(namespace string) (string, error) {
 	clients, err := c.kubernetes.AppsV1().DaemonSets(namespace).List(c.Ctx,
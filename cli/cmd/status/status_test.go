@@ -2,14 +2,23 @@ package status
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-k8s/cli/common"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -165,6 +174,88 @@ func TestCheckConsulClients(t *testing.T) {
 	require.Contains(t, err.Error(), fmt.Sprintf("%d/%d Consul clients unhealthy", 1, desired))
 }
 
+// TestCheckServerCertificate creates a server stateful set mounting a server certificate secret and tests
+// the checkServerCertificate function.
+func TestCheckServerCertificate(t *testing.T) {
+	c := getInitializedCommand(t)
+	c.kubernetes = fake.NewSimpleClientset()
+
+	// No stateful set or certificate volume means there is nothing to report.
+	s, err := c.checkServerCertificate("default")
+	require.NoError(t, err)
+	require.Empty(t, s)
+
+	ss := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "consul", "chart": "consul-helm", "component": "server"},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "consul-server-cert",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: "consul-server-cert"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	c.kubernetes.AppsV1().StatefulSets("default").Create(context.Background(), ss, metav1.CreateOptions{})
+
+	// The secret doesn't exist yet.
+	_, err = c.checkServerCertificate("default")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "couldn't get the server TLS certificate")
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "consul-server-cert",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey: testCertificate(t, notBefore, notAfter),
+		},
+	}
+	c.kubernetes.CoreV1().Secrets("default").Create(context.Background(), secret, metav1.CreateOptions{})
+
+	s, err = c.checkServerCertificate("default")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("Server TLS certificate rotated at %s, expires at %s",
+		notBefore.Local().Format("2006/01/02 15:04:05 MST"), notAfter.Local().Format("2006/01/02 15:04:05 MST")), s)
+
+	// An expired certificate is an error.
+	secret.Data[corev1.TLSCertKey] = testCertificate(t, notBefore, notBefore.Add(time.Minute))
+	c.kubernetes.CoreV1().Secrets("default").Update(context.Background(), secret, metav1.UpdateOptions{})
+
+	_, err = c.checkServerCertificate("default")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Server TLS certificate expired at")
+}
+
+// testCertificate returns a PEM-encoded self-signed certificate valid between notBefore and notAfter.
+func testCertificate(t *testing.T, notBefore, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "server.dc1.consul"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
 // getInitializedCommand sets up a command struct for tests.
 func getInitializedCommand(t *testing.T) *Command {
 	t.Helper()
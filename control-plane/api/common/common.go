@@ -2,15 +2,19 @@
 package common
 
 const (
-	ServiceDefaults    string = "servicedefaults"
-	ProxyDefaults      string = "proxydefaults"
-	ServiceResolver    string = "serviceresolver"
-	ServiceRouter      string = "servicerouter"
-	ServiceSplitter    string = "servicesplitter"
-	ServiceIntentions  string = "serviceintentions"
-	ExportedServices   string = "exportedservices"
-	IngressGateway     string = "ingressgateway"
-	TerminatingGateway string = "terminatinggateway"
+	ServiceDefaults          string = "servicedefaults"
+	ProxyDefaults            string = "proxydefaults"
+	ServiceResolver          string = "serviceresolver"
+	ServiceRouter            string = "servicerouter"
+	ServiceSplitter          string = "servicesplitter"
+	ServiceIntentions        string = "serviceintentions"
+	ExportedServices         string = "exportedservices"
+	SamenessGroup            string = "samenessgroup"
+	JWTProvider              string = "jwtprovider"
+	ControlPlaneRequestLimit string = "controlplanerequestlimit"
+	IngressGateway           string = "ingressgateway"
+	TerminatingGateway       string = "terminatinggateway"
+	PeeringConnection        string = "peeringconnection"
 
 	Global                 string = "global"
 	Mesh                   string = "mesh"
@@ -46,6 +46,15 @@ type ConfigEntryResource interface {
 	SyncedCondition() (status corev1.ConditionStatus, reason, message string)
 	// SyncedConditionStatus returns the status of the synced condition.
 	SyncedConditionStatus() corev1.ConditionStatus
+	// SetCondition updates the condition of the given type, e.g. Validated.
+	SetCondition(conditionType string, status corev1.ConditionStatus, reason, message string)
+	// ConditionStatus returns the status of the condition of the given type.
+	ConditionStatus(conditionType string) corev1.ConditionStatus
+	// SetConsulIndex updates the modify index of the config entry in Consul.
+	SetConsulIndex(index uint64)
+	// GetConsulIndex returns the modify index of the config entry in Consul
+	// when the resource was last synced.
+	GetConsulIndex() uint64
 	// ToConsul converts the resource to the corresponding Consul API definition.
 	// Its return type is the generic ConfigEntry but a specific config entry
 	// type should be constructed e.g. ServiceConfigEntry.
@@ -96,4 +105,21 @@ type ConsulMeta struct {
 	// service in the k8s `staging` namespace will be registered into the
 	// `k8s-staging` Consul namespace.
 	Prefix string
+
+	// TLSMinVersion is the minimum TLS version, e.g. "TLSv1_2", that the Envoy
+	// listeners configured by config entries must use. Config entries that
+	// don't set a minimum TLS version default to it.
+	TLSMinVersion string
+	// TLSCipherSuites are the TLS cipher suites that the Envoy listeners
+	// configured by config entries may use. Config entries that don't set
+	// any cipher suites default to them.
+	TLSCipherSuites []string
+}
+
+// TLSDefaulter is implemented by config entries with TLS settings for Envoy
+// listeners, so that they default to the TLS settings of the installation.
+type TLSDefaulter interface {
+	// DefaultTLSFields sets the TLS minimum versions and cipher suites that
+	// aren't set to those in consulMeta.
+	DefaultTLSFields(consulMeta ConsulMeta)
 }
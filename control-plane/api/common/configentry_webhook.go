@@ -19,6 +19,16 @@ type ConfigEntryLister interface {
 	List(ctx context.Context) ([]ConfigEntryResource, error)
 }
 
+// ConsulStateValidator is optionally implemented by CRD-specific webhooks
+// that validate config entries against the config entries in Consul, so that
+// resources that Consul would reject are rejected when they're applied
+// rather than when they're synced.
+type ConsulStateValidator interface {
+	// ValidateConsulState returns an error if Consul would reject the
+	// config entry.
+	ValidateConsulState(ctx context.Context, req admission.Request, cfgEntry ConfigEntryResource) error
+}
+
 // ValidateConfigEntry validates cfgEntry. It is a generic method that
 // can be used by all CRD-specific validators.
 // Callers should pass themselves as validator and kind should be the custom
@@ -61,6 +71,11 @@ func ValidateConfigEntry(
 	if err := cfgEntry.Validate(consulMeta); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	if validator, ok := configEntryLister.(ConsulStateValidator); ok {
+		if err := validator.ValidateConsulState(ctx, req, cfgEntry); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
 	return admission.Patched(fmt.Sprintf("valid %s request", cfgEntry.KubeKind()), defaultingPatches...)
 }
 
@@ -72,6 +87,9 @@ func DefaultingPatches(cfgEntry ConfigEntryResource, consulMeta ConsulMeta) ([]j
 		return nil, fmt.Errorf("marshalling input: %s", err)
 	}
 	cfgEntry.DefaultNamespaceFields(consulMeta)
+	if defaulter, ok := cfgEntry.(TLSDefaulter); ok {
+		defaulter.DefaultTLSFields(consulMeta)
+	}
 	afterDefaulting, err := json.Marshal(cfgEntry)
 	if err != nil {
 		return nil, fmt.Errorf("marshalling after defaulting: %s", err)
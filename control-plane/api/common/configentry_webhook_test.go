@@ -318,6 +318,18 @@ func (in *mockConfigEntry) SyncedConditionStatus() corev1.ConditionStatus {
 	return corev1.ConditionTrue
 }
 
+func (in *mockConfigEntry) SetCondition(_ string, _ corev1.ConditionStatus, _ string, _ string) {}
+
+func (in *mockConfigEntry) ConditionStatus(_ string) corev1.ConditionStatus {
+	return corev1.ConditionTrue
+}
+
+func (in *mockConfigEntry) SetConsulIndex(_ uint64) {}
+
+func (in *mockConfigEntry) GetConsulIndex() uint64 {
+	return 0
+}
+
 func (in *mockConfigEntry) ToConsul(string) capi.ConfigEntry {
 	return &capi.ServiceConfigEntry{}
 }
@@ -0,0 +1,213 @@
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	capi "github.com/hashicorp/consul/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// consulState looks up the config entries that apply to a resource in both
+// Kubernetes and Consul so that the webhooks can reject resources that
+// Consul would reject once they're synced. Resources in Kubernetes take
+// precedence over the config entries in Consul since the controller makes
+// Consul match them, which allows related resources to be applied together.
+type consulState struct {
+	client       client.Client
+	consulClient *capi.Client
+	consulMeta   common.ConsulMeta
+}
+
+// l7Protocols are the protocols that support routing and splitting.
+var l7Protocols = map[string]bool{
+	"http":  true,
+	"http2": true,
+	"grpc":  true,
+}
+
+// validateL7Protocol returns an error if the protocol of the service isn't
+// an L7 protocol, which the config entry kind requires.
+func (s *consulState) validateL7Protocol(ctx context.Context, kind, service, kubeNS string) error {
+	protocol, source, err := s.serviceProtocol(ctx, service, kubeNS)
+	if err != nil {
+		return err
+	}
+	if l7Protocols[protocol] {
+		return nil
+	}
+	return fmt.Errorf("%s for service %q requires its protocol to be one of \"http\", \"http2\" or \"grpc\", but it is %q (%s): "+
+		"set spec.protocol of the service's ServiceDefaults resource, or the protocol of the global ProxyDefaults resource, to one of these protocols",
+		kind, service, protocol, source)
+}
+
+// validateNoL7ConfigEntries returns an error if the service has a service
+// router or splitter, which require an L7 protocol.
+func (s *consulState) validateNoL7ConfigEntries(ctx context.Context, service, kubeNS string, protocol string) error {
+	consulNS := s.consulNamespace(kubeNS)
+
+	var routers ServiceRouterList
+	if err := s.client.List(ctx, &routers); err != nil {
+		return err
+	}
+	for _, router := range routers.Items {
+		if router.KubernetesName() == service && s.consulNamespace(router.Namespace) == consulNS && router.GetDeletionTimestamp().IsZero() {
+			return l7ConfigEntryErr(service, protocol, fmt.Sprintf("ServiceRouter resource %s/%s", router.Namespace, router.Name))
+		}
+	}
+	var splitters ServiceSplitterList
+	if err := s.client.List(ctx, &splitters); err != nil {
+		return err
+	}
+	for _, splitter := range splitters.Items {
+		if splitter.KubernetesName() == service && s.consulNamespace(splitter.Namespace) == consulNS && splitter.GetDeletionTimestamp().IsZero() {
+			return l7ConfigEntryErr(service, protocol, fmt.Sprintf("ServiceSplitter resource %s/%s", splitter.Namespace, splitter.Name))
+		}
+	}
+
+	for _, kind := range []string{capi.ServiceRouter, capi.ServiceSplitter} {
+		entry, err := s.getConfigEntry(kind, service, consulNS)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			return l7ConfigEntryErr(service, protocol, fmt.Sprintf("%s config entry in Consul", kind))
+		}
+	}
+	return nil
+}
+
+// validateNotUnmanaged returns an error if a config entry with the same kind
+// and name exists in Consul without being managed by Kubernetes, since the
+// controller won't overwrite it unless it's migrated.
+func (s *consulState) validateNotUnmanaged(cfgEntry common.ConfigEntryResource, kubeNS string) error {
+	if cfgEntry.GetObjectMeta().Annotations[common.MigrateEntryKey] == common.MigrateEntryTrue {
+		return nil
+	}
+	entry, err := s.getConfigEntry(cfgEntry.ConsulKind(), cfgEntry.ConsulName(), s.configEntryNamespace(cfgEntry, kubeNS))
+	if err != nil || entry == nil {
+		return err
+	}
+	if entry.GetMeta()[common.SourceKey] == common.SourceValue {
+		return nil
+	}
+	return fmt.Errorf("%s config entry %q already exists in Consul and is not managed by Kubernetes: "+
+		"delete it from Consul, or set the %s annotation to %q to migrate it if it matches this resource",
+		cfgEntry.ConsulKind(), cfgEntry.ConsulName(), common.MigrateEntryKey, common.MigrateEntryTrue)
+}
+
+// serviceProtocol returns the protocol of the service and where it is
+// configured. Like in Consul, the protocol of the service defaults takes
+// precedence over the protocol of the global proxy defaults, and the default
+// protocol is tcp.
+func (s *consulState) serviceProtocol(ctx context.Context, service, kubeNS string) (string, string, error) {
+	consulNS := s.consulNamespace(kubeNS)
+
+	var svcDefaultsList ServiceDefaultsList
+	if err := s.client.List(ctx, &svcDefaultsList); err != nil {
+		return "", "", err
+	}
+	for _, svcDefaults := range svcDefaultsList.Items {
+		if svcDefaults.KubernetesName() == service && s.consulNamespace(svcDefaults.Namespace) == consulNS && svcDefaults.Spec.Protocol != "" {
+			return svcDefaults.Spec.Protocol, fmt.Sprintf("from ServiceDefaults resource %s/%s", svcDefaults.Namespace, svcDefaults.Name), nil
+		}
+	}
+	entry, err := s.getConfigEntry(capi.ServiceDefaults, service, consulNS)
+	if err != nil {
+		return "", "", err
+	}
+	if svcDefaults, ok := entry.(*capi.ServiceConfigEntry); ok && svcDefaults.Protocol != "" {
+		return svcDefaults.Protocol, "from the service-defaults config entry in Consul", nil
+	}
+
+	protocol, source, err := s.globalProtocol(ctx)
+	if err != nil || protocol != "" {
+		return protocol, source, err
+	}
+	return "tcp", "the default protocol", nil
+}
+
+// globalProtocol returns the protocol of the global proxy defaults and where
+// it is configured, if it's set.
+func (s *consulState) globalProtocol(ctx context.Context) (string, string, error) {
+	var proxyDefaultsList ProxyDefaultsList
+	if err := s.client.List(ctx, &proxyDefaultsList); err != nil {
+		return "", "", err
+	}
+	for _, proxyDefaults := range proxyDefaultsList.Items {
+		if proxyDefaults.Name != common.Global {
+			continue
+		}
+		if protocol, ok := proxyDefaults.convertConfig()["protocol"].(string); ok && protocol != "" {
+			return protocol, fmt.Sprintf("from ProxyDefaults resource %s/%s", proxyDefaults.Namespace, proxyDefaults.Name), nil
+		}
+		// The config entry in Consul will be replaced by the resource.
+		return "", "", nil
+	}
+
+	entry, err := s.getConfigEntry(capi.ProxyDefaults, capi.ProxyConfigGlobal, s.globalNamespace())
+	if err != nil {
+		return "", "", err
+	}
+	if proxyDefaults, ok := entry.(*capi.ProxyConfigEntry); ok {
+		if protocol, ok := proxyDefaults.Config["protocol"].(string); ok && protocol != "" {
+			return protocol, "from the proxy-defaults config entry in Consul", nil
+		}
+	}
+	return "", "", nil
+}
+
+// getConfigEntry returns the config entry from Consul, or nil if it doesn't
+// exist.
+func (s *consulState) getConfigEntry(kind, name, consulNS string) (capi.ConfigEntry, error) {
+	opts := &capi.QueryOptions{Namespace: consulNS}
+	if s.consulMeta.PartitionsEnabled {
+		opts.Partition = s.consulMeta.Partition
+	}
+	entry, _, err := s.consulClient.ConfigEntries().Get(kind, name, opts)
+	var statusErr capi.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting %s config entry %q from Consul: %w", kind, name, err)
+	}
+	return entry, nil
+}
+
+// configEntryNamespace returns the Consul namespace of the config entry of
+// the resource in the Kubernetes namespace, like the controller does when it
+// syncs it.
+func (s *consulState) configEntryNamespace(cfgEntry common.ConfigEntryResource, kubeNS string) string {
+	if ns := cfgEntry.ToConsul("").GetNamespace(); ns != "" {
+		return ns
+	}
+	if cfgEntry.ConsulGlobalResource() {
+		return s.globalNamespace()
+	}
+	return s.consulNamespace(kubeNS)
+}
+
+// consulNamespace returns the Consul namespace that the resources of the
+// Kubernetes namespace are synced to.
+func (s *consulState) consulNamespace(kubeNS string) string {
+	return namespaces.ConsulNamespace(kubeNS, s.consulMeta.NamespacesEnabled, s.consulMeta.DestinationNamespace, s.consulMeta.Mirroring, s.consulMeta.Prefix)
+}
+
+// globalNamespace returns the Consul namespace of global config entries.
+func (s *consulState) globalNamespace() string {
+	if s.consulMeta.NamespacesEnabled {
+		return common.DefaultConsulNamespace
+	}
+	return ""
+}
+
+func l7ConfigEntryErr(service, protocol, source string) error {
+	return fmt.Errorf("service %q cannot have protocol %q since it has a %s, which requires its protocol to be one of \"http\", \"http2\" or \"grpc\": "+
+		"delete it first or use one of these protocols",
+		service, protocol, source)
+}
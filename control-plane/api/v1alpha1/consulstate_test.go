@@ -0,0 +1,302 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const unmanagedSplitterErr = `service-splitter config entry "foo" already exists in Consul and is not managed by Kubernetes: ` +
+	`delete it from Consul, or set the consul.hashicorp.com/migrate-entry annotation to "true" to migrate it if it matches this resource`
+
+func TestHandle_ServiceRouter_ConsulState(t *testing.T) {
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		consulEntries     []capi.ConfigEntry
+		disabled          bool
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"default protocol": {
+			expAllow: false,
+			expErrMessage: `ServiceRouter for service "foo" requires its protocol to be one of "http", "http2" or "grpc", but it is "tcp" (the default protocol): ` +
+				`set spec.protocol of the service's ServiceDefaults resource, or the protocol of the global ProxyDefaults resource, to one of these protocols`,
+		},
+		"default protocol with validation disabled": {
+			disabled: true,
+			expAllow: true,
+		},
+		"protocol from ServiceDefaults resource": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "http"},
+			}},
+			consulEntries: []capi.ConfigEntry{&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "tcp"}},
+			expAllow:      true,
+		},
+		"protocol from ServiceDefaults resource that isn't L7": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+			}},
+			consulEntries: []capi.ConfigEntry{&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "http"}},
+			expAllow:      false,
+			expErrMessage: `ServiceRouter for service "foo" requires its protocol to be one of "http", "http2" or "grpc", but it is "tcp" (from ServiceDefaults resource default/foo): ` +
+				`set spec.protocol of the service's ServiceDefaults resource, or the protocol of the global ProxyDefaults resource, to one of these protocols`,
+		},
+		"protocol from service-defaults config entry": {
+			consulEntries: []capi.ConfigEntry{&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "grpc"}},
+			expAllow:      true,
+		},
+		"protocol from ProxyDefaults resource": {
+			existingResources: []runtime.Object{&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+				Spec:       ProxyDefaultsSpec{Config: json.RawMessage(`{"protocol": "http2"}`)},
+			}},
+			expAllow: true,
+		},
+		"protocol from proxy-defaults config entry": {
+			consulEntries: []capi.ConfigEntry{&capi.ProxyConfigEntry{
+				Kind:   capi.ProxyDefaults,
+				Name:   capi.ProxyConfigGlobal,
+				Config: map[string]interface{}{"protocol": "http"},
+			}},
+			expAllow: true,
+		},
+		"unmanaged config entry": {
+			consulEntries: []capi.ConfigEntry{
+				&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "http"},
+				&capi.ServiceRouterConfigEntry{Kind: capi.ServiceRouter, Name: "foo"},
+			},
+			expAllow: false,
+			expErrMessage: `service-router config entry "foo" already exists in Consul and is not managed by Kubernetes: ` +
+				`delete it from Consul, or set the consul.hashicorp.com/migrate-entry annotation to "true" to migrate it if it matches this resource`,
+		},
+		"managed config entry": {
+			consulEntries: []capi.ConfigEntry{
+				&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "http"},
+				&capi.ServiceRouterConfigEntry{Kind: capi.ServiceRouter, Name: "foo", Meta: map[string]string{common.SourceKey: common.SourceValue}},
+			},
+			expAllow: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			svcRouter := &ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			}
+			client, decoder := consulStateTestClient(t, c.existingResources...)
+			validator := &ServiceRouterWebhook{
+				Client:                      client,
+				ConsulClient:                consulStateTestServer(t, c.consulEntries...),
+				Logger:                      logrtest.TestLogger{T: t},
+				decoder:                     decoder,
+				EnableConsulStateValidation: !c.disabled,
+			}
+			response := validator.Handle(context.Background(), consulStateTestRequest(t, svcRouter))
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
+
+func TestHandle_ServiceSplitter_ConsulState(t *testing.T) {
+	cases := map[string]struct {
+		annotations   map[string]string
+		expAllow      bool
+		expErrMessage string
+	}{
+		"unmanaged config entry": {
+			expAllow:      false,
+			expErrMessage: unmanagedSplitterErr,
+		},
+		"unmanaged config entry being migrated": {
+			annotations: map[string]string{common.MigrateEntryKey: common.MigrateEntryTrue},
+			expAllow:    true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			svcSplitter := &ServiceSplitter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: c.annotations},
+				Spec:       ServiceSplitterSpec{Splits: []ServiceSplit{{Weight: 100}}},
+			}
+			client, decoder := consulStateTestClient(t)
+			validator := &ServiceSplitterWebhook{
+				Client: client,
+				ConsulClient: consulStateTestServer(t,
+					&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", Protocol: "http"},
+					&capi.ServiceSplitterConfigEntry{Kind: capi.ServiceSplitter, Name: "foo", Splits: []capi.ServiceSplit{{Weight: 100}}}),
+				Logger:                      logrtest.TestLogger{T: t},
+				decoder:                     decoder,
+				EnableConsulStateValidation: true,
+			}
+			response := validator.Handle(context.Background(), consulStateTestRequest(t, svcSplitter))
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
+
+func TestHandle_ServiceDefaults_ConsulState(t *testing.T) {
+	cases := map[string]struct {
+		protocol          string
+		existingResources []runtime.Object
+		consulEntries     []capi.ConfigEntry
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"no routers or splitters": {
+			protocol: "tcp",
+			expAllow: true,
+		},
+		"ServiceRouter resource": {
+			protocol: "tcp",
+			existingResources: []runtime.Object{&ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			}},
+			expAllow: false,
+			expErrMessage: `service "foo" cannot have protocol "tcp" since it has a ServiceRouter resource default/foo, ` +
+				`which requires its protocol to be one of "http", "http2" or "grpc": delete it first or use one of these protocols`,
+		},
+		"ServiceRouter resource with L7 protocol": {
+			protocol: "http",
+			existingResources: []runtime.Object{&ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			}},
+			expAllow: true,
+		},
+		"service-splitter config entry with default protocol": {
+			consulEntries: []capi.ConfigEntry{&capi.ServiceSplitterConfigEntry{Kind: capi.ServiceSplitter, Name: "foo"}},
+			expAllow:      false,
+			expErrMessage: `service "foo" cannot have protocol "tcp" since it has a service-splitter config entry in Consul, ` +
+				`which requires its protocol to be one of "http", "http2" or "grpc": delete it first or use one of these protocols`,
+		},
+		"service-splitter config entry with protocol from proxy-defaults config entry": {
+			consulEntries: []capi.ConfigEntry{
+				&capi.ServiceSplitterConfigEntry{Kind: capi.ServiceSplitter, Name: "foo"},
+				&capi.ProxyConfigEntry{
+					Kind:   capi.ProxyDefaults,
+					Name:   capi.ProxyConfigGlobal,
+					Config: map[string]interface{}{"protocol": "grpc"},
+				},
+			},
+			expAllow: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			svcDefaults := &ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: c.protocol},
+			}
+			client, decoder := consulStateTestClient(t, c.existingResources...)
+			validator := &ServiceDefaultsWebhook{
+				Client:                      client,
+				ConsulClient:                consulStateTestServer(t, c.consulEntries...),
+				Logger:                      logrtest.TestLogger{T: t},
+				decoder:                     decoder,
+				EnableConsulStateValidation: true,
+			}
+			response := validator.Handle(context.Background(), consulStateTestRequest(t, svcDefaults))
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
+
+func TestHandle_ServiceIntentions_ConsulState(t *testing.T) {
+	svcIntentions := &ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{Name: "wildcard", Namespace: "default"},
+		Spec: ServiceIntentionsSpec{
+			Destination: Destination{Name: "*"},
+			Sources:     SourceIntentions{{Name: "bar", Action: "allow"}},
+		},
+	}
+	client, decoder := consulStateTestClient(t)
+	validator := &ServiceIntentionsWebhook{
+		Client: client,
+		ConsulClient: consulStateTestServer(t, &capi.ServiceIntentionsConfigEntry{
+			Kind:    capi.ServiceIntentions,
+			Name:    "*",
+			Sources: []*capi.SourceIntention{{Name: "baz", Action: capi.IntentionActionDeny}},
+		}),
+		Logger:                      logrtest.TestLogger{T: t},
+		decoder:                     decoder,
+		EnableConsulStateValidation: true,
+	}
+	response := validator.Handle(context.Background(), consulStateTestRequest(t, svcIntentions))
+	require.False(t, response.Allowed)
+	require.Equal(t, `service-intentions config entry "*" already exists in Consul and is not managed by Kubernetes: `+
+		`delete it from Consul, or set the consul.hashicorp.com/migrate-entry annotation to "true" to migrate it if it matches this resource`,
+		response.AdmissionResponse.Result.Message)
+}
+
+// consulStateTestServer returns a client of a fake Consul server that only
+// serves the config entries.
+func consulStateTestServer(t *testing.T, entries ...capi.ConfigEntry) *capi.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, entry := range entries {
+			if r.URL.Path == fmt.Sprintf("/v1/config/%s/%s", entry.GetKind(), entry.GetName()) {
+				require.NoError(t, json.NewEncoder(w).Encode(entry))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	consulClient, err := capi.NewClient(&capi.Config{Address: srv.URL})
+	require.NoError(t, err)
+	return consulClient
+}
+
+func consulStateTestClient(t *testing.T, existingResources ...runtime.Object) (client.Client, *admission.Decoder) {
+	t.Helper()
+	s := runtime.NewScheme()
+	s.AddKnownTypes(GroupVersion,
+		&ServiceDefaults{}, &ServiceDefaultsList{},
+		&ProxyDefaults{}, &ProxyDefaultsList{},
+		&ServiceRouter{}, &ServiceRouterList{},
+		&ServiceSplitter{}, &ServiceSplitterList{},
+		&ServiceIntentions{}, &ServiceIntentionsList{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(existingResources...).Build(), decoder
+}
+
+func consulStateTestRequest(t *testing.T, obj client.Object) admission.Request {
+	t.Helper()
+	marshalledRequestObject, err := json.Marshal(obj)
+	require.NoError(t, err)
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Operation: admissionv1.Create,
+			Object: runtime.RawExtension{
+				Raw: marshalledRequestObject,
+			},
+		},
+	}
+}
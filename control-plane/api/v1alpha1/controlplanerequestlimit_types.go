@@ -0,0 +1,303 @@
+package v1alpha1
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const ControlPlaneRequestLimitKubeKind = "controlplanerequestlimit"
+
+// requestLimitModes are the modes of the control plane request limits.
+var requestLimitModes = []string{"permissive", "enforcing", "disabled"}
+
+func init() {
+	SchemeBuilder.Register(&ControlPlaneRequestLimit{}, &ControlPlaneRequestLimitList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ControlPlaneRequestLimit is the Schema for the controlplanerequestlimits API
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="control-plane-request-limit"
+type ControlPlaneRequestLimit struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ControlPlaneRequestLimitSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ControlPlaneRequestLimitList contains a list of ControlPlaneRequestLimit.
+type ControlPlaneRequestLimitList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ControlPlaneRequestLimit `json:"items"`
+}
+
+// ControlPlaneRequestLimitSpec defines the desired state of ControlPlaneRequestLimit.
+// The read and write rates are the maximum number of requests per second
+// that each client IP address can make to the servers.
+type ControlPlaneRequestLimitSpec struct {
+	// Mode is the mode of the request limits. It can be "permissive" to only
+	// log the requests that exceed the limits, "enforcing" to reject them or
+	// "disabled".
+	Mode string `json:"mode,omitempty"`
+	// ReadWriteRatesConfig sets the rate limits of all operations. The
+	// limits of the operation categories below take precedence over them.
+	ReadWriteRatesConfig `json:",inline"`
+	// ACL sets the rate limits of the ACL operations.
+	ACL *ReadWriteRatesConfig `json:"acl,omitempty"`
+	// Catalog sets the rate limits of the catalog operations.
+	Catalog *ReadWriteRatesConfig `json:"catalog,omitempty"`
+	// ConfigEntry sets the rate limits of the config entry operations.
+	ConfigEntry *ReadWriteRatesConfig `json:"configEntry,omitempty"`
+	// ConnectCA sets the rate limits of the Connect CA operations.
+	ConnectCA *ReadWriteRatesConfig `json:"connectCA,omitempty"`
+	// Coordinate sets the rate limits of the coordinate operations.
+	Coordinate *ReadWriteRatesConfig `json:"coordinate,omitempty"`
+	// DiscoveryChain sets the rate limits of the discovery chain operations.
+	DiscoveryChain *ReadWriteRatesConfig `json:"discoveryChain,omitempty"`
+	// ServerDiscovery sets the rate limits of the server discovery operations.
+	ServerDiscovery *ReadWriteRatesConfig `json:"serverDiscovery,omitempty"`
+	// Health sets the rate limits of the health operations.
+	Health *ReadWriteRatesConfig `json:"health,omitempty"`
+	// Intention sets the rate limits of the intention operations.
+	Intention *ReadWriteRatesConfig `json:"intention,omitempty"`
+	// KV sets the rate limits of the KV operations.
+	KV *ReadWriteRatesConfig `json:"kv,omitempty"`
+	// Tenancy sets the rate limits of the tenancy operations.
+	Tenancy *ReadWriteRatesConfig `json:"tenancy,omitempty"`
+	// PreparedQuery sets the rate limits of the prepared query operations.
+	PreparedQuery *ReadWriteRatesConfig `json:"preparedQuery,omitempty"`
+	// Session sets the rate limits of the session operations.
+	Session *ReadWriteRatesConfig `json:"session,omitempty"`
+	// Txn sets the rate limits of the transaction operations.
+	Txn *ReadWriteRatesConfig `json:"txn,omitempty"`
+	// AutoConfig sets the rate limits of the auto config operations.
+	AutoConfig *ReadWriteRatesConfig `json:"autoConfig,omitempty"`
+	// FederationState sets the rate limits of the federation state operations.
+	FederationState *ReadWriteRatesConfig `json:"federationState,omitempty"`
+	// Internal sets the rate limits of the internal operations.
+	Internal *ReadWriteRatesConfig `json:"internal,omitempty"`
+	// PeerStream sets the rate limits of the peer stream operations.
+	PeerStream *ReadWriteRatesConfig `json:"peerStream,omitempty"`
+	// Peering sets the rate limits of the peering operations.
+	Peering *ReadWriteRatesConfig `json:"peering,omitempty"`
+	// DataPlane sets the rate limits of the dataplane operations.
+	DataPlane *ReadWriteRatesConfig `json:"dataPlane,omitempty"`
+	// DNS sets the rate limits of the DNS operations.
+	DNS *ReadWriteRatesConfig `json:"dns,omitempty"`
+	// Subscribe sets the rate limits of the subscribe operations.
+	Subscribe *ReadWriteRatesConfig `json:"subscribe,omitempty"`
+	// Resource sets the rate limits of the resource operations.
+	Resource *ReadWriteRatesConfig `json:"resource,omitempty"`
+}
+
+// ReadWriteRatesConfig sets the read and write rate limits in
+// requests per second.
+type ReadWriteRatesConfig struct {
+	// ReadRate is the maximum number of read requests per second.
+	ReadRate float64 `json:"readRate,omitempty"`
+	// WriteRate is the maximum number of write requests per second.
+	WriteRate float64 `json:"writeRate,omitempty"`
+}
+
+func (in *ControlPlaneRequestLimit) GetObjectMeta() metav1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+func (in *ControlPlaneRequestLimit) AddFinalizer(name string) {
+	in.ObjectMeta.Finalizers = append(in.Finalizers(), name)
+}
+
+func (in *ControlPlaneRequestLimit) RemoveFinalizer(name string) {
+	var newFinalizers []string
+	for _, oldF := range in.Finalizers() {
+		if oldF != name {
+			newFinalizers = append(newFinalizers, oldF)
+		}
+	}
+	in.ObjectMeta.Finalizers = newFinalizers
+}
+
+func (in *ControlPlaneRequestLimit) Finalizers() []string {
+	return in.ObjectMeta.Finalizers
+}
+
+func (in *ControlPlaneRequestLimit) ConsulKind() string {
+	return capi.RateLimitIPConfig
+}
+
+func (in *ControlPlaneRequestLimit) ConsulGlobalResource() bool {
+	return true
+}
+
+func (in *ControlPlaneRequestLimit) ConsulMirroringNS() string {
+	return common.DefaultConsulNamespace
+}
+
+func (in *ControlPlaneRequestLimit) KubeKind() string {
+	return ControlPlaneRequestLimitKubeKind
+}
+
+func (in *ControlPlaneRequestLimit) ConsulName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *ControlPlaneRequestLimit) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *ControlPlaneRequestLimit) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
+	in.Status.setCondition(ConditionSynced, status, reason, message)
+}
+
+func (in *ControlPlaneRequestLimit) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *ControlPlaneRequestLimit) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *ControlPlaneRequestLimit) SyncedConditionStatus() corev1.ConditionStatus {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown
+	}
+	return cond.Status
+}
+
+func (in *ControlPlaneRequestLimit) ToConsul(datacenter string) capi.ConfigEntry {
+	return &capi.RateLimitIPConfigEntry{
+		Kind:            in.ConsulKind(),
+		Name:            in.ConsulName(),
+		Mode:            in.Spec.Mode,
+		ReadRate:        in.Spec.ReadRate,
+		WriteRate:       in.Spec.WriteRate,
+		ACL:             in.Spec.ACL.toConsul(),
+		Catalog:         in.Spec.Catalog.toConsul(),
+		ConfigEntry:     in.Spec.ConfigEntry.toConsul(),
+		ConnectCA:       in.Spec.ConnectCA.toConsul(),
+		Coordinate:      in.Spec.Coordinate.toConsul(),
+		DiscoveryChain:  in.Spec.DiscoveryChain.toConsul(),
+		ServerDiscovery: in.Spec.ServerDiscovery.toConsul(),
+		Health:          in.Spec.Health.toConsul(),
+		Intention:       in.Spec.Intention.toConsul(),
+		KV:              in.Spec.KV.toConsul(),
+		Tenancy:         in.Spec.Tenancy.toConsul(),
+		PreparedQuery:   in.Spec.PreparedQuery.toConsul(),
+		Session:         in.Spec.Session.toConsul(),
+		Txn:             in.Spec.Txn.toConsul(),
+		AutoConfig:      in.Spec.AutoConfig.toConsul(),
+		FederationState: in.Spec.FederationState.toConsul(),
+		Internal:        in.Spec.Internal.toConsul(),
+		PeerStream:      in.Spec.PeerStream.toConsul(),
+		Peering:         in.Spec.Peering.toConsul(),
+		DataPlane:       in.Spec.DataPlane.toConsul(),
+		DNS:             in.Spec.DNS.toConsul(),
+		Subscribe:       in.Spec.Subscribe.toConsul(),
+		Resource:        in.Spec.Resource.toConsul(),
+		Meta:            meta(datacenter),
+	}
+}
+
+func (in *ControlPlaneRequestLimit) MatchesConsul(candidate capi.ConfigEntry) bool {
+	configEntry, ok := candidate.(*capi.RateLimitIPConfigEntry)
+	if !ok {
+		return false
+	}
+	// No datacenter is passed to ToConsul as we ignore the Meta field when checking for equality.
+	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.RateLimitIPConfigEntry{}, "Partition", "Namespace", "Meta", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
+}
+
+func (in *ControlPlaneRequestLimit) Validate(_ common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if in.Spec.Mode == "" {
+		errs = append(errs, field.Required(path.Child("mode"), notInSliceMessage(requestLimitModes)))
+	} else if !sliceContains(requestLimitModes, in.Spec.Mode) {
+		errs = append(errs, field.Invalid(path.Child("mode"), in.Spec.Mode, notInSliceMessage(requestLimitModes)))
+	}
+	errs = append(errs, in.Spec.ReadWriteRatesConfig.validate(path)...)
+	for _, category := range []struct {
+		name  string
+		rates *ReadWriteRatesConfig
+	}{
+		{"acl", in.Spec.ACL},
+		{"catalog", in.Spec.Catalog},
+		{"configEntry", in.Spec.ConfigEntry},
+		{"connectCA", in.Spec.ConnectCA},
+		{"coordinate", in.Spec.Coordinate},
+		{"discoveryChain", in.Spec.DiscoveryChain},
+		{"serverDiscovery", in.Spec.ServerDiscovery},
+		{"health", in.Spec.Health},
+		{"intention", in.Spec.Intention},
+		{"kv", in.Spec.KV},
+		{"tenancy", in.Spec.Tenancy},
+		{"preparedQuery", in.Spec.PreparedQuery},
+		{"session", in.Spec.Session},
+		{"txn", in.Spec.Txn},
+		{"autoConfig", in.Spec.AutoConfig},
+		{"federationState", in.Spec.FederationState},
+		{"internal", in.Spec.Internal},
+		{"peerStream", in.Spec.PeerStream},
+		{"peering", in.Spec.Peering},
+		{"dataPlane", in.Spec.DataPlane},
+		{"dns", in.Spec.DNS},
+		{"subscribe", in.Spec.Subscribe},
+		{"resource", in.Spec.Resource},
+	} {
+		if category.rates != nil {
+			errs = append(errs, category.rates.validate(path.Child(category.name))...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: ControlPlaneRequestLimitKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in *ControlPlaneRequestLimit) DefaultNamespaceFields(_ common.ConsulMeta) {
+}
+
+func (in *ReadWriteRatesConfig) toConsul() *capi.ReadWriteRatesConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.ReadWriteRatesConfig{
+		ReadRate:  in.ReadRate,
+		WriteRate: in.WriteRate,
+	}
+}
+
+func (in *ReadWriteRatesConfig) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.ReadRate < 0 {
+		errs = append(errs, field.Invalid(path.Child("readRate"), in.ReadRate, "must not be negative"))
+	}
+	if in.WriteRate < 0 {
+		errs = append(errs, field.Invalid(path.Child("writeRate"), in.WriteRate, "must not be negative"))
+	}
+	return errs
+}
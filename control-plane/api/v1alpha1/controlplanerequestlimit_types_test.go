@@ -0,0 +1,335 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestControlPlaneRequestLimit_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    ControlPlaneRequestLimit
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.RateLimitIPConfigEntry{
+				Kind:        capi.RateLimitIPConfig,
+				Name:        "name",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ControlPlaneRequestLimitSpec{
+					Mode: "enforcing",
+					ReadWriteRatesConfig: ReadWriteRatesConfig{
+						ReadRate:  100,
+						WriteRate: 50,
+					},
+					ACL:             &ReadWriteRatesConfig{ReadRate: 1, WriteRate: 2},
+					Catalog:         &ReadWriteRatesConfig{ReadRate: 3, WriteRate: 4},
+					ConfigEntry:     &ReadWriteRatesConfig{ReadRate: 5, WriteRate: 6},
+					ConnectCA:       &ReadWriteRatesConfig{ReadRate: 7, WriteRate: 8},
+					Coordinate:      &ReadWriteRatesConfig{ReadRate: 9, WriteRate: 10},
+					DiscoveryChain:  &ReadWriteRatesConfig{ReadRate: 11, WriteRate: 12},
+					ServerDiscovery: &ReadWriteRatesConfig{ReadRate: 13, WriteRate: 14},
+					Health:          &ReadWriteRatesConfig{ReadRate: 15, WriteRate: 16},
+					Intention:       &ReadWriteRatesConfig{ReadRate: 17, WriteRate: 18},
+					KV:              &ReadWriteRatesConfig{ReadRate: 19, WriteRate: 20},
+					Tenancy:         &ReadWriteRatesConfig{ReadRate: 21, WriteRate: 22},
+					PreparedQuery:   &ReadWriteRatesConfig{ReadRate: 23, WriteRate: 24},
+					Session:         &ReadWriteRatesConfig{ReadRate: 25, WriteRate: 26},
+					Txn:             &ReadWriteRatesConfig{ReadRate: 27, WriteRate: 28},
+					AutoConfig:      &ReadWriteRatesConfig{ReadRate: 29, WriteRate: 30},
+					FederationState: &ReadWriteRatesConfig{ReadRate: 31, WriteRate: 32},
+					Internal:        &ReadWriteRatesConfig{ReadRate: 33, WriteRate: 34},
+					PeerStream:      &ReadWriteRatesConfig{ReadRate: 35, WriteRate: 36},
+					Peering:         &ReadWriteRatesConfig{ReadRate: 37, WriteRate: 38},
+					DataPlane:       &ReadWriteRatesConfig{ReadRate: 39, WriteRate: 40},
+					DNS:             &ReadWriteRatesConfig{ReadRate: 41, WriteRate: 42},
+					Subscribe:       &ReadWriteRatesConfig{ReadRate: 43, WriteRate: 44},
+					Resource:        &ReadWriteRatesConfig{ReadRate: 45, WriteRate: 46},
+				},
+			},
+			Theirs: &capi.RateLimitIPConfigEntry{
+				Kind:            capi.RateLimitIPConfig,
+				Name:            "name",
+				Partition:       "default",
+				Namespace:       "default",
+				Mode:            "enforcing",
+				ReadRate:        100,
+				WriteRate:       50,
+				ACL:             &capi.ReadWriteRatesConfig{ReadRate: 1, WriteRate: 2},
+				Catalog:         &capi.ReadWriteRatesConfig{ReadRate: 3, WriteRate: 4},
+				ConfigEntry:     &capi.ReadWriteRatesConfig{ReadRate: 5, WriteRate: 6},
+				ConnectCA:       &capi.ReadWriteRatesConfig{ReadRate: 7, WriteRate: 8},
+				Coordinate:      &capi.ReadWriteRatesConfig{ReadRate: 9, WriteRate: 10},
+				DiscoveryChain:  &capi.ReadWriteRatesConfig{ReadRate: 11, WriteRate: 12},
+				ServerDiscovery: &capi.ReadWriteRatesConfig{ReadRate: 13, WriteRate: 14},
+				Health:          &capi.ReadWriteRatesConfig{ReadRate: 15, WriteRate: 16},
+				Intention:       &capi.ReadWriteRatesConfig{ReadRate: 17, WriteRate: 18},
+				KV:              &capi.ReadWriteRatesConfig{ReadRate: 19, WriteRate: 20},
+				Tenancy:         &capi.ReadWriteRatesConfig{ReadRate: 21, WriteRate: 22},
+				PreparedQuery:   &capi.ReadWriteRatesConfig{ReadRate: 23, WriteRate: 24},
+				Session:         &capi.ReadWriteRatesConfig{ReadRate: 25, WriteRate: 26},
+				Txn:             &capi.ReadWriteRatesConfig{ReadRate: 27, WriteRate: 28},
+				AutoConfig:      &capi.ReadWriteRatesConfig{ReadRate: 29, WriteRate: 30},
+				FederationState: &capi.ReadWriteRatesConfig{ReadRate: 31, WriteRate: 32},
+				Internal:        &capi.ReadWriteRatesConfig{ReadRate: 33, WriteRate: 34},
+				PeerStream:      &capi.ReadWriteRatesConfig{ReadRate: 35, WriteRate: 36},
+				Peering:         &capi.ReadWriteRatesConfig{ReadRate: 37, WriteRate: 38},
+				DataPlane:       &capi.ReadWriteRatesConfig{ReadRate: 39, WriteRate: 40},
+				DNS:             &capi.ReadWriteRatesConfig{ReadRate: 41, WriteRate: 42},
+				Subscribe:       &capi.ReadWriteRatesConfig{ReadRate: 43, WriteRate: 44},
+				Resource:        &capi.ReadWriteRatesConfig{ReadRate: 45, WriteRate: 46},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"different rates do not match": {
+			Ours: ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ControlPlaneRequestLimitSpec{
+					Mode: "permissive",
+					KV:   &ReadWriteRatesConfig{ReadRate: 10},
+				},
+			},
+			Theirs: &capi.RateLimitIPConfigEntry{
+				Kind: capi.RateLimitIPConfig,
+				Name: "name",
+				Mode: "permissive",
+				KV:   &capi.ReadWriteRatesConfig{ReadRate: 20},
+			},
+			Matches: false,
+		},
+		"mismatched types does not match": {
+			Ours: ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.ServiceConfigEntry{
+				Name: "name",
+				Kind: capi.RateLimitIPConfig,
+			},
+			Matches: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestControlPlaneRequestLimit_ToConsul(t *testing.T) {
+	requestLimit := &ControlPlaneRequestLimit{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "name",
+		},
+		Spec: ControlPlaneRequestLimitSpec{
+			Mode: "permissive",
+			ReadWriteRatesConfig: ReadWriteRatesConfig{
+				ReadRate:  100,
+				WriteRate: 50.5,
+			},
+			Catalog: &ReadWriteRatesConfig{ReadRate: 10},
+		},
+	}
+	require.Equal(t, &capi.RateLimitIPConfigEntry{
+		Kind:      capi.RateLimitIPConfig,
+		Name:      "name",
+		Mode:      "permissive",
+		ReadRate:  100,
+		WriteRate: 50.5,
+		Catalog:   &capi.ReadWriteRatesConfig{ReadRate: 10},
+		Meta: map[string]string{
+			common.SourceKey:     common.SourceValue,
+			common.DatacenterKey: "datacenter",
+		},
+	}, requestLimit.ToConsul("datacenter"))
+}
+
+func TestControlPlaneRequestLimit_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input          *ControlPlaneRequestLimit
+		expectedErrMsg string
+	}{
+		"valid": {
+			input: &ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: ControlPlaneRequestLimitSpec{
+					Mode: "enforcing",
+					ReadWriteRatesConfig: ReadWriteRatesConfig{
+						ReadRate:  100,
+						WriteRate: 100,
+					},
+					ACL: &ReadWriteRatesConfig{ReadRate: 10},
+				},
+			},
+		},
+		"no mode": {
+			input: &ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+			},
+			expectedErrMsg: `controlplanerequestlimit.consul.hashicorp.com "name" is invalid: spec.mode: Required value: must be one of "permissive", "enforcing", "disabled"`,
+		},
+		"invalid mode": {
+			input: &ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: ControlPlaneRequestLimitSpec{
+					Mode: "strict",
+				},
+			},
+			expectedErrMsg: `controlplanerequestlimit.consul.hashicorp.com "name" is invalid: spec.mode: Invalid value: "strict": must be one of "permissive", "enforcing", "disabled"`,
+		},
+		"negative rates": {
+			input: &ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: ControlPlaneRequestLimitSpec{
+					Mode: "permissive",
+					ReadWriteRatesConfig: ReadWriteRatesConfig{
+						ReadRate: -1,
+					},
+					KV:  &ReadWriteRatesConfig{WriteRate: -2},
+					DNS: &ReadWriteRatesConfig{ReadRate: -3, WriteRate: -4},
+				},
+			},
+			expectedErrMsg: `controlplanerequestlimit.consul.hashicorp.com "name" is invalid: [spec.readRate: Invalid value: -1: must not be negative, spec.kv.writeRate: Invalid value: -2: must not be negative, spec.dns.readRate: Invalid value: -3: must not be negative, spec.dns.writeRate: Invalid value: -4: must not be negative]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.input.Validate(common.ConsulMeta{})
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestControlPlaneRequestLimit_AddFinalizer(t *testing.T) {
+	requestLimit := &ControlPlaneRequestLimit{}
+	requestLimit.AddFinalizer("finalizer")
+	require.Equal(t, []string{"finalizer"}, requestLimit.ObjectMeta.Finalizers)
+}
+
+func TestControlPlaneRequestLimit_RemoveFinalizer(t *testing.T) {
+	requestLimit := &ControlPlaneRequestLimit{
+		ObjectMeta: metav1.ObjectMeta{
+			Finalizers: []string{"f1", "f2"},
+		},
+	}
+	requestLimit.RemoveFinalizer("f1")
+	require.Equal(t, []string{"f2"}, requestLimit.ObjectMeta.Finalizers)
+}
+
+func TestControlPlaneRequestLimit_SetSyncedCondition(t *testing.T) {
+	requestLimit := &ControlPlaneRequestLimit{}
+	requestLimit.SetSyncedCondition(corev1.ConditionTrue, "reason", "message")
+
+	require.Equal(t, corev1.ConditionTrue, requestLimit.Status.Conditions[0].Status)
+	require.Equal(t, "reason", requestLimit.Status.Conditions[0].Reason)
+	require.Equal(t, "message", requestLimit.Status.Conditions[0].Message)
+	now := metav1.Now()
+	require.True(t, requestLimit.Status.Conditions[0].LastTransitionTime.Before(&now))
+}
+
+func TestControlPlaneRequestLimit_SetLastSyncedTime(t *testing.T) {
+	requestLimit := &ControlPlaneRequestLimit{}
+	syncedTime := metav1.NewTime(time.Now())
+	requestLimit.SetLastSyncedTime(&syncedTime)
+
+	require.Equal(t, &syncedTime, requestLimit.Status.LastSyncedTime)
+}
+
+func TestControlPlaneRequestLimit_GetSyncedConditionStatus(t *testing.T) {
+	cases := []corev1.ConditionStatus{
+		corev1.ConditionUnknown,
+		corev1.ConditionFalse,
+		corev1.ConditionTrue,
+	}
+	for _, status := range cases {
+		t.Run(string(status), func(t *testing.T) {
+			requestLimit := &ControlPlaneRequestLimit{
+				Status: Status{
+					Conditions: []Condition{{
+						Type:   ConditionSynced,
+						Status: status,
+					}},
+				},
+			}
+
+			require.Equal(t, status, requestLimit.SyncedConditionStatus())
+		})
+	}
+}
+
+func TestControlPlaneRequestLimit_SyncedConditionWhenStatusNil(t *testing.T) {
+	status, reason, message := (&ControlPlaneRequestLimit{}).SyncedCondition()
+	require.Equal(t, corev1.ConditionUnknown, status)
+	require.Equal(t, "", reason)
+	require.Equal(t, "", message)
+}
+
+func TestControlPlaneRequestLimit_ConsulKind(t *testing.T) {
+	require.Equal(t, capi.RateLimitIPConfig, (&ControlPlaneRequestLimit{}).ConsulKind())
+}
+
+func TestControlPlaneRequestLimit_KubeKind(t *testing.T) {
+	require.Equal(t, "controlplanerequestlimit", (&ControlPlaneRequestLimit{}).KubeKind())
+}
+
+func TestControlPlaneRequestLimit_ConsulName(t *testing.T) {
+	require.Equal(t, "foo", (&ControlPlaneRequestLimit{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).ConsulName())
+}
+
+func TestControlPlaneRequestLimit_KubernetesName(t *testing.T) {
+	require.Equal(t, "foo", (&ControlPlaneRequestLimit{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).KubernetesName())
+}
+
+func TestControlPlaneRequestLimit_ConsulNamespace(t *testing.T) {
+	require.Equal(t, common.DefaultConsulNamespace, (&ControlPlaneRequestLimit{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}).ConsulMirroringNS())
+}
+
+func TestControlPlaneRequestLimit_ConsulGlobalResource(t *testing.T) {
+	require.True(t, (&ControlPlaneRequestLimit{}).ConsulGlobalResource())
+}
+
+func TestControlPlaneRequestLimit_ObjectMeta(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:      "name",
+		Namespace: "namespace",
+	}
+	requestLimit := &ControlPlaneRequestLimit{
+		ObjectMeta: meta,
+	}
+	require.Equal(t, meta, requestLimit.GetObjectMeta())
+}
@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:object:generate=false
+
+type ControlPlaneRequestLimitWebhook struct {
+	ConsulClient *capi.Client
+	Logger       logr.Logger
+
+	// ConsulMeta contains metadata specific to the Consul installation.
+	ConsulMeta common.ConsulMeta
+
+	decoder *admission.Decoder
+	client.Client
+}
+
+// NOTE: The path value in the below line is the path to the webhook.
+// If it is updated, run code-gen, update subcommand/controller/command.go
+// and the consul-helm value for the path to the webhook.
+//
+// NOTE: The below line cannot be combined with any other comment. If it is it will break the code generation.
+//
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-controlplanerequestlimit,mutating=true,failurePolicy=fail,groups=consul.hashicorp.com,resources=controlplanerequestlimits,versions=v1alpha1,name=mutate-controlplanerequestlimit.consul.hashicorp.com,sideEffects=None,admissionReviewVersions=v1beta1;v1
+
+func (v *ControlPlaneRequestLimitWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var requestLimit ControlPlaneRequestLimit
+	err := v.decoder.Decode(req, &requestLimit)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Since control plane request limits aren't namespaced in Consul, their
+	// names must be unique across Kubernetes namespaces even when namespaces
+	// are mirrored.
+	if req.Operation == admissionv1.Create {
+		var requestLimitList ControlPlaneRequestLimitList
+		if err := v.Client.List(ctx, &requestLimitList); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		for _, item := range requestLimitList.Items {
+			if item.Name == requestLimit.Name {
+				return admission.Errored(http.StatusBadRequest,
+					fmt.Errorf("%s resource with name %q is already defined in namespace %q – all %s resources must have unique names across namespaces",
+						requestLimit.KubeKind(), requestLimit.Name, item.Namespace, requestLimit.KubeKind()))
+			}
+		}
+	}
+
+	return common.ValidateConfigEntry(ctx, req, v.Logger, v, &requestLimit, v.ConsulMeta)
+}
+
+func (v *ControlPlaneRequestLimitWebhook) List(ctx context.Context) ([]common.ConfigEntryResource, error) {
+	var requestLimitList ControlPlaneRequestLimitList
+	if err := v.Client.List(ctx, &requestLimitList); err != nil {
+		return nil, err
+	}
+	var entries []common.ConfigEntryResource
+	for _, item := range requestLimitList.Items {
+		entries = append(entries, common.ConfigEntryResource(&item))
+	}
+	return entries, nil
+}
+
+func (v *ControlPlaneRequestLimitWebhook) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,107 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateControlPlaneRequestLimit(t *testing.T) {
+	requestLimit := func(namespace, name string) *ControlPlaneRequestLimit {
+		return &ControlPlaneRequestLimit{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: ControlPlaneRequestLimitSpec{
+				Mode: "permissive",
+				ReadWriteRatesConfig: ReadWriteRatesConfig{
+					ReadRate:  100,
+					WriteRate: 100,
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *ControlPlaneRequestLimit
+		consulMeta        common.ConsulMeta
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"valid": {
+			existingResources: []runtime.Object{requestLimit("default", "other")},
+			newResource:       requestLimit("default", "limits"),
+			expAllow:          true,
+		},
+		"name exists in another namespace": {
+			existingResources: []runtime.Object{requestLimit("other", "limits")},
+			newResource:       requestLimit("default", "limits"),
+			expAllow:          false,
+			expErrMessage:     "controlplanerequestlimit resource with name \"limits\" is already defined in namespace \"other\" – all controlplanerequestlimit resources must have unique names across namespaces",
+		},
+		"name exists in another namespace with mirroring": {
+			existingResources: []runtime.Object{requestLimit("other", "limits")},
+			newResource:       requestLimit("default", "limits"),
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+			},
+			expAllow:      false,
+			expErrMessage: "controlplanerequestlimit resource with name \"limits\" is already defined in namespace \"other\" – all controlplanerequestlimit resources must have unique names across namespaces",
+		},
+		"invalid": {
+			newResource: &ControlPlaneRequestLimit{
+				ObjectMeta: metav1.ObjectMeta{Name: "limits", Namespace: "default"},
+				Spec:       ControlPlaneRequestLimitSpec{Mode: "strict"},
+			},
+			expAllow:      false,
+			expErrMessage: "controlplanerequestlimit.consul.hashicorp.com \"limits\" is invalid: spec.mode: Invalid value: \"strict\": must be one of \"permissive\", \"enforcing\", \"disabled\"",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ControlPlaneRequestLimit{}, &ControlPlaneRequestLimitList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ControlPlaneRequestLimitWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+				ConsulMeta:   c.consulMeta,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: c.newResource.Namespace,
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package v1alpha1
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/google/go-cmp/cmp"
@@ -66,9 +65,12 @@ type ExportedService struct {
 }
 
 // ServiceConsumer represents a downstream consumer of the service to be exported.
+// Exactly one of Partition or Peer must be set.
 type ServiceConsumer struct {
 	// Partition is the admin partition to export the service to.
 	Partition string `json:"partition,omitempty"`
+	// Peer is the name of the peer to export the service to.
+	Peer string `json:"peer,omitempty"`
 }
 
 func (in *ExportedServices) GetObjectMeta() metav1.ObjectMeta {
@@ -118,15 +120,7 @@ func (in *ExportedServices) KubernetesName() string {
 }
 
 func (in *ExportedServices) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *ExportedServices) SetLastSyncedTime(time *metav1.Time) {
@@ -164,7 +158,7 @@ func (in *ExportedServices) ToConsul(datacenter string) api.ConfigEntry {
 func (in *ExportedService) toConsul() capi.ExportedService {
 	var consumers []capi.ServiceConsumer
 	for _, consumer := range in.Consumers {
-		consumers = append(consumers, capi.ServiceConsumer{Partition: consumer.Partition})
+		consumers = append(consumers, capi.ServiceConsumer{Partition: consumer.Partition, Peer: consumer.Peer})
 	}
 	return capi.ExportedService{
 		Name:      in.Name,
@@ -185,22 +179,20 @@ func (in *ExportedServices) MatchesConsul(candidate api.ConfigEntry) bool {
 
 func (in *ExportedServices) Validate(consulMeta common.ConsulMeta) error {
 	var errs field.ErrorList
+	// Without admin partitions, services can only be exported to peers
+	// from the default partition.
+	partition := consulMeta.Partition
 	if !consulMeta.PartitionsEnabled {
-		return apierrors.NewForbidden(
-			schema.GroupResource{Group: ConsulHashicorpGroup, Resource: common.ExportedServices},
-			in.KubernetesName(),
-			errors.New("Consul Enterprise Admin Partitions must be enabled to create ExportedServices"))
+		partition = common.DefaultConsulPartition
 	}
-	if in.Name != consulMeta.Partition {
-		errs = append(errs, field.Invalid(field.NewPath("name"), in.Name, fmt.Sprintf(`%s resource name must be the same name as the partition, "%s"`, in.KubeKind(), consulMeta.Partition)))
+	if in.Name != partition {
+		errs = append(errs, field.Invalid(field.NewPath("name"), in.Name, fmt.Sprintf(`%s resource name must be the same name as the partition, "%s"`, in.KubeKind(), partition)))
 	}
 	if len(in.Spec.Services) == 0 {
 		errs = append(errs, field.Invalid(field.NewPath("spec").Child("services"), in.Spec.Services, "at least one service must be exported"))
 	}
 	for i, service := range in.Spec.Services {
-		if err := service.validate(field.NewPath("spec").Child("services").Index(i)); err != nil {
-			errs = append(errs, err)
-		}
+		errs = append(errs, service.validate(field.NewPath("spec").Child("services").Index(i), consulMeta)...)
 	}
 	if len(errs) > 0 {
 		return apierrors.NewInvalid(
@@ -210,9 +202,28 @@ func (in *ExportedServices) Validate(consulMeta common.ConsulMeta) error {
 	return nil
 }
 
-func (in *ExportedService) validate(path *field.Path) *field.Error {
+func (in *ExportedService) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
 	if len(in.Consumers) == 0 {
-		return field.Invalid(path, in.Consumers, "service must have at least 1 consumer.")
+		return field.ErrorList{field.Invalid(path, in.Consumers, "service must have at least 1 consumer.")}
+	}
+	var errs field.ErrorList
+	for i, consumer := range in.Consumers {
+		if err := consumer.validate(path.Child("consumers").Index(i), consulMeta); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (in *ServiceConsumer) validate(path *field.Path, consulMeta common.ConsulMeta) *field.Error {
+	if in.Partition == "" && in.Peer == "" {
+		return field.Required(path, "service consumer must define at least one of Partition or Peer")
+	}
+	if in.Partition != "" && in.Peer != "" {
+		return field.Invalid(path, *in, "service consumer must define at most one of Partition or Peer")
+	}
+	if in.Partition != "" && !consulMeta.PartitionsEnabled {
+		return field.Invalid(path.Child("partition"), in.Partition, "Consul Enterprise Admin Partitions must be enabled to export services to a partition")
 	}
 	return nil
 }
@@ -177,6 +177,9 @@ func TestExportedServices_ToConsul(t *testing.T) {
 								{
 									Partition: "fifth",
 								},
+								{
+									Peer: "second-peer",
+								},
 							},
 						},
 					},
@@ -207,6 +210,9 @@ func TestExportedServices_ToConsul(t *testing.T) {
 							{
 								Partition: "fifth",
 							},
+							{
+								Peer: "second-peer",
+							},
 						},
 					},
 				},
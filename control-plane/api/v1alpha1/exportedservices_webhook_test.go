@@ -119,7 +119,75 @@ func TestValidateExportedServices(t *testing.T) {
 				Partition:         "",
 			},
 			expAllow:      false,
-			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is forbidden: Consul Enterprise Admin Partitions must be enabled to create ExportedServices",
+			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is invalid: [name: Invalid value: \"other\": exportedservices resource name must be the same name as the partition, \"default\", spec.services[0].consumers[0].partition: Invalid value: \"other\": Consul Enterprise Admin Partitions must be enabled to export services to a partition]",
+		},
+		"partitions disabled, exported to peers": {
+			existingResources: []runtime.Object{},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.DefaultConsulPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{Peer: "other-peer"}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: false,
+				Partition:         "",
+			},
+			expAllow: true,
+		},
+		"consumer with partition and peer": {
+			existingResources: []runtime.Object{},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: otherPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{Partition: "other", Peer: "other-peer"}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: true,
+				Partition:         otherPartition,
+			},
+			expAllow:      false,
+			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is invalid: spec.services[0].consumers[0]: Invalid value: v1alpha1.ServiceConsumer{Partition:\"other\", Peer:\"other-peer\"}: service consumer must define at most one of Partition or Peer",
+		},
+		"consumer without partition or peer": {
+			existingResources: []runtime.Object{},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: otherPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: true,
+				Partition:         otherPartition,
+			},
+			expAllow:      false,
+			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is invalid: spec.services[0].consumers[0]: Required value: service consumer must define at least one of Partition or Peer",
 		},
 		"no services": {
 			existingResources: []runtime.Object{},
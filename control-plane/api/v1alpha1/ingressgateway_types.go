@@ -19,6 +19,11 @@ import (
 const (
 	ingressGatewayKubeKind = "ingressgateway"
 	wildcardServiceName    = "*"
+
+	// ingressGatewaySDSClusterName is the name of the Envoy cluster of the
+	// SDS server that serves Kubernetes TLS secrets in ingress gateway pods.
+	// It must match the cluster that the Helm chart adds to the gateways.
+	ingressGatewaySDSClusterName = "consul-k8s-sds"
 )
 
 func init() {
@@ -64,6 +69,11 @@ type GatewayTLSConfig struct {
 	Enabled bool `json:"enabled"`
 	// SDS allows configuring TLS certificate from an SDS service.
 	SDS *GatewayTLSSDSConfig `json:"sds,omitempty"`
+	// SecretName is the name of a Kubernetes TLS secret in the namespace of the
+	// gateway to use as the TLS certificate. The secret is served to the gateway
+	// by the SDS server in the gateway pods, so it must be listed in the
+	// `tlsSecrets` of the gateway in the Helm chart. Cannot be set with SDS.
+	SecretName string `json:"secretName,omitempty"`
 	// TLSMinVersion sets the default minimum TLS version supported.
 	// One of `TLS_AUTO`, `TLSv1_0`, `TLSv1_1`, `TLSv1_2`, or `TLSv1_3`.
 	// If unspecified, Envoy v1.22.0 and newer will default to TLS 1.2 as a min version,
@@ -81,6 +91,11 @@ type GatewayTLSConfig struct {
 type GatewayServiceTLSConfig struct {
 	// SDS allows configuring TLS certificate from an SDS service.
 	SDS *GatewayTLSSDSConfig `json:"sds,omitempty"`
+	// SecretName is the name of a Kubernetes TLS secret in the namespace of the
+	// gateway to use as the TLS certificate. The secret is served to the gateway
+	// by the SDS server in the gateway pods, so it must be listed in the
+	// `tlsSecrets` of the gateway in the Helm chart. Cannot be set with SDS.
+	SecretName string `json:"secretName,omitempty"`
 }
 
 type GatewayTLSSDSConfig struct {
@@ -193,15 +208,7 @@ func (in *IngressGateway) KubernetesName() string {
 }
 
 func (in *IngressGateway) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *IngressGateway) SetLastSyncedTime(time *metav1.Time) {
@@ -251,7 +258,7 @@ func (in *IngressGateway) Validate(consulMeta common.ConsulMeta) error {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
 
-	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"), consulMeta)...)
 
 	for i, v := range in.Spec.Listeners {
 		errs = append(errs, v.validate(path.Child("listeners").Index(i), consulMeta)...)
@@ -284,20 +291,36 @@ func (in *IngressGateway) DefaultNamespaceFields(consulMeta common.ConsulMeta) {
 	}
 }
 
+// DefaultTLSFields sets the TLS settings of the gateway and its listeners
+// that have TLS enabled and aren't set to the TLS settings of the installation.
+func (in *IngressGateway) DefaultTLSFields(consulMeta common.ConsulMeta) {
+	in.Spec.TLS.defaultTLSFields(consulMeta)
+	for _, listener := range in.Spec.Listeners {
+		listener.TLS.defaultTLSFields(consulMeta)
+	}
+}
+
+func (in *GatewayTLSConfig) defaultTLSFields(consulMeta common.ConsulMeta) {
+	if in == nil || !in.Enabled {
+		return
+	}
+	defaultTLSPolicy(&in.TLSMinVersion, &in.CipherSuites, consulMeta)
+}
+
 func (in *GatewayTLSConfig) toConsul() *capi.GatewayTLSConfig {
 	if in == nil {
 		return nil
 	}
 	return &capi.GatewayTLSConfig{
 		Enabled:       in.Enabled,
-		SDS:           in.SDS.toConsul(),
+		SDS:           sdsToConsul(in.SDS, in.SecretName),
 		TLSMaxVersion: in.TLSMaxVersion,
 		TLSMinVersion: in.TLSMinVersion,
 		CipherSuites:  in.CipherSuites,
 	}
 }
 
-func (in *GatewayTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *GatewayTLSConfig) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
 	if in == nil {
 		return nil
 	}
@@ -310,6 +333,12 @@ func (in *GatewayTLSConfig) validate(path *field.Path) field.ErrorList {
 	if !sliceContains(versions, in.TLSMinVersion) {
 		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), in.TLSMinVersion, notInSliceMessage(versions)))
 	}
+	if in.SecretName != "" && in.SDS != nil {
+		errs = append(errs, field.Invalid(path.Child("secretName"), in.SecretName, "secretName and sds cannot both be set"))
+	}
+	if in.Enabled {
+		errs = append(errs, validateTLSPolicy(path, in.TLSMinVersion, in.CipherSuites, consulMeta)...)
+	}
 	return errs
 }
 
@@ -354,8 +383,31 @@ func (in *GatewayServiceTLSConfig) toConsul() *capi.GatewayServiceTLSConfig {
 		return nil
 	}
 	return &capi.GatewayServiceTLSConfig{
-		SDS: in.SDS.toConsul(),
+		SDS: sdsToConsul(in.SDS, in.SecretName),
+	}
+}
+
+func (in *GatewayServiceTLSConfig) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	if in.SecretName != "" && in.SDS != nil {
+		return field.ErrorList{field.Invalid(path.Child("secretName"), in.SecretName, "secretName and sds cannot both be set")}
 	}
+	return nil
+}
+
+// sdsToConsul returns the SDS config of a TLS config that either sets the SDS
+// config or the name of a Kubernetes secret that is served by the SDS server
+// in the gateway pods.
+func sdsToConsul(sds *GatewayTLSSDSConfig, secretName string) *capi.GatewayTLSSDSConfig {
+	if secretName != "" {
+		return &capi.GatewayTLSSDSConfig{
+			ClusterName:  ingressGatewaySDSClusterName,
+			CertResource: secretName,
+		}
+	}
+	return sds.toConsul()
 }
 
 func (in IngressListener) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
@@ -374,7 +426,7 @@ func (in IngressListener) validate(path *field.Path, consulMeta common.ConsulMet
 			fmt.Sprintf("if protocol is \"tcp\", only a single service is allowed, found %d", len(in.Services))))
 	}
 
-	errs = append(errs, in.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.TLS.validate(path.Child("tls"), consulMeta)...)
 
 	for i, svc := range in.Services {
 		if svc.Name == wildcardServiceName && in.Protocol != "http" {
@@ -406,6 +458,8 @@ func (in IngressListener) validate(path *field.Path, consulMeta common.ConsulMet
 				string(asJSON),
 				"hosts must be empty if protocol is \"tcp\""))
 		}
+
+		errs = append(errs, svc.TLS.validate(path.Child("services").Index(i).Child("tls"))...)
 	}
 	return errs
 }
@@ -273,6 +273,74 @@ func TestIngressGateway_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"secret names": {
+			Ours: IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: IngressGatewaySpec{
+					TLS: GatewayTLSConfig{
+						SecretName: "gateway-cert",
+					},
+					Listeners: []IngressListener{
+						{
+							Port:     8888,
+							Protocol: "http",
+							TLS: &GatewayTLSConfig{
+								SecretName: "listener-cert",
+							},
+							Services: []IngressService{
+								{
+									Name:  "name1",
+									Hosts: []string{"host1.example.com"},
+									TLS: &GatewayServiceTLSConfig{
+										SecretName: "service-cert",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Exp: &capi.IngressGatewayConfigEntry{
+				Kind: capi.IngressGateway,
+				Name: "name",
+				TLS: capi.GatewayTLSConfig{
+					SDS: &capi.GatewayTLSSDSConfig{
+						ClusterName:  "consul-k8s-sds",
+						CertResource: "gateway-cert",
+					},
+				},
+				Listeners: []capi.IngressListener{
+					{
+						Port:     8888,
+						Protocol: "http",
+						TLS: &capi.GatewayTLSConfig{
+							SDS: &capi.GatewayTLSSDSConfig{
+								ClusterName:  "consul-k8s-sds",
+								CertResource: "listener-cert",
+							},
+						},
+						Services: []capi.IngressService{
+							{
+								Name:  "name1",
+								Hosts: []string{"host1.example.com"},
+								TLS: &capi.GatewayServiceTLSConfig{
+									SDS: &capi.GatewayTLSSDSConfig{
+										ClusterName:  "consul-k8s-sds",
+										CertResource: "service-cert",
+									},
+								},
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 		"every field set": {
 			Ours: IngressGateway{
 				ObjectMeta: metav1.ObjectMeta{
@@ -785,6 +853,55 @@ func TestIngressGateway_Validate(t *testing.T) {
 			},
 			partitionEnabled: true,
 		},
+		"tls.secretName and tls.sds set": {
+			input: &IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: IngressGatewaySpec{
+					TLS: GatewayTLSConfig{
+						SecretName: "cert",
+						SDS: &GatewayTLSSDSConfig{
+							ClusterName:  "cluster",
+							CertResource: "cert",
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.tls.secretName: Invalid value: "cert": secretName and sds cannot both be set`,
+			},
+		},
+		"listener.services.tls.secretName and listener.services.tls.sds set": {
+			input: &IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: IngressGatewaySpec{
+					Listeners: []IngressListener{
+						{
+							Protocol: "http",
+							Services: []IngressService{
+								{
+									Name:  "name1",
+									Hosts: []string{"host1.example.com"},
+									TLS: &GatewayServiceTLSConfig{
+										SecretName: "cert",
+										SDS: &GatewayTLSSDSConfig{
+											ClusterName:  "cluster",
+											CertResource: "cert",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.listeners[0].services[0].tls.secretName: Invalid value: "cert": secretName and sds cannot both be set`,
+			},
+		},
 		"multiple errors": {
 			input: &IngressGateway{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1029,3 +1146,38 @@ func TestIngressGateway_ObjectMeta(t *testing.T) {
 	}
 	require.Equal(t, meta, ingressGateway.GetObjectMeta())
 }
+
+func TestIngressGateway_TLSPolicy(t *testing.T) {
+	consulMeta := common.ConsulMeta{
+		TLSMinVersion:   "TLSv1_2",
+		TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	}
+	gateway := &IngressGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "name"},
+		Spec: IngressGatewaySpec{
+			TLS: GatewayTLSConfig{Enabled: true},
+			Listeners: []IngressListener{
+				{
+					Port:     8080,
+					Protocol: "tcp",
+					TLS:      &GatewayTLSConfig{Enabled: true, TLSMinVersion: "TLS_AUTO"},
+				},
+				{
+					Port:     8081,
+					Protocol: "tcp",
+				},
+			},
+		},
+	}
+
+	gateway.DefaultTLSFields(consulMeta)
+	require.Equal(t, "TLSv1_2", gateway.Spec.TLS.TLSMinVersion)
+	require.Equal(t, []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}, gateway.Spec.TLS.CipherSuites)
+	require.Equal(t, "TLS_AUTO", gateway.Spec.Listeners[0].TLS.TLSMinVersion)
+	require.Nil(t, gateway.Spec.Listeners[1].TLS)
+
+	err := gateway.Validate(consulMeta)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `spec.listeners[0].tls.tlsMinVersion: Invalid value: "TLS_AUTO": must be at least "TLSv1_2"`)
+	require.NotContains(t, err.Error(), "spec.tls.")
+}
@@ -0,0 +1,636 @@
+package v1alpha1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const JWTProviderKubeKind = "jwtprovider"
+
+func init() {
+	SchemeBuilder.Register(&JWTProvider{}, &JWTProviderList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// JWTProvider is the Schema for the jwtproviders API
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="jwt-provider"
+type JWTProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JWTProviderSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JWTProviderList contains a list of JWTProvider.
+type JWTProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JWTProvider `json:"items"`
+}
+
+// JWTProviderSpec defines the desired state of JWTProvider.
+type JWTProviderSpec struct {
+	// JSONWebKeySet defines a JSON Web Key Set, its location on disk, or the
+	// means with which to fetch a key set from a remote server.
+	JSONWebKeySet *JSONWebKeySet `json:"jsonWebKeySet,omitempty"`
+	// Issuer is the entity that must have issued the JWT.
+	// This value must match the "iss" claim of the token.
+	Issuer string `json:"issuer,omitempty"`
+	// Audiences is the set of audiences the JWT is allowed to access.
+	// If specified, all JWTs verified with this provider must address
+	// at least one of these to be considered valid.
+	Audiences []string `json:"audiences,omitempty"`
+	// Locations where the JWT will be present in requests.
+	// Envoy will check all of these locations to extract a JWT.
+	// If no locations are specified Envoy will default to the Authorization
+	// header with the Bearer schema, e.g. "Authorization: Bearer <token>",
+	// and the access_token query parameter.
+	Locations []*JWTLocation `json:"locations,omitempty"`
+	// Forwarding defines rules for forwarding verified JWTs to the backend.
+	Forwarding *JWTForwardingConfig `json:"forwarding,omitempty"`
+	// ClockSkewSeconds specifies the maximum allowable time difference
+	// from clock skew when validating the "exp" (Expiration) and "nbf"
+	// (Not Before) claims. Defaults to 30 seconds.
+	ClockSkewSeconds int `json:"clockSkewSeconds,omitempty"`
+	// CacheConfig defines configuration for caching the validation
+	// result for previously seen JWTs. Caching results can speed up
+	// verification when individual tokens are expected to be handled
+	// multiple times.
+	CacheConfig *JWTCacheConfig `json:"cacheConfig,omitempty"`
+}
+
+// JSONWebKeySet defines a key set, its location on disk, or the
+// means with which to fetch a key set from a remote server.
+// Exactly one of Local or Remote must be specified.
+type JSONWebKeySet struct {
+	// Local specifies a local source for the key set.
+	Local *LocalJWKS `json:"local,omitempty"`
+	// Remote specifies how to fetch a key set from a remote server.
+	Remote *RemoteJWKS `json:"remote,omitempty"`
+}
+
+// LocalJWKS specifies a location for a local JWKS.
+// Exactly one of JWKS or Filename must be specified.
+type LocalJWKS struct {
+	// JWKS contains a base64 encoded JWKS.
+	JWKS string `json:"jwks,omitempty"`
+	// Filename configures a location on disk where the JWKS can be
+	// found. If specified, the file must be present on the disk of ALL
+	// proxies with intentions referencing this provider.
+	Filename string `json:"filename,omitempty"`
+}
+
+// RemoteJWKS specifies how to fetch a JWKS from a remote server.
+type RemoteJWKS struct {
+	// URI is the URI of the server to query for the JWKS.
+	URI string `json:"uri,omitempty"`
+	// RequestTimeoutMs is the number of milliseconds to
+	// time out when making a request for the JWKS.
+	RequestTimeoutMs int `json:"requestTimeoutMs,omitempty"`
+	// CacheDuration is the duration after which cached keys
+	// should be expired. Defaults to 5 minutes.
+	CacheDuration metav1.Duration `json:"cacheDuration,omitempty"`
+	// FetchAsynchronously indicates that the JWKS should be fetched
+	// when a client request arrives. Client requests will be paused
+	// until the JWKS is fetched.
+	// If false, the proxy listener will wait for the JWKS to be
+	// fetched before being activated.
+	FetchAsynchronously bool `json:"fetchAsynchronously,omitempty"`
+	// RetryPolicy defines a retry policy for fetching JWKS.
+	// There is no retry by default.
+	RetryPolicy *JWKSRetryPolicy `json:"retryPolicy,omitempty"`
+	// JWKSCluster defines how the specified Remote JWKS URI is to be fetched.
+	JWKSCluster *JWKSCluster `json:"jwksCluster,omitempty"`
+}
+
+// JWKSRetryPolicy defines a retry policy for fetching JWKS.
+type JWKSRetryPolicy struct {
+	// NumRetries is the number of times to retry fetching the JWKS.
+	// The retry strategy uses jittered exponential backoff with
+	// a base interval of 1s and max of 10s.
+	NumRetries int `json:"numRetries,omitempty"`
+	// RetryPolicyBackOff is the backoff policy. Defaults to Envoy's backoff
+	// policy.
+	RetryPolicyBackOff *RetryPolicyBackOff `json:"retryPolicyBackOff,omitempty"`
+}
+
+// RetryPolicyBackOff is the backoff policy of the retries.
+type RetryPolicyBackOff struct {
+	// BaseInterval to be used for the next back off computation.
+	// Defaults to 1s.
+	BaseInterval metav1.Duration `json:"baseInterval,omitempty"`
+	// MaxInterval to be used to specify the maximum interval between retries.
+	// Optional but should be greater or equal to BaseInterval.
+	// Defaults to 10 times BaseInterval.
+	MaxInterval metav1.Duration `json:"maxInterval,omitempty"`
+}
+
+// JWKSCluster defines how the specified Remote JWKS URI is to be fetched.
+type JWKSCluster struct {
+	// DiscoveryType refers to the service discovery type to use for resolving the cluster.
+	// Defaults to STRICT_DNS. Other options include STATIC, LOGICAL_DNS, EDS or ORIGINAL_DST.
+	DiscoveryType string `json:"discoveryType,omitempty"`
+	// TLSCertificates refers to the data containing certificate authority certificates to use
+	// in verifying a presented peer certificate.
+	// If not specified and a peer certificate is presented it will not be verified.
+	TLSCertificates *JWKSTLSCertificate `json:"tlsCertificates,omitempty"`
+	// The timeout for new network connections to hosts in the cluster.
+	// Defaults to 5s.
+	ConnectTimeout metav1.Duration `json:"connectTimeout,omitempty"`
+}
+
+// JWKSTLSCertificate refers to the data containing certificate authority
+// certificates to use in verifying a presented peer certificate.
+// Exactly one of CaCertificateProviderInstance or TrustedCA must be specified.
+type JWKSTLSCertificate struct {
+	// CaCertificateProviderInstance is the certificate provider instance for
+	// fetching TLS certificates.
+	CaCertificateProviderInstance *JWKSTLSCertProviderInstance `json:"caCertificateProviderInstance,omitempty"`
+	// TrustedCA defines TLS certificate data containing certificate authority certificates
+	// to use in verifying a presented peer certificate.
+	TrustedCA *JWKSTLSCertTrustedCA `json:"trustedCA,omitempty"`
+}
+
+// JWKSTLSCertProviderInstance is a certificate provider instance.
+type JWKSTLSCertProviderInstance struct {
+	// InstanceName refers to the certificate provider instance name.
+	// Defaults to "default".
+	InstanceName string `json:"instanceName,omitempty"`
+	// CertificateName is used to specify certificate instances or types. For example, "ROOTCA" to specify
+	// a root-certificate (validation context) or "example.com" to specify a certificate for a
+	// particular domain.
+	CertificateName string `json:"certificateName,omitempty"`
+}
+
+// JWKSTLSCertTrustedCA defines TLS certificate data containing certificate
+// authority certificates. Exactly one of Filename, EnvironmentVariable,
+// InlineString or InlineBytes must be specified.
+type JWKSTLSCertTrustedCA struct {
+	Filename            string `json:"filename,omitempty"`
+	EnvironmentVariable string `json:"environmentVariable,omitempty"`
+	InlineString        string `json:"inlineString,omitempty"`
+	InlineBytes         []byte `json:"inlineBytes,omitempty"`
+}
+
+// JWTLocation is a location where the JWT could be present in requests.
+// Exactly one of Header, QueryParam, or Cookie must be specified.
+type JWTLocation struct {
+	// Header defines how to extract a JWT from an HTTP request header.
+	Header *JWTLocationHeader `json:"header,omitempty"`
+	// QueryParam defines how to extract a JWT from an HTTP request
+	// query parameter.
+	QueryParam *JWTLocationQueryParam `json:"queryParam,omitempty"`
+	// Cookie defines how to extract a JWT from an HTTP request cookie.
+	Cookie *JWTLocationCookie `json:"cookie,omitempty"`
+}
+
+// JWTLocationHeader defines how to extract a JWT from an HTTP request header.
+type JWTLocationHeader struct {
+	// Name is the name of the header containing the token.
+	Name string `json:"name,omitempty"`
+	// ValuePrefix is an optional prefix that precedes the token in the
+	// header value.
+	// For example, "Bearer " is a standard value prefix for a header named
+	// "Authorization", but the prefix is not part of the token itself:
+	// "Authorization: Bearer <token>"
+	ValuePrefix string `json:"valuePrefix,omitempty"`
+	// Forward defines whether the header with the JWT should be
+	// forwarded after the token has been verified. If false, the
+	// header will not be forwarded to the backend.
+	Forward bool `json:"forward,omitempty"`
+}
+
+// JWTLocationQueryParam defines how to extract a JWT from an HTTP request query parameter.
+type JWTLocationQueryParam struct {
+	// Name is the name of the query param containing the token.
+	Name string `json:"name,omitempty"`
+}
+
+// JWTLocationCookie defines how to extract a JWT from an HTTP request cookie.
+type JWTLocationCookie struct {
+	// Name is the name of the cookie containing the token.
+	Name string `json:"name,omitempty"`
+}
+
+// JWTForwardingConfig defines rules for forwarding verified JWTs to the backend.
+type JWTForwardingConfig struct {
+	// HeaderName is a header name to use when forwarding a verified
+	// JWT to the backend. The verified JWT could have been extracted
+	// from any location (query param, header, or cookie).
+	// The header value will be base64-URL-encoded, and will not be
+	// padded unless PadForwardPayloadHeader is true.
+	HeaderName string `json:"headerName,omitempty"`
+	// PadForwardPayloadHeader determines whether padding should be added
+	// to the base64 encoded token forwarded with ForwardPayloadHeader.
+	PadForwardPayloadHeader bool `json:"padForwardPayloadHeader,omitempty"`
+}
+
+// JWTCacheConfig defines configuration for caching the validation result of
+// previously seen JWTs.
+type JWTCacheConfig struct {
+	// Size specifies the maximum number of JWT verification
+	// results to cache. Defaults to 0, meaning that JWT caching is disabled.
+	Size int `json:"size,omitempty"`
+}
+
+func (in *JWTProvider) GetObjectMeta() metav1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+func (in *JWTProvider) AddFinalizer(name string) {
+	in.ObjectMeta.Finalizers = append(in.Finalizers(), name)
+}
+
+func (in *JWTProvider) RemoveFinalizer(name string) {
+	var newFinalizers []string
+	for _, oldF := range in.Finalizers() {
+		if oldF != name {
+			newFinalizers = append(newFinalizers, oldF)
+		}
+	}
+	in.ObjectMeta.Finalizers = newFinalizers
+}
+
+func (in *JWTProvider) Finalizers() []string {
+	return in.ObjectMeta.Finalizers
+}
+
+func (in *JWTProvider) ConsulKind() string {
+	return capi.JWTProvider
+}
+
+func (in *JWTProvider) ConsulGlobalResource() bool {
+	return true
+}
+
+func (in *JWTProvider) ConsulMirroringNS() string {
+	return common.DefaultConsulNamespace
+}
+
+func (in *JWTProvider) KubeKind() string {
+	return JWTProviderKubeKind
+}
+
+func (in *JWTProvider) ConsulName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *JWTProvider) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *JWTProvider) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
+	in.Status.setCondition(ConditionSynced, status, reason, message)
+}
+
+func (in *JWTProvider) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *JWTProvider) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *JWTProvider) SyncedConditionStatus() corev1.ConditionStatus {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown
+	}
+	return cond.Status
+}
+
+func (in *JWTProvider) ToConsul(datacenter string) capi.ConfigEntry {
+	var locations []*capi.JWTLocation
+	for _, location := range in.Spec.Locations {
+		locations = append(locations, location.toConsul())
+	}
+	return &capi.JWTProviderConfigEntry{
+		Kind:             in.ConsulKind(),
+		Name:             in.ConsulName(),
+		JSONWebKeySet:    in.Spec.JSONWebKeySet.toConsul(),
+		Issuer:           in.Spec.Issuer,
+		Audiences:        in.Spec.Audiences,
+		Locations:        locations,
+		Forwarding:       in.Spec.Forwarding.toConsul(),
+		ClockSkewSeconds: in.Spec.ClockSkewSeconds,
+		CacheConfig:      in.Spec.CacheConfig.toConsul(),
+		Meta:             meta(datacenter),
+	}
+}
+
+func (in *JWTProvider) MatchesConsul(candidate capi.ConfigEntry) bool {
+	configEntry, ok := candidate.(*capi.JWTProviderConfigEntry)
+	if !ok {
+		return false
+	}
+	// No datacenter is passed to ToConsul as we ignore the Meta field when checking for equality.
+	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.JWTProviderConfigEntry{}, "Partition", "Namespace", "Meta", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
+}
+
+func (in *JWTProvider) Validate(_ common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if in.Spec.JSONWebKeySet == nil {
+		errs = append(errs, field.Required(path.Child("jsonWebKeySet"), "jsonWebKeySet is required"))
+	} else {
+		errs = append(errs, in.Spec.JSONWebKeySet.validate(path.Child("jsonWebKeySet"))...)
+	}
+	for i, location := range in.Spec.Locations {
+		errs = append(errs, location.validate(path.Child("locations").Index(i))...)
+	}
+	if in.Spec.Forwarding != nil && in.Spec.Forwarding.HeaderName == "" {
+		errs = append(errs, field.Required(path.Child("forwarding", "headerName"), "headerName is required when forwarding is set"))
+	}
+	if in.Spec.ClockSkewSeconds < 0 {
+		errs = append(errs, field.Invalid(path.Child("clockSkewSeconds"), in.Spec.ClockSkewSeconds, "must not be negative"))
+	}
+	if in.Spec.CacheConfig != nil && in.Spec.CacheConfig.Size < 0 {
+		errs = append(errs, field.Invalid(path.Child("cacheConfig", "size"), in.Spec.CacheConfig.Size, "must not be negative"))
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: JWTProviderKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in *JWTProvider) DefaultNamespaceFields(_ common.ConsulMeta) {
+}
+
+func (in *JSONWebKeySet) toConsul() *capi.JSONWebKeySet {
+	if in == nil {
+		return nil
+	}
+	return &capi.JSONWebKeySet{
+		Local:  in.Local.toConsul(),
+		Remote: in.Remote.toConsul(),
+	}
+}
+
+func (in *JSONWebKeySet) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if (in.Local == nil) == (in.Remote == nil) {
+		asJSON, _ := json.Marshal(in)
+		errs = append(errs, field.Invalid(path, string(asJSON), "exactly one of local or remote must be set"))
+		return errs
+	}
+	if in.Local != nil {
+		errs = append(errs, in.Local.validate(path.Child("local"))...)
+	}
+	if in.Remote != nil {
+		errs = append(errs, in.Remote.validate(path.Child("remote"))...)
+	}
+	return errs
+}
+
+func (in *LocalJWKS) toConsul() *capi.LocalJWKS {
+	if in == nil {
+		return nil
+	}
+	return &capi.LocalJWKS{
+		JWKS:     in.JWKS,
+		Filename: in.Filename,
+	}
+}
+
+func (in *LocalJWKS) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if (in.JWKS == "") == (in.Filename == "") {
+		asJSON, _ := json.Marshal(in)
+		errs = append(errs, field.Invalid(path, string(asJSON), "exactly one of jwks or filename must be set"))
+	}
+	if in.JWKS != "" {
+		if _, err := base64.StdEncoding.DecodeString(in.JWKS); err != nil {
+			errs = append(errs, field.Invalid(path.Child("jwks"), in.JWKS, "must be base64 encoded"))
+		}
+	}
+	return errs
+}
+
+func (in *RemoteJWKS) toConsul() *capi.RemoteJWKS {
+	if in == nil {
+		return nil
+	}
+	return &capi.RemoteJWKS{
+		URI:                 in.URI,
+		RequestTimeoutMs:    in.RequestTimeoutMs,
+		CacheDuration:       in.CacheDuration.Duration,
+		FetchAsynchronously: in.FetchAsynchronously,
+		RetryPolicy:         in.RetryPolicy.toConsul(),
+		JWKSCluster:         in.JWKSCluster.toConsul(),
+	}
+}
+
+func (in *RemoteJWKS) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.URI == "" {
+		errs = append(errs, field.Required(path.Child("uri"), "uri is required"))
+	} else if u, err := url.ParseRequestURI(in.URI); err != nil || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("uri"), in.URI, "must be a valid URL"))
+	}
+	if in.RequestTimeoutMs < 0 {
+		errs = append(errs, field.Invalid(path.Child("requestTimeoutMs"), in.RequestTimeoutMs, "must not be negative"))
+	}
+	if in.CacheDuration.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("cacheDuration"), in.CacheDuration.Duration.String(), "must not be negative"))
+	}
+	if in.RetryPolicy != nil {
+		errs = append(errs, in.RetryPolicy.validate(path.Child("retryPolicy"))...)
+	}
+	if in.JWKSCluster != nil {
+		errs = append(errs, in.JWKSCluster.validate(path.Child("jwksCluster"))...)
+	}
+	return errs
+}
+
+func (in *JWKSRetryPolicy) toConsul() *capi.JWKSRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	policy := &capi.JWKSRetryPolicy{NumRetries: in.NumRetries}
+	if in.RetryPolicyBackOff != nil {
+		policy.RetryPolicyBackOff = &capi.RetryPolicyBackOff{
+			BaseInterval: in.RetryPolicyBackOff.BaseInterval.Duration,
+			MaxInterval:  in.RetryPolicyBackOff.MaxInterval.Duration,
+		}
+	}
+	return policy
+}
+
+func (in *JWKSRetryPolicy) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.NumRetries < 0 {
+		errs = append(errs, field.Invalid(path.Child("numRetries"), in.NumRetries, "must not be negative"))
+	}
+	if backOff := in.RetryPolicyBackOff; backOff != nil {
+		backOffPath := path.Child("retryPolicyBackOff")
+		if backOff.BaseInterval.Duration < 0 {
+			errs = append(errs, field.Invalid(backOffPath.Child("baseInterval"), backOff.BaseInterval.Duration.String(), "must not be negative"))
+		}
+		if backOff.MaxInterval.Duration < 0 {
+			errs = append(errs, field.Invalid(backOffPath.Child("maxInterval"), backOff.MaxInterval.Duration.String(), "must not be negative"))
+		}
+		if backOff.MaxInterval.Duration > 0 && backOff.MaxInterval.Duration < backOff.BaseInterval.Duration {
+			errs = append(errs, field.Invalid(backOffPath.Child("maxInterval"), backOff.MaxInterval.Duration.String(), "must be greater than or equal to baseInterval"))
+		}
+	}
+	return errs
+}
+
+func (in *JWKSCluster) toConsul() *capi.JWKSCluster {
+	if in == nil {
+		return nil
+	}
+	cluster := &capi.JWKSCluster{
+		DiscoveryType:  capi.ClusterDiscoveryType(in.DiscoveryType),
+		ConnectTimeout: in.ConnectTimeout.Duration,
+	}
+	if certs := in.TLSCertificates; certs != nil {
+		cluster.TLSCertificates = &capi.JWKSTLSCertificate{}
+		if certs.CaCertificateProviderInstance != nil {
+			cluster.TLSCertificates.CaCertificateProviderInstance = &capi.JWKSTLSCertProviderInstance{
+				InstanceName:    certs.CaCertificateProviderInstance.InstanceName,
+				CertificateName: certs.CaCertificateProviderInstance.CertificateName,
+			}
+		}
+		if certs.TrustedCA != nil {
+			cluster.TLSCertificates.TrustedCA = &capi.JWKSTLSCertTrustedCA{
+				Filename:            certs.TrustedCA.Filename,
+				EnvironmentVariable: certs.TrustedCA.EnvironmentVariable,
+				InlineString:        certs.TrustedCA.InlineString,
+				InlineBytes:         certs.TrustedCA.InlineBytes,
+			}
+		}
+	}
+	return cluster
+}
+
+func (in *JWKSCluster) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	discoveryTypes := []string{
+		string(capi.DiscoveryTypeStrictDNS),
+		string(capi.DiscoveryTypeStatic),
+		string(capi.DiscoveryTypeLogicalDNS),
+		string(capi.DiscoveryTypeEDS),
+		string(capi.DiscoveryTypeOriginalDST),
+	}
+	if in.DiscoveryType != "" && !sliceContains(discoveryTypes, in.DiscoveryType) {
+		errs = append(errs, field.Invalid(path.Child("discoveryType"), in.DiscoveryType, notInSliceMessage(discoveryTypes)))
+	}
+	if in.ConnectTimeout.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("connectTimeout"), in.ConnectTimeout.Duration.String(), "must not be negative"))
+	}
+	if certs := in.TLSCertificates; certs != nil {
+		certsPath := path.Child("tlsCertificates")
+		if (certs.CaCertificateProviderInstance == nil) == (certs.TrustedCA == nil) {
+			asJSON, _ := json.Marshal(certs)
+			errs = append(errs, field.Invalid(certsPath, string(asJSON), "exactly one of caCertificateProviderInstance or trustedCA must be set"))
+		}
+		if ca := certs.TrustedCA; ca != nil {
+			set := numNotEmpty(ca.Filename, ca.EnvironmentVariable, ca.InlineString)
+			if len(ca.InlineBytes) > 0 {
+				set++
+			}
+			if set != 1 {
+				asJSON, _ := json.Marshal(ca)
+				errs = append(errs, field.Invalid(certsPath.Child("trustedCA"), string(asJSON), "exactly one of filename, environmentVariable, inlineString or inlineBytes must be set"))
+			}
+		}
+	}
+	return errs
+}
+
+func (in *JWTLocation) toConsul() *capi.JWTLocation {
+	if in == nil {
+		return nil
+	}
+	location := &capi.JWTLocation{}
+	if in.Header != nil {
+		location.Header = &capi.JWTLocationHeader{
+			Name:        in.Header.Name,
+			ValuePrefix: in.Header.ValuePrefix,
+			Forward:     in.Header.Forward,
+		}
+	}
+	if in.QueryParam != nil {
+		location.QueryParam = &capi.JWTLocationQueryParam{Name: in.QueryParam.Name}
+	}
+	if in.Cookie != nil {
+		location.Cookie = &capi.JWTLocationCookie{Name: in.Cookie.Name}
+	}
+	return location
+}
+
+func (in *JWTLocation) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return append(errs, field.Required(path, "location must not be empty"))
+	}
+	set := 0
+	if in.Header != nil {
+		set++
+		if in.Header.Name == "" {
+			errs = append(errs, field.Required(path.Child("header", "name"), "name is required"))
+		}
+	}
+	if in.QueryParam != nil {
+		set++
+		if in.QueryParam.Name == "" {
+			errs = append(errs, field.Required(path.Child("queryParam", "name"), "name is required"))
+		}
+	}
+	if in.Cookie != nil {
+		set++
+		if in.Cookie.Name == "" {
+			errs = append(errs, field.Required(path.Child("cookie", "name"), "name is required"))
+		}
+	}
+	if set != 1 {
+		asJSON, _ := json.Marshal(in)
+		errs = append(errs, field.Invalid(path, string(asJSON), "exactly one of header, queryParam or cookie must be set"))
+	}
+	return errs
+}
+
+func (in *JWTForwardingConfig) toConsul() *capi.JWTForwardingConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.JWTForwardingConfig{
+		HeaderName:              in.HeaderName,
+		PadForwardPayloadHeader: in.PadForwardPayloadHeader,
+	}
+}
+
+func (in *JWTCacheConfig) toConsul() *capi.JWTCacheConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.JWTCacheConfig{Size: in.Size}
+}
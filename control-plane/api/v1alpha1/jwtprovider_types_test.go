@@ -0,0 +1,545 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJWTProvider_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    JWTProvider
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.JWTProviderConfigEntry{
+				Kind:        capi.JWTProvider,
+				Name:        "name",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI:                 "https://jwks.example.com",
+							RequestTimeoutMs:    500,
+							CacheDuration:       metav1.Duration{Duration: 10 * time.Minute},
+							FetchAsynchronously: true,
+							RetryPolicy: &JWKSRetryPolicy{
+								NumRetries: 3,
+								RetryPolicyBackOff: &RetryPolicyBackOff{
+									BaseInterval: metav1.Duration{Duration: time.Second},
+									MaxInterval:  metav1.Duration{Duration: 5 * time.Second},
+								},
+							},
+							JWKSCluster: &JWKSCluster{
+								DiscoveryType: "STATIC",
+								TLSCertificates: &JWKSTLSCertificate{
+									TrustedCA: &JWKSTLSCertTrustedCA{
+										Filename: "ca.pem",
+									},
+								},
+								ConnectTimeout: metav1.Duration{Duration: 2 * time.Second},
+							},
+						},
+					},
+					Issuer:    "test-issuer",
+					Audiences: []string{"aud1", "aud2"},
+					Locations: []*JWTLocation{
+						{
+							Header: &JWTLocationHeader{
+								Name:        "Authorization",
+								ValuePrefix: "Bearer ",
+								Forward:     true,
+							},
+						},
+						{
+							QueryParam: &JWTLocationQueryParam{Name: "token"},
+						},
+						{
+							Cookie: &JWTLocationCookie{Name: "session"},
+						},
+					},
+					Forwarding: &JWTForwardingConfig{
+						HeaderName:              "x-jwt",
+						PadForwardPayloadHeader: true,
+					},
+					ClockSkewSeconds: 30,
+					CacheConfig: &JWTCacheConfig{
+						Size: 100,
+					},
+				},
+			},
+			Theirs: &capi.JWTProviderConfigEntry{
+				Kind:      capi.JWTProvider,
+				Name:      "name",
+				Namespace: "default",
+				Partition: "default",
+				JSONWebKeySet: &capi.JSONWebKeySet{
+					Remote: &capi.RemoteJWKS{
+						URI:                 "https://jwks.example.com",
+						RequestTimeoutMs:    500,
+						CacheDuration:       10 * time.Minute,
+						FetchAsynchronously: true,
+						RetryPolicy: &capi.JWKSRetryPolicy{
+							NumRetries: 3,
+							RetryPolicyBackOff: &capi.RetryPolicyBackOff{
+								BaseInterval: time.Second,
+								MaxInterval:  5 * time.Second,
+							},
+						},
+						JWKSCluster: &capi.JWKSCluster{
+							DiscoveryType: capi.DiscoveryTypeStatic,
+							TLSCertificates: &capi.JWKSTLSCertificate{
+								TrustedCA: &capi.JWKSTLSCertTrustedCA{
+									Filename: "ca.pem",
+								},
+							},
+							ConnectTimeout: 2 * time.Second,
+						},
+					},
+				},
+				Issuer:    "test-issuer",
+				Audiences: []string{"aud1", "aud2"},
+				Locations: []*capi.JWTLocation{
+					{
+						Header: &capi.JWTLocationHeader{
+							Name:        "Authorization",
+							ValuePrefix: "Bearer ",
+							Forward:     true,
+						},
+					},
+					{
+						QueryParam: &capi.JWTLocationQueryParam{Name: "token"},
+					},
+					{
+						Cookie: &capi.JWTLocationCookie{Name: "session"},
+					},
+				},
+				Forwarding: &capi.JWTForwardingConfig{
+					HeaderName:              "x-jwt",
+					PadForwardPayloadHeader: true,
+				},
+				ClockSkewSeconds: 30,
+				CacheConfig: &capi.JWTCacheConfig{
+					Size: 100,
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"different issuer does not match": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: JWTProviderSpec{
+					Issuer: "test-issuer",
+				},
+			},
+			Theirs: &capi.JWTProviderConfigEntry{
+				Kind:   capi.JWTProvider,
+				Name:   "name",
+				Issuer: "other-issuer",
+			},
+			Matches: false,
+		},
+		"mismatched types does not match": {
+			Ours: JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.ServiceConfigEntry{
+				Name: "name",
+				Kind: capi.JWTProvider,
+			},
+			Matches: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestJWTProvider_ToConsul(t *testing.T) {
+	jwtProvider := &JWTProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "name",
+		},
+		Spec: JWTProviderSpec{
+			JSONWebKeySet: &JSONWebKeySet{
+				Local: &LocalJWKS{
+					Filename: "jwks.json",
+				},
+			},
+			Issuer: "test-issuer",
+			Locations: []*JWTLocation{
+				{
+					Header: &JWTLocationHeader{Name: "Authorization"},
+				},
+			},
+		},
+	}
+	require.Equal(t, &capi.JWTProviderConfigEntry{
+		Kind: capi.JWTProvider,
+		Name: "name",
+		JSONWebKeySet: &capi.JSONWebKeySet{
+			Local: &capi.LocalJWKS{
+				Filename: "jwks.json",
+			},
+		},
+		Issuer: "test-issuer",
+		Locations: []*capi.JWTLocation{
+			{
+				Header: &capi.JWTLocationHeader{Name: "Authorization"},
+			},
+		},
+		Meta: map[string]string{
+			common.SourceKey:     common.SourceValue,
+			common.DatacenterKey: "datacenter",
+		},
+	}, jwtProvider.ToConsul("datacenter"))
+}
+
+func TestJWTProvider_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input          *JWTProvider
+		expectedErrMsg string
+	}{
+		"valid local": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{JWKS: "eyJrZXlzIjpbXX0="},
+					},
+				},
+			},
+		},
+		"valid remote": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI: "https://jwks.example.com/.well-known/jwks.json",
+							RetryPolicy: &JWKSRetryPolicy{
+								NumRetries: 2,
+								RetryPolicyBackOff: &RetryPolicyBackOff{
+									BaseInterval: metav1.Duration{Duration: time.Second},
+								},
+							},
+							JWKSCluster: &JWKSCluster{
+								DiscoveryType: "STRICT_DNS",
+								TLSCertificates: &JWKSTLSCertificate{
+									CaCertificateProviderInstance: &JWKSTLSCertProviderInstance{
+										InstanceName: "default",
+									},
+								},
+							},
+						},
+					},
+					Locations: []*JWTLocation{
+						{Header: &JWTLocationHeader{Name: "Authorization"}},
+					},
+					Forwarding: &JWTForwardingConfig{HeaderName: "x-jwt"},
+				},
+			},
+		},
+		"no key set": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet: Required value: jsonWebKeySet is required`,
+		},
+		"local and remote key sets": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local:  &LocalJWKS{Filename: "jwks.json"},
+						Remote: &RemoteJWKS{URI: "https://jwks.example.com"},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet: Invalid value: "{\"local\":{\"filename\":\"jwks.json\"},\"remote\":{\"uri\":\"https://jwks.example.com\",\"cacheDuration\":\"0s\"}}": exactly one of local or remote must be set`,
+		},
+		"local jwks and filename": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{JWKS: "eyJrZXlzIjpbXX0=", Filename: "jwks.json"},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.local: Invalid value: "{\"jwks\":\"eyJrZXlzIjpbXX0=\",\"filename\":\"jwks.json\"}": exactly one of jwks or filename must be set`,
+		},
+		"local jwks not base64": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{JWKS: "{\"keys\":[]}"},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.local.jwks: Invalid value: "{\"keys\":[]}": must be base64 encoded`,
+		},
+		"remote without uri": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.remote.uri: Required value: uri is required`,
+		},
+		"remote with invalid uri": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{URI: "jwks.example.com"},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.remote.uri: Invalid value: "jwks.example.com": must be a valid URL`,
+		},
+		"remote with max interval less than base interval": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI: "https://jwks.example.com",
+							RetryPolicy: &JWKSRetryPolicy{
+								RetryPolicyBackOff: &RetryPolicyBackOff{
+									BaseInterval: metav1.Duration{Duration: 5 * time.Second},
+									MaxInterval:  metav1.Duration{Duration: time.Second},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.remote.retryPolicy.retryPolicyBackOff.maxInterval: Invalid value: "1s": must be greater than or equal to baseInterval`,
+		},
+		"remote with invalid discovery type": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI: "https://jwks.example.com",
+							JWKSCluster: &JWKSCluster{
+								DiscoveryType: "DNS",
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.remote.jwksCluster.discoveryType: Invalid value: "DNS": must be one of "STRICT_DNS", "STATIC", "LOGICAL_DNS", "EDS", "ORIGINAL_DST"`,
+		},
+		"trusted CA with multiple sources": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Remote: &RemoteJWKS{
+							URI: "https://jwks.example.com",
+							JWKSCluster: &JWKSCluster{
+								TLSCertificates: &JWKSTLSCertificate{
+									TrustedCA: &JWKSTLSCertTrustedCA{
+										Filename:            "ca.pem",
+										EnvironmentVariable: "CA",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.jsonWebKeySet.remote.jwksCluster.tlsCertificates.trustedCA: Invalid value: "{\"filename\":\"ca.pem\",\"environmentVariable\":\"CA\"}": exactly one of filename, environmentVariable, inlineString or inlineBytes must be set`,
+		},
+		"invalid locations": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{Filename: "jwks.json"},
+					},
+					Locations: []*JWTLocation{
+						{},
+						{Cookie: &JWTLocationCookie{}},
+					},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: [spec.locations[0]: Invalid value: "{}": exactly one of header, queryParam or cookie must be set, spec.locations[1].cookie.name: Required value: name is required]`,
+		},
+		"forwarding without header name": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{Filename: "jwks.json"},
+					},
+					Forwarding: &JWTForwardingConfig{PadForwardPayloadHeader: true},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: spec.forwarding.headerName: Required value: headerName is required when forwarding is set`,
+		},
+		"negative values": {
+			input: &JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: JWTProviderSpec{
+					JSONWebKeySet: &JSONWebKeySet{
+						Local: &LocalJWKS{Filename: "jwks.json"},
+					},
+					ClockSkewSeconds: -1,
+					CacheConfig:      &JWTCacheConfig{Size: -1},
+				},
+			},
+			expectedErrMsg: `jwtprovider.consul.hashicorp.com "name" is invalid: [spec.clockSkewSeconds: Invalid value: -1: must not be negative, spec.cacheConfig.size: Invalid value: -1: must not be negative]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.input.Validate(common.ConsulMeta{})
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJWTProvider_AddFinalizer(t *testing.T) {
+	jwtProvider := &JWTProvider{}
+	jwtProvider.AddFinalizer("finalizer")
+	require.Equal(t, []string{"finalizer"}, jwtProvider.ObjectMeta.Finalizers)
+}
+
+func TestJWTProvider_RemoveFinalizer(t *testing.T) {
+	jwtProvider := &JWTProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Finalizers: []string{"f1", "f2"},
+		},
+	}
+	jwtProvider.RemoveFinalizer("f1")
+	require.Equal(t, []string{"f2"}, jwtProvider.ObjectMeta.Finalizers)
+}
+
+func TestJWTProvider_SetSyncedCondition(t *testing.T) {
+	jwtProvider := &JWTProvider{}
+	jwtProvider.SetSyncedCondition(corev1.ConditionTrue, "reason", "message")
+
+	require.Equal(t, corev1.ConditionTrue, jwtProvider.Status.Conditions[0].Status)
+	require.Equal(t, "reason", jwtProvider.Status.Conditions[0].Reason)
+	require.Equal(t, "message", jwtProvider.Status.Conditions[0].Message)
+	now := metav1.Now()
+	require.True(t, jwtProvider.Status.Conditions[0].LastTransitionTime.Before(&now))
+}
+
+func TestJWTProvider_SetLastSyncedTime(t *testing.T) {
+	jwtProvider := &JWTProvider{}
+	syncedTime := metav1.NewTime(time.Now())
+	jwtProvider.SetLastSyncedTime(&syncedTime)
+
+	require.Equal(t, &syncedTime, jwtProvider.Status.LastSyncedTime)
+}
+
+func TestJWTProvider_GetSyncedConditionStatus(t *testing.T) {
+	cases := []corev1.ConditionStatus{
+		corev1.ConditionUnknown,
+		corev1.ConditionFalse,
+		corev1.ConditionTrue,
+	}
+	for _, status := range cases {
+		t.Run(string(status), func(t *testing.T) {
+			jwtProvider := &JWTProvider{
+				Status: Status{
+					Conditions: []Condition{{
+						Type:   ConditionSynced,
+						Status: status,
+					}},
+				},
+			}
+
+			require.Equal(t, status, jwtProvider.SyncedConditionStatus())
+		})
+	}
+}
+
+func TestJWTProvider_SyncedConditionWhenStatusNil(t *testing.T) {
+	status, reason, message := (&JWTProvider{}).SyncedCondition()
+	require.Equal(t, corev1.ConditionUnknown, status)
+	require.Equal(t, "", reason)
+	require.Equal(t, "", message)
+}
+
+func TestJWTProvider_ConsulKind(t *testing.T) {
+	require.Equal(t, capi.JWTProvider, (&JWTProvider{}).ConsulKind())
+}
+
+func TestJWTProvider_KubeKind(t *testing.T) {
+	require.Equal(t, "jwtprovider", (&JWTProvider{}).KubeKind())
+}
+
+func TestJWTProvider_ConsulName(t *testing.T) {
+	require.Equal(t, "foo", (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).ConsulName())
+}
+
+func TestJWTProvider_KubernetesName(t *testing.T) {
+	require.Equal(t, "foo", (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).KubernetesName())
+}
+
+func TestJWTProvider_ConsulNamespace(t *testing.T) {
+	require.Equal(t, common.DefaultConsulNamespace, (&JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}).ConsulMirroringNS())
+}
+
+func TestJWTProvider_ConsulGlobalResource(t *testing.T) {
+	require.True(t, (&JWTProvider{}).ConsulGlobalResource())
+}
+
+func TestJWTProvider_ObjectMeta(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:      "name",
+		Namespace: "namespace",
+	}
+	jwtProvider := &JWTProvider{
+		ObjectMeta: meta,
+	}
+	require.Equal(t, meta, jwtProvider.GetObjectMeta())
+}
@@ -0,0 +1,102 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateJWTProvider(t *testing.T) {
+	provider := func(namespace, name string) *JWTProvider {
+		return &JWTProvider{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: JWTProviderSpec{
+				JSONWebKeySet: &JSONWebKeySet{
+					Remote: &RemoteJWKS{URI: "https://jwks.example.com"},
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *JWTProvider
+		consulMeta        common.ConsulMeta
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"valid": {
+			existingResources: []runtime.Object{provider("default", "other")},
+			newResource:       provider("default", "okta"),
+			expAllow:          true,
+		},
+		"name exists in another namespace": {
+			existingResources: []runtime.Object{provider("other", "okta")},
+			newResource:       provider("default", "okta"),
+			expAllow:          false,
+			expErrMessage:     "jwtprovider resource with name \"okta\" is already defined in namespace \"other\" – all jwtprovider resources must have unique names across namespaces",
+		},
+		"name exists in another namespace with mirroring": {
+			existingResources: []runtime.Object{provider("other", "okta")},
+			newResource:       provider("default", "okta"),
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+			},
+			expAllow:      false,
+			expErrMessage: "jwtprovider resource with name \"okta\" is already defined in namespace \"other\" – all jwtprovider resources must have unique names across namespaces",
+		},
+		"invalid": {
+			newResource:   &JWTProvider{ObjectMeta: metav1.ObjectMeta{Name: "okta", Namespace: "default"}},
+			expAllow:      false,
+			expErrMessage: "jwtprovider.consul.hashicorp.com \"okta\" is invalid: spec.jsonWebKeySet: Required value: jsonWebKeySet is required",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &JWTProvider{}, &JWTProviderList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &JWTProviderWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+				ConsulMeta:   c.consulMeta,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: c.newResource.Namespace,
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
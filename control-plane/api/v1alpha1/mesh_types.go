@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	"fmt"
+
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
@@ -50,6 +52,10 @@ type MeshSpec struct {
 	TransparentProxy TransparentProxyMeshConfig `json:"transparentProxy,omitempty"`
 	// TLS defines the TLS configuration for the service mesh.
 	TLS *MeshTLSConfig `json:"tls,omitempty"`
+	// HTTP defines the HTTP configuration for the service mesh.
+	HTTP *MeshHTTPConfig `json:"http,omitempty"`
+	// Peering defines the peering configuration for the service mesh.
+	Peering *PeeringMeshConfig `json:"peering,omitempty"`
 }
 
 // TransparentProxyMeshConfig controls configuration specific to proxies in "transparent" mode. Added in v1.10.0.
@@ -87,6 +93,22 @@ type MeshDirectionalTLSConfig struct {
 	CipherSuites []string `json:"cipherSuites,omitempty"`
 }
 
+type MeshHTTPConfig struct {
+	// SanitizeXForwardedClientCert determines whether the X-Forwarded-Client-Cert
+	// header is removed from requests forwarded by the proxies instead of
+	// being appended to.
+	SanitizeXForwardedClientCert bool `json:"sanitizeXForwardedClientCert,omitempty"`
+}
+
+type PeeringMeshConfig struct {
+	// PeerThroughMeshGateways determines whether peering traffic between
+	// control planes should flow through mesh gateways. If enabled,
+	// Consul servers will advertise mesh gateway addresses as their own.
+	// Additionally, mesh gateways will configure themselves to expose
+	// the local servers using a peering-specific SNI.
+	PeerThroughMeshGateways bool `json:"peerThroughMeshGateways,omitempty"`
+}
+
 func (in *TransparentProxyMeshConfig) toConsul() capi.TransparentProxyMeshConfig {
 	return capi.TransparentProxyMeshConfig{MeshDestinationsOnly: in.MeshDestinationsOnly}
 }
@@ -155,15 +177,7 @@ func (in *Mesh) KubernetesName() string {
 }
 
 func (in *Mesh) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *Mesh) SetLastSyncedTime(time *metav1.Time) {
@@ -174,6 +188,8 @@ func (in *Mesh) ToConsul(datacenter string) capi.ConfigEntry {
 	return &capi.MeshConfigEntry{
 		TransparentProxy: in.Spec.TransparentProxy.toConsul(),
 		TLS:              in.Spec.TLS.toConsul(),
+		HTTP:             in.Spec.HTTP.toConsul(),
+		Peering:          in.Spec.Peering.toConsul(),
 		Meta:             meta(datacenter),
 	}
 }
@@ -187,11 +203,11 @@ func (in *Mesh) MatchesConsul(candidate capi.ConfigEntry) bool {
 	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.MeshConfigEntry{}, "Partition", "Namespace", "Meta", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
 }
 
-func (in *Mesh) Validate(_ common.ConsulMeta) error {
+func (in *Mesh) Validate(consulMeta common.ConsulMeta) error {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
 
-	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"))...)
+	errs = append(errs, in.Spec.TLS.validate(path.Child("tls"), consulMeta)...)
 
 	if len(errs) > 0 {
 		return apierrors.NewInvalid(
@@ -211,18 +227,18 @@ func (in *MeshTLSConfig) toConsul() *capi.MeshTLSConfig {
 	}
 }
 
-func (in *MeshTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *MeshTLSConfig) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
 	if in == nil {
 		return nil
 	}
 
 	var errs field.ErrorList
-	errs = append(errs, in.Incoming.validate(path.Child("incoming"))...)
-	errs = append(errs, in.Outgoing.validate(path.Child("outgoing"))...)
+	errs = append(errs, in.Incoming.validate(path.Child("incoming"), consulMeta)...)
+	errs = append(errs, in.Outgoing.validate(path.Child("outgoing"), consulMeta)...)
 	return errs
 }
 
-func (in *MeshDirectionalTLSConfig) validate(path *field.Path) field.ErrorList {
+func (in *MeshDirectionalTLSConfig) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
 	if in == nil {
 		return nil
 	}
@@ -236,6 +252,17 @@ func (in *MeshDirectionalTLSConfig) validate(path *field.Path) field.ErrorList {
 	if !sliceContains(versions, in.TLSMinVersion) {
 		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), in.TLSMinVersion, notInSliceMessage(versions)))
 	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// The versions are ordered, with TLS_AUTO and unset not constraining the other.
+	if in.TLSMinVersion != "" && in.TLSMinVersion != "TLS_AUTO" && in.TLSMaxVersion != "" && in.TLSMaxVersion != "TLS_AUTO" &&
+		in.TLSMinVersion > in.TLSMaxVersion {
+		errs = append(errs, field.Invalid(path.Child("tlsMaxVersion"), in.TLSMaxVersion,
+			fmt.Sprintf("must be greater than or equal to tlsMinVersion %q", in.TLSMinVersion)))
+	}
+	errs = append(errs, validateTLSPolicy(path, in.TLSMinVersion, in.CipherSuites, consulMeta)...)
 	return errs
 }
 
@@ -250,6 +277,44 @@ func (in *MeshDirectionalTLSConfig) toConsul() *capi.MeshDirectionalTLSConfig {
 	}
 }
 
+func (in *MeshHTTPConfig) toConsul() *capi.MeshHTTPConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.MeshHTTPConfig{
+		SanitizeXForwardedClientCert: in.SanitizeXForwardedClientCert,
+	}
+}
+
+func (in *PeeringMeshConfig) toConsul() *capi.PeeringMeshConfig {
+	if in == nil {
+		return nil
+	}
+	return &capi.PeeringMeshConfig{
+		PeerThroughMeshGateways: in.PeerThroughMeshGateways,
+	}
+}
+
 // DefaultNamespaceFields has no behaviour here as meshes have no namespace specific fields.
 func (in *Mesh) DefaultNamespaceFields(_ common.ConsulMeta) {
 }
+
+// DefaultTLSFields sets the TLS settings of incoming and outgoing mTLS
+// connections that aren't set to the TLS settings of the installation.
+func (in *Mesh) DefaultTLSFields(consulMeta common.ConsulMeta) {
+	if consulMeta.TLSMinVersion == "" && len(consulMeta.TLSCipherSuites) == 0 {
+		return
+	}
+	if in.Spec.TLS == nil {
+		in.Spec.TLS = &MeshTLSConfig{}
+	}
+	if in.Spec.TLS.Incoming == nil {
+		in.Spec.TLS.Incoming = &MeshDirectionalTLSConfig{}
+	}
+	if in.Spec.TLS.Outgoing == nil {
+		in.Spec.TLS.Outgoing = &MeshDirectionalTLSConfig{}
+	}
+	for _, tls := range []*MeshDirectionalTLSConfig{in.Spec.TLS.Incoming, in.Spec.TLS.Outgoing} {
+		defaultTLSPolicy(&tls.TLSMinVersion, &tls.CipherSuites, consulMeta)
+	}
+}
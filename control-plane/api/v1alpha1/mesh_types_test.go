@@ -145,6 +145,12 @@ func TestMesh_ToConsul(t *testing.T) {
 							CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
 						},
 					},
+					HTTP: &MeshHTTPConfig{
+						SanitizeXForwardedClientCert: true,
+					},
+					Peering: &PeeringMeshConfig{
+						PeerThroughMeshGateways: true,
+					},
 				},
 			},
 			Exp: &capi.MeshConfigEntry{
@@ -163,6 +169,12 @@ func TestMesh_ToConsul(t *testing.T) {
 						CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
 					},
 				},
+				HTTP: &capi.MeshHTTPConfig{
+					SanitizeXForwardedClientCert: true,
+				},
+				Peering: &capi.PeeringMeshConfig{
+					PeerThroughMeshGateways: true,
+				},
 				Namespace: "",
 				Meta: map[string]string{
 					common.SourceKey:     common.SourceValue,
@@ -309,6 +321,39 @@ func TestMesh_Validate(t *testing.T) {
 				`spec.tls.outgoing.tlsMaxVersion: Invalid value: "bar": must be one of "TLS_AUTO", "TLSv1_0", "TLSv1_1", "TLSv1_2", "TLSv1_3", ""`,
 			},
 		},
+		"tls.incoming.tlsMaxVersion less than tlsMinVersion": {
+			input: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: MeshSpec{
+					TLS: &MeshTLSConfig{
+						Incoming: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_2",
+							TLSMaxVersion: "TLSv1_1",
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.tls.incoming.tlsMaxVersion: Invalid value: "TLSv1_1": must be greater than or equal to tlsMinVersion "TLSv1_2"`,
+			},
+		},
+		"tls versions with TLS_AUTO": {
+			input: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: MeshSpec{
+					TLS: &MeshTLSConfig{
+						Outgoing: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_3",
+							TLSMaxVersion: "TLS_AUTO",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for name, testCase := range cases {
@@ -432,3 +477,42 @@ func TestMesh_ObjectMeta(t *testing.T) {
 	}
 	require.Equal(t, meta, mesh.GetObjectMeta())
 }
+
+func TestMesh_TLSPolicy(t *testing.T) {
+	consulMeta := common.ConsulMeta{
+		TLSMinVersion:   "TLSv1_2",
+		TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	}
+
+	mesh := &Mesh{ObjectMeta: metav1.ObjectMeta{Name: "name"}}
+	mesh.DefaultTLSFields(consulMeta)
+	expected := &MeshDirectionalTLSConfig{
+		TLSMinVersion: "TLSv1_2",
+		CipherSuites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+	}
+	require.Equal(t, &MeshTLSConfig{Incoming: expected, Outgoing: expected}, mesh.Spec.TLS)
+	require.NoError(t, mesh.Validate(consulMeta))
+
+	// Settings that are already set aren't defaulted, and cipher suites
+	// aren't defaulted for TLS 1.3.
+	mesh = &Mesh{
+		ObjectMeta: metav1.ObjectMeta{Name: "name"},
+		Spec: MeshSpec{
+			TLS: &MeshTLSConfig{
+				Incoming: &MeshDirectionalTLSConfig{TLSMinVersion: "TLSv1_3"},
+				Outgoing: &MeshDirectionalTLSConfig{TLSMinVersion: "TLSv1_1", CipherSuites: []string{"TLS_RSA_WITH_AES_128_CBC_SHA"}},
+			},
+		},
+	}
+	mesh.DefaultTLSFields(consulMeta)
+	require.Equal(t, &MeshDirectionalTLSConfig{TLSMinVersion: "TLSv1_3"}, mesh.Spec.TLS.Incoming)
+	err := mesh.Validate(consulMeta)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `spec.tls.outgoing.tlsMinVersion: Invalid value: "TLSv1_1": must be at least "TLSv1_2"`)
+	require.Contains(t, err.Error(), `spec.tls.outgoing.cipherSuites[0]: Invalid value: "TLS_RSA_WITH_AES_128_CBC_SHA": must be one of "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"`)
+
+	// Nothing is defaulted without TLS settings for the installation.
+	mesh = &Mesh{ObjectMeta: metav1.ObjectMeta{Name: "name"}}
+	mesh.DefaultTLSFields(common.ConsulMeta{})
+	require.Nil(t, mesh.Spec.TLS)
+}
@@ -20,6 +20,7 @@ type MeshWebhook struct {
 	ConsulClient *capi.Client
 	Logger       logr.Logger
 	decoder      *admission.Decoder
+	ConsulMeta   common.ConsulMeta
 }
 
 // NOTE: The path value in the below line is the path to the webhook.
@@ -59,7 +60,16 @@ func (v *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		}
 	}
 
-	return admission.Allowed(fmt.Sprintf("valid %s request", mesh.KubeKind()))
+	defaultingPatches, err := common.DefaultingPatches(&mesh, v.ConsulMeta)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if err := mesh.Validate(v.ConsulMeta); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	return admission.Patched(fmt.Sprintf("valid %s request", mesh.KubeKind()), defaultingPatches...)
 }
 
 func (v *MeshWebhook) InjectDecoder(d *admission.Decoder) error {
@@ -63,6 +63,55 @@ func TestValidateMesh(t *testing.T) {
 			expAllow:      false,
 			expErrMessage: "mesh resource name must be \"mesh\"",
 		},
+		"mesh exists in another namespace": {
+			existingResources: []runtime.Object{&Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      common.Mesh,
+					Namespace: "default",
+				},
+			}},
+			newResource: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.Mesh,
+				},
+			},
+			expAllow:      false,
+			expErrMessage: "mesh resource already defined - only one mesh entry is supported",
+		},
+		"invalid tls": {
+			existingResources: []runtime.Object{},
+			newResource: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.Mesh,
+				},
+				Spec: MeshSpec{
+					TLS: &MeshTLSConfig{
+						Incoming: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "foo",
+						},
+					},
+				},
+			},
+			expAllow:      false,
+			expErrMessage: "mesh.consul.hashicorp.com \"mesh\" is invalid: spec.tls.incoming.tlsMinVersion: Invalid value: \"foo\": must be one of \"TLS_AUTO\", \"TLSv1_0\", \"TLSv1_1\", \"TLSv1_2\", \"TLSv1_3\", \"\"",
+		},
+		"http and peering": {
+			existingResources: []runtime.Object{},
+			newResource: &Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.Mesh,
+				},
+				Spec: MeshSpec{
+					HTTP: &MeshHTTPConfig{
+						SanitizeXForwardedClientCert: true,
+					},
+					Peering: &PeeringMeshConfig{
+						PeerThroughMeshGateways: true,
+					},
+				},
+			},
+			expAllow: true,
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -99,3 +148,45 @@ func TestValidateMesh(t *testing.T) {
 		})
 	}
 }
+
+// Test that the TLS settings of a mesh default to those of the installation.
+func TestHandle_Mesh_TLSPolicyPatches(t *testing.T) {
+	ctx := context.Background()
+	mesh := &Mesh{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: common.Mesh,
+		},
+	}
+	marshalledRequestObject, err := json.Marshal(mesh)
+	require.NoError(t, err)
+	s := runtime.NewScheme()
+	s.AddKnownTypes(GroupVersion, &Mesh{}, &MeshList{})
+	client := fake.NewClientBuilder().WithScheme(s).Build()
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	validator := &MeshWebhook{
+		Client:     client,
+		Logger:     logrtest.TestLogger{T: t},
+		decoder:    decoder,
+		ConsulMeta: common.ConsulMeta{TLSMinVersion: "TLSv1_2"},
+	}
+	response := validator.Handle(ctx, admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      mesh.KubernetesName(),
+			Namespace: "default",
+			Operation: admissionv1.Create,
+			Object: runtime.RawExtension{
+				Raw: marshalledRequestObject,
+			},
+		},
+	})
+
+	require.True(t, response.Allowed)
+	require.Len(t, response.Patches, 1)
+	require.Equal(t, "/spec/tls", response.Patches[0].Path)
+	require.Equal(t, map[string]interface{}{
+		"incoming": map[string]interface{}{"tlsMinVersion": "TLSv1_2"},
+		"outgoing": map[string]interface{}{"tlsMinVersion": "TLSv1_2"},
+	}, response.Patches[0].Value)
+}
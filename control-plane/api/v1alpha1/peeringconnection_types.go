@@ -0,0 +1,206 @@
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	PeeringConnectionKubeKind = "peeringconnection"
+
+	// PeeringRoleAcceptor is the role of the side of a peering that generates
+	// the peering token.
+	PeeringRoleAcceptor = "acceptor"
+	// PeeringRoleDialer is the role of the side of a peering that establishes
+	// the peering with the token of the acceptor.
+	PeeringRoleDialer = "dialer"
+
+	// DefaultPeeringTokenKey is the key of the peering token in the token
+	// secret if it isn't set.
+	DefaultPeeringTokenKey = "token"
+	// DefaultKubeconfigKey is the key of the kubeconfig in the kubeconfig
+	// secret of the remote cluster if it isn't set.
+	DefaultKubeconfigKey = "kubeconfig"
+)
+
+func init() {
+	SchemeBuilder.Register(&PeeringConnection{}, &PeeringConnectionList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// PeeringConnection is the Schema for the peeringconnections API. It
+// establishes a cluster peering between the Consul datacenter of this cluster
+// and the Consul datacenter of another cluster.
+// +kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.role",description="The role of this side of the peering"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state",description="The state of the peering in Consul"
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="peering-connection"
+type PeeringConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PeeringConnectionSpec   `json:"spec,omitempty"`
+	Status PeeringConnectionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PeeringConnectionList contains a list of PeeringConnection.
+type PeeringConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PeeringConnection `json:"items"`
+}
+
+// PeeringConnectionSpec defines the desired state of PeeringConnection.
+type PeeringConnectionSpec struct {
+	// PeerName is the name of the peering in Consul, i.e. the name that the
+	// other datacenter is known by in this one. Defaults to the name of the
+	// resource.
+	PeerName string `json:"peerName,omitempty"`
+	// Role is the role of this side of the peering, either "acceptor" or
+	// "dialer". The acceptor generates the peering token and writes it to
+	// TokenSecret. The dialer reads the token from TokenSecret, in the remote
+	// cluster if Remote is set, and establishes the peering with it.
+	// +kubebuilder:validation:Enum=acceptor;dialer
+	Role string `json:"role"`
+	// TokenSecret is the secret that holds the peering token.
+	TokenSecret PeeringSecretRef `json:"tokenSecret"`
+	// Remote is the cluster of the acceptor that the dialer reads the
+	// peering token from. If it isn't set, the dialer reads the token from
+	// TokenSecret in the namespace of this resource, e.g. when the token is
+	// copied to this cluster out of band.
+	Remote *PeeringRemote `json:"remote,omitempty"`
+	// ServerExternalAddresses are the addresses, in the form <host>:<port>,
+	// that the dialer connects to the Consul servers of the acceptor on.
+	// They are added to the token instead of the addresses of the servers,
+	// e.g. when the servers are behind a load balancer. They can only be set
+	// on the acceptor. When the mesh is configured with
+	// `peering.peerThroughMeshGateways`, the dialer connects through the mesh
+	// gateways instead.
+	ServerExternalAddresses []string `json:"serverExternalAddresses,omitempty"`
+	// ExportedServices are the services of this datacenter that are exported
+	// to the peer. They are added to the ExportedServices resource of the
+	// partition, which is created if it doesn't exist.
+	ExportedServices []PeeringExportedService `json:"exportedServices,omitempty"`
+}
+
+// PeeringSecretRef is a key of a Kubernetes secret.
+type PeeringSecretRef struct {
+	// Name is the name of the secret.
+	Name string `json:"name"`
+	// Key is the key of the secret.
+	Key string `json:"key,omitempty"`
+}
+
+// PeeringRemote is a Kubernetes cluster that the peering token is read from.
+type PeeringRemote struct {
+	// KubeconfigSecret is a secret in the namespace of this resource that
+	// holds a kubeconfig with access to the token secret in the remote
+	// cluster. The key defaults to "kubeconfig".
+	KubeconfigSecret PeeringSecretRef `json:"kubeconfigSecret"`
+	// Namespace is the namespace of the token secret in the remote cluster.
+	// Defaults to the namespace of this resource.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PeeringExportedService is a service that is exported to the peer.
+type PeeringExportedService struct {
+	// Name is the name of the service.
+	Name string `json:"name"`
+	// Namespace is the Consul namespace of the service.
+	// Only applicable with Consul Enterprise.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PeeringConnectionStatus defines the observed state of PeeringConnection.
+type PeeringConnectionStatus struct {
+	Status `json:",inline"`
+	// State is the state of the peering in Consul, e.g. ACTIVE or FAILING.
+	// +optional
+	State string `json:"state,omitempty"`
+	// PeerID is the ID of the peering in Consul.
+	// +optional
+	PeerID string `json:"peerID,omitempty"`
+	// TokenHash is the SHA-256 hash of the peering token that the peering was
+	// last generated or established with. The dialer re-establishes the
+	// peering when the token changes.
+	// +optional
+	TokenHash string `json:"tokenHash,omitempty"`
+}
+
+// ConsulPeerName returns the name of the peering in Consul.
+func (in *PeeringConnection) ConsulPeerName() string {
+	if in.Spec.PeerName != "" {
+		return in.Spec.PeerName
+	}
+	return in.Name
+}
+
+// TokenKey returns the key of the peering token in the token secret.
+func (in *PeeringConnection) TokenKey() string {
+	if in.Spec.TokenSecret.Key != "" {
+		return in.Spec.TokenSecret.Key
+	}
+	return DefaultPeeringTokenKey
+}
+
+// RemoteNamespace returns the namespace of the token secret in the remote
+// cluster.
+func (in *PeeringConnection) RemoteNamespace() string {
+	if in.Spec.Remote != nil && in.Spec.Remote.Namespace != "" {
+		return in.Spec.Remote.Namespace
+	}
+	return in.Namespace
+}
+
+// KubeconfigKey returns the key of the kubeconfig in the kubeconfig secret
+// of the remote cluster.
+func (in *PeeringConnection) KubeconfigKey() string {
+	if in.Spec.Remote != nil && in.Spec.Remote.KubeconfigSecret.Key != "" {
+		return in.Spec.Remote.KubeconfigSecret.Key
+	}
+	return DefaultKubeconfigKey
+}
+
+func (in *PeeringConnection) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	switch in.Spec.Role {
+	case PeeringRoleAcceptor:
+		if in.Spec.Remote != nil {
+			errs = append(errs, field.Forbidden(path.Child("remote"), "remote can only be set on the dialer"))
+		}
+	case PeeringRoleDialer:
+		if len(in.Spec.ServerExternalAddresses) > 0 {
+			errs = append(errs, field.Forbidden(path.Child("serverExternalAddresses"), "serverExternalAddresses can only be set on the acceptor"))
+		}
+		if in.Spec.Remote != nil && in.Spec.Remote.KubeconfigSecret.Name == "" {
+			errs = append(errs, field.Required(path.Child("remote").Child("kubeconfigSecret").Child("name"), "the kubeconfig secret of the remote cluster must be set"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("role"), in.Spec.Role, []string{PeeringRoleAcceptor, PeeringRoleDialer}))
+	}
+	if in.Spec.TokenSecret.Name == "" {
+		errs = append(errs, field.Required(path.Child("tokenSecret").Child("name"), "the token secret must be set"))
+	}
+	for i, svc := range in.Spec.ExportedServices {
+		if svc.Name == "" {
+			errs = append(errs, field.Required(path.Child("exportedServices").Index(i).Child("name"), "the name of the service must be set"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: PeeringConnectionKubeKind},
+			in.Name, errs)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPeeringConnection_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input          *PeeringConnection
+		expectedErrMsg string
+	}{
+		"valid acceptor": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:                    PeeringRoleAcceptor,
+					TokenSecret:             PeeringSecretRef{Name: "token"},
+					ServerExternalAddresses: []string{"1.2.3.4:8503"},
+					ExportedServices:        []PeeringExportedService{{Name: "backend"}},
+				},
+			},
+		},
+		"valid dialer": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:        PeeringRoleDialer,
+					TokenSecret: PeeringSecretRef{Name: "token"},
+					Remote: &PeeringRemote{
+						KubeconfigSecret: PeeringSecretRef{Name: "kubeconfig"},
+					},
+				},
+			},
+		},
+		"invalid role": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:        "listener",
+					TokenSecret: PeeringSecretRef{Name: "token"},
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.role: Unsupported value: "listener": supported values: "acceptor", "dialer"`,
+		},
+		"no token secret": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role: PeeringRoleAcceptor,
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.tokenSecret.name: Required value: the token secret must be set`,
+		},
+		"remote on acceptor": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:        PeeringRoleAcceptor,
+					TokenSecret: PeeringSecretRef{Name: "token"},
+					Remote: &PeeringRemote{
+						KubeconfigSecret: PeeringSecretRef{Name: "kubeconfig"},
+					},
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.remote: Forbidden: remote can only be set on the dialer`,
+		},
+		"server external addresses on dialer": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:                    PeeringRoleDialer,
+					TokenSecret:             PeeringSecretRef{Name: "token"},
+					ServerExternalAddresses: []string{"1.2.3.4:8503"},
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.serverExternalAddresses: Forbidden: serverExternalAddresses can only be set on the acceptor`,
+		},
+		"remote without kubeconfig secret": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:        PeeringRoleDialer,
+					TokenSecret: PeeringSecretRef{Name: "token"},
+					Remote:      &PeeringRemote{Namespace: "consul"},
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.remote.kubeconfigSecret.name: Required value: the kubeconfig secret of the remote cluster must be set`,
+		},
+		"exported service without name": {
+			input: &PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: PeeringConnectionSpec{
+					Role:             PeeringRoleAcceptor,
+					TokenSecret:      PeeringSecretRef{Name: "token"},
+					ExportedServices: []PeeringExportedService{{Namespace: "ns1"}},
+				},
+			},
+			expectedErrMsg: `peeringconnection.consul.hashicorp.com "name" is invalid: spec.exportedServices[0].name: Required value: the name of the service must be set`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.input.Validate()
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPeeringConnection_Defaults(t *testing.T) {
+	conn := &PeeringConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "default"},
+		Spec: PeeringConnectionSpec{
+			Role:        PeeringRoleDialer,
+			TokenSecret: PeeringSecretRef{Name: "token"},
+			Remote:      &PeeringRemote{},
+		},
+	}
+	require.Equal(t, "name", conn.ConsulPeerName())
+	require.Equal(t, DefaultPeeringTokenKey, conn.TokenKey())
+	require.Equal(t, DefaultKubeconfigKey, conn.KubeconfigKey())
+	require.Equal(t, "default", conn.RemoteNamespace())
+
+	conn.Spec.PeerName = "peer"
+	conn.Spec.TokenSecret.Key = "peering-token"
+	conn.Spec.Remote = &PeeringRemote{
+		KubeconfigSecret: PeeringSecretRef{Name: "kubeconfig", Key: "config"},
+		Namespace:        "consul",
+	}
+	require.Equal(t, "peer", conn.ConsulPeerName())
+	require.Equal(t, "peering-token", conn.TokenKey())
+	require.Equal(t, "config", conn.KubeconfigKey())
+	require.Equal(t, "consul", conn.RemoteNamespace())
+}
@@ -75,6 +75,11 @@ type ProxyDefaultsSpec struct {
 	MeshGateway MeshGateway `json:"meshGateway,omitempty"`
 	// Expose controls the default expose path configuration for Envoy.
 	Expose Expose `json:"expose,omitempty"`
+	// PrioritizeByLocality controls whether the locality of the instances of
+	// the services in the local partition is used to prioritize them. It's
+	// the default of all services and can be overridden in their
+	// ServiceResolvers.
+	PrioritizeByLocality *ServiceResolverPrioritizeByLocality `json:"prioritizeByLocality,omitempty"`
 }
 
 func (in *ProxyDefaults) GetObjectMeta() metav1.ObjectMeta {
@@ -141,15 +146,7 @@ func (in *ProxyDefaults) KubernetesName() string {
 }
 
 func (in *ProxyDefaults) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *ProxyDefaults) SetLastSyncedTime(time *metav1.Time) {
@@ -159,13 +156,14 @@ func (in *ProxyDefaults) SetLastSyncedTime(time *metav1.Time) {
 func (in *ProxyDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 	consulConfig := in.convertConfig()
 	return &capi.ProxyConfigEntry{
-		Kind:             in.ConsulKind(),
-		Name:             in.ConsulName(),
-		MeshGateway:      in.Spec.MeshGateway.toConsul(),
-		Expose:           in.Spec.Expose.toConsul(),
-		Config:           consulConfig,
-		TransparentProxy: in.Spec.TransparentProxy.toConsul(),
-		Meta:             meta(datacenter),
+		Kind:                 in.ConsulKind(),
+		Name:                 in.ConsulName(),
+		MeshGateway:          in.Spec.MeshGateway.toConsul(),
+		Expose:               in.Spec.Expose.toConsul(),
+		Config:               consulConfig,
+		TransparentProxy:     in.Spec.TransparentProxy.toConsul(),
+		PrioritizeByLocality: in.Spec.PrioritizeByLocality.toConsul(),
+		Meta:                 meta(datacenter),
 	}
 }
 
@@ -196,6 +194,7 @@ func (in *ProxyDefaults) Validate(_ common.ConsulMeta) error {
 		allErrs = append(allErrs, err)
 	}
 	allErrs = append(allErrs, in.Spec.Expose.validate(path.Child("expose"))...)
+	allErrs = append(allErrs, in.Spec.PrioritizeByLocality.validate(path.Child("prioritizeByLocality"))...)
 	if len(allErrs) > 0 {
 		return apierrors.NewInvalid(
 			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: ProxyDefaultsKubeKind},
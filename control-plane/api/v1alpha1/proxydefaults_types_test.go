@@ -67,6 +67,9 @@ func TestProxyDefaults_MatchesConsul(t *testing.T) {
 							},
 						},
 					},
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "failover",
+					},
 					TransparentProxy: &TransparentProxy{
 						OutboundListenerPort: 1000,
 						DialedDirectly:       true,
@@ -99,6 +102,9 @@ func TestProxyDefaults_MatchesConsul(t *testing.T) {
 						},
 					},
 				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 				TransparentProxy: &capi.TransparentProxyConfig{
 					OutboundListenerPort: 1000,
 					DialedDirectly:       true,
@@ -232,6 +238,9 @@ func TestProxyDefaults_ToConsul(t *testing.T) {
 							},
 						},
 					},
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "failover",
+					},
 					TransparentProxy: &TransparentProxy{
 						OutboundListenerPort: 1000,
 						DialedDirectly:       true,
@@ -265,6 +274,9 @@ func TestProxyDefaults_ToConsul(t *testing.T) {
 						},
 					},
 				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 				TransparentProxy: &capi.TransparentProxyConfig{
 					OutboundListenerPort: 1000,
 					DialedDirectly:       true,
@@ -306,6 +318,19 @@ func TestProxyDefaults_Validate(t *testing.T) {
 			},
 			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.meshGateway.mode: Invalid value: "foobar": must be one of "remote", "local", "none", ""`,
 		},
+		"prioritizeByLocality.mode": {
+			&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "global",
+				},
+				Spec: ProxyDefaultsSpec{
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "zone",
+					},
+				},
+			},
+			`proxydefaults.consul.hashicorp.com "global" is invalid: spec.prioritizeByLocality.mode: Invalid value: "zone": must be one of "none", "failover", ""`,
+		},
 		"expose.paths[].protocol": {
 			&ProxyDefaults{
 				ObjectMeta: metav1.ObjectMeta{
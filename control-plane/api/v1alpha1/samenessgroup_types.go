@@ -0,0 +1,219 @@
+package v1alpha1
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const SamenessGroupKubeKind = "samenessgroup"
+
+func init() {
+	SchemeBuilder.Register(&SamenessGroup{}, &SamenessGroupList{})
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// SamenessGroup is the Schema for the samenessgroups API
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="sameness-group"
+type SamenessGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SamenessGroupSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SamenessGroupList contains a list of SamenessGroup.
+type SamenessGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SamenessGroup `json:"items"`
+}
+
+// SamenessGroupSpec defines the desired state of SamenessGroup.
+type SamenessGroupSpec struct {
+	// DefaultForFailover indicates that upstream requests to members of the
+	// sameness group fail over to the other members when no failover policy
+	// is configured. Only one sameness group of a partition can be the default
+	// for failover.
+	DefaultForFailover bool `json:"defaultForFailover,omitempty"`
+	// IncludeLocal indicates that the local partition is the first member of
+	// the sameness group. Otherwise it must be listed in Members.
+	IncludeLocal bool `json:"includeLocal,omitempty"`
+	// Members are the partitions and peers that are members of the sameness
+	// group, in failover order.
+	Members []SamenessGroupMember `json:"members,omitempty"`
+}
+
+// SamenessGroupMember is a member of a sameness group. Exactly one of
+// Partition or Peer must be set.
+type SamenessGroupMember struct {
+	// Partition is the name of an admin partition of the local datacenter.
+	Partition string `json:"partition,omitempty"`
+	// Peer is the name of a cluster peer.
+	Peer string `json:"peer,omitempty"`
+}
+
+func (in *SamenessGroup) GetObjectMeta() metav1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+func (in *SamenessGroup) AddFinalizer(name string) {
+	in.ObjectMeta.Finalizers = append(in.Finalizers(), name)
+}
+
+func (in *SamenessGroup) RemoveFinalizer(name string) {
+	var newFinalizers []string
+	for _, oldF := range in.Finalizers() {
+		if oldF != name {
+			newFinalizers = append(newFinalizers, oldF)
+		}
+	}
+	in.ObjectMeta.Finalizers = newFinalizers
+}
+
+func (in *SamenessGroup) Finalizers() []string {
+	return in.ObjectMeta.Finalizers
+}
+
+func (in *SamenessGroup) ConsulKind() string {
+	return capi.SamenessGroup
+}
+
+func (in *SamenessGroup) ConsulGlobalResource() bool {
+	return true
+}
+
+func (in *SamenessGroup) ConsulMirroringNS() string {
+	return common.DefaultConsulNamespace
+}
+
+func (in *SamenessGroup) KubeKind() string {
+	return SamenessGroupKubeKind
+}
+
+func (in *SamenessGroup) ConsulName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *SamenessGroup) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *SamenessGroup) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
+	in.Status.setCondition(ConditionSynced, status, reason, message)
+}
+
+func (in *SamenessGroup) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *SamenessGroup) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *SamenessGroup) SyncedConditionStatus() corev1.ConditionStatus {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown
+	}
+	return cond.Status
+}
+
+func (in *SamenessGroup) ToConsul(datacenter string) capi.ConfigEntry {
+	var members []capi.SamenessGroupMember
+	for _, member := range in.Spec.Members {
+		members = append(members, capi.SamenessGroupMember{Partition: member.Partition, Peer: member.Peer})
+	}
+	return &capi.SamenessGroupConfigEntry{
+		Kind:               in.ConsulKind(),
+		Name:               in.ConsulName(),
+		DefaultForFailover: in.Spec.DefaultForFailover,
+		IncludeLocal:       in.Spec.IncludeLocal,
+		Members:            members,
+		Meta:               meta(datacenter),
+	}
+}
+
+func (in *SamenessGroup) MatchesConsul(candidate capi.ConfigEntry) bool {
+	configEntry, ok := candidate.(*capi.SamenessGroupConfigEntry)
+	if !ok {
+		return false
+	}
+	// No datacenter is passed to ToConsul as we ignore the Meta field when checking for equality.
+	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.SamenessGroupConfigEntry{}, "Partition", "Meta", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
+}
+
+func (in *SamenessGroup) Validate(consulMeta common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+	if !consulMeta.PartitionsEnabled {
+		errs = append(errs, field.Forbidden(field.NewPath("kind"), "Consul Enterprise Admin Partitions must be enabled to create a sameness group"))
+	}
+	if len(in.Spec.Members) == 0 && !in.Spec.IncludeLocal {
+		errs = append(errs, field.Required(path.Child("members"), "sameness group must have at least one member or include the local partition"))
+	}
+
+	partitions := make(map[string]bool)
+	peers := make(map[string]bool)
+	for i, member := range in.Spec.Members {
+		memberPath := path.Child("members").Index(i)
+		if err := member.validate(memberPath); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if member.Partition != "" {
+			if partitions[member.Partition] {
+				errs = append(errs, field.Duplicate(memberPath.Child("partition"), member.Partition))
+			}
+			partitions[member.Partition] = true
+			// The local partition is already the first member when it is
+			// included, so it can't be listed again.
+			if in.Spec.IncludeLocal && consulMeta.PartitionsEnabled && member.Partition == consulMeta.Partition {
+				errs = append(errs, field.Invalid(memberPath.Child("partition"), member.Partition, "the local partition must not be a member when includeLocal is true"))
+			}
+		} else {
+			if peers[member.Peer] {
+				errs = append(errs, field.Duplicate(memberPath.Child("peer"), member.Peer))
+			}
+			peers[member.Peer] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: SamenessGroupKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in *SamenessGroupMember) validate(path *field.Path) *field.Error {
+	if in.Partition == "" && in.Peer == "" {
+		return field.Required(path, "sameness group member must define at least one of Partition or Peer")
+	}
+	if in.Partition != "" && in.Peer != "" {
+		return field.Invalid(path, *in, "sameness group member must define at most one of Partition or Peer")
+	}
+	return nil
+}
+
+func (in *SamenessGroup) DefaultNamespaceFields(_ common.ConsulMeta) {
+}
@@ -0,0 +1,347 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSamenessGroup_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    SamenessGroup
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.SamenessGroupConfigEntry{
+				Kind:        capi.SamenessGroup,
+				Name:        "name",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: SamenessGroupSpec{
+					DefaultForFailover: true,
+					IncludeLocal:       true,
+					Members: []SamenessGroupMember{
+						{Partition: "other"},
+						{Peer: "peer"},
+					},
+				},
+			},
+			Theirs: &capi.SamenessGroupConfigEntry{
+				Kind:               capi.SamenessGroup,
+				Name:               "name",
+				Partition:          "local",
+				DefaultForFailover: true,
+				IncludeLocal:       true,
+				Members: []capi.SamenessGroupMember{
+					{Partition: "other"},
+					{Peer: "peer"},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"member order does not match": {
+			Ours: SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{
+						{Partition: "other"},
+						{Peer: "peer"},
+					},
+				},
+			},
+			Theirs: &capi.SamenessGroupConfigEntry{
+				Kind: capi.SamenessGroup,
+				Name: "name",
+				Members: []capi.SamenessGroupMember{
+					{Peer: "peer"},
+					{Partition: "other"},
+				},
+			},
+			Matches: false,
+		},
+		"mismatched types does not match": {
+			Ours: SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.ServiceConfigEntry{
+				Name: "name",
+				Kind: capi.SamenessGroup,
+			},
+			Matches: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestSamenessGroup_ToConsul(t *testing.T) {
+	samenessGroup := &SamenessGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "name",
+		},
+		Spec: SamenessGroupSpec{
+			DefaultForFailover: true,
+			Members: []SamenessGroupMember{
+				{Partition: "local"},
+				{Peer: "peer"},
+			},
+		},
+	}
+	require.Equal(t, &capi.SamenessGroupConfigEntry{
+		Kind:               capi.SamenessGroup,
+		Name:               "name",
+		DefaultForFailover: true,
+		Members: []capi.SamenessGroupMember{
+			{Partition: "local"},
+			{Peer: "peer"},
+		},
+		Meta: map[string]string{
+			common.SourceKey:     common.SourceValue,
+			common.DatacenterKey: "datacenter",
+		},
+	}, samenessGroup.ToConsul("datacenter"))
+}
+
+func TestSamenessGroup_Validate(t *testing.T) {
+	consulMeta := common.ConsulMeta{
+		PartitionsEnabled: true,
+		Partition:         "local",
+	}
+	cases := map[string]struct {
+		input          *SamenessGroup
+		consulMeta     common.ConsulMeta
+		expectedErrMsg string
+	}{
+		"valid": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					IncludeLocal: true,
+					Members: []SamenessGroupMember{
+						{Partition: "other"},
+						{Peer: "peer"},
+					},
+				},
+			},
+			consulMeta: consulMeta,
+		},
+		"valid, local partition listed": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{
+						{Partition: "local"},
+						{Peer: "peer"},
+					},
+				},
+			},
+			consulMeta: consulMeta,
+		},
+		"partitions disabled": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{{Peer: "peer"}},
+				},
+			},
+			consulMeta:     common.ConsulMeta{},
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: kind: Forbidden: Consul Enterprise Admin Partitions must be enabled to create a sameness group`,
+		},
+		"no members": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+			},
+			consulMeta:     consulMeta,
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: spec.members: Required value: sameness group must have at least one member or include the local partition`,
+		},
+		"member without partition or peer": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{{}},
+				},
+			},
+			consulMeta:     consulMeta,
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: spec.members[0]: Required value: sameness group member must define at least one of Partition or Peer`,
+		},
+		"member with partition and peer": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{{Partition: "other", Peer: "peer"}},
+				},
+			},
+			consulMeta:     consulMeta,
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: spec.members[0]: Invalid value: v1alpha1.SamenessGroupMember{Partition:"other", Peer:"peer"}: sameness group member must define at most one of Partition or Peer`,
+		},
+		"duplicate members": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					Members: []SamenessGroupMember{
+						{Partition: "other"},
+						{Peer: "peer"},
+						{Partition: "other"},
+						{Peer: "peer"},
+					},
+				},
+			},
+			consulMeta:     consulMeta,
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: [spec.members[2].partition: Duplicate value: "other", spec.members[3].peer: Duplicate value: "peer"]`,
+		},
+		"local partition listed with includeLocal": {
+			input: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "name"},
+				Spec: SamenessGroupSpec{
+					IncludeLocal: true,
+					Members:      []SamenessGroupMember{{Partition: "local"}},
+				},
+			},
+			consulMeta:     consulMeta,
+			expectedErrMsg: `samenessgroup.consul.hashicorp.com "name" is invalid: spec.members[0].partition: Invalid value: "local": the local partition must not be a member when includeLocal is true`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.input.Validate(c.consulMeta)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSamenessGroup_AddFinalizer(t *testing.T) {
+	samenessGroup := &SamenessGroup{}
+	samenessGroup.AddFinalizer("finalizer")
+	require.Equal(t, []string{"finalizer"}, samenessGroup.ObjectMeta.Finalizers)
+}
+
+func TestSamenessGroup_RemoveFinalizer(t *testing.T) {
+	samenessGroup := &SamenessGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Finalizers: []string{"f1", "f2"},
+		},
+	}
+	samenessGroup.RemoveFinalizer("f1")
+	require.Equal(t, []string{"f2"}, samenessGroup.ObjectMeta.Finalizers)
+}
+
+func TestSamenessGroup_SetSyncedCondition(t *testing.T) {
+	samenessGroup := &SamenessGroup{}
+	samenessGroup.SetSyncedCondition(corev1.ConditionTrue, "reason", "message")
+
+	require.Equal(t, corev1.ConditionTrue, samenessGroup.Status.Conditions[0].Status)
+	require.Equal(t, "reason", samenessGroup.Status.Conditions[0].Reason)
+	require.Equal(t, "message", samenessGroup.Status.Conditions[0].Message)
+	now := metav1.Now()
+	require.True(t, samenessGroup.Status.Conditions[0].LastTransitionTime.Before(&now))
+}
+
+func TestSamenessGroup_SetLastSyncedTime(t *testing.T) {
+	samenessGroup := &SamenessGroup{}
+	syncedTime := metav1.NewTime(time.Now())
+	samenessGroup.SetLastSyncedTime(&syncedTime)
+
+	require.Equal(t, &syncedTime, samenessGroup.Status.LastSyncedTime)
+}
+
+func TestSamenessGroup_GetSyncedConditionStatus(t *testing.T) {
+	cases := []corev1.ConditionStatus{
+		corev1.ConditionUnknown,
+		corev1.ConditionFalse,
+		corev1.ConditionTrue,
+	}
+	for _, status := range cases {
+		t.Run(string(status), func(t *testing.T) {
+			samenessGroup := &SamenessGroup{
+				Status: Status{
+					Conditions: []Condition{{
+						Type:   ConditionSynced,
+						Status: status,
+					}},
+				},
+			}
+
+			require.Equal(t, status, samenessGroup.SyncedConditionStatus())
+		})
+	}
+}
+
+func TestSamenessGroup_SyncedConditionWhenStatusNil(t *testing.T) {
+	status, reason, message := (&SamenessGroup{}).SyncedCondition()
+	require.Equal(t, corev1.ConditionUnknown, status)
+	require.Equal(t, "", reason)
+	require.Equal(t, "", message)
+}
+
+func TestSamenessGroup_ConsulKind(t *testing.T) {
+	require.Equal(t, capi.SamenessGroup, (&SamenessGroup{}).ConsulKind())
+}
+
+func TestSamenessGroup_KubeKind(t *testing.T) {
+	require.Equal(t, "samenessgroup", (&SamenessGroup{}).KubeKind())
+}
+
+func TestSamenessGroup_ConsulName(t *testing.T) {
+	require.Equal(t, "foo", (&SamenessGroup{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).ConsulName())
+}
+
+func TestSamenessGroup_KubernetesName(t *testing.T) {
+	require.Equal(t, "foo", (&SamenessGroup{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}).KubernetesName())
+}
+
+func TestSamenessGroup_ConsulNamespace(t *testing.T) {
+	require.Equal(t, common.DefaultConsulNamespace, (&SamenessGroup{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}).ConsulMirroringNS())
+}
+
+func TestSamenessGroup_ConsulGlobalResource(t *testing.T) {
+	require.True(t, (&SamenessGroup{}).ConsulGlobalResource())
+}
+
+func TestSamenessGroup_ObjectMeta(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:      "name",
+		Namespace: "namespace",
+	}
+	samenessGroup := &SamenessGroup{
+		ObjectMeta: meta,
+	}
+	require.Equal(t, meta, samenessGroup.GetObjectMeta())
+}
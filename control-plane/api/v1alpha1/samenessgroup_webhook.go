@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:object:generate=false
+
+type SamenessGroupWebhook struct {
+	client.Client
+	ConsulClient *capi.Client
+	Logger       logr.Logger
+	decoder      *admission.Decoder
+	ConsulMeta   common.ConsulMeta
+}
+
+// NOTE: The path value in the below line is the path to the webhook.
+// If it is updated, run code-gen, update subcommand/controller/command.go
+// and the consul-helm value for the path to the webhook.
+//
+// NOTE: The below line cannot be combined with any other comment. If it is
+// it will break the code generation.
+//
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-samenessgroup,mutating=true,failurePolicy=fail,groups=consul.hashicorp.com,resources=samenessgroups,versions=v1alpha1,name=mutate-samenessgroup.consul.hashicorp.com,sideEffects=None,admissionReviewVersions=v1beta1;v1
+
+func (v *SamenessGroupWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var samenessGroup SamenessGroup
+	err := v.decoder.Decode(req, &samenessGroup)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Since sameness groups aren't namespaced in Consul, group names must be
+	// unique across Kubernetes namespaces and only one group may be the
+	// default for failover.
+	var samenessGroupList SamenessGroupList
+	if err := v.Client.List(ctx, &samenessGroupList); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	for _, item := range samenessGroupList.Items {
+		if item.Namespace == samenessGroup.Namespace && item.Name == samenessGroup.Name {
+			continue
+		}
+		if item.Name == samenessGroup.Name {
+			return admission.Errored(http.StatusBadRequest,
+				fmt.Errorf("%s resource with name %q is already defined in namespace %q – all %s resources must have unique names across namespaces",
+					samenessGroup.KubeKind(), samenessGroup.Name, item.Namespace, samenessGroup.KubeKind()))
+		}
+		if samenessGroup.Spec.DefaultForFailover && item.Spec.DefaultForFailover {
+			return admission.Errored(http.StatusBadRequest,
+				fmt.Errorf("%s resource %q in namespace %q is already the default for failover – only one %s resource can be the default for failover",
+					samenessGroup.KubeKind(), item.Name, item.Namespace, samenessGroup.KubeKind()))
+		}
+	}
+
+	v.Logger.Info("validate", "name", samenessGroup.KubernetesName())
+	if err := samenessGroup.Validate(v.ConsulMeta); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	return admission.Allowed(fmt.Sprintf("valid %s request", samenessGroup.KubeKind()))
+}
+
+func (v *SamenessGroupWebhook) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
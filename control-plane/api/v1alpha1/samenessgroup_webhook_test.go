@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateSamenessGroup(t *testing.T) {
+	consulMeta := common.ConsulMeta{
+		PartitionsEnabled: true,
+		Partition:         "local",
+	}
+	group := func(namespace, name string, defaultForFailover bool) *SamenessGroup {
+		return &SamenessGroup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: SamenessGroupSpec{
+				DefaultForFailover: defaultForFailover,
+				IncludeLocal:       true,
+				Members:            []SamenessGroupMember{{Peer: "peer"}},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *SamenessGroup
+		operation         admissionv1.Operation
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"valid": {
+			existingResources: []runtime.Object{group("default", "other", true)},
+			newResource:       group("default", "group", false),
+			operation:         admissionv1.Create,
+			expAllow:          true,
+		},
+		"name exists in another namespace": {
+			existingResources: []runtime.Object{group("other", "group", false)},
+			newResource:       group("default", "group", false),
+			operation:         admissionv1.Create,
+			expAllow:          false,
+			expErrMessage:     "samenessgroup resource with name \"group\" is already defined in namespace \"other\" – all samenessgroup resources must have unique names across namespaces",
+		},
+		"another group is the default for failover": {
+			existingResources: []runtime.Object{group("other", "other", true)},
+			newResource:       group("default", "group", true),
+			operation:         admissionv1.Create,
+			expAllow:          false,
+			expErrMessage:     "samenessgroup resource \"other\" in namespace \"other\" is already the default for failover – only one samenessgroup resource can be the default for failover",
+		},
+		"update of the default for failover": {
+			existingResources: []runtime.Object{group("default", "group", true)},
+			newResource:       group("default", "group", true),
+			operation:         admissionv1.Update,
+			expAllow:          true,
+		},
+		"invalid": {
+			newResource: &SamenessGroup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "group",
+					Namespace: "default",
+				},
+			},
+			operation:     admissionv1.Create,
+			expAllow:      false,
+			expErrMessage: "samenessgroup.consul.hashicorp.com \"group\" is invalid: spec.members: Required value: sameness group must have at least one member or include the local partition",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &SamenessGroup{}, &SamenessGroupList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &SamenessGroupWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+				ConsulMeta:   consulMeta,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: c.newResource.Namespace,
+					Operation: c.operation,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
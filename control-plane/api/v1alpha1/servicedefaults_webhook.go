@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	admissionv1 "k8s.io/api/admission/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -20,6 +21,10 @@ type ServiceDefaultsWebhook struct {
 	// ConsulMeta contains metadata specific to the Consul installation.
 	ConsulMeta common.ConsulMeta
 
+	// EnableConsulStateValidation enables validating resources against the
+	// config entries in Consul.
+	EnableConsulStateValidation bool
+
 	decoder *admission.Decoder
 	client.Client
 }
@@ -54,6 +59,41 @@ func (v *ServiceDefaultsWebhook) List(ctx context.Context) ([]common.ConfigEntry
 	return entries, nil
 }
 
+// ValidateConsulState implements common.ConsulStateValidator. Consul doesn't
+// allow services that are routed or split to have a protocol other than an
+// L7 protocol.
+func (v *ServiceDefaultsWebhook) ValidateConsulState(ctx context.Context, req admission.Request, cfgEntry common.ConfigEntryResource) error {
+	if !v.EnableConsulStateValidation {
+		return nil
+	}
+	svcDefaults, ok := cfgEntry.(*ServiceDefaults)
+	if !ok {
+		return nil
+	}
+	state := &consulState{client: v.Client, consulClient: v.ConsulClient, consulMeta: v.ConsulMeta}
+	if req.Operation == admissionv1.Create {
+		if err := state.validateNotUnmanaged(cfgEntry, req.Namespace); err != nil {
+			return err
+		}
+	}
+
+	protocol := svcDefaults.Spec.Protocol
+	if protocol == "" {
+		var err error
+		protocol, _, err = state.globalProtocol(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if l7Protocols[protocol] {
+		return nil
+	}
+	return state.validateNoL7ConfigEntries(ctx, svcDefaults.KubernetesName(), req.Namespace, protocol)
+}
+
 func (v *ServiceDefaultsWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
@@ -55,6 +55,9 @@ type ServiceIntentionsSpec struct {
 	// The order of this list does not matter, but out of convenience Consul will always store this
 	// reverse sorted by intention precedence, as that is the order that they will be evaluated at enforcement time.
 	Sources SourceIntentions `json:"sources,omitempty"`
+	// JWT specifies the configuration to validate a JSON Web Token for all
+	// incoming requests to the destination.
+	JWT *IntentionJWTRequirement `json:"jwt,omitempty"`
 }
 
 type Destination struct {
@@ -100,6 +103,9 @@ type IntentionPermission struct {
 	Action IntentionAction `json:"action,omitempty"`
 	// HTTP is a set of HTTP-specific authorization criteria.
 	HTTP *IntentionHTTPPermission `json:"http,omitempty"`
+	// JWT specifies the configuration to validate a JSON Web Token for
+	// the requests that match the permission.
+	JWT *IntentionJWTRequirement `json:"jwt,omitempty"`
 }
 
 type IntentionHTTPPermission struct {
@@ -134,6 +140,28 @@ type IntentionHTTPHeaderPermission struct {
 	Invert bool `json:"invert,omitempty"`
 }
 
+type IntentionJWTRequirement struct {
+	// Providers is a list of providers to consider when verifying a JWT.
+	Providers []*IntentionJWTProvider `json:"providers,omitempty"`
+}
+
+type IntentionJWTProvider struct {
+	// Name is the name of the JWT provider. There MUST be a corresponding
+	// JWTProvider resource or jwt-provider config entry with this name.
+	Name string `json:"name,omitempty"`
+	// VerifyClaims is a list of additional claims to verify in a JWT's payload.
+	VerifyClaims []*IntentionJWTClaimVerification `json:"verifyClaims,omitempty"`
+}
+
+type IntentionJWTClaimVerification struct {
+	// Path is the path to the claim in the token JSON.
+	Path []string `json:"path,omitempty"`
+	// Value is the expected value at the given path. If the type at the path
+	// is a list then we verify that this value is contained in the list. If
+	// the type at the path is a string then we verify that this value matches.
+	Value string `json:"value,omitempty"`
+}
+
 // IntentionAction is the action that the intention represents. This
 // can be "allow" or "deny" to allowlist or denylist intentions.
 type IntentionAction string
@@ -181,15 +209,7 @@ func (in *ServiceIntentions) KubernetesName() string {
 }
 
 func (in *ServiceIntentions) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *ServiceIntentions) SetLastSyncedTime(time *metav1.Time) {
@@ -218,6 +238,7 @@ func (in *ServiceIntentions) ToConsul(datacenter string) api.ConfigEntry {
 		Name:      in.Spec.Destination.Name,
 		Namespace: in.Spec.Destination.Namespace,
 		Sources:   in.Spec.Sources.toConsul(),
+		JWT:       in.Spec.JWT.toConsul(),
 		Meta:      meta(datacenter),
 	}
 }
@@ -269,6 +290,10 @@ func (in *ServiceIntentions) Validate(consulMeta common.ConsulMeta) error {
 		}
 	}
 
+	if in.Spec.JWT != nil {
+		errs = append(errs, in.Spec.JWT.validate(path.Child("jwt"))...)
+	}
+
 	errs = append(errs, in.validateNamespaces(consulMeta.NamespacesEnabled)...)
 	errs = append(errs, in.validatePartitions(consulMeta.PartitionsEnabled)...)
 
@@ -280,6 +305,28 @@ func (in *ServiceIntentions) Validate(consulMeta common.ConsulMeta) error {
 	return nil
 }
 
+// JWTProviderNames returns the names of the JWT providers that the intentions
+// reference, in order and without duplicates.
+func (in *ServiceIntentions) JWTProviderNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(providers []string) {
+		for _, name := range providers {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(in.Spec.JWT.providerNames())
+	for _, source := range in.Spec.Sources {
+		for _, permission := range source.Permissions {
+			add(permission.JWT.providerNames())
+		}
+	}
+	return names
+}
+
 // DefaultNamespaceFields sets the namespace field on spec.destination to their default values if namespaces are enabled.
 func (in *ServiceIntentions) DefaultNamespaceFields(consulMeta common.ConsulMeta) {
 	// If namespaces are enabled we want to set the destination namespace field to it's
@@ -327,6 +374,7 @@ func (in IntentionPermissions) toConsul() []*capi.IntentionPermission {
 		consulIntentionPermissions = append(consulIntentionPermissions, &capi.IntentionPermission{
 			Action: permission.Action.toConsul(),
 			HTTP:   permission.HTTP.toConsul(),
+			JWT:    permission.JWT.toConsul(),
 		})
 	}
 	return consulIntentionPermissions
@@ -362,6 +410,55 @@ func (in IntentionHTTPHeaderPermissions) toConsul() []capi.IntentionHTTPHeaderPe
 	return headerPermissions
 }
 
+func (in *IntentionJWTRequirement) toConsul() *capi.IntentionJWTRequirement {
+	if in == nil {
+		return nil
+	}
+	var providers []*capi.IntentionJWTProvider
+	for _, provider := range in.Providers {
+		var claims []*capi.IntentionJWTClaimVerification
+		for _, claim := range provider.VerifyClaims {
+			claims = append(claims, &capi.IntentionJWTClaimVerification{
+				Path:  claim.Path,
+				Value: claim.Value,
+			})
+		}
+		providers = append(providers, &capi.IntentionJWTProvider{
+			Name:         provider.Name,
+			VerifyClaims: claims,
+		})
+	}
+	return &capi.IntentionJWTRequirement{Providers: providers}
+}
+
+// providerNames returns the names of the JWT providers of the requirement.
+func (in *IntentionJWTRequirement) providerNames() []string {
+	if in == nil {
+		return nil
+	}
+	var names []string
+	for _, provider := range in.Providers {
+		names = append(names, provider.Name)
+	}
+	return names
+}
+
+func (in *IntentionJWTRequirement) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, provider := range in.Providers {
+		providerPath := path.Child("providers").Index(i)
+		if provider.Name == "" {
+			errs = append(errs, field.Required(providerPath.Child("name"), "JWT provider name is required"))
+		}
+		for j, claim := range provider.VerifyClaims {
+			if len(claim.Path) == 0 {
+				errs = append(errs, field.Required(providerPath.Child("verifyClaims").Index(j).Child("path"), "claim path is required"))
+			}
+		}
+	}
+	return errs
+}
+
 func (in IntentionPermissions) validate(path *field.Path) field.ErrorList {
 	var errs field.ErrorList
 	for i, permission := range in {
@@ -371,6 +468,9 @@ func (in IntentionPermissions) validate(path *field.Path) field.ErrorList {
 		if permission.HTTP != nil {
 			errs = append(errs, permission.HTTP.validate(path.Child("permissions").Index(i))...)
 		}
+		if permission.JWT != nil {
+			errs = append(errs, permission.JWT.validate(path.Child("permissions").Index(i).Child("jwt"))...)
+		}
 	}
 	return errs
 }
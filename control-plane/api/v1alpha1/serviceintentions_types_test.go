@@ -357,6 +357,92 @@ func TestServiceIntentions_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"JWT": {
+			Ours: ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{
+						Name: "dest-name",
+					},
+					Sources: SourceIntentions{
+						{
+							Name: "svc",
+							Permissions: IntentionPermissions{
+								{
+									Action: "allow",
+									HTTP: &IntentionHTTPPermission{
+										PathPrefix: "/admin",
+									},
+									JWT: &IntentionJWTRequirement{
+										Providers: []*IntentionJWTProvider{
+											{
+												Name: "okta",
+												VerifyClaims: []*IntentionJWTClaimVerification{
+													{
+														Path:  []string{"perms", "role"},
+														Value: "admin",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					JWT: &IntentionJWTRequirement{
+						Providers: []*IntentionJWTProvider{
+							{
+								Name: "okta",
+							},
+						},
+					},
+				},
+			},
+			Exp: &capi.ServiceIntentionsConfigEntry{
+				Kind: capi.ServiceIntentions,
+				Name: "dest-name",
+				Sources: []*capi.SourceIntention{
+					{
+						Name: "svc",
+						Permissions: []*capi.IntentionPermission{
+							{
+								Action: "allow",
+								HTTP: &capi.IntentionHTTPPermission{
+									PathPrefix: "/admin",
+								},
+								JWT: &capi.IntentionJWTRequirement{
+									Providers: []*capi.IntentionJWTProvider{
+										{
+											Name: "okta",
+											VerifyClaims: []*capi.IntentionJWTClaimVerification{
+												{
+													Path:  []string{"perms", "role"},
+													Value: "admin",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				JWT: &capi.IntentionJWTRequirement{
+					Providers: []*capi.IntentionJWTProvider{
+						{
+							Name: "okta",
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -1311,6 +1397,43 @@ func TestServiceIntentions_Validate(t *testing.T) {
 				`spec.sources[2].partition: Invalid value: "partition-foo": Consul Enterprise Admin Partitions must be enabled to set source.partition`,
 			},
 		},
+		"JWT providers without names or claim paths": {
+			input: &ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "does-not-matter",
+				},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{
+						Name: "dest-service",
+					},
+					Sources: SourceIntentions{
+						{
+							Name: "web",
+							Permissions: IntentionPermissions{
+								{
+									Action: "allow",
+									JWT: &IntentionJWTRequirement{
+										Providers: []*IntentionJWTProvider{
+											{
+												Name:         "okta",
+												VerifyClaims: []*IntentionJWTClaimVerification{{Value: "admin"}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					JWT: &IntentionJWTRequirement{
+						Providers: []*IntentionJWTProvider{{}},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.jwt.providers[0].name: Required value: JWT provider name is required`,
+				`spec.sources[0].permissions[0].jwt.providers[0].verifyClaims[0].path: Required value: claim path is required`,
+			},
+		},
 	}
 	for name, testCase := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -22,6 +22,10 @@ type ServiceIntentionsWebhook struct {
 	Logger       logr.Logger
 	decoder      *admission.Decoder
 	ConsulMeta   common.ConsulMeta
+
+	// EnableConsulStateValidation enables validating resources against the
+	// config entries in Consul.
+	EnableConsulStateValidation bool
 }
 
 // NOTE: The path value in the below line is the path to the webhook.
@@ -90,6 +94,15 @@ func (v *ServiceIntentionsWebhook) Handle(ctx context.Context, req admission.Req
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// Consul only allows one service-intentions config entry per destination,
+	// including the wildcard destination.
+	if v.EnableConsulStateValidation && req.Operation == admissionv1.Create {
+		state := &consulState{client: v.Client, consulClient: v.ConsulClient, consulMeta: v.ConsulMeta}
+		if err := state.validateNotUnmanaged(&svcIntentions, req.Namespace); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
 	// We always return an admission.Patched() response, even if there are no patches, since
 	// admission.Patched() with no patches is equal to admission.Allowed() under
 	// the hood.
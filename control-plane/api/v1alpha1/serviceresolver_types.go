@@ -73,6 +73,23 @@ type ServiceResolverSpec struct {
 	// LoadBalancer determines the load balancing policy and configuration for services
 	// issuing requests to this upstream service.
 	LoadBalancer *LoadBalancer `json:"loadBalancer,omitempty"`
+	// PrioritizeByLocality controls whether the locality of the instances of
+	// the service in the local partition is used to prioritize them. The
+	// instances must be registered with their locality, e.g. with
+	// `connectInject.locality.enabled` in the Helm chart.
+	PrioritizeByLocality *ServiceResolverPrioritizeByLocality `json:"prioritizeByLocality,omitempty"`
+}
+
+// ServiceResolverPrioritizeByLocality configures how the instances of a
+// service are prioritized by their locality.
+type ServiceResolverPrioritizeByLocality struct {
+	// Mode specifies the type of prioritization that is performed when
+	// selecting the instances of the service in the local partition.
+	// Valid values are "none" (the default) and "failover". With "failover",
+	// proxies send requests to the instances in their own zone, and fail over
+	// to the instances in the other zones of their region and then to the
+	// other regions when those are unhealthy.
+	Mode string `json:"mode,omitempty"`
 }
 
 type ServiceResolverRedirect struct {
@@ -234,15 +251,7 @@ func (in *ServiceResolver) ConsulName() string {
 }
 
 func (in *ServiceResolver) SetSyncedCondition(status corev1.ConditionStatus, reason string, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *ServiceResolver) SetLastSyncedTime(time *metav1.Time) {
@@ -268,15 +277,16 @@ func (in *ServiceResolver) SyncedConditionStatus() corev1.ConditionStatus {
 // ToConsul converts the entry into its Consul equivalent struct.
 func (in *ServiceResolver) ToConsul(datacenter string) capi.ConfigEntry {
 	return &capi.ServiceResolverConfigEntry{
-		Kind:           in.ConsulKind(),
-		Name:           in.ConsulName(),
-		DefaultSubset:  in.Spec.DefaultSubset,
-		Subsets:        in.Spec.Subsets.toConsul(),
-		Redirect:       in.Spec.Redirect.toConsul(),
-		Failover:       in.Spec.Failover.toConsul(),
-		ConnectTimeout: in.Spec.ConnectTimeout.Duration,
-		LoadBalancer:   in.Spec.LoadBalancer.toConsul(),
-		Meta:           meta(datacenter),
+		Kind:                 in.ConsulKind(),
+		Name:                 in.ConsulName(),
+		DefaultSubset:        in.Spec.DefaultSubset,
+		Subsets:              in.Spec.Subsets.toConsul(),
+		Redirect:             in.Spec.Redirect.toConsul(),
+		Failover:             in.Spec.Failover.toConsul(),
+		ConnectTimeout:       in.Spec.ConnectTimeout.Duration,
+		LoadBalancer:         in.Spec.LoadBalancer.toConsul(),
+		PrioritizeByLocality: in.Spec.PrioritizeByLocality.toConsul(),
+		Meta:                 meta(datacenter),
 	}
 }
 
@@ -304,6 +314,7 @@ func (in *ServiceResolver) Validate(consulMeta common.ConsulMeta) error {
 	}
 
 	errs = append(errs, in.Spec.LoadBalancer.validate(path.Child("loadBalancer"))...)
+	errs = append(errs, in.Spec.PrioritizeByLocality.validate(path.Child("prioritizeByLocality"))...)
 
 	errs = append(errs, in.validateEnterprise(consulMeta)...)
 
@@ -474,6 +485,26 @@ func (in *ServiceResolverFailover) validate(path *field.Path) *field.Error {
 	return nil
 }
 
+func (in *ServiceResolverPrioritizeByLocality) toConsul() *capi.ServiceResolverPrioritizeByLocality {
+	if in == nil {
+		return nil
+	}
+	return &capi.ServiceResolverPrioritizeByLocality{
+		Mode: in.Mode,
+	}
+}
+
+func (in *ServiceResolverPrioritizeByLocality) validate(path *field.Path) field.ErrorList {
+	if in == nil {
+		return nil
+	}
+	validModes := []string{"none", "failover", ""}
+	if !sliceContains(validModes, in.Mode) {
+		return field.ErrorList{field.Invalid(path.Child("mode"), in.Mode, notInSliceMessage(validModes))}
+	}
+	return nil
+}
+
 func (in *LoadBalancer) validate(path *field.Path) field.ErrorList {
 	if in == nil {
 		return nil
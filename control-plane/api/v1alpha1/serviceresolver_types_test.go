@@ -98,6 +98,9 @@ func TestServiceResolver_MatchesConsul(t *testing.T) {
 							},
 						},
 					},
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "failover",
+					},
 				},
 			},
 			Theirs: &capi.ServiceResolverConfigEntry{
@@ -158,6 +161,9 @@ func TestServiceResolver_MatchesConsul(t *testing.T) {
 						},
 					},
 				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 			},
 			Matches: true,
 		},
@@ -267,6 +273,9 @@ func TestServiceResolver_ToConsul(t *testing.T) {
 							},
 						},
 					},
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "failover",
+					},
 				},
 			},
 			Exp: &capi.ServiceResolverConfigEntry{
@@ -327,6 +336,9 @@ func TestServiceResolver_ToConsul(t *testing.T) {
 						},
 					},
 				},
+				PrioritizeByLocality: &capi.ServiceResolverPrioritizeByLocality{
+					Mode: "failover",
+				},
 				Meta: map[string]string{
 					common.SourceKey:     common.SourceValue,
 					common.DatacenterKey: "datacenter",
@@ -692,6 +704,22 @@ func TestServiceResolver_Validate(t *testing.T) {
 				`serviceresolver.consul.hashicorp.com "foo" is invalid: spec.loadBalancer.hashPolicies[0].cookieConfig: Invalid value: "{\"session\":true,\"ttl\":\"100ns\"}": cannot set both session and ttl`,
 			},
 		},
+		"prioritizeByLocality mode invalid": {
+			input: &ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceResolverSpec{
+					PrioritizeByLocality: &ServiceResolverPrioritizeByLocality{
+						Mode: "zone",
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`serviceresolver.consul.hashicorp.com "foo" is invalid: spec.prioritizeByLocality.mode: Invalid value: "zone": must be one of "none", "failover", ""`,
+			},
+		},
 		"namespaces disabled: redirect namespace specified": {
 			input: &ServiceResolver{
 				ObjectMeta: metav1.ObjectMeta{
@@ -194,15 +194,7 @@ func (in *ServiceRouter) KubernetesName() string {
 }
 
 func (in *ServiceRouter) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *ServiceRouter) SetLastSyncedTime(time *metav1.Time) {
@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	admissionv1 "k8s.io/api/admission/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -20,6 +21,10 @@ type ServiceSplitterWebhook struct {
 	// ConsulMeta contains metadata specific to the Consul installation.
 	ConsulMeta common.ConsulMeta
 
+	// EnableConsulStateValidation enables validating resources against the
+	// config entries in Consul.
+	EnableConsulStateValidation bool
+
 	decoder *admission.Decoder
 	client.Client
 }
@@ -55,6 +60,21 @@ func (v *ServiceSplitterWebhook) List(ctx context.Context) ([]common.ConfigEntry
 	return entries, nil
 }
 
+// ValidateConsulState implements common.ConsulStateValidator. Consul only
+// allows splitting services with an L7 protocol.
+func (v *ServiceSplitterWebhook) ValidateConsulState(ctx context.Context, req admission.Request, cfgEntry common.ConfigEntryResource) error {
+	if !v.EnableConsulStateValidation {
+		return nil
+	}
+	state := &consulState{client: v.Client, consulClient: v.ConsulClient, consulMeta: v.ConsulMeta}
+	if req.Operation == admissionv1.Create {
+		if err := state.validateNotUnmanaged(cfgEntry, req.Namespace); err != nil {
+			return err
+		}
+	}
+	return state.validateL7Protocol(ctx, "ServiceSplitter", cfgEntry.KubernetesName(), req.Namespace)
+}
+
 func (v *ServiceSplitterWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
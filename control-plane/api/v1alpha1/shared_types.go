@@ -189,6 +189,39 @@ func sliceContains(slice []string, entry string) bool {
 	return false
 }
 
+// validateTLSPolicy returns an error for a TLS minimum version or cipher
+// suites of an Envoy listener that the TLS settings of the installation don't
+// allow.
+func validateTLSPolicy(path *field.Path, minVersion string, cipherSuites []string, consulMeta common.ConsulMeta) field.ErrorList {
+	var errs field.ErrorList
+	// The versions are ordered, and TLS_AUTO leaves the minimum version to Envoy.
+	if consulMeta.TLSMinVersion != "" && (minVersion == "" || minVersion == "TLS_AUTO" || minVersion < consulMeta.TLSMinVersion) {
+		errs = append(errs, field.Invalid(path.Child("tlsMinVersion"), minVersion,
+			fmt.Sprintf("must be at least %q", consulMeta.TLSMinVersion)))
+	}
+	if len(consulMeta.TLSCipherSuites) > 0 {
+		for i, suite := range cipherSuites {
+			if !sliceContains(consulMeta.TLSCipherSuites, suite) {
+				errs = append(errs, field.Invalid(path.Child("cipherSuites").Index(i), suite,
+					notInSliceMessage(consulMeta.TLSCipherSuites)))
+			}
+		}
+	}
+	return errs
+}
+
+// defaultTLSPolicy sets a TLS minimum version and cipher suites of an Envoy
+// listener that aren't set to the TLS settings of the installation. Cipher
+// suites can't be configured for TLS 1.3.
+func defaultTLSPolicy(minVersion *string, cipherSuites *[]string, consulMeta common.ConsulMeta) {
+	if *minVersion == "" {
+		*minVersion = consulMeta.TLSMinVersion
+	}
+	if len(*cipherSuites) == 0 && len(consulMeta.TLSCipherSuites) > 0 && *minVersion != "TLSv1_3" {
+		*cipherSuites = append([]string(nil), consulMeta.TLSCipherSuites...)
+	}
+}
+
 func invalidPathPrefix(path string) bool {
 	return path != "" && !strings.HasPrefix(path, "/")
 }
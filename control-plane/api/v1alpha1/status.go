@@ -13,7 +13,21 @@ type ConditionType string
 
 const (
 	// ConditionSynced specifies that the resource has been synced with Consul.
+	// It summarizes the other conditions.
 	ConditionSynced ConditionType = "Synced"
+
+	// ConditionValidated specifies that the resources that the resource
+	// references, such as services or peers, exist in Consul.
+	ConditionValidated ConditionType = "Validated"
+
+	// ConditionSyncedToConsul specifies that the config entry has been
+	// written to Consul.
+	ConditionSyncedToConsul ConditionType = "SyncedToConsul"
+
+	// ConditionInConflict specifies that a config entry with the same name
+	// exists in Consul but isn't managed by this resource, e.g. because it
+	// was created in Consul directly or by another datacenter.
+	ConditionInConflict ConditionType = "InConflict"
 )
 
 // Conditions define a readiness condition for a Consul resource.
@@ -78,6 +92,11 @@ type Status struct {
 	// LastSyncedTime is the last time the resource successfully synced with Consul.
 	// +optional
 	LastSyncedTime *metav1.Time `json:"lastSyncedTime,omitempty" description:"last time the condition transitioned from one status to another"`
+
+	// ConsulIndex is the modify index of the config entry in Consul when the
+	// resource was last synced.
+	// +optional
+	ConsulIndex uint64 `json:"consulIndex,omitempty" description:"modify index of the config entry in Consul when the resource was last synced"`
 }
 
 func (s *Status) GetCondition(t ConditionType) *Condition {
@@ -88,3 +107,51 @@ func (s *Status) GetCondition(t ConditionType) *Condition {
 	}
 	return nil
 }
+
+// SetCondition sets the condition of the given type. The last transition
+// time is only updated if the status of the condition changes.
+func (s *Status) SetCondition(conditionType string, status corev1.ConditionStatus, reason, message string) {
+	s.setCondition(ConditionType(conditionType), status, reason, message)
+}
+
+// ConditionStatus returns the status of the condition of the given type, or
+// Unknown if it isn't set.
+func (s *Status) ConditionStatus(conditionType string) corev1.ConditionStatus {
+	cond := s.GetCondition(ConditionType(conditionType))
+	if cond == nil {
+		return corev1.ConditionUnknown
+	}
+	return cond.Status
+}
+
+// SetConsulIndex sets the modify index of the config entry in Consul.
+func (s *Status) SetConsulIndex(index uint64) {
+	s.ConsulIndex = index
+}
+
+// GetConsulIndex returns the modify index of the config entry in Consul when
+// the resource was last synced.
+func (s *Status) GetConsulIndex() uint64 {
+	return s.ConsulIndex
+}
+
+func (s *Status) setCondition(conditionType ConditionType, status corev1.ConditionStatus, reason, message string) {
+	cond := Condition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i, existing := range s.Conditions {
+		if existing.Type != conditionType {
+			continue
+		}
+		if existing.Status == status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		}
+		s.Conditions[i] = cond
+		return
+	}
+	s.Conditions = append(s.Conditions, cond)
+}
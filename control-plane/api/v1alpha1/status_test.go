@@ -0,0 +1,54 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatus_SetCondition(t *testing.T) {
+	transitioned := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	status := &Status{
+		Conditions: Conditions{
+			{
+				Type:               ConditionSynced,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: transitioned,
+			},
+			{
+				Type:               ConditionSyncedToConsul,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: transitioned,
+			},
+		},
+	}
+
+	// Conditions of other types are kept, and the last transition time is
+	// kept if the status doesn't change.
+	status.SetCondition(string(ConditionSyncedToConsul), corev1.ConditionTrue, "", "")
+	status.SetCondition(string(ConditionInConflict), corev1.ConditionFalse, "", "")
+	require.Len(t, status.Conditions, 3)
+	require.Equal(t, transitioned, status.GetCondition(ConditionSynced).LastTransitionTime)
+	require.Equal(t, transitioned, status.GetCondition(ConditionSyncedToConsul).LastTransitionTime)
+	require.Equal(t, corev1.ConditionFalse, status.ConditionStatus(string(ConditionInConflict)))
+
+	// The last transition time is updated if the status changes.
+	status.SetCondition(string(ConditionSyncedToConsul), corev1.ConditionFalse, "ConsulAgentError", "error")
+	syncedToConsul := status.GetCondition(ConditionSyncedToConsul)
+	require.Equal(t, corev1.ConditionFalse, syncedToConsul.Status)
+	require.Equal(t, "ConsulAgentError", syncedToConsul.Reason)
+	require.Equal(t, "error", syncedToConsul.Message)
+	require.True(t, transitioned.Before(&syncedToConsul.LastTransitionTime))
+
+	require.Equal(t, corev1.ConditionUnknown, status.ConditionStatus(string(ConditionValidated)))
+}
+
+func TestStatus_ConsulIndex(t *testing.T) {
+	status := &Status{}
+	require.Equal(t, uint64(0), status.GetConsulIndex())
+	status.SetConsulIndex(10)
+	require.Equal(t, uint64(10), status.GetConsulIndex())
+}
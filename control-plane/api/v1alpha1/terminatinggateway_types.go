@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	"encoding/json"
+	"path/filepath"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -17,6 +18,13 @@ import (
 
 const (
 	terminatingGatewayKubeKind = "terminatinggateway"
+
+	// ExternalServiceCAMountPath is the directory that the CA secrets of
+	// external services are mounted under in terminating gateway pods. The
+	// key of each secret is mounted in a directory named after the secret.
+	ExternalServiceCAMountPath = "/consul/external-ca"
+	// ExternalServiceCAKey is the key of the CA bundle in CA secrets.
+	ExternalServiceCAKey = "ca.crt"
 )
 
 func init() {
@@ -52,6 +60,38 @@ type TerminatingGatewayList struct {
 type TerminatingGatewaySpec struct {
 	// Services is a list of service names represented by the terminating gateway.
 	Services []LinkedService `json:"services,omitempty"`
+
+	// ExternalServices is a list of services outside of the mesh that are
+	// represented by the terminating gateway. Unlike services, they don't
+	// need to be registered in Consul's catalog already: the controller
+	// registers them and, if ACLs are enabled, gives the gateway's ACL role
+	// service:write on them.
+	ExternalServices []ExternalService `json:"externalServices,omitempty"`
+}
+
+// An ExternalService is a destination outside of the mesh that is registered
+// in Consul's catalog and represented by a terminating gateway.
+type ExternalService struct {
+	// Name is the name of the service to register in Consul's catalog.
+	Name string `json:"name,omitempty"`
+
+	// The namespace to register the service in.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Hostname is the hostname or IP address of the service.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Port is the port of the service.
+	Port int `json:"port,omitempty"`
+
+	// CASecret is the optional name of a Kubernetes secret, with key ca.crt,
+	// that contains the CA bundle to verify the certificate of the service
+	// with. The secret must be in the namespace of the gateway and listed in
+	// the gateway's caSecrets Helm value so that it's mounted in its pods.
+	CASecret string `json:"caSecret,omitempty"`
+
+	// SNI is the optional name to specify during the TLS handshake with the service.
+	SNI string `json:"sni,omitempty"`
 }
 
 // A LinkedService is a service represented by a terminating gateway.
@@ -125,15 +165,7 @@ func (in *TerminatingGateway) KubernetesName() string {
 }
 
 func (in *TerminatingGateway) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
-	in.Status.Conditions = Conditions{
-		{
-			Type:               ConditionSynced,
-			Status:             status,
-			LastTransitionTime: metav1.Now(),
-			Reason:             reason,
-			Message:            message,
-		},
-	}
+	in.Status.setCondition(ConditionSynced, status, reason, message)
 }
 
 func (in *TerminatingGateway) SetLastSyncedTime(time *metav1.Time) {
@@ -161,6 +193,9 @@ func (in *TerminatingGateway) ToConsul(datacenter string) capi.ConfigEntry {
 	for _, s := range in.Spec.Services {
 		svcs = append(svcs, s.toConsul())
 	}
+	for _, s := range in.Spec.ExternalServices {
+		svcs = append(svcs, s.toConsul())
+	}
 	return &capi.TerminatingGatewayConfigEntry{
 		Kind:     in.ConsulKind(),
 		Name:     in.ConsulName(),
@@ -185,6 +220,7 @@ func (in *TerminatingGateway) Validate(consulMeta common.ConsulMeta) error {
 	for i, v := range in.Spec.Services {
 		errs = append(errs, v.validate(path.Child("services").Index(i))...)
 	}
+	errs = append(errs, in.validateExternalServices(path.Child("externalServices"))...)
 
 	errs = append(errs, in.validateNamespaces(consulMeta.NamespacesEnabled)...)
 
@@ -210,6 +246,11 @@ func (in *TerminatingGateway) DefaultNamespaceFields(consulMeta common.ConsulMet
 				in.Spec.Services[i].Namespace = namespace
 			}
 		}
+		for i, service := range in.Spec.ExternalServices {
+			if service.Namespace == "" {
+				in.Spec.ExternalServices[i].Namespace = namespace
+			}
+		}
 	}
 }
 
@@ -235,6 +276,58 @@ func (in LinkedService) validate(path *field.Path) field.ErrorList {
 	return errs
 }
 
+// CAFile returns the path that the CA bundle of the service is mounted at in
+// terminating gateway pods, or an empty string if it has no CA secret.
+func (in ExternalService) CAFile() string {
+	if in.CASecret == "" {
+		return ""
+	}
+	return filepath.Join(ExternalServiceCAMountPath, in.CASecret, ExternalServiceCAKey)
+}
+
+func (in ExternalService) toConsul() capi.LinkedService {
+	return capi.LinkedService{
+		Namespace: in.Namespace,
+		Name:      in.Name,
+		CAFile:    in.CAFile(),
+		SNI:       in.SNI,
+	}
+}
+
+// validateExternalServices validates the external services and that none of
+// them is also listed in spec.services, since the gateway can't route to the
+// same service in two ways.
+func (in *TerminatingGateway) validateExternalServices(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	linked := make(map[string]bool)
+	for _, s := range in.Spec.Services {
+		linked[s.Namespace+"/"+s.Name] = true
+	}
+	seen := make(map[string]bool)
+	for i, s := range in.Spec.ExternalServices {
+		p := path.Index(i)
+		if s.Name == "" {
+			errs = append(errs, field.Required(p.Child("name"), "externalServices must have a name"))
+		} else if s.Name == "*" {
+			errs = append(errs, field.Invalid(p.Child("name"), s.Name, "externalServices cannot be a wildcard"))
+		}
+		if s.Hostname == "" {
+			errs = append(errs, field.Required(p.Child("hostname"), "externalServices must have a hostname"))
+		}
+		if s.Port < 1 || s.Port > 65535 {
+			errs = append(errs, field.Invalid(p.Child("port"), s.Port, "must be between 1 and 65535"))
+		}
+		key := s.Namespace + "/" + s.Name
+		if linked[key] {
+			errs = append(errs, field.Invalid(p.Child("name"), s.Name, "service is also listed in spec.services"))
+		} else if seen[key] {
+			errs = append(errs, field.Duplicate(p.Child("name"), s.Name))
+		}
+		seen[key] = true
+	}
+	return errs
+}
+
 func (in *TerminatingGateway) validateNamespaces(namespacesEnabled bool) field.ErrorList {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
@@ -245,6 +338,12 @@ func (in *TerminatingGateway) validateNamespaces(namespacesEnabled bool) field.E
 					service.Namespace, `Consul Enterprise namespaces must be enabled to set service.namespace`))
 			}
 		}
+		for i, service := range in.Spec.ExternalServices {
+			if service.Namespace != "" {
+				errs = append(errs, field.Invalid(path.Child("externalServices").Index(i).Child("namespace"),
+					service.Namespace, `Consul Enterprise namespaces must be enabled to set externalService.namespace`))
+			}
+		}
 	}
 	return errs
 }
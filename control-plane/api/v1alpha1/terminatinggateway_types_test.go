@@ -170,6 +170,57 @@ func TestTerminatingGateway_ToConsul(t *testing.T) {
 				},
 			},
 		},
+		"external services": {
+			Ours: TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name: "linked",
+						},
+					},
+					ExternalServices: []ExternalService{
+						{
+							Name:      "external",
+							Namespace: "ns",
+							Hostname:  "api.example.com",
+							Port:      443,
+							CASecret:  "example-ca",
+							SNI:       "api.example.com",
+						},
+						{
+							Name:     "no-tls",
+							Hostname: "10.0.0.1",
+							Port:     80,
+						},
+					},
+				},
+			},
+			Exp: &capi.TerminatingGatewayConfigEntry{
+				Kind: capi.TerminatingGateway,
+				Name: "name",
+				Services: []capi.LinkedService{
+					{
+						Name: "linked",
+					},
+					{
+						Name:      "external",
+						Namespace: "ns",
+						CAFile:    "/consul/external-ca/example-ca/ca.crt",
+						SNI:       "api.example.com",
+					},
+					{
+						Name: "no-tls",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
 	}
 
 	for name, c := range cases {
@@ -264,6 +315,120 @@ func TestTerminatingGateway_Validate(t *testing.T) {
 			namespacesEnabled: true,
 			expectedErrMsgs:   []string{},
 		},
+		"valid external service": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					ExternalServices: []ExternalService{
+						{
+							Name:     "foo",
+							Hostname: "api.example.com",
+							Port:     443,
+							CASecret: "example-ca",
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs:   []string{},
+		},
+		"external service without name, hostname or port": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					ExternalServices: []ExternalService{
+						{},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.externalServices[0].name: Required value: externalServices must have a name`,
+				`spec.externalServices[0].hostname: Required value: externalServices must have a hostname`,
+				`spec.externalServices[0].port: Invalid value: 0: must be between 1 and 65535`,
+			},
+		},
+		"external service is a wildcard": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					ExternalServices: []ExternalService{
+						{
+							Name:     "*",
+							Hostname: "api.example.com",
+							Port:     443,
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.externalServices[0].name: Invalid value: "*": externalServices cannot be a wildcard`,
+			},
+		},
+		"external service is also a linked service": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name: "foo",
+						},
+					},
+					ExternalServices: []ExternalService{
+						{
+							Name:     "foo",
+							Hostname: "api.example.com",
+							Port:     443,
+						},
+						{
+							Name:     "bar",
+							Hostname: "api.example.com",
+							Port:     443,
+						},
+						{
+							Name:     "bar",
+							Hostname: "api.example.com",
+							Port:     8443,
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.externalServices[0].name: Invalid value: "foo": service is also listed in spec.services`,
+				`spec.externalServices[2].name: Duplicate value: "bar"`,
+			},
+		},
+		"externalService.namespace set when namespaces disabled": {
+			input: &TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TerminatingGatewaySpec{
+					ExternalServices: []ExternalService{
+						{
+							Name:      "foo",
+							Namespace: "ns",
+							Hostname:  "api.example.com",
+							Port:      443,
+						},
+					},
+				},
+			},
+			namespacesEnabled: false,
+			expectedErrMsgs: []string{
+				`spec.externalServices[0].namespace: Invalid value: "ns": Consul Enterprise namespaces must be enabled to set externalService.namespace`,
+			},
+		},
 	}
 
 	for name, testCase := range cases {
@@ -342,6 +507,11 @@ func TestTerminatingGateway_DefaultNamespaceFields(t *testing.T) {
 							Namespace: "other",
 						},
 					},
+					ExternalServices: []ExternalService{
+						{
+							Name: "external",
+						},
+					},
 				},
 			}
 			output := &TerminatingGateway{
@@ -360,6 +530,12 @@ func TestTerminatingGateway_DefaultNamespaceFields(t *testing.T) {
 							Namespace: "other",
 						},
 					},
+					ExternalServices: []ExternalService{
+						{
+							Name:      "external",
+							Namespace: s.expectedDestination,
+						},
+					},
 				},
 			}
 			input.DefaultNamespaceFields(s.consulMeta)
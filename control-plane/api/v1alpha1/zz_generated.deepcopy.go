@@ -47,6 +47,196 @@ func (in Conditions) DeepCopy() Conditions {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneRequestLimit) DeepCopyInto(out *ControlPlaneRequestLimit) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneRequestLimit.
+func (in *ControlPlaneRequestLimit) DeepCopy() *ControlPlaneRequestLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneRequestLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ControlPlaneRequestLimit) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneRequestLimitList) DeepCopyInto(out *ControlPlaneRequestLimitList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ControlPlaneRequestLimit, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneRequestLimitList.
+func (in *ControlPlaneRequestLimitList) DeepCopy() *ControlPlaneRequestLimitList {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneRequestLimitList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ControlPlaneRequestLimitList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneRequestLimitSpec) DeepCopyInto(out *ControlPlaneRequestLimitSpec) {
+	*out = *in
+	out.ReadWriteRatesConfig = in.ReadWriteRatesConfig
+	if in.ACL != nil {
+		in, out := &in.ACL, &out.ACL
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Catalog != nil {
+		in, out := &in.Catalog, &out.Catalog
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.ConfigEntry != nil {
+		in, out := &in.ConfigEntry, &out.ConfigEntry
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.ConnectCA != nil {
+		in, out := &in.ConnectCA, &out.ConnectCA
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Coordinate != nil {
+		in, out := &in.Coordinate, &out.Coordinate
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.DiscoveryChain != nil {
+		in, out := &in.DiscoveryChain, &out.DiscoveryChain
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.ServerDiscovery != nil {
+		in, out := &in.ServerDiscovery, &out.ServerDiscovery
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Intention != nil {
+		in, out := &in.Intention, &out.Intention
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.KV != nil {
+		in, out := &in.KV, &out.KV
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Tenancy != nil {
+		in, out := &in.Tenancy, &out.Tenancy
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.PreparedQuery != nil {
+		in, out := &in.PreparedQuery, &out.PreparedQuery
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Txn != nil {
+		in, out := &in.Txn, &out.Txn
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.AutoConfig != nil {
+		in, out := &in.AutoConfig, &out.AutoConfig
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.FederationState != nil {
+		in, out := &in.FederationState, &out.FederationState
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Internal != nil {
+		in, out := &in.Internal, &out.Internal
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.PeerStream != nil {
+		in, out := &in.PeerStream, &out.PeerStream
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Peering != nil {
+		in, out := &in.Peering, &out.Peering
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.DataPlane != nil {
+		in, out := &in.DataPlane, &out.DataPlane
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Subscribe != nil {
+		in, out := &in.Subscribe, &out.Subscribe
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+	if in.Resource != nil {
+		in, out := &in.Resource, &out.Resource
+		*out = new(ReadWriteRatesConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneRequestLimitSpec.
+func (in *ControlPlaneRequestLimitSpec) DeepCopy() *ControlPlaneRequestLimitSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneRequestLimitSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CookieConfig) DeepCopyInto(out *CookieConfig) {
 	*out = *in
@@ -214,6 +404,21 @@ func (in *ExposePath) DeepCopy() *ExposePath {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalService) DeepCopyInto(out *ExternalService) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalService.
+func (in *ExternalService) DeepCopy() *ExternalService {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalService)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GatewayServiceTLSConfig) DeepCopyInto(out *GatewayServiceTLSConfig) {
 	*out = *in
@@ -496,150 +701,926 @@ func (in IntentionHTTPHeaderPermissions) DeepCopyInto(out *IntentionHTTPHeaderPe
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionHTTPHeaderPermissions.
-func (in IntentionHTTPHeaderPermissions) DeepCopy() IntentionHTTPHeaderPermissions {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionHTTPHeaderPermissions.
+func (in IntentionHTTPHeaderPermissions) DeepCopy() IntentionHTTPHeaderPermissions {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionHTTPHeaderPermissions)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntentionHTTPPermission) DeepCopyInto(out *IntentionHTTPPermission) {
+	*out = *in
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = make(IntentionHTTPHeaderPermissions, len(*in))
+		copy(*out, *in)
+	}
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionHTTPPermission.
+func (in *IntentionHTTPPermission) DeepCopy() *IntentionHTTPPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionHTTPPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntentionJWTClaimVerification) DeepCopyInto(out *IntentionJWTClaimVerification) {
+	*out = *in
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionJWTClaimVerification.
+func (in *IntentionJWTClaimVerification) DeepCopy() *IntentionJWTClaimVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionJWTClaimVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntentionJWTProvider) DeepCopyInto(out *IntentionJWTProvider) {
+	*out = *in
+	if in.VerifyClaims != nil {
+		in, out := &in.VerifyClaims, &out.VerifyClaims
+		*out = make([]*IntentionJWTClaimVerification, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(IntentionJWTClaimVerification)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionJWTProvider.
+func (in *IntentionJWTProvider) DeepCopy() *IntentionJWTProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionJWTProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntentionJWTRequirement) DeepCopyInto(out *IntentionJWTRequirement) {
+	*out = *in
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]*IntentionJWTProvider, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(IntentionJWTProvider)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionJWTRequirement.
+func (in *IntentionJWTRequirement) DeepCopy() *IntentionJWTRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionJWTRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntentionPermission) DeepCopyInto(out *IntentionPermission) {
+	*out = *in
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(IntentionHTTPPermission)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JWT != nil {
+		in, out := &in.JWT, &out.JWT
+		*out = new(IntentionJWTRequirement)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionPermission.
+func (in *IntentionPermission) DeepCopy() *IntentionPermission {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionPermission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in IntentionPermissions) DeepCopyInto(out *IntentionPermissions) {
+	{
+		in := &in
+		*out = make(IntentionPermissions, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(IntentionPermission)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionPermissions.
+func (in IntentionPermissions) DeepCopy() IntentionPermissions {
+	if in == nil {
+		return nil
+	}
+	out := new(IntentionPermissions)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JSONWebKeySet) DeepCopyInto(out *JSONWebKeySet) {
+	*out = *in
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = new(LocalJWKS)
+		**out = **in
+	}
+	if in.Remote != nil {
+		in, out := &in.Remote, &out.Remote
+		*out = new(RemoteJWKS)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JSONWebKeySet.
+func (in *JSONWebKeySet) DeepCopy() *JSONWebKeySet {
+	if in == nil {
+		return nil
+	}
+	out := new(JSONWebKeySet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSCluster) DeepCopyInto(out *JWKSCluster) {
+	*out = *in
+	if in.TLSCertificates != nil {
+		in, out := &in.TLSCertificates, &out.TLSCertificates
+		*out = new(JWKSTLSCertificate)
+		(*in).DeepCopyInto(*out)
+	}
+	out.ConnectTimeout = in.ConnectTimeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSCluster.
+func (in *JWKSCluster) DeepCopy() *JWKSCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSRetryPolicy) DeepCopyInto(out *JWKSRetryPolicy) {
+	*out = *in
+	if in.RetryPolicyBackOff != nil {
+		in, out := &in.RetryPolicyBackOff, &out.RetryPolicyBackOff
+		*out = new(RetryPolicyBackOff)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSRetryPolicy.
+func (in *JWKSRetryPolicy) DeepCopy() *JWKSRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSTLSCertProviderInstance) DeepCopyInto(out *JWKSTLSCertProviderInstance) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSTLSCertProviderInstance.
+func (in *JWKSTLSCertProviderInstance) DeepCopy() *JWKSTLSCertProviderInstance {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSTLSCertProviderInstance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSTLSCertTrustedCA) DeepCopyInto(out *JWKSTLSCertTrustedCA) {
+	*out = *in
+	if in.InlineBytes != nil {
+		in, out := &in.InlineBytes, &out.InlineBytes
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSTLSCertTrustedCA.
+func (in *JWKSTLSCertTrustedCA) DeepCopy() *JWKSTLSCertTrustedCA {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSTLSCertTrustedCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWKSTLSCertificate) DeepCopyInto(out *JWKSTLSCertificate) {
+	*out = *in
+	if in.CaCertificateProviderInstance != nil {
+		in, out := &in.CaCertificateProviderInstance, &out.CaCertificateProviderInstance
+		*out = new(JWKSTLSCertProviderInstance)
+		**out = **in
+	}
+	if in.TrustedCA != nil {
+		in, out := &in.TrustedCA, &out.TrustedCA
+		*out = new(JWKSTLSCertTrustedCA)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWKSTLSCertificate.
+func (in *JWKSTLSCertificate) DeepCopy() *JWKSTLSCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(JWKSTLSCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTCacheConfig) DeepCopyInto(out *JWTCacheConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTCacheConfig.
+func (in *JWTCacheConfig) DeepCopy() *JWTCacheConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTCacheConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTForwardingConfig) DeepCopyInto(out *JWTForwardingConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTForwardingConfig.
+func (in *JWTForwardingConfig) DeepCopy() *JWTForwardingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTForwardingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTLocation) DeepCopyInto(out *JWTLocation) {
+	*out = *in
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(JWTLocationHeader)
+		**out = **in
+	}
+	if in.QueryParam != nil {
+		in, out := &in.QueryParam, &out.QueryParam
+		*out = new(JWTLocationQueryParam)
+		**out = **in
+	}
+	if in.Cookie != nil {
+		in, out := &in.Cookie, &out.Cookie
+		*out = new(JWTLocationCookie)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTLocation.
+func (in *JWTLocation) DeepCopy() *JWTLocation {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTLocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTLocationCookie) DeepCopyInto(out *JWTLocationCookie) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTLocationCookie.
+func (in *JWTLocationCookie) DeepCopy() *JWTLocationCookie {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTLocationCookie)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTLocationHeader) DeepCopyInto(out *JWTLocationHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTLocationHeader.
+func (in *JWTLocationHeader) DeepCopy() *JWTLocationHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTLocationHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTLocationQueryParam) DeepCopyInto(out *JWTLocationQueryParam) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTLocationQueryParam.
+func (in *JWTLocationQueryParam) DeepCopy() *JWTLocationQueryParam {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTLocationQueryParam)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTProvider) DeepCopyInto(out *JWTProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProvider.
+func (in *JWTProvider) DeepCopy() *JWTProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTProviderList) DeepCopyInto(out *JWTProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]JWTProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProviderList.
+func (in *JWTProviderList) DeepCopy() *JWTProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JWTProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTProviderSpec) DeepCopyInto(out *JWTProviderSpec) {
+	*out = *in
+	if in.JSONWebKeySet != nil {
+		in, out := &in.JSONWebKeySet, &out.JSONWebKeySet
+		*out = new(JSONWebKeySet)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]*JWTLocation, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(JWTLocation)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.Forwarding != nil {
+		in, out := &in.Forwarding, &out.Forwarding
+		*out = new(JWTForwardingConfig)
+		**out = **in
+	}
+	if in.CacheConfig != nil {
+		in, out := &in.CacheConfig, &out.CacheConfig
+		*out = new(JWTCacheConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTProviderSpec.
+func (in *JWTProviderSpec) DeepCopy() *JWTProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LeastRequestConfig) DeepCopyInto(out *LeastRequestConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeastRequestConfig.
+func (in *LeastRequestConfig) DeepCopy() *LeastRequestConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LeastRequestConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinkedService) DeepCopyInto(out *LinkedService) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinkedService.
+func (in *LinkedService) DeepCopy() *LinkedService {
+	if in == nil {
+		return nil
+	}
+	out := new(LinkedService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancer) DeepCopyInto(out *LoadBalancer) {
+	*out = *in
+	if in.RingHashConfig != nil {
+		in, out := &in.RingHashConfig, &out.RingHashConfig
+		*out = new(RingHashConfig)
+		**out = **in
+	}
+	if in.LeastRequestConfig != nil {
+		in, out := &in.LeastRequestConfig, &out.LeastRequestConfig
+		*out = new(LeastRequestConfig)
+		**out = **in
+	}
+	if in.HashPolicies != nil {
+		in, out := &in.HashPolicies, &out.HashPolicies
+		*out = make([]HashPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancer.
+func (in *LoadBalancer) DeepCopy() *LoadBalancer {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalJWKS) DeepCopyInto(out *LocalJWKS) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalJWKS.
+func (in *LocalJWKS) DeepCopy() *LocalJWKS {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalJWKS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Mesh) DeepCopyInto(out *Mesh) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Mesh.
+func (in *Mesh) DeepCopy() *Mesh {
+	if in == nil {
+		return nil
+	}
+	out := new(Mesh)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Mesh) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshDirectionalTLSConfig) DeepCopyInto(out *MeshDirectionalTLSConfig) {
+	*out = *in
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshDirectionalTLSConfig.
+func (in *MeshDirectionalTLSConfig) DeepCopy() *MeshDirectionalTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshDirectionalTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshGateway) DeepCopyInto(out *MeshGateway) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshGateway.
+func (in *MeshGateway) DeepCopy() *MeshGateway {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshGateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshHTTPConfig) DeepCopyInto(out *MeshHTTPConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshHTTPConfig.
+func (in *MeshHTTPConfig) DeepCopy() *MeshHTTPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshHTTPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshList) DeepCopyInto(out *MeshList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Mesh, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshList.
+func (in *MeshList) DeepCopy() *MeshList {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MeshList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshSpec) DeepCopyInto(out *MeshSpec) {
+	*out = *in
+	out.TransparentProxy = in.TransparentProxy
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(MeshTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(MeshHTTPConfig)
+		**out = **in
+	}
+	if in.Peering != nil {
+		in, out := &in.Peering, &out.Peering
+		*out = new(PeeringMeshConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshSpec.
+func (in *MeshSpec) DeepCopy() *MeshSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MeshTLSConfig) DeepCopyInto(out *MeshTLSConfig) {
+	*out = *in
+	if in.Incoming != nil {
+		in, out := &in.Incoming, &out.Incoming
+		*out = new(MeshDirectionalTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Outgoing != nil {
+		in, out := &in.Outgoing, &out.Outgoing
+		*out = new(MeshDirectionalTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshTLSConfig.
+func (in *MeshTLSConfig) DeepCopy() *MeshTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MeshTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassiveHealthCheck) DeepCopyInto(out *PassiveHealthCheck) {
+	*out = *in
+	out.Interval = in.Interval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassiveHealthCheck.
+func (in *PassiveHealthCheck) DeepCopy() *PassiveHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(PassiveHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeeringConnection) DeepCopyInto(out *PeeringConnection) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringConnection.
+func (in *PeeringConnection) DeepCopy() *PeeringConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(PeeringConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PeeringConnection) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeeringConnectionList) DeepCopyInto(out *PeeringConnectionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PeeringConnection, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringConnectionList.
+func (in *PeeringConnectionList) DeepCopy() *PeeringConnectionList {
 	if in == nil {
 		return nil
 	}
-	out := new(IntentionHTTPHeaderPermissions)
+	out := new(PeeringConnectionList)
 	in.DeepCopyInto(out)
-	return *out
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PeeringConnectionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IntentionHTTPPermission) DeepCopyInto(out *IntentionHTTPPermission) {
+func (in *PeeringConnectionSpec) DeepCopyInto(out *PeeringConnectionSpec) {
 	*out = *in
-	if in.Header != nil {
-		in, out := &in.Header, &out.Header
-		*out = make(IntentionHTTPHeaderPermissions, len(*in))
-		copy(*out, *in)
+	out.TokenSecret = in.TokenSecret
+	if in.Remote != nil {
+		in, out := &in.Remote, &out.Remote
+		*out = new(PeeringRemote)
+		**out = **in
 	}
-	if in.Methods != nil {
-		in, out := &in.Methods, &out.Methods
+	if in.ServerExternalAddresses != nil {
+		in, out := &in.ServerExternalAddresses, &out.ServerExternalAddresses
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ExportedServices != nil {
+		in, out := &in.ExportedServices, &out.ExportedServices
+		*out = make([]PeeringExportedService, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionHTTPPermission.
-func (in *IntentionHTTPPermission) DeepCopy() *IntentionHTTPPermission {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringConnectionSpec.
+func (in *PeeringConnectionSpec) DeepCopy() *PeeringConnectionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(IntentionHTTPPermission)
+	out := new(PeeringConnectionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IntentionPermission) DeepCopyInto(out *IntentionPermission) {
+func (in *PeeringConnectionStatus) DeepCopyInto(out *PeeringConnectionStatus) {
 	*out = *in
-	if in.HTTP != nil {
-		in, out := &in.HTTP, &out.HTTP
-		*out = new(IntentionHTTPPermission)
-		(*in).DeepCopyInto(*out)
-	}
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionPermission.
-func (in *IntentionPermission) DeepCopy() *IntentionPermission {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringConnectionStatus.
+func (in *PeeringConnectionStatus) DeepCopy() *PeeringConnectionStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(IntentionPermission)
+	out := new(PeeringConnectionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in IntentionPermissions) DeepCopyInto(out *IntentionPermissions) {
-	{
-		in := &in
-		*out = make(IntentionPermissions, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(IntentionPermission)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
+func (in *PeeringExportedService) DeepCopyInto(out *PeeringExportedService) {
+	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntentionPermissions.
-func (in IntentionPermissions) DeepCopy() IntentionPermissions {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringExportedService.
+func (in *PeeringExportedService) DeepCopy() *PeeringExportedService {
 	if in == nil {
 		return nil
 	}
-	out := new(IntentionPermissions)
+	out := new(PeeringExportedService)
 	in.DeepCopyInto(out)
-	return *out
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LeastRequestConfig) DeepCopyInto(out *LeastRequestConfig) {
+func (in *PeeringMeshConfig) DeepCopyInto(out *PeeringMeshConfig) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeastRequestConfig.
-func (in *LeastRequestConfig) DeepCopy() *LeastRequestConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringMeshConfig.
+func (in *PeeringMeshConfig) DeepCopy() *PeeringMeshConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(LeastRequestConfig)
+	out := new(PeeringMeshConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LinkedService) DeepCopyInto(out *LinkedService) {
+func (in *PeeringRemote) DeepCopyInto(out *PeeringRemote) {
 	*out = *in
+	out.KubeconfigSecret = in.KubeconfigSecret
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinkedService.
-func (in *LinkedService) DeepCopy() *LinkedService {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringRemote.
+func (in *PeeringRemote) DeepCopy() *PeeringRemote {
 	if in == nil {
 		return nil
 	}
-	out := new(LinkedService)
+	out := new(PeeringRemote)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadBalancer) DeepCopyInto(out *LoadBalancer) {
+func (in *PeeringSecretRef) DeepCopyInto(out *PeeringSecretRef) {
 	*out = *in
-	if in.RingHashConfig != nil {
-		in, out := &in.RingHashConfig, &out.RingHashConfig
-		*out = new(RingHashConfig)
-		**out = **in
-	}
-	if in.LeastRequestConfig != nil {
-		in, out := &in.LeastRequestConfig, &out.LeastRequestConfig
-		*out = new(LeastRequestConfig)
-		**out = **in
-	}
-	if in.HashPolicies != nil {
-		in, out := &in.HashPolicies, &out.HashPolicies
-		*out = make([]HashPolicy, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancer.
-func (in *LoadBalancer) DeepCopy() *LoadBalancer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeeringSecretRef.
+func (in *PeeringSecretRef) DeepCopy() *PeeringSecretRef {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadBalancer)
+	out := new(PeeringSecretRef)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Mesh) DeepCopyInto(out *Mesh) {
+func (in *ProxyDefaults) DeepCopyInto(out *ProxyDefaults) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -647,18 +1628,18 @@ func (in *Mesh) DeepCopyInto(out *Mesh) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Mesh.
-func (in *Mesh) DeepCopy() *Mesh {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaults.
+func (in *ProxyDefaults) DeepCopy() *ProxyDefaults {
 	if in == nil {
 		return nil
 	}
-	out := new(Mesh)
+	out := new(ProxyDefaults)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Mesh) DeepCopyObject() runtime.Object {
+func (in *ProxyDefaults) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -666,136 +1647,149 @@ func (in *Mesh) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MeshDirectionalTLSConfig) DeepCopyInto(out *MeshDirectionalTLSConfig) {
+func (in *ProxyDefaultsList) DeepCopyInto(out *ProxyDefaultsList) {
 	*out = *in
-	if in.CipherSuites != nil {
-		in, out := &in.CipherSuites, &out.CipherSuites
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProxyDefaults, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshDirectionalTLSConfig.
-func (in *MeshDirectionalTLSConfig) DeepCopy() *MeshDirectionalTLSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaultsList.
+func (in *ProxyDefaultsList) DeepCopy() *ProxyDefaultsList {
 	if in == nil {
 		return nil
 	}
-	out := new(MeshDirectionalTLSConfig)
+	out := new(ProxyDefaultsList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProxyDefaultsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MeshGateway) DeepCopyInto(out *MeshGateway) {
+func (in *ProxyDefaultsSpec) DeepCopyInto(out *ProxyDefaultsSpec) {
 	*out = *in
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(ProxyMode)
+		**out = **in
+	}
+	if in.TransparentProxy != nil {
+		in, out := &in.TransparentProxy, &out.TransparentProxy
+		*out = new(TransparentProxy)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(json.RawMessage, len(*in))
+		copy(*out, *in)
+	}
+	out.MeshGateway = in.MeshGateway
+	in.Expose.DeepCopyInto(&out.Expose)
+	if in.PrioritizeByLocality != nil {
+		in, out := &in.PrioritizeByLocality, &out.PrioritizeByLocality
+		*out = new(ServiceResolverPrioritizeByLocality)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshGateway.
-func (in *MeshGateway) DeepCopy() *MeshGateway {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaultsSpec.
+func (in *ProxyDefaultsSpec) DeepCopy() *ProxyDefaultsSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MeshGateway)
+	out := new(ProxyDefaultsSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MeshList) DeepCopyInto(out *MeshList) {
+func (in *ReadWriteRatesConfig) DeepCopyInto(out *ReadWriteRatesConfig) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Mesh, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshList.
-func (in *MeshList) DeepCopy() *MeshList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadWriteRatesConfig.
+func (in *ReadWriteRatesConfig) DeepCopy() *ReadWriteRatesConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(MeshList)
+	out := new(ReadWriteRatesConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MeshList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MeshSpec) DeepCopyInto(out *MeshSpec) {
+func (in *RemoteJWKS) DeepCopyInto(out *RemoteJWKS) {
 	*out = *in
-	out.TransparentProxy = in.TransparentProxy
-	if in.TLS != nil {
-		in, out := &in.TLS, &out.TLS
-		*out = new(MeshTLSConfig)
+	out.CacheDuration = in.CacheDuration
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(JWKSRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JWKSCluster != nil {
+		in, out := &in.JWKSCluster, &out.JWKSCluster
+		*out = new(JWKSCluster)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshSpec.
-func (in *MeshSpec) DeepCopy() *MeshSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteJWKS.
+func (in *RemoteJWKS) DeepCopy() *RemoteJWKS {
 	if in == nil {
 		return nil
 	}
-	out := new(MeshSpec)
+	out := new(RemoteJWKS)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MeshTLSConfig) DeepCopyInto(out *MeshTLSConfig) {
+func (in *RetryPolicyBackOff) DeepCopyInto(out *RetryPolicyBackOff) {
 	*out = *in
-	if in.Incoming != nil {
-		in, out := &in.Incoming, &out.Incoming
-		*out = new(MeshDirectionalTLSConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Outgoing != nil {
-		in, out := &in.Outgoing, &out.Outgoing
-		*out = new(MeshDirectionalTLSConfig)
-		(*in).DeepCopyInto(*out)
-	}
+	out.BaseInterval = in.BaseInterval
+	out.MaxInterval = in.MaxInterval
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MeshTLSConfig.
-func (in *MeshTLSConfig) DeepCopy() *MeshTLSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicyBackOff.
+func (in *RetryPolicyBackOff) DeepCopy() *RetryPolicyBackOff {
 	if in == nil {
 		return nil
 	}
-	out := new(MeshTLSConfig)
+	out := new(RetryPolicyBackOff)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PassiveHealthCheck) DeepCopyInto(out *PassiveHealthCheck) {
+func (in *RingHashConfig) DeepCopyInto(out *RingHashConfig) {
 	*out = *in
-	out.Interval = in.Interval
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassiveHealthCheck.
-func (in *PassiveHealthCheck) DeepCopy() *PassiveHealthCheck {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RingHashConfig.
+func (in *RingHashConfig) DeepCopy() *RingHashConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(PassiveHealthCheck)
+	out := new(RingHashConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyDefaults) DeepCopyInto(out *ProxyDefaults) {
+func (in *SamenessGroup) DeepCopyInto(out *SamenessGroup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -803,18 +1797,18 @@ func (in *ProxyDefaults) DeepCopyInto(out *ProxyDefaults) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaults.
-func (in *ProxyDefaults) DeepCopy() *ProxyDefaults {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SamenessGroup.
+func (in *SamenessGroup) DeepCopy() *SamenessGroup {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyDefaults)
+	out := new(SamenessGroup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ProxyDefaults) DeepCopyObject() runtime.Object {
+func (in *SamenessGroup) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -822,31 +1816,31 @@ func (in *ProxyDefaults) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyDefaultsList) DeepCopyInto(out *ProxyDefaultsList) {
+func (in *SamenessGroupList) DeepCopyInto(out *SamenessGroupList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ProxyDefaults, len(*in))
+		*out = make([]SamenessGroup, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaultsList.
-func (in *ProxyDefaultsList) DeepCopy() *ProxyDefaultsList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SamenessGroupList.
+func (in *SamenessGroupList) DeepCopy() *SamenessGroupList {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyDefaultsList)
+	out := new(SamenessGroupList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ProxyDefaultsList) DeepCopyObject() runtime.Object {
+func (in *SamenessGroupList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -854,48 +1848,36 @@ func (in *ProxyDefaultsList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProxyDefaultsSpec) DeepCopyInto(out *ProxyDefaultsSpec) {
+func (in *SamenessGroupMember) DeepCopyInto(out *SamenessGroupMember) {
 	*out = *in
-	if in.Mode != nil {
-		in, out := &in.Mode, &out.Mode
-		*out = new(ProxyMode)
-		**out = **in
-	}
-	if in.TransparentProxy != nil {
-		in, out := &in.TransparentProxy, &out.TransparentProxy
-		*out = new(TransparentProxy)
-		**out = **in
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = make(json.RawMessage, len(*in))
-		copy(*out, *in)
-	}
-	out.MeshGateway = in.MeshGateway
-	in.Expose.DeepCopyInto(&out.Expose)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyDefaultsSpec.
-func (in *ProxyDefaultsSpec) DeepCopy() *ProxyDefaultsSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SamenessGroupMember.
+func (in *SamenessGroupMember) DeepCopy() *SamenessGroupMember {
 	if in == nil {
 		return nil
 	}
-	out := new(ProxyDefaultsSpec)
+	out := new(SamenessGroupMember)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RingHashConfig) DeepCopyInto(out *RingHashConfig) {
+func (in *SamenessGroupSpec) DeepCopyInto(out *SamenessGroupSpec) {
 	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]SamenessGroupMember, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RingHashConfig.
-func (in *RingHashConfig) DeepCopy() *RingHashConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SamenessGroupSpec.
+func (in *SamenessGroupSpec) DeepCopy() *SamenessGroupSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(RingHashConfig)
+	out := new(SamenessGroupSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1080,6 +2062,11 @@ func (in *ServiceIntentionsSpec) DeepCopyInto(out *ServiceIntentionsSpec) {
 			}
 		}
 	}
+	if in.JWT != nil {
+		in, out := &in.JWT, &out.JWT
+		*out = new(IntentionJWTRequirement)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceIntentionsSpec.
@@ -1192,6 +2179,21 @@ func (in *ServiceResolverList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceResolverPrioritizeByLocality) DeepCopyInto(out *ServiceResolverPrioritizeByLocality) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResolverPrioritizeByLocality.
+func (in *ServiceResolverPrioritizeByLocality) DeepCopy() *ServiceResolverPrioritizeByLocality {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceResolverPrioritizeByLocality)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceResolverRedirect) DeepCopyInto(out *ServiceResolverRedirect) {
 	*out = *in
@@ -1235,6 +2237,11 @@ func (in *ServiceResolverSpec) DeepCopyInto(out *ServiceResolverSpec) {
 		*out = new(LoadBalancer)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PrioritizeByLocality != nil {
+		in, out := &in.PrioritizeByLocality, &out.PrioritizeByLocality
+		*out = new(ServiceResolverPrioritizeByLocality)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceResolverSpec.
@@ -1771,6 +2778,11 @@ func (in *TerminatingGatewaySpec) DeepCopyInto(out *TerminatingGatewaySpec) {
 		*out = make([]LinkedService, len(*in))
 		copy(*out, *in)
 	}
+	if in.ExternalServices != nil {
+		in, out := &in.ExternalServices, &out.ExternalServices
+		*out = make([]ExternalService, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TerminatingGatewaySpec.
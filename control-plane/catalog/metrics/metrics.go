@@ -0,0 +1,110 @@
+// Package metrics defines the Prometheus metrics of catalog sync.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DirectionToConsul is the direction label of the metrics of syncing
+	// Kubernetes services to Consul.
+	DirectionToConsul = "to-consul"
+
+	// DirectionToK8S is the direction label of the metrics of syncing
+	// Consul services to Kubernetes.
+	DirectionToK8S = "to-k8s"
+)
+
+const (
+	namespace = "consul_k8s"
+	subsystem = "catalog_sync"
+)
+
+// Metrics are the metrics of catalog sync. The syncers of both directions
+// share a Metrics, so the metrics that apply to both have a direction label.
+type Metrics struct {
+	// WriteQueueDepth is the number of service instances waiting to be
+	// registered or deregistered in Consul.
+	WriteQueueDepth prometheus.Gauge
+
+	// LastSuccessfulSync is the Unix time of the last full sync that was
+	// completed without errors.
+	LastSuccessfulSync *prometheus.GaugeVec
+
+	// Registrations is the number of services, or service instances for
+	// the to-consul direction, that are synced.
+	Registrations *prometheus.GaugeVec
+
+	// Writes is the number of successful writes by operation.
+	Writes *prometheus.CounterVec
+
+	// APIErrors is the number of failed requests by operation.
+	APIErrors *prometheus.CounterVec
+
+	// Drift is the number of services, or service instances for the
+	// to-consul direction, that are known to differ between Kubernetes and
+	// Consul and have not been synced yet.
+	Drift *prometheus.GaugeVec
+}
+
+// New returns the metrics of catalog sync. They must be registered with
+// Register to be exported.
+func New() *Metrics {
+	labels := []string{"direction"}
+	opLabels := []string{"direction", "operation"}
+	return &Metrics{
+		WriteQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "write_queue_depth",
+			Help:      "Number of service instances waiting to be registered or deregistered in Consul.",
+		}),
+		LastSuccessfulSync: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "last_successful_sync_timestamp_seconds",
+			Help:      "Unix time of the last full sync that was completed without errors.",
+		}, labels),
+		Registrations: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "registrations",
+			Help:      "Number of synced services, or service instances when syncing to Consul.",
+		}, labels),
+		Writes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "writes_total",
+			Help:      "Number of successful writes by operation.",
+		}, opLabels),
+		APIErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "api_errors_total",
+			Help:      "Number of failed API requests by operation.",
+		}, opLabels),
+		Drift: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "drift",
+			Help:      "Number of services, or service instances when syncing to Consul, that differ between Kubernetes and Consul and have not been synced yet.",
+		}, labels),
+	}
+}
+
+// Register registers the metrics with the registerer.
+func (m *Metrics) Register(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		m.WriteQueueDepth,
+		m.LastSuccessfulSync,
+		m.Registrations,
+		m.Writes,
+		m.APIErrors,
+		m.Drift,
+	} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
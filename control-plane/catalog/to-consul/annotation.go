@@ -6,6 +6,12 @@ const (
 	// the default based on the syncer configuration is chosen.
 	annotationServiceSync = "consul.hashicorp.com/service-sync"
 
+	// annotationServiceSyncConflictPolicy overrides the policy for resolving
+	// conflicts with a Consul service of the same name that has instances
+	// that were not registered from Kubernetes. Valid values are "k8s-wins",
+	// "consul-wins", "merge" and "error".
+	annotationServiceSyncConflictPolicy = "consul.hashicorp.com/service-sync-conflict-policy"
+
 	// annotationServiceName is set to override the name of the service
 	// registered. By default this will be the name of the Service resource.
 	annotationServiceName = "consul.hashicorp.com/service-name"
@@ -21,6 +27,37 @@ const (
 	annotationServiceTags = "consul.hashicorp.com/service-tags"
 
 	// annotationServiceMetaPrefix is the prefix for setting meta key/value
-	// for a service. The remainder of the key is the meta key.
+	// for a service. The remainder of the key is the meta key. It can also
+	// be set on pods to set meta on the instances of their endpoints.
 	annotationServiceMetaPrefix = "consul.hashicorp.com/service-meta-"
+
+	// annotationServiceWeightsPassing and annotationServiceWeightsWarning set
+	// the weights of the registered service instances when their health is
+	// passing or warning. They can also be set on pods to set the weights of
+	// the instances of their endpoints.
+	annotationServiceWeightsPassing = "consul.hashicorp.com/service-weights-passing"
+	annotationServiceWeightsWarning = "consul.hashicorp.com/service-weights-warning"
+
+	// annotationServiceTaggedAddressPrefix is the prefix for setting tagged
+	// addresses of a service. The remainder of the key is the tag and the
+	// value is an address with an optional port, e.g. "10.0.0.1:8080". The
+	// port of the instance is used if the port is omitted. It can also be set
+	// on pods to set tagged addresses on the instances of their endpoints.
+	annotationServiceTaggedAddressPrefix = "consul.hashicorp.com/service-tagged-address-"
+
+	// annotationExternalNameHealthCheck specifies whether to register a TCP
+	// health check against the external name and port of an ExternalName
+	// service. The check is run by consul-esm. This should be set to a truthy
+	// or falsy value, as parseable by strconv.ParseBool.
+	annotationExternalNameHealthCheck = "consul.hashicorp.com/external-name-health-check"
+
+	// annotationServiceHealthCheckName overrides the name of the health check
+	// that reflects the readiness of the synced service instances.
+	annotationServiceHealthCheckName = "consul.hashicorp.com/service-health-check-name"
+
+	// annotationServiceHealthCheckContainers is a comma separated list of
+	// containers. If set, the health checks of the synced service instances
+	// are passing only if these containers of the instance's pod are ready,
+	// instead of reflecting the readiness of the pod.
+	annotationServiceHealthCheckContainers = "consul.hashicorp.com/service-health-check-containers"
 )
@@ -0,0 +1,68 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictPolicy determines how to sync a Kubernetes service to Consul when
+// the Consul service of the same name also has instances that were not
+// registered from Kubernetes.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyK8SWins registers the Kubernetes instances alongside the
+	// Consul instances and never syncs the Consul service back to Kubernetes
+	// while the Kubernetes service is synced, even if it has no endpoints.
+	ConflictPolicyK8SWins ConflictPolicy = "k8s-wins"
+
+	// ConflictPolicyConsulWins does not register the Kubernetes instances and
+	// deregisters any that were registered previously, so that the Consul
+	// service can be synced to Kubernetes instead.
+	ConflictPolicyConsulWins ConflictPolicy = "consul-wins"
+
+	// ConflictPolicyMerge registers the Kubernetes instances alongside the
+	// Consul instances. The Consul service is synced to Kubernetes whenever
+	// it doesn't have any instances registered from Kubernetes.
+	ConflictPolicyMerge ConflictPolicy = "merge"
+
+	// ConflictPolicyError does not sync the service in either direction and
+	// logs an error so that the conflict can be resolved manually.
+	ConflictPolicyError ConflictPolicy = "error"
+)
+
+// ConflictPolicies is the list of valid conflict policies.
+var ConflictPolicies = []ConflictPolicy{
+	ConflictPolicyK8SWins,
+	ConflictPolicyConsulWins,
+	ConflictPolicyMerge,
+	ConflictPolicyError,
+}
+
+// ParseConflictPolicy returns the conflict policy with the given name or an
+// error if it isn't a valid conflict policy.
+func ParseConflictPolicy(raw string) (ConflictPolicy, error) {
+	for _, p := range ConflictPolicies {
+		if string(p) == raw {
+			return p, nil
+		}
+	}
+	valid := make([]string, 0, len(ConflictPolicies))
+	for _, p := range ConflictPolicies {
+		valid = append(valid, string(p))
+	}
+	return "", fmt.Errorf("invalid conflict policy %q, must be one of %s", raw, strings.Join(valid, ", "))
+}
+
+// excludesFromK8S returns true if the Consul service of the same name as a
+// Kubernetes service with this policy should never be synced to Kubernetes.
+func (p ConflictPolicy) excludesFromK8S() bool {
+	return p == ConflictPolicyK8SWins || p == ConflictPolicyError
+}
+
+// yieldsToConsul returns true if the Kubernetes instances of a service with
+// this policy should not be registered when the Consul service has instances
+// that were not registered from Kubernetes.
+func (p ConflictPolicy) yieldsToConsul() bool {
+	return p == ConflictPolicyConsulWins || p == ConflictPolicyError
+}
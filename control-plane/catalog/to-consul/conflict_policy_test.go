@@ -0,0 +1,22 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, p := range ConflictPolicies {
+		t.Run(string(p), func(t *testing.T) {
+			actual, err := ParseConflictPolicy(string(p))
+			require.NoError(t, err)
+			require.Equal(t, p, actual)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseConflictPolicy("k8s-loses")
+		require.EqualError(t, err, `invalid conflict policy "k8s-loses", must be one of k8s-wins, consul-wins, merge, error`)
+	})
+}
@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	consulapi "github.com/hashicorp/consul/api"
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+const (
+	// DefaultHealthCheckName is the default name of the health check that
+	// reflects the readiness of a synced service instance.
+	DefaultHealthCheckName = "Kubernetes Readiness Check"
+
+	// kubernetesReadyReasonMsg is the output of passing readiness checks.
+	kubernetesReadyReasonMsg = "Kubernetes endpoint is ready"
+)
+
+// healthCheckFailure tracks since when a service instance has been observed
+// not ready.
+type healthCheckFailure struct {
+	// since is when the instance was first observed not ready.
+	since time.Time
+
+	// timer regenerates the registrations of the instance's service once
+	// the instance has been not ready for HealthCheckCriticalAfter so that
+	// its check becomes critical even if its endpoint slice doesn't change.
+	timer *time.Timer
+}
+
+// readinessHealthCheck returns the health check to register for the service
+// instance of the given endpoint. The check is passing if the endpoint is
+// ready. If the service is annotated with a list of containers, the check is
+// passing only if those containers of the endpoint's pod are ready instead,
+// and pod must be the endpoint's pod or nil if it couldn't be read.
+// A check that isn't passing is warning until the instance has been not ready
+// for HealthCheckCriticalAfter, and critical after that.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) readinessHealthCheck(
+	key string,
+	svc *apiv1.Service,
+	pod *apiv1.Pod,
+	endpoint discoveryv1.Endpoint,
+	node string,
+	service *consulapi.AgentService) *consulapi.AgentCheck {
+
+	ready, output := endpointHealth(svc, pod, endpoint)
+	status := consulapi.HealthPassing
+	if !ready {
+		status = consulapi.HealthCritical
+		if t.observeHealthCheckFailure(key, service.ID) < t.HealthCheckCriticalAfter {
+			status = consulapi.HealthWarning
+		}
+	} else {
+		t.forgetHealthCheckFailure(key, service.ID)
+	}
+
+	name := t.HealthCheckName
+	if name == "" {
+		name = DefaultHealthCheckName
+	}
+	if raw, ok := svc.Annotations[annotationServiceHealthCheckName]; ok && raw != "" {
+		name = raw
+	}
+
+	return &consulapi.AgentCheck{
+		Node:        node,
+		CheckID:     fmt.Sprintf("%s/kubernetes-readiness-check", service.ID),
+		Name:        name,
+		Status:      status,
+		Output:      output,
+		ServiceID:   service.ID,
+		ServiceName: service.Service,
+		Namespace:   service.Namespace,
+		Partition:   service.Partition,
+	}
+}
+
+// endpointHealth returns whether the endpoint is healthy along with the
+// reason why.
+func endpointHealth(svc *apiv1.Service, pod *apiv1.Pod, endpoint discoveryv1.Endpoint) (bool, string) {
+	raw, ok := svc.Annotations[annotationServiceHealthCheckContainers]
+	if !ok || strings.TrimSpace(raw) == "" {
+		if !endpointReady(endpoint) {
+			return false, "Kubernetes endpoint is not ready"
+		}
+		return true, kubernetesReadyReasonMsg
+	}
+
+	if pod == nil {
+		return false, "Pod of the Kubernetes endpoint could not be read"
+	}
+
+	statuses := make(map[string]bool, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status.Ready
+	}
+	for _, container := range parseTags(raw) {
+		ready, ok := statuses[container]
+		if !ok {
+			return false, fmt.Sprintf("Container %q of pod \"%s/%s\" has no status", container, pod.Namespace, pod.Name)
+		}
+		if !ready {
+			return false, fmt.Sprintf("Container %q of pod \"%s/%s\" is not ready", container, pod.Namespace, pod.Name)
+		}
+	}
+	return true, kubernetesReadyReasonMsg
+}
+
+// observeHealthCheckFailure records that the service instance with the given
+// ID was observed not ready and returns for how long it has been not ready.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) observeHealthCheckFailure(key, serviceID string) time.Duration {
+	if t.healthCheckFailures == nil {
+		t.healthCheckFailures = make(map[string]map[string]*healthCheckFailure)
+	}
+	if t.healthCheckFailures[key] == nil {
+		t.healthCheckFailures[key] = make(map[string]*healthCheckFailure)
+	}
+
+	failure, ok := t.healthCheckFailures[key][serviceID]
+	if !ok {
+		failure = &healthCheckFailure{since: time.Now()}
+		if t.HealthCheckCriticalAfter > 0 {
+			failure.timer = time.AfterFunc(t.HealthCheckCriticalAfter, func() {
+				t.resyncHealthChecks(key)
+			})
+		}
+		t.healthCheckFailures[key][serviceID] = failure
+	}
+	return time.Since(failure.since)
+}
+
+// forgetHealthCheckFailure forgets that the service instance with the given
+// ID was observed not ready.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) forgetHealthCheckFailure(key, serviceID string) {
+	failure, ok := t.healthCheckFailures[key][serviceID]
+	if !ok {
+		return
+	}
+	if failure.timer != nil {
+		failure.timer.Stop()
+	}
+	delete(t.healthCheckFailures[key], serviceID)
+	if len(t.healthCheckFailures[key]) == 0 {
+		delete(t.healthCheckFailures, key)
+	}
+}
+
+// pruneHealthCheckFailures forgets the failures of service instances of the
+// given service that are no longer registered.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) pruneHealthCheckFailures(key string) {
+	registered := make(map[string]struct{}, len(t.consulMap[key]))
+	for _, r := range t.consulMap[key] {
+		registered[r.Service.ID] = struct{}{}
+	}
+	for id := range t.healthCheckFailures[key] {
+		if _, ok := registered[id]; !ok {
+			t.forgetHealthCheckFailure(key, id)
+		}
+	}
+}
+
+// resyncHealthChecks regenerates and syncs the registrations of the given
+// service so that the checks of instances that have been not ready for
+// HealthCheckCriticalAfter become critical.
+func (t *ServiceResource) resyncHealthChecks(key string) {
+	t.serviceLock.Lock()
+	defer t.serviceLock.Unlock()
+
+	if t.Ctx.Err() != nil {
+		return
+	}
+	if _, ok := t.serviceMap[key]; !ok {
+		return
+	}
+	t.generateRegistrations(key)
+	t.sync()
+	t.Log.Debug("[resyncHealthChecks] resynced health checks", controller.ResourceLogArgs(key)...)
+}
@@ -0,0 +1,264 @@
+package catalog
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	consulapi "github.com/hashicorp/consul/api"
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// applyEndpointDetails sets the fields of the registration of a service
+// instance that depend on the instance's endpoint: the meta, weights and
+// tagged addresses of the endpoint's pod annotations if SyncPodAnnotations
+// is true, and the readiness health check if SyncHealthChecks is true.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) applyEndpointDetails(
+	key string,
+	svc *apiv1.Service,
+	slice *discoveryv1.EndpointSlice,
+	endpoint discoveryv1.Endpoint,
+	r *consulapi.CatalogRegistration) {
+
+	// Only read the pod if we need it since this is done for every endpoint.
+	var pod *apiv1.Pod
+	_, checkContainers := svc.Annotations[annotationServiceHealthCheckContainers]
+	if t.SyncPodAnnotations || (t.SyncHealthChecks && checkContainers) {
+		var err error
+		pod, err = t.endpointPod(svc, endpoint)
+		if err != nil {
+			t.Log.With(controller.ResourceLogArgs(key)...).Warn("error getting pod of endpoint", "err", err)
+		}
+	}
+
+	if t.SyncPodAnnotations && pod != nil {
+		t.applyInstanceAnnotations(key, r.Service, pod.Annotations)
+	}
+	if t.SyncHealthChecks {
+		r.Check = t.readinessHealthCheck(key, svc, pod, endpoint, r.Node, r.Service)
+	}
+}
+
+// endpointPod returns the pod that the endpoint references from the pods
+// watched by the ServiceResource.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) endpointPod(svc *apiv1.Service, endpoint discoveryv1.Endpoint) (*apiv1.Pod, error) {
+	podKey, ok := endpointPodKey(svc.Namespace, endpoint)
+	if !ok {
+		return nil, fmt.Errorf("endpoint does not reference a pod")
+	}
+	pod, ok := t.podMap[podKey]
+	if !ok {
+		return nil, fmt.Errorf("pod %q is not known yet", podKey)
+	}
+	return pod, nil
+}
+
+// endpointPodKey returns the key of the pod that the endpoint references,
+// in the form <kube namespace>/<kube pod name>, and whether the endpoint
+// references a pod. namespace is the namespace of the endpoint's slice.
+func endpointPodKey(namespace string, endpoint discoveryv1.Endpoint) (string, bool) {
+	if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" {
+		return "", false
+	}
+	if endpoint.TargetRef.Namespace != "" {
+		namespace = endpoint.TargetRef.Namespace
+	}
+	return fmt.Sprintf("%s/%s", namespace, endpoint.TargetRef.Name), true
+}
+
+// podDetailsChanged returns true if the pod changed in a way that changes
+// the registrations of its endpoints: its meta, weights or tagged addresses
+// annotations or the readiness of its containers.
+func podDetailsChanged(old, pod *apiv1.Pod) bool {
+	if !reflect.DeepEqual(instanceAnnotations(old.Annotations), instanceAnnotations(pod.Annotations)) {
+		return true
+	}
+	return !reflect.DeepEqual(containerReadiness(old), containerReadiness(pod))
+}
+
+// instanceAnnotations returns the annotations that applyInstanceAnnotations reads.
+func instanceAnnotations(annotations map[string]string) map[string]string {
+	filtered := make(map[string]string)
+	for k, v := range annotations {
+		if strings.HasPrefix(k, annotationServiceMetaPrefix) ||
+			strings.HasPrefix(k, annotationServiceTaggedAddressPrefix) ||
+			k == annotationServiceWeightsPassing ||
+			k == annotationServiceWeightsWarning {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// containerReadiness returns whether each container of the pod is ready,
+// keyed by container name.
+func containerReadiness(pod *apiv1.Pod) map[string]bool {
+	ready := make(map[string]bool, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		ready[status.Name] = status.Ready
+	}
+	return ready
+}
+
+// applyInstanceAnnotations sets the meta, weights and tagged addresses of
+// the service from the given service or pod annotations. Invalid annotations
+// and meta annotations for the meta keys set by the sync are logged and
+// ignored. The meta and tagged addresses maps are copied
+// before they are modified since they are shared by the instances of a
+// service.
+func (t *ServiceResource) applyInstanceAnnotations(key string, service *consulapi.AgentService, annotations map[string]string) {
+	var meta, taggedAddresses map[string]string
+	for k, v := range annotations {
+		if strings.HasPrefix(k, annotationServiceMetaPrefix) {
+			if meta == nil {
+				meta = make(map[string]string)
+			}
+			metaKey := strings.TrimPrefix(k, annotationServiceMetaPrefix)
+			if isReservedMetaKey(metaKey) {
+				t.Log.With(controller.ResourceLogArgs(key)...).Warn("ignoring meta annotation for a meta key set by the sync", "meta-key", metaKey)
+				continue
+			}
+			meta[metaKey] = v
+		}
+		if strings.HasPrefix(k, annotationServiceTaggedAddressPrefix) {
+			if taggedAddresses == nil {
+				taggedAddresses = make(map[string]string)
+			}
+			taggedAddresses[strings.TrimPrefix(k, annotationServiceTaggedAddressPrefix)] = v
+		}
+	}
+
+	if meta != nil {
+		merged := make(map[string]string, len(service.Meta)+len(meta))
+		for k, v := range service.Meta {
+			merged[k] = v
+		}
+		for k, v := range meta {
+			merged[k] = v
+		}
+		service.Meta = merged
+	}
+
+	if taggedAddresses != nil {
+		merged := make(map[string]consulapi.ServiceAddress, len(service.TaggedAddresses)+len(taggedAddresses))
+		for k, v := range service.TaggedAddresses {
+			merged[k] = v
+		}
+		for tag, raw := range taggedAddresses {
+			address, err := parseTaggedAddress(raw)
+			if err != nil {
+				t.Log.With(controller.ResourceLogArgs(key)...).Warn("error parsing tagged address annotation", "tag", tag, "err", err)
+				continue
+			}
+			merged[tag] = address
+		}
+		service.TaggedAddresses = merged
+	}
+
+	weights, err := parseWeights(annotations, service.Weights)
+	if err != nil {
+		t.Log.With(controller.ResourceLogArgs(key)...).Warn("error parsing weights annotations", "err", err)
+	} else {
+		service.Weights = weights
+	}
+}
+
+// isReservedMetaKey returns true if the meta key is set by the sync to track
+// the registrations it owns, so it can't be overridden by an annotation.
+func isReservedMetaKey(k string) bool {
+	switch k {
+	case ConsulSourceKey, ConsulK8SNS, ConsulK8SCluster, ConsulK8SConflictPolicy:
+		return true
+	}
+	return false
+}
+
+// parseTaggedAddress parses a tagged address in the form "<address>" or
+// "<address>:<port>". The port is 0 if it is omitted.
+func parseTaggedAddress(raw string) (consulapi.ServiceAddress, error) {
+	raw = strings.TrimSpace(raw)
+	host, rawPort, err := net.SplitHostPort(raw)
+	if err != nil {
+		// The address doesn't have a port.
+		address := strings.Trim(raw, "[]")
+		if address == "" {
+			return consulapi.ServiceAddress{}, fmt.Errorf("tagged address %q is missing an address", raw)
+		}
+		return consulapi.ServiceAddress{Address: address}, nil
+	}
+	if host == "" {
+		return consulapi.ServiceAddress{}, fmt.Errorf("tagged address %q is missing an address", raw)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || port < 1 || port > 65535 {
+		return consulapi.ServiceAddress{}, fmt.Errorf("tagged address %q has an invalid port", raw)
+	}
+	return consulapi.ServiceAddress{Address: host, Port: port}, nil
+}
+
+// parseWeights returns the weights set by the weights annotations, using
+// current for the weights that aren't set. Consul's default weights are used
+// if current is empty as well.
+func parseWeights(annotations map[string]string, current consulapi.AgentWeights) (consulapi.AgentWeights, error) {
+	rawPassing, hasPassing := annotations[annotationServiceWeightsPassing]
+	rawWarning, hasWarning := annotations[annotationServiceWeightsWarning]
+	if !hasPassing && !hasWarning {
+		return current, nil
+	}
+
+	weights := current
+	if weights.Passing == 0 && weights.Warning == 0 {
+		weights = consulapi.AgentWeights{Passing: 1, Warning: 1}
+	}
+	if hasPassing {
+		v, err := strconv.Atoi(strings.TrimSpace(rawPassing))
+		if err != nil || v < 1 {
+			return current, fmt.Errorf("passing weight %q must be a positive integer", rawPassing)
+		}
+		weights.Passing = v
+	}
+	if hasWarning {
+		v, err := strconv.Atoi(strings.TrimSpace(rawWarning))
+		if err != nil || v < 0 {
+			return current, fmt.Errorf("warning weight %q must be a non-negative integer", rawWarning)
+		}
+		weights.Warning = v
+	}
+	return weights, nil
+}
+
+// setTaggedAddressPorts sets the port of the tagged addresses that don't
+// have a port to the port of their service instance.
+func setTaggedAddressPorts(registrations []*consulapi.CatalogRegistration) {
+	for _, r := range registrations {
+		var missingPort bool
+		for _, address := range r.Service.TaggedAddresses {
+			if address.Port == 0 {
+				missingPort = true
+				break
+			}
+		}
+		if !missingPort {
+			continue
+		}
+
+		// Copy the tagged addresses since they are shared by the instances
+		// of a service.
+		taggedAddresses := make(map[string]consulapi.ServiceAddress, len(r.Service.TaggedAddresses))
+		for tag, address := range r.Service.TaggedAddresses {
+			if address.Port == 0 {
+				address.Port = r.Service.Port
+			}
+			taggedAddresses[tag] = address
+		}
+		r.Service.TaggedAddresses = taggedAddresses
+	}
+}
@@ -0,0 +1,195 @@
+package catalog
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseTaggedAddress(t *testing.T) {
+	cases := map[string]struct {
+		raw        string
+		expAddress consulapi.ServiceAddress
+		expErr     string
+	}{
+		"address only": {
+			raw:        "10.0.0.1",
+			expAddress: consulapi.ServiceAddress{Address: "10.0.0.1"},
+		},
+		"address and port": {
+			raw:        "10.0.0.1:8080",
+			expAddress: consulapi.ServiceAddress{Address: "10.0.0.1", Port: 8080},
+		},
+		"hostname and port": {
+			raw:        "web.example.com:443",
+			expAddress: consulapi.ServiceAddress{Address: "web.example.com", Port: 443},
+		},
+		"IPv6 address only": {
+			raw:        "[2001:db8::1]",
+			expAddress: consulapi.ServiceAddress{Address: "2001:db8::1"},
+		},
+		"IPv6 address and port": {
+			raw:        "[2001:db8::1]:8080",
+			expAddress: consulapi.ServiceAddress{Address: "2001:db8::1", Port: 8080},
+		},
+		"empty": {
+			raw:    "",
+			expErr: `tagged address "" is missing an address`,
+		},
+		"missing address": {
+			raw:    ":8080",
+			expErr: `tagged address ":8080" is missing an address`,
+		},
+		"invalid port": {
+			raw:    "10.0.0.1:http",
+			expErr: `tagged address "10.0.0.1:http" has an invalid port`,
+		},
+		"port out of range": {
+			raw:    "10.0.0.1:70000",
+			expErr: `tagged address "10.0.0.1:70000" has an invalid port`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			address, err := parseTaggedAddress(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expAddress, address)
+		})
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		current     consulapi.AgentWeights
+		expWeights  consulapi.AgentWeights
+		expErr      string
+	}{
+		"no annotations": {
+			current:    consulapi.AgentWeights{Passing: 5, Warning: 2},
+			expWeights: consulapi.AgentWeights{Passing: 5, Warning: 2},
+		},
+		"passing only": {
+			annotations: map[string]string{annotationServiceWeightsPassing: "10"},
+			expWeights:  consulapi.AgentWeights{Passing: 10, Warning: 1},
+		},
+		"warning only": {
+			annotations: map[string]string{annotationServiceWeightsWarning: "0"},
+			expWeights:  consulapi.AgentWeights{Passing: 1, Warning: 0},
+		},
+		"passing and warning": {
+			annotations: map[string]string{annotationServiceWeightsPassing: "10", annotationServiceWeightsWarning: "3"},
+			expWeights:  consulapi.AgentWeights{Passing: 10, Warning: 3},
+		},
+		"overrides current": {
+			annotations: map[string]string{annotationServiceWeightsWarning: "3"},
+			current:     consulapi.AgentWeights{Passing: 5, Warning: 2},
+			expWeights:  consulapi.AgentWeights{Passing: 5, Warning: 3},
+		},
+		"invalid passing": {
+			annotations: map[string]string{annotationServiceWeightsPassing: "0"},
+			expErr:      `passing weight "0" must be a positive integer`,
+		},
+		"invalid warning": {
+			annotations: map[string]string{annotationServiceWeightsWarning: "-1"},
+			expErr:      `warning weight "-1" must be a non-negative integer`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			weights, err := parseWeights(c.annotations, c.current)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expWeights, weights)
+		})
+	}
+}
+
+func TestApplyInstanceAnnotations_reservedMetaKeys(t *testing.T) {
+	resource := ServiceResource{Log: hclog.NewNullLogger()}
+	service := &consulapi.AgentService{
+		Meta: map[string]string{
+			ConsulSourceKey:         ConsulSourceValue,
+			ConsulK8SNS:             "default",
+			ConsulK8SCluster:        "cluster",
+			ConsulK8SConflictPolicy: string(ConflictPolicyK8SWins),
+		},
+	}
+	resource.applyInstanceAnnotations("default/foo", service, map[string]string{
+		annotationServiceMetaPrefix + ConsulSourceKey:         "other",
+		annotationServiceMetaPrefix + ConsulK8SNS:             "other",
+		annotationServiceMetaPrefix + ConsulK8SCluster:        "other",
+		annotationServiceMetaPrefix + ConsulK8SConflictPolicy: "other",
+		annotationServiceMetaPrefix + "version":               "canary",
+	})
+	require.Equal(t, map[string]string{
+		ConsulSourceKey:         ConsulSourceValue,
+		ConsulK8SNS:             "default",
+		ConsulK8SCluster:        "cluster",
+		ConsulK8SConflictPolicy: string(ConflictPolicyK8SWins),
+		"version":               "canary",
+	}, service.Meta)
+}
+
+func TestPodDetailsChanged(t *testing.T) {
+	pod := func(annotations map[string]string, ready bool) *apiv1.Pod {
+		return &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+			Status: apiv1.PodStatus{
+				ContainerStatuses: []apiv1.ContainerStatus{{Name: "app", Ready: ready}},
+			},
+		}
+	}
+	cases := map[string]struct {
+		old *apiv1.Pod
+		new *apiv1.Pod
+		exp bool
+	}{
+		"unchanged": {
+			old: pod(map[string]string{annotationServiceMetaPrefix + "version": "v1"}, true),
+			new: pod(map[string]string{annotationServiceMetaPrefix + "version": "v1"}, true),
+			exp: false,
+		},
+		"other annotation changed": {
+			old: pod(map[string]string{"other": "a"}, true),
+			new: pod(map[string]string{"other": "b"}, true),
+			exp: false,
+		},
+		"meta annotation changed": {
+			old: pod(map[string]string{annotationServiceMetaPrefix + "version": "v1"}, true),
+			new: pod(map[string]string{annotationServiceMetaPrefix + "version": "v2"}, true),
+			exp: true,
+		},
+		"weights annotation added": {
+			old: pod(nil, true),
+			new: pod(map[string]string{annotationServiceWeightsPassing: "5"}, true),
+			exp: true,
+		},
+		"tagged address annotation removed": {
+			old: pod(map[string]string{annotationServiceTaggedAddressPrefix + "lan": "10.0.0.1"}, true),
+			new: pod(nil, true),
+			exp: true,
+		},
+		"container readiness changed": {
+			old: pod(nil, true),
+			new: pod(nil, false),
+			exp: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.exp, podDetailsChanged(c.old, c.new))
+		})
+	}
+}
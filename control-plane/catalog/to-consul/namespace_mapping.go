@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	namespaceMappingPrefix = "prefix:"
+	namespaceMappingRegex  = "regex:"
+)
+
+// NamespaceMappingRule maps the Kubernetes namespaces it matches to a Consul
+// admin partition and namespace. A rule matches a Kubernetes namespace either
+// by prefix or by regular expression.
+type NamespaceMappingRule struct {
+	// Prefix matches the Kubernetes namespaces that start with it. It is
+	// empty if the rule matches by regular expression.
+	Prefix string
+
+	// Regex matches the Kubernetes namespaces that match it. It is nil if the
+	// rule matches by prefix.
+	Regex *regexp.Regexp
+
+	// Partition is the Consul admin partition to register the services of
+	// matching Kubernetes namespaces into. If empty, the ConsulPartition of
+	// the ServiceResource is used.
+	Partition string
+
+	// Namespace is the Consul namespace to register the services of matching
+	// Kubernetes namespaces into. If empty, the namespace is determined by the
+	// namespace mirroring and destination namespace options. If the rule
+	// matches by regular expression, it may reference the expression's
+	// submatches, e.g. "team-${1}".
+	Namespace string
+}
+
+// ParseNamespaceMappingRule parses a rule in the form
+// "prefix:<prefix>=<partition>/<namespace>" or
+// "regex:<regex>=<partition>/<namespace>". Either the partition or the
+// namespace may be empty, but not both. The "/" may be omitted when only the
+// partition is set.
+func ParseNamespaceMappingRule(raw string) (NamespaceMappingRule, error) {
+	idx := strings.LastIndex(raw, "=")
+	if idx == -1 {
+		return NamespaceMappingRule{}, fmt.Errorf("rule %q must be in the form <match>=<partition>/<namespace>", raw)
+	}
+	match, target := raw[:idx], raw[idx+1:]
+
+	var rule NamespaceMappingRule
+	switch {
+	case strings.HasPrefix(match, namespaceMappingPrefix):
+		rule.Prefix = strings.TrimPrefix(match, namespaceMappingPrefix)
+		if rule.Prefix == "" {
+			return NamespaceMappingRule{}, fmt.Errorf("rule %q has an empty prefix", raw)
+		}
+	case strings.HasPrefix(match, namespaceMappingRegex):
+		expr := strings.TrimPrefix(match, namespaceMappingRegex)
+		if expr == "" {
+			return NamespaceMappingRule{}, fmt.Errorf("rule %q has an empty regex", raw)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return NamespaceMappingRule{}, fmt.Errorf("rule %q has an invalid regex: %s", raw, err)
+		}
+		rule.Regex = re
+	default:
+		return NamespaceMappingRule{}, fmt.Errorf("rule %q must match with %q or %q", raw, namespaceMappingPrefix, namespaceMappingRegex)
+	}
+
+	rule.Partition = target
+	if idx := strings.Index(target, "/"); idx != -1 {
+		rule.Partition, rule.Namespace = target[:idx], target[idx+1:]
+	}
+	if rule.Partition == "" && rule.Namespace == "" {
+		return NamespaceMappingRule{}, fmt.Errorf("rule %q must set a partition or a namespace", raw)
+	}
+	if strings.Contains(rule.Partition, "$") {
+		return NamespaceMappingRule{}, fmt.Errorf("rule %q has a partition that references a submatch", raw)
+	}
+	return rule, nil
+}
+
+// ParseNamespaceMappingRules parses each of the rules with
+// ParseNamespaceMappingRule.
+func ParseNamespaceMappingRules(raw []string) ([]NamespaceMappingRule, error) {
+	rules := make([]NamespaceMappingRule, 0, len(raw))
+	for _, r := range raw {
+		rule, err := ParseNamespaceMappingRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Map returns the Consul partition and namespace that the rule maps the
+// Kubernetes namespace to, and whether the rule matches it at all.
+func (r NamespaceMappingRule) Map(k8sNS string) (string, string, bool) {
+	if r.Regex == nil {
+		if !strings.HasPrefix(k8sNS, r.Prefix) {
+			return "", "", false
+		}
+		return r.Partition, r.Namespace, true
+	}
+
+	submatches := r.Regex.FindStringSubmatchIndex(k8sNS)
+	if submatches == nil {
+		return "", "", false
+	}
+	namespace := string(r.Regex.ExpandString(nil, r.Namespace, k8sNS, submatches))
+	return r.Partition, namespace, true
+}
+
+// mapNamespace returns the Consul partition and namespace that the first of
+// the rules that matches the Kubernetes namespace maps it to, and whether any
+// rule matches it.
+func mapNamespace(rules []NamespaceMappingRule, k8sNS string) (string, string, bool) {
+	for _, rule := range rules {
+		if partition, namespace, ok := rule.Map(k8sNS); ok {
+			return partition, namespace, true
+		}
+	}
+	return "", "", false
+}
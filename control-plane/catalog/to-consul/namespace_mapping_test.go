@@ -0,0 +1,199 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNamespaceMappingRule(t *testing.T) {
+	cases := map[string]struct {
+		raw          string
+		expPrefix    string
+		expRegex     string
+		expPartition string
+		expNamespace string
+		expErr       string
+	}{
+		"prefix with partition and namespace": {
+			raw:          "prefix:team-=team/ns",
+			expPrefix:    "team-",
+			expPartition: "team",
+			expNamespace: "ns",
+		},
+		"prefix with partition only": {
+			raw:          "prefix:team-=team",
+			expPrefix:    "team-",
+			expPartition: "team",
+		},
+		"prefix with namespace only": {
+			raw:          "prefix:team-=/ns",
+			expPrefix:    "team-",
+			expNamespace: "ns",
+		},
+		"regex with submatch": {
+			raw:          "regex:^team-(.*)$=team/${1}",
+			expRegex:     "^team-(.*)$",
+			expPartition: "team",
+			expNamespace: "${1}",
+		},
+		"regex containing an equals sign": {
+			raw:          "regex:^a=b$=team/ns",
+			expRegex:     "^a=b$",
+			expPartition: "team",
+			expNamespace: "ns",
+		},
+		"missing target": {
+			raw:    "prefix:team-",
+			expErr: `rule "prefix:team-" must be in the form <match>=<partition>/<namespace>`,
+		},
+		"unknown match type": {
+			raw:    "glob:team-*=team/ns",
+			expErr: `rule "glob:team-*=team/ns" must match with "prefix:" or "regex:"`,
+		},
+		"empty prefix": {
+			raw:    "prefix:=team/ns",
+			expErr: `rule "prefix:=team/ns" has an empty prefix`,
+		},
+		"empty regex": {
+			raw:    "regex:=team/ns",
+			expErr: `rule "regex:=team/ns" has an empty regex`,
+		},
+		"invalid regex": {
+			raw:    "regex:team-(=team/ns",
+			expErr: "rule \"regex:team-(=team/ns\" has an invalid regex: error parsing regexp: missing closing ): `team-(`",
+		},
+		"empty target": {
+			raw:    "prefix:team-=/",
+			expErr: `rule "prefix:team-=/" must set a partition or a namespace`,
+		},
+		"partition with submatch": {
+			raw:    "regex:^team-(.*)$=${1}/ns",
+			expErr: `rule "regex:^team-(.*)$=${1}/ns" has a partition that references a submatch`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule, err := ParseNamespaceMappingRule(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expPrefix, rule.Prefix)
+			if c.expRegex == "" {
+				require.Nil(t, rule.Regex)
+			} else {
+				require.Equal(t, c.expRegex, rule.Regex.String())
+			}
+			require.Equal(t, c.expPartition, rule.Partition)
+			require.Equal(t, c.expNamespace, rule.Namespace)
+		})
+	}
+}
+
+func TestParseNamespaceMappingRules(t *testing.T) {
+	rules, err := ParseNamespaceMappingRules([]string{"prefix:a-=a", "prefix:b-=b"})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, "a", rules[0].Partition)
+	require.Equal(t, "b", rules[1].Partition)
+
+	_, err = ParseNamespaceMappingRules([]string{"prefix:a-=a", "prefix:b-"})
+	require.EqualError(t, err, `rule "prefix:b-" must be in the form <match>=<partition>/<namespace>`)
+}
+
+func TestNamespaceMappingRule_Map(t *testing.T) {
+	cases := map[string]struct {
+		rule         string
+		k8sNS        string
+		expMatch     bool
+		expPartition string
+		expNamespace string
+	}{
+		"prefix match": {
+			rule:         "prefix:team-=team/shared",
+			k8sNS:        "team-a",
+			expMatch:     true,
+			expPartition: "team",
+			expNamespace: "shared",
+		},
+		"prefix no match": {
+			rule:  "prefix:team-=team/shared",
+			k8sNS: "other-team-a",
+		},
+		"regex match with submatch": {
+			rule:         "regex:^team-(.*)$=team/ns-${1}",
+			k8sNS:        "team-a",
+			expMatch:     true,
+			expPartition: "team",
+			expNamespace: "ns-a",
+		},
+		"regex match with named submatch": {
+			rule:         "regex:^(?P<env>dev|prod)-(?P<app>.*)$=/${env}-apps-${app}",
+			k8sNS:        "prod-web",
+			expMatch:     true,
+			expNamespace: "prod-apps-web",
+		},
+		"regex match without namespace": {
+			rule:         "regex:^team-=team",
+			k8sNS:        "team-a",
+			expMatch:     true,
+			expPartition: "team",
+		},
+		"regex no match": {
+			rule:  "regex:^team-(.*)$=team/ns-${1}",
+			k8sNS: "default",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule, err := ParseNamespaceMappingRule(c.rule)
+			require.NoError(t, err)
+			partition, namespace, ok := rule.Map(c.k8sNS)
+			require.Equal(t, c.expMatch, ok)
+			require.Equal(t, c.expPartition, partition)
+			require.Equal(t, c.expNamespace, namespace)
+		})
+	}
+}
+
+func TestMapNamespace_firstMatchWins(t *testing.T) {
+	rules, err := ParseNamespaceMappingRules([]string{
+		"prefix:team-infra=infra/platform",
+		"regex:^team-(.*)$=teams/${1}",
+		"prefix:team-=fallback/fallback",
+	})
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		k8sNS        string
+		expMatch     bool
+		expPartition string
+		expNamespace string
+	}{
+		"first rule": {
+			k8sNS:        "team-infra",
+			expMatch:     true,
+			expPartition: "infra",
+			expNamespace: "platform",
+		},
+		"second rule shadows third": {
+			k8sNS:        "team-web",
+			expMatch:     true,
+			expPartition: "teams",
+			expNamespace: "web",
+		},
+		"no rule": {
+			k8sNS: "default",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			partition, namespace, ok := mapNamespace(rules, c.k8sNS)
+			require.Equal(t, c.expMatch, ok)
+			require.Equal(t, c.expPartition, partition)
+			require.Equal(t, c.expNamespace, namespace)
+		})
+	}
+}
@@ -3,9 +3,12 @@ package catalog
 import (
 	"context"
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
@@ -13,7 +16,9 @@ import (
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
@@ -29,6 +34,22 @@ const (
 	// ConsulK8SNS is the key used in the meta to record the namespace
 	// of the service/node registration.
 	ConsulK8SNS = "external-k8s-ns"
+
+	// ConsulK8SCluster is the key used in the meta to record the name of the
+	// Kubernetes cluster that the service/node registration is synced from.
+	ConsulK8SCluster = "external-k8s-cluster"
+
+	// ConsulK8SConflictPolicy is the key used in the meta to record the
+	// conflict policy of the service registration.
+	ConsulK8SConflictPolicy = "external-k8s-conflict-policy"
+
+	// ConsulExternalNodeKey is the node meta key that marks a node as an
+	// external node so that its health checks are run by consul-esm.
+	ConsulExternalNodeKey = "external-node"
+
+	// externalNameHealthCheckInterval is how often the health check of
+	// a synced ExternalName service is run.
+	externalNameHealthCheckInterval = 10 * time.Second
 )
 
 type NodePortSyncType string
@@ -68,6 +89,20 @@ type ServiceResource struct {
 	// takes precedence over AllowK8sNamespacesSet.
 	DenyK8sNamespacesSet mapset.Set
 
+	// ServiceSelector is a Kubernetes label selector that services must
+	// match to be synced. A nil selector matches all services. Like the
+	// namespace lists, this filter is applied before checking annotations.
+	ServiceSelector labels.Selector
+
+	// IncludeAnnotations is a list of annotation filters. If it isn't empty,
+	// only services that match at least one of the filters are synced.
+	IncludeAnnotations []AnnotationFilter
+
+	// ExcludeAnnotations is a list of annotation filters. Services that match
+	// any of the filters are not synced. This filter takes precedence over
+	// IncludeAnnotations.
+	ExcludeAnnotations []AnnotationFilter
+
 	// ConsulK8STag is the tag value for services registered.
 	ConsulK8STag string
 
@@ -119,9 +154,66 @@ type ServiceResource struct {
 	// `k8s-default` namespace.
 	K8SNSMirroringPrefix string
 
+	// ConsulPartition is the Consul admin partition to register services
+	// into if they don't match a namespace mapping rule that sets a
+	// partition. If empty, the partition of the Consul client is used.
+	ConsulPartition string
+
+	// NamespaceMappingRules map Kubernetes namespaces to Consul admin
+	// partitions and namespaces. The first rule that matches the namespace
+	// of a service takes precedence over ConsulPartition and the other
+	// namespace options.
+	NamespaceMappingRules []NamespaceMappingRule
+
 	// The Consul node name to register service with.
 	ConsulNodeName string
 
+	// ClusterName is the name of the Kubernetes cluster that services are
+	// synced from. It must be unique among the clusters that sync services
+	// into the same Consul datacenter. If set, it is recorded in the node
+	// and service meta and is part of the service instance IDs so that the
+	// instances of different clusters don't conflict.
+	ClusterName string
+
+	// ConflictPolicy is the default policy for resolving conflicts between
+	// synced services and Consul services of the same name. It can be
+	// overridden per service with an annotation. Defaults to k8s-wins.
+	ConflictPolicy ConflictPolicy
+
+	// SyncExternalNameServices set to true (default false) syncs ExternalName
+	// services to Consul as external services with the external name as their
+	// address.
+	SyncExternalNameServices bool
+
+	// The Consul node name to register ExternalName services with. This node
+	// is marked as an external node and should differ from ConsulNodeName.
+	ConsulExternalNodeName string
+
+	// SyncHealthChecks set to true (default false) registers a health check
+	// with each synced service instance that is backed by an endpoint. The
+	// check reflects the readiness of the endpoint, and instances of
+	// endpoints that are not ready are registered with a failing check
+	// instead of not being registered.
+	SyncHealthChecks bool
+
+	// HealthCheckName is the name of the health checks registered when
+	// SyncHealthChecks is true. It can be overridden per service with an
+	// annotation. Defaults to DefaultHealthCheckName.
+	HealthCheckName string
+
+	// HealthCheckCriticalAfter is how long an instance must be not ready
+	// for before its health check is critical. Until then, the check is
+	// warning. If zero, the check is critical as soon as the instance is
+	// not ready.
+	HealthCheckCriticalAfter time.Duration
+
+	// SyncPodAnnotations set to true (default false) reads the pods of the
+	// endpoints of synced services so that the meta, weights and tagged
+	// addresses annotations of a pod are set on the instance of its endpoint,
+	// overriding the ones set on the service. Pods are watched so that
+	// changes to their annotations are synced as they happen.
+	SyncPodAnnotations bool
+
 	// serviceLock must be held for any read/write to these maps.
 	serviceLock sync.RWMutex
 
@@ -129,14 +221,25 @@ type ServiceResource struct {
 	// in the form <kube namespace>/<kube svc name>.
 	serviceMap map[string]*apiv1.Service
 
-	// endpointsMap uses the same keys as serviceMap but maps to the endpoints
-	// of each service.
-	endpointsMap map[string]*apiv1.Endpoints
+	// endpointsMap uses the same keys as serviceMap but maps to the endpoint
+	// slices of each service, keyed by the endpoint slice name. Endpoint
+	// slices are tracked individually so that a change to one of them doesn't
+	// require reloading the others.
+	endpointsMap map[string]map[string]*discoveryv1.EndpointSlice
 
 	// consulMap holds the services in Consul that we've registered from kube.
 	// It's populated via Consul's API and lets us diff what is actually in
 	// Consul vs. what we expect to be there.
 	consulMap map[string][]*consulapi.CatalogRegistration
+
+	// podMap holds the pods watched when SyncPodAnnotations or
+	// SyncHealthChecks is true. Keys are in the form
+	// <kube namespace>/<kube pod name>.
+	podMap map[string]*apiv1.Pod
+
+	// healthCheckFailures uses the same keys as serviceMap but maps to the
+	// failures of the service's instances, keyed by service ID.
+	healthCheckFailures map[string]map[string]*healthCheckFailure
 }
 
 // Informer implements the controller.Resource interface.
@@ -181,37 +284,41 @@ func (t *ServiceResource) Upsert(key string, raw interface{}) error {
 			t.Log.Info("service should no longer be synced", "service", key)
 			t.doDelete(key)
 		} else {
-			t.Log.Debug("[ServiceResource.Upsert] syncing disabled for service, ignoring", "key", key)
+			t.Log.Debug("[ServiceResource.Upsert] syncing disabled for service, ignoring", controller.ResourceLogArgs(key)...)
 		}
 		return nil
 	}
 
 	// Syncing is enabled, let's keep track of this service.
 	t.serviceMap[key] = service
-	t.Log.Debug("[ServiceResource.Upsert] adding service to serviceMap", "key", key, "service", service)
+	t.Log.With(controller.ResourceLogArgs(key)...).Debug("[ServiceResource.Upsert] adding service to serviceMap", "service", service)
 
 	// If we care about endpoints, we should do the initial endpoints load.
 	if t.shouldTrackEndpoints(key) {
-		endpoints, err := t.Client.CoreV1().
-			Endpoints(service.Namespace).
-			Get(t.Ctx, service.Name, metav1.GetOptions{})
+		endpointSlices, err := t.Client.DiscoveryV1().
+			EndpointSlices(service.Namespace).
+			List(t.Ctx, metav1.ListOptions{
+				LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, service.Name),
+			})
 		if err != nil {
-			t.Log.Warn("error loading initial endpoints",
-				"key", key,
-				"err", err)
+			t.Log.With(controller.ResourceLogArgs(key)...).Warn("error loading initial endpoint slices", "err", err)
 		} else {
 			if t.endpointsMap == nil {
-				t.endpointsMap = make(map[string]*apiv1.Endpoints)
+				t.endpointsMap = make(map[string]map[string]*discoveryv1.EndpointSlice)
+			}
+			slices := make(map[string]*discoveryv1.EndpointSlice, len(endpointSlices.Items))
+			for i := range endpointSlices.Items {
+				slices[endpointSlices.Items[i].Name] = &endpointSlices.Items[i]
 			}
-			t.endpointsMap[key] = endpoints
-			t.Log.Debug("[ServiceResource.Upsert] adding service's endpoints to endpointsMap", "key", key, "service", service, "endpoints", endpoints)
+			t.endpointsMap[key] = slices
+			t.Log.With(controller.ResourceLogArgs(key)...).Debug("[ServiceResource.Upsert] adding service's endpoint slices to endpointsMap", "service", service, "endpoint slices", len(slices))
 		}
 	}
 
 	// Update the registration and trigger a sync
 	t.generateRegistrations(key)
 	t.sync()
-	t.Log.Info("upsert", "key", key)
+	t.Log.Info("upsert", controller.ResourceLogArgs(key)...)
 	return nil
 }
 
@@ -220,7 +327,7 @@ func (t *ServiceResource) Delete(key string, _ interface{}) error {
 	t.serviceLock.Lock()
 	defer t.serviceLock.Unlock()
 	t.doDelete(key)
-	t.Log.Info("delete", "key", key)
+	t.Log.Info("delete", controller.ResourceLogArgs(key)...)
 	return nil
 }
 
@@ -229,9 +336,12 @@ func (t *ServiceResource) Delete(key string, _ interface{}) error {
 // Precondition: assumes t.serviceLock is held.
 func (t *ServiceResource) doDelete(key string) {
 	delete(t.serviceMap, key)
-	t.Log.Debug("[doDelete] deleting service from serviceMap", "key", key)
+	t.Log.Debug("[doDelete] deleting service from serviceMap", controller.ResourceLogArgs(key)...)
 	delete(t.endpointsMap, key)
-	t.Log.Debug("[doDelete] deleting endpoints from endpointsMap", "key", key)
+	t.Log.Debug("[doDelete] deleting endpoints from endpointsMap", controller.ResourceLogArgs(key)...)
+	for id := range t.healthCheckFailures[key] {
+		t.forgetHealthCheckFailure(key, id)
+	}
 	// If there were registrations related to this service, then
 	// delete them and sync.
 	if _, ok := t.consulMap[key]; ok {
@@ -242,11 +352,45 @@ func (t *ServiceResource) doDelete(key string) {
 
 // Run implements the controller.Backgrounder interface.
 func (t *ServiceResource) Run(ch <-chan struct{}) {
-	t.Log.Info("starting runner for endpoints")
+	var wg sync.WaitGroup
+	if t.SyncPodAnnotations || t.SyncHealthChecks {
+		for _, namespace := range t.podNamespaces() {
+			t.Log.Info("starting runner for pods", "namespace", namespace)
+			wg.Add(1)
+			go func(namespace string) {
+				defer wg.Done()
+				(&controller.Controller{
+					Log:      t.Log.Named("controller/pods"),
+					Resource: &servicePodsResource{Service: t, Ctx: t.Ctx, Namespace: namespace},
+				}).Run(ch)
+			}(namespace)
+		}
+	}
+
+	t.Log.Info("starting runner for endpoint slices")
 	(&controller.Controller{
-		Log:      t.Log.Named("controller/endpoints"),
-		Resource: &serviceEndpointsResource{Service: t, Ctx: t.Ctx},
+		Log:      t.Log.Named("controller/endpointslices"),
+		Resource: &serviceEndpointSlicesResource{Service: t, Ctx: t.Ctx},
 	}).Run(ch)
+	wg.Wait()
+}
+
+// podNamespaces returns the namespaces to watch the pods of endpoints in,
+// which are the namespaces that services are synced from. If services are
+// synced from all namespaces, it returns metav1.NamespaceAll and the pods of
+// the denied namespaces are ignored when they're received.
+func (t *ServiceResource) podNamespaces() []string {
+	if t.AllowK8sNamespacesSet.Contains("*") {
+		return []string{metav1.NamespaceAll}
+	}
+	var namespaces []string
+	for namespace := range t.AllowK8sNamespacesSet.Iter() {
+		if !t.DenyK8sNamespacesSet.Contains(namespace) {
+			namespaces = append(namespaces, namespace.(string))
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
 }
 
 // shouldSync returns true if resyncing should be enabled for the given service.
@@ -264,6 +408,24 @@ func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 		return false
 	}
 
+	// If the service doesn't match the label selector, don't sync
+	if t.ServiceSelector != nil && !t.ServiceSelector.Matches(labels.Set(svc.Labels)) {
+		t.Log.Debug("[shouldSync] service does not match the label selector", "svc.Namespace", svc.Namespace, "service", svc)
+		return false
+	}
+
+	// If the service matches an exclude filter, don't sync
+	if matchesAnyAnnotationFilter(t.ExcludeAnnotations, svc.Annotations) {
+		t.Log.Debug("[shouldSync] service matches an exclude annotation filter", "svc.Namespace", svc.Namespace, "service", svc)
+		return false
+	}
+
+	// If there are include filters and the service matches none of them, don't sync
+	if len(t.IncludeAnnotations) > 0 && !matchesAnyAnnotationFilter(t.IncludeAnnotations, svc.Annotations) {
+		t.Log.Debug("[shouldSync] service does not match any include annotation filter", "svc.Namespace", svc.Namespace, "service", svc)
+		return false
+	}
+
 	// Ignore ClusterIP services if ClusterIP sync is disabled
 	if svc.Spec.Type == apiv1.ServiceTypeClusterIP && !t.ClusterIPSync {
 		t.Log.Debug("[shouldSync] ignoring clusterip service", "svc.Namespace", svc.Namespace, "service", svc)
@@ -323,7 +485,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		return
 	}
 
-	t.Log.Debug("[generateRegistrations] generating registration", "key", key)
+	t.Log.Debug("[generateRegistrations] generating registration", controller.ResourceLogArgs(key)...)
 
 	// Initialize our consul service map here if it isn't already.
 	if t.consulMap == nil {
@@ -347,29 +509,30 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	}
 
 	baseService := consulapi.AgentService{
-		Service: t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
+		Service: t.consulServiceName(svc),
 		Tags:    []string{t.ConsulK8STag},
 		Meta: map[string]string{
-			ConsulSourceKey: ConsulSourceValue,
-			ConsulK8SNS:     svc.Namespace,
+			ConsulSourceKey:         ConsulSourceValue,
+			ConsulK8SNS:             svc.Namespace,
+			ConsulK8SConflictPolicy: string(t.conflictPolicy(svc)),
 		},
 	}
-
-	// If the name is explicitly annotated, adopt that name
-	if v, ok := svc.Annotations[annotationServiceName]; ok {
-		baseService.Service = strings.TrimSpace(v)
+	if t.ClusterName != "" {
+		baseNode.NodeMeta[ConsulK8SCluster] = t.ClusterName
+		baseService.Meta[ConsulK8SCluster] = t.ClusterName
 	}
 
-	// Update the Consul namespace based on namespace settings
-	consulNS := namespaces.ConsulNamespace(svc.Namespace,
-		t.EnableNamespaces,
-		t.ConsulDestinationNamespace,
-		t.EnableK8SNSMirroring,
-		t.K8SNSMirroringPrefix)
+	// Update the Consul partition and namespace based on namespace settings
+	partition, consulNS := t.consulPartitionAndNamespace(svc.Namespace)
 	if consulNS != "" {
-		t.Log.Debug("[generateRegistrations] namespace being used", "key", key, "namespace", consulNS)
+		t.Log.With(controller.ResourceLogArgs(key)...).Debug("[generateRegistrations] namespace being used", "consul-namespace", consulNS)
 		baseService.Namespace = consulNS
 	}
+	if partition != "" {
+		t.Log.With(controller.ResourceLogArgs(key)...).Debug("[generateRegistrations] partition being used", "partition", partition)
+		baseNode.Partition = partition
+		baseService.Partition = partition
+	}
 
 	// Determine the default port and set port annotations
 	var overridePortName string
@@ -438,20 +601,16 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		baseService.Tags = append(baseService.Tags, parseTags(rawTags)...)
 	}
 
-	// Parse any additional meta
-	for k, v := range svc.Annotations {
-		if strings.HasPrefix(k, annotationServiceMetaPrefix) {
-			k = strings.TrimPrefix(k, annotationServiceMetaPrefix)
-			baseService.Meta[k] = v
-		}
-	}
+	// Parse any additional meta, weights and tagged addresses
+	t.applyInstanceAnnotations(key, &baseService, svc.Annotations)
 
 	// Always log what we generated
 	defer func() {
-		t.Log.Debug("generated registration",
-			"key", key,
+		setTaggedAddressPorts(t.consulMap[key])
+		t.pruneHealthCheckFailures(key)
+		t.Log.With(controller.ResourceLogArgs(key)...).Debug("generated registration",
 			"service", baseService.Service,
-			"namespace", baseService.Namespace,
+			"consul-namespace", baseService.Namespace,
 			"instances", len(t.consulMap[key]))
 	}()
 
@@ -462,7 +621,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 			r := baseNode
 			rs := baseService
 			r.Service = &rs
-			r.Service.ID = serviceID(r.Service.Service, ip)
+			r.Service.ID = t.instanceID(r.Service.Service, ip)
 			r.Service.Address = ip
 			t.consulMap[key] = append(t.consulMap[key], &r)
 		}
@@ -497,7 +656,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 				r := baseNode
 				rs := baseService
 				r.Service = &rs
-				r.Service.ID = serviceID(r.Service.Service, addr)
+				r.Service.ID = t.instanceID(r.Service.Service, addr)
 				r.Service.Address = addr
 
 				t.consulMap[key] = append(t.consulMap[key], &r)
@@ -513,21 +672,17 @@ func (t *ServiceResource) generateRegistrations(key string) {
 			return
 		}
 
-		endpoints := t.endpointsMap[key]
-		if endpoints == nil {
-			return
-		}
-
-		for _, subset := range endpoints.Subsets {
-			for _, subsetAddr := range subset.Addresses {
-				// Check that the node name exists
-				// subsetAddr.NodeName is of type *string
-				if subsetAddr.NodeName == nil {
+		for _, slice := range sortedEndpointSlices(t.endpointsMap[key], serviceAddressType(svc)) {
+			for _, endpoint := range slice.Endpoints {
+				// Check that the endpoint is ready and the node name exists
+				// endpoint.NodeName is of type *string
+				if !t.shouldRegisterEndpoint(endpoint) || endpoint.NodeName == nil || len(endpoint.Addresses) == 0 {
 					continue
 				}
+				endpointAddr := endpoint.Addresses[0]
 
 				// Look up the node's ip address by getting node info
-				node, err := t.Client.CoreV1().Nodes().Get(t.Ctx, *subsetAddr.NodeName, metav1.GetOptions{})
+				node, err := t.Client.CoreV1().Nodes().Get(t.Ctx, *endpoint.NodeName, metav1.GetOptions{})
 				if err != nil {
 					t.Log.Warn("error getting node info", "error", err)
 					continue
@@ -550,8 +705,9 @@ func (t *ServiceResource) generateRegistrations(key string) {
 						r := baseNode
 						rs := baseService
 						r.Service = &rs
-						r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
+						r.Service.ID = t.instanceID(r.Service.Service, endpointAddr)
 						r.Service.Address = address.Address
+						t.applyEndpointDetails(key, svc, slice, endpoint, &r)
 
 						t.consulMap[key] = append(t.consulMap[key], &r)
 						// Only consider the first address that matches. In some cases
@@ -571,8 +727,9 @@ func (t *ServiceResource) generateRegistrations(key string) {
 							r := baseNode
 							rs := baseService
 							r.Service = &rs
-							r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
+							r.Service.ID = t.instanceID(r.Service.Service, endpointAddr)
 							r.Service.Address = address.Address
+							t.applyEndpointDetails(key, svc, slice, endpoint, &r)
 
 							t.consulMap[key] = append(t.consulMap[key], &r)
 							// Only consider the first address that matches. In some cases
@@ -591,6 +748,67 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	// for each endpoint.
 	case apiv1.ServiceTypeClusterIP:
 		t.registerServiceInstance(baseNode, baseService, key, overridePortName, overridePortNumber, true)
+
+	// For ExternalName services, we register a single service instance
+	// on the external node with the external name as its address.
+	case apiv1.ServiceTypeExternalName:
+		if !t.SyncExternalNameServices || svc.Spec.ExternalName == "" {
+			return
+		}
+
+		r := baseNode
+		r.Node = t.ConsulExternalNodeName
+		r.NodeMeta = map[string]string{
+			ConsulSourceKey:       ConsulSourceValue,
+			ConsulExternalNodeKey: "true",
+		}
+		if t.ClusterName != "" {
+			r.NodeMeta[ConsulK8SCluster] = t.ClusterName
+		}
+		rs := baseService
+		r.Service = &rs
+		r.Service.ID = t.instanceID(r.Service.Service, svc.Spec.ExternalName)
+		r.Service.Address = svc.Spec.ExternalName
+		r.Check = t.externalNameHealthCheck(key, svc, r.Node, r.Service)
+
+		t.consulMap[key] = append(t.consulMap[key], &r)
+	}
+}
+
+// externalNameHealthCheck returns the TCP health check to register for the
+// given ExternalName service instance if it is annotated to have one, or nil
+// otherwise. The check is run by consul-esm since the instance is registered
+// on an external node.
+func (t *ServiceResource) externalNameHealthCheck(key string, svc *apiv1.Service, node string, service *consulapi.AgentService) *consulapi.AgentCheck {
+	raw, ok := svc.Annotations[annotationExternalNameHealthCheck]
+	if !ok {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		t.Log.With(controller.ResourceLogArgs(key)...).Warn("error parsing external-name-health-check annotation", "err", err)
+		return nil
+	}
+	if !enabled {
+		return nil
+	}
+	if service.Port == 0 {
+		t.Log.Warn("not registering health check for ExternalName service without a port", controller.ResourceLogArgs(key)...)
+		return nil
+	}
+
+	return &consulapi.AgentCheck{
+		Node:        node,
+		CheckID:     fmt.Sprintf("%s/external-name-check", service.ID),
+		Name:        "Kubernetes ExternalName Health Check",
+		ServiceID:   service.ID,
+		ServiceName: service.Service,
+		Namespace:   service.Namespace,
+		Partition:   service.Partition,
+		Definition: consulapi.HealthCheckDefinition{
+			TCP:              net.JoinHostPort(service.Address, strconv.Itoa(service.Port)),
+			IntervalDuration: externalNameHealthCheckInterval,
+		},
 	}
 }
 
@@ -606,37 +824,41 @@ func (t *ServiceResource) registerServiceInstance(
 		return
 	}
 
-	endpoints := t.endpointsMap[key]
-	if endpoints == nil {
-		return
-	}
-
 	seen := map[string]struct{}{}
-	for _, subset := range endpoints.Subsets {
+	for _, slice := range sortedEndpointSlices(t.endpointsMap[key], serviceAddressType(t.serviceMap[key])) {
 		// For ClusterIP services and if LoadBalancerEndpointsSync is true, we use the endpoint port instead
 		// of the service port because we're registering each endpoint
 		// as a separate service instance.
 		epPort := baseService.Port
 		if overridePortName != "" {
 			// If we're supposed to use a specific named port, find it.
-			for _, p := range subset.Ports {
-				if overridePortName == p.Name {
-					epPort = int(p.Port)
+			for _, p := range slice.Ports {
+				if p.Name != nil && overridePortName == *p.Name && p.Port != nil {
+					epPort = int(*p.Port)
 					break
 				}
 			}
 		} else if overridePortNumber == 0 {
 			// Otherwise we'll just use the first port in the list
 			// (unless the port number was overridden by an annotation).
-			for _, p := range subset.Ports {
-				epPort = int(p.Port)
+			for _, p := range slice.Ports {
+				if p.Port != nil {
+					epPort = int(*p.Port)
+				}
 				break
 			}
 		}
-		for _, subsetAddr := range subset.Addresses {
-			addr := subsetAddr.IP
-			if addr == "" && useHostname {
-				addr = subsetAddr.Hostname
+		for _, endpoint := range slice.Endpoints {
+			if !t.shouldRegisterEndpoint(endpoint) {
+				continue
+			}
+
+			var addr string
+			if len(endpoint.Addresses) > 0 {
+				addr = endpoint.Addresses[0]
+			}
+			if addr == "" && useHostname && endpoint.Hostname != nil {
+				addr = *endpoint.Hostname
 			}
 			if addr == "" {
 				continue
@@ -653,9 +875,10 @@ func (t *ServiceResource) registerServiceInstance(
 			r := baseNode
 			rs := baseService
 			r.Service = &rs
-			r.Service.ID = serviceID(r.Service.Service, addr)
+			r.Service.ID = t.instanceID(r.Service.Service, addr)
 			r.Service.Address = addr
 			r.Service.Port = epPort
+			t.applyEndpointDetails(key, t.serviceMap[key], slice, endpoint, &r)
 
 			t.consulMap[key] = append(t.consulMap[key], &r)
 		}
@@ -679,15 +902,15 @@ func (t *ServiceResource) sync() {
 	t.Syncer.Sync(rs)
 }
 
-// serviceEndpointsResource implements controller.Resource and starts
-// a background watcher on endpoints that is used by the ServiceResource
+// serviceEndpointSlicesResource implements controller.Resource and starts
+// a background watcher on endpoint slices that is used by the ServiceResource
 // to keep track of changing endpoints for registered services.
-type serviceEndpointsResource struct {
+type serviceEndpointSlicesResource struct {
 	Service *ServiceResource
 	Ctx     context.Context
 }
 
-func (t *serviceEndpointsResource) Informer() cache.SharedIndexInformer {
+func (t *serviceEndpointSlicesResource) Informer() cache.SharedIndexInformer {
 	// Watch all k8s namespaces. Events will be filtered out as appropriate in the
 	// `shouldTrackEndpoints` function which checks whether the service is marked
 	// to be tracked by the `shouldSync` function which uses the allow and deny
@@ -695,71 +918,332 @@ func (t *serviceEndpointsResource) Informer() cache.SharedIndexInformer {
 	return cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return t.Service.Client.CoreV1().
-					Endpoints(metav1.NamespaceAll).
+				return t.Service.Client.DiscoveryV1().
+					EndpointSlices(metav1.NamespaceAll).
 					List(t.Ctx, options)
 			},
 
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return t.Service.Client.CoreV1().
-					Endpoints(metav1.NamespaceAll).
+				return t.Service.Client.DiscoveryV1().
+					EndpointSlices(metav1.NamespaceAll).
 					Watch(t.Ctx, options)
 			},
 		},
-		&apiv1.Endpoints{},
+		&discoveryv1.EndpointSlice{},
 		0,
 		cache.Indexers{},
 	)
 }
 
-func (t *serviceEndpointsResource) Upsert(key string, raw interface{}) error {
+func (t *serviceEndpointSlicesResource) Upsert(key string, raw interface{}) error {
 	svc := t.Service
-	endpoints, ok := raw.(*apiv1.Endpoints)
+	endpointSlice, ok := raw.(*discoveryv1.EndpointSlice)
 	if !ok {
 		svc.Log.Warn("upsert got invalid type", "raw", raw)
 		return nil
 	}
 
+	// Endpoint slices that aren't managed for a service don't have the
+	// service name label, so there is no service to update.
+	serviceKey, ok := endpointSliceServiceKey(endpointSlice)
+	if !ok {
+		return nil
+	}
+
 	svc.serviceLock.Lock()
 	defer svc.serviceLock.Unlock()
 
 	// Check if we care about endpoints for this service
-	if !svc.shouldTrackEndpoints(key) {
+	if !svc.shouldTrackEndpoints(serviceKey) {
 		return nil
 	}
 
-	// We are tracking this service so let's keep track of the endpoints
+	// We are tracking this service so let's keep track of the endpoint slice
 	if svc.endpointsMap == nil {
-		svc.endpointsMap = make(map[string]*apiv1.Endpoints)
+		svc.endpointsMap = make(map[string]map[string]*discoveryv1.EndpointSlice)
+	}
+	if svc.endpointsMap[serviceKey] == nil {
+		svc.endpointsMap[serviceKey] = make(map[string]*discoveryv1.EndpointSlice)
 	}
-	svc.endpointsMap[key] = endpoints
+	svc.endpointsMap[serviceKey][endpointSlice.Name] = endpointSlice
 
 	// Update the registration and trigger a sync
-	svc.generateRegistrations(key)
+	svc.generateRegistrations(serviceKey)
 	svc.sync()
-	svc.Log.Info("upsert endpoint", "key", key)
+	svc.Log.With(controller.ResourceLogArgs(key)...).Info("upsert endpoint slice", "service", serviceKey)
 	return nil
 }
 
-func (t *serviceEndpointsResource) Delete(key string, _ interface{}) error {
+func (t *serviceEndpointSlicesResource) Delete(key string, raw interface{}) error {
 	t.Service.serviceLock.Lock()
 	defer t.Service.serviceLock.Unlock()
 
+	// Find the service the endpoint slice belonged to. If the deleted object
+	// isn't available, fall back to looking it up by the slice's name.
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	serviceKey, ok := "", false
+	if endpointSlice, isSlice := raw.(*discoveryv1.EndpointSlice); isSlice {
+		serviceKey, ok = endpointSliceServiceKey(endpointSlice)
+	}
+	if !ok {
+		for k, slices := range t.Service.endpointsMap {
+			if _, found := slices[name]; found && strings.HasPrefix(k, namespace+"/") {
+				serviceKey, ok = k, true
+				break
+			}
+		}
+	}
+
 	// This is a bit of an optimization. We only want to force a resync
-	// if we were tracking this endpoint to begin with and that endpoint
+	// if we were tracking this endpoint slice to begin with and that service
 	// had associated registrations.
-	if _, ok := t.Service.endpointsMap[key]; ok {
-		delete(t.Service.endpointsMap, key)
-		if _, ok := t.Service.consulMap[key]; ok {
-			delete(t.Service.consulMap, key)
-			t.Service.sync()
+	if ok {
+		if _, tracked := t.Service.endpointsMap[serviceKey][name]; tracked {
+			delete(t.Service.endpointsMap[serviceKey], name)
+			if _, ok := t.Service.consulMap[serviceKey]; ok {
+				t.Service.generateRegistrations(serviceKey)
+				t.Service.sync()
+			}
 		}
 	}
 
-	t.Service.Log.Info("delete endpoint", "key", key)
+	t.Service.Log.Info("delete endpoint slice", controller.ResourceLogArgs(key)...)
+	return nil
+}
+
+// servicePodsResource implements controller.Resource and starts a
+// background watcher on the pods of a namespace that is used by the
+// ServiceResource to read the pods of endpoints without calling the
+// Kubernetes API for each endpoint, and to update the registrations of a
+// pod's endpoints when its annotations or readiness change.
+type servicePodsResource struct {
+	Service *ServiceResource
+	Ctx     context.Context
+
+	// Namespace is the namespace to watch pods in, or metav1.NamespaceAll.
+	Namespace string
+}
+
+func (t *servicePodsResource) Informer() cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return t.Service.Client.CoreV1().
+					Pods(t.Namespace).
+					List(t.Ctx, options)
+			},
+
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return t.Service.Client.CoreV1().
+					Pods(t.Namespace).
+					Watch(t.Ctx, options)
+			},
+		},
+		&apiv1.Pod{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+func (t *servicePodsResource) Upsert(key string, raw interface{}) error {
+	svc := t.Service
+	pod, ok := raw.(*apiv1.Pod)
+	if !ok {
+		svc.Log.Warn("upsert got invalid type", "raw", raw)
+		return nil
+	}
+
+	// Services aren't synced from denied namespaces, so their pods aren't needed.
+	if svc.DenyK8sNamespacesSet.Contains(pod.Namespace) {
+		return nil
+	}
+
+	svc.serviceLock.Lock()
+	defer svc.serviceLock.Unlock()
+
+	if svc.podMap == nil {
+		svc.podMap = make(map[string]*apiv1.Pod)
+	}
+	old, known := svc.podMap[key]
+	svc.podMap[key] = pod
+	if known && !podDetailsChanged(old, pod) {
+		return nil
+	}
+
+	// Update the registrations of the services with endpoints for the pod.
+	serviceKeys := svc.podServiceKeys(key)
+	for _, serviceKey := range serviceKeys {
+		svc.generateRegistrations(serviceKey)
+	}
+	if len(serviceKeys) > 0 {
+		svc.sync()
+		svc.Log.With(controller.ResourceLogArgs(key)...).Info("upsert pod", "services", serviceKeys)
+	}
 	return nil
 }
 
+func (t *servicePodsResource) Delete(key string, _ interface{}) error {
+	t.Service.serviceLock.Lock()
+	defer t.Service.serviceLock.Unlock()
+
+	// The endpoints of the pod are removed from their endpoint slices so
+	// there are no registrations to update.
+	delete(t.Service.podMap, key)
+	t.Service.Log.Debug("delete pod", controller.ResourceLogArgs(key)...)
+	return nil
+}
+
+// podServiceKeys returns the keys of the tracked services that have an
+// endpoint for the pod, sorted so that they are updated in a stable order.
+//
+// Precondition: assumes t.serviceLock is held.
+func (t *ServiceResource) podServiceKeys(podKey string) []string {
+	var serviceKeys []string
+	for serviceKey, slices := range t.endpointsMap {
+		if !t.shouldTrackEndpoints(serviceKey) {
+			continue
+		}
+	slicesLoop:
+		for _, slice := range slices {
+			for _, endpoint := range slice.Endpoints {
+				if k, ok := endpointPodKey(slice.Namespace, endpoint); ok && k == podKey {
+					serviceKeys = append(serviceKeys, serviceKey)
+					break slicesLoop
+				}
+			}
+		}
+	}
+	sort.Strings(serviceKeys)
+	return serviceKeys
+}
+
+// endpointSliceServiceKey returns the key of the service that owns the
+// endpoint slice, in the same format as the ServiceResource keys, and
+// whether the endpoint slice belongs to a service.
+func endpointSliceServiceKey(endpointSlice *discoveryv1.EndpointSlice) (string, bool) {
+	serviceName, ok := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	if !ok || serviceName == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s", endpointSlice.Namespace, serviceName), true
+}
+
+// sortedEndpointSlices returns the endpoint slices of the given address type
+// sorted by name so that registrations are generated in a stable order.
+func sortedEndpointSlices(slices map[string]*discoveryv1.EndpointSlice, addressType discoveryv1.AddressType) []*discoveryv1.EndpointSlice {
+	sorted := make([]*discoveryv1.EndpointSlice, 0, len(slices))
+	for _, slice := range slices {
+		if slice.AddressType != addressType {
+			continue
+		}
+		sorted = append(sorted, slice)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// serviceAddressType returns the address type of the endpoint slices that are
+// synced for the service. Like the Endpoints API, only the endpoints of the
+// service's primary IP family are synced so that dual-stack services don't
+// get a service instance for each IP family.
+func serviceAddressType(svc *apiv1.Service) discoveryv1.AddressType {
+	if svc != nil && len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == apiv1.IPv6Protocol {
+		return discoveryv1.AddressTypeIPv6
+	}
+	return discoveryv1.AddressTypeIPv4
+}
+
+// shouldRegisterEndpoint returns true if a service instance should be
+// registered for the endpoint. Endpoints that are not ready are only
+// registered if their readiness is synced to health checks.
+func (t *ServiceResource) shouldRegisterEndpoint(endpoint discoveryv1.Endpoint) bool {
+	return t.SyncHealthChecks || endpointReady(endpoint)
+}
+
+// endpointReady returns true if the endpoint is ready. As recommended by
+// the EndpointSlice API, an unknown ready condition is treated as ready.
+func endpointReady(endpoint discoveryv1.Endpoint) bool {
+	return endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+}
+
+// ConsulServicesExcludedFromK8S returns the names of the Consul services that
+// are synced from Kubernetes with a conflict policy that prevents the Consul
+// service of the same name from being synced to Kubernetes.
+func (t *ServiceResource) ConsulServicesExcludedFromK8S() map[string]struct{} {
+	t.serviceLock.RLock()
+	defer t.serviceLock.RUnlock()
+
+	names := make(map[string]struct{})
+	for _, svc := range t.serviceMap {
+		if t.conflictPolicy(svc).excludesFromK8S() {
+			names[t.consulServiceName(svc)] = struct{}{}
+		}
+	}
+	return names
+}
+
+// consulServiceName returns the name of the Consul service to register
+// the given service as.
+func (t *ServiceResource) consulServiceName(svc *apiv1.Service) string {
+	// If the name is explicitly annotated, adopt that name
+	if v, ok := svc.Annotations[annotationServiceName]; ok {
+		return strings.TrimSpace(v)
+	}
+	return t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace)
+}
+
+// consulPartitionAndNamespace returns the Consul partition and namespace to
+// register the services of the given Kubernetes namespace into. The first
+// namespace mapping rule that matches the namespace takes precedence, and
+// what it doesn't set falls back to ConsulPartition and the namespace
+// options. Either is empty if no option sets it.
+func (t *ServiceResource) consulPartitionAndNamespace(k8sNS string) (string, string) {
+	partition := t.ConsulPartition
+	consulNS := namespaces.ConsulNamespace(k8sNS,
+		t.EnableNamespaces,
+		t.ConsulDestinationNamespace,
+		t.EnableK8SNSMirroring,
+		t.K8SNSMirroringPrefix)
+	if rulePartition, ruleNS, ok := mapNamespace(t.NamespaceMappingRules, k8sNS); ok {
+		if rulePartition != "" {
+			partition = rulePartition
+		}
+		if ruleNS != "" {
+			consulNS = ruleNS
+		}
+	}
+	return partition, consulNS
+}
+
+// conflictPolicy returns the conflict policy for the given service, which is
+// either set by annotation or the default policy.
+func (t *ServiceResource) conflictPolicy(svc *apiv1.Service) ConflictPolicy {
+	defaultPolicy := t.ConflictPolicy
+	if defaultPolicy == "" {
+		defaultPolicy = ConflictPolicyK8SWins
+	}
+
+	raw, ok := svc.Annotations[annotationServiceSyncConflictPolicy]
+	if !ok {
+		return defaultPolicy
+	}
+	policy, err := ParseConflictPolicy(strings.TrimSpace(raw))
+	if err != nil {
+		t.Log.Warn("error parsing service-sync-conflict-policy annotation",
+			"service-name", t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
+			"err", err)
+
+		// Fallback to default
+		return defaultPolicy
+	}
+	return policy
+}
+
 func (t *ServiceResource) addPrefixAndK8SNamespace(name, namespace string) string {
 	if t.ConsulServicePrefix != "" {
 		name = fmt.Sprintf("%s%s", t.ConsulServicePrefix, name)
@@ -2,18 +2,26 @@ package catalog
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"testing"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 const nodeName1 = "ip-10-11-12-13.ec2.internal"
@@ -607,6 +615,74 @@ func TestServiceResource_lbAnnotatedMeta(t *testing.T) {
 	})
 }
 
+// Test annotated service weights and tagged addresses.
+func TestServiceResource_lbAnnotatedWeightsAndTaggedAddresses(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	svc.Spec.Ports = []apiv1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+	}
+	svc.Annotations[annotationServiceWeightsPassing] = "10"
+	svc.Annotations[annotationServiceWeightsWarning] = "2"
+	svc.Annotations[annotationServiceTaggedAddressPrefix+"lan"] = "10.0.0.1"
+	svc.Annotations[annotationServiceTaggedAddressPrefix+"wan"] = "5.6.7.8:9090"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, consulapi.AgentWeights{Passing: 10, Warning: 2}, actual[0].Service.Weights)
+		require.Equal(r, map[string]consulapi.ServiceAddress{
+			"lan": {Address: "10.0.0.1", Port: 80},
+			"wan": {Address: "5.6.7.8", Port: 9090},
+		}, actual[0].Service.TaggedAddresses)
+	})
+}
+
+// Test that the cluster name is recorded in the meta and is part of the
+// service instance IDs.
+func TestServiceResource_clusterName(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterName = "cluster-a"
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "cluster-a", actual[0].NodeMeta[ConsulK8SCluster])
+		require.Equal(r, "cluster-a", actual[0].Service.Meta[ConsulK8SCluster])
+		require.Equal(r, serviceID("foo", "cluster-a-1.2.3.4"), actual[0].Service.ID)
+		require.NotEqual(r, serviceID("foo", "1.2.3.4"), actual[0].Service.ID)
+	})
+}
+
 // Test that with LoadBalancerEndpointsSync set to true we track the IP of the endpoints not the LB IP/name.
 func TestServiceResource_lbRegisterEndpoints(t *testing.T) {
 	t.Parallel()
@@ -622,25 +698,11 @@ func TestServiceResource_lbRegisterEndpoints(t *testing.T) {
 	node1, _ := createNodes(t, client)
 
 	// Insert the endpoints
-	_, err := client.CoreV1().Endpoints(metav1.NamespaceDefault).Create(
+	_, err := client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
 		context.Background(),
-		&apiv1.Endpoints{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "foo",
-			},
-
-			Subsets: []apiv1.EndpointSubset{
-				{
-					Addresses: []apiv1.EndpointAddress{
-						{NodeName: &node1.Name, IP: "8.8.8.8"},
-					},
-					Ports: []apiv1.EndpointPort{
-						{Name: "http", Port: 8080},
-						{Name: "rpc", Port: 2000},
-					},
-				},
-			},
-		},
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+			NodeName: &node1.Name, Addresses: []string{"8.8.8.8"},
+		}),
 		metav1.CreateOptions{})
 	require.NoError(t, err)
 
@@ -757,25 +819,11 @@ func TestServiceResource_nodePort_singleEndpoint(t *testing.T) {
 	node1, _ := createNodes(t, client)
 
 	// Insert the endpoints
-	_, err := client.CoreV1().Endpoints(metav1.NamespaceDefault).Create(
+	_, err := client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
 		context.Background(),
-		&apiv1.Endpoints{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: "foo",
-			},
-
-			Subsets: []apiv1.EndpointSubset{
-				{
-					Addresses: []apiv1.EndpointAddress{
-						{NodeName: &node1.Name, IP: "1.2.3.4"},
-					},
-					Ports: []apiv1.EndpointPort{
-						{Name: "http", Port: 8080},
-						{Name: "rpc", Port: 2000},
-					},
-				},
-			},
-		},
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+			NodeName: &node1.Name, Addresses: []string{"1.2.3.4"},
+		}),
 		metav1.CreateOptions{})
 	require.NoError(t, err)
 
@@ -1005,14 +1053,15 @@ func TestServiceResource_clusterIP(t *testing.T) {
 	})
 }
 
-// Test clusterIP with prefix.
-func TestServiceResource_clusterIPPrefix(t *testing.T) {
+// Test that the endpoints of all the endpoint slices of a service are
+// registered and that deleting one endpoint slice only deregisters
+// its endpoints.
+func TestServiceResource_clusterIPMultipleEndpointSlices(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
 	serviceResource := defaultServiceResource(client, syncer)
 	serviceResource.ClusterIPSync = true
-	serviceResource.ConsulServicePrefix = "prefix"
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
@@ -1032,57 +1081,138 @@ func TestServiceResource_clusterIPPrefix(t *testing.T) {
 		defer syncer.Unlock()
 		actual := syncer.Registrations
 		require.Len(r, actual, 2)
-		require.Equal(r, "prefixfoo", actual[0].Service.Service)
 		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 8080, actual[0].Service.Port)
-		require.Equal(r, "prefixfoo", actual[1].Service.Service)
 		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 8080, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+
+	// Delete one of the endpoint slices
+	err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Delete(context.Background(), "foo-abcde", metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	// Verify that only the endpoints of the other endpoint slice are registered
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "2.2.2.2", actual[0].Service.Address)
 	})
 }
 
-// Test that the proper registrations are generated for a ClusterIP type with
-// annotated port name override.
-func TestServiceResource_clusterIPAnnotatedPortName(t *testing.T) {
+// Test that only the endpoint slices of the service's primary IP family are
+// registered when a service has endpoint slices of several address types.
+func TestServiceResource_clusterIPMixedAddressTypes(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		ipFamilies []apiv1.IPFamily
+		expAddress string
+	}{
+		"no IP families": {
+			expAddress: "1.1.1.1",
+		},
+		"IPv4 primary": {
+			ipFamilies: []apiv1.IPFamily{apiv1.IPv4Protocol, apiv1.IPv6Protocol},
+			expAddress: "1.1.1.1",
+		},
+		"IPv6 primary": {
+			ipFamilies: []apiv1.IPFamily{apiv1.IPv6Protocol, apiv1.IPv4Protocol},
+			expAddress: "2001:db8::1",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.ClusterIPSync = true
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert the service
+			svc := clusterIPService("foo", metav1.NamespaceDefault)
+			svc.Spec.IPFamilies = c.ipFamilies
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Insert an endpoint slice of each address type
+			node := nodeName1
+			ipv4Slice := endpointSlice("foo-ipv4", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+				NodeName: &node, Addresses: []string{"1.1.1.1"},
+			})
+			ipv6Slice := endpointSlice("foo-ipv6", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+				NodeName: &node, Addresses: []string{"2001:db8::1"},
+			})
+			ipv6Slice.AddressType = discoveryv1.AddressTypeIPv6
+			fqdnSlice := endpointSlice("foo-fqdn", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+				NodeName: &node, Addresses: []string{"foo.example.com"},
+			})
+			fqdnSlice.AddressType = discoveryv1.AddressTypeFQDN
+			for _, slice := range []*discoveryv1.EndpointSlice{ipv4Slice, ipv6Slice, fqdnSlice} {
+				_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(context.Background(), slice, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			// Verify that only the endpoint of the primary IP family is registered
+			retry.Run(t, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 1)
+				require.Equal(r, c.expAddress, actual[0].Service.Address)
+			})
+		})
+	}
+}
+
+// Test that only the endpoint slices of the service's primary IP family are
+// registered for NodePort services.
+func TestServiceResource_nodePortMixedAddressTypes(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
 	serviceResource := defaultServiceResource(client, syncer)
-	serviceResource.ClusterIPSync = true
+	serviceResource.NodePortSync = ExternalOnly
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
 	defer closer()
 
+	node1, _ := createNodes(t, client)
+
 	// Insert the service
-	svc := clusterIPService("foo", metav1.NamespaceDefault)
-	svc.Annotations[annotationServicePort] = "rpc"
+	svc := nodePortService("foo", metav1.NamespaceDefault)
 	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
-	// Insert the endpoints
-	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+	// Insert an IPv4 and an IPv6 endpoint slice with an endpoint on the same node
+	nodeName := node1.Name
+	ipv4Slice := endpointSlice("foo-ipv4", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+		NodeName: &nodeName, Addresses: []string{"1.1.1.1"},
+	})
+	ipv6Slice := endpointSlice("foo-ipv6", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+		NodeName: &nodeName, Addresses: []string{"2001:db8::1"},
+	})
+	ipv6Slice.AddressType = discoveryv1.AddressTypeIPv6
+	for _, slice := range []*discoveryv1.EndpointSlice{ipv4Slice, ipv6Slice} {
+		_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(context.Background(), slice, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
 
-	// Verify what we got
+	// Verify that the node is only registered once
 	retry.Run(t, func(r *retry.R) {
 		syncer.Lock()
 		defer syncer.Unlock()
 		actual := syncer.Registrations
-		require.Len(r, actual, 2)
+		require.Len(r, actual, 1)
 		require.Equal(r, "foo", actual[0].Service.Service)
-		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 2000, actual[0].Service.Port)
-		require.Equal(r, "foo", actual[1].Service.Service)
-		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 2000, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+		require.Equal(r, 30000, actual[0].Service.Port)
 	})
 }
 
-// Test that the proper registrations are generated for a ClusterIP type with
-// annotated port number override.
-func TestServiceResource_clusterIPAnnotatedPortNumber(t *testing.T) {
+// Test that endpoints that are not ready are not registered.
+func TestServiceResource_clusterIPNotReadyEndpoints(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
@@ -1095,12 +1225,20 @@ func TestServiceResource_clusterIPAnnotatedPortNumber(t *testing.T) {
 
 	// Insert the service
 	svc := clusterIPService("foo", metav1.NamespaceDefault)
-	svc.Annotations[annotationServicePort] = "4141"
 	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
 	// Insert the endpoints
-	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+	ready, notReady := true, false
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
+		context.Background(),
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault,
+			discoveryv1.Endpoint{Addresses: []string{"1.1.1.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			discoveryv1.Endpoint{Addresses: []string{"2.2.2.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+			discoveryv1.Endpoint{Addresses: []string{"3.3.3.3"}},
+		),
+		metav1.CreateOptions{})
+	require.NoError(t, err)
 
 	// Verify what we got
 	retry.Run(t, func(r *retry.R) {
@@ -1108,23 +1246,21 @@ func TestServiceResource_clusterIPAnnotatedPortNumber(t *testing.T) {
 		defer syncer.Unlock()
 		actual := syncer.Registrations
 		require.Len(r, actual, 2)
-		require.Equal(r, "foo", actual[0].Service.Service)
 		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 4141, actual[0].Service.Port)
-		require.Equal(r, "foo", actual[1].Service.Service)
-		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 4141, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+		require.Equal(r, "3.3.3.3", actual[1].Service.Address)
 	})
 }
 
-// Test that the proper registrations are generated for a ClusterIP type with unnamed ports.
-func TestServiceResource_clusterIPUnnamedPorts(t *testing.T) {
+// Test that with SyncHealthChecks set to true, a health check reflecting
+// the readiness of the endpoint is registered with each instance.
+func TestServiceResource_healthChecks(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
 	serviceResource := defaultServiceResource(client, syncer)
 	serviceResource.ClusterIPSync = true
+	serviceResource.SyncHealthChecks = true
+	serviceResource.HealthCheckName = "Readiness"
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
@@ -1132,15 +1268,19 @@ func TestServiceResource_clusterIPUnnamedPorts(t *testing.T) {
 
 	// Insert the service
 	svc := clusterIPService("foo", metav1.NamespaceDefault)
-	svc.Spec.Ports = []apiv1.ServicePort{
-		{Port: 80, TargetPort: intstr.FromInt(8080)},
-		{Port: 8500, TargetPort: intstr.FromInt(2000)},
-	}
 	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
 	// Insert the endpoints
-	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+	ready, notReady := true, false
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
+		context.Background(),
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault,
+			discoveryv1.Endpoint{Addresses: []string{"1.1.1.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			discoveryv1.Endpoint{Addresses: []string{"2.2.2.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		),
+		metav1.CreateOptions{})
+	require.NoError(t, err)
 
 	// Verify what we got
 	retry.Run(t, func(r *retry.R) {
@@ -1148,24 +1288,27 @@ func TestServiceResource_clusterIPUnnamedPorts(t *testing.T) {
 		defer syncer.Unlock()
 		actual := syncer.Registrations
 		require.Len(r, actual, 2)
-		require.Equal(r, "foo", actual[0].Service.Service)
 		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 8080, actual[0].Service.Port)
-		require.Equal(r, "foo", actual[1].Service.Service)
+		require.NotNil(r, actual[0].Check)
+		require.Equal(r, actual[0].Service.ID+"/kubernetes-readiness-check", actual[0].Check.CheckID)
+		require.Equal(r, "Readiness", actual[0].Check.Name)
+		require.Equal(r, consulapi.HealthPassing, actual[0].Check.Status)
+		require.Equal(r, actual[0].Service.ID, actual[0].Check.ServiceID)
+		require.Equal(r, "k8s-sync", actual[0].Check.Node)
 		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 8080, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+		require.NotNil(r, actual[1].Check)
+		require.Equal(r, consulapi.HealthCritical, actual[1].Check.Status)
 	})
 }
 
-// Test that the ClusterIP services aren't synced when ClusterIPSync
-// is disabled.
-func TestServiceResource_clusterIPSyncDisabled(t *testing.T) {
+// Test that the health check name can be overridden with an annotation.
+func TestServiceResource_healthCheckNameAnnotation(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
 	serviceResource := defaultServiceResource(client, syncer)
-	serviceResource.ClusterIPSync = false
+	serviceResource.ClusterIPSync = true
+	serviceResource.SyncHealthChecks = true
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
@@ -1173,6 +1316,7 @@ func TestServiceResource_clusterIPSyncDisabled(t *testing.T) {
 
 	// Insert the service
 	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServiceHealthCheckName] = "Custom Check"
 	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
@@ -1184,54 +1328,78 @@ func TestServiceResource_clusterIPSyncDisabled(t *testing.T) {
 		syncer.Lock()
 		defer syncer.Unlock()
 		actual := syncer.Registrations
-		require.Len(r, actual, 0)
+		require.Len(r, actual, 2)
+		require.Equal(r, "Custom Check", actual[0].Check.Name)
+		require.Equal(r, "Custom Check", actual[1].Check.Name)
 	})
 }
 
-// Test that the ClusterIP services are synced when watching all namespaces.
-func TestServiceResource_clusterIPAllNamespaces(t *testing.T) {
+// Test that the health check is warning until the instance has been not
+// ready for HealthCheckCriticalAfter, and that it becomes critical even if
+// the endpoint slice doesn't change after that.
+func TestServiceResource_healthCheckCriticalAfter(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
-	testNamespace := "test_namespace"
 	serviceResource := defaultServiceResource(client, syncer)
 	serviceResource.ClusterIPSync = true
+	serviceResource.SyncHealthChecks = true
+	serviceResource.HealthCheckCriticalAfter = 500 * time.Millisecond
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
 	defer closer()
 
 	// Insert the service
-	svc := clusterIPService("foo", testNamespace)
-	_, err := client.CoreV1().Services(testNamespace).Create(context.Background(), svc, metav1.CreateOptions{})
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
-	// Insert the endpoints
-	createEndpoints(t, client, "foo", testNamespace)
+	// Insert not ready endpoints
+	notReady := false
+	slice := endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault,
+		discoveryv1.Endpoint{Addresses: []string{"1.1.1.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+	)
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(context.Background(), slice, metav1.CreateOptions{})
+	require.NoError(t, err)
+	start := time.Now()
 
-	// Verify what we got
+	// The check is warning at first
 	retry.Run(t, func(r *retry.R) {
 		syncer.Lock()
 		defer syncer.Unlock()
 		actual := syncer.Registrations
-		require.Len(r, actual, 2)
-		require.Equal(r, "foo", actual[0].Service.Service)
-		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 8080, actual[0].Service.Port)
-		require.Equal(r, "foo", actual[1].Service.Service)
-		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 8080, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+		require.Len(r, actual, 1)
+		require.Equal(r, consulapi.HealthWarning, actual[0].Check.Status)
+	})
+
+	// Updating the service doesn't reset when the instance became not ready
+	svc.Annotations[annotationServiceTags] = "abc"
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Update(context.Background(), svc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// The check becomes critical without any further changes to the endpoint slice
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Contains(r, actual[0].Service.Tags, "abc")
+		require.Equal(r, consulapi.HealthCritical, actual[0].Check.Status)
 	})
+	require.GreaterOrEqual(t, time.Since(start), serviceResource.HealthCheckCriticalAfter)
 }
 
-// Test using a port name annotation when the targetPort is a named port.
-func TestServiceResource_clusterIPTargetPortNamed(t *testing.T) {
+// Test that when the instance becomes ready again, its check is passing and
+// it must be not ready for HealthCheckCriticalAfter again before it's critical.
+func TestServiceResource_healthCheckCriticalAfterReset(t *testing.T) {
 	t.Parallel()
 	client := fake.NewSimpleClientset()
 	syncer := newTestSyncer()
 	serviceResource := defaultServiceResource(client, syncer)
 	serviceResource.ClusterIPSync = true
+	serviceResource.SyncHealthChecks = true
+	serviceResource.HealthCheckCriticalAfter = time.Hour
 
 	// Start the controller
 	closer := controller.TestControllerRun(&serviceResource)
@@ -1239,60 +1407,835 @@ func TestServiceResource_clusterIPTargetPortNamed(t *testing.T) {
 
 	// Insert the service
 	svc := clusterIPService("foo", metav1.NamespaceDefault)
-	svc.Annotations[annotationServicePort] = "rpc"
-	svc.Spec.Ports = []apiv1.ServicePort{
-		{Port: 80, TargetPort: intstr.FromString("httpPort"), Name: "http"},
-		{Port: 8500, TargetPort: intstr.FromString("rpcPort"), Name: "rpc"},
-	}
 	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
 	require.NoError(t, err)
 
-	// Insert the endpoints
-	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+	// Insert not ready endpoints
+	ready, notReady := true, false
+	slice := endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault,
+		discoveryv1.Endpoint{Addresses: []string{"1.1.1.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+	)
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(context.Background(), slice, metav1.CreateOptions{})
+	require.NoError(t, err)
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, consulapi.HealthWarning, actual[0].Check.Status)
+	})
 
-	// Verify what we got
+	// Make the endpoint ready
+	slice.Endpoints[0].Conditions.Ready = &ready
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Update(context.Background(), slice, metav1.UpdateOptions{})
+	require.NoError(t, err)
 	retry.Run(t, func(r *retry.R) {
 		syncer.Lock()
 		defer syncer.Unlock()
 		actual := syncer.Registrations
-		require.Len(r, actual, 2)
-		require.Equal(r, "foo", actual[0].Service.Service)
-		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
-		require.Equal(r, 2000, actual[0].Service.Port)
-		require.Equal(r, "foo", actual[1].Service.Service)
-		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
-		require.Equal(r, 2000, actual[1].Service.Port)
-		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+		require.Len(r, actual, 1)
+		require.Equal(r, consulapi.HealthPassing, actual[0].Check.Status)
 	})
+	serviceResource.serviceLock.RLock()
+	require.Empty(t, serviceResource.healthCheckFailures)
+	serviceResource.serviceLock.RUnlock()
 }
 
-// Test allow/deny namespace lists.
-func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
+// Test that the health check reflects the readiness of the containers
+// listed in the annotation instead of the readiness of the endpoint.
+func TestServiceResource_healthCheckContainers(t *testing.T) {
 	t.Parallel()
-
 	cases := map[string]struct {
-		AllowList     mapset.Set
-		DenyList      mapset.Set
-		ExpNamespaces []string
+		containerStatuses []apiv1.ContainerStatus
+		expStatus         string
 	}{
-		"empty lists": {
-			AllowList:     mapset.NewSet(),
-			DenyList:      mapset.NewSet(),
-			ExpNamespaces: nil,
+		"listed containers ready": {
+			containerStatuses: []apiv1.ContainerStatus{
+				{Name: "app", Ready: true},
+				{Name: "other", Ready: false},
+			},
+			expStatus: consulapi.HealthPassing,
 		},
-		"only from allow list": {
-			AllowList:     mapset.NewSet("foo"),
-			DenyList:      mapset.NewSet(),
-			ExpNamespaces: []string{"foo"},
+		"listed container not ready": {
+			containerStatuses: []apiv1.ContainerStatus{
+				{Name: "app", Ready: false},
+				{Name: "other", Ready: true},
+			},
+			expStatus: consulapi.HealthCritical,
 		},
-		"both in allow and deny": {
-			AllowList:     mapset.NewSet("foo"),
-			DenyList:      mapset.NewSet("foo"),
-			ExpNamespaces: nil,
+		"listed container missing": {
+			containerStatuses: []apiv1.ContainerStatus{
+				{Name: "other", Ready: true},
+			},
+			expStatus: consulapi.HealthCritical,
 		},
-		"deny removes one from allow": {
-			AllowList:     mapset.NewSet("foo", "bar"),
-			DenyList:      mapset.NewSet("foo"),
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.ClusterIPSync = true
+			serviceResource.SyncHealthChecks = true
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert the pod
+			pod := &apiv1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-pod", Namespace: metav1.NamespaceDefault},
+				Status:     apiv1.PodStatus{ContainerStatuses: c.containerStatuses},
+			}
+			_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.Background(), pod, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Insert the service
+			svc := clusterIPService("foo", metav1.NamespaceDefault)
+			svc.Annotations[annotationServiceHealthCheckContainers] = "app"
+			_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Insert the endpoints
+			notReady := false
+			_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
+				context.Background(),
+				endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+					Addresses:  []string{"1.1.1.1"},
+					Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+					TargetRef:  &apiv1.ObjectReference{Kind: "Pod", Name: "foo-pod", Namespace: metav1.NamespaceDefault},
+				}),
+				metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			// Verify what we got
+			retry.Run(t, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 1)
+				require.Equal(r, c.expStatus, actual[0].Check.Status)
+			})
+		})
+	}
+}
+
+// Test that with SyncPodAnnotations set to true, the annotations of a pod
+// override the annotations of the service for the instance of its endpoint.
+func TestServiceResource_clusterIPPodAnnotations(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.SyncPodAnnotations = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the pods
+	annotatedPod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo-annotated",
+			Namespace: metav1.NamespaceDefault,
+			Annotations: map[string]string{
+				annotationServiceMetaPrefix + "version":      "canary",
+				annotationServiceWeightsPassing:              "1",
+				annotationServiceTaggedAddressPrefix + "lan": "10.0.0.1",
+			},
+		},
+	}
+	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.Background(), annotatedPod, metav1.CreateOptions{})
+	require.NoError(t, err)
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo-pod", Namespace: metav1.NamespaceDefault}}
+	_, err = client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServiceMetaPrefix+"version"] = "stable"
+	svc.Annotations[annotationServiceWeightsPassing] = "10"
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
+		context.Background(),
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault,
+			discoveryv1.Endpoint{
+				Addresses: []string{"1.1.1.1"},
+				TargetRef: &apiv1.ObjectReference{Kind: "Pod", Name: "foo-annotated", Namespace: metav1.NamespaceDefault},
+			},
+			discoveryv1.Endpoint{
+				Addresses: []string{"2.2.2.2"},
+				TargetRef: &apiv1.ObjectReference{Kind: "Pod", Name: "foo-pod", Namespace: metav1.NamespaceDefault},
+			},
+		),
+		metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, "canary", actual[0].Service.Meta["version"])
+		require.Equal(r, consulapi.AgentWeights{Passing: 1, Warning: 1}, actual[0].Service.Weights)
+		require.Equal(r, map[string]consulapi.ServiceAddress{
+			"lan": {Address: "10.0.0.1", Port: 8080},
+		}, actual[0].Service.TaggedAddresses)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, "stable", actual[1].Service.Meta["version"])
+		require.Equal(r, consulapi.AgentWeights{Passing: 10, Warning: 1}, actual[1].Service.Weights)
+		require.Empty(r, actual[1].Service.TaggedAddresses)
+	})
+}
+
+// Test that with SyncPodAnnotations set to true, pods are read from the
+// watched pods and a change to the annotations of a pod is synced without
+// its endpoint slice changing.
+func TestServiceResource_podAnnotationsChange(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("pods must be read from the informer")
+	})
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.SyncPodAnnotations = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the pod
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo-pod",
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: map[string]string{annotationServiceMetaPrefix + "version": "v1"},
+		},
+	}
+	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.Background(), pod, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(
+		context.Background(),
+		endpointSlice("foo-abcde", "foo", metav1.NamespaceDefault, discoveryv1.Endpoint{
+			Addresses: []string{"1.1.1.1"},
+			TargetRef: &apiv1.ObjectReference{Kind: "Pod", Name: "foo-pod", Namespace: metav1.NamespaceDefault},
+		}),
+		metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "v1", actual[0].Service.Meta["version"])
+	})
+
+	// Update the pod's annotations
+	pod.Annotations[annotationServiceMetaPrefix+"version"] = "v2"
+	_, err = client.CoreV1().Pods(metav1.NamespaceDefault).Update(context.Background(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "v2", actual[0].Service.Meta["version"])
+	})
+}
+
+// Test that pods are only watched in the namespaces that services are synced
+// from, and only if pod annotations or health checks are synced.
+func TestServiceResource_podNamespaces(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		syncPodAnnotations bool
+		allow              []interface{}
+		deny               []interface{}
+		expNamespaces      []string
+	}{
+		"pod annotations not synced": {
+			allow:         []interface{}{"*"},
+			expNamespaces: nil,
+		},
+		"all namespaces": {
+			syncPodAnnotations: true,
+			allow:              []interface{}{"*"},
+			deny:               []interface{}{"kube-system"},
+			expNamespaces:      []string{metav1.NamespaceAll},
+		},
+		"allowed namespaces": {
+			syncPodAnnotations: true,
+			allow:              []interface{}{"foo", "bar", "kube-system"},
+			deny:               []interface{}{"kube-system"},
+			expNamespaces:      []string{"bar", "foo"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			serviceResource := defaultServiceResource(client, newTestSyncer())
+			serviceResource.SyncPodAnnotations = c.syncPodAnnotations
+			serviceResource.AllowK8sNamespacesSet = mapset.NewSet(c.allow...)
+			serviceResource.DenyK8sNamespacesSet = mapset.NewSet(c.deny...)
+
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			podListNamespaces := func() []string {
+				var namespaces []string
+				for _, action := range client.Actions() {
+					if action.Matches("list", "pods") {
+						namespaces = append(namespaces, action.GetNamespace())
+					}
+				}
+				sort.Strings(namespaces)
+				return namespaces
+			}
+			retry.Run(t, func(r *retry.R) {
+				require.Equal(r, c.expNamespaces, podListNamespaces())
+			})
+			// Make sure no other namespaces are listed after the expected ones.
+			time.Sleep(100 * time.Millisecond)
+			require.Equal(t, c.expNamespaces, podListNamespaces())
+		})
+	}
+}
+
+// Test clusterIP with prefix.
+func TestServiceResource_clusterIPPrefix(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulServicePrefix = "prefix"
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "prefixfoo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 8080, actual[0].Service.Port)
+		require.Equal(r, "prefixfoo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 8080, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that the proper registrations are generated for a ClusterIP type with
+// annotated port name override.
+func TestServiceResource_clusterIPAnnotatedPortName(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServicePort] = "rpc"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 2000, actual[0].Service.Port)
+		require.Equal(r, "foo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 2000, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that the proper registrations are generated for a ClusterIP type with
+// annotated port number override.
+func TestServiceResource_clusterIPAnnotatedPortNumber(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServicePort] = "4141"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 4141, actual[0].Service.Port)
+		require.Equal(r, "foo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 4141, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that the proper registrations are generated for a ClusterIP type with unnamed ports.
+func TestServiceResource_clusterIPUnnamedPorts(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Spec.Ports = []apiv1.ServicePort{
+		{Port: 80, TargetPort: intstr.FromInt(8080)},
+		{Port: 8500, TargetPort: intstr.FromInt(2000)},
+	}
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 8080, actual[0].Service.Port)
+		require.Equal(r, "foo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 8080, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that the ClusterIP services aren't synced when ClusterIPSync
+// is disabled.
+func TestServiceResource_clusterIPSyncDisabled(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = false
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 0)
+	})
+}
+
+// Test that the ClusterIP services are synced when watching all namespaces.
+func TestServiceResource_clusterIPAllNamespaces(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	testNamespace := "test_namespace"
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", testNamespace)
+	_, err := client.CoreV1().Services(testNamespace).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", testNamespace)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 8080, actual[0].Service.Port)
+		require.Equal(r, "foo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 8080, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test using a port name annotation when the targetPort is a named port.
+func TestServiceResource_clusterIPTargetPortNamed(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	svc.Annotations[annotationServicePort] = "rpc"
+	svc.Spec.Ports = []apiv1.ServicePort{
+		{Port: 80, TargetPort: intstr.FromString("httpPort"), Name: "http"},
+		{Port: 8500, TargetPort: intstr.FromString("rpcPort"), Name: "rpc"},
+	}
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 2000, actual[0].Service.Port)
+		require.Equal(r, "foo", actual[1].Service.Service)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, 2000, actual[1].Service.Port)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that ExternalName services are not synced by default.
+func TestServiceResource_externalNameSyncDisabled(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := externalNameService("foo", metav1.NamespaceDefault, "db.example.com")
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 0)
+	})
+}
+
+// Test that ExternalName services are synced to the external node.
+func TestServiceResource_externalName(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.SyncExternalNameServices = true
+	serviceResource.ConsulExternalNodeName = "k8s-sync-external"
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := externalNameService("foo", metav1.NamespaceDefault, "db.example.com")
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "k8s-sync-external", actual[0].Node)
+		require.Equal(r, "true", actual[0].NodeMeta[ConsulExternalNodeKey])
+		require.Equal(r, ConsulSourceValue, actual[0].NodeMeta[ConsulSourceKey])
+		require.Equal(r, "foo", actual[0].Service.Service)
+		require.Equal(r, "db.example.com", actual[0].Service.Address)
+		require.Equal(r, 5432, actual[0].Service.Port)
+		require.Nil(r, actual[0].Check)
+	})
+}
+
+// Test that ExternalName services can be annotated to register a health check.
+func TestServiceResource_externalNameHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		Annotation string
+		Ports      []apiv1.ServicePort
+		ExpCheck   bool
+	}{
+		"annotation true": {
+			Annotation: "true",
+			Ports:      []apiv1.ServicePort{{Name: "db", Port: 5432}},
+			ExpCheck:   true,
+		},
+		"annotation false": {
+			Annotation: "false",
+			Ports:      []apiv1.ServicePort{{Name: "db", Port: 5432}},
+			ExpCheck:   false,
+		},
+		"invalid annotation": {
+			Annotation: "not-a-bool",
+			Ports:      []apiv1.ServicePort{{Name: "db", Port: 5432}},
+			ExpCheck:   false,
+		},
+		"no port": {
+			Annotation: "true",
+			ExpCheck:   false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(tt *testing.T) {
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.SyncExternalNameServices = true
+			serviceResource.ConsulExternalNodeName = "k8s-sync-external"
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert the service
+			svc := externalNameService("foo", metav1.NamespaceDefault, "db.example.com")
+			svc.Annotations[annotationExternalNameHealthCheck] = c.Annotation
+			svc.Spec.Ports = c.Ports
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(tt, err)
+
+			// Verify what we got
+			retry.Run(tt, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 1)
+				if !c.ExpCheck {
+					require.Nil(r, actual[0].Check)
+					return
+				}
+				check := actual[0].Check
+				require.NotNil(r, check)
+				require.Equal(r, "k8s-sync-external", check.Node)
+				require.Equal(r, actual[0].Service.ID, check.ServiceID)
+				require.Equal(r, "foo", check.ServiceName)
+				require.Equal(r, "db.example.com:5432", check.Definition.TCP)
+				require.Equal(r, externalNameHealthCheckInterval, check.Definition.IntervalDuration)
+			})
+		})
+	}
+}
+
+// Test that the conflict policy is recorded in the registration meta.
+func TestServiceResource_conflictPolicy(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		DefaultPolicy ConflictPolicy
+		Annotation    string
+		ExpPolicy     ConflictPolicy
+	}{
+		"defaults to k8s-wins": {
+			ExpPolicy: ConflictPolicyK8SWins,
+		},
+		"default policy": {
+			DefaultPolicy: ConflictPolicyMerge,
+			ExpPolicy:     ConflictPolicyMerge,
+		},
+		"annotation overrides default policy": {
+			DefaultPolicy: ConflictPolicyMerge,
+			Annotation:    "consul-wins",
+			ExpPolicy:     ConflictPolicyConsulWins,
+		},
+		"invalid annotation falls back to default policy": {
+			DefaultPolicy: ConflictPolicyError,
+			Annotation:    "k8s-loses",
+			ExpPolicy:     ConflictPolicyError,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(tt *testing.T) {
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			serviceResource.ConflictPolicy = c.DefaultPolicy
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			// Insert an LB service
+			svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+			if c.Annotation != "" {
+				svc.Annotations[annotationServiceSyncConflictPolicy] = c.Annotation
+			}
+			_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+			require.NoError(tt, err)
+
+			// Verify what we got
+			retry.Run(tt, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, 1)
+				require.Equal(r, string(c.ExpPolicy), actual[0].Service.Meta[ConsulK8SConflictPolicy])
+			})
+		})
+	}
+}
+
+// Test that services that don't have any endpoints are still excluded from
+// being synced to Kubernetes based on their conflict policy.
+func TestServiceResource_ConsulServicesExcludedFromK8S(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulServicePrefix = "k8s-"
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert ClusterIP services without endpoints
+	for name, policy := range map[string]ConflictPolicy{
+		"k8s-wins":    ConflictPolicyK8SWins,
+		"consul-wins": ConflictPolicyConsulWins,
+		"merge":       ConflictPolicyMerge,
+		"error":       ConflictPolicyError,
+	} {
+		svc := clusterIPService(name, metav1.NamespaceDefault)
+		svc.Annotations[annotationServiceSyncConflictPolicy] = string(policy)
+		_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	svc := clusterIPService("renamed", metav1.NamespaceDefault)
+	svc.Annotations[annotationServiceName] = "custom-name"
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		require.Equal(r, map[string]struct{}{
+			"k8s-k8s-wins": {},
+			"k8s-error":    {},
+			"custom-name":  {},
+		}, serviceResource.ConsulServicesExcludedFromK8S())
+	})
+}
+
+// Test allow/deny namespace lists.
+func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		AllowList     mapset.Set
+		DenyList      mapset.Set
+		ExpNamespaces []string
+	}{
+		"empty lists": {
+			AllowList:     mapset.NewSet(),
+			DenyList:      mapset.NewSet(),
+			ExpNamespaces: nil,
+		},
+		"only from allow list": {
+			AllowList:     mapset.NewSet("foo"),
+			DenyList:      mapset.NewSet(),
+			ExpNamespaces: []string{"foo"},
+		},
+		"both in allow and deny": {
+			AllowList:     mapset.NewSet("foo"),
+			DenyList:      mapset.NewSet("foo"),
+			ExpNamespaces: nil,
+		},
+		"deny removes one from allow": {
+			AllowList:     mapset.NewSet("foo", "bar"),
+			DenyList:      mapset.NewSet("foo"),
 			ExpNamespaces: []string{"bar"},
 		},
 		"* in allow": {
@@ -1353,6 +2296,149 @@ func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
 	}
 }
 
+// Test label selector and annotation filters.
+func TestServiceResource_serviceFilters(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		Selector           string
+		IncludeAnnotations []AnnotationFilter
+		ExcludeAnnotations []AnnotationFilter
+		ExpServices        []string
+	}{
+		"no filters": {
+			ExpServices: []string{"foo", "bar"},
+		},
+		"label selector": {
+			Selector:    "team=payments",
+			ExpServices: []string{"foo"},
+		},
+		"label selector matching nothing": {
+			Selector:    "team in (billing)",
+			ExpServices: nil,
+		},
+		"include annotation key": {
+			IncludeAnnotations: []AnnotationFilter{{Key: "example.com/public"}},
+			ExpServices:        []string{"bar"},
+		},
+		"include annotation key and value": {
+			IncludeAnnotations: []AnnotationFilter{{Key: "example.com/tier", Value: "backend"}},
+			ExpServices:        []string{"foo"},
+		},
+		"include matches any filter": {
+			IncludeAnnotations: []AnnotationFilter{{Key: "example.com/public"}, {Key: "example.com/tier", Value: "backend"}},
+			ExpServices:        []string{"foo", "bar"},
+		},
+		"exclude annotation": {
+			ExcludeAnnotations: []AnnotationFilter{{Key: "example.com/public"}},
+			ExpServices:        []string{"foo"},
+		},
+		"exclude takes precedence over include": {
+			IncludeAnnotations: []AnnotationFilter{{Key: "example.com/tier"}},
+			ExcludeAnnotations: []AnnotationFilter{{Key: "example.com/tier", Value: "frontend"}},
+			ExpServices:        []string{"foo"},
+		},
+		"label selector and annotation filters": {
+			Selector:           "team",
+			IncludeAnnotations: []AnnotationFilter{{Key: "example.com/public"}},
+			ExpServices:        nil,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(tt *testing.T) {
+			client := fake.NewSimpleClientset()
+			syncer := newTestSyncer()
+			serviceResource := defaultServiceResource(client, syncer)
+			if c.Selector != "" {
+				selector, err := labels.Parse(c.Selector)
+				require.NoError(tt, err)
+				serviceResource.ServiceSelector = selector
+			}
+			serviceResource.IncludeAnnotations = c.IncludeAnnotations
+			serviceResource.ExcludeAnnotations = c.ExcludeAnnotations
+
+			// Start the controller
+			closer := controller.TestControllerRun(&serviceResource)
+			defer closer()
+
+			foo := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+			foo.Labels = map[string]string{"team": "payments"}
+			foo.Annotations["example.com/tier"] = "backend"
+			bar := lbService("bar", metav1.NamespaceDefault, "2.3.4.5")
+			bar.Annotations["example.com/tier"] = "frontend"
+			bar.Annotations["example.com/public"] = "true"
+			for _, svc := range []*apiv1.Service{foo, bar} {
+				_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+				require.NoError(tt, err)
+			}
+
+			// Test we got registrations for the expected services.
+			retry.Run(tt, func(r *retry.R) {
+				syncer.Lock()
+				defer syncer.Unlock()
+				actual := syncer.Registrations
+				require.Len(r, actual, len(c.ExpServices))
+			})
+
+			syncer.Lock()
+			defer syncer.Unlock()
+			for _, expSvc := range c.ExpServices {
+				found := false
+				for _, reg := range syncer.Registrations {
+					if reg.Service.Service == expSvc {
+						found = true
+					}
+				}
+				require.True(tt, found, "did not find service %s", expSvc)
+			}
+		})
+	}
+}
+
+// Test that a service is deregistered once its labels no longer match
+// the label selector.
+func TestServiceResource_changeLabelsToNotMatchSelector(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	selector, err := labels.Parse("consul-sync=true")
+	require.NoError(t, err)
+	serviceResource.ServiceSelector = selector
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert an LB service with the label
+	svc := lbService("foo", metav1.NamespaceDefault, "1.2.3.4")
+	svc.Labels = map[string]string{"consul-sync": "true"}
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify the service gets registered.
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+	})
+
+	// Remove the label.
+	svc.Labels = nil
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Update(context.Background(), svc, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Verify the service gets deregistered.
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 0)
+	})
+}
+
 // Test that services are synced to the correct destination ns
 // when a single destination namespace is set.
 func TestServiceResource_singleDestNamespace(t *testing.T) {
@@ -1453,6 +2539,125 @@ func TestServiceResource_MirroredPrefixNamespace(t *testing.T) {
 	})
 }
 
+// Test that namespace mapping rules take precedence over the namespace options
+// and that what they don't set falls back to them.
+func TestServiceResource_consulPartitionAndNamespace(t *testing.T) {
+	rules, err := ParseNamespaceMappingRules([]string{
+		"regex:^team-(.*)$=teams/team-${1}",
+		"prefix:infra-=infra",
+		"prefix:shared-=/shared",
+	})
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		mirroring       bool
+		mirroringPrefix string
+		k8sNS           string
+		expPartition    string
+		expNamespace    string
+	}{
+		"rule sets partition and namespace": {
+			k8sNS:        "team-a",
+			expPartition: "teams",
+			expNamespace: "team-a",
+		},
+		"rule sets partition only uses destination namespace": {
+			k8sNS:        "infra-a",
+			expPartition: "infra",
+			expNamespace: "dest",
+		},
+		"rule sets partition only uses mirrored namespace": {
+			mirroring:    true,
+			k8sNS:        "infra-a",
+			expPartition: "infra",
+			expNamespace: "infra-a",
+		},
+		"rule sets partition only uses mirrored namespace with prefix": {
+			mirroring:       true,
+			mirroringPrefix: "k8s-",
+			k8sNS:           "infra-a",
+			expPartition:    "infra",
+			expNamespace:    "k8s-infra-a",
+		},
+		"rule sets namespace only uses default partition": {
+			mirroring:    true,
+			k8sNS:        "shared-a",
+			expPartition: "default-partition",
+			expNamespace: "shared",
+		},
+		"no rule uses destination namespace": {
+			k8sNS:        "other",
+			expPartition: "default-partition",
+			expNamespace: "dest",
+		},
+		"no rule uses mirrored namespace with prefix": {
+			mirroring:       true,
+			mirroringPrefix: "k8s-",
+			k8sNS:           "other",
+			expPartition:    "default-partition",
+			expNamespace:    "k8s-other",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			serviceResource := ServiceResource{
+				EnableNamespaces:           true,
+				ConsulDestinationNamespace: "dest",
+				EnableK8SNSMirroring:       c.mirroring,
+				K8SNSMirroringPrefix:       c.mirroringPrefix,
+				ConsulPartition:            "default-partition",
+				NamespaceMappingRules:      rules,
+			}
+			partition, namespace := serviceResource.consulPartitionAndNamespace(c.k8sNS)
+			require.Equal(t, c.expPartition, partition)
+			require.Equal(t, c.expNamespace, namespace)
+		})
+	}
+}
+
+// Test that services are registered into the partition and namespace of the
+// namespace mapping rule that matches their namespace.
+func TestServiceResource_namespaceMappingRules(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.EnableNamespaces = true
+	serviceResource.ConsulDestinationNamespace = "default"
+	rules, err := ParseNamespaceMappingRules([]string{"regex:^team-(.*)$=teams/${1}"})
+	require.NoError(t, err)
+	serviceResource.NamespaceMappingRules = rules
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	for _, ns := range []string{"team-a", "other"} {
+		_, err := client.CoreV1().Services(ns).
+			Create(context.Background(), lbService(ns, ns, "1.2.3.4"), metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		for _, reg := range actual {
+			switch reg.Service.Service {
+			case "team-a":
+				require.Equal(r, "teams", reg.Partition)
+				require.Equal(r, "teams", reg.Service.Partition)
+				require.Equal(r, "a", reg.Service.Namespace)
+			case "other":
+				require.Equal(r, "", reg.Partition)
+				require.Equal(r, "", reg.Service.Partition)
+				require.Equal(r, "default", reg.Service.Namespace)
+			default:
+				r.Fatalf("unexpected service %q", reg.Service.Service)
+			}
+		}
+	})
+}
+
 func TestParseTags(t *testing.T) {
 	cases := []struct {
 		tagsAnno string
@@ -1522,6 +2727,25 @@ func lbService(name, namespace, lbIP string) *apiv1.Service {
 	}
 }
 
+// externalNameService returns a Kubernetes service of type ExternalName.
+func externalNameService(name, namespace, externalName string) *apiv1.Service {
+	return &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{},
+		},
+
+		Spec: apiv1.ServiceSpec{
+			Type:         apiv1.ServiceTypeExternalName,
+			ExternalName: externalName,
+			Ports: []apiv1.ServicePort{
+				{Name: "db", Port: 5432},
+			},
+		},
+	}
+}
+
 // nodePortService returns a Kubernetes service of type NodePort.
 func nodePortService(name, namespace string) *apiv1.Service {
 	return &apiv1.Service{
@@ -1597,45 +2821,48 @@ func createNodes(t *testing.T, client *fake.Clientset) (*apiv1.Node, *apiv1.Node
 	return node1, node2
 }
 
-// createEndpoints calls the fake k8s client to create two endpoints on two nodes.
+// createEndpoints calls the fake k8s client to create two endpoint slices
+// with one endpoint each on two nodes.
 func createEndpoints(t *testing.T, client *fake.Clientset, serviceName string, namespace string) {
 	node1 := nodeName1
 	node2 := nodeName2
-	_, err := client.CoreV1().Endpoints(namespace).Create(
+	_, err := client.DiscoveryV1().EndpointSlices(namespace).Create(
 		context.Background(),
-		&apiv1.Endpoints{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      serviceName,
-				Namespace: namespace,
-			},
-
-			Subsets: []apiv1.EndpointSubset{
-				{
-					Addresses: []apiv1.EndpointAddress{
-						{NodeName: &node1, IP: "1.1.1.1"},
-					},
-					Ports: []apiv1.EndpointPort{
-						{Name: "http", Port: 8080},
-						{Name: "rpc", Port: 2000},
-					},
-				},
-
-				{
-					Addresses: []apiv1.EndpointAddress{
-						{NodeName: &node2, IP: "2.2.2.2"},
-					},
-					Ports: []apiv1.EndpointPort{
-						{Name: "http", Port: 8080},
-						{Name: "rpc", Port: 2000},
-					},
-				},
-			},
-		},
+		endpointSlice(serviceName+"-abcde", serviceName, namespace, discoveryv1.Endpoint{
+			NodeName: &node1, Addresses: []string{"1.1.1.1"},
+		}),
 		metav1.CreateOptions{})
+	require.NoError(t, err)
 
+	_, err = client.DiscoveryV1().EndpointSlices(namespace).Create(
+		context.Background(),
+		endpointSlice(serviceName+"-fghij", serviceName, namespace, discoveryv1.Endpoint{
+			NodeName: &node2, Addresses: []string{"2.2.2.2"},
+		}),
+		metav1.CreateOptions{})
 	require.NoError(t, err)
 }
 
+// endpointSlice returns an endpoint slice of the service with the given
+// endpoints and the http and rpc ports.
+func endpointSlice(name, serviceName, namespace string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	httpName, httpPort := "http", int32(8080)
+	rpcName, rpcPort := "rpc", int32(2000)
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: serviceName},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+		Ports: []discoveryv1.EndpointPort{
+			{Name: &httpName, Port: &httpPort},
+			{Name: &rpcName, Port: &rpcPort},
+		},
+	}
+}
+
 func defaultServiceResource(client kubernetes.Interface, syncer Syncer) ServiceResource {
 	return ServiceResource{
 		Log:                   hclog.Default(),
@@ -0,0 +1,61 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnnotationFilter matches Kubernetes services by annotation. A service
+// matches if it has an annotation with the filter's key and, if the filter
+// has a value, the annotation is set to that value.
+type AnnotationFilter struct {
+	Key   string
+	Value string
+}
+
+// ParseAnnotationFilter parses an annotation filter in the form `<key>` or
+// `<key>=<value>`.
+func ParseAnnotationFilter(raw string) (AnnotationFilter, error) {
+	key, value := raw, ""
+	hasValue := false
+	if i := strings.Index(raw, "="); i >= 0 {
+		key, value, hasValue = raw[:i], raw[i+1:], true
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if key == "" {
+		return AnnotationFilter{}, fmt.Errorf("annotation filter %q is missing an annotation key", raw)
+	}
+	if hasValue && value == "" {
+		return AnnotationFilter{}, fmt.Errorf("annotation filter %q is missing a value after '='", raw)
+	}
+	return AnnotationFilter{Key: key, Value: value}, nil
+}
+
+// Matches returns true if the given annotations match the filter.
+func (f AnnotationFilter) Matches(annotations map[string]string) bool {
+	v, ok := annotations[f.Key]
+	if !ok {
+		return false
+	}
+	return f.Value == "" || f.Value == v
+}
+
+// String returns the filter in the format accepted by ParseAnnotationFilter.
+func (f AnnotationFilter) String() string {
+	if f.Value == "" {
+		return f.Key
+	}
+	return fmt.Sprintf("%s=%s", f.Key, f.Value)
+}
+
+// matchesAnyAnnotationFilter returns true if the annotations match at least
+// one of the filters.
+func matchesAnyAnnotationFilter(filters []AnnotationFilter, annotations map[string]string) bool {
+	for _, f := range filters {
+		if f.Matches(annotations) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnnotationFilter(t *testing.T) {
+	cases := map[string]struct {
+		raw       string
+		expFilter AnnotationFilter
+		expErr    string
+	}{
+		"key only": {
+			raw:       "example.com/sync",
+			expFilter: AnnotationFilter{Key: "example.com/sync"},
+		},
+		"key and value": {
+			raw:       "example.com/team=payments",
+			expFilter: AnnotationFilter{Key: "example.com/team", Value: "payments"},
+		},
+		"whitespace is trimmed": {
+			raw:       " example.com/team = payments ",
+			expFilter: AnnotationFilter{Key: "example.com/team", Value: "payments"},
+		},
+		"value containing '='": {
+			raw:       "example.com/query=a=b",
+			expFilter: AnnotationFilter{Key: "example.com/query", Value: "a=b"},
+		},
+		"empty": {
+			raw:    "",
+			expErr: `annotation filter "" is missing an annotation key`,
+		},
+		"missing key": {
+			raw:    "=payments",
+			expErr: `annotation filter "=payments" is missing an annotation key`,
+		},
+		"missing value": {
+			raw:    "example.com/team=",
+			expErr: `annotation filter "example.com/team=" is missing a value after '='`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			filter, err := ParseAnnotationFilter(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expFilter, filter)
+		})
+	}
+}
+
+func TestAnnotationFilter_Matches(t *testing.T) {
+	annotations := map[string]string{
+		"example.com/team": "payments",
+		"example.com/sync": "",
+	}
+	cases := map[string]struct {
+		filter   AnnotationFilter
+		expMatch bool
+	}{
+		"key present": {
+			filter:   AnnotationFilter{Key: "example.com/sync"},
+			expMatch: true,
+		},
+		"key missing": {
+			filter:   AnnotationFilter{Key: "example.com/other"},
+			expMatch: false,
+		},
+		"value matches": {
+			filter:   AnnotationFilter{Key: "example.com/team", Value: "payments"},
+			expMatch: true,
+		},
+		"value does not match": {
+			filter:   AnnotationFilter{Key: "example.com/team", Value: "billing"},
+			expMatch: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expMatch, c.filter.Matches(annotations))
+		})
+	}
+}
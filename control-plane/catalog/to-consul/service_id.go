@@ -15,3 +15,13 @@ func serviceID(name, addr string) string {
 	sum := sha1.Sum([]byte(fmt.Sprintf("%s-%s", name, addr)))
 	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:])[:12])
 }
+
+// instanceID generates the ID of the service instance with the given
+// address. If ClusterName is set, it is part of the ID so that instances
+// synced from clusters with overlapping addresses don't conflict.
+func (t *ServiceResource) instanceID(name, addr string) string {
+	if t.ClusterName != "" {
+		addr = fmt.Sprintf("%s-%s", t.ClusterName, addr)
+	}
+	return serviceID(name, addr)
+}
@@ -2,14 +2,17 @@ package catalog
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	mapset "github.com/deckarep/golang-set"
-	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
 )
 
 const (
@@ -20,6 +23,10 @@ const (
 	// ConsulServicePollPeriod is how often a service is checked for
 	// whether it has instances to reap.
 	ConsulServicePollPeriod = 60 * time.Second
+
+	// DefaultConsulWriteBatchSize is the default maximum number of service
+	// instances that are registered or deregistered in a single write.
+	DefaultConsulWriteBatchSize = 32
 )
 
 // Syncer is responsible for syncing a set of Consul catalog registrations.
@@ -45,6 +52,12 @@ type ConsulSyncer struct {
 	// k8s namespaces.
 	EnableNamespaces bool
 
+	// ConsulPartitions are the Consul admin partitions that services are
+	// registered into. The services on the sync nodes of each partition are
+	// watched so that they can be reaped. If empty, services are registered
+	// into the partition of the client, or admin partitions aren't enabled.
+	ConsulPartitions []string
+
 	// CrossNamespaceACLPolicy is the name of the ACL policy to attach to
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
@@ -56,23 +69,67 @@ type ConsulSyncer struct {
 	//
 	// ServicePollPeriod is the interval to look for invalid services to
 	// deregister. One request will be made for each synced service in
-	// Kubernetes.
+	// Kubernetes. It is also how long the result of checking for a Consul
+	// service that conflicts with a synced service is cached.
 	//
 	// For both syncs, smaller more frequent and focused syncs may be
 	// triggered by known drift or changes.
 	SyncPeriod        time.Duration
 	ServicePollPeriod time.Duration
 
+	// WriteBatchSize is the maximum number of service instances that are
+	// registered or deregistered in a single write to Consul. The writes to
+	// the nodes that are known to exist are made in transactions, which
+	// have up to two operations per service instance and are split so that
+	// they don't exceed the maximum number of operations of a Consul
+	// transaction. Defaults to DefaultConsulWriteBatchSize.
+	WriteBatchSize int
+
+	// WriteRateLimit is the maximum number of writes per second made to
+	// Consul. Changes are queued and written in batches of up to
+	// WriteBatchSize, so repeated changes to a service instance while it's
+	// queued are written once. If zero, writes aren't rate limited.
+	WriteRateLimit rate.Limit
+
 	// ConsulK8STag is the tag value for services registered.
 	ConsulK8STag string
 
 	// The Consul node name to register services with.
 	ConsulNodeName string
 
+	// The Consul node name to register ExternalName services with. If set,
+	// services on this node are reaped in the same way as services on
+	// ConsulNodeName.
+	ConsulExternalNodeName string
+
+	// ClusterName is the name of the Kubernetes cluster that services are
+	// synced from. If set, only service instances with the same cluster name
+	// in their meta are deregistered, so that syncers of different clusters
+	// can sync services into the same Consul datacenter without deleting
+	// each other's service instances.
+	ClusterName string
+
+	// AdoptUnownedServices set to true (default false) makes a syncer with
+	// a ClusterName also deregister the service instances that don't have a
+	// cluster name in their meta. This migrates the instances synced before
+	// ClusterName was set, which are replaced by instances with the cluster
+	// name. It must only be set in the cluster that synced those instances.
+	AdoptUnownedServices bool
+
+	// LegacyConsulNodeNames are the Consul node names that services were
+	// registered with before ClusterName was set. If AdoptUnownedServices
+	// is true, services on these nodes are reaped in the same way as
+	// services on ConsulNodeName.
+	LegacyConsulNodeNames []string
+
 	// ConsulNodeServicesClient is used to list services for a node. We use a
 	// separate client for this API call that handles older version of Consul.
 	ConsulNodeServicesClient ConsulNodeServicesClient
 
+	// Metrics are updated with the state of the syncer. If nil, the
+	// metrics are kept but not exported.
+	Metrics *metrics.Metrics
+
 	lock sync.Mutex
 	once sync.Once
 
@@ -86,32 +143,75 @@ type ConsulSyncer struct {
 
 	// serviceNames is all namespaces mapped to a set of valid
 	// Consul service names
-	serviceNames map[string]mapset.Set
+	serviceNames map[consulNamespace]mapset.Set
 
 	// namespaces is all namespaces mapped to a map of Consul service
 	// ids mapped to their CatalogRegistrations
-	namespaces map[string]map[string]*api.CatalogRegistration
+	namespaces map[consulNamespace]map[string]*api.CatalogRegistration
 	deregs     map[string]*api.CatalogDeregistration
 
 	// watchers is all namespaces mapped to a map of Consul service
 	// names mapped to a cancel function for watcher routines
-	watchers map[string]map[string]context.CancelFunc
+	watchers map[consulNamespace]map[string]context.CancelFunc
+
+	// conflicts is the "<partition>/<namespace>/<service name>" of services
+	// whose conflict policy yields to Consul mapped to the result of the
+	// check for a conflicting Consul service
+	conflicts map[string]*serviceConflict
+
+	// writeQueue holds the syncWrites to make. Failed writes are retried
+	// with an exponential backoff.
+	writeQueue workqueue.RateLimitingInterface
+
+	// writeLimiter limits the rate of writes to WriteRateLimit.
+	writeLimiter *rate.Limiter
+
+	// registeredNodes is the "<partition>/<node>" of the nodes that the
+	// syncer has registered, which service instances can be registered to
+	// in a transaction.
+	registeredNodes map[string]bool
+
+	// ensuredNamespaces is the Consul namespaces created since the last
+	// full sync.
+	ensuredNamespaces map[consulNamespace]bool
+
+	// drift is the writes of the changes that were detected outside of a
+	// full sync and haven't been written yet.
+	drift map[syncWrite]bool
+
+	// failingWrites is the writes that failed and wait to be retried.
+	failingWrites map[syncWrite]bool
+
+	// fullSyncPending is true if the writes queued by the last full sync
+	// haven't all been written yet.
+	fullSyncPending bool
+}
+
+// consulNamespace identifies a Consul namespace in an admin partition.
+// Partition is empty if services are registered into the partition of the
+// client, and Namespace is empty if namespaces aren't enabled.
+type consulNamespace struct {
+	Partition string
+	Namespace string
 }
 
 // Sync implements Syncer.
 func (s *ConsulSyncer) Sync(rs []*api.CatalogRegistration) {
+	s.once.Do(s.init)
+
 	// Grab the lock so we can replace the sync state
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	s.serviceNames = make(map[string]mapset.Set)
-	s.namespaces = make(map[string]map[string]*api.CatalogRegistration)
+	previous := s.namespaces
+	s.serviceNames = make(map[consulNamespace]mapset.Set)
+	s.namespaces = make(map[consulNamespace]map[string]*api.CatalogRegistration)
 
 	for _, r := range rs {
 		// Determine the namespace the service is in to use for indexing
 		// against the s.serviceNames and s.namespaces maps.
-		// This will be "" for OSS.
-		ns := r.Service.Namespace
+		// This will be empty for OSS.
+		ns := consulNamespace{Partition: r.Partition, Namespace: r.Service.Namespace}
 
 		// Mark this as a valid service, initializing state if necessary
 		if _, ok := s.serviceNames[ns]; !ok {
@@ -126,7 +226,14 @@ func (s *ConsulSyncer) Sync(rs []*api.CatalogRegistration) {
 		}
 		s.namespaces[ns][r.Service.ID] = r
 		s.Log.Debug("[Sync] adding service to namespaces map", "service", r.Service)
+
+		// Write the registrations that changed without waiting for the
+		// next full sync.
+		if !reflect.DeepEqual(previous[ns][r.Service.ID], r) {
+			s.enqueueDriftLocked(syncWrite{Namespace: ns, ServiceID: r.Service.ID})
+		}
 	}
+	s.Metrics.Registrations.WithLabelValues(metrics.DirectionToConsul).Set(float64(len(rs)))
 
 	// Signal that the initial sync is complete and our maps have been populated.
 	// We can now safely reap untracked services.
@@ -139,7 +246,32 @@ func (s *ConsulSyncer) Run(ctx context.Context) {
 	s.once.Do(s.init)
 
 	// Start the background watchers
-	go s.watchReapableServices(ctx)
+	partitions := s.ConsulPartitions
+	if len(partitions) == 0 {
+		partitions = []string{""}
+	}
+	for _, partition := range partitions {
+		go s.watchReapableServices(ctx, s.ConsulNodeName, partition)
+		if s.ConsulExternalNodeName != "" {
+			go s.watchReapableServices(ctx, s.ConsulExternalNodeName, partition)
+		}
+		if s.ClusterName != "" && s.AdoptUnownedServices {
+			for _, nodeName := range s.LegacyConsulNodeNames {
+				go s.watchReapableServices(ctx, nodeName, partition)
+			}
+		}
+	}
+
+	// Start the writer, and stop it when we're done
+	writesDone := make(chan struct{})
+	go func() {
+		defer close(writesDone)
+		s.processWrites(ctx)
+	}()
+	defer func() {
+		s.writeQueue.ShutDown()
+		<-writesDone
+	}()
 
 	reconcileTimer := time.NewTimer(s.SyncPeriod)
 	defer reconcileTimer.Stop()
@@ -159,10 +291,10 @@ func (s *ConsulSyncer) Run(ctx context.Context) {
 
 // watchReapableServices is a long-running task started by Run that
 // holds blocking queries to the Consul server to watch for any services
-// tagged with k8s that are no longer valid and need to be deleted.
-// This task only marks them for deletion but doesn't perform the actual
-// deletion.
-func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
+// on nodeName in partition tagged with k8s that are no longer valid and need
+// to be deleted. This task only marks them for deletion but doesn't perform
+// the actual deletion.
+func (s *ConsulSyncer) watchReapableServices(ctx context.Context, nodeName, partition string) {
 	// We must wait for the initial sync to be complete and our maps to be
 	// populated. If we don't wait, we will reap all services tagged with k8s
 	// because we have no tracked services in our maps yet.
@@ -172,6 +304,7 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 		AllowStale: true,
 		WaitIndex:  1,
 		WaitTime:   1 * time.Minute,
+		Partition:  partition,
 	}
 
 	if s.EnableNamespaces {
@@ -187,14 +320,16 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 		var meta *api.QueryMeta
 		err := backoff.Retry(func() error {
 			var err error
-			services, meta, err = s.ConsulNodeServicesClient.NodeServices(s.ConsulK8STag, s.ConsulNodeName, *opts)
+			services, meta, err = s.ConsulNodeServicesClient.NodeServices(s.ConsulK8STag, nodeName, *opts)
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 
 		if err != nil {
 			s.Log.Warn("error querying services, will retry", "err", err)
+			s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "read").Inc()
 		} else {
 			s.Log.Debug("[watchReapableServices] services returned from catalog",
+				"node-name", nodeName,
 				"services", services)
 		}
 
@@ -218,11 +353,13 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 
 		// Go through the service array and find services that should be reaped
 		for _, service := range services {
+			ns := consulNamespace{Partition: partition, Namespace: service.Namespace}
+
 			// Check that the namespace exists in the valid service names map
 			// before checking whether it contains the service
-			if _, ok := s.serviceNames[service.Namespace]; ok {
+			if _, ok := s.serviceNames[ns]; ok {
 				// We only care if we don't know about this service at all.
-				if s.serviceNames[service.Namespace].Contains(service.Name) {
+				if s.serviceNames[ns].Contains(service.Name) {
 					s.Log.Debug("[watchReapableServices] serviceNames contains service",
 						"namespace", service.Namespace,
 						"service-name", service.Name)
@@ -232,7 +369,8 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 
 			s.Log.Info("invalid service found, scheduling for delete",
 				"service-name", service.Name, "service-consul-namespace", service.Namespace)
-			if err := s.scheduleReapServiceLocked(service.Name, service.Namespace); err != nil {
+			if err := s.scheduleReapServiceLocked(service.Name, ns); err != nil {
+				s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "read").Inc()
 				s.Log.Info("error querying service for delete",
 					"service-name", service.Name,
 					"service-consul-namespace", service.Namespace,
@@ -246,7 +384,8 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 
 // watchService watches all instances of a service by name for changes
 // and schedules re-registration or deletion if necessary.
-func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string) {
+func (s *ConsulSyncer) watchService(ctx context.Context, name string, ns consulNamespace) {
+	namespace := ns.Namespace
 	s.Log.Info("starting service watcher", "service-name", name, "service-consul-namespace", namespace)
 	defer s.Log.Info("stopping service watcher", "service-name", name, "service-consul-namespace", namespace)
 
@@ -263,6 +402,7 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		// Set up query options
 		queryOpts := &api.QueryOptions{
 			AllowStale: true,
+			Partition:  ns.Partition,
 		}
 		if s.EnableNamespaces {
 			// Sets the Consul namespace to query the catalog
@@ -281,6 +421,7 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 				"service-name", name,
 				"service-namespace", namespace, // will be "" if namespaces aren't enabled
 				"err", err)
+			s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "read").Inc()
 			continue
 		}
 
@@ -288,10 +429,15 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		s.lock.Lock()
 
 		for _, svc := range services {
+			// Leave the service instances synced from other clusters alone
+			if !s.ownsService(svc) {
+				continue
+			}
+
 			// Make sure the namespace exists before we run checks against it
-			if _, ok := s.serviceNames[namespace]; ok {
+			if _, ok := s.serviceNames[ns]; ok {
 				// If the service is valid and its info isn't nil, we don't deregister it
-				if s.serviceNames[namespace].Contains(svc.ServiceName) && s.namespaces[namespace][svc.ServiceID] != nil {
+				if s.serviceNames[ns].Contains(svc.ServiceName) && s.namespaces[ns][svc.ServiceID] != nil {
 					continue
 				}
 			}
@@ -299,10 +445,12 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 			s.deregs[svc.ServiceID] = &api.CatalogDeregistration{
 				Node:      svc.Node,
 				ServiceID: svc.ServiceID,
+				Partition: ns.Partition,
 			}
 			if s.EnableNamespaces {
 				s.deregs[svc.ServiceID].Namespace = namespace
 			}
+			s.enqueueDriftLocked(syncWrite{Deregister: true, ServiceID: svc.ServiceID})
 			s.Log.Debug("[watchService] service being scheduled for deregistration",
 				"namespace", namespace,
 				"service name", svc.ServiceName,
@@ -318,9 +466,11 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 // name that have the k8s tag and schedules them for removal.
 //
 // Precondition: lock must be held.
-func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
+func (s *ConsulSyncer) scheduleReapServiceLocked(name string, ns consulNamespace) error {
+	namespace := ns.Namespace
+
 	// Set up query options
-	opts := api.QueryOptions{AllowStale: true}
+	opts := api.QueryOptions{AllowStale: true, Partition: ns.Partition}
 	if s.EnableNamespaces {
 		opts.Namespace = namespace
 	}
@@ -333,13 +483,19 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 
 	// Create deregistrations for all of these
 	for _, svc := range services {
+		if !s.ownsService(svc) {
+			continue
+		}
+
 		s.deregs[svc.ServiceID] = &api.CatalogDeregistration{
 			Node:      svc.Node,
 			ServiceID: svc.ServiceID,
+			Partition: ns.Partition,
 		}
 		if s.EnableNamespaces {
 			s.deregs[svc.ServiceID].Namespace = namespace
 		}
+		s.enqueueDriftLocked(syncWrite{Deregister: true, ServiceID: svc.ServiceID})
 		s.Log.Debug("[scheduleReapServiceLocked] service being scheduled for deregistration",
 			"namespace", namespace,
 			"service name", svc.ServiceName,
@@ -350,9 +506,19 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 	return nil
 }
 
-// syncFull is called periodically to perform all the write-based API
-// calls to sync the data with Consul. This may also start background
-// watchers for specific services.
+// ownsService returns true if the service instance was synced by this
+// syncer's cluster, or is adopted by it, and may be deregistered by it.
+func (s *ConsulSyncer) ownsService(svc *api.CatalogService) bool {
+	if s.ClusterName == "" {
+		return true
+	}
+	cluster := svc.ServiceMeta[ConsulK8SCluster]
+	return cluster == s.ClusterName || (cluster == "" && s.AdoptUnownedServices)
+}
+
+// syncFull is called periodically to queue all the writes that sync the
+// data with Consul. The writes are made by processWrites. This may also
+// start background watchers for specific services.
 func (s *ConsulSyncer) syncFull(ctx context.Context) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -391,57 +557,202 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 		}
 	}
 
-	// Do all deregistrations first
-	for _, r := range s.deregs {
-		s.Log.Info("deregistering service",
-			"node-name", r.Node,
-			"service-id", r.ServiceID,
-			"service-consul-namespace", r.Namespace)
-		_, err := s.Client.Catalog().Deregister(r, nil)
+	// Queue all deregistrations. Failed deregistrations stay in deregs
+	// until they are retried.
+	for id := range s.deregs {
+		s.enqueueWriteLocked(syncWrite{Deregister: true, ServiceID: id})
+	}
+
+	// Queue all the registrations. This will overwrite any changes that
+	// may have been made to the registered services. Registrations that
+	// yield to a conflicting Consul service are skipped when they're
+	// written.
+	s.expireConflictsLocked()
+	s.ensuredNamespaces = make(map[consulNamespace]bool)
+	for ns, services := range s.namespaces {
+		for id := range services {
+			s.enqueueWriteLocked(syncWrite{Namespace: ns, ServiceID: id})
+		}
+	}
+	s.fullSyncPending = true
+	s.updateWriteMetricsLocked()
+}
+
+// yieldsToConsulServiceLocked returns true if the registration must not be
+// registered because its conflict policy yields to a Consul service of the
+// same name that has instances that were not registered from Kubernetes, or
+// because that hasn't been checked yet. Instances of such registrations that
+// were registered before the conflict are scheduled for deregistration. It
+// only uses the results of the conflict checks made by checkConflicts.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) yieldsToConsulServiceLocked(r *api.CatalogRegistration) bool {
+	policy := ConflictPolicy(r.Service.Meta[ConsulK8SConflictPolicy])
+	if !policy.yieldsToConsul() {
+		return false
+	}
+
+	conflict, ok := s.conflicts[conflictKey(r)]
+	if !ok {
+		return true
+	}
+	if !conflict.conflict {
+		return false
+	}
+
+	if policy == ConflictPolicyError {
+		s.Log.Error("service conflicts with a Consul service of the same name, not registering",
+			"service-name", r.Service.Service,
+			"service-consul-namespace", r.Service.Namespace,
+			"conflict-policy", policy)
+	} else {
+		s.Log.Debug("[yieldsToConsulServiceLocked] Consul service of the same name exists, not registering",
+			"service-name", r.Service.Service,
+			"service-consul-namespace", r.Service.Namespace,
+			"conflict-policy", policy)
+	}
+
+	// Remove the instance if it was registered before the conflict. Failed
+	// deregistrations stay in deregs until they are retried.
+	instance := r.Node + "/" + r.Service.ID
+	if !conflict.syncedInstances[instance] {
+		return true
+	}
+	s.deregs[r.Service.ID] = &api.CatalogDeregistration{
+		Node:      r.Node,
+		ServiceID: r.Service.ID,
+		Partition: r.Partition,
+	}
+	if s.EnableNamespaces {
+		s.deregs[r.Service.ID].Namespace = r.Service.Namespace
+	}
+	s.enqueueDriftLocked(syncWrite{Deregister: true, ServiceID: r.Service.ID})
+	s.Log.Info("service conflicts with a Consul service, scheduling for deregistration",
+		"node-name", r.Node,
+		"service-id", r.Service.ID,
+		"service-consul-namespace", r.Service.Namespace)
+	delete(conflict.syncedInstances, instance)
+	return true
+}
+
+// checkConflicts checks for Consul services that conflict with the
+// registrations of the batch whose conflict policy yields to Consul and
+// caches the results for the service poll period, so that the checks aren't
+// repeated on every write. The checks are made without holding the lock so
+// that Sync isn't blocked by them. It returns the writes whose check failed.
+func (s *ConsulSyncer) checkConflicts(batch []syncWrite) map[syncWrite]bool {
+	type check struct {
+		name   string
+		ns     consulNamespace
+		writes []syncWrite
+	}
+	checks := make(map[string]*check)
+	s.lock.Lock()
+	for _, w := range batch {
+		if w.Deregister {
+			continue
+		}
+		r, ok := s.namespaces[w.Namespace][w.ServiceID]
+		if !ok || !ConflictPolicy(r.Service.Meta[ConsulK8SConflictPolicy]).yieldsToConsul() {
+			continue
+		}
+		key := conflictKey(r)
+		if _, ok := s.conflicts[key]; ok {
+			continue
+		}
+		if checks[key] == nil {
+			checks[key] = &check{
+				name: r.Service.Service,
+				ns:   consulNamespace{Partition: r.Partition, Namespace: r.Service.Namespace},
+			}
+		}
+		checks[key].writes = append(checks[key].writes, w)
+	}
+	s.lock.Unlock()
+
+	failed := make(map[syncWrite]bool)
+	for key, c := range checks {
+		conflict, err := s.checkConflict(c.name, c.ns)
 		if err != nil {
-			s.Log.Warn("error deregistering service",
-				"node-name", r.Node,
-				"service-id", r.ServiceID,
-				"service-consul-namespace", r.Namespace,
+			s.Log.Warn("error checking for conflicting Consul service, will retry",
+				"service-name", c.name,
+				"service-consul-namespace", c.ns.Namespace,
 				"err", err)
+			s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "read").Inc()
+			for _, w := range c.writes {
+				failed[w] = true
+			}
+			continue
 		}
+		s.lock.Lock()
+		s.conflicts[key] = conflict
+		s.lock.Unlock()
 	}
+	return failed
+}
 
-	// Always clear deregistrations, they'll repopulate if we had errors
-	s.deregs = make(map[string]*api.CatalogDeregistration)
+// conflictKey returns the key of the registration's service in the
+// conflicts map.
+func conflictKey(r *api.CatalogRegistration) string {
+	return r.Partition + "/" + r.Service.Namespace + "/" + r.Service.Service
+}
 
-	// Register all the services. This will overwrite any changes that
-	// may have been made to the registered services.
-	for _, services := range s.namespaces {
-		for _, r := range services {
-			if s.EnableNamespaces {
-				_, err := namespaces.EnsureExists(s.Client, r.Service.Namespace, s.CrossNamespaceACLPolicy)
-				if err != nil {
-					s.Log.Warn("error checking and creating Consul namespace",
-						"node-name", r.Node,
-						"service-name", r.Service.Service,
-						"consul-namespace-name", r.Service.Namespace,
-						"err", err)
-					continue
-				}
-			}
+// serviceConflict is the result of checking whether a service that is synced
+// from Kubernetes conflicts with a Consul service of the same name.
+type serviceConflict struct {
+	// conflict is true if the service has instances in Consul that were not
+	// registered from Kubernetes.
+	conflict bool
 
-			// Register the service
-			_, err := s.Client.Catalog().Register(r, nil)
-			if err != nil {
-				s.Log.Warn("error registering service",
-					"node-name", r.Node,
-					"service-name", r.Service.Service,
-					"service", r.Service,
-					"err", err)
-				continue
+	// syncedInstances is the set of "<node>/<service id>" of the instances
+	// of the service that were registered from Kubernetes.
+	syncedInstances map[string]bool
+
+	// checkedAt is when the check was made.
+	checkedAt time.Time
+}
+
+// checkConflict checks whether the service with the given name has instances
+// in Consul that were not registered from Kubernetes.
+func (s *ConsulSyncer) checkConflict(name string, ns consulNamespace) (*serviceConflict, error) {
+	opts := &api.QueryOptions{AllowStale: true, Partition: ns.Partition}
+	if s.EnableNamespaces {
+		opts.Namespace = ns.Namespace
+	}
+
+	services, _, err := s.Client.Catalog().Service(name, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	conflict := &serviceConflict{
+		syncedInstances: make(map[string]bool),
+		checkedAt:       time.Now(),
+	}
+	for _, svc := range services {
+		k8s := false
+		for _, tag := range svc.ServiceTags {
+			if tag == s.ConsulK8STag {
+				k8s = true
+				break
 			}
+		}
+		if k8s {
+			conflict.syncedInstances[svc.Node+"/"+svc.ServiceID] = true
+		} else {
+			conflict.conflict = true
+		}
+	}
+	return conflict, nil
+}
 
-			s.Log.Debug("registered service instance",
-				"node-name", r.Node,
-				"service-name", r.Service.Service,
-				"consul-namespace-name", r.Service.Namespace,
-				"service", r.Service)
+// expireConflictsLocked removes the results of the conflict checks that are
+// older than the service poll period so that they're checked again.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) expireConflictsLocked() {
+	for key, conflict := range s.conflicts {
+		if time.Since(conflict.checkedAt) >= s.ServicePollPeriod {
+			delete(s.conflicts, key)
 		}
 	}
 }
@@ -450,16 +761,44 @@ func (s *ConsulSyncer) init() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	if s.serviceNames == nil {
-		s.serviceNames = make(map[string]mapset.Set)
+		s.serviceNames = make(map[consulNamespace]mapset.Set)
 	}
 	if s.namespaces == nil {
-		s.namespaces = make(map[string]map[string]*api.CatalogRegistration)
+		s.namespaces = make(map[consulNamespace]map[string]*api.CatalogRegistration)
 	}
 	if s.deregs == nil {
 		s.deregs = make(map[string]*api.CatalogDeregistration)
 	}
 	if s.watchers == nil {
-		s.watchers = make(map[string]map[string]context.CancelFunc)
+		s.watchers = make(map[consulNamespace]map[string]context.CancelFunc)
+	}
+	if s.conflicts == nil {
+		s.conflicts = make(map[string]*serviceConflict)
+	}
+	if s.registeredNodes == nil {
+		s.registeredNodes = make(map[string]bool)
+	}
+	if s.ensuredNamespaces == nil {
+		s.ensuredNamespaces = make(map[consulNamespace]bool)
+	}
+	if s.drift == nil {
+		s.drift = make(map[syncWrite]bool)
+	}
+	if s.failingWrites == nil {
+		s.failingWrites = make(map[syncWrite]bool)
+	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.New()
+	}
+	if s.WriteBatchSize <= 0 {
+		s.WriteBatchSize = DefaultConsulWriteBatchSize
+	}
+	if s.writeLimiter == nil {
+		limit := s.WriteRateLimit
+		if limit <= 0 {
+			limit = rate.Inf
+		}
+		s.writeLimiter = rate.NewLimiter(limit, 1)
 	}
 	if s.SyncPeriod == 0 {
 		s.SyncPeriod = ConsulSyncPeriod
@@ -470,4 +809,8 @@ func (s *ConsulSyncer) init() {
 	if s.initialSync == nil {
 		s.initialSync = make(chan bool)
 	}
+	if s.writeQueue == nil {
+		s.writeQueue = workqueue.NewRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(time.Second, s.SyncPeriod))
+	}
 }
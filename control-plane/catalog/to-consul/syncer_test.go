@@ -2,9 +2,11 @@ package catalog
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -264,6 +266,374 @@ func TestConsulSyncer_stopsGracefully(t *testing.T) {
 	require.LessOrEqual(t, callCount-beforeStopAPICount, 2)
 }
 
+// Test that the syncer doesn't register services that conflict with Consul
+// services when their conflict policy yields to Consul.
+func TestConsulSyncer_conflictPolicy(t *testing.T) {
+	t.Parallel()
+
+	cases := map[ConflictPolicy]bool{
+		ConflictPolicyK8SWins:    true,
+		ConflictPolicyMerge:      true,
+		ConflictPolicyConsulWins: false,
+		ConflictPolicyError:      false,
+	}
+
+	for policy, expRegistered := range cases {
+		t.Run(string(policy), func(t *testing.T) {
+			require := require.New(t)
+
+			// Set up server, client, syncer
+			a, err := testutil.NewTestServerConfigT(t, nil)
+			require.NoError(err)
+			defer a.Stop()
+
+			client, err := api.NewClient(&api.Config{
+				Address: a.HTTPAddr,
+			})
+			require.NoError(err)
+
+			// Register a Consul service that isn't synced from k8s.
+			_, err = client.Catalog().Register(&api.CatalogRegistration{
+				Node:    "external",
+				Address: "127.0.0.2",
+				Service: &api.AgentService{
+					ID:      "bar-external",
+					Service: "bar",
+				},
+			}, nil)
+			require.NoError(err)
+
+			s, closer := testConsulSyncer(client)
+			defer closer()
+
+			// Sync
+			reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+			reg.Service.Meta[ConsulK8SConflictPolicy] = string(policy)
+			s.Sync([]*api.CatalogRegistration{reg})
+
+			if expRegistered {
+				retry.Run(t, func(r *retry.R) {
+					services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+					if err != nil {
+						r.Fatalf("err: %s", err)
+					}
+					if len(services) != 1 {
+						r.Fatal("service not found")
+					}
+				})
+				return
+			}
+
+			// Wait for a few sync periods and verify the service was never registered.
+			time.Sleep(3 * s.SyncPeriod)
+			services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+			require.NoError(err)
+			require.Len(services, 0)
+		})
+	}
+}
+
+// Test that syncers of different clusters that sync the same service
+// to the same node don't deregister each other's service instances.
+func TestConsulSyncer_clusterName(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Set up server, client, syncers
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(err)
+	defer a.Stop()
+
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(err)
+
+	sA, closerA := testConsulSyncerWithConfig(client, func(s *ConsulSyncer) { s.ClusterName = "cluster-a" })
+	defer closerA()
+	sB, closerB := testConsulSyncerWithConfig(client, func(s *ConsulSyncer) { s.ClusterName = "cluster-b" })
+	defer closerB()
+
+	// Sync the same service from both clusters, and a service only
+	// from the first cluster
+	regA := testRegistration(ConsulSyncNodeName, "bar", "default")
+	regA.Service.ID = serviceID("bar", "cluster-a-1.1.1.1")
+	regA.Service.Meta[ConsulK8SCluster] = "cluster-a"
+	regAOnly := testRegistration(ConsulSyncNodeName, "baz", "default")
+	regAOnly.Service.Meta[ConsulK8SCluster] = "cluster-a"
+	sA.Sync([]*api.CatalogRegistration{regA, regAOnly})
+
+	regB := testRegistration(ConsulSyncNodeName, "bar", "default")
+	regB.Service.ID = serviceID("bar", "cluster-b-1.1.1.1")
+	regB.Service.Meta[ConsulK8SCluster] = "cluster-b"
+	sB.Sync([]*api.CatalogRegistration{regB})
+
+	// Wait for the services
+	retry.Run(t, func(r *retry.R) {
+		services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(services) != 2 {
+			r.Fatal("service instances not found")
+		}
+		services, _, err = client.Catalog().Service("baz", TestConsulK8STag, nil)
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(services) != 1 {
+			r.Fatal("service not found")
+		}
+	})
+
+	// Wait for a few sync periods and verify that no instances were deregistered.
+	time.Sleep(3 * sA.SyncPeriod)
+	services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+	require.NoError(err)
+	require.Len(services, 2)
+	services, _, err = client.Catalog().Service("baz", TestConsulK8STag, nil)
+	require.NoError(err)
+	require.Len(services, 1)
+
+	// Stop syncing the service from the first cluster and verify that only
+	// its instance is deregistered.
+	sA.Sync([]*api.CatalogRegistration{regAOnly})
+	retry.Run(t, func(r *retry.R) {
+		services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(services) != 1 {
+			r.Fatal("service instance not deregistered")
+		}
+		if services[0].ServiceID != regB.Service.ID {
+			r.Fatalf("unexpected service instance %s", services[0].ServiceID)
+		}
+	})
+}
+
+// Test that the syncer only deregisters the instances of services that yield
+// to a conflicting Consul service if they are registered, that it caches
+// the result of the conflict check between syncs, and that the checks don't
+// block Sync.
+func TestConsulSyncer_conflictPolicyChecks(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		registered      bool
+		pollPeriod      time.Duration
+		expChecks       int
+		expDeregistered int
+	}{
+		"instance not registered": {
+			registered:      false,
+			pollPeriod:      time.Hour,
+			expChecks:       1,
+			expDeregistered: 0,
+		},
+		"instance registered": {
+			registered:      true,
+			pollPeriod:      time.Hour,
+			expChecks:       1,
+			expDeregistered: 1,
+		},
+		"check expired": {
+			registered:      false,
+			pollPeriod:      time.Nanosecond,
+			expChecks:       3,
+			expDeregistered: 0,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+			reg.Service.Meta[ConsulK8SConflictPolicy] = string(ConflictPolicyConsulWins)
+
+			// We use a test http server here so we can count the number of calls.
+			var lock sync.Mutex
+			var s *ConsulSyncer
+			checks, deregistered := 0, 0
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lock.Lock()
+				defer lock.Unlock()
+				switch r.URL.Path {
+				case "/v1/catalog/service/bar":
+					checks++
+					synced := make(chan struct{})
+					go func() {
+						s.Sync([]*api.CatalogRegistration{reg})
+						close(synced)
+					}()
+					select {
+					case <-synced:
+					case <-time.After(5 * time.Second):
+						t.Error("Sync is blocked by the conflict check")
+					}
+					services := []*api.CatalogService{
+						{Node: "external", ServiceID: "bar-external", ServiceName: "bar"},
+					}
+					if c.registered && deregistered == 0 {
+						services = append(services, &api.CatalogService{
+							Node:        reg.Node,
+							ServiceID:   reg.Service.ID,
+							ServiceName: "bar",
+							ServiceTags: []string{TestConsulK8STag},
+						})
+					}
+					json.NewEncoder(w).Encode(services)
+				case "/v1/txn":
+					var ops api.TxnOps
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&ops))
+					for _, op := range ops {
+						if op.Service == nil || op.Service.Verb != api.ServiceDelete {
+							t.Errorf("service should not be registered")
+							continue
+						}
+						deregistered++
+					}
+					json.NewEncoder(w).Encode(api.TxnResponse{})
+				case "/v1/catalog/register":
+					t.Errorf("service should not be registered")
+				}
+			}))
+			defer consulServer.Close()
+
+			client, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+			s = &ConsulSyncer{
+				Client:            client,
+				Log:               hclog.Default(),
+				SyncPeriod:        time.Hour,
+				ServicePollPeriod: c.pollPeriod,
+				ConsulK8STag:      TestConsulK8STag,
+				ConsulNodeName:    ConsulSyncNodeName,
+			}
+			s.init()
+			s.Sync([]*api.CatalogRegistration{reg})
+			// The node exists, so that deregistrations are written in a transaction.
+			s.registeredNodes[nodeKey("", ConsulSyncNodeName)] = true
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for i := 0; i < 3; i++ {
+				s.syncFull(ctx)
+				writeQueued(s)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			require.Equal(t, c.expChecks, checks)
+			require.Equal(t, c.expDeregistered, deregistered)
+		})
+	}
+}
+
+// Test that a syncer that adopts unowned services replaces the service
+// instances synced before the cluster name was set, including the ones on
+// the legacy node name.
+func TestConsulSyncer_adoptUnownedServices(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Set up server, client
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(err)
+	defer a.Stop()
+
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(err)
+
+	// Register the service instances synced before the cluster name was set:
+	// one for a service that is still synced and one for a service that isn't.
+	legacyBar := testRegistration(ConsulSyncNodeName, "bar", "default")
+	_, err = client.Catalog().Register(legacyBar, nil)
+	require.NoError(err)
+	legacyBaz := testRegistration(ConsulSyncNodeName, "baz", "default")
+	_, err = client.Catalog().Register(legacyBaz, nil)
+	require.NoError(err)
+
+	s, closer := testConsulSyncerWithConfig(client, func(s *ConsulSyncer) {
+		s.ConsulNodeName = ConsulSyncNodeName + "-cluster-a"
+		s.ClusterName = "cluster-a"
+		s.AdoptUnownedServices = true
+		s.LegacyConsulNodeNames = []string{ConsulSyncNodeName}
+	})
+	defer closer()
+
+	reg := testRegistration(s.ConsulNodeName, "bar", "default")
+	reg.Service.Meta[ConsulK8SCluster] = "cluster-a"
+	s.Sync([]*api.CatalogRegistration{reg})
+
+	// Verify that only the instance with the cluster name is left
+	retry.Run(t, func(r *retry.R) {
+		services, _, err := client.Catalog().Service("bar", TestConsulK8STag, nil)
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(services) != 1 {
+			r.Fatal("legacy service instance not deregistered")
+		}
+		if services[0].ServiceID != reg.Service.ID {
+			r.Fatalf("unexpected service instance %s", services[0].ServiceID)
+		}
+		services, _, err = client.Catalog().Service("baz", TestConsulK8STag, nil)
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(services) != 0 {
+			r.Fatal("legacy service not deregistered")
+		}
+	})
+}
+
+func TestConsulSyncer_ownsService(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		clusterName string
+		adopt       bool
+		serviceMeta map[string]string
+		expOwned    bool
+	}{
+		"no cluster name": {
+			serviceMeta: map[string]string{ConsulK8SCluster: "cluster-b"},
+			expOwned:    true,
+		},
+		"same cluster": {
+			clusterName: "cluster-a",
+			serviceMeta: map[string]string{ConsulK8SCluster: "cluster-a"},
+			expOwned:    true,
+		},
+		"other cluster": {
+			clusterName: "cluster-a",
+			serviceMeta: map[string]string{ConsulK8SCluster: "cluster-b"},
+			expOwned:    false,
+		},
+		"other cluster when adopting": {
+			clusterName: "cluster-a",
+			adopt:       true,
+			serviceMeta: map[string]string{ConsulK8SCluster: "cluster-b"},
+			expOwned:    false,
+		},
+		"no cluster meta": {
+			clusterName: "cluster-a",
+			expOwned:    false,
+		},
+		"no cluster meta when adopting": {
+			clusterName: "cluster-a",
+			adopt:       true,
+			expOwned:    true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &ConsulSyncer{ClusterName: c.clusterName, AdoptUnownedServices: c.adopt}
+			require.Equal(t, c.expOwned, s.ownsService(&api.CatalogService{ServiceMeta: c.serviceMeta}))
+		})
+	}
+}
+
 func testRegistration(node, service, k8sSrcNamespace string) *api.CatalogRegistration {
 	return &api.CatalogRegistration{
 		Node:           node,
@@ -282,6 +652,18 @@ func testRegistration(node, service, k8sSrcNamespace string) *api.CatalogRegistr
 	}
 }
 
+// writeQueued writes the queued writes one at a time without running the
+// syncer, including the writes that are queued while writing.
+func writeQueued(s *ConsulSyncer) {
+	for s.writeQueue.Len() > 0 {
+		item, _ := s.writeQueue.Get()
+		w := item.(syncWrite)
+		s.writeBatch([]syncWrite{w})
+		s.writeQueue.Forget(w)
+		s.writeQueue.Done(w)
+	}
+}
+
 func testConsulSyncer(client *api.Client) (*ConsulSyncer, func()) {
 	return testConsulSyncerWithConfig(client, func(syncer *ConsulSyncer) {})
 }
@@ -0,0 +1,394 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+)
+
+// maxTxnOps is the maximum number of operations in a transaction. Consul
+// rejects transactions with more operations.
+const maxTxnOps = 128
+
+// syncWrite is an item of the write queue of the ConsulSyncer. It identifies
+// a service instance to register or deregister rather than holding the
+// registration itself so that the latest state is written when the item is
+// processed, and so that repeated changes to an instance are written once.
+type syncWrite struct {
+	// Deregister is true if the service instance is deregistered.
+	Deregister bool
+
+	// Namespace is the Consul namespace of the registration. It is not
+	// set for deregistrations since they are keyed by service ID only.
+	Namespace consulNamespace
+
+	// ServiceID is the ID of the service instance.
+	ServiceID string
+}
+
+// processWrites is a long-running task started by Run that writes the
+// registrations and deregistrations of the write queue to Consul in batches,
+// waiting for the write rate limiter before each batch. Failed writes are
+// retried with an exponential backoff.
+func (s *ConsulSyncer) processWrites(ctx context.Context) {
+	for {
+		item, quit := s.writeQueue.Get()
+		if quit {
+			return
+		}
+		batch := []syncWrite{item.(syncWrite)}
+		for len(batch) < s.WriteBatchSize && s.writeQueue.Len() > 0 {
+			item, quit := s.writeQueue.Get()
+			if quit {
+				break
+			}
+			batch = append(batch, item.(syncWrite))
+		}
+
+		if err := s.writeLimiter.Wait(ctx); err != nil {
+			// The context is done.
+			for _, w := range batch {
+				s.writeQueue.Done(w)
+			}
+			return
+		}
+
+		failed := s.writeBatch(batch)
+		s.lock.Lock()
+		for _, w := range batch {
+			if failed[w] {
+				s.failingWrites[w] = true
+				s.writeQueue.AddRateLimited(w)
+			} else {
+				delete(s.failingWrites, w)
+				delete(s.drift, w)
+				s.writeQueue.Forget(w)
+			}
+			s.writeQueue.Done(w)
+		}
+		s.updateWriteMetricsLocked()
+		s.lock.Unlock()
+	}
+}
+
+// enqueueDriftLocked adds the write of a change that was detected outside
+// of a full sync to the write queue, and tracks it as drift until it is
+// written.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) enqueueDriftLocked(w syncWrite) {
+	s.drift[w] = true
+	s.enqueueWriteLocked(w)
+	s.updateWriteMetricsLocked()
+}
+
+// updateWriteMetricsLocked updates the metrics of the write queue. The last
+// full sync is successful once all of its writes have been written.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) updateWriteMetricsLocked() {
+	s.Metrics.WriteQueueDepth.Set(float64(s.writeQueue.Len()))
+	s.Metrics.Drift.WithLabelValues(metrics.DirectionToConsul).Set(float64(len(s.drift)))
+	if s.fullSyncPending && s.writeQueue.Len() == 0 && len(s.failingWrites) == 0 {
+		s.fullSyncPending = false
+		s.Metrics.LastSuccessfulSync.WithLabelValues(metrics.DirectionToConsul).Set(float64(time.Now().Unix()))
+	}
+}
+
+// enqueueWriteLocked adds the write to the write queue unless it is waiting
+// to be retried after failing, in which case it is written when the backoff
+// expires.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) enqueueWriteLocked(w syncWrite) {
+	if s.writeQueue.NumRequeues(w) > 0 {
+		return
+	}
+	s.writeQueue.Add(w)
+}
+
+// writeBatch writes the batch to Consul and returns the writes that failed.
+// The deregistrations and the registrations on nodes that are known to
+// exist are written in transactions for each admin partition, of up to
+// maxTxnOps operations each. The other
+// registrations are written with their own request so that their node is
+// created.
+func (s *ConsulSyncer) writeBatch(batch []syncWrite) map[syncWrite]bool {
+	failed := s.checkConflicts(batch)
+
+	// Build the operations while holding the lock, but make the requests
+	// without it so that Sync isn't blocked by them.
+	s.lock.Lock()
+	txns := make(map[string]*writeTxn)
+	var nodeRegistrations []syncWrite
+	registrations := make(map[syncWrite]*api.CatalogRegistration)
+	deregistrations := make(map[syncWrite]*api.CatalogDeregistration)
+	for _, w := range batch {
+		if w.Deregister {
+			// The deregistration was already written if it's gone.
+			d, ok := s.deregs[w.ServiceID]
+			if !ok {
+				continue
+			}
+			deregistrations[w] = d
+			txn := txns[d.Partition]
+			if txn == nil {
+				txn = &writeTxn{}
+				txns[d.Partition] = txn
+			}
+			txn.add(w, &api.TxnOp{Service: &api.ServiceTxnOp{
+				Verb: api.ServiceDelete,
+				Node: d.Node,
+				Service: api.AgentService{
+					ID:        d.ServiceID,
+					Namespace: d.Namespace,
+					Partition: d.Partition,
+				},
+			}})
+			continue
+		}
+
+		// The service instance is no longer synced if it's gone, in which
+		// case it is deregistered by its service watcher.
+		r, ok := s.namespaces[w.Namespace][w.ServiceID]
+		if !ok || failed[w] || s.yieldsToConsulServiceLocked(r) {
+			continue
+		}
+		registrations[w] = r
+		if !s.registeredNodes[nodeKey(r.Partition, r.Node)] {
+			nodeRegistrations = append(nodeRegistrations, w)
+			continue
+		}
+		txn := txns[r.Partition]
+		if txn == nil {
+			txn = &writeTxn{}
+			txns[r.Partition] = txn
+		}
+		txn.add(w, registrationTxnOps(r)...)
+	}
+	s.lock.Unlock()
+
+	// Make sure the Consul namespaces of the registrations exist.
+	if s.EnableNamespaces {
+		for w, r := range registrations {
+			if err := s.ensureNamespace(r); err != nil {
+				s.Log.Warn("error checking and creating Consul namespace",
+					"node-name", r.Node,
+					"service-name", r.Service.Service,
+					"consul-namespace-name", r.Service.Namespace,
+					"err", err)
+				s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "namespace").Inc()
+				failed[w] = true
+			}
+		}
+	}
+
+	// Register the service instances whose node may not exist yet.
+	for _, w := range nodeRegistrations {
+		if failed[w] {
+			continue
+		}
+		r := registrations[w]
+		if _, err := s.Client.Catalog().Register(r, nil); err != nil {
+			s.Log.Warn("error registering service",
+				"node-name", r.Node,
+				"service-name", r.Service.Service,
+				"service", r.Service,
+				"err", err)
+			s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "register").Inc()
+			failed[w] = true
+			continue
+		}
+		s.Metrics.Writes.WithLabelValues(metrics.DirectionToConsul, "register").Inc()
+		s.Log.Debug("registered service instance",
+			"node-name", r.Node,
+			"service-name", r.Service.Service,
+			"consul-namespace-name", r.Service.Namespace,
+			"service", r.Service)
+
+		s.lock.Lock()
+		s.registeredNodes[nodeKey(r.Partition, r.Node)] = true
+		s.lock.Unlock()
+	}
+
+	// Write the other writes in transactions per partition, in a stable
+	// order.
+	partitions := make([]string, 0, len(txns))
+	for partition := range txns {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	for _, partition := range partitions {
+		for _, chunk := range txns[partition].split(failed) {
+			s.writeTxnChunk(partition, chunk, registrations, deregistrations, failed)
+		}
+	}
+
+	return failed
+}
+
+// writeTxnChunk writes the operations of the chunk in a transaction and
+// records the writes that failed in failed.
+func (s *ConsulSyncer) writeTxnChunk(partition string, chunk *writeTxn, registrations map[syncWrite]*api.CatalogRegistration,
+	deregistrations map[syncWrite]*api.CatalogDeregistration, failed map[syncWrite]bool) {
+	var ops api.TxnOps
+	for _, o := range chunk.ops {
+		ops = append(ops, o...)
+	}
+	err := s.writeTxn(ops, partition)
+	if err != nil {
+		s.Log.Warn("error writing services",
+			"partition", partition,
+			"writes", len(chunk.writes),
+			"err", err)
+		s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToConsul, "txn").Inc()
+	}
+
+	s.lock.Lock()
+	for _, w := range chunk.writes {
+		if err != nil {
+			failed[w] = true
+			// The node may have been deleted, so register it again
+			// on the next attempt.
+			if r, ok := registrations[w]; ok {
+				delete(s.registeredNodes, nodeKey(r.Partition, r.Node))
+			}
+			continue
+		}
+
+		if d, ok := deregistrations[w]; ok {
+			s.Metrics.Writes.WithLabelValues(metrics.DirectionToConsul, "deregister").Inc()
+			s.Log.Info("deregistered service",
+				"node-name", d.Node,
+				"service-id", d.ServiceID,
+				"service-consul-namespace", d.Namespace)
+			// Keep the deregistration if it was scheduled again while
+			// it was being written.
+			if s.deregs[w.ServiceID] == d {
+				delete(s.deregs, w.ServiceID)
+			}
+		} else {
+			r := registrations[w]
+			s.Metrics.Writes.WithLabelValues(metrics.DirectionToConsul, "register").Inc()
+			s.Log.Debug("registered service instance",
+				"node-name", r.Node,
+				"service-name", r.Service.Service,
+				"consul-namespace-name", r.Service.Namespace,
+				"service", r.Service)
+		}
+	}
+	s.lock.Unlock()
+}
+
+// writeTxn writes the operations to Consul in a single transaction.
+func (s *ConsulSyncer) writeTxn(ops api.TxnOps, partition string) error {
+	ok, resp, _, err := s.Client.Txn().Txn(ops, &api.QueryOptions{Partition: partition})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if resp != nil && len(resp.Errors) > 0 {
+			return fmt.Errorf("transaction was rolled back: operation %d: %s",
+				resp.Errors[0].OpIndex, resp.Errors[0].What)
+		}
+		return fmt.Errorf("transaction was rolled back")
+	}
+	return nil
+}
+
+// ensureNamespace creates the Consul namespace of the registration if it
+// hasn't been created since the last full sync.
+func (s *ConsulSyncer) ensureNamespace(r *api.CatalogRegistration) error {
+	ns := consulNamespace{Partition: r.Partition, Namespace: r.Service.Namespace}
+	s.lock.Lock()
+	ensured := s.ensuredNamespaces[ns]
+	s.lock.Unlock()
+	if ensured {
+		return nil
+	}
+
+	if _, err := namespaces.EnsureExistsInPartition(s.Client, r.Service.Namespace, r.Partition, s.CrossNamespaceACLPolicy); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.ensuredNamespaces[ns] = true
+	s.lock.Unlock()
+	return nil
+}
+
+// writeTxn holds the writes of a transaction and their operations.
+type writeTxn struct {
+	writes []syncWrite
+	ops    [][]*api.TxnOp
+}
+
+func (t *writeTxn) add(w syncWrite, ops ...*api.TxnOp) {
+	t.writes = append(t.writes, w)
+	t.ops = append(t.ops, ops)
+}
+
+// split returns the writes that didn't fail in transactions of up to
+// maxTxnOps operations. The operations of a write are in one transaction.
+func (t *writeTxn) split(failed map[syncWrite]bool) []*writeTxn {
+	var chunks []*writeTxn
+	var chunk *writeTxn
+	chunkOps := 0
+	for i, w := range t.writes {
+		if failed[w] {
+			continue
+		}
+		if chunk == nil || chunkOps+len(t.ops[i]) > maxTxnOps {
+			chunk = &writeTxn{}
+			chunks = append(chunks, chunk)
+			chunkOps = 0
+		}
+		chunk.add(w, t.ops[i]...)
+		chunkOps += len(t.ops[i])
+	}
+	return chunks
+}
+
+// registrationTxnOps returns the transaction operations that register the
+// service instance and its health check. The node isn't updated, like with
+// SkipNodeUpdate, so it must already exist.
+func registrationTxnOps(r *api.CatalogRegistration) []*api.TxnOp {
+	service := *r.Service
+	if service.Partition == "" {
+		service.Partition = r.Partition
+	}
+	ops := []*api.TxnOp{{Service: &api.ServiceTxnOp{
+		Verb:    api.ServiceSet,
+		Node:    r.Node,
+		Service: service,
+	}}}
+	if r.Check != nil {
+		ops = append(ops, &api.TxnOp{Check: &api.CheckTxnOp{
+			Verb: api.CheckSet,
+			Check: api.HealthCheck{
+				Node:        r.Node,
+				CheckID:     r.Check.CheckID,
+				Name:        r.Check.Name,
+				Status:      r.Check.Status,
+				Notes:       r.Check.Notes,
+				Output:      r.Check.Output,
+				ServiceID:   r.Check.ServiceID,
+				ServiceName: r.Check.ServiceName,
+				Type:        r.Check.Type,
+				Definition:  r.Check.Definition,
+				Namespace:   r.Check.Namespace,
+				Partition:   service.Partition,
+			},
+		}})
+	}
+	return ops
+}
+
+// nodeKey returns the key of the node in the registeredNodes map.
+func nodeKey(partition, node string) string {
+	return partition + "/" + node
+}
@@ -0,0 +1,319 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that registrations are written in batches, with a transaction once
+// the node is known to exist.
+func TestConsulSyncer_batchesWrites(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {
+		s.WriteBatchSize = 2
+	})
+
+	var rs []*api.CatalogRegistration
+	for i := 0; i < 5; i++ {
+		rs = append(rs, testRegistration(ConsulSyncNodeName, fmt.Sprintf("svc-%d", i), "default"))
+	}
+	s.Sync(rs)
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		consul.Lock()
+		defer consul.Unlock()
+		if len(consul.registered) != 5 {
+			r.Fatalf("expected 5 registered services, got %d", len(consul.registered))
+		}
+	})
+
+	consul.Lock()
+	defer consul.Unlock()
+	for _, r := range rs {
+		require.Equal(t, 1, consul.registered[r.Service.ID], r.Service.ID)
+	}
+	// The first batch is registered without a transaction since the node
+	// may not exist yet.
+	require.Equal(t, 2, consul.registerRequests)
+	require.Equal(t, []int{2, 1}, consul.txnSizes)
+}
+
+// Test that batches with more operations than a Consul transaction allows
+// are written in several transactions.
+func TestConsulSyncer_splitsLargeBatches(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {
+		s.WriteBatchSize = 100
+	})
+	// Each service instance has a health check, so a batch of 100 instances
+	// has 200 operations.
+	var rs []*api.CatalogRegistration
+	for i := 0; i < 100; i++ {
+		r := testRegistration(ConsulSyncNodeName, fmt.Sprintf("svc-%d", i), "default")
+		r.Check = &api.AgentCheck{
+			CheckID:   r.Service.ID + "/readiness",
+			Name:      "Kubernetes Readiness Check",
+			Status:    api.HealthPassing,
+			ServiceID: r.Service.ID,
+		}
+		rs = append(rs, r)
+	}
+	s.Sync(rs)
+	// The node exists, so that the batch is written in transactions.
+	s.registeredNodes[nodeKey("", ConsulSyncNodeName)] = true
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		consul.Lock()
+		defer consul.Unlock()
+		if len(consul.registered) != 100 {
+			r.Fatalf("expected 100 registered services, got %d", len(consul.registered))
+		}
+	})
+	consul.Lock()
+	defer consul.Unlock()
+	require.Equal(t, []int{128, 72}, consul.txnSizes)
+	require.Zero(t, consul.rejectedTxns)
+}
+
+// Test that a service instance that changes while its write is queued is
+// written once with its latest registration.
+func TestConsulSyncer_coalescesQueuedWrites(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {})
+
+	reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+	s.Sync([]*api.CatalogRegistration{reg})
+	updated := testRegistration(ConsulSyncNodeName, "bar", "default")
+	updated.Service.Port = 8080
+	s.Sync([]*api.CatalogRegistration{updated})
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		consul.Lock()
+		defer consul.Unlock()
+		if consul.registered[reg.Service.ID] != 1 {
+			r.Fatal("service not registered")
+		}
+	})
+	consul.Lock()
+	defer consul.Unlock()
+	require.Equal(t, 8080, consul.ports[reg.Service.ID])
+}
+
+// Test that failed writes are retried.
+func TestConsulSyncer_retriesFailedWrites(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	consul.failRegistrations = 1
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {})
+
+	reg := testRegistration(ConsulSyncNodeName, "bar", "default")
+	s.Sync([]*api.CatalogRegistration{reg})
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		consul.Lock()
+		defer consul.Unlock()
+		if consul.registered[reg.Service.ID] != 1 {
+			r.Fatal("service not registered")
+		}
+	})
+	consul.Lock()
+	defer consul.Unlock()
+	require.Equal(t, 2, consul.registerRequests)
+}
+
+// Test that writes are rate limited.
+func TestConsulSyncer_rateLimitsWrites(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {
+		s.WriteBatchSize = 1
+		s.WriteRateLimit = 10
+	})
+
+	var rs []*api.CatalogRegistration
+	for i := 0; i < 5; i++ {
+		rs = append(rs, testRegistration(ConsulSyncNodeName, fmt.Sprintf("svc-%d", i), "default"))
+	}
+	s.Sync(rs)
+	started := time.Now()
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		consul.Lock()
+		defer consul.Unlock()
+		if len(consul.registered) != 5 {
+			r.Fatalf("expected 5 registered services, got %d", len(consul.registered))
+		}
+	})
+	// The first write is made immediately and the others are made at
+	// 10 per second.
+	require.GreaterOrEqual(t, time.Since(started), 400*time.Millisecond)
+}
+
+// Test that the metrics track the writes, the drift until it is written,
+// and the last successful full sync.
+func TestConsulSyncer_metrics(t *testing.T) {
+	t.Parallel()
+	consul := newFakeConsulWrites(t)
+	consul.failRegistrations = 1
+	s, start := testFakeConsulSyncer(t, consul, func(s *ConsulSyncer) {})
+
+	s.Sync([]*api.CatalogRegistration{testRegistration(ConsulSyncNodeName, "bar", "default")})
+	m := s.Metrics
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Registrations.WithLabelValues(metrics.DirectionToConsul)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Drift.WithLabelValues(metrics.DirectionToConsul)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.WriteQueueDepth))
+	closer := start()
+	defer closer()
+
+	retry.Run(t, func(r *retry.R) {
+		if testutil.ToFloat64(m.Writes.WithLabelValues(metrics.DirectionToConsul, "register")) != 1 {
+			r.Fatal("service not registered")
+		}
+		if testutil.ToFloat64(m.Drift.WithLabelValues(metrics.DirectionToConsul)) != 0 {
+			r.Fatal("drift not written")
+		}
+	})
+	require.Equal(t, 1.0, testutil.ToFloat64(m.APIErrors.WithLabelValues(metrics.DirectionToConsul, "register")))
+	require.Equal(t, 0.0, testutil.ToFloat64(m.WriteQueueDepth))
+	require.Equal(t, 0.0, testutil.ToFloat64(m.LastSuccessfulSync.WithLabelValues(metrics.DirectionToConsul)))
+
+	// A full sync is successful once its writes are written.
+	ctx, cancelF := context.WithCancel(context.Background())
+	defer cancelF()
+	s.syncFull(ctx)
+	retry.Run(t, func(r *retry.R) {
+		if testutil.ToFloat64(m.LastSuccessfulSync.WithLabelValues(metrics.DirectionToConsul)) == 0 {
+			r.Fatal("full sync not successful")
+		}
+	})
+	require.Equal(t, 2.0, testutil.ToFloat64(m.Writes.WithLabelValues(metrics.DirectionToConsul, "register")))
+}
+
+// fakeConsulWrites is a fake Consul server that records the registrations
+// it receives.
+type fakeConsulWrites struct {
+	sync.Mutex
+	*httptest.Server
+
+	// failRegistrations is the number of registration requests to fail.
+	failRegistrations int
+
+	registerRequests int
+	txnSizes         []int
+	rejectedTxns     int
+	registered       map[string]int
+	ports            map[string]int
+}
+
+func newFakeConsulWrites(t *testing.T) *fakeConsulWrites {
+	consul := &fakeConsulWrites{
+		registered: make(map[string]int),
+		ports:      make(map[string]int),
+	}
+	consul.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		consul.Lock()
+		defer consul.Unlock()
+		switch r.URL.Path {
+		case "/v1/catalog/register":
+			consul.registerRequests++
+			if consul.failRegistrations > 0 {
+				consul.failRegistrations--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			var reg api.CatalogRegistration
+			if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			consul.registered[reg.Service.ID]++
+			consul.ports[reg.Service.ID] = reg.Service.Port
+			w.Write([]byte("true"))
+		case "/v1/txn":
+			var ops api.TxnOps
+			if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// Consul rejects transactions with too many operations.
+			if len(ops) > maxTxnOps {
+				consul.rejectedTxns++
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			consul.txnSizes = append(consul.txnSizes, len(ops))
+			for _, op := range ops {
+				if op.Service != nil && op.Service.Verb == api.ServiceSet {
+					consul.registered[op.Service.Service.ID]++
+					consul.ports[op.Service.Service.ID] = op.Service.Service.Port
+				}
+			}
+			json.NewEncoder(w).Encode(api.TxnResponse{})
+		default:
+			// Reads for the service watchers and reapers.
+			w.Write([]byte("[]"))
+		}
+	}))
+	t.Cleanup(consul.Close)
+	return consul
+}
+
+// testFakeConsulSyncer returns a syncer for the fake Consul server and a
+// function that runs it, which returns a function that stops it. The
+// syncer only syncs fully once an hour so that writes are only made for
+// changes.
+func testFakeConsulSyncer(t *testing.T, consul *fakeConsulWrites, configurator func(*ConsulSyncer)) (*ConsulSyncer, func() func()) {
+	client, err := api.NewClient(&api.Config{Address: consul.URL})
+	require.NoError(t, err)
+	s := &ConsulSyncer{
+		Client:            client,
+		Log:               hclog.Default(),
+		SyncPeriod:        time.Hour,
+		ServicePollPeriod: time.Hour,
+		ConsulK8STag:      TestConsulK8STag,
+		ConsulNodeName:    ConsulSyncNodeName,
+		ConsulNodeServicesClient: &PreNamespacesNodeServicesClient{
+			Client: client,
+		},
+	}
+	configurator(s)
+
+	return s, func() func() {
+		ctx, cancelF := context.WithCancel(context.Background())
+		doneCh := make(chan struct{})
+		go func() {
+			defer close(doneCh)
+			s.Run(ctx)
+		}()
+		return func() {
+			cancelF()
+			<-doneCh
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/coalesce"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
 	"github.com/hashicorp/go-hclog"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -54,6 +56,10 @@ type K8SSink struct {
 	// Ctx is used to cancel the Sink.
 	Ctx context.Context
 
+	// Metrics are updated with the state of the sink. If nil, the metrics
+	// aren't updated.
+	Metrics *metrics.Metrics
+
 	// lock gates concurrent access to all the maps.
 	lock sync.Mutex
 
@@ -156,7 +162,7 @@ func (s *K8SSink) Upsert(key string, raw interface{}) error {
 		s.trigger() // Always trigger sync
 	}
 
-	s.Log.Info("upsert", "key", key)
+	s.Log.Info("upsert", controller.ResourceLogArgs(key)...)
 	return nil
 }
 
@@ -185,7 +191,7 @@ func (s *K8SSink) Delete(key string, _ interface{}) error {
 		s.trigger()
 	}
 
-	s.Log.Info("delete", "key", key, "name", name)
+	s.Log.Info("delete", controller.ResourceLogArgs(key)...)
 	return nil
 }
 
@@ -225,29 +231,61 @@ func (s *K8SSink) Run(ch <-chan struct{}) {
 		s.lock.Unlock()
 		s.Log.Debug("sync triggered", "create", len(create), "update", len(update), "delete", len(delete))
 
+		// failed is the number of services that still differ from Consul.
+		failed := 0
 		svcClient := s.Client.CoreV1().Services(s.namespace())
 		for _, name := range delete {
 			if err := svcClient.Delete(s.Ctx, name, metav1.DeleteOptions{}); err != nil {
 				s.Log.Warn("error deleting service", "name", name, "error", err)
+				s.recordWrite("delete", err)
+				failed++
+				continue
 			}
+			s.recordWrite("delete", nil)
 		}
 
 		for _, svc := range update {
 			_, err := svcClient.Update(s.Ctx, svc, metav1.UpdateOptions{})
 			if err != nil {
 				s.Log.Warn("error updating service", "name", svc.Name, "error", err)
+				failed++
 			}
+			s.recordWrite("update", err)
 		}
 
 		for _, svc := range create {
 			_, err := svcClient.Create(s.Ctx, svc, metav1.CreateOptions{})
 			if err != nil {
 				s.Log.Warn("error creating service", "name", svc.Name, "error", err)
+				failed++
+			}
+			s.recordWrite("create", err)
+		}
+
+		if s.Metrics != nil {
+			s.lock.Lock()
+			s.Metrics.Registrations.WithLabelValues(metrics.DirectionToK8S).Set(float64(len(s.sourceServices)))
+			s.lock.Unlock()
+			s.Metrics.Drift.WithLabelValues(metrics.DirectionToK8S).Set(float64(failed))
+			if failed == 0 {
+				s.Metrics.LastSuccessfulSync.WithLabelValues(metrics.DirectionToK8S).Set(float64(time.Now().Unix()))
 			}
 		}
 	}
 }
 
+// recordWrite counts the write of the operation, or its error.
+func (s *K8SSink) recordWrite(operation string, err error) {
+	if s.Metrics == nil {
+		return
+	}
+	if err != nil {
+		s.Metrics.APIErrors.WithLabelValues(metrics.DirectionToK8S, operation).Inc()
+		return
+	}
+	s.Metrics.Writes.WithLabelValues(metrics.DirectionToK8S, operation).Inc()
+}
+
 // crudList returns the services to create, update, and delete (respectively).
 func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 	var create, update []*apiv1.Service
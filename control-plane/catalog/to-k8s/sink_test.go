@@ -2,16 +2,21 @@ package catalog
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func init() {
@@ -393,6 +398,52 @@ func TestK8SSink_deleteReconcileLocal(t *testing.T) {
 	})
 }
 
+// Test that the metrics track the writes and the services that fail to sync.
+func TestK8SSink_metrics(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		svc := action.(k8stesting.CreateAction).GetObject().(*apiv1.Service)
+		if svc.Name == "bad" {
+			return true, nil, errors.New("create failed")
+		}
+		return false, nil, nil
+	})
+
+	m := metrics.New()
+	sink := &K8SSink{
+		Client:  client,
+		Log:     hclog.Default(),
+		Ctx:     context.Background(),
+		Metrics: m,
+	}
+	closer := controller.TestControllerRun(sink)
+	defer closer()
+
+	sink.SetServices(map[string]string{
+		"web": "web.service.local.",
+		"bad": "bad.service.local.",
+	})
+	retry.Run(t, func(r *retry.R) {
+		if testutil.ToFloat64(m.Drift.WithLabelValues(metrics.DirectionToK8S)) != 1 {
+			r.Fatal("expected the failed service to drift")
+		}
+	})
+	require.Equal(t, 2.0, testutil.ToFloat64(m.Registrations.WithLabelValues(metrics.DirectionToK8S)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Writes.WithLabelValues(metrics.DirectionToK8S, "create")))
+	require.GreaterOrEqual(t, testutil.ToFloat64(m.APIErrors.WithLabelValues(metrics.DirectionToK8S, "create")), 1.0)
+	require.Equal(t, 0.0, testutil.ToFloat64(m.LastSuccessfulSync.WithLabelValues(metrics.DirectionToK8S)))
+
+	sink.SetServices(map[string]string{"web": "web.service.local."})
+	retry.Run(t, func(r *retry.R) {
+		if testutil.ToFloat64(m.LastSuccessfulSync.WithLabelValues(metrics.DirectionToK8S)) == 0 {
+			r.Fatal("sync not successful")
+		}
+	})
+	require.Equal(t, 0.0, testutil.ToFloat64(m.Drift.WithLabelValues(metrics.DirectionToK8S)))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Registrations.WithLabelValues(metrics.DirectionToK8S)))
+}
+
 func testSink(t *testing.T, client kubernetes.Interface) (*K8SSink, func()) {
 	sink := &K8SSink{
 		Client: client,
@@ -19,6 +19,11 @@ type Source struct {
 	Prefix       string       // Prefix is a prefix to prepend to services
 	Log          hclog.Logger // Logger
 	ConsulK8STag string       // The tag value for services registered
+
+	// ExcludeServices is optional and returns the names of Consul services
+	// that should not be synced to Kubernetes. It is used to resolve
+	// conflicts with services that are synced from Kubernetes to Consul.
+	ExcludeServices func() map[string]struct{}
 }
 
 // Run is the long-running runloop for watching Consul services and
@@ -53,9 +58,21 @@ func (s *Source) Run(ctx context.Context) {
 		// Update our blocking index
 		opts.WaitIndex = meta.LastIndex
 
+		// Get the services that are excluded because of conflicts with
+		// services synced from k8s.
+		var excluded map[string]struct{}
+		if s.ExcludeServices != nil {
+			excluded = s.ExcludeServices()
+		}
+
 		// Setup the services
 		services := make(map[string]string, len(serviceMap))
 		for name, tags := range serviceMap {
+			if _, ok := excluded[name]; ok {
+				s.Log.Debug("service conflicts with a service synced from k8s, not syncing", "name", name)
+				continue
+			}
+
 			// We ignore services that are synced from k8s so we can avoid
 			// circular syncing. Realistically this shouldn't happen since
 			// we won't register services that already exist but we double
@@ -103,6 +103,51 @@ func TestSource_prefix(t *testing.T) {
 	require.Equal(expected, actual)
 }
 
+// Test that the source doesn't sync excluded services.
+func TestSource_excludeServices(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Set up server, client
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(err)
+	defer a.Stop()
+
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(err)
+
+	_, sink, closer := testSourceWithConfig(client, func(s *Source) {
+		s.ExcludeServices = func() map[string]struct{} {
+			return map[string]struct{}{"svcB": {}}
+		}
+	})
+	defer closer()
+
+	// Create services before the source is running
+	_, err = client.Catalog().Register(testRegistration("hostA", "svcA", nil), nil)
+	require.NoError(err)
+	_, err = client.Catalog().Register(testRegistration("hostB", "svcB", nil), nil)
+	require.NoError(err)
+
+	var actual map[string]string
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		actual = sink.Services
+		if len(actual) != 2 {
+			r.Fatal("services not found")
+		}
+	})
+
+	expected := map[string]string{
+		"consul": "consul.service.test",
+		"svcA":   "svcA.service.test",
+	}
+	require.Equal(expected, actual)
+}
+
 // Test that the source ignores K8S services.
 func TestSource_ignoreK8S(t *testing.T) {
 	t.Parallel()
@@ -4,18 +4,26 @@ import (
 	"os"
 
 	cmdACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/acl-init"
+	cmdACLLoginAudit "github.com/hashicorp/consul-k8s/control-plane/subcommand/acl-login-audit"
+	cmdACLTokenRotate "github.com/hashicorp/consul-k8s/control-plane/subcommand/acl-token-rotate"
 	cmdConnectInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/connect-init"
 	cmdConsulLogout "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-logout"
 	cmdConsulSidecar "github.com/hashicorp/consul-k8s/control-plane/subcommand/consul-sidecar"
 	cmdController "github.com/hashicorp/consul-k8s/control-plane/subcommand/controller"
 	cmdCreateFederationSecret "github.com/hashicorp/consul-k8s/control-plane/subcommand/create-federation-secret"
 	cmdDeleteCompletedJob "github.com/hashicorp/consul-k8s/control-plane/subcommand/delete-completed-job"
+	cmdDNSForward "github.com/hashicorp/consul-k8s/control-plane/subcommand/dns-forward"
 	cmdGetConsulClientCA "github.com/hashicorp/consul-k8s/control-plane/subcommand/get-consul-client-ca"
 	cmdGossipEncryptionAutogenerate "github.com/hashicorp/consul-k8s/control-plane/subcommand/gossip-encryption-autogenerate"
+	cmdGossipEncryptionRotate "github.com/hashicorp/consul-k8s/control-plane/subcommand/gossip-encryption-rotate"
 	cmdInjectConnect "github.com/hashicorp/consul-k8s/control-plane/subcommand/inject-connect"
+	cmdJobWatcher "github.com/hashicorp/consul-k8s/control-plane/subcommand/job-watcher"
 	cmdPartitionInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/partition-init"
+	cmdRedirectTrafficIPv6 "github.com/hashicorp/consul-k8s/control-plane/subcommand/redirect-traffic-ipv6"
+	cmdSDSServer "github.com/hashicorp/consul-k8s/control-plane/subcommand/sds-server"
 	cmdServerACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/server-acl-init"
 	cmdServiceAddress "github.com/hashicorp/consul-k8s/control-plane/subcommand/service-address"
+	cmdSnapshotVerify "github.com/hashicorp/consul-k8s/control-plane/subcommand/snapshot-verify"
 	cmdSyncCatalog "github.com/hashicorp/consul-k8s/control-plane/subcommand/sync-catalog"
 	cmdTLSInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/tls-init"
 	cmdVersion "github.com/hashicorp/consul-k8s/control-plane/subcommand/version"
@@ -67,10 +75,22 @@ func init() {
 			return &cmdDeleteCompletedJob.Command{UI: ui}, nil
 		},
 
+		"job-watcher": func() (cli.Command, error) {
+			return &cmdJobWatcher.Command{UI: ui}, nil
+		},
+
+		"redirect-traffic-ipv6": func() (cli.Command, error) {
+			return &cmdRedirectTrafficIPv6.Command{UI: ui}, nil
+		},
+
 		"service-address": func() (cli.Command, error) {
 			return &cmdServiceAddress.Command{UI: ui}, nil
 		},
 
+		"sds-server": func() (cli.Command, error) {
+			return &cmdSDSServer.Command{UI: ui}, nil
+		},
+
 		"get-consul-client-ca": func() (cli.Command, error) {
 			return &cmdGetConsulClientCA.Command{UI: ui}, nil
 		},
@@ -98,6 +118,26 @@ func init() {
 		"gossip-encryption-autogenerate": func() (cli.Command, error) {
 			return &cmdGossipEncryptionAutogenerate.Command{UI: ui}, nil
 		},
+
+		"gossip-encryption-rotate": func() (cli.Command, error) {
+			return &cmdGossipEncryptionRotate.Command{UI: ui}, nil
+		},
+
+		"acl-token-rotate": func() (cli.Command, error) {
+			return &cmdACLTokenRotate.Command{UI: ui}, nil
+		},
+
+		"acl-login-audit": func() (cli.Command, error) {
+			return &cmdACLLoginAudit.Command{UI: ui}, nil
+		},
+
+		"snapshot-verify": func() (cli.Command, error) {
+			return &cmdSnapshotVerify.Command{UI: ui}, nil
+		},
+
+		"dns-forward": func() (cli.Command, error) {
+			return &cmdDNSForward.Command{UI: ui}, nil
+		},
 	}
 }
 
@@ -47,6 +47,12 @@ const (
 	// service name should map to a Consul service namd and the local port
 	// is the local port in the pod that the listener will bind to. It can
 	// be a named port.
+	// Each upstream can be followed by `;<key>=<value>` settings to configure
+	// the upstream's datacenter, namespace, partition, peer, connect-timeout
+	// or local-bind-address, e.g. `db:1234;peer=cluster-2;connect-timeout=5s`.
+	// The local port can instead be set with the local-bind-port setting,
+	// which also accepts a range of ports to bind to the first free port of,
+	// e.g. `db;local-bind-port=20000-20100`.
 	annotationUpstreams = "consul.hashicorp.com/connect-service-upstreams"
 
 	// annotationTags is a list of tags to register with the service
@@ -131,6 +137,14 @@ const (
 	// annotationTProxyExcludeUIDs is a comma-separated list of additional user IDs to exclude from traffic redirection.
 	annotationTProxyExcludeUIDs = "consul.hashicorp.com/transparent-proxy-exclude-uids"
 
+	// annotationTProxyExcludeInitContainers is a comma-separated list of init container names whose traffic
+	// should be excluded from redirection. Only init containers that run after the Consul init containers
+	// are redirected, which are the ones added by webhooks that run after the connect injector. It requires
+	// the connect injector webhook to be reinvoked after those webhooks. Listed init containers that don't
+	// set a user ID are then assigned a dedicated user ID which is excluded from traffic redirection.
+	// Listed init containers that set their own user ID must also be listed in annotationTProxyExcludeUIDs.
+	annotationTProxyExcludeInitContainers = "consul.hashicorp.com/transparent-proxy-exclude-init-containers"
+
 	// annotationTransparentProxyOverwriteProbes controls whether the Kubernetes probes should be overwritten
 	// to point to the Envoy proxy when running in Transparent Proxy mode.
 	annotationTransparentProxyOverwriteProbes = "consul.hashicorp.com/transparent-proxy-overwrite-probes"
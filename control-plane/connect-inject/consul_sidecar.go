@@ -2,17 +2,23 @@ package connectinject
 
 import (
 	"fmt"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
-// consulSidecar starts the consul-sidecar command to only run
-// the metrics merging server when metrics merging feature is enabled.
+// lifecycleMetricsPortName is the name of the port of the consul-sidecar
+// that serves the lifecycle metrics of the pod.
+const lifecycleMetricsPortName = "lifecycle-metrics"
+
+// consulSidecar starts the consul-sidecar command to run the metrics merging
+// server when metrics merging feature is enabled, and to serve the lifecycle
+// metrics of the pod when they are enabled.
 // It always disables service registration because for connect we no longer
 // need to keep services registered as this is handled in the endpoints-controller.
 func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
-	metricsPorts, err := h.MetricsConfig.mergedMetricsServerConfiguration(pod)
+	runMergedMetricsServer, err := h.MetricsConfig.shouldRunMergedMetricsServer(pod)
 	if err != nil {
 		return corev1.Container{}, err
 	}
@@ -26,13 +32,42 @@ func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
 		"consul-k8s-control-plane",
 		"consul-sidecar",
 		"-enable-service-registration=false",
-		"-enable-metrics-merging=true",
-		fmt.Sprintf("-merged-metrics-port=%s", metricsPorts.mergedPort),
-		fmt.Sprintf("-service-metrics-port=%s", metricsPorts.servicePort),
-		fmt.Sprintf("-service-metrics-path=%s", metricsPorts.servicePath),
+	}
+	if runMergedMetricsServer {
+		metricsPorts, err := h.MetricsConfig.mergedMetricsServerConfiguration(pod)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		command = append(command,
+			"-enable-metrics-merging=true",
+			fmt.Sprintf("-merged-metrics-port=%s", metricsPorts.mergedPort),
+			fmt.Sprintf("-service-metrics-port=%s", metricsPorts.servicePort),
+			fmt.Sprintf("-service-metrics-path=%s", metricsPorts.servicePath),
+		)
+	}
+
+	// The lifecycle metrics are merged into the merged metrics if the pod
+	// runs the merged metrics server, and otherwise served on their own port.
+	var ports []corev1.ContainerPort
+	if h.MetricsConfig.shouldRunLifecycleMetrics(pod) {
+		command = append(command, "-enable-lifecycle-metrics=true")
+		if !runMergedMetricsServer {
+			port, err := strconv.Atoi(h.MetricsConfig.LifecycleMetricsPort)
+			if err != nil {
+				return corev1.Container{}, fmt.Errorf("parsing lifecycle metrics port %q: %s", h.MetricsConfig.LifecycleMetricsPort, err)
+			}
+			command = append(command, fmt.Sprintf("-lifecycle-metrics-port=%d", port))
+			ports = append(ports, corev1.ContainerPort{
+				Name:          lifecycleMetricsPortName,
+				ContainerPort: int32(port),
+			})
+		}
+	}
+
+	command = append(command,
 		fmt.Sprintf("-log-level=%s", h.LogLevel),
 		fmt.Sprintf("-log-json=%t", h.LogJSON),
-	}
+	)
 
 	return corev1.Container{
 		Name:  "consul-sidecar",
@@ -43,8 +78,10 @@ func (h *Handler) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
 				MountPath: "/consul/connect-inject",
 			},
 		},
-		Command:   command,
-		Resources: resources,
+		Command:         command,
+		Ports:           ports,
+		Resources:       resources,
+		SecurityContext: h.restrictSecurityContext(nil),
 	}, nil
 }
 
@@ -45,6 +45,77 @@ func TestConsulSidecar_MetricsFlags(t *testing.T) {
 	require.Contains(t, container.Command, "-service-metrics-path=/metrics")
 }
 
+// Test that the lifecycle metrics are merged into the merged metrics of pods
+// that run the merged metrics server, and otherwise served on their own port.
+func TestConsulSidecar_LifecycleMetricsFlags(t *testing.T) {
+	cases := map[string]struct {
+		enableMetricsMerging bool
+		expCommand           []string
+		expPorts             []corev1.ContainerPort
+	}{
+		"merged": {
+			enableMetricsMerging: true,
+			expCommand: []string{
+				"consul-k8s-control-plane",
+				"consul-sidecar",
+				"-enable-service-registration=false",
+				"-enable-metrics-merging=true",
+				"-merged-metrics-port=20100",
+				"-service-metrics-port=8080",
+				"-service-metrics-path=/metrics",
+				"-enable-lifecycle-metrics=true",
+				"-log-level=info",
+				"-log-json=false",
+			},
+		},
+		"own port": {
+			enableMetricsMerging: false,
+			expCommand: []string{
+				"consul-k8s-control-plane",
+				"consul-sidecar",
+				"-enable-service-registration=false",
+				"-enable-lifecycle-metrics=true",
+				"-lifecycle-metrics-port=20300",
+				"-log-level=info",
+				"-log-json=false",
+			},
+			expPorts: []corev1.ContainerPort{
+				{
+					Name:          "lifecycle-metrics",
+					ContainerPort: 20300,
+				},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := Handler{
+				Log:            logrtest.TestLogger{T: t},
+				ImageConsulK8S: "hashicorp/consul-k8s:9.9.9",
+				LogLevel:       "info",
+				MetricsConfig: MetricsConfig{
+					DefaultEnableMetrics:        true,
+					DefaultEnableMetricsMerging: c.enableMetricsMerging,
+					EnableLifecycleMetrics:      true,
+					LifecycleMetricsPort:        "20300",
+				},
+			}
+			container, err := handler.consulSidecar(corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationMergedMetricsPort:  "20100",
+						annotationServiceMetricsPort: "8080",
+						annotationServiceMetricsPath: "/metrics",
+					},
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expCommand, container.Command)
+			require.Equal(t, c.expPorts, container.Ports)
+		})
+	}
+}
+
 func TestHandlerConsulSidecar_Resources(t *testing.T) {
 	mem1 := resource.MustParse("100Mi")
 	mem2 := resource.MustParse("200Mi")
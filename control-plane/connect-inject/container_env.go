@@ -5,33 +5,39 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/consul/api"
 	corev1 "k8s.io/api/core/v1"
 )
 
-func (h *Handler) containerEnvVars(pod corev1.Pod) []corev1.EnvVar {
-	raw, ok := pod.Annotations[annotationUpstreams]
-	if !ok || raw == "" {
-		return []corev1.EnvVar{}
-	}
-
+func (h *Handler) containerEnvVars(pod corev1.Pod) ([]corev1.EnvVar, error) {
 	var result []corev1.EnvVar
-	for _, raw := range strings.Split(raw, ",") {
-		parts := strings.SplitN(raw, ":", 3)
-		port, _ := portValue(pod, strings.TrimSpace(parts[1]))
-		if port > 0 {
-			name := strings.TrimSpace(parts[0])
-			name = strings.ToUpper(strings.Replace(name, "-", "_", -1))
-			portStr := strconv.Itoa(int(port))
+	for _, entry := range parseUpstreamEntries(pod, h.EnableNamespaces, h.ConsulPartition != "") {
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		// Prepared query upstreams don't get environment variables.
+		if entry.upstream.DestinationType == api.UpstreamDestTypePreparedQuery {
+			continue
+		}
+
+		// The name of the environment variables is derived from the upstream
+		// as written in the annotation, including its namespace and partition.
+		name := strings.TrimSpace(strings.SplitN(strings.Split(entry.raw, upstreamSettingsSeparator)[0], ":", 2)[0])
+		name = strings.ToUpper(strings.Replace(name, "-", "_", -1))
 
-			result = append(result, corev1.EnvVar{
-				Name:  fmt.Sprintf("%s_CONNECT_SERVICE_HOST", name),
-				Value: "127.0.0.1",
-			}, corev1.EnvVar{
-				Name:  fmt.Sprintf("%s_CONNECT_SERVICE_PORT", name),
-				Value: portStr,
-			})
+		host := loopbackAddress(h.ListenerIPFamily)
+		if entry.upstream.LocalBindAddress != "" {
+			host = entry.upstream.LocalBindAddress
 		}
+
+		result = append(result, corev1.EnvVar{
+			Name:  fmt.Sprintf("%s_CONNECT_SERVICE_HOST", name),
+			Value: host,
+		}, corev1.EnvVar{
+			Name:  fmt.Sprintf("%s_CONNECT_SERVICE_PORT", name),
+			Value: strconv.Itoa(entry.upstream.LocalBindPort),
+		})
 	}
 
-	return result
+	return result, nil
 }
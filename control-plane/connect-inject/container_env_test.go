@@ -13,14 +13,50 @@ func TestContainerEnvVars(t *testing.T) {
 	cases := []struct {
 		Name     string
 		Upstream string
+		ExpHost  string
+		ExpPort  string
 	}{
 		{
 			"Upstream with datacenter",
 			"static-server:7890:dc1",
+			"127.0.0.1",
+			"7890",
 		},
 		{
 			"Upstream without datacenter",
 			"static-server:7890",
+			"127.0.0.1",
+			"7890",
+		},
+		{
+			"Upstream with settings",
+			"static-server:7890;peer=cluster-2;connect-timeout=5s",
+			"127.0.0.1",
+			"7890",
+		},
+		{
+			"Upstream with local bind address",
+			"static-server:7890;local-bind-address=127.0.0.2",
+			"127.0.0.2",
+			"7890",
+		},
+		{
+			"Upstream with local bind port",
+			"static-server;local-bind-port=7891",
+			"127.0.0.1",
+			"7891",
+		},
+		{
+			"Upstream with local bind port range",
+			"static-server;local-bind-port=8080-8090",
+			"127.0.0.1",
+			"8081",
+		},
+		{
+			"Upstream and prepared query",
+			"static-server:7890, prepared_query:queryname:7891",
+			"127.0.0.1",
+			"7890",
 		},
 	}
 
@@ -29,24 +65,46 @@ func TestContainerEnvVars(t *testing.T) {
 			require := require.New(t)
 
 			var h Handler
-			envVars := h.containerEnvVars(corev1.Pod{
+			envVars, err := h.containerEnvVars(corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
 					Annotations: map[string]string{
 						annotationService:   "foo",
 						annotationUpstreams: tt.Upstream,
 					},
 				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "web",
+							Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+						},
+					},
+				},
 			})
+			require.NoError(err)
 
 			require.ElementsMatch(envVars, []corev1.EnvVar{
 				{
 					Name:  "STATIC_SERVER_CONNECT_SERVICE_HOST",
-					Value: "127.0.0.1",
+					Value: tt.ExpHost,
 				}, {
 					Name:  "STATIC_SERVER_CONNECT_SERVICE_PORT",
-					Value: "7890",
+					Value: tt.ExpPort,
 				},
 			})
 		})
 	}
 }
+
+func TestContainerEnvVars_InvalidUpstream(t *testing.T) {
+	var h Handler
+	_, err := h.containerEnvVars(corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService:   "foo",
+				annotationUpstreams: "static-server:7890;connect-timeout=5",
+			},
+		},
+	})
+	require.EqualError(t, err, `upstream "static-server:7890;connect-timeout=5" is invalid: connect-timeout "5" must be a duration of at least 1ms, e.g. 5s`)
+}
@@ -3,6 +3,7 @@ package connectinject
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -18,6 +19,7 @@ const (
 	rootUserAndGroupID          = 0
 	envoyUserAndGroupID         = 5995
 	copyContainerUserAndGroupID = 5996
+	excludedInitContainerUserID = 5997
 	netAdminCapability          = "NET_ADMIN"
 	dnsServiceHostEnvSuffix     = "DNS_SERVICE_HOST"
 )
@@ -74,6 +76,18 @@ type initContainerCommandData struct {
 	// ConsulDNSClusterIP is the IP of the Consul DNS Service.
 	ConsulDNSClusterIP string
 
+	// EnableIPv6 configures this init container to also apply the traffic redirection rules
+	// for IPv6 traffic with the consul-k8s-control-plane redirect-traffic-ipv6 command.
+	EnableIPv6 bool
+
+	// TProxyExcludeOutboundIPv6CIDRs is the list of outbound IPv6 CIDRs to exclude from
+	// traffic redirection via the redirect-traffic-ipv6 command.
+	TProxyExcludeOutboundIPv6CIDRs []string
+
+	// ConsulDNSClusterIPv6 is the IPv6 address of the Consul DNS Service on clusters
+	// whose primary address family is IPv6.
+	ConsulDNSClusterIPv6 string
+
 	// MultiPort determines whether this is a multi port Pod, which configures the init container to be specific to one
 	// of the services on the multi port Pod.
 	MultiPort bool
@@ -86,9 +100,20 @@ type initContainerCommandData struct {
 	// multi port Pod.
 	BearerTokenFile string
 
+	// AWSIAMLogin configures connect-init to log in with the AWS credentials of the
+	// Pod instead of the service account token in BearerTokenFile.
+	AWSIAMLogin               bool
+	AWSSTSRegion              string
+	AWSSTSEndpoint            string
+	AWSIAMServerIDHeaderValue string
+
 	// ConsulAPITimeout is the duration that the consul API client will
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
+
+	// MetricsFile is the file on the shared volume that connect-init writes
+	// its metrics to for the consul-sidecar to serve, if set.
+	MetricsFile string
 }
 
 // initCopyContainer returns the init container spec for the copy container which places
@@ -118,6 +143,7 @@ func (h *Handler) initCopyContainer() corev1.Container {
 			ReadOnlyRootFilesystem: pointerToBool(true),
 		}
 	}
+	container.SecurityContext = h.restrictSecurityContext(container.SecurityContext)
 	return container
 }
 
@@ -148,6 +174,10 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 
 	multiPort := mpi.serviceName != ""
 
+	excludeUIDs := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod)
+	excludeUIDs = append(excludeUIDs, excludedInitContainerUIDs(pod)...)
+	excludeUIDs = append(excludeUIDs, h.jobWatcherUIDs(pod)...)
+
 	data := initContainerCommandData{
 		AuthMethod:                 h.AuthMethod,
 		ConsulPartition:            h.ConsulPartition,
@@ -158,7 +188,7 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		TProxyExcludeInboundPorts:  splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundPorts, pod),
 		TProxyExcludeOutboundPorts: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, pod),
 		TProxyExcludeOutboundCIDRs: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, pod),
-		TProxyExcludeUIDs:          splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod),
+		TProxyExcludeUIDs:          excludeUIDs,
 		ConsulDNSClusterIP:         consulDNSClusterIP,
 		EnvoyUID:                   envoyUserAndGroupID,
 		MultiPort:                  multiPort,
@@ -166,6 +196,25 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		ConsulAPITimeout:           h.ConsulAPITimeout,
 	}
 
+	if tproxyEnabled && h.EnableIPv6 {
+		// The IPv6 addresses can only be redirected with ip6tables, which
+		// consul connect redirect-traffic doesn't apply rules with.
+		data.EnableIPv6 = true
+		var ipv4CIDRs []string
+		for _, cidr := range data.TProxyExcludeOutboundCIDRs {
+			if ip, _, err := net.ParseCIDR(cidr); err == nil && ip.To4() == nil {
+				data.TProxyExcludeOutboundIPv6CIDRs = append(data.TProxyExcludeOutboundIPv6CIDRs, cidr)
+			} else {
+				ipv4CIDRs = append(ipv4CIDRs, cidr)
+			}
+		}
+		data.TProxyExcludeOutboundCIDRs = ipv4CIDRs
+		if ipFamily(consulDNSClusterIP) == corev1.IPv6Protocol {
+			data.ConsulDNSClusterIP = ""
+			data.ConsulDNSClusterIPv6 = consulDNSClusterIP
+		}
+	}
+
 	// Create expected volume mounts
 	volMounts := []corev1.VolumeMount{
 		{
@@ -187,15 +236,39 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		} else {
 			data.ServiceAccountName = pod.Spec.ServiceAccountName
 		}
-		// Extract the service account token's volume mount
-		saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, multiPort, mpi.serviceName)
-		if err != nil {
-			return corev1.Container{}, err
+		if h.EnableAWSIAMLogin {
+			data.AWSIAMLogin = true
+			data.AWSSTSRegion = h.AWSSTSRegion
+			data.AWSSTSEndpoint = h.AWSSTSEndpoint
+			data.AWSIAMServerIDHeaderValue = h.AWSIAMServerIDHeaderValue
+		} else if h.useProjectedServiceAccountToken(pod) {
+			// Log in with the projected service account token added by the handler.
+			data.BearerTokenFile = projectedServiceAccountTokenMountPath + "/token"
+			volMounts = append(volMounts, corev1.VolumeMount{
+				Name:      projectedServiceAccountTokenVolumeName,
+				MountPath: projectedServiceAccountTokenMountPath,
+				ReadOnly:  true,
+			})
+		} else {
+			// Extract the service account token's volume mount
+			saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, multiPort, mpi.serviceName)
+			if err != nil {
+				return corev1.Container{}, err
+			}
+			data.BearerTokenFile = bearerTokenFile
+
+			// Append to volume mounts
+			volMounts = append(volMounts, saTokenVolumeMount)
 		}
-		data.BearerTokenFile = bearerTokenFile
+	}
 
-		// Append to volume mounts
-		volMounts = append(volMounts, saTokenVolumeMount)
+	if h.MetricsConfig.shouldRunLifecycleMetrics(pod) {
+		// The metrics of every connect-init container of a multi port pod
+		// are written to their own file.
+		data.MetricsFile = "/consul/connect-inject/connect-init-metrics.prom"
+		if multiPort {
+			data.MetricsFile = fmt.Sprintf("/consul/connect-inject/connect-init-metrics-%s.prom", mpi.serviceName)
+		}
 	}
 
 	// This determines how to configure the consul connect envoy command: what
@@ -262,6 +335,11 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 		Command:      []string{"/bin/sh", "-ec", buf.String()},
 	}
 
+	if h.Datadog.Enabled {
+		// The Envoy bootstrap config that connect-init generates gets the DogStatsD URL from here.
+		container.Env = append(container.Env, corev1.EnvVar{Name: dogstatsdURLEnvVar, Value: h.Datadog.dogstatsdURL()})
+	}
+
 	if tproxyEnabled {
 		// Running consul connect redirect-traffic with iptables
 		// requires both being a root user and having NET_ADMIN capability.
@@ -275,6 +353,8 @@ func (h *Handler) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi
 				Add: []corev1.Capability{netAdminCapability},
 			},
 		}
+	} else {
+		container.SecurityContext = h.restrictSecurityContext(container.SecurityContext)
 	}
 
 	return container, nil
@@ -321,6 +401,119 @@ func consulDNSEnabled(namespace corev1.Namespace, pod corev1.Pod, globalEnabled
 	return globalEnabled, nil
 }
 
+// excludeInitContainersFromRedirection assigns excludedInitContainerUserID to the init containers
+// listed in the annotationTProxyExcludeInitContainers annotation that run after the Consul init
+// containers and don't set a user ID themselves, so that their traffic can be excluded from
+// redirection by user ID. Init containers that run before the Consul init containers have
+// finished aren't redirected, so the listed init containers that are already in the pod when it
+// is injected are left as they are. Init containers added by webhooks that run after the connect
+// injector are placed after the Consul init containers, and these are assigned the user ID when
+// the webhook is reinvoked.
+//
+// A listed init container that sets its own user ID must use one that is listed in the
+// annotationTProxyExcludeUIDs annotation, since the redirection rules are created before it is
+// added. It returns an error if a listed init container runs as a user that cannot be excluded
+// because it is root, Envoy's or Consul's user, or the user of one of the pod's containers.
+func excludeInitContainersFromRedirection(pod *corev1.Pod) error {
+	names := excludedInitContainerNames(*pod)
+	if len(names) == 0 {
+		return nil
+	}
+
+	// Excluding a container's user would also exclude the container's traffic.
+	for _, container := range pod.Spec.Containers {
+		if containerUID(*pod, container) == excludedInitContainerUserID {
+			return fmt.Errorf("container %q runs as user %d which is reserved for the init containers that are excluded from traffic redirection",
+				container.Name, excludedInitContainerUserID)
+		}
+	}
+
+	// Only the init containers after the last Consul init container are redirected.
+	first := 0
+	for i, c := range pod.Spec.InitContainers {
+		if sliceContains(names, c.Name) && isConsulInitContainer(c) {
+			return fmt.Errorf("init container %q is injected by Consul and cannot be excluded from traffic redirection", c.Name)
+		}
+		if isConsulInitContainer(c) {
+			first = i + 1
+		}
+	}
+	if first == 0 {
+		return nil
+	}
+
+	excludedUIDs := splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, *pod)
+	for i := first; i < len(pod.Spec.InitContainers); i++ {
+		c := pod.Spec.InitContainers[i]
+		if !sliceContains(names, c.Name) {
+			continue
+		}
+		if c.SecurityContext == nil || c.SecurityContext.RunAsUser == nil {
+			if pod.Spec.InitContainers[i].SecurityContext == nil {
+				pod.Spec.InitContainers[i].SecurityContext = &corev1.SecurityContext{}
+			}
+			pod.Spec.InitContainers[i].SecurityContext.RunAsUser = pointerToInt64(excludedInitContainerUserID)
+			continue
+		}
+
+		uid := *c.SecurityContext.RunAsUser
+		switch uid {
+		case excludedInitContainerUserID:
+			continue
+		case rootUserAndGroupID, envoyUserAndGroupID, copyContainerUserAndGroupID:
+			return fmt.Errorf("init container %q runs as user %d which is used by root, Envoy or Consul, so its traffic cannot be excluded from redirection", c.Name, uid)
+		}
+		for _, container := range pod.Spec.Containers {
+			if containerUID(*pod, container) == uid {
+				return fmt.Errorf("init container %q runs as user %d which is also used by container %q, so its traffic cannot be excluded from redirection", c.Name, uid, container.Name)
+			}
+		}
+		if !sliceContains(excludedUIDs, strconv.FormatInt(uid, 10)) {
+			return fmt.Errorf("init container %q runs as user %d which must be listed in the %q annotation for its traffic to be excluded from redirection",
+				c.Name, uid, annotationTProxyExcludeUIDs)
+		}
+	}
+	return nil
+}
+
+// excludedInitContainerUIDs returns the user IDs to exclude from redirection for the init
+// containers listed in the annotationTProxyExcludeInitContainers annotation.
+func excludedInitContainerUIDs(pod corev1.Pod) []string {
+	if len(excludedInitContainerNames(pod)) == 0 {
+		return nil
+	}
+	return []string{strconv.Itoa(excludedInitContainerUserID)}
+}
+
+// excludedInitContainerNames returns the names of the init containers listed in the
+// annotationTProxyExcludeInitContainers annotation.
+func excludedInitContainerNames(pod corev1.Pod) []string {
+	var names []string
+	for _, name := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInitContainers, pod) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isConsulInitContainer returns true if the init container is injected by Consul.
+func isConsulInitContainer(c corev1.Container) bool {
+	return c.Name == InjectInitCopyContainerName || strings.HasPrefix(c.Name, InjectInitContainerName)
+}
+
+// containerUID returns the user ID the container runs as from the container's or the pod's
+// security context, or -1 if neither sets it.
+func containerUID(pod corev1.Pod, container corev1.Container) int64 {
+	if container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil {
+		return *container.SecurityContext.RunAsUser
+	}
+	if pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.RunAsUser != nil {
+		return *pod.Spec.SecurityContext.RunAsUser
+	}
+	return -1
+}
+
 // pointerToInt64 takes an int64 and returns a pointer to it.
 func pointerToInt64(i int64) *int64 {
 	return &i
@@ -358,11 +551,25 @@ export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 {{- end}}
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout={{ .ConsulAPITimeout }} \
+  -termination-message-file=/dev/termination-log \
   {{- if .AuthMethod }}
   -acl-auth-method="{{ .AuthMethod }}" \
   -service-account-name="{{ .ServiceAccountName }}" \
   -service-name="{{ .ServiceName }}" \
+  {{- if .AWSIAMLogin }}
+  -aws-iam-login \
+  {{- if .AWSSTSRegion }}
+  -aws-sts-region="{{ .AWSSTSRegion }}" \
+  {{- end }}
+  {{- if .AWSSTSEndpoint }}
+  -aws-sts-endpoint="{{ .AWSSTSEndpoint }}" \
+  {{- end }}
+  {{- if .AWSIAMServerIDHeaderValue }}
+  -aws-iam-server-id-header-value="{{ .AWSIAMServerIDHeaderValue }}" \
+  {{- end }}
+  {{- else }}
   -bearer-token-file={{ .BearerTokenFile }} \
+  {{- end }}
   {{- if .MultiPort }}
   -acl-token-sink=/consul/connect-inject/acl-token-{{ .ServiceName }} \
   {{- end }}
@@ -383,6 +590,9 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -service-name="{{ .ServiceName }}" \
   {{- end }}
   {{- end }}
+  {{- if .MetricsFile }}
+  -metrics-file={{ .MetricsFile }} \
+  {{- end }}
   {{- if .ConsulPartition }}
   -partition="{{ .ConsulPartition }}" \
   {{- end }}
@@ -455,4 +665,40 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -proxy-id="$(cat /consul/connect-inject/proxyid)" \
   -proxy-uid={{ .EnvoyUID }}
 {{- end }}
+
+
+{{- if .EnableIPv6 }}
+{{- /* The newline below is intentional to allow extra space
+       in the rendered template between this and the previous commands. */}}
+
+# Apply traffic redirection rules for IPv6 traffic.
+consul-k8s-control-plane redirect-traffic-ipv6 \
+  -consul-api-timeout={{ .ConsulAPITimeout }} \
+  {{- if .AuthMethod }}
+  -token-file="/consul/connect-inject/acl-token" \
+  {{- end }}
+  {{- if .ConsulPartition }}
+  -partition="{{ .ConsulPartition }}" \
+  {{- end }}
+  {{- if .ConsulNamespace }}
+  -namespace="{{ .ConsulNamespace }}" \
+  {{- end }}
+  {{- if .ConsulDNSClusterIPv6 }}
+  -consul-dns-ip="{{ .ConsulDNSClusterIPv6 }}" \
+  {{- end }}
+  {{- range .TProxyExcludeInboundPorts }}
+  -exclude-inbound-port="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeOutboundPorts }}
+  -exclude-outbound-port="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeOutboundIPv6CIDRs }}
+  -exclude-outbound-cidr="{{ . }}" \
+  {{- end }}
+  {{- range .TProxyExcludeUIDs }}
+  -exclude-uid="{{ . }}" \
+  {{- end }}
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid={{ .EnvoyUID }}
+{{- end }}
 `
@@ -64,6 +64,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=0s \
+  -termination-message-file=/dev/termination-log \
 
 # Generate the envoy bootstrap code
 /consul/connect-inject/consul connect envoy \
@@ -94,6 +95,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="an-auth-method" \
   -service-account-name="a-service-account-name" \
   -service-name="web" \
@@ -320,6 +322,167 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 	}
 }
 
+func TestHandlerContainerInit_transparentProxyExcludeInitContainers(t *testing.T) {
+	consulInitContainers := []corev1.Container{{Name: InjectInitCopyContainerName}, {Name: InjectInitContainerName}}
+	withConsulInitContainers := func(before []corev1.Container, after ...corev1.Container) []corev1.Container {
+		containers := append([]corev1.Container{}, before...)
+		containers = append(containers, consulInitContainers...)
+		return append(containers, after...)
+	}
+
+	cases := map[string]struct {
+		initContainers     []corev1.Container
+		podSecurityContext *corev1.PodSecurityContext
+		annotation         string
+		excludeUIDs        string
+		expectedUIDs       []int64
+		expErr             string
+	}{
+		"init containers before the Consul init containers are left as they are": {
+			initContainers: withConsulInitContainers([]corev1.Container{{Name: "migrate"}}),
+			annotation:     "migrate",
+			expectedUIDs:   []int64{-1, -1, -1},
+		},
+		"init container after the Consul init containers is assigned a user ID": {
+			initContainers: withConsulInitContainers(nil, corev1.Container{Name: "vault-agent-init"}, corev1.Container{Name: "other"}),
+			annotation:     "vault-agent-init",
+			expectedUIDs:   []int64{-1, -1, excludedInitContainerUserID, -1},
+		},
+		"init container user ID overrides the pod's user ID": {
+			initContainers:     withConsulInitContainers(nil, corev1.Container{Name: "vault-agent-init"}),
+			podSecurityContext: &corev1.PodSecurityContext{RunAsUser: pointerToInt64(1000)},
+			annotation:         "vault-agent-init",
+			expectedUIDs:       []int64{-1, -1, excludedInitContainerUserID},
+		},
+		"init container with its own excluded user ID keeps it": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(100)}}),
+			annotation:   "vault-agent-init",
+			excludeUIDs:  "100",
+			expectedUIDs: []int64{-1, -1, 100},
+		},
+		"init container with its own user ID that isn't excluded": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(100)}}),
+			annotation: "vault-agent-init",
+			expErr:     `init container "vault-agent-init" runs as user 100 which must be listed in the "consul.hashicorp.com/transparent-proxy-exclude-uids" annotation for its traffic to be excluded from redirection`,
+		},
+		"init container runs as root": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(0)}}),
+			annotation:  "vault-agent-init",
+			excludeUIDs: "0",
+			expErr:      `init container "vault-agent-init" runs as user 0 which is used by root, Envoy or Consul, so its traffic cannot be excluded from redirection`,
+		},
+		"init container runs as Envoy": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(envoyUserAndGroupID)}}),
+			annotation: "vault-agent-init",
+			expErr:     `init container "vault-agent-init" runs as user 5995 which is used by root, Envoy or Consul, so its traffic cannot be excluded from redirection`,
+		},
+		"init container runs as the copy container": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(copyContainerUserAndGroupID)}}),
+			annotation: "vault-agent-init",
+			expErr:     `init container "vault-agent-init" runs as user 5996 which is used by root, Envoy or Consul, so its traffic cannot be excluded from redirection`,
+		},
+		"init container shares its user ID with a container": {
+			initContainers: withConsulInitContainers(nil,
+				corev1.Container{Name: "vault-agent-init", SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(1000)}}),
+			podSecurityContext: &corev1.PodSecurityContext{RunAsUser: pointerToInt64(1000)},
+			annotation:         "vault-agent-init",
+			excludeUIDs:        "1000",
+			expErr:             `init container "vault-agent-init" runs as user 1000 which is also used by container "web", so its traffic cannot be excluded from redirection`,
+		},
+		"container runs as the excluded user ID": {
+			initContainers:     withConsulInitContainers(nil),
+			podSecurityContext: &corev1.PodSecurityContext{RunAsUser: pointerToInt64(excludedInitContainerUserID)},
+			annotation:         "vault-agent-init",
+			expErr:             `container "web" runs as user 5997 which is reserved for the init containers that are excluded from traffic redirection`,
+		},
+		"injected init container cannot be excluded": {
+			initContainers: withConsulInitContainers(nil),
+			annotation:     InjectInitCopyContainerName,
+			expErr:         `init container "copy-consul-bin" is injected by Consul and cannot be excluded from traffic redirection`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := minimal()
+			pod.Annotations[annotationTProxyExcludeInitContainers] = c.annotation
+			if c.excludeUIDs != "" {
+				pod.Annotations[annotationTProxyExcludeUIDs] = c.excludeUIDs
+			}
+			pod.Spec.InitContainers = c.initContainers
+			pod.Spec.SecurityContext = c.podSecurityContext
+
+			err := excludeInitContainersFromRedirection(pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var uids []int64
+			for _, initContainer := range pod.Spec.InitContainers {
+				if initContainer.SecurityContext != nil && initContainer.SecurityContext.RunAsUser != nil {
+					uids = append(uids, *initContainer.SecurityContext.RunAsUser)
+				} else {
+					uids = append(uids, -1)
+				}
+			}
+			require.Equal(t, c.expectedUIDs, uids)
+		})
+	}
+}
+
+func TestHandlerContainerInit_transparentProxyExcludeInitContainersUIDs(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		expectedCmd string
+	}{
+		"no init containers are excluded": {
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+		"init containers are excluded": {
+			annotations: map[string]string{annotationTProxyExcludeInitContainers: "vault-agent-init"},
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-uid="5997" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+		"init containers and user IDs are excluded": {
+			annotations: map[string]string{
+				annotationTProxyExcludeInitContainers: "vault-agent-init",
+				annotationTProxyExcludeUIDs:           "100",
+			},
+			expectedCmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-uid="100" \
+  -exclude-uid="5997" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				EnableTransparentProxy: true,
+				ConsulAPITimeout:       5 * time.Second,
+			}
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+
+			container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			require.Contains(t, strings.Join(container.Command, " "), c.expectedCmd)
+		})
+	}
+}
+
 func TestHandlerContainerInit_consulDNS(t *testing.T) {
 	cases := map[string]struct {
 		globalEnabled       bool
@@ -489,6 +652,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -consul-service-namespace="default" \
 
 # Generate the envoy bootstrap code
@@ -514,6 +678,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -partition="default" \
   -consul-service-namespace="default" \
 
@@ -541,6 +706,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -consul-service-namespace="non-default" \
 
 # Generate the envoy bootstrap code
@@ -566,6 +732,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -partition="non-default-part" \
   -consul-service-namespace="non-default" \
 
@@ -594,6 +761,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
   -service-name="" \
@@ -629,6 +797,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
   -service-name="" \
@@ -663,6 +832,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -consul-service-namespace="default" \
 
 # Generate the envoy bootstrap code
@@ -695,6 +865,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -partition="default" \
   -consul-service-namespace="non-default" \
 
@@ -733,6 +904,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
   -service-name="web" \
@@ -845,6 +1017,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -multiport=true \
   -proxy-id-file=/consul/connect-inject/proxyid-web \
   -service-name="web" \
@@ -860,6 +1033,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -multiport=true \
   -proxy-id-file=/consul/connect-inject/proxyid-web-admin \
   -service-name="web-admin" \
@@ -896,6 +1070,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="auth-method" \
   -service-account-name="web" \
   -service-name="web" \
@@ -916,6 +1091,7 @@ export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
 export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="auth-method" \
   -service-account-name="web-admin" \
   -service-name="web-admin" \
@@ -984,6 +1160,7 @@ func TestHandlerContainerInit_authMethod(t *testing.T) {
 	require.Contains(actual, `
 consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
   -consul-api-timeout=5s \
+  -termination-message-file=/dev/termination-log \
   -acl-auth-method="release-name-consul-k8s-auth-method"`)
 	require.Contains(actual, `
 # Generate the envoy bootstrap code
@@ -993,6 +1170,141 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
   -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`)
 }
 
+// If projected service account tokens are enabled, connect-init should log in
+// with the projected token instead of the pod's default service account token.
+func TestHandlerContainerInit_authMethodProjectedServiceAccountToken(t *testing.T) {
+	require := require.New(t)
+	h := Handler{
+		AuthMethod:                             "release-name-consul-k8s-auth-method",
+		EnableProjectedServiceAccountToken:     true,
+		ProjectedServiceAccountTokenExpiration: time.Hour,
+		ConsulAPITimeout:                       5 * time.Second,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "foo",
+			},
+		},
+
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+			ServiceAccountName: "foo",
+		},
+	}
+	container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(err)
+	actual := strings.Join(container.Command, " ")
+	require.Contains(actual, `-bearer-token-file=/consul/connect-inject-service-account/token`)
+	require.Contains(container.VolumeMounts, corev1.VolumeMount{
+		Name:      projectedServiceAccountTokenVolumeName,
+		MountPath: projectedServiceAccountTokenMountPath,
+		ReadOnly:  true,
+	})
+
+	volume := h.projectedServiceAccountTokenVolume()
+	require.Equal(projectedServiceAccountTokenVolumeName, volume.Name)
+	require.Equal(&corev1.ServiceAccountTokenProjection{
+		ExpirationSeconds: pointerToInt64(3600),
+		Path:              "token",
+	}, volume.Projected.Sources[0].ServiceAccountToken)
+}
+
+// If AWS IAM login is enabled, connect-init should log in with the AWS
+// credentials of the pod and no service account token should be mounted.
+func TestHandlerContainerInit_authMethodAWSIAMLogin(t *testing.T) {
+	require := require.New(t)
+	h := Handler{
+		AuthMethod:                         "release-name-consul-aws-iam-auth-method",
+		EnableAWSIAMLogin:                  true,
+		AWSSTSRegion:                       "us-west-2",
+		AWSIAMServerIDHeaderValue:          "consul.example.com",
+		EnableProjectedServiceAccountToken: true,
+		ConsulAPITimeout:                   5 * time.Second,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "foo",
+			},
+		},
+
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+			ServiceAccountName: "foo",
+		},
+	}
+	container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(err)
+	actual := strings.Join(container.Command, " ")
+	require.Contains(actual, `
+  -acl-auth-method="release-name-consul-aws-iam-auth-method" \
+  -service-account-name="foo" \
+  -service-name="foo" \
+  -aws-iam-login \
+  -aws-sts-region="us-west-2" \
+  -aws-iam-server-id-header-value="consul.example.com" \`)
+	require.NotContains(actual, "-bearer-token-file")
+	require.Len(container.VolumeMounts, 1)
+}
+
+// If lifecycle metrics are enabled, connect-init should write its metrics to
+// the shared volume, to a file per service for multi port pods.
+func TestHandlerContainerInit_lifecycleMetrics(t *testing.T) {
+	cases := map[string]struct {
+		mpi           multiPortInfo
+		expMetricFile string
+	}{
+		"single port": {
+			expMetricFile: "/consul/connect-inject/connect-init-metrics.prom",
+		},
+		"multi port": {
+			mpi:           multiPortInfo{serviceIndex: 1, serviceName: "web-admin"},
+			expMetricFile: "/consul/connect-inject/connect-init-metrics-web-admin.prom",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				ConsulAPITimeout: 5 * time.Second,
+				MetricsConfig:    MetricsConfig{EnableLifecycleMetrics: true},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationService: "web",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+						},
+					},
+				},
+			}
+			container, err := h.containerInit(testNS, *pod, c.mpi)
+			require.NoError(t, err)
+			require.Contains(t, strings.Join(container.Command, " "), fmt.Sprintf("-metrics-file=%s \\", c.expMetricFile))
+
+			// connect-init doesn't write its metrics in Job pods, which don't
+			// run the consul-sidecar.
+			pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "job"}}
+			container, err = h.containerInit(testNS, *pod, c.mpi)
+			require.NoError(t, err)
+			require.NotContains(t, strings.Join(container.Command, " "), "-metrics-file")
+		})
+	}
+}
+
 // If Consul CA cert is set,
 // Consul addresses should use HTTPS
 // and CA cert should be set as env variable.
@@ -4,9 +4,19 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
-// volumeName is the name of the volume that is created to store the
-// Consul Connect injection data.
-const volumeName = "consul-connect-inject-data"
+const (
+	// volumeName is the name of the volume that is created to store the
+	// Consul Connect injection data.
+	volumeName = "consul-connect-inject-data"
+
+	// projectedServiceAccountTokenVolumeName is the name of the volume that holds the
+	// projected service account token connect-init uses to log in.
+	projectedServiceAccountTokenVolumeName = "consul-connect-inject-service-account-token"
+
+	// projectedServiceAccountTokenMountPath is where the projected service account
+	// token volume is mounted in the connect-init container.
+	projectedServiceAccountTokenMountPath = "/consul/connect-inject-service-account"
+)
 
 // containerVolume returns the volume data to add to the pod. This volume
 // is used for shared data between containers.
@@ -18,3 +28,37 @@ func (h *Handler) containerVolume() corev1.Volume {
 		},
 	}
 }
+
+// useProjectedServiceAccountToken returns true if connect-init should log in with a
+// projected service account token. Multi port pods log in with a service account per
+// service, which can't be projected, so they always use the service account secrets.
+// Pods that log in with AWS IAM don't need a service account token.
+func (h *Handler) useProjectedServiceAccountToken(pod corev1.Pod) bool {
+	return h.AuthMethod != "" && h.EnableProjectedServiceAccountToken && !h.EnableAWSIAMLogin && len(h.annotatedServiceNames(pod)) <= 1
+}
+
+// projectedServiceAccountTokenVolume returns the volume that projects an expiring
+// token for the pod's service account. The token is issued for the API server's
+// default audience, which the Kubernetes auth method validates it against. The
+// kubelet refreshes the token before it expires.
+func (h *Handler) projectedServiceAccountTokenVolume() corev1.Volume {
+	var expirationSeconds *int64
+	if h.ProjectedServiceAccountTokenExpiration > 0 {
+		expirationSeconds = pointerToInt64(int64(h.ProjectedServiceAccountTokenExpiration.Seconds()))
+	}
+	return corev1.Volume{
+		Name: projectedServiceAccountTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							ExpirationSeconds: expirationSeconds,
+							Path:              "token",
+						},
+					},
+				},
+			},
+		},
+	}
+}
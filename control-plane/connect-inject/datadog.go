@@ -0,0 +1,140 @@
+package connectinject
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The labels of Datadog unified service tagging, which the Datadog Agent
+	// tags the metrics, traces and logs of a pod with.
+	labelDatadogEnv     = "tags.datadoghq.com/env"
+	labelDatadogService = "tags.datadoghq.com/service"
+	labelDatadogVersion = "tags.datadoghq.com/version"
+
+	// labelAppVersion is the recommended Kubernetes label of the version of
+	// an application, which is the default of its Datadog version tag.
+	labelAppVersion = "app.kubernetes.io/version"
+
+	envoyDogstatsdURL = "envoy_dogstatsd_url"
+	envoyStatsTags    = "envoy_stats_tags"
+
+	// dogstatsdURLEnvVar is the environment variable of the connect-init
+	// containers with the URL of DogStatsD. The envoy_dogstatsd_url of the
+	// proxies refers to it so that the URL can have the IP of the node, which
+	// `consul connect envoy` resolves when it generates the Envoy bootstrap
+	// config in the connect-init container.
+	dogstatsdURLEnvVar = "DD_DOGSTATSD_URL"
+
+	// datadogSocketVolumeName is the name of the volume with the DogStatsD
+	// socket of the Datadog Agent on the node.
+	datadogSocketVolumeName = "consul-connect-inject-dsd-socket"
+
+	// datadogEntityIDTag is the tag that the Datadog Agent detects the pod
+	// that sent a metric over UDP from, its origin, with. Its value is the
+	// UID of the pod.
+	datadogEntityIDTag = "dd.internal.entity_id"
+)
+
+// DatadogConfig configures the Envoy sidecars to send their metrics to the
+// DogStatsD server of the Datadog Agent on their node and the injected pods
+// to be tagged with Datadog unified service tagging.
+type DatadogConfig struct {
+	// Enabled enables the Datadog integration.
+	Enabled bool
+	// DogStatsDSocketPath is the path of the DogStatsD Unix domain socket on
+	// the nodes. If it's empty, the metrics are sent over UDP to
+	// DogStatsDPort on the IP of the node.
+	DogStatsDSocketPath string
+	// DogStatsDPort is the UDP port of DogStatsD on the nodes.
+	DogStatsDPort int
+	// Env is the env tag of the injected pods that don't have the
+	// tags.datadoghq.com/env label. If it's empty, the label isn't added.
+	Env string
+	// EnableIPv6 brackets the IP of the node in the DogStatsD URL because
+	// it can be an IPv6 address.
+	EnableIPv6 bool
+}
+
+// dogstatsdURL returns the DogStatsD URL of the Envoy bootstrap config. The
+// IP of the node is expanded from the HOST_IP environment variable of the
+// connect-init container.
+func (c DatadogConfig) dogstatsdURL() string {
+	if c.DogStatsDSocketPath != "" {
+		return "unix://" + c.DogStatsDSocketPath
+	}
+	if c.EnableIPv6 {
+		return fmt.Sprintf("udp://[$(HOST_IP)]:%d", c.DogStatsDPort)
+	}
+	return fmt.Sprintf("udp://$(HOST_IP):%d", c.DogStatsDPort)
+}
+
+// addDatadogLabels adds the labels of Datadog unified service tagging that pod
+// doesn't have yet. The service tag is the Consul service of the pod if it's
+// set with the connect-service annotation, and the version tag the
+// app.kubernetes.io/version label of the pod.
+func (h *Handler) addDatadogLabels(pod *corev1.Pod) {
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	setDefault := func(key, value string) {
+		if _, ok := pod.Labels[key]; !ok && value != "" {
+			pod.Labels[key] = value
+		}
+	}
+	setDefault(labelDatadogEnv, h.Datadog.Env)
+	// Multi port pods have several services, so none of them is the service
+	// of the pod.
+	if service := pod.Annotations[annotationService]; !strings.Contains(service, ",") {
+		setDefault(labelDatadogService, strings.TrimSpace(service))
+	}
+	setDefault(labelDatadogVersion, pod.Labels[labelAppVersion])
+}
+
+// datadogSocketVolume returns the volume of the directory of the DogStatsD
+// socket on the node. It's created if it doesn't exist so that the pods start
+// before the Datadog Agent.
+func (h *Handler) datadogSocketVolume() corev1.Volume {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	return corev1.Volume{
+		Name: datadogSocketVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: filepath.Dir(h.Datadog.DogStatsDSocketPath),
+				Type: &hostPathType,
+			},
+		},
+	}
+}
+
+// datadogSocketVolumeMount returns the volume mount of the DogStatsD socket
+// of the Envoy sidecars.
+func (h *Handler) datadogSocketVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      datadogSocketVolumeName,
+		MountPath: filepath.Dir(h.Datadog.DogStatsDSocketPath),
+		ReadOnly:  true,
+	}
+}
+
+// datadogStatsTags returns the envoy_stats_tags of the proxy of the pod for
+// the service. They're the unified service tags of the pod, with the service
+// as the default service tag, and the entity ID tag that the Datadog Agent
+// detects the origin of the metrics sent over UDP with.
+func datadogStatsTags(pod corev1.Pod, service string) []string {
+	tags := []string{fmt.Sprintf("%s=%s", datadogEntityIDTag, pod.UID)}
+	if env := pod.Labels[labelDatadogEnv]; env != "" {
+		tags = append(tags, "env="+env)
+	}
+	if s := pod.Labels[labelDatadogService]; s != "" {
+		service = s
+	}
+	tags = append(tags, "service="+service)
+	if version := pod.Labels[labelDatadogVersion]; version != "" {
+		tags = append(tags, "version="+version)
+	}
+	return tags
+}
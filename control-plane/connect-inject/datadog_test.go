@@ -0,0 +1,175 @@
+package connectinject
+
+import (
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDatadogConfig_dogstatsdURL(t *testing.T) {
+	require.Equal(t, "unix:///var/run/datadog/dsd.socket",
+		DatadogConfig{DogStatsDSocketPath: "/var/run/datadog/dsd.socket", DogStatsDPort: 8125}.dogstatsdURL())
+	require.Equal(t, "udp://$(HOST_IP):8125", DatadogConfig{DogStatsDPort: 8125}.dogstatsdURL())
+	require.Equal(t, "udp://[$(HOST_IP)]:8125", DatadogConfig{DogStatsDPort: 8125, EnableIPv6: true}.dogstatsdURL())
+}
+
+func TestHandlerAddDatadogLabels(t *testing.T) {
+	cases := map[string]struct {
+		env       string
+		labels    map[string]string
+		service   string
+		expLabels map[string]string
+	}{
+		"no tags": {
+			expLabels: map[string]string{},
+		},
+		"env, service and version": {
+			env:     "prod",
+			labels:  map[string]string{labelAppVersion: "1.2.0"},
+			service: "web",
+			expLabels: map[string]string{
+				labelAppVersion:     "1.2.0",
+				labelDatadogEnv:     "prod",
+				labelDatadogService: "web",
+				labelDatadogVersion: "1.2.0",
+			},
+		},
+		"existing labels are kept": {
+			env: "prod",
+			labels: map[string]string{
+				labelDatadogEnv:     "staging",
+				labelDatadogService: "frontend",
+			},
+			service: "web",
+			expLabels: map[string]string{
+				labelDatadogEnv:     "staging",
+				labelDatadogService: "frontend",
+			},
+		},
+		"multi port pod": {
+			service:   "web,web-admin",
+			expLabels: map[string]string{},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      c.labels,
+					Annotations: map[string]string{},
+				},
+			}
+			if c.service != "" {
+				pod.Annotations[annotationService] = c.service
+			}
+			h := Handler{Datadog: DatadogConfig{Enabled: true, Env: c.env}}
+			h.addDatadogLabels(pod)
+			require.Equal(t, c.expLabels, pod.Labels)
+		})
+	}
+}
+
+// Test that the connect-init container has the DogStatsD URL and the Envoy
+// sidecar the DogStatsD socket.
+func TestHandlerContainers_withDatadog(t *testing.T) {
+	cases := map[string]struct {
+		socketPath string
+		expURL     string
+		expMount   bool
+	}{
+		"UDS": {
+			socketPath: "/var/run/datadog/dsd.socket",
+			expURL:     "unix:///var/run/datadog/dsd.socket",
+			expMount:   true,
+		},
+		"UDP": {
+			expURL: "udp://$(HOST_IP):8125",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				ImageConsul:    "hashicorp/consul:latest",
+				ImageEnvoy:     "hashicorp/consul-k8s:latest",
+				ImageConsulK8S: "hashicorp/consul-k8s:9.9.9",
+				Datadog: DatadogConfig{
+					Enabled:             true,
+					DogStatsDSocketPath: c.socketPath,
+					DogStatsDPort:       8125,
+				},
+			}
+
+			initContainer, err := h.containerInit(testNS, *minimal(), multiPortInfo{})
+			require.NoError(t, err)
+			require.Contains(t, initContainer.Env, corev1.EnvVar{Name: dogstatsdURLEnvVar, Value: c.expURL})
+
+			sidecar, err := h.envoySidecar(testNS, *minimal(), multiPortInfo{})
+			require.NoError(t, err)
+			mount := corev1.VolumeMount{Name: datadogSocketVolumeName, MountPath: "/var/run/datadog", ReadOnly: true}
+			if c.expMount {
+				require.Contains(t, sidecar.VolumeMounts, mount)
+				require.Equal(t, "/var/run/datadog", h.datadogSocketVolume().HostPath.Path)
+			} else {
+				require.NotContains(t, sidecar.VolumeMounts, mount)
+			}
+		})
+	}
+}
+
+// Test that the proxy registration sends the metrics of Envoy to DogStatsD
+// with the unified service tags of the pod.
+func TestCreateServiceRegistrations_withDatadog(t *testing.T) {
+	pod := createPod("test-pod", "1.2.3.4", true, true)
+	pod.UID = "c1e4e4a4-2d4d-4a0e-9c55-c77c0b8a5b8a"
+	pod.Labels[labelDatadogEnv] = "prod"
+	pod.Labels[labelDatadogVersion] = "1.2.0"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP: "1.2.3.4",
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      pod.Name,
+							Namespace: pod.Namespace,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build()
+
+	epCtrl := EndpointsController{
+		Client:  fakeClient,
+		Datadog: DatadogConfig{Enabled: true, DogStatsDPort: 8125},
+		Log:     logrtest.TestLogger{T: t},
+	}
+	_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "$DD_DOGSTATSD_URL", proxyServiceRegistration.Proxy.Config[envoyDogstatsdURL])
+	require.Equal(t, []string{
+		"dd.internal.entity_id=c1e4e4a4-2d4d-4a0e-9c55-c77c0b8a5b8a",
+		"env=prod",
+		"service=test-service",
+		"version=1.2.0",
+	}, proxyServiceRegistration.Proxy.Config[envoyStatsTags])
+
+	// Datadog isn't configured by default.
+	epCtrl.Datadog = DatadogConfig{}
+	_, proxyServiceRegistration, err = epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+	require.NotContains(t, proxyServiceRegistration.Proxy.Config, envoyDogstatsdURL)
+	require.NotContains(t, proxyServiceRegistration.Proxy.Config, envoyStatsTags)
+}
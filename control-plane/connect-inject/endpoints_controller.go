@@ -13,9 +13,12 @@ import (
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/sharding"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tracing"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -46,6 +50,11 @@ const (
 	// in Consul. Note: This value should not be changed without a corresponding change in Consul.
 	clusterIPTaggedAddressName = "virtual"
 
+	// lanIPv4TaggedAddressName and lanIPv6TaggedAddressName are the keys for the tagged addresses
+	// to store the IPv4 and IPv6 pod IPs of the service instances of dual-stack pods in Consul.
+	lanIPv4TaggedAddressName = "lan_ipv4"
+	lanIPv6TaggedAddressName = "lan_ipv6"
+
 	// exposedPathsLivenessPortsRangeStart is the start of the port range that we will use as
 	// the ListenerPort for the Expose configuration of the proxy registration for a liveness probe.
 	exposedPathsLivenessPortsRangeStart = 20300
@@ -106,6 +115,24 @@ type EndpointsController struct {
 	// TProxyOverwriteProbes controls whether the endpoints controller should expose pod's HTTP probes
 	// via Envoy proxy.
 	TProxyOverwriteProbes bool
+	// EnableLocality controls whether the service instances are registered
+	// with the locality of the Kubernetes node of their pod, i.e. its
+	// topology.kubernetes.io/region and topology.kubernetes.io/zone labels,
+	// so that Consul can prioritize the instances in the same locality.
+	EnableLocality bool
+	// MetadataRules copy labels and annotations of the pods and labels of
+	// their nodes into the meta of their service instances.
+	MetadataRules []MetadataRule
+	// RegistrationIPFamily is the address family of the pod IPs that the
+	// service instances are registered with on dual-stack clusters. If it's
+	// set, the service instances of dual-stack pods are also registered with
+	// lan_ipv4 and lan_ipv6 tagged addresses. If it's empty, the primary pod
+	// IP is registered.
+	RegistrationIPFamily corev1.IPFamily
+	// ListenerIPFamily is the address family of the local addresses that the
+	// sidecar proxies listen on for upstreams and metrics, and forward inbound
+	// traffic to the application on. It defaults to IPv4.
+	ListenerIPFamily corev1.IPFamily
 	// AuthMethod is the name of the Kubernetes Auth Method that
 	// was used to login with Consul. The Endpoints controller
 	// will delete any tokens associated with this auth method
@@ -115,8 +142,24 @@ type EndpointsController struct {
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
 
+	// Shards, if set, limits the controller to the Endpoints in the
+	// namespaces of the shards that this replica owns so that all the
+	// replicas register service instances at the same time.
+	Shards *sharding.Coordinator
+
 	MetricsConfig MetricsConfig
-	Log           logr.Logger
+	// Datadog configures the proxies to send their metrics to the DogStatsD
+	// server of the Datadog Agent on their node.
+	Datadog DatadogConfig
+	// TracingZipkinAddress is the host:port of the Zipkin collector that the
+	// Envoy sidecars send their traces to. If empty, tracing isn't configured.
+	TracingZipkinAddress string
+	Log                  logr.Logger
+	// Recorder records a Kubernetes event on the pods whose service instances
+	// can't be registered or whose connect-init container failed, and on the
+	// Services whose former service instances can't be deregistered. If it's
+	// nil, no events are recorded.
+	Recorder record.EventRecorder
 
 	Scheme *runtime.Scheme
 	context.Context
@@ -124,7 +167,8 @@ type EndpointsController struct {
 
 // Reconcile reads the state of an Endpoints object for a Kubernetes Service and reconciles Consul services which
 // correspond to the Kubernetes Service. These events are driven by changes to the Pods backing the Kube service.
-func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+// The reconcile is traced with a span that the Kubernetes reads and Consul calls of the reconcile are children of.
+func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	var errs error
 	var serviceEndpoints corev1.Endpoints
 
@@ -133,7 +177,17 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	err := r.Client.Get(ctx, req.NamespacedName, &serviceEndpoints)
+	ctx, span := tracing.Start(ctx, "EndpointsController.Reconcile",
+		attribute.String("k8s.namespace", req.Namespace),
+		attribute.String("k8s.name", req.Name))
+	defer func() {
+		span.SetAttributes(attribute.Bool("reconcile.requeue", result.Requeue || err != nil))
+		tracing.End(span, err)
+	}()
+
+	getCtx, getSpan := tracing.Start(ctx, "get Endpoints")
+	err = r.Client.Get(getCtx, req.NamespacedName, &serviceEndpoints)
+	tracing.End(getSpan, client.IgnoreNotFound(err))
 
 	// endpointPods holds a set of all pods this endpoints object is currently pointing to.
 	// We use this later when we reconcile ACL tokens to decide whether an ACL token in Consul
@@ -146,20 +200,26 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		// Deregister all instances in Consul for this service. The function deregisterServiceOnAllAgents handles
 		// the case where the Consul service name is different from the Kubernetes service name.
 		err = r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
+		if err != nil {
+			r.recordDeregistrationFailure(ctx, req.Name, req.Namespace, err)
+		}
 		return ctrl.Result{}, err
 	} else if err != nil {
-		r.Log.Error(err, "failed to get Endpoints", "name", req.Name, "ns", req.Namespace)
+		r.Log.Error(err, "failed to get Endpoints", "namespace", req.Namespace, "resource", req.Name)
 		return ctrl.Result{}, err
 	}
 
-	r.Log.Info("retrieved", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+	r.Log.Info("retrieved", "namespace", serviceEndpoints.Namespace, "resource", serviceEndpoints.Name)
 
 	// If the endpoints object has the label "consul.hashicorp.com/service-ignore" set to true, deregister all instances in Consul for this service.
 	// It is possible that the endpoints object has never been registered, in which case deregistration is a no-op.
 	if isLabeledIgnore(serviceEndpoints.Labels) {
 		// We always deregister the service to handle the case where a user has registered the service, then added the label later.
-		r.Log.Info("Ignoring endpoint labeled with `consul.hashicorp.com/service-ignore: \"true\"`", "name", req.Name, "namespace", req.Namespace)
+		r.Log.Info("Ignoring endpoint labeled with `consul.hashicorp.com/service-ignore: \"true\"`", "namespace", req.Namespace, "resource", req.Name)
 		err = r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
+		if err != nil {
+			r.recordDeregistrationFailure(ctx, req.Name, req.Namespace, err)
+		}
 		return ctrl.Result{}, err
 	}
 
@@ -173,7 +233,10 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
 				var pod corev1.Pod
 				objectKey := types.NamespacedName{Name: address.TargetRef.Name, Namespace: address.TargetRef.Namespace}
-				if err := r.Client.Get(ctx, objectKey, &pod); err != nil {
+				getCtx, getSpan := tracing.Start(ctx, "get Pod", attribute.String("k8s.pod.name", address.TargetRef.Name))
+				err := r.Client.Get(getCtx, objectKey, &pod)
+				tracing.End(getSpan, err)
+				if err != nil {
 					r.Log.Error(err, "failed to get pod", "name", address.TargetRef.Name)
 					errs = multierror.Append(errs, err)
 					continue
@@ -181,15 +244,24 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 
 				serviceName, ok := pod.Annotations[annotationKubernetesService]
 				if ok && serviceEndpoints.Name != serviceName {
-					r.Log.Info("ignoring endpoint because it doesn't match explicit service annotation", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+					r.Log.Info("ignoring endpoint because it doesn't match explicit service annotation", "namespace", serviceEndpoints.Namespace, "resource", serviceEndpoints.Name)
 					// deregistration for service instances that don't match the annotation happens later because we don't add this pod to the endpointAddressMap.
 					continue
 				}
 
+				// Pods that have completed, such as the pods of Jobs, are deregistered even
+				// if they are still in the Endpoints object since they will never be ready again.
+				if podCompleted(pod) {
+					r.Log.Info("ignoring endpoint because its pod has completed", "namespace", address.TargetRef.Namespace, "resource", address.TargetRef.Name)
+					continue
+				}
+
 				if hasBeenInjected(pod) {
 					endpointPods.Add(address.TargetRef.Name)
-					if err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
-						r.Log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+					r.recordConnectInitFailure(pod)
+					if err := r.registerServicesAndHealthCheck(ctx, pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
+						r.Log.Error(err, "failed to register services or health check", "namespace", serviceEndpoints.Namespace, "resource", serviceEndpoints.Name)
+						r.recordRegistrationFailure(pod, err)
 						errs = multierror.Append(errs, err)
 					}
 				}
@@ -201,7 +273,8 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	// from Consul. This uses endpointAddressMap which is populated with the addresses in the Endpoints object during
 	// the registration codepath.
 	if err = r.deregisterServiceOnAllAgents(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap); err != nil {
-		r.Log.Error(err, "failed to deregister endpoints on all agents", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+		r.Log.Error(err, "failed to deregister endpoints on all agents", "namespace", serviceEndpoints.Namespace, "resource", serviceEndpoints.Name)
+		r.recordDeregistrationFailure(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, err)
 		errs = multierror.Append(errs, err)
 	}
 
@@ -209,27 +282,39 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 }
 
 func (r *EndpointsController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *EndpointsController) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Endpoints{}).
+	blder, err := r.Shards.Watch(ctrl.NewControllerManagedBy(mgr).For(&corev1.Endpoints{}), mgr, &corev1.Endpoints{})
+	if err != nil {
+		return err
+	}
+	return blder.
 		Watches(
 			&source.Kind{Type: &corev1.Pod{}},
 			handler.EnqueueRequestsFromMapFunc(r.requestsForRunningAgentPods),
 			builder.WithPredicates(predicate.NewPredicateFuncs(r.filterAgentPods)),
-		).Complete(r)
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForConnectInitFailedPods),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.filterConnectInitFailedPods)),
+		).Complete(r.Shards.Reconciler(r))
 }
 
 // registerServicesAndHealthCheck creates Consul registrations for the service and proxy and registers them with Consul.
 // It also upserts a Kubernetes health check for the service based on whether the endpoint address is ready.
-func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool) error {
+func (r *EndpointsController) registerServicesAndHealthCheck(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool) (err error) {
 	podHostIP := pod.Status.HostIP
+	ctx, span := tracing.Start(ctx, "register service instance",
+		attribute.String("k8s.pod.name", pod.Name),
+		attribute.String("consul.agent.address", podHostIP))
+	defer func() { tracing.End(span, err) }()
 
 	if hasBeenInjected(pod) {
 		// Build the endpointAddressMap up for deregistering service instances later.
-		endpointAddressMap[pod.Status.PodIP] = true
+		endpointAddressMap[podAddress(pod, r.RegistrationIPFamily)] = true
 		// Create client for Consul agent local to the pod.
 		client, err := r.remoteConsulClient(podHostIP, r.consulNamespace(pod.Namespace))
 		if err != nil {
@@ -246,9 +331,27 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 			// Get information from the pod to create service instance registrations.
 			serviceRegistration, proxyServiceRegistration, err := r.createServiceRegistrations(pod, serviceEndpoints)
 			if err != nil {
-				r.Log.Error(err, "failed to create service registrations for endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+				r.Log.Error(err, "failed to create service registrations for endpoints", "namespace", serviceEndpoints.Namespace, "resource", serviceEndpoints.Name)
 				return err
 			}
+			var node *corev1.Node
+			if r.EnableLocality || hasNodeLabelRules(r.MetadataRules) {
+				node = &corev1.Node{}
+				if err := r.Client.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+					r.Log.Error(err, "failed to get node of pod", "node", pod.Spec.NodeName)
+					return err
+				}
+			}
+			if r.EnableLocality {
+				serviceRegistration.Locality = nodeLocality(node)
+				proxyServiceRegistration.Locality = serviceRegistration.Locality
+			}
+			for _, registration := range []*api.AgentServiceRegistration{serviceRegistration, proxyServiceRegistration} {
+				if err := copyMetadata(r.MetadataRules, pod, node, registration.Meta); err != nil {
+					r.Log.Error(err, "failed to copy metadata into service registration", "name", registration.Name)
+					return err
+				}
+			}
 
 			// Register the service instance with the local agent.
 			// Note: the order of how we register services is important,
@@ -256,7 +359,7 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 			// because its alias health check depends on the main service existing.
 			r.Log.Info("registering service with Consul", "name", serviceRegistration.Name,
 				"id", serviceRegistration.ID, "agentIP", podHostIP)
-			err = client.Agent().ServiceRegister(serviceRegistration)
+			err = client.Agent().ServiceRegisterOpts(serviceRegistration, api.ServiceRegisterOpts{}.WithContext(ctx))
 			if err != nil {
 				r.Log.Error(err, "failed to register service", "name", serviceRegistration.Name)
 				return err
@@ -264,7 +367,7 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 
 			// Register the proxy service instance with the local agent.
 			r.Log.Info("registering proxy service with Consul", "name", proxyServiceRegistration.Name)
-			err = client.Agent().ServiceRegister(proxyServiceRegistration)
+			err = client.Agent().ServiceRegisterOpts(proxyServiceRegistration, api.ServiceRegisterOpts{}.WithContext(ctx))
 			if err != nil {
 				r.Log.Error(err, "failed to register proxy service", "name", proxyServiceRegistration.Name)
 				return err
@@ -281,7 +384,7 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 		r.Log.Info("updating health check status for service", "name", serviceName, "reason", reason, "status", healthStatus)
 		serviceID := getServiceID(pod, serviceEndpoints)
 		healthCheckID := getConsulHealthCheckID(pod, serviceID)
-		err = r.upsertHealthCheck(pod, client, serviceID, healthCheckID, healthStatus)
+		err = r.upsertHealthCheck(ctx, pod, client, serviceID, healthCheckID, healthStatus)
 		if err != nil {
 			r.Log.Error(err, "failed to update health check status for service", "name", serviceName)
 			return err
@@ -291,10 +394,21 @@ func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, ser
 	return nil
 }
 
+// nodeLocality returns the locality of the Kubernetes node from its topology
+// labels, or nil if the node doesn't have any.
+func nodeLocality(node *corev1.Node) *api.Locality {
+	region := node.Labels[corev1.LabelTopologyRegion]
+	zone := node.Labels[corev1.LabelTopologyZone]
+	if region == "" && zone == "" {
+		return nil
+	}
+	return &api.Locality{Region: region, Zone: zone}
+}
+
 // getServiceCheck will return the health check for this pod and service if it exists.
-func getServiceCheck(client *api.Client, healthCheckID string) (*api.AgentCheck, error) {
+func getServiceCheck(ctx context.Context, client *api.Client, healthCheckID string) (*api.AgentCheck, error) {
 	filter := fmt.Sprintf("CheckID == `%s`", healthCheckID)
-	checks, err := client.Agent().ChecksWithFilter(filter)
+	checks, err := client.Agent().ChecksWithFilterOpts(filter, (&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -305,7 +419,10 @@ func getServiceCheck(client *api.Client, healthCheckID string) (*api.AgentCheck,
 // registerConsulHealthCheck registers a TTL health check for the service on this Agent local to the Pod. This will add
 // the Pod's readiness status, which will mark the service instance healthy/unhealthy for Consul service mesh
 // traffic.
-func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceID, status string) error {
+func registerConsulHealthCheck(ctx context.Context, client *api.Client, consulHealthCheckID, serviceID, status string) error {
+	// The check registration can't be passed a context, so it's traced with
+	// its own span.
+	_, span := tracing.Start(ctx, "register health check", attribute.String("consul.check.id", consulHealthCheckID))
 	// Create a TTL health check in Consul associated with this service and pod.
 	// The TTL time is 100000h which should ensure that the check never fails due to timeout
 	// of the TTL check.
@@ -320,6 +437,7 @@ func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceI
 			FailuresBeforeCritical: 1,
 		},
 	})
+	tracing.End(span, err)
 	if err != nil {
 		// Full error looks like:
 		// Unexpected response code: 500 (ServiceID "consulnamespace/svc-id" does not exist)
@@ -333,9 +451,9 @@ func registerConsulHealthCheck(client *api.Client, consulHealthCheckID, serviceI
 }
 
 // updateConsulHealthCheckStatus updates the consul health check status.
-func (r *EndpointsController) updateConsulHealthCheckStatus(client *api.Client, consulHealthCheckID, status, reason string) error {
+func (r *EndpointsController) updateConsulHealthCheckStatus(ctx context.Context, client *api.Client, consulHealthCheckID, status, reason string) error {
 	r.Log.Info("updating health check", "id", consulHealthCheckID)
-	err := client.Agent().UpdateTTL(consulHealthCheckID, reason, status)
+	err := client.Agent().UpdateTTLOpts(consulHealthCheckID, reason, status, (&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("error updating health check: %w", err)
 	}
@@ -344,28 +462,28 @@ func (r *EndpointsController) updateConsulHealthCheckStatus(client *api.Client,
 
 // upsertHealthCheck checks if the healthcheck exists for the service, and creates it if it doesn't exist, or updates it
 // if it does.
-func (r *EndpointsController) upsertHealthCheck(pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string) error {
+func (r *EndpointsController) upsertHealthCheck(ctx context.Context, pod corev1.Pod, client *api.Client, serviceID, healthCheckID, status string) error {
 	reason := getHealthCheckStatusReason(status, pod.Name, pod.Namespace)
 	// Retrieve the health check that would exist if the service had one registered for this pod.
-	serviceCheck, err := getServiceCheck(client, healthCheckID)
+	serviceCheck, err := getServiceCheck(ctx, client, healthCheckID)
 	if err != nil {
 		return fmt.Errorf("unable to get agent health checks: serviceID=%s, checkID=%s, %s", serviceID, healthCheckID, err)
 	}
 	if serviceCheck == nil {
 		// Create a new health check.
-		err = registerConsulHealthCheck(client, healthCheckID, serviceID, status)
+		err = registerConsulHealthCheck(ctx, client, healthCheckID, serviceID, status)
 		if err != nil {
 			return err
 		}
 
 		// Also update it, the reason this is separate is there is no way to set the Output field of the health check
 		// at creation time, and this is what is displayed on the UI as opposed to the Notes field.
-		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
+		err = r.updateConsulHealthCheckStatus(ctx, client, healthCheckID, status, reason)
 		if err != nil {
 			return err
 		}
 	} else if serviceCheck.Status != status {
-		err = r.updateConsulHealthCheckStatus(client, healthCheckID, status, reason)
+		err = r.updateConsulHealthCheckStatus(ctx, client, healthCheckID, status, reason)
 		if err != nil {
 			return err
 		}
@@ -445,15 +563,17 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		}
 	}
 	tags := consulTags(pod)
+	podIP := podAddress(pod, r.RegistrationIPFamily)
 
 	service := &api.AgentServiceRegistration{
-		ID:        serviceID,
-		Name:      serviceName,
-		Port:      consulServicePort,
-		Address:   pod.Status.PodIP,
-		Meta:      meta,
-		Namespace: r.consulNamespace(pod.Namespace),
-		Tags:      tags,
+		ID:              serviceID,
+		Name:            serviceName,
+		Port:            consulServicePort,
+		Address:         podIP,
+		TaggedAddresses: r.podTaggedAddresses(pod, consulServicePort),
+		Meta:            meta,
+		Namespace:       r.consulNamespace(pod.Namespace),
+		Tags:            tags,
 	}
 
 	proxyServiceName := getProxyServiceName(pod, serviceEndpoints)
@@ -464,7 +584,7 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		Config:                 make(map[string]interface{}),
 	}
 
-	// If metrics are enabled, the proxyConfig should set envoy_prometheus_bind_addr to a listener on 0.0.0.0 on
+	// If metrics are enabled, the proxyConfig should set envoy_prometheus_bind_addr to a listener on 0.0.0.0 (or :: for IPv6 listeners) on
 	// the prometheusScrapePort that points to a metrics backend. The backend for this listener will be determined by
 	// the envoy bootstrapping command (consul connect envoy) configuration in the init container. If there is a merged
 	// metrics server, the backend would be that server. If we are not running the merged metrics server, the backend
@@ -478,12 +598,27 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		if err != nil {
 			return nil, nil, err
 		}
-		prometheusScrapeListener := fmt.Sprintf("0.0.0.0:%s", prometheusScrapePort)
+		prometheusScrapeListener := net.JoinHostPort(unspecifiedAddress(r.ListenerIPFamily), prometheusScrapePort)
 		proxyConfig.Config[envoyPrometheusBindAddr] = prometheusScrapeListener
 	}
 
+	if r.TracingZipkinAddress != "" {
+		tracing, clusters, err := zipkinTracingConfig(r.TracingZipkinAddress)
+		if err != nil {
+			return nil, nil, err
+		}
+		proxyConfig.Config[envoyTracingJSON] = tracing
+		proxyConfig.Config[envoyExtraStaticClustersJSON] = clusters
+	}
+
+	if r.Datadog.Enabled {
+		// The URL is resolved from the environment of the connect-init container.
+		proxyConfig.Config[envoyDogstatsdURL] = "$" + dogstatsdURLEnvVar
+		proxyConfig.Config[envoyStatsTags] = datadogStatsTags(pod, serviceName)
+	}
+
 	if consulServicePort > 0 {
-		proxyConfig.LocalServiceAddress = "127.0.0.1"
+		proxyConfig.LocalServiceAddress = loopbackAddress(r.ListenerIPFamily)
 		proxyConfig.LocalServicePort = consulServicePort
 	}
 
@@ -498,18 +633,19 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		proxyPort += idx
 	}
 	proxyService := &api.AgentServiceRegistration{
-		Kind:      api.ServiceKindConnectProxy,
-		ID:        proxyServiceID,
-		Name:      proxyServiceName,
-		Port:      proxyPort,
-		Address:   pod.Status.PodIP,
-		Meta:      meta,
-		Namespace: r.consulNamespace(pod.Namespace),
-		Proxy:     proxyConfig,
+		Kind:            api.ServiceKindConnectProxy,
+		ID:              proxyServiceID,
+		Name:            proxyServiceName,
+		Port:            proxyPort,
+		Address:         podIP,
+		TaggedAddresses: r.podTaggedAddresses(pod, proxyPort),
+		Meta:            meta,
+		Namespace:       r.consulNamespace(pod.Namespace),
+		Proxy:           proxyConfig,
 		Checks: api.AgentServiceChecks{
 			{
 				Name:                           "Proxy Public Listener",
-				TCP:                            fmt.Sprintf("%s:%d", pod.Status.PodIP, proxyPort),
+				TCP:                            net.JoinHostPort(podIP, strconv.Itoa(proxyPort)),
 				Interval:                       "10s",
 				DeregisterCriticalServiceAfter: "10m",
 			},
@@ -541,10 +677,11 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 			return nil, nil, err
 		}
 
-		// Check if the service has a valid IP.
-		parsedIP := net.ParseIP(k8sService.Spec.ClusterIP)
+		// Check if the service has a valid IP. On dual-stack clusters the cluster IP
+		// of the registration address family is used.
+		clusterIP := clusterIPAddress(k8sService, r.RegistrationIPFamily)
+		parsedIP := net.ParseIP(clusterIP)
 		if parsedIP != nil {
-			taggedAddresses := make(map[string]api.ServiceAddress)
 
 			// When a service has multiple ports, we need to choose the port that is registered with Consul
 			// and only set that port as the tagged address because Consul currently does not support multiple ports
@@ -570,17 +707,22 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 				}
 			}
 
-			taggedAddresses[clusterIPTaggedAddressName] = api.ServiceAddress{
-				Address: k8sService.Spec.ClusterIP,
+			virtual := api.ServiceAddress{
+				Address: clusterIP,
 				Port:    int(k8sServicePort),
 			}
-
-			service.TaggedAddresses = taggedAddresses
-			proxyService.TaggedAddresses = taggedAddresses
+			if service.TaggedAddresses == nil {
+				service.TaggedAddresses = make(map[string]api.ServiceAddress)
+			}
+			service.TaggedAddresses[clusterIPTaggedAddressName] = virtual
+			if proxyService.TaggedAddresses == nil {
+				proxyService.TaggedAddresses = make(map[string]api.ServiceAddress)
+			}
+			proxyService.TaggedAddresses[clusterIPTaggedAddressName] = virtual
 
 			proxyService.Proxy.Mode = api.ProxyModeTransparent
 		} else {
-			r.Log.Info("skipping syncing service cluster IP to Consul", "name", k8sService.Name, "ns", k8sService.Namespace, "ip", k8sService.Spec.ClusterIP)
+			r.Log.Info("skipping syncing service cluster IP to Consul", "namespace", k8sService.Namespace, "resource", k8sService.Name, "ip", clusterIP)
 		}
 
 		// Expose k8s probes as Envoy listeners if needed.
@@ -682,7 +824,10 @@ func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string)
 // The argument endpointsAddressesMap decides whether to deregister *all* service instances or selectively deregister
 // them only if they are not in endpointsAddressesMap. If the map is nil, it will deregister all instances. If the map
 // has addresses, it will only deregister instances not in the map.
-func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
+func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) (err error) {
+	ctx, span := tracing.Start(ctx, "deregister service instances", attribute.Bool("deregister.all", endpointsAddressesMap == nil))
+	defer func() { tracing.End(span, err) }()
+
 	// Get all agents by getting pods with label component=client, app=consul and release=<ReleaseName>
 	agents := corev1.PodList{}
 	listOptions := client.ListOptions{
@@ -719,7 +864,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 		}
 
 		// Get services matching metadata.
-		svcs, err := serviceInstancesForK8SServiceNameAndNamespace(k8sSvcName, k8sSvcNamespace, client)
+		svcs, err := serviceInstancesForK8SServiceNameAndNamespace(ctx, k8sSvcName, k8sSvcNamespace, client)
 		if err != nil {
 			r.Log.Error(err, "failed to get service instances", "name", k8sSvcName)
 			return err
@@ -734,7 +879,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 				if _, ok := endpointsAddressesMap[serviceRegistration.Address]; !ok {
 					// If the service address is not in the Endpoints addresses, deregister it.
 					r.Log.Info("deregistering service from consul", "svc", svcID)
-					if err = client.Agent().ServiceDeregister(svcID); err != nil {
+					if err = client.Agent().ServiceDeregisterOpts(svcID, (&api.QueryOptions{}).WithContext(ctx)); err != nil {
 						r.Log.Error(err, "failed to deregister service instance", "id", svcID)
 						return err
 					}
@@ -742,7 +887,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 				}
 			} else {
 				r.Log.Info("deregistering service from consul", "svc", svcID)
-				if err = client.Agent().ServiceDeregister(svcID); err != nil {
+				if err = client.Agent().ServiceDeregisterOpts(svcID, (&api.QueryOptions{}).WithContext(ctx)); err != nil {
 					r.Log.Error(err, "failed to deregister service instance", "id", svcID)
 					return err
 				}
@@ -751,7 +896,7 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 
 			if r.AuthMethod != "" && serviceDeregistered {
 				r.Log.Info("reconciling ACL tokens for service", "svc", serviceRegistration.Service)
-				err = r.deleteACLTokensForServiceInstance(client, serviceRegistration.Service, k8sSvcNamespace, serviceRegistration.Meta[MetaKeyPodName])
+				err = r.deleteACLTokensForServiceInstance(ctx, client, serviceRegistration.Service, k8sSvcNamespace, serviceRegistration.Meta[MetaKeyPodName])
 				if err != nil {
 					r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", serviceRegistration.Service)
 					return err
@@ -766,13 +911,13 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 // deleteACLTokensForServiceInstance finds the ACL tokens that belongs to the service instance and deletes it from Consul.
 // It will only check for ACL tokens that have been created with the auth method this controller
 // has been configured with and will only delete tokens for the provided podName.
-func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Client, serviceName, k8sNS, podName string) error {
+func (r *EndpointsController) deleteACLTokensForServiceInstance(ctx context.Context, client *api.Client, serviceName, k8sNS, podName string) error {
 	// Skip if podName is empty.
 	if podName == "" {
 		return nil
 	}
 
-	tokens, _, err := client.ACL().TokenList(nil)
+	tokens, _, err := client.ACL().TokenList((&api.QueryOptions{}).WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to get a list of tokens from Consul: %s", err)
 	}
@@ -794,7 +939,7 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 			// If we can't find token's pod, delete it.
 			if tokenPodName == podName {
 				r.Log.Info("deleting ACL token for pod", "name", podName)
-				_, err = client.ACL().TokenDelete(token.AccessorID, nil)
+				_, err = client.ACL().TokenDelete(token.AccessorID, (&api.WriteOptions{}).WithContext(ctx))
 				if err != nil {
 					return fmt.Errorf("failed to delete token from Consul: %s", err)
 				}
@@ -828,10 +973,11 @@ func getTokenMetaFromDescription(description string) (map[string]string, error)
 
 // serviceInstancesForK8SServiceNameAndNamespace calls Consul's ServicesWithFilter to get the list
 // of services instances that have the provided k8sServiceName and k8sServiceNamespace in their metadata.
-func serviceInstancesForK8SServiceNameAndNamespace(k8sServiceName, k8sServiceNamespace string, client *api.Client) (map[string]*api.AgentService, error) {
-	return client.Agent().ServicesWithFilter(
+func serviceInstancesForK8SServiceNameAndNamespace(ctx context.Context, k8sServiceName, k8sServiceNamespace string, client *api.Client) (map[string]*api.AgentService, error) {
+	return client.Agent().ServicesWithFilterOpts(
 		fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
-			MetaKeyKubeServiceName, k8sServiceName, MetaKeyKubeNS, k8sServiceNamespace, MetaKeyManagedBy, managedByValue))
+			MetaKeyKubeServiceName, k8sServiceName, MetaKeyKubeNS, k8sServiceNamespace, MetaKeyManagedBy, managedByValue),
+		(&api.QueryOptions{}).WithContext(ctx))
 }
 
 // processUpstreams reads the list of upstreams from the Pod annotation and converts them into a list of api.Upstream
@@ -845,78 +991,42 @@ func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.
 	}
 
 	var upstreams []api.Upstream
-	if raw, ok := pod.Annotations[annotationUpstreams]; ok && raw != "" {
-		for _, raw := range strings.Split(raw, ",") {
-			parts := strings.SplitN(raw, ":", 3)
-
-			var datacenter, serviceName, preparedQuery, namespace, partition string
-			var port int32
-			if strings.TrimSpace(parts[0]) == "prepared_query" {
-				port, _ = portValue(pod, strings.TrimSpace(parts[2]))
-				preparedQuery = strings.TrimSpace(parts[1])
-			} else {
-				port, _ = portValue(pod, strings.TrimSpace(parts[1]))
-
-				// If Consul Namespaces or Admin Partitions are enabled, attempt to parse the
-				// upstream for a namespace.
-				if r.EnableConsulNamespaces || r.EnableConsulPartitions {
-					pieces := strings.SplitN(parts[0], ".", 3)
-					switch len(pieces) {
-					case 3:
-						partition = strings.TrimSpace(pieces[2])
-						fallthrough
-					case 2:
-						namespace = strings.TrimSpace(pieces[1])
-						fallthrough
-					default:
-						serviceName = strings.TrimSpace(pieces[0])
-					}
-				} else {
-					serviceName = strings.TrimSpace(parts[0])
-				}
+	for _, parsed := range parseUpstreamEntries(pod, r.EnableConsulNamespaces, r.EnableConsulPartitions) {
+		raw, upstream := parsed.raw, parsed.upstream
+		if parsed.err != nil {
+			// Invalid upstreams are rejected by the webhook, so this can only happen for pods
+			// admitted before the upstream was validated. Skip it rather than failing
+			// the registration of the whole service.
+			r.Log.Error(parsed.err, "skipping invalid upstream", "namespace", pod.Namespace, "resource", pod.Name)
+			continue
+		}
 
-				// parse the optional datacenter
-				if len(parts) > 2 {
-					datacenter = strings.TrimSpace(parts[2])
-
-					// Check if there's a proxy defaults config with mesh gateway
-					// mode set to local or remote. This helps users from
-					// accidentally forgetting to set a mesh gateway mode
-					// and then being confused as to why their traffic isn't
-					// routing.
-					entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
-					if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
-						return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", raw)
-					} else if err == nil {
-						mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
-						if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
-							return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
-						}
-					}
-					// NOTE: If we can't reach Consul we don't error out because
-					// that would fail the pod scheduling and this is a nice-to-have
-					// check, not something that should block during a Consul hiccup.
+		if upstream.DestinationType == api.UpstreamDestTypeService && upstream.Datacenter != "" {
+			// Check if there's a proxy defaults config with mesh gateway
+			// mode set to local or remote. This helps users from
+			// accidentally forgetting to set a mesh gateway mode
+			// and then being confused as to why their traffic isn't
+			// routing.
+			entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+			if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
+				return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", raw)
+			} else if err == nil {
+				mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
+				if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
+					return []api.Upstream{}, fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", raw, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
 				}
 			}
+			// NOTE: If we can't reach Consul we don't error out because
+			// that would fail the pod scheduling and this is a nice-to-have
+			// check, not something that should block during a Consul hiccup.
+		}
 
-			if port > 0 {
-				upstream := api.Upstream{
-					DestinationType:      api.UpstreamDestTypeService,
-					DestinationPartition: partition,
-					DestinationNamespace: namespace,
-					DestinationName:      serviceName,
-					Datacenter:           datacenter,
-					LocalBindPort:        int(port),
-				}
-
-				if preparedQuery != "" {
-					upstream.DestinationType = api.UpstreamDestTypePreparedQuery
-					upstream.DestinationName = preparedQuery
-				}
-
-				upstreams = append(upstreams, upstream)
-			}
+		// Consul binds the upstream listeners to the IPv4 loopback address by default.
+		if upstream.LocalBindAddress == "" && r.ListenerIPFamily == corev1.IPv6Protocol {
+			upstream.LocalBindAddress = loopbackAddress(r.ListenerIPFamily)
 		}
+
+		upstreams = append(upstreams, upstream)
 	}
 
 	return upstreams, nil
@@ -924,13 +1034,31 @@ func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.
 
 // remoteConsulClient returns an *api.Client that points at the consul agent local to the pod for a provided namespace.
 func (r *EndpointsController) remoteConsulClient(ip string, namespace string) (*api.Client, error) {
-	newAddr := fmt.Sprintf("%s://%s:%s", r.ConsulScheme, ip, r.ConsulPort)
+	newAddr := fmt.Sprintf("%s://%s", r.ConsulScheme, net.JoinHostPort(ip, r.ConsulPort))
 	localConfig := r.ConsulClientCfg
 	localConfig.Address = newAddr
 	localConfig.Namespace = namespace
 	return consul.NewClient(localConfig, r.ConsulAPITimeout)
 }
 
+// podTaggedAddresses returns the lan_ipv4 and lan_ipv6 tagged addresses of a
+// service instance of a dual-stack pod so that it can be reached with either
+// address family. It returns nil if the registration address family isn't
+// set or the pod doesn't have IPs of both families.
+func (r *EndpointsController) podTaggedAddresses(pod corev1.Pod, port int) map[string]api.ServiceAddress {
+	if r.RegistrationIPFamily == "" {
+		return nil
+	}
+	ipv4, ipv6 := podAddress(pod, corev1.IPv4Protocol), podAddress(pod, corev1.IPv6Protocol)
+	if ipFamily(ipv4) != corev1.IPv4Protocol || ipFamily(ipv6) != corev1.IPv6Protocol {
+		return nil
+	}
+	return map[string]api.ServiceAddress{
+		lanIPv4TaggedAddressName: {Address: ipv4, Port: port},
+		lanIPv6TaggedAddressName: {Address: ipv6, Port: port},
+	}
+}
+
 // shouldIgnore ignores namespaces where we don't connect-inject.
 func shouldIgnore(namespace string, denySet, allowSet mapset.Set) bool {
 	// Ignores system namespaces.
@@ -1051,6 +1179,11 @@ func hasBeenInjected(pod corev1.Pod) bool {
 	return false
 }
 
+// podCompleted returns true if all of the pod's containers have terminated and won't be restarted.
+func podCompleted(pod corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
 // mapAddresses combines all addresses to a mapping of address to its health status.
 func mapAddresses(addresses corev1.EndpointSubset) map[corev1.EndpointAddress]string {
 	m := make(map[corev1.EndpointAddress]string)
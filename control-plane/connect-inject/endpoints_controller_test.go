@@ -115,6 +115,43 @@ func TestHasBeenInjected(t *testing.T) {
 	}
 }
 
+func TestPodCompleted(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		phase    corev1.PodPhase
+		expected bool
+	}{
+		{
+			name:     "Pending pod",
+			phase:    corev1.PodPending,
+			expected: false,
+		},
+		{
+			name:     "Running pod",
+			phase:    corev1.PodRunning,
+			expected: false,
+		},
+		{
+			name:     "Succeeded pod",
+			phase:    corev1.PodSucceeded,
+			expected: true,
+		},
+		{
+			name:     "Failed pod",
+			phase:    corev1.PodFailed,
+			expected: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Status.Phase = tt.phase
+			require.Equal(t, tt.expected, podCompleted(*pod))
+		})
+	}
+}
+
 // TestProcessUpstreamsTLSandACLs enables TLS and ACLS and tests processUpstreams through
 // the only path which sets up and uses a consul client: when proxy defaults need to be read.
 // This test was plucked from the table test TestProcessUpstreams as the rest do not use the client.
@@ -495,6 +532,48 @@ func TestProcessUpstreams(t *testing.T) {
 			consulNamespacesEnabled: false,
 			consulPartitionsEnabled: false,
 		},
+		{
+			name: "upstreams with per-upstream settings",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1:1234;peer=cluster-2;connect-timeout=2s, upstream2:2234;local-bind-address=127.0.0.2"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					DestinationPeer: "cluster-2",
+					LocalBindPort:   1234,
+					Config:          map[string]interface{}{"connect_timeout_ms": 2000},
+				},
+				{
+					DestinationType:  api.UpstreamDestTypeService,
+					DestinationName:  "upstream2",
+					LocalBindAddress: "127.0.0.2",
+					LocalBindPort:    2234,
+				},
+			},
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
+		{
+			name: "invalid upstreams are skipped",
+			pod: func() *corev1.Pod {
+				pod1 := createPod("pod1", "1.2.3.4", true, true)
+				pod1.Annotations[annotationUpstreams] = "upstream1:notaport, upstream2:2234"
+				return pod1
+			},
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream2",
+					LocalBindPort:   2234,
+				},
+			},
+			consulNamespacesEnabled: false,
+			consulPartitionsEnabled: false,
+		},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -3575,6 +3654,117 @@ func TestReconcile_podSpecifiesExplicitService(t *testing.T) {
 	require.Len(t, proxyServiceInstances, 1)
 }
 
+// TestReconcile_completedPod tests that the service instances of pods that have completed, such as the
+// pods of Jobs, are deregistered even if the pods are still in the Endpoints object.
+func TestReconcile_completedPod(t *testing.T) {
+	cases := []corev1.PodPhase{corev1.PodSucceeded, corev1.PodFailed}
+	for _, phase := range cases {
+		t.Run(string(phase), func(t *testing.T) {
+			nodeName := "test-node"
+			namespace := "default"
+			serviceName := "job"
+
+			endpoint := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: namespace,
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						NotReadyAddresses: []corev1.EndpointAddress{
+							{
+								IP:       "1.2.3.4",
+								NodeName: &nodeName,
+								TargetRef: &corev1.ObjectReference{
+									Kind:      "Pod",
+									Name:      "pod1",
+									Namespace: namespace,
+								},
+							},
+						},
+					},
+				},
+			}
+			pod1 := createPod("pod1", "1.2.3.4", true, true)
+			pod1.Status.Phase = phase
+			fakeClientPod := createPod("fake-consul-client", "127.0.0.1", false, true)
+			fakeClientPod.Labels = map[string]string{"component": "client", "app": "consul", "release": "consul"}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			fakeClient := fake.NewClientBuilder().WithRuntimeObjects(endpoint, pod1, fakeClientPod, &ns).Build()
+
+			// Create test Consul server.
+			consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) { c.NodeName = nodeName })
+			require.NoError(t, err)
+			defer consul.Stop()
+			consul.WaitForServiceIntentions(t)
+			cfg := &api.Config{Address: consul.HTTPAddr}
+			consulClient, err := api.NewClient(cfg)
+			require.NoError(t, err)
+			addr := strings.Split(consul.HTTPAddr, ":")
+			consulPort := addr[1]
+
+			ep := &EndpointsController{
+				Client:                fakeClient,
+				Log:                   logrtest.TestLogger{T: t},
+				ConsulClient:          consulClient,
+				ConsulPort:            consulPort,
+				ConsulScheme:          "http",
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSetWith(),
+				ReleaseName:           "consul",
+				ReleaseNamespace:      namespace,
+				ConsulClientCfg:       cfg,
+			}
+
+			// Register the service instances of the pod from before it completed.
+			err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+				ID:      "pod1-" + serviceName,
+				Name:    serviceName,
+				Port:    0,
+				Address: "1.2.3.4",
+				Meta: map[string]string{
+					MetaKeyKubeNS:          namespace,
+					MetaKeyKubeServiceName: serviceName,
+					MetaKeyManagedBy:       managedByValue,
+					MetaKeyPodName:         "pod1",
+				},
+			})
+			require.NoError(t, err)
+			err = consulClient.Agent().ServiceRegister(&api.AgentServiceRegistration{
+				Kind:    api.ServiceKindConnectProxy,
+				ID:      "pod1-" + serviceName + "-sidecar-proxy",
+				Name:    serviceName + "-sidecar-proxy",
+				Port:    20000,
+				Address: "1.2.3.4",
+				Proxy: &api.AgentServiceConnectProxyConfig{
+					DestinationServiceName: serviceName,
+					DestinationServiceID:   "pod1-" + serviceName,
+				},
+				Meta: map[string]string{
+					MetaKeyKubeNS:          namespace,
+					MetaKeyKubeServiceName: serviceName,
+					MetaKeyManagedBy:       managedByValue,
+					MetaKeyPodName:         "pod1",
+				},
+			})
+			require.NoError(t, err)
+
+			namespacedName := types.NamespacedName{Namespace: namespace, Name: serviceName}
+			resp, err := ep.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+			require.NoError(t, err)
+			require.False(t, resp.Requeue)
+
+			// Check that the service instances have been deregistered.
+			serviceInstances, _, err := consulClient.Catalog().Service(serviceName, "", nil)
+			require.NoError(t, err)
+			require.Len(t, serviceInstances, 0)
+			proxyServiceInstances, _, err := consulClient.Catalog().Service(serviceName+"-sidecar-proxy", "", nil)
+			require.NoError(t, err)
+			require.Len(t, proxyServiceInstances, 0)
+		})
+	}
+}
+
 // TestReconcileUnreachableClient tests the scenario where a consul client is unreachable.  We want to verify that
 // the Timeout on the HttpClient has timed out quickly so as not to infinitely wait and cause queuing of subsequent
 // endpoint objects.
@@ -4385,7 +4575,7 @@ func TestServiceInstancesForK8SServiceNameAndNamespace(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			svcs, err := serviceInstancesForK8SServiceNameAndNamespace(k8sSvc, k8sNS, consulClient)
+			svcs, err := serviceInstancesForK8SServiceNameAndNamespace(context.Background(), k8sSvc, k8sNS, consulClient)
 			require.NoError(t, err)
 			if len(svcs) > 0 {
 				require.Len(t, svcs, 2)
@@ -5728,6 +5918,42 @@ func TestGetTokenMetaFromDescription(t *testing.T) {
 	}
 }
 
+func TestNodeLocality(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		labels           map[string]string
+		expectedLocality *api.Locality
+	}{
+		"no topology labels": {
+			labels:           map[string]string{"kubernetes.io/os": "linux"},
+			expectedLocality: nil,
+		},
+		"region and zone": {
+			labels: map[string]string{
+				corev1.LabelTopologyRegion: "us-east-1",
+				corev1.LabelTopologyZone:   "us-east-1a",
+			},
+			expectedLocality: &api.Locality{Region: "us-east-1", Zone: "us-east-1a"},
+		},
+		"zone only": {
+			labels:           map[string]string{corev1.LabelTopologyZone: "us-east-1a"},
+			expectedLocality: &api.Locality{Zone: "us-east-1a"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-node",
+					Labels: c.labels,
+				},
+			}
+			require.Equal(t, c.expectedLocality, nodeLocality(node))
+		})
+	}
+}
+
 func TestMapAddresses(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
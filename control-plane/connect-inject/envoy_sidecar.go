@@ -47,6 +47,9 @@ func (h *Handler) envoySidecar(namespace corev1.Namespace, pod corev1.Pod, mpi m
 		},
 		Command: cmd,
 	}
+	if h.Datadog.Enabled && h.Datadog.DogStatsDSocketPath != "" {
+		container.VolumeMounts = append(container.VolumeMounts, h.datadogSocketVolumeMount())
+	}
 
 	tproxyEnabled, err := transparentProxyEnabled(namespace, pod, h.EnableTransparentProxy)
 	if err != nil {
@@ -79,6 +82,7 @@ func (h *Handler) envoySidecar(namespace corev1.Namespace, pod corev1.Pod, mpi m
 			ReadOnlyRootFilesystem: pointerToBool(true),
 		}
 	}
+	container.SecurityContext = h.restrictSecurityContext(container.SecurityContext)
 
 	return container, nil
 }
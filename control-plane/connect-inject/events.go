@@ -0,0 +1,194 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// The reasons of the warning events that are recorded on the pods and
+// Services whose service instances can't be set up in Consul.
+const (
+	// eventReasonInjectionFailed is recorded on the controller of a pod, such
+	// as its ReplicaSet, when the pod can't be injected.
+	eventReasonInjectionFailed = "InjectionFailed"
+	// eventReasonConnectInitFailed is recorded on a pod when its connect-init
+	// container fails.
+	eventReasonConnectInitFailed = "ConnectInitFailed"
+	// eventReasonRegistrationFailed is recorded on a pod when its service
+	// instance can't be registered with Consul.
+	eventReasonRegistrationFailed = "RegistrationFailed"
+	// eventReasonDeregistrationFailed is recorded on a Service when the
+	// service instances of the pods that are no longer its endpoints can't be
+	// deregistered from Consul.
+	eventReasonDeregistrationFailed = "DeregistrationFailed"
+)
+
+// recordInjectionFailure records a warning event for the pod of req, which
+// the webhook denied with resp. The pods that are being created don't exist
+// yet, so the event is recorded on the controller of the pod if it has one.
+func (h *Handler) recordInjectionFailure(req admission.Request, resp admission.Response) {
+	if h.EventRecorder == nil {
+		return
+	}
+	var pod corev1.Pod
+	if err := h.decoder.Decode(req, &pod); err != nil {
+		return
+	}
+	var ref *corev1.ObjectReference
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		ref = &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Namespace:  req.Namespace,
+			Name:       owner.Name,
+			UID:        owner.UID,
+		}
+	} else if pod.Name != "" {
+		ref = &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  req.Namespace,
+			Name:       pod.Name,
+			UID:        pod.UID,
+		}
+	} else {
+		return
+	}
+
+	reason := "unknown error"
+	if resp.Result != nil && resp.Result.Message != "" {
+		reason = resp.Result.Message
+	}
+	action := "Check the logs of the connect injector; the pod is admitted once the error is resolved."
+	if resp.Result != nil && resp.Result.Code == http.StatusBadRequest {
+		action = "Correct the consul.hashicorp.com annotations or the spec of the pod."
+	}
+	h.EventRecorder.Eventf(ref, corev1.EventTypeWarning, eventReasonInjectionFailed,
+		"Unable to inject the Consul sidecar into pod %s: %s. %s", podName(pod), reason, action)
+}
+
+// podName returns the name of pod, or the prefix of its generated name if it
+// hasn't been generated yet.
+func podName(pod corev1.Pod) string {
+	if pod.Name == "" && pod.GenerateName != "" {
+		return pod.GenerateName + "*"
+	}
+	return pod.Name
+}
+
+// connectInitFailure returns why the connect-init container of pod failed
+// last, or "" if it didn't. Its message is the termination message that
+// connect-init writes when it fails.
+func connectInitFailure(pod corev1.Pod) string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != InjectInitContainerName && !strings.HasPrefix(status.Name, InjectInitContainerName+"-") {
+			continue
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		message := strings.TrimSpace(terminated.Message)
+		if message == "" {
+			message = fmt.Sprintf("It exited with code %d.", terminated.ExitCode)
+		}
+		return fmt.Sprintf("The %s container failed: %s See its logs with `kubectl logs -n %s %s -c %s`.",
+			status.Name, message, pod.Namespace, pod.Name, status.Name)
+	}
+	return ""
+}
+
+// filterConnectInitFailedPods returns true for the injected pods whose
+// connect-init container failed.
+func (r *EndpointsController) filterConnectInitFailedPods(object client.Object) bool {
+	pod, ok := object.(*corev1.Pod)
+	if !ok {
+		return false
+	}
+	return hasBeenInjected(*pod) && connectInitFailure(*pod) != ""
+}
+
+// requestsForConnectInitFailedPods enqueues a request for each Endpoints
+// object that has an address of the pod, whose connect-init container failed,
+// so that the failure is recorded. The Endpoints objects don't change while
+// connect-init is restarted since the pod stays unready.
+func (r *EndpointsController) requestsForConnectInitFailedPods(object client.Object) []ctrl.Request {
+	var endpointsList corev1.EndpointsList
+	if err := r.Client.List(r.Context, &endpointsList, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list endpoints", "namespace", object.GetNamespace())
+		return []ctrl.Request{}
+	}
+	var requests []ctrl.Request
+	for _, endpoints := range endpointsList.Items {
+		if endpointsHavePod(endpoints, object.GetName()) {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: endpoints.Name, Namespace: endpoints.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// endpointsHavePod returns true if endpoints has an address of the pod named
+// podName.
+func endpointsHavePod(endpoints corev1.Endpoints, podName string) bool {
+	for _, subset := range endpoints.Subsets {
+		for address := range mapAddresses(subset) {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" && address.TargetRef.Name == podName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordConnectInitFailure records a warning event on pod if its
+// connect-init container failed.
+func (r *EndpointsController) recordConnectInitFailure(pod corev1.Pod) {
+	if r.Recorder == nil {
+		return
+	}
+	if message := connectInitFailure(pod); message != "" {
+		r.Recorder.Event(&pod, corev1.EventTypeWarning, eventReasonConnectInitFailed, message)
+	}
+}
+
+// recordRegistrationFailure records a warning event on pod for the error
+// registering its service instance with Consul.
+func (r *EndpointsController) recordRegistrationFailure(pod corev1.Pod, err error) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(&pod, corev1.EventTypeWarning, eventReasonRegistrationFailed,
+		"Unable to register the service instance of the pod with the Consul client on node %s (%s): %s. "+
+			"The registration is retried; check that the Consul client pod on the node is running and ready.",
+		pod.Spec.NodeName, pod.Status.HostIP, err)
+}
+
+// recordDeregistrationFailure records a warning event on the Service name in
+// namespace for the error deregistering the service instances of its former
+// endpoints from Consul. No event is recorded if the Service was deleted.
+func (r *EndpointsController) recordDeregistrationFailure(ctx context.Context, name, namespace string, err error) {
+	if r.Recorder == nil {
+		return
+	}
+	var service corev1.Service
+	if getErr := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &service); getErr != nil {
+		return
+	}
+	r.Recorder.Eventf(&service, corev1.EventTypeWarning, eventReasonDeregistrationFailed,
+		"Unable to deregister the service instances of the pods that are no longer endpoints of the Service from Consul: %s. "+
+			"The deregistration is retried; check that the Consul client pods are running and ready.", err)
+}
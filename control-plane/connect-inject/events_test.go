@@ -0,0 +1,243 @@
+package connectinject
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Test that an event is recorded on the controller of the pods that can't be
+// injected.
+func TestHandlerHandle_RecordsInjectionFailure(t *testing.T) {
+	t.Parallel()
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	isController := true
+	cases := map[string]struct {
+		pod      *corev1.Pod
+		expEvent string
+	}{
+		"pod with a controller": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "web-5d4f9c8b7-",
+					Annotations:  map[string]string{annotationProtocol: "http"},
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d4f9c8b7", UID: "uid", Controller: &isController},
+					},
+				},
+			},
+			expEvent: "Warning InjectionFailed Unable to inject the Consul sidecar into pod web-5d4f9c8b7-*: " +
+				"the \"consul.hashicorp.com/connect-service-protocol\" annotation is no longer supported.",
+		},
+		"pod without a controller": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "web",
+					Annotations: map[string]string{annotationProtocol: "http"},
+				},
+			},
+			expEvent: "Warning InjectionFailed Unable to inject the Consul sidecar into pod web: ",
+		},
+		"pod without a name or controller": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "web-",
+					Annotations:  map[string]string{annotationProtocol: "http"},
+				},
+			},
+		},
+		"injected pod": {
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "web",
+					Annotations: map[string]string{keyInjectStatus: injected},
+				},
+			},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			h := Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				EventRecorder:         recorder,
+				decoder:               decoder,
+			}
+			h.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "default",
+					Object:    encodeRaw(t, c.pod),
+				},
+			})
+			if c.expEvent == "" {
+				require.Empty(t, recorder.Events)
+				return
+			}
+			require.Len(t, recorder.Events, 1)
+			require.Contains(t, <-recorder.Events, c.expEvent)
+		})
+	}
+}
+
+func TestConnectInitFailure(t *testing.T) {
+	t.Parallel()
+	failed := corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 1,
+			Message:  "Timed out waiting for the services of the pod to be registered with Consul after 121 attempts.",
+		},
+	}
+	cases := map[string]struct {
+		statuses   []corev1.ContainerStatus
+		expMessage string
+	}{
+		"running": {
+			statuses: []corev1.ContainerStatus{
+				{Name: InjectInitContainerName, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			},
+		},
+		"succeeded": {
+			statuses: []corev1.ContainerStatus{
+				{Name: InjectInitContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			},
+		},
+		"failed": {
+			statuses: []corev1.ContainerStatus{
+				{Name: InjectInitContainerName, State: failed},
+			},
+			expMessage: "The consul-connect-inject-init container failed: Timed out waiting for the services of the pod to be registered with Consul after 121 attempts. " +
+				"See its logs with `kubectl logs -n default web -c consul-connect-inject-init`.",
+		},
+		"restarted after failing": {
+			statuses: []corev1.ContainerStatus{
+				{
+					Name:                 InjectInitContainerName,
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 2}},
+				},
+			},
+			expMessage: "The consul-connect-inject-init container failed: It exited with code 2. " +
+				"See its logs with `kubectl logs -n default web -c consul-connect-inject-init`.",
+		},
+		"succeeded after failing": {
+			statuses: []corev1.ContainerStatus{
+				{
+					Name:                 InjectInitContainerName,
+					State:                corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+					LastTerminationState: failed,
+				},
+			},
+		},
+		"multi port pod": {
+			statuses: []corev1.ContainerStatus{
+				{Name: InjectInitContainerName + "-web", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				{Name: InjectInitContainerName + "-web-admin", State: failed},
+			},
+			expMessage: "The consul-connect-inject-init-web-admin container failed: ",
+		},
+		"other init container": {
+			statuses: []corev1.ContainerStatus{
+				{Name: "init", State: failed},
+			},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Status:     corev1.PodStatus{InitContainerStatuses: c.statuses},
+			}
+			message := connectInitFailure(pod)
+			if c.expMessage == "" {
+				require.Empty(t, message)
+			} else {
+				require.Contains(t, message, c.expMessage)
+			}
+		})
+	}
+}
+
+// Test that the Endpoints objects with an address of a pod whose connect-init
+// container failed are reconciled so that the failure is recorded.
+func TestRequestsForConnectInitFailedPods(t *testing.T) {
+	t.Parallel()
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: InjectInitContainerName, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+	}
+	endpoints := func(name, namespace, podName string) *corev1.Endpoints {
+		return &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Subsets: []corev1.EndpointSubset{
+				{
+					NotReadyAddresses: []corev1.EndpointAddress{
+						{IP: "1.2.3.4", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: podName, Namespace: namespace}},
+					},
+				},
+			},
+		}
+	}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(
+		pod,
+		endpoints("service-created", "default", "pod1"),
+		endpoints("other-service", "default", "pod2"),
+		endpoints("service-created", "other", "pod1"),
+	).Build()
+	recorder := record.NewFakeRecorder(1)
+	ep := &EndpointsController{
+		Client:   fakeClient,
+		Log:      logrtest.TestLogger{T: t},
+		Recorder: recorder,
+		Context:  context.Background(),
+	}
+
+	require.True(t, ep.filterConnectInitFailedPods(pod))
+	require.False(t, ep.filterConnectInitFailedPods(createPod("pod2", "1.2.3.5", true, true)))
+	require.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "service-created", Namespace: "default"}},
+	}, ep.requestsForConnectInitFailedPods(pod))
+
+	ep.recordConnectInitFailure(*pod)
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, "Warning ConnectInitFailed The consul-connect-inject-init container failed: It exited with code 1.")
+}
+
+func TestRecordDeregistrationFailure(t *testing.T) {
+	t.Parallel()
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"}}
+	recorder := record.NewFakeRecorder(2)
+	ep := &EndpointsController{
+		Client:   fake.NewClientBuilder().WithRuntimeObjects(service).Build(),
+		Log:      logrtest.TestLogger{T: t},
+		Recorder: recorder,
+	}
+
+	ep.recordDeregistrationFailure(context.Background(), "service-created", "default", errors.New("connection refused"))
+	// No event is recorded for Services that were deleted.
+	ep.recordDeregistrationFailure(context.Background(), "service-deleted", "default", errors.New("connection refused"))
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, "Warning DeregistrationFailed Unable to deregister the service instances of the pods "+
+		"that are no longer endpoints of the Service from Consul: connection refused.")
+}
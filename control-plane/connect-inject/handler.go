@@ -22,6 +22,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -59,6 +60,28 @@ type Handler struct {
 	// use for identity with connectInjection if ACLs are enabled.
 	AuthMethod string
 
+	// EnableProjectedServiceAccountToken configures connect-init to log in
+	// with a projected service account token instead of the pod's default
+	// service account token. It has no effect unless AuthMethod is set.
+	EnableProjectedServiceAccountToken bool
+
+	// ProjectedServiceAccountTokenExpiration is the requested lifetime of the
+	// projected service account token. The kubelet rotates the token before it expires.
+	ProjectedServiceAccountTokenExpiration time.Duration
+
+	// EnableAWSIAMLogin configures connect-init to log in to AuthMethod, which
+	// must be an AWS IAM auth method, with the AWS credentials of the pod, e.g.
+	// the IAM role of its service account on EKS, instead of its service account
+	// token. It isn't supported for multi port pods.
+	EnableAWSIAMLogin bool
+
+	// AWSSTSRegion, AWSSTSEndpoint and AWSIAMServerIDHeaderValue configure
+	// the sts:GetCallerIdentity request connect-init signs to log in with
+	// EnableAWSIAMLogin. They must match the configuration of the auth method.
+	AWSSTSRegion              string
+	AWSSTSEndpoint            string
+	AWSIAMServerIDHeaderValue string
+
 	// The PEM-encoded CA certificate string
 	// to use when communicating with Consul clients over HTTPS.
 	// If not set, will use HTTP.
@@ -120,6 +143,10 @@ type Handler struct {
 	// annotations and the merged metrics server.
 	MetricsConfig MetricsConfig
 
+	// Datadog configures the Envoy sidecars to send their metrics to the DogStatsD server of
+	// the Datadog Agent on their node and adds the labels of Datadog unified service tagging.
+	Datadog DatadogConfig
+
 	// Resource settings for init container. All of these fields
 	// will be populated by the defaults provided in the initial flags.
 	InitContainerResources corev1.ResourceRequirements
@@ -145,17 +172,37 @@ type Handler struct {
 	// name of the Consul DNS service.
 	ResourcePrefix string
 
+	// EnableJobWatcher adds the job watcher container to Job pods, which shuts down the Envoy
+	// sidecar once the Job's containers have completed. The job watcher gets the pod from the
+	// Kubernetes API, so the service accounts of Jobs must be allowed to get pods.
+	EnableJobWatcher bool
+
 	// EnableOpenShift indicates that when tproxy is enabled, the security context for the Envoy and init
 	// containers should not be added because OpenShift sets a random user for those and will not allow
 	// those containers to be created otherwise.
 	EnableOpenShift bool
 
+	// EnableIPv6 also applies the transparent proxy traffic redirection rules
+	// for IPv6 traffic, with ip6tables, on IPv6-only and dual-stack clusters.
+	EnableIPv6 bool
+
+	// ListenerIPFamily is the address family of the local addresses that the
+	// sidecar proxies listen on for upstreams. It defaults to IPv4.
+	ListenerIPFamily corev1.IPFamily
+
+	// EnableRestrictedPodSecurity configures the injected containers to comply with the
+	// restricted Pod Security Standard and rejects pods that enable transparent proxy.
+	EnableRestrictedPodSecurity bool
+
 	// ConsulAPITimeout is the duration that the consul API client will
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
 
 	// Log
 	Log logr.Logger
+	// EventRecorder records a Kubernetes event when a pod can't be injected.
+	// If it's nil, no events are recorded.
+	EventRecorder record.EventRecorder
 	// Log settings for consul-sidecar
 	LogLevel string
 	LogJSON  bool
@@ -169,8 +216,17 @@ type multiPortInfo struct {
 
 // Handle is the admission.Handler implementation that actually handles the
 // webhook request for admission control. This should be registered or
-// served via the controller runtime manager.
+// served via the controller runtime manager. An event is recorded for the
+// pods that can't be injected.
 func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.handle(ctx, req)
+	if !resp.Allowed {
+		h.recordInjectionFailure(req, resp)
+	}
+	return resp
+}
+
+func (h *Handler) handle(ctx context.Context, req admission.Request) admission.Response {
 	var pod corev1.Pod
 
 	// Decode the pod from the request
@@ -191,6 +247,12 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// The webhook is reinvoked for pods it has already injected if a webhook that runs after it
+	// modifies the pod, e.g. by adding init containers.
+	if pod.Annotations[keyInjectStatus] == injected {
+		return h.handleReinvocation(ctx, req, pod, origPodJson)
+	}
+
 	// Setup the default annotation values that are used for the container.
 	// This MUST be done before shouldInject is called since that function
 	// uses these annotations.
@@ -208,18 +270,33 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
 	}
 
-	h.Log.Info("received pod", "name", req.Name, "ns", req.Namespace)
+	h.Log.Info("received pod", "namespace", req.Namespace, "resource", req.Name)
 
 	// Add our volume that will be shared by the init container and
 	// the sidecar for passing data in the pod.
 	pod.Spec.Volumes = append(pod.Spec.Volumes, h.containerVolume())
 
+	// Add the volume of the DogStatsD socket that the Envoy sidecars send their metrics to.
+	if h.Datadog.Enabled && h.Datadog.DogStatsDSocketPath != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, h.datadogSocketVolume())
+	}
+
 	// Optionally mount data volume to other containers
 	h.injectVolumeMount(pod)
 
+	// Add the projected service account token volume that connect-init uses
+	// to log in instead of the pod's default service account token.
+	if h.useProjectedServiceAccountToken(pod) {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, h.projectedServiceAccountTokenVolume())
+	}
+
 	// Add the upstream services as environment variables for easy
 	// service discovery.
-	containerEnvVars := h.containerEnvVars(pod)
+	containerEnvVars, err := h.containerEnvVars(pod)
+	if err != nil {
+		h.Log.Error(err, "error configuring upstream environment variables", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("the %q annotation is invalid: %s", annotationUpstreams, err))
+	}
 	for i := range pod.Spec.InitContainers {
 		pod.Spec.InitContainers[i].Env = append(pod.Spec.InitContainers[i].Env, containerEnvVars...)
 	}
@@ -228,6 +305,14 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, containerEnvVars...)
 	}
 
+	// Tell the containers of Jobs where to find the Envoy admin API so that they can wait for
+	// Envoy to be ready and shut it down themselves when the job watcher isn't enabled.
+	if isJobPod(pod) {
+		for i := range pod.Spec.Containers {
+			pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, jobContainerEnvVars()...)
+		}
+	}
+
 	// Add the init container which copies the Consul binary to /consul/connect-inject/.
 	initCopyContainer := h.initCopyContainer()
 	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initCopyContainer)
@@ -239,6 +324,35 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
 	}
 
+	// Assign user IDs to the init containers whose traffic should bypass redirection
+	// so that the init container can exclude them when applying the redirection rules.
+	if tproxyEnabled, err := transparentProxyEnabled(*ns, pod, h.EnableTransparentProxy); err != nil {
+		h.Log.Error(err, "error checking if transparent proxy is enabled", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking if transparent proxy is enabled: %s", err))
+	} else if tproxyEnabled {
+		if h.EnableRestrictedPodSecurity {
+			h.Log.Error(errTransparentProxyRestricted, "error checking if transparent proxy is enabled", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, errTransparentProxyRestricted)
+		}
+		if err := excludeInitContainersFromRedirection(&pod); err != nil {
+			h.Log.Error(err, "error excluding init containers from traffic redirection", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("error excluding init containers from traffic redirection: %s", err))
+		}
+	}
+
+	// Jobs need the Envoy sidecar to be shut down once their containers have completed
+	// for the pod to complete. The job watcher is created before the Envoy sidecar is added
+	// so that it only waits for the Job's containers.
+	var jobWatcher *corev1.Container
+	if h.jobWatcherEnabled(pod) {
+		container, err := h.jobWatcherContainer(*ns, pod)
+		if err != nil {
+			h.Log.Error(err, "error configuring job watcher container", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("error configuring job watcher container: %s", err))
+		}
+		jobWatcher = &container
+	}
+
 	// Get service names from the annotation. If theres 0-1 service names, it's a single port pod, otherwise it's multi
 	// port.
 	annotatedSvcNames := h.annotatedServiceNames(pod)
@@ -335,8 +449,9 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error determining if metrics merging server should be run: %s", err))
 	}
 
-	// Add the consul-sidecar only if we need to run the metrics merging server.
-	if shouldRunMetricsMerging {
+	// Add the consul-sidecar only if we need to run the metrics merging server
+	// or to serve the lifecycle metrics.
+	if shouldRunMetricsMerging || h.MetricsConfig.shouldRunLifecycleMetrics(pod) {
 		consulSidecar, err := h.consulSidecar(pod)
 		if err != nil {
 			h.Log.Error(err, "error configuring consul sidecar container", "request name", req.Name)
@@ -345,6 +460,10 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 		pod.Spec.Containers = append(pod.Spec.Containers, consulSidecar)
 	}
 
+	if jobWatcher != nil {
+		pod.Spec.Containers = append(pod.Spec.Containers, *jobWatcher)
+	}
+
 	// pod.Annotations has already been initialized by h.defaultAnnotations()
 	// and does not need to be checked for being a nil value.
 	pod.Annotations[keyInjectStatus] = injected
@@ -364,6 +483,10 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 	// from consul-k8s without Endpoints controller to consul-k8s with Endpoints controller.
 	pod.Labels[keyManagedBy] = managedByValue
 
+	if h.Datadog.Enabled {
+		h.addDatadogLabels(&pod)
+	}
+
 	// Consul-ENT only: Add the Consul destination namespace as an annotation to the pod.
 	if h.EnableNamespaces {
 		pod.Annotations[annotationConsulNamespace] = h.consulNamespace(req.Namespace)
@@ -396,7 +519,7 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 	if h.EnableNamespaces {
 		if _, err := namespaces.EnsureExists(h.ConsulClient, h.consulNamespace(req.Namespace), h.CrossNamespaceACLPolicy); err != nil {
 			h.Log.Error(err, "error checking or creating namespace",
-				"ns", h.consulNamespace(req.Namespace), "request name", req.Name)
+				"consul-namespace", h.consulNamespace(req.Namespace), "namespace", req.Namespace, "resource", req.Name)
 			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking or creating namespace: %s", err))
 		}
 	}
@@ -406,6 +529,42 @@ func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.R
 	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
 }
 
+// handleReinvocation handles a pod that has already been injected. The init containers that
+// webhooks running after the connect injector added since, and that should be excluded from
+// traffic redirection, are assigned the user ID that is excluded from it.
+func (h *Handler) handleReinvocation(ctx context.Context, req admission.Request, pod corev1.Pod, origPodJson []byte) admission.Response {
+	if len(excludedInitContainerNames(pod)) == 0 {
+		return admission.Allowed(fmt.Sprintf("%s %s is already injected", pod.Kind, pod.Name))
+	}
+
+	ns, err := h.Clientset.CoreV1().Namespaces().Get(ctx, req.Namespace, metav1.GetOptions{})
+	if err != nil {
+		h.Log.Error(err, "error fetching namespace metadata for container", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
+	}
+	if tproxyEnabled, err := transparentProxyEnabled(*ns, pod, h.EnableTransparentProxy); err != nil {
+		h.Log.Error(err, "error checking if transparent proxy is enabled", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error checking if transparent proxy is enabled: %s", err))
+	} else if !tproxyEnabled {
+		return admission.Allowed(fmt.Sprintf("%s %s is already injected", pod.Kind, pod.Name))
+	}
+
+	if err := excludeInitContainersFromRedirection(&pod); err != nil {
+		h.Log.Error(err, "error excluding init containers from traffic redirection", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error excluding init containers from traffic redirection: %s", err))
+	}
+
+	updatedPodJson, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	patches, err := jsonpatch.CreatePatch(origPodJson, updatedPodJson)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
+}
+
 // shouldOverwriteProbes returns true if we need to overwrite readiness/liveness probes for this pod.
 // It returns an error when the annotation value cannot be parsed by strconv.ParseBool.
 func shouldOverwriteProbes(pod corev1.Pod, globalOverwrite bool) (bool, error) {
@@ -553,6 +712,10 @@ func (h *Handler) validatePod(pod corev1.Pod) error {
 	if _, ok := pod.Annotations[annotationSyncPeriod]; ok {
 		return fmt.Errorf("the %q annotation is no longer supported because consul-sidecar is no longer injected to periodically register services", annotationSyncPeriod)
 	}
+
+	if _, err := parseUpstreams(pod, h.EnableNamespaces, h.ConsulPartition != ""); err != nil {
+		return fmt.Errorf("the %q annotation is invalid: %s", annotationUpstreams, err)
+	}
 	return nil
 }
 
@@ -639,6 +802,12 @@ func (h *Handler) checkUnsupportedMultiPortCases(ns corev1.Namespace, pod corev1
 	if metricsMergingEnabled {
 		return fmt.Errorf("multi port services are not compatible with metrics merging")
 	}
+	if isJobPod(pod) {
+		return fmt.Errorf("multi port services are not compatible with Jobs")
+	}
+	if h.AuthMethod != "" && h.EnableAWSIAMLogin {
+		return fmt.Errorf("multi port services are not compatible with AWS IAM login")
+	}
 	return nil
 }
 
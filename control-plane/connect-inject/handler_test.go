@@ -565,6 +565,56 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 
+		{
+			"when lifecycle metrics are enabled, we should inject the consul-sidecar without metrics merging",
+			Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				MetricsConfig: MetricsConfig{
+					EnableLifecycleMetrics: true,
+					LifecycleMetricsPort:   "20300",
+				},
+				decoder:   decoder,
+				Clientset: defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/initContainers",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/1",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/2",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+			},
+		},
+
 		{
 			"tproxy with overwriteProbes is enabled",
 			Handler{
@@ -747,6 +797,13 @@ func TestHandler_ErrorsOnDeprecatedAnnotations(t *testing.T) {
 			},
 			"the \"consul.hashicorp.com/connect-sync-period\" annotation is no longer supported because consul-sidecar is no longer injected to periodically register services",
 		},
+		{
+			"invalid upstreams annotation",
+			map[string]string{
+				annotationUpstreams: "db:1234;peer=cluster-2;datacenter=dc2",
+			},
+			"the \"consul.hashicorp.com/connect-service-upstreams\" annotation is invalid: upstream \"db:1234;peer=cluster-2;datacenter=dc2\" is invalid: peer and datacenter cannot both be set",
+		},
 	}
 
 	for _, c := range cases {
@@ -1824,6 +1881,110 @@ func TestHandler_checkUnsupportedMultiPortCases(t *testing.T) {
 }
 
 // encodeRaw is a helper to encode some data into a RawExtension.
+// Test that the init containers listed in the exclude init containers annotation are
+// excluded from redirection by the connect-init command, and that the ones added by
+// webhooks that run after the connect injector are assigned the excluded user ID when
+// the webhook is reinvoked.
+func TestHandlerHandle_excludeInitContainers(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	handler := Handler{
+		Log:                    logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:   mapset.NewSet(),
+		EnableTransparentProxy: true,
+		decoder:                decoder,
+		Clientset:              defaultTestClientWithNamespace(),
+	}
+	request := func(pod *corev1.Pod) admission.Request {
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Namespace: namespaces.DefaultNamespace,
+				Object:    encodeRaw(t, pod),
+			},
+		}
+	}
+
+	t.Run("injection", func(t *testing.T) {
+		pod := minimal()
+		pod.Annotations[annotationTProxyExcludeInitContainers] = "vault-agent-init"
+		pod.Spec.InitContainers = []corev1.Container{{Name: "migrate"}}
+
+		resp := handler.Handle(context.Background(), request(pod))
+		require.True(t, resp.Allowed, resp.Result)
+
+		var connectInitCmd string
+		for _, patch := range resp.Patches {
+			// The patch adds each init container after the existing one.
+			container, ok := patch.Value.(map[string]interface{})
+			if !ok || container["name"] != InjectInitContainerName {
+				continue
+			}
+			for _, arg := range container["command"].([]interface{}) {
+				connectInitCmd += arg.(string) + " "
+			}
+		}
+		require.Contains(t, connectInitCmd, `-exclude-uid="5997" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)"`)
+
+		// The existing init container runs before the traffic is redirected, so it
+		// isn't changed.
+		for _, patch := range resp.Patches {
+			require.NotEqual(t, "/spec/initContainers/0/securityContext", patch.Path)
+		}
+	})
+
+	applyInjection := func(pod *corev1.Pod, later ...corev1.Container) *corev1.Pod {
+		pod.Annotations[keyInjectStatus] = injected
+		pod.Spec.InitContainers = append([]corev1.Container{{Name: InjectInitCopyContainerName}, {Name: InjectInitContainerName}}, later...)
+		return pod
+	}
+
+	t.Run("reinvocation assigns the excluded user ID", func(t *testing.T) {
+		pod := minimal()
+		pod.Annotations[annotationTProxyExcludeInitContainers] = "vault-agent-init"
+		pod = applyInjection(pod, corev1.Container{Name: "vault-agent-init"})
+
+		resp := handler.Handle(context.Background(), request(pod))
+		require.True(t, resp.Allowed, resp.Result)
+		require.Equal(t, []jsonpatch.Operation{
+			{
+				Operation: "add",
+				Path:      "/spec/initContainers/2/securityContext",
+				Value:     map[string]interface{}{"runAsUser": float64(excludedInitContainerUserID)},
+			},
+		}, resp.Patches)
+	})
+
+	t.Run("reinvocation without excluded init containers", func(t *testing.T) {
+		pod := applyInjection(minimal(), corev1.Container{Name: "vault-agent-init"})
+
+		resp := handler.Handle(context.Background(), request(pod))
+		require.True(t, resp.Allowed, resp.Result)
+		require.Empty(t, resp.Patches)
+	})
+
+	t.Run("reinvocation rejects root", func(t *testing.T) {
+		pod := minimal()
+		pod.Annotations[annotationTProxyExcludeInitContainers] = "vault-agent-init"
+		pod.Annotations[annotationTProxyExcludeUIDs] = "0"
+		pod = applyInjection(pod, corev1.Container{
+			Name:            "vault-agent-init",
+			SecurityContext: &corev1.SecurityContext{RunAsUser: pointerToInt64(0)},
+		})
+
+		resp := handler.Handle(context.Background(), request(pod))
+		require.False(t, resp.Allowed)
+		require.Contains(t, resp.Result.Message, `init container "vault-agent-init" runs as user 0 which is used by root, Envoy or Consul`)
+	})
+}
+
 func encodeRaw(t *testing.T, input interface{}) runtime.RawExtension {
 	data, err := json.Marshal(input)
 	require.NoError(t, err)
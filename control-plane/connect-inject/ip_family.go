@@ -0,0 +1,67 @@
+package connectinject
+
+import (
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// loopbackAddress returns the loopback address of the address family that the
+// sidecar proxies listen on. It defaults to the IPv4 loopback address.
+func loopbackAddress(family corev1.IPFamily) string {
+	if family == corev1.IPv6Protocol {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
+
+// unspecifiedAddress returns the address that listens on all the addresses of
+// the address family. It defaults to the IPv4 unspecified address.
+func unspecifiedAddress(family corev1.IPFamily) string {
+	if family == corev1.IPv6Protocol {
+		return "::"
+	}
+	return "0.0.0.0"
+}
+
+// podAddress returns the IP of the pod of the address family, which is one of
+// its status.podIPs on dual-stack clusters. If family is empty or the pod has no
+// IP of the family, the primary IP of the pod is returned.
+func podAddress(pod corev1.Pod, family corev1.IPFamily) string {
+	if family != "" {
+		for _, podIP := range pod.Status.PodIPs {
+			if ipFamily(podIP.IP) == family {
+				return podIP.IP
+			}
+		}
+	}
+	return pod.Status.PodIP
+}
+
+// clusterIPAddress returns the cluster IP of the Service of the address family,
+// which is one of its spec.clusterIPs on dual-stack clusters. If family is empty
+// or the Service has no cluster IP of the family, the primary cluster IP is returned.
+func clusterIPAddress(service corev1.Service, family corev1.IPFamily) string {
+	if family != "" {
+		for _, clusterIP := range service.Spec.ClusterIPs {
+			if ipFamily(clusterIP) == family {
+				return clusterIP
+			}
+		}
+	}
+	return service.Spec.ClusterIP
+}
+
+// ipFamily returns the address family of the IP, or an empty family if addr
+// isn't an IP.
+func ipFamily(addr string) corev1.IPFamily {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return corev1.IPv4Protocol
+	default:
+		return corev1.IPv6Protocol
+	}
+}
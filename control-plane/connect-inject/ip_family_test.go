@@ -0,0 +1,208 @@
+package connectinject
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPodAddress(t *testing.T) {
+	dualStack := corev1.Pod{
+		Status: corev1.PodStatus{
+			PodIP:  "10.1.2.3",
+			PodIPs: []corev1.PodIP{{IP: "10.1.2.3"}, {IP: "fd00::3"}},
+		},
+	}
+	require.Equal(t, "10.1.2.3", podAddress(dualStack, ""))
+	require.Equal(t, "10.1.2.3", podAddress(dualStack, corev1.IPv4Protocol))
+	require.Equal(t, "fd00::3", podAddress(dualStack, corev1.IPv6Protocol))
+
+	// The primary pod IP is used if the pod has no IP of the family.
+	ipv6Only := corev1.Pod{
+		Status: corev1.PodStatus{
+			PodIP:  "fd00::3",
+			PodIPs: []corev1.PodIP{{IP: "fd00::3"}},
+		},
+	}
+	require.Equal(t, "fd00::3", podAddress(ipv6Only, corev1.IPv4Protocol))
+}
+
+func TestClusterIPAddress(t *testing.T) {
+	service := corev1.Service{
+		Spec: corev1.ServiceSpec{
+			ClusterIP:  "fd00:10::20",
+			ClusterIPs: []string{"fd00:10::20", "10.96.0.20"},
+		},
+	}
+	require.Equal(t, "fd00:10::20", clusterIPAddress(service, ""))
+	require.Equal(t, "10.96.0.20", clusterIPAddress(service, corev1.IPv4Protocol))
+	require.Equal(t, "fd00:10::20", clusterIPAddress(service, corev1.IPv6Protocol))
+
+	headless := corev1.Service{Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone}}
+	require.Equal(t, corev1.ClusterIPNone, clusterIPAddress(headless, corev1.IPv6Protocol))
+}
+
+// Test that the service instances of a dual-stack pod are registered with the
+// pod IP of the registration address family and that the proxy listens on the
+// local addresses of the listener address family.
+func TestCreateServiceRegistrations_ipFamilies(t *testing.T) {
+	pod := createPod("test-pod", "10.1.2.3", true, true)
+	pod.Status.PodIPs = []corev1.PodIP{{IP: "10.1.2.3"}, {IP: "fd00::3"}}
+	pod.Annotations[annotationPort] = "8080"
+	pod.Annotations[annotationUpstreams] = "db:1234"
+	pod.Annotations[annotationEnableMetrics] = "true"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:  "10.96.0.20",
+			ClusterIPs: []string{"10.96.0.20", "fd00:10::20"},
+			Ports:      []corev1.ServicePort{{Port: 8080}},
+		},
+	}
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, &ns).Build()
+
+	epCtrl := EndpointsController{
+		Client:                 fakeClient,
+		EnableTransparentProxy: true,
+		RegistrationIPFamily:   corev1.IPv6Protocol,
+		ListenerIPFamily:       corev1.IPv6Protocol,
+		MetricsConfig:          MetricsConfig{DefaultPrometheusScrapePort: "20200"},
+		Log:                    logrtest.TestLogger{T: t},
+	}
+	serviceRegistration, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+
+	require.Equal(t, "fd00::3", serviceRegistration.Address)
+	require.Equal(t, map[string]api.ServiceAddress{
+		lanIPv4TaggedAddressName:   {Address: "10.1.2.3", Port: 8080},
+		lanIPv6TaggedAddressName:   {Address: "fd00::3", Port: 8080},
+		clusterIPTaggedAddressName: {Address: "fd00:10::20", Port: 8080},
+	}, serviceRegistration.TaggedAddresses)
+
+	require.Equal(t, "fd00::3", proxyServiceRegistration.Address)
+	require.Equal(t, map[string]api.ServiceAddress{
+		lanIPv4TaggedAddressName:   {Address: "10.1.2.3", Port: 20000},
+		lanIPv6TaggedAddressName:   {Address: "fd00::3", Port: 20000},
+		clusterIPTaggedAddressName: {Address: "fd00:10::20", Port: 8080},
+	}, proxyServiceRegistration.TaggedAddresses)
+	require.Equal(t, "[fd00::3]:20000", proxyServiceRegistration.Checks[0].TCP)
+	require.Equal(t, "::1", proxyServiceRegistration.Proxy.LocalServiceAddress)
+	require.Equal(t, "[::]:20200", proxyServiceRegistration.Proxy.Config[envoyPrometheusBindAddr])
+	require.Len(t, proxyServiceRegistration.Proxy.Upstreams, 1)
+	require.Equal(t, "::1", proxyServiceRegistration.Proxy.Upstreams[0].LocalBindAddress)
+
+	// Without the address families, the primary pod IP and the IPv4 local addresses are used.
+	epCtrl.RegistrationIPFamily = ""
+	epCtrl.ListenerIPFamily = ""
+	serviceRegistration, proxyServiceRegistration, err = epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "10.1.2.3", serviceRegistration.Address)
+	require.Equal(t, map[string]api.ServiceAddress{
+		clusterIPTaggedAddressName: {Address: "10.96.0.20", Port: 8080},
+	}, serviceRegistration.TaggedAddresses)
+	require.Equal(t, "10.1.2.3:20000", proxyServiceRegistration.Checks[0].TCP)
+	require.Equal(t, "127.0.0.1", proxyServiceRegistration.Proxy.LocalServiceAddress)
+	require.Equal(t, "0.0.0.0:20200", proxyServiceRegistration.Proxy.Config[envoyPrometheusBindAddr])
+	require.Empty(t, proxyServiceRegistration.Proxy.Upstreams[0].LocalBindAddress)
+}
+
+// Test that the IPv6 traffic redirection rules are applied with the IPv6 Consul DNS IP
+// and the IPv6 CIDRs to exclude, and the IPv4 rules with the rest.
+func TestHandlerContainerInit_ipv6(t *testing.T) {
+	cases := map[string]struct {
+		dnsIP           string
+		expectedIPv4Cmd string
+		expectedIPv6Cmd string
+	}{
+		"dual-stack": {
+			dnsIP: "10.0.34.16",
+			expectedIPv4Cmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -consul-dns-ip="10.0.34.16" \
+  -exclude-outbound-cidr="10.0.0.0/8" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+			expectedIPv6Cmd: `consul-k8s-control-plane redirect-traffic-ipv6 \
+  -consul-api-timeout=5s \
+  -exclude-outbound-cidr="fd00:1::/64" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+		"IPv6 primary": {
+			dnsIP: "fd00:10::10",
+			expectedIPv4Cmd: `/consul/connect-inject/consul connect redirect-traffic \
+  -exclude-outbound-cidr="10.0.0.0/8" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+			expectedIPv6Cmd: `consul-k8s-control-plane redirect-traffic-ipv6 \
+  -consul-api-timeout=5s \
+  -consul-dns-ip="fd00:10::10" \
+  -exclude-outbound-cidr="fd00:1::/64" \
+  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
+  -proxy-uid=5995`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				EnableTransparentProxy: true,
+				EnableConsulDNS:        true,
+				EnableIPv6:             true,
+				ResourcePrefix:         "consul-consul",
+				ConsulAPITimeout:       5 * time.Second,
+			}
+			os.Setenv("CONSUL_CONSUL_DNS_SERVICE_HOST", c.dnsIP)
+			defer os.Unsetenv("CONSUL_CONSUL_DNS_SERVICE_HOST")
+
+			pod := minimal()
+			pod.Annotations[annotationTProxyExcludeOutboundCIDRs] = "10.0.0.0/8,fd00:1::/64"
+			container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+			require.NoError(t, err)
+			actualCmd := strings.Join(container.Command, " ")
+			require.Contains(t, actualCmd, c.expectedIPv4Cmd)
+			require.Contains(t, actualCmd, c.expectedIPv6Cmd)
+		})
+	}
+
+	// The IPv6 rules aren't applied without IPv6 or without transparent proxy.
+	for _, h := range []Handler{
+		{EnableTransparentProxy: true, ConsulAPITimeout: 5 * time.Second},
+		{EnableIPv6: true, ConsulAPITimeout: 5 * time.Second},
+	} {
+		container, err := h.containerInit(testNS, *minimal(), multiPortInfo{})
+		require.NoError(t, err)
+		require.NotContains(t, strings.Join(container.Command, " "), "redirect-traffic-ipv6")
+	}
+}
+
+func TestContainerEnvVars_ipv6Listeners(t *testing.T) {
+	h := Handler{ListenerIPFamily: corev1.IPv6Protocol}
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService:   "foo",
+				annotationUpstreams: "db:1234",
+			},
+		},
+	}
+	envVars, err := h.containerEnvVars(pod)
+	require.NoError(t, err)
+	require.Contains(t, envVars, corev1.EnvVar{Name: "DB_CONNECT_SERVICE_HOST", Value: "::1"})
+}
@@ -0,0 +1,144 @@
+package connectinject
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// envProxyReadyURL is the environment variable added to the containers of Job pods
+	// with the URL of the Envoy admin endpoint that reports whether the proxy is ready.
+	// Jobs can poll it before making requests through the mesh.
+	envProxyReadyURL = "CONSUL_PROXY_READY_URL"
+
+	// envProxyShutdownURL is the environment variable added to the containers of Job pods
+	// with the URL of the Envoy admin endpoint that shuts down the proxy. Jobs should
+	// POST to it once they are done so that the pod can complete.
+	envProxyShutdownURL = "CONSUL_PROXY_SHUTDOWN_URL"
+
+	// envoyAdminAddress is the address of the Envoy admin API in single port pods.
+	envoyAdminAddress = "127.0.0.1:19000"
+
+	// jobWatcherContainerName is the name of the container that shuts down the Envoy
+	// sidecar once the containers of a Job pod have completed.
+	jobWatcherContainerName = "consul-job-watcher"
+
+	// jobWatcherUserAndGroupID is the user the job watcher runs as. Its traffic to the
+	// Kubernetes API is excluded from transparent proxy redirection.
+	jobWatcherUserAndGroupID = copyContainerUserAndGroupID
+)
+
+// isJobPod returns true if the pod is created by a Kubernetes Job, which includes
+// the Jobs created by CronJobs.
+func isJobPod(pod corev1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "Job" && ref.APIVersion == "batch/v1" {
+			return true
+		}
+	}
+	return false
+}
+
+// jobContainerEnvVars returns the environment variables that let the containers of a Job pod wait
+// for the Envoy sidecar to be ready and shut it down once the Job's work is done. The sidecar
+// otherwise keeps running after the Job's containers exit, which keeps the pod from completing.
+func jobContainerEnvVars() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  envProxyReadyURL,
+			Value: fmt.Sprintf("http://%s/ready", envoyAdminAddress),
+		},
+		{
+			Name:  envProxyShutdownURL,
+			Value: fmt.Sprintf("http://%s/quitquitquit", envoyAdminAddress),
+		},
+	}
+}
+
+// jobWatcherEnabled returns true if the job watcher should be added to the pod.
+func (h *Handler) jobWatcherEnabled(pod corev1.Pod) bool {
+	return h.EnableJobWatcher && isJobPod(pod)
+}
+
+// jobWatcherContainer returns the container that waits for the containers of a Job pod to
+// complete and then shuts down the Envoy sidecar so that the pod can complete. It must be
+// called before the Envoy sidecar is added to the pod so that it only waits for the Job's
+// containers. The job watcher gets the pod from the Kubernetes API, so the pod's service
+// account must be allowed to get pods.
+func (h *Handler) jobWatcherContainer(namespace corev1.Namespace, pod corev1.Pod) (corev1.Container, error) {
+	resources, err := h.consulSidecarResources(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	command := []string{
+		"consul-k8s-control-plane",
+		"job-watcher",
+		"-pod-name=$(POD_NAME)",
+		"-pod-namespace=$(POD_NAMESPACE)",
+		fmt.Sprintf("-envoy-admin-addr=%s", envoyAdminAddress),
+		fmt.Sprintf("-log-level=%s", h.LogLevel),
+		fmt.Sprintf("-log-json=%t", h.LogJSON),
+	}
+	for _, c := range pod.Spec.Containers {
+		command = append(command, fmt.Sprintf("-container=%s", c.Name))
+	}
+
+	container := corev1.Container{
+		Name:  jobWatcherContainerName,
+		Image: h.ImageConsulK8S,
+		Env: []corev1.EnvVar{
+			{
+				Name: "POD_NAME",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+				},
+			},
+			{
+				Name: "POD_NAMESPACE",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+				},
+			},
+		},
+		Command:   command,
+		Resources: resources,
+	}
+
+	tproxyEnabled, err := transparentProxyEnabled(namespace, pod, h.EnableTransparentProxy)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	// When transparent proxy is enabled, the job watcher needs to run as a user whose
+	// traffic is excluded from redirection so that it can reach the Kubernetes API.
+	if tproxyEnabled || !h.EnableOpenShift {
+		if tproxyEnabled {
+			for _, c := range pod.Spec.Containers {
+				if containerUID(pod, c) == jobWatcherUserAndGroupID {
+					return corev1.Container{}, fmt.Errorf("container %q has runAsUser set to the same uid %d as the job watcher which is not allowed", c.Name, jobWatcherUserAndGroupID)
+				}
+			}
+		}
+		container.SecurityContext = &corev1.SecurityContext{
+			RunAsUser:              pointerToInt64(jobWatcherUserAndGroupID),
+			RunAsGroup:             pointerToInt64(jobWatcherUserAndGroupID),
+			RunAsNonRoot:           pointerToBool(true),
+			ReadOnlyRootFilesystem: pointerToBool(true),
+		}
+	}
+	container.SecurityContext = h.restrictSecurityContext(container.SecurityContext)
+
+	return container, nil
+}
+
+// jobWatcherUIDs returns the user IDs to exclude from redirection so that the job watcher
+// of a Job pod can reach the Kubernetes API.
+func (h *Handler) jobWatcherUIDs(pod corev1.Pod) []string {
+	if !h.jobWatcherEnabled(pod) {
+		return nil
+	}
+	return []string{strconv.Itoa(jobWatcherUserAndGroupID)}
+}
@@ -0,0 +1,233 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestIsJobPod(t *testing.T) {
+	cases := map[string]struct {
+		ownerReferences []metav1.OwnerReference
+		expected        bool
+	}{
+		"no owner": {
+			expected: false,
+		},
+		"owned by a ReplicaSet": {
+			ownerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web"}},
+			expected:        false,
+		},
+		"owned by a Job": {
+			ownerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			expected:        true,
+		},
+		"owned by a Job of another API group": {
+			ownerReferences: []metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Job", Name: "migrate"}},
+			expected:        false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := minimal()
+			pod.OwnerReferences = c.ownerReferences
+			require.Equal(t, c.expected, isJobPod(*pod))
+		})
+	}
+}
+
+func TestHandlerHandle_jobPod(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		ownerReferences  []metav1.OwnerReference
+		enableJobWatcher bool
+		expJob           bool
+		expJobWatcher    bool
+	}{
+		"job pod": {
+			ownerReferences:  []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			enableJobWatcher: true,
+			expJob:           true,
+			expJobWatcher:    true,
+		},
+		"job pod without the job watcher": {
+			ownerReferences: []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}},
+			expJob:          true,
+			expJobWatcher:   false,
+		},
+		"non-job pod": {
+			enableJobWatcher: true,
+			expJob:           false,
+			expJobWatcher:    false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				Log:                   logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:  mapset.NewSet(),
+				decoder:               decoder,
+				Clientset:             defaultTestClientWithNamespace(),
+				EnableJobWatcher:      c.enableJobWatcher,
+			}
+			pod := minimal()
+			pod.OwnerReferences = c.ownerReferences
+			request := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: "default",
+					Object:    encodeRaw(t, pod),
+				},
+			}
+
+			resp := h.Handle(context.Background(), request)
+			require.True(t, resp.Allowed)
+
+			var envPatched, jobWatcherAdded bool
+			for _, patch := range resp.Patches {
+				if patch.Path == "/spec/containers/0/env" {
+					envPatched = true
+					require.Equal(t, []interface{}{
+						map[string]interface{}{"name": envProxyReadyURL, "value": "http://127.0.0.1:19000/ready"},
+						map[string]interface{}{"name": envProxyShutdownURL, "value": "http://127.0.0.1:19000/quitquitquit"},
+					}, patch.Value)
+				}
+				if container, ok := patch.Value.(map[string]interface{}); ok && container["name"] == jobWatcherContainerName {
+					jobWatcherAdded = true
+				}
+			}
+			require.Equal(t, c.expJob, envPatched)
+			require.Equal(t, c.expJobWatcher, jobWatcherAdded)
+		})
+	}
+}
+
+func TestHandlerJobWatcherContainer(t *testing.T) {
+	h := Handler{
+		ImageConsulK8S: "hashicorp/consul-k8s:9.9.9",
+		LogLevel:       "info",
+	}
+	pod := minimal()
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}}
+
+	container, err := h.jobWatcherContainer(testNS, *pod)
+	require.NoError(t, err)
+	require.Equal(t, jobWatcherContainerName, container.Name)
+	require.Equal(t, "hashicorp/consul-k8s:9.9.9", container.Image)
+	require.Equal(t, []string{
+		"consul-k8s-control-plane",
+		"job-watcher",
+		"-pod-name=$(POD_NAME)",
+		"-pod-namespace=$(POD_NAMESPACE)",
+		"-envoy-admin-addr=127.0.0.1:19000",
+		"-log-level=info",
+		"-log-json=false",
+		"-container=web",
+		"-container=web-side",
+	}, container.Command)
+	require.Equal(t, []corev1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		},
+		{
+			Name: "POD_NAMESPACE",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+			},
+		},
+	}, container.Env)
+	require.Equal(t, &corev1.SecurityContext{
+		RunAsUser:              pointerToInt64(jobWatcherUserAndGroupID),
+		RunAsGroup:             pointerToInt64(jobWatcherUserAndGroupID),
+		RunAsNonRoot:           pointerToBool(true),
+		ReadOnlyRootFilesystem: pointerToBool(true),
+	}, container.SecurityContext)
+}
+
+func TestHandlerJobWatcherContainer_transparentProxy(t *testing.T) {
+	cases := map[string]struct {
+		openShift    bool
+		tproxy       bool
+		containerUID *int64
+		expSecCtx    bool
+		expErr       string
+	}{
+		"tproxy disabled": {
+			expSecCtx: true,
+		},
+		"tproxy disabled on OpenShift": {
+			openShift: true,
+			expSecCtx: false,
+		},
+		"tproxy enabled on OpenShift": {
+			openShift: true,
+			tproxy:    true,
+			expSecCtx: true,
+		},
+		"container runs as the job watcher user": {
+			tproxy:       true,
+			containerUID: pointerToInt64(jobWatcherUserAndGroupID),
+			expErr:       `container "web" has runAsUser set to the same uid 5996 as the job watcher which is not allowed`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				EnableOpenShift:        c.openShift,
+				EnableTransparentProxy: c.tproxy,
+			}
+			pod := minimal()
+			pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}}
+			if c.containerUID != nil {
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{RunAsUser: c.containerUID}
+			}
+
+			container, err := h.jobWatcherContainer(testNS, *pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expSecCtx, container.SecurityContext != nil)
+		})
+	}
+}
+
+func TestHandlerContainerInit_jobWatcherExcludedFromRedirection(t *testing.T) {
+	h := Handler{EnableTransparentProxy: true, EnableJobWatcher: true}
+
+	pod := minimal()
+	container, err := h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.NotContains(t, container.Command[2], `-exclude-uid="5996"`)
+
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}}
+	container, err = h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.Contains(t, container.Command[2], `-exclude-uid="5996"`)
+
+	// The job watcher's user isn't excluded if the job watcher isn't enabled.
+	h.EnableJobWatcher = false
+	container, err = h.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(t, err)
+	require.NotContains(t, container.Command[2], `-exclude-uid="5996"`)
+}
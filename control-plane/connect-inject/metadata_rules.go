@@ -0,0 +1,172 @@
+package connectinject
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// MetadataSourceNodeLabel copies a label of the Kubernetes node of a pod.
+	MetadataSourceNodeLabel = "node-label"
+	// MetadataSourcePodLabel copies a label of a pod.
+	MetadataSourcePodLabel = "pod-label"
+	// MetadataSourcePodAnnotation copies an annotation of a pod.
+	MetadataSourcePodAnnotation = "pod-annotation"
+
+	// defaultMetadataKeyTemplate is the key template of a rule that doesn't
+	// set one, i.e. the name of the label or annotation without its prefix.
+	defaultMetadataKeyTemplate = "{{ .Key }}"
+
+	// maxMetadataKeyLength is the maximum length of the key of service meta.
+	maxMetadataKeyLength = 128
+)
+
+// invalidMetadataKeyChars matches the characters that Consul doesn't allow in
+// the keys of service meta.
+var invalidMetadataKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// MetadataRule copies the value of a label or an annotation of a pod, or a
+// label of its node, into the meta of its service instances.
+type MetadataRule struct {
+	// Source is where the value is copied from, one of "node-label",
+	// "pod-label" or "pod-annotation".
+	Source string
+	// Name is the name of the label or annotation. If it ends with "*", it's
+	// a prefix and all the labels or annotations with the prefix are copied.
+	Name string
+	// KeyTemplate is the template of the key of the meta. It's executed with
+	// the name of the label or annotation as .Name and the name without its
+	// prefix, e.g. "instance-type" for "node.kubernetes.io/instance-type", as
+	// .Key. The characters that aren't allowed in the key are replaced with
+	// "-".
+	KeyTemplate *template.Template
+}
+
+// metadataKeyData is the data that the key template of a rule is executed
+// with.
+type metadataKeyData struct {
+	Name string
+	Key  string
+}
+
+// ParseMetadataRule parses a rule of the form
+// <source>:<name>[=<key template>], e.g.
+// "node-label:node.kubernetes.io/instance-type=k8s-{{ .Key }}".
+func ParseMetadataRule(raw string) (MetadataRule, error) {
+	source, rest, ok := cut(raw, ":")
+	if !ok {
+		return MetadataRule{}, fmt.Errorf("metadata rule %q must be of the form <source>:<name>[=<key template>]", raw)
+	}
+	switch source {
+	case MetadataSourceNodeLabel, MetadataSourcePodLabel, MetadataSourcePodAnnotation:
+	default:
+		return MetadataRule{}, fmt.Errorf("metadata rule %q has an invalid source %q: must be one of %q, %q or %q",
+			raw, source, MetadataSourceNodeLabel, MetadataSourcePodLabel, MetadataSourcePodAnnotation)
+	}
+	name, keyTemplate, ok := cut(rest, "=")
+	if !ok || keyTemplate == "" {
+		keyTemplate = defaultMetadataKeyTemplate
+	}
+	if name == "" || name == "*" {
+		return MetadataRule{}, fmt.Errorf("metadata rule %q must have the name of a label or annotation", raw)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return MetadataRule{}, fmt.Errorf("metadata rule %q has an invalid key template: %s", raw, err)
+	}
+
+	rule := MetadataRule{Source: source, Name: name, KeyTemplate: tmpl}
+	// Execute the template with the name of the rule so that a template that
+	// fails to execute is caught at startup.
+	if _, err := rule.key(strings.TrimSuffix(name, "*")); err != nil {
+		return MetadataRule{}, fmt.Errorf("metadata rule %q: %s", raw, err)
+	}
+	return rule, nil
+}
+
+// copyMetadata copies the values of the rules from the pod and its node into
+// meta. The keys that are already set, e.g. by the controller or the
+// consul.hashicorp.com/service-meta- annotations, are kept. node may be nil
+// if there are no node label rules.
+func copyMetadata(rules []MetadataRule, pod corev1.Pod, node *corev1.Node, meta map[string]string) error {
+	for _, rule := range rules {
+		var values map[string]string
+		switch rule.Source {
+		case MetadataSourceNodeLabel:
+			if node != nil {
+				values = node.Labels
+			}
+		case MetadataSourcePodLabel:
+			values = pod.Labels
+		case MetadataSourcePodAnnotation:
+			values = pod.Annotations
+		}
+		for name, value := range values {
+			if !rule.matches(name) {
+				continue
+			}
+			key, err := rule.key(name)
+			if err != nil {
+				return err
+			}
+			if _, ok := meta[key]; !ok {
+				meta[key] = value
+			}
+		}
+	}
+	return nil
+}
+
+// hasNodeLabelRules returns true if any of the rules copies a node label.
+func hasNodeLabelRules(rules []MetadataRule) bool {
+	for _, rule := range rules {
+		if rule.Source == MetadataSourceNodeLabel {
+			return true
+		}
+	}
+	return false
+}
+
+func (r MetadataRule) matches(name string) bool {
+	if prefix := strings.TrimSuffix(r.Name, "*"); prefix != r.Name {
+		return strings.HasPrefix(name, prefix)
+	}
+	return name == r.Name
+}
+
+// key returns the key of the meta for the label or annotation name.
+func (r MetadataRule) key(name string) (string, error) {
+	data := metadataKeyData{Name: name, Key: name}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		data.Key = name[i+1:]
+	}
+	var buf bytes.Buffer
+	if err := r.KeyTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("executing the key template for %q: %s", name, err)
+	}
+	key := invalidMetadataKeyChars.ReplaceAllString(buf.String(), "-")
+	if key == "" {
+		return "", fmt.Errorf("the key for %q is empty", name)
+	}
+	if len(key) > maxMetadataKeyLength {
+		return "", fmt.Errorf("the key %q for %q is longer than %d characters", key, name, maxMetadataKeyLength)
+	}
+	if strings.HasPrefix(key, "consul-") {
+		return "", fmt.Errorf("the key %q for %q must not start with %q", key, name, "consul-")
+	}
+	return key, nil
+}
+
+// cut slices s around the first instance of sep. It's strings.Cut, which
+// isn't available in Go 1.17.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,168 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseMetadataRule(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		raw         string
+		expSource   string
+		expName     string
+		expKey      string
+		expErrorMsg string
+	}{
+		"default key template": {
+			raw:       "node-label:node.kubernetes.io/instance-type",
+			expSource: MetadataSourceNodeLabel,
+			expName:   "node.kubernetes.io/instance-type",
+			expKey:    "instance-type",
+		},
+		"key template": {
+			raw:       "pod-label:app.kubernetes.io/version=k8s-{{ .Key }}",
+			expSource: MetadataSourcePodLabel,
+			expName:   "app.kubernetes.io/version",
+			expKey:    "k8s-version",
+		},
+		"invalid characters are replaced": {
+			raw:       "pod-annotation:example.com/team={{ .Name }}",
+			expSource: MetadataSourcePodAnnotation,
+			expName:   "example.com/team",
+			expKey:    "example-com-team",
+		},
+		"no source": {
+			raw:         "node.kubernetes.io/instance-type",
+			expErrorMsg: `metadata rule "node.kubernetes.io/instance-type" must be of the form <source>:<name>[=<key template>]`,
+		},
+		"invalid source": {
+			raw:         "node-annotation:foo",
+			expErrorMsg: `metadata rule "node-annotation:foo" has an invalid source "node-annotation": must be one of "node-label", "pod-label" or "pod-annotation"`,
+		},
+		"no name": {
+			raw:         "pod-label:=foo",
+			expErrorMsg: `metadata rule "pod-label:=foo" must have the name of a label or annotation`,
+		},
+		"invalid key template": {
+			raw:         "pod-label:foo={{ .Key",
+			expErrorMsg: `metadata rule "pod-label:foo={{ .Key" has an invalid key template: template: foo:1: unclosed action`,
+		},
+		"unknown field in key template": {
+			raw:         "pod-label:foo={{ .Value }}",
+			expErrorMsg: `metadata rule "pod-label:foo={{ .Value }}": executing the key template for "foo": template: foo:1:3: executing "foo" at <.Value>: can't evaluate field Value in type connectinject.metadataKeyData`,
+		},
+		"reserved key": {
+			raw:         "pod-label:foo=consul-{{ .Key }}",
+			expErrorMsg: `metadata rule "pod-label:foo=consul-{{ .Key }}": the key "consul-foo" for "foo" must not start with "consul-"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule, err := ParseMetadataRule(c.raw)
+			if c.expErrorMsg != "" {
+				require.EqualError(t, err, c.expErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expSource, rule.Source)
+			require.Equal(t, c.expName, rule.Name)
+			key, err := rule.key(rule.Name)
+			require.NoError(t, err)
+			require.Equal(t, c.expKey, key)
+		})
+	}
+}
+
+func TestCopyMetadata(t *testing.T) {
+	t.Parallel()
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pod1",
+			Labels: map[string]string{
+				"app":                       "web",
+				"app.kubernetes.io/version": "1.0.0",
+				"app.kubernetes.io/part-of": "store",
+			},
+			Annotations: map[string]string{
+				"example.com/team": "payments",
+			},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node1",
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": "m5.large",
+				corev1.LabelTopologyZone:           "us-east-1a",
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		rules   []string
+		node    *corev1.Node
+		expMeta map[string]string
+	}{
+		"no rules": {
+			expMeta: map[string]string{MetaKeyPodName: "pod1"},
+		},
+		"node labels": {
+			rules: []string{
+				"node-label:node.kubernetes.io/instance-type",
+				"node-label:" + corev1.LabelTopologyZone + "=node-{{ .Key }}",
+			},
+			node: node,
+			expMeta: map[string]string{
+				MetaKeyPodName:  "pod1",
+				"instance-type": "m5.large",
+				"node-zone":     "us-east-1a",
+			},
+		},
+		"pod labels with prefix": {
+			rules: []string{"pod-label:app.kubernetes.io/*=app-{{ .Key }}"},
+			expMeta: map[string]string{
+				MetaKeyPodName: "pod1",
+				"app-version":  "1.0.0",
+				"app-part-of":  "store",
+			},
+		},
+		"pod annotations": {
+			rules: []string{"pod-annotation:example.com/team"},
+			expMeta: map[string]string{
+				MetaKeyPodName: "pod1",
+				"team":         "payments",
+			},
+		},
+		"missing values are skipped": {
+			rules: []string{"pod-label:tier", "node-label:node.kubernetes.io/instance-type"},
+			expMeta: map[string]string{
+				MetaKeyPodName: "pod1",
+			},
+		},
+		"existing keys are kept": {
+			rules: []string{"pod-label:app=" + MetaKeyPodName},
+			expMeta: map[string]string{
+				MetaKeyPodName: "pod1",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var rules []MetadataRule
+			for _, raw := range c.rules {
+				rule, err := ParseMetadataRule(raw)
+				require.NoError(t, err)
+				rules = append(rules, rule)
+			}
+			meta := map[string]string{MetaKeyPodName: "pod1"}
+			require.NoError(t, copyMetadata(rules, pod, c.node, meta))
+			require.Equal(t, c.expMeta, meta)
+		})
+	}
+}
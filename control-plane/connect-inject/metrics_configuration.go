@@ -15,6 +15,13 @@ type MetricsConfig struct {
 	DefaultMergedMetricsPort    string
 	DefaultPrometheusScrapePort string
 	DefaultPrometheusScrapePath string
+
+	// EnableLifecycleMetrics configures connect-init to write its metrics to
+	// the shared volume and the consul-sidecar to serve them, together with
+	// its own restarts. They are added to the merged metrics of pods that run
+	// the merged metrics server, and otherwise served on LifecycleMetricsPort.
+	EnableLifecycleMetrics bool
+	LifecycleMetricsPort   string
 }
 
 type metricsPorts struct {
@@ -148,6 +155,12 @@ func (mc MetricsConfig) serviceMetricsPath(pod corev1.Pod) string {
 // container, so it can pass appropriate arguments to the consul connect envoy
 // command.
 func (mc MetricsConfig) shouldRunMergedMetricsServer(pod corev1.Pod) (bool, error) {
+	// The consul-sidecar that runs the merged metrics server would keep running after
+	// a Job is done and keep its pod from completing.
+	if isJobPod(pod) {
+		return false, nil
+	}
+
 	enableMetrics, err := mc.enableMetrics(pod)
 	if err != nil {
 		return false, err
@@ -171,6 +184,13 @@ func (mc MetricsConfig) shouldRunMergedMetricsServer(pod corev1.Pod) (bool, erro
 	return false, nil
 }
 
+// shouldRunLifecycleMetrics returns whether the consul-sidecar serves the
+// lifecycle metrics of the pod. Like the merged metrics server, it doesn't
+// run in Job pods so that they can complete.
+func (mc MetricsConfig) shouldRunLifecycleMetrics(pod corev1.Pod) bool {
+	return mc.EnableLifecycleMetrics && !isJobPod(pod)
+}
+
 // determineAndValidatePort behaves as follows:
 // If the annotation exists, validate the port and return it.
 // If the annotation does not exist, return the default port.
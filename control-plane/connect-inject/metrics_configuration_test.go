@@ -288,6 +288,19 @@ func TestMetricsConfigShouldRunMergedMetricsServer(t *testing.T) {
 			},
 			Expected: false,
 		},
+		{
+			Name: "Returns false for Job pods",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationPort] = "1234"
+				pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate"}}
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			Expected: false,
+		},
 	}
 
 	for _, tt := range cases {
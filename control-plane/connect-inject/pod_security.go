@@ -0,0 +1,36 @@
+package connectinject
+
+import (
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// errTransparentProxyRestricted is returned for pods that enable transparent proxy when the
+// injected containers must comply with the restricted Pod Security Standard. The traffic
+// redirection rules can only be applied by a privileged root container with NET_ADMIN.
+var errTransparentProxyRestricted = errors.New("transparent proxy can't be enabled when restricted pod security is enabled " +
+	"because applying the traffic redirection rules requires a privileged container with the NET_ADMIN capability")
+
+// restrictSecurityContext returns the security context of an injected container with the
+// settings that the restricted Pod Security Standard requires if restricted pod security
+// is enabled, and sc otherwise. The user and group aren't set so that on OpenShift the
+// restricted-v2 SCC can assign them.
+func (h *Handler) restrictSecurityContext(sc *corev1.SecurityContext) *corev1.SecurityContext {
+	if !h.EnableRestrictedPodSecurity {
+		return sc
+	}
+	if sc == nil {
+		sc = &corev1.SecurityContext{}
+	}
+	sc.AllowPrivilegeEscalation = pointerToBool(false)
+	sc.Capabilities = &corev1.Capabilities{
+		Drop: []corev1.Capability{"ALL"},
+	}
+	sc.RunAsNonRoot = pointerToBool(true)
+	sc.ReadOnlyRootFilesystem = pointerToBool(true)
+	sc.SeccompProfile = &corev1.SeccompProfile{
+		Type: corev1.SeccompProfileTypeRuntimeDefault,
+	}
+	return sc
+}
@@ -0,0 +1,137 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Test that the injected containers comply with the restricted Pod Security Standard,
+// with and without OpenShift assigning the user.
+func TestHandler_restrictedPodSecurity(t *testing.T) {
+	for _, openShift := range []bool{false, true} {
+		t.Run(map[bool]string{false: "kubernetes", true: "openshift"}[openShift], func(t *testing.T) {
+			h := Handler{
+				EnableRestrictedPodSecurity: true,
+				EnableOpenShift:             openShift,
+				ImageConsul:                 "hashicorp/consul:latest",
+				ImageEnvoy:                  "hashicorp/consul-envoy:latest",
+				ImageConsulK8S:              "hashicorp/consul-k8s:latest",
+			}
+			pod := *minimal()
+
+			var containers []corev1.Container
+			containers = append(containers, h.initCopyContainer())
+			initContainer, err := h.containerInit(testNS, pod, multiPortInfo{})
+			require.NoError(t, err)
+			containers = append(containers, initContainer)
+			envoySidecar, err := h.envoySidecar(testNS, pod, multiPortInfo{})
+			require.NoError(t, err)
+			containers = append(containers, envoySidecar)
+			consulSidecar, err := h.consulSidecar(pod)
+			require.NoError(t, err)
+			containers = append(containers, consulSidecar)
+			jobWatcher, err := h.jobWatcherContainer(testNS, pod)
+			require.NoError(t, err)
+			containers = append(containers, jobWatcher)
+
+			for _, c := range containers {
+				sc := c.SecurityContext
+				require.NotNil(t, sc, c.Name)
+				require.Equal(t, pointerToBool(false), sc.AllowPrivilegeEscalation, c.Name)
+				require.Equal(t, &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}, sc.Capabilities, c.Name)
+				require.Equal(t, pointerToBool(true), sc.RunAsNonRoot, c.Name)
+				require.Equal(t, pointerToBool(true), sc.ReadOnlyRootFilesystem, c.Name)
+				require.Equal(t, &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}, sc.SeccompProfile, c.Name)
+				require.Nil(t, sc.Privileged, c.Name)
+			}
+			// On OpenShift the restricted-v2 SCC assigns the user of the containers.
+			if openShift {
+				for _, c := range containers {
+					require.Nil(t, c.SecurityContext.RunAsUser, c.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_restrictSecurityContext(t *testing.T) {
+	sc := &corev1.SecurityContext{RunAsUser: pointerToInt64(envoyUserAndGroupID)}
+
+	// The security context is left unchanged if restricted pod security isn't enabled.
+	h := Handler{}
+	require.Nil(t, h.restrictSecurityContext(nil))
+	require.Equal(t, &corev1.SecurityContext{RunAsUser: pointerToInt64(envoyUserAndGroupID)}, h.restrictSecurityContext(sc))
+
+	// The user of the container is kept.
+	h.EnableRestrictedPodSecurity = true
+	require.Equal(t, &corev1.SecurityContext{
+		RunAsUser:                pointerToInt64(envoyUserAndGroupID),
+		RunAsNonRoot:             pointerToBool(true),
+		ReadOnlyRootFilesystem:   pointerToBool(true),
+		AllowPrivilegeEscalation: pointerToBool(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}, h.restrictSecurityContext(sc))
+}
+
+// Test that pods that enable transparent proxy are rejected because the redirection rules
+// can't be applied by a container that complies with the restricted Pod Security Standard.
+func TestHandlerHandle_restrictedPodSecurityRejectsTransparentProxy(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		enableTransparentProxy bool
+		annotations            map[string]string
+		expAllowed             bool
+	}{
+		"transparent proxy disabled": {
+			expAllowed: true,
+		},
+		"transparent proxy enabled by default": {
+			enableTransparentProxy: true,
+		},
+		"transparent proxy enabled by annotation": {
+			annotations: map[string]string{keyTransparentProxy: "true"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := Handler{
+				Log:                         logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:       mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:        mapset.NewSet(),
+				EnableTransparentProxy:      c.enableTransparentProxy,
+				EnableRestrictedPodSecurity: true,
+				Clientset:                   defaultTestClientWithNamespace(),
+				decoder:                     decoder,
+			}
+			pod := minimal()
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			resp := h.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object:    encodeRaw(t, pod),
+				},
+			})
+			require.Equal(t, c.expAllowed, resp.Allowed)
+			if !c.expAllowed {
+				require.Contains(t, resp.Result.Message, errTransparentProxyRestricted.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package connectinject
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	envoyTracingJSON             = "envoy_tracing_json"
+	envoyExtraStaticClustersJSON = "envoy_extra_static_clusters_json"
+
+	// zipkinClusterName is the name of the Envoy cluster of the Zipkin
+	// collector that Envoy sidecars send their traces to.
+	zipkinClusterName = "consul_k8s_zipkin"
+)
+
+// zipkinTracingConfig returns the envoy_tracing_json and
+// envoy_extra_static_clusters_json proxy config that makes Envoy send traces
+// to the Zipkin collector at address, e.g. the Zipkin receiver of an
+// OpenTelemetry Collector.
+func zipkinTracingConfig(address string) (string, string, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid Zipkin address %q: %s", address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid Zipkin address %q: %s", address, err)
+	}
+
+	tracing, err := json.Marshal(map[string]interface{}{
+		"http": map[string]interface{}{
+			"name": "envoy.tracers.zipkin",
+			"typedConfig": map[string]interface{}{
+				"@type":                      "type.googleapis.com/envoy.config.trace.v3.ZipkinConfig",
+				"collector_cluster":          zipkinClusterName,
+				"collector_endpoint_version": "HTTP_JSON",
+				"collector_endpoint":         "/api/v2/spans",
+				"shared_span_context":        false,
+			},
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// The collector is usually addressed by the DNS name of its Kubernetes
+	// service, so it's resolved with STRICT_DNS.
+	cluster, err := json.Marshal(map[string]interface{}{
+		"name":            zipkinClusterName,
+		"type":            "STRICT_DNS",
+		"connect_timeout": "5s",
+		"load_assignment": map[string]interface{}{
+			"cluster_name": zipkinClusterName,
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"lb_endpoints": []interface{}{
+						map[string]interface{}{
+							"endpoint": map[string]interface{}{
+								"address": map[string]interface{}{
+									"socket_address": map[string]interface{}{
+										"address":    host,
+										"port_value": port,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return string(tracing), string(cluster), nil
+}
@@ -0,0 +1,111 @@
+package connectinject
+
+import (
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestZipkinTracingConfig(t *testing.T) {
+	tracing, clusters, err := zipkinTracingConfig("otel-collector.consul.svc:9411")
+	require.NoError(t, err)
+
+	require.JSONEq(t, `{
+  "http": {
+    "name": "envoy.tracers.zipkin",
+    "typedConfig": {
+      "@type": "type.googleapis.com/envoy.config.trace.v3.ZipkinConfig",
+      "collector_cluster": "consul_k8s_zipkin",
+      "collector_endpoint_version": "HTTP_JSON",
+      "collector_endpoint": "/api/v2/spans",
+      "shared_span_context": false
+    }
+  }
+}`, tracing)
+
+	require.JSONEq(t, `{
+  "name": "consul_k8s_zipkin",
+  "type": "STRICT_DNS",
+  "connect_timeout": "5s",
+  "load_assignment": {
+    "cluster_name": "consul_k8s_zipkin",
+    "endpoints": [
+      {
+        "lb_endpoints": [
+          {
+            "endpoint": {
+              "address": {
+                "socket_address": {
+                  "address": "otel-collector.consul.svc",
+                  "port_value": 9411
+                }
+              }
+            }
+          }
+        ]
+      }
+    ]
+  }
+}`, clusters)
+}
+
+func TestZipkinTracingConfig_invalidAddress(t *testing.T) {
+	for _, address := range []string{"otel-collector", "otel-collector:zipkin"} {
+		_, _, err := zipkinTracingConfig(address)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid Zipkin address")
+	}
+}
+
+// Test that the proxy registration sends traces to -tracing-zipkin-address.
+func TestCreateServiceRegistrations_withTracing(t *testing.T) {
+	pod := createPod("test-pod", "1.2.3.4", true, true)
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP: "1.2.3.4",
+						TargetRef: &corev1.ObjectReference{
+							Kind:      "Pod",
+							Name:      pod.Name,
+							Namespace: pod.Namespace,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, &ns).Build()
+
+	epCtrl := EndpointsController{
+		Client:               fakeClient,
+		TracingZipkinAddress: "otel-collector.consul.svc:9411",
+		Log:                  logrtest.TestLogger{T: t},
+	}
+	_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+
+	var tracing map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(proxyServiceRegistration.Proxy.Config[envoyTracingJSON].(string)), &tracing))
+	require.Equal(t, "envoy.tracers.zipkin", tracing["http"].(map[string]interface{})["name"])
+	require.Contains(t, proxyServiceRegistration.Proxy.Config[envoyExtraStaticClustersJSON], "otel-collector.consul.svc")
+
+	// Tracing isn't configured by default.
+	epCtrl.TracingZipkinAddress = ""
+	_, proxyServiceRegistration, err = epCtrl.createServiceRegistrations(*pod, *endpoints)
+	require.NoError(t, err)
+	require.NotContains(t, proxyServiceRegistration.Proxy.Config, envoyTracingJSON)
+	require.NotContains(t, proxyServiceRegistration.Proxy.Config, envoyExtraStaticClustersJSON)
+}
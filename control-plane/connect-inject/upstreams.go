@@ -0,0 +1,304 @@
+package connectinject
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// upstreamSettingsSeparator separates an upstream from its optional per-upstream
+	// settings in the upstreams annotation, e.g. `db:1234;peer=cluster-2;connect-timeout=5s`.
+	upstreamSettingsSeparator = ";"
+
+	// Keys of the per-upstream settings that can follow an upstream in the upstreams annotation.
+	upstreamSettingDatacenter       = "datacenter"
+	upstreamSettingPartition        = "partition"
+	upstreamSettingNamespace        = "namespace"
+	upstreamSettingPeer             = "peer"
+	upstreamSettingConnectTimeout   = "connect-timeout"
+	upstreamSettingLocalBindAddress = "local-bind-address"
+	upstreamSettingLocalBindPort    = "local-bind-port"
+
+	// upstreamConfigConnectTimeout is the key in the upstream's opaque proxy
+	// config that Consul uses to configure the upstream cluster's connect timeout.
+	upstreamConfigConnectTimeout = "connect_timeout_ms"
+)
+
+// upstreamEntry is an entry of the upstreams annotation.
+type upstreamEntry struct {
+	// raw is the entry as it is written in the annotation.
+	raw string
+
+	// upstream is the parsed upstream. It is only set if err is nil.
+	upstream api.Upstream
+
+	// err describes why the entry is invalid.
+	err error
+}
+
+// portRange is an inclusive range of ports. The zero value is an empty range.
+type portRange struct {
+	start, end int
+}
+
+// parseUpstreams parses every upstream in the upstreams annotation of the pod.
+// It returns an error describing the first invalid upstream.
+func parseUpstreams(pod corev1.Pod, enableNamespaces, enablePartitions bool) ([]api.Upstream, error) {
+	var upstreams []api.Upstream
+	for _, entry := range parseUpstreamEntries(pod, enableNamespaces, enablePartitions) {
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		upstreams = append(upstreams, entry.upstream)
+	}
+	return upstreams, nil
+}
+
+// parseUpstreamEntries parses each entry of the upstreams annotation of the pod.
+// Upstreams whose local bind port is a range are assigned the lowest port of
+// the range that isn't a container port of the pod or the local bind port of
+// another upstream. Invalid entries are returned with their error so that
+// callers can decide whether to skip them.
+func parseUpstreamEntries(pod corev1.Pod, enableNamespaces, enablePartitions bool) []upstreamEntry {
+	raw, ok := pod.Annotations[annotationUpstreams]
+	if !ok || raw == "" {
+		return nil
+	}
+
+	var entries []upstreamEntry
+	var ranges []portRange
+	usedPorts := make(map[int]bool)
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			usedPorts[int(p.ContainerPort)] = true
+		}
+	}
+	for _, raw := range strings.Split(raw, ",") {
+		upstream, ports, err := parseUpstream(pod, raw, enableNamespaces, enablePartitions)
+		if err == nil && upstream.LocalBindPort != 0 {
+			usedPorts[upstream.LocalBindPort] = true
+		}
+		entries = append(entries, upstreamEntry{raw: raw, upstream: upstream, err: err})
+		ranges = append(ranges, ports)
+	}
+
+	for i, ports := range ranges {
+		if entries[i].err != nil || ports == (portRange{}) {
+			continue
+		}
+		for port := ports.start; port <= ports.end; port++ {
+			if !usedPorts[port] {
+				usedPorts[port] = true
+				entries[i].upstream.LocalBindPort = port
+				break
+			}
+		}
+		if entries[i].upstream.LocalBindPort == 0 {
+			entries[i].err = fmt.Errorf("upstream %q is invalid: all ports of %s %d-%d are already in use",
+				entries[i].raw, upstreamSettingLocalBindPort, ports.start, ports.end)
+		}
+	}
+	return entries
+}
+
+// parseUpstream parses a single upstream from the upstreams annotation. An upstream is
+// either `<service-name>[.<namespace>[.<partition>]]:<port>[:<datacenter>]` or
+// `prepared_query:<query-name>:<port>`, optionally followed by `;<key>=<value>` settings.
+// Namespace and partition are only parsed from the service name when
+// namespaces or partitions are enabled. The port may be omitted if it is set
+// with the local-bind-port setting instead, which can also be a range of
+// ports. If it is a range, the range is returned and the upstream's local
+// bind port is left for the caller to assign.
+func parseUpstream(pod corev1.Pod, raw string, enableNamespaces, enablePartitions bool) (api.Upstream, portRange, error) {
+	pieces := strings.Split(raw, upstreamSettingsSeparator)
+	settings, err := parseUpstreamSettings(raw, pieces[1:])
+	if err != nil {
+		return api.Upstream{}, portRange{}, err
+	}
+
+	// The port is optional if it is set by the local-bind-port setting, in
+	// which case an empty port is added to parse the upstream the same way.
+	rawBindPort, hasBindPort := settings.values[upstreamSettingLocalBindPort]
+	upstreamPart := strings.TrimSpace(pieces[0])
+	if hasBindPort {
+		if strings.HasPrefix(upstreamPart, "prepared_query:") {
+			if strings.Count(upstreamPart, ":") == 1 {
+				upstreamPart += ":"
+			}
+		} else if !strings.Contains(upstreamPart, ":") {
+			upstreamPart += ":"
+		}
+	}
+
+	parts := strings.SplitN(upstreamPart, ":", 3)
+	if len(parts) < 2 {
+		return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: expected format <service-name>:<port>[:<datacenter>]", raw)
+	}
+
+	upstream := api.Upstream{DestinationType: api.UpstreamDestTypeService}
+	rawPort := strings.TrimSpace(parts[1])
+	if strings.TrimSpace(parts[0]) == "prepared_query" {
+		if len(parts) < 3 {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: expected format prepared_query:<query-name>:<port>", raw)
+		}
+		upstream.DestinationType = api.UpstreamDestTypePreparedQuery
+		upstream.DestinationName = strings.TrimSpace(parts[1])
+		rawPort = strings.TrimSpace(parts[2])
+	} else {
+		if enableNamespaces || enablePartitions {
+			names := strings.SplitN(parts[0], ".", 3)
+			switch len(names) {
+			case 3:
+				upstream.DestinationPartition = strings.TrimSpace(names[2])
+				fallthrough
+			case 2:
+				upstream.DestinationNamespace = strings.TrimSpace(names[1])
+				fallthrough
+			default:
+				upstream.DestinationName = strings.TrimSpace(names[0])
+			}
+		} else {
+			upstream.DestinationName = strings.TrimSpace(parts[0])
+		}
+		if len(parts) > 2 {
+			upstream.Datacenter = strings.TrimSpace(parts[2])
+		}
+	}
+
+	if upstream.DestinationName == "" {
+		return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: destination name must not be empty", raw)
+	}
+
+	var ports portRange
+	if hasBindPort {
+		if rawPort != "" {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: the port and %s cannot both be set", raw, upstreamSettingLocalBindPort)
+		}
+		ports, err = parsePortRange(rawBindPort)
+		if err != nil {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %s %q %s", raw, upstreamSettingLocalBindPort, rawBindPort, err)
+		}
+		if ports.start == ports.end {
+			upstream.LocalBindPort = ports.start
+			ports = portRange{}
+		}
+	} else {
+		port, err := portValue(pod, rawPort)
+		if err != nil || port < 1 || port > 65535 {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %q is not a port between 1 and 65535 or a named container port", raw, rawPort)
+		}
+		upstream.LocalBindPort = int(port)
+	}
+
+	for _, key := range settings.keys {
+		value := settings.values[key]
+		switch key {
+		case upstreamSettingDatacenter:
+			if upstream.Datacenter != "" {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: datacenter is specified more than once", raw)
+			}
+			upstream.Datacenter = value
+		case upstreamSettingNamespace:
+			if !enableNamespaces {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %q can only be set when Consul namespaces are enabled", raw, key)
+			}
+			if upstream.DestinationNamespace != "" {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: namespace is specified more than once", raw)
+			}
+			upstream.DestinationNamespace = value
+		case upstreamSettingPartition:
+			if !enablePartitions {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %q can only be set when Consul admin partitions are enabled", raw, key)
+			}
+			if upstream.DestinationPartition != "" {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: partition is specified more than once", raw)
+			}
+			upstream.DestinationPartition = value
+		case upstreamSettingPeer:
+			upstream.DestinationPeer = value
+		case upstreamSettingConnectTimeout:
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < time.Millisecond {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %s %q must be a duration of at least 1ms, e.g. 5s", raw, key, value)
+			}
+			upstream.Config = map[string]interface{}{
+				upstreamConfigConnectTimeout: int(timeout.Milliseconds()),
+			}
+		case upstreamSettingLocalBindAddress:
+			if net.ParseIP(value) == nil {
+				return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: %s %q is not a valid IP address", raw, key, value)
+			}
+			upstream.LocalBindAddress = value
+		case upstreamSettingLocalBindPort:
+			// The local bind port has been parsed above.
+		default:
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: unknown setting %q, must be one of %s", raw, key,
+				strings.Join([]string{upstreamSettingDatacenter, upstreamSettingNamespace, upstreamSettingPartition,
+					upstreamSettingPeer, upstreamSettingConnectTimeout, upstreamSettingLocalBindAddress, upstreamSettingLocalBindPort}, ", "))
+		}
+	}
+
+	if upstream.DestinationPeer != "" {
+		if upstream.Datacenter != "" {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: peer and datacenter cannot both be set", raw)
+		}
+		if upstream.DestinationPartition != "" {
+			return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: peer and partition cannot both be set", raw)
+		}
+	}
+	if upstream.DestinationType == api.UpstreamDestTypePreparedQuery &&
+		(upstream.DestinationPeer != "" || upstream.DestinationNamespace != "" || upstream.DestinationPartition != "") {
+		return api.Upstream{}, portRange{}, fmt.Errorf("upstream %q is invalid: prepared query upstreams do not support peer, namespace or partition", raw)
+	}
+
+	return upstream, ports, nil
+}
+
+// upstreamSettings are the `;<key>=<value>` settings of an upstream in the
+// order they are specified.
+type upstreamSettings struct {
+	keys   []string
+	values map[string]string
+}
+
+// parseUpstreamSettings parses the settings that follow the upstream raw.
+func parseUpstreamSettings(raw string, pieces []string) (upstreamSettings, error) {
+	settings := upstreamSettings{values: make(map[string]string)}
+	for _, setting := range pieces {
+		kv := strings.SplitN(setting, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return upstreamSettings{}, fmt.Errorf("upstream %q is invalid: setting %q must be in the format <key>=<value>", raw, strings.TrimSpace(setting))
+		}
+		if _, ok := settings.values[key]; ok {
+			return upstreamSettings{}, fmt.Errorf("upstream %q is invalid: setting %q is specified more than once", raw, key)
+		}
+		settings.keys = append(settings.keys, key)
+		settings.values[key] = strings.TrimSpace(kv[1])
+	}
+	return settings, nil
+}
+
+// parsePortRange parses a port or an inclusive range of ports in the form
+// `<start>-<end>`.
+func parsePortRange(raw string) (portRange, error) {
+	rawStart, rawEnd := raw, raw
+	if idx := strings.Index(raw, "-"); idx != -1 {
+		rawStart, rawEnd = raw[:idx], raw[idx+1:]
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(rawStart))
+	if err != nil || start < 1 || start > 65535 {
+		return portRange{}, fmt.Errorf("must be a port between 1 and 65535 or a range of ports, e.g. 20000-20100")
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(rawEnd))
+	if err != nil || end < start || end > 65535 {
+		return portRange{}, fmt.Errorf("must be a port between 1 and 65535 or a range of ports, e.g. 20000-20100")
+	}
+	return portRange{start: start, end: end}, nil
+}
@@ -0,0 +1,269 @@
+package connectinject
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	cases := []struct {
+		name             string
+		annotation       string
+		enableNamespaces bool
+		enablePartitions bool
+		expected         []api.Upstream
+		expErr           string
+	}{
+		{
+			name:       "no upstreams",
+			annotation: "",
+		},
+		{
+			name:       "legacy upstreams",
+			annotation: "upstream1:1234, upstream2:2234:dc2, prepared_query:queryname:3234",
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					LocalBindPort:   1234,
+				},
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream2",
+					Datacenter:      "dc2",
+					LocalBindPort:   2234,
+				},
+				{
+					DestinationType: api.UpstreamDestTypePreparedQuery,
+					DestinationName: "queryname",
+					LocalBindPort:   3234,
+				},
+			},
+		},
+		{
+			name:       "named port",
+			annotation: "upstream1:web",
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					LocalBindPort:   8080,
+				},
+			},
+		},
+		{
+			name:       "peer with connect timeout and local bind address",
+			annotation: "upstream1:1234;peer=cluster-2;connect-timeout=5s;local-bind-address=127.0.0.2",
+			expected: []api.Upstream{
+				{
+					DestinationType:  api.UpstreamDestTypeService,
+					DestinationName:  "upstream1",
+					DestinationPeer:  "cluster-2",
+					LocalBindAddress: "127.0.0.2",
+					LocalBindPort:    1234,
+					Config:           map[string]interface{}{"connect_timeout_ms": 5000},
+				},
+			},
+		},
+		{
+			name:             "namespace and partition settings",
+			annotation:       "upstream1:1234; namespace=ns1 ; partition=ap1; datacenter=dc2",
+			enableNamespaces: true,
+			enablePartitions: true,
+			expected: []api.Upstream{
+				{
+					DestinationType:      api.UpstreamDestTypeService,
+					DestinationName:      "upstream1",
+					DestinationNamespace: "ns1",
+					DestinationPartition: "ap1",
+					Datacenter:           "dc2",
+					LocalBindPort:        1234,
+				},
+			},
+		},
+		{
+			name:             "namespace in the service name",
+			annotation:       "upstream1.ns1:1234",
+			enableNamespaces: true,
+			expected: []api.Upstream{
+				{
+					DestinationType:      api.UpstreamDestTypeService,
+					DestinationName:      "upstream1",
+					DestinationNamespace: "ns1",
+					LocalBindPort:        1234,
+				},
+			},
+		},
+		{
+			name:       "missing port",
+			annotation: "upstream1",
+			expErr:     `upstream "upstream1" is invalid: expected format <service-name>:<port>[:<datacenter>]`,
+		},
+		{
+			name:       "invalid port",
+			annotation: "upstream1:notaport",
+			expErr:     `upstream "upstream1:notaport" is invalid: "notaport" is not a port between 1 and 65535 or a named container port`,
+		},
+		{
+			name:       "port out of range",
+			annotation: "upstream1:70000",
+			expErr:     `upstream "upstream1:70000" is invalid: "70000" is not a port between 1 and 65535 or a named container port`,
+		},
+		{
+			name:       "unknown setting",
+			annotation: "upstream1:1234;foo=bar",
+			expErr:     `upstream "upstream1:1234;foo=bar" is invalid: unknown setting "foo", must be one of datacenter, namespace, partition, peer, connect-timeout, local-bind-address, local-bind-port`,
+		},
+		{
+			name:       "setting without value",
+			annotation: "upstream1:1234;peer",
+			expErr:     `upstream "upstream1:1234;peer" is invalid: setting "peer" must be in the format <key>=<value>`,
+		},
+		{
+			name:       "duplicate setting",
+			annotation: "upstream1:1234;peer=a;peer=b",
+			expErr:     `upstream "upstream1:1234;peer=a;peer=b" is invalid: setting "peer" is specified more than once`,
+		},
+		{
+			name:       "datacenter set twice",
+			annotation: "upstream1:1234:dc1;datacenter=dc2",
+			expErr:     `upstream "upstream1:1234:dc1;datacenter=dc2" is invalid: datacenter is specified more than once`,
+		},
+		{
+			name:       "peer and datacenter",
+			annotation: "upstream1:1234:dc1;peer=cluster-2",
+			expErr:     `upstream "upstream1:1234:dc1;peer=cluster-2" is invalid: peer and datacenter cannot both be set`,
+		},
+		{
+			name:             "peer and partition",
+			annotation:       "upstream1:1234;peer=cluster-2;partition=ap1",
+			enablePartitions: true,
+			expErr:           `upstream "upstream1:1234;peer=cluster-2;partition=ap1" is invalid: peer and partition cannot both be set`,
+		},
+		{
+			name:       "namespace when namespaces are disabled",
+			annotation: "upstream1:1234;namespace=ns1",
+			expErr:     `upstream "upstream1:1234;namespace=ns1" is invalid: "namespace" can only be set when Consul namespaces are enabled`,
+		},
+		{
+			name:       "partition when partitions are disabled",
+			annotation: "upstream1:1234;partition=ap1",
+			expErr:     `upstream "upstream1:1234;partition=ap1" is invalid: "partition" can only be set when Consul admin partitions are enabled`,
+		},
+		{
+			name:       "invalid connect timeout",
+			annotation: "upstream1:1234;connect-timeout=5",
+			expErr:     `upstream "upstream1:1234;connect-timeout=5" is invalid: connect-timeout "5" must be a duration of at least 1ms, e.g. 5s`,
+		},
+		{
+			name:       "invalid local bind address",
+			annotation: "upstream1:1234;local-bind-address=localhost",
+			expErr:     `upstream "upstream1:1234;local-bind-address=localhost" is invalid: local-bind-address "localhost" is not a valid IP address`,
+		},
+		{
+			name:       "local bind port",
+			annotation: "upstream1;local-bind-port=1234, prepared_query:queryname;local-bind-port=2234",
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					LocalBindPort:   1234,
+				},
+				{
+					DestinationType: api.UpstreamDestTypePreparedQuery,
+					DestinationName: "queryname",
+					LocalBindPort:   2234,
+				},
+			},
+		},
+		{
+			name:       "local bind port ranges skip used ports",
+			annotation: "upstream1;local-bind-port=8080-8090, upstream2:8081, upstream3;local-bind-port=8080-8090, upstream4::dc2;local-bind-port=9000-9000",
+			expected: []api.Upstream{
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream1",
+					LocalBindPort:   8082,
+				},
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream2",
+					LocalBindPort:   8081,
+				},
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream3",
+					LocalBindPort:   8083,
+				},
+				{
+					DestinationType: api.UpstreamDestTypeService,
+					DestinationName: "upstream4",
+					Datacenter:      "dc2",
+					LocalBindPort:   9000,
+				},
+			},
+		},
+		{
+			name:       "local bind port range in use",
+			annotation: "upstream1:8081, upstream2;local-bind-port=8080-8081",
+			expErr:     `upstream " upstream2;local-bind-port=8080-8081" is invalid: all ports of local-bind-port 8080-8081 are already in use`,
+		},
+		{
+			name:       "port and local bind port",
+			annotation: "upstream1:1234;local-bind-port=1235",
+			expErr:     `upstream "upstream1:1234;local-bind-port=1235" is invalid: the port and local-bind-port cannot both be set`,
+		},
+		{
+			name:       "invalid local bind port range",
+			annotation: "upstream1;local-bind-port=2000-1000",
+			expErr:     `upstream "upstream1;local-bind-port=2000-1000" is invalid: local-bind-port "2000-1000" must be a port between 1 and 65535 or a range of ports, e.g. 20000-20100`,
+		},
+		{
+			name:       "missing port",
+			annotation: "upstream1",
+			expErr:     `upstream "upstream1" is invalid: expected format <service-name>:<port>[:<datacenter>]`,
+		},
+		{
+			name:       "prepared query with peer",
+			annotation: "prepared_query:queryname:1234;peer=cluster-2",
+			expErr:     `upstream "prepared_query:queryname:1234;peer=cluster-2" is invalid: prepared query upstreams do not support peer, namespace or partition`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotationUpstreams: c.annotation,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "web",
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "web",
+									ContainerPort: 8080,
+								},
+							},
+						},
+					},
+				},
+			}
+
+			upstreams, err := parseUpstreams(pod, c.enableNamespaces, c.enablePartitions)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, c.expected, upstreams)
+			}
+		})
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tracing"
 	"github.com/hashicorp/consul-k8s/control-plane/version"
 	capi "github.com/hashicorp/consul/api"
 )
 
 // NewClient returns a Consul API client. It adds a required User-Agent
-// header that describes the version of consul-k8s making the call.
+// header that describes the version of consul-k8s making the call, and
+// traces the calls made within a traced reconcile.
 func NewClient(config *capi.Config, consulAPITimeout time.Duration) (*capi.Client, error) {
 	if consulAPITimeout <= 0 {
 		// This is only here as a last resort scenario.  This should not get
@@ -40,7 +42,7 @@ func NewClient(config *capi.Config, consulAPITimeout time.Duration) (*capi.Clien
 
 		config.Transport.TLSClientConfig = tlsClientConfig
 	}
-	config.HttpClient.Transport = config.Transport
+	config.HttpClient.Transport = tracing.Transport(config.Transport)
 
 	client, err := capi.NewClient(config)
 	if err != nil {
@@ -9,13 +9,18 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/sharding"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tracing"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	capi "github.com/hashicorp/consul/api"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,6 +33,7 @@ const (
 	ConsulAgentError             = "ConsulAgentError"
 	ExternallyManagedConfigError = "ExternallyManagedConfigError"
 	MigrationFailedError         = "MigrationFailedError"
+	ReferenceNotFoundError       = "ReferenceNotFoundError"
 )
 
 // Controller is implemented by CRD-specific controllers. It is used by
@@ -46,6 +52,28 @@ type Controller interface {
 	Logger(types.NamespacedName) logr.Logger
 }
 
+// ReferenceValidator is optionally implemented by CRD-specific controllers
+// whose resources reference other Consul resources, such as services or
+// peers, that must exist before the config entry is written.
+type ReferenceValidator interface {
+	// ValidateReferences returns an error if a resource that the config
+	// entry references doesn't exist.
+	ValidateReferences(context.Context, common.ConfigEntryResource) error
+}
+
+// ConsulResourceSyncer is optionally implemented by CRD-specific controllers
+// whose resources manage Consul resources other than their config entry, such
+// as catalog registrations or ACL policies.
+type ConsulResourceSyncer interface {
+	// SyncConsulResources creates or updates the resources. It's called
+	// before the config entry is written so that the config entry doesn't
+	// reference resources that don't exist yet.
+	SyncConsulResources(context.Context, common.ConfigEntryResource) error
+	// DeleteConsulResources deletes the resources once the config entry has
+	// been deleted.
+	DeleteConsulResources(context.Context, common.ConfigEntryResource) error
+}
+
 // ConfigEntryController is a generic controller that is used to reconcile
 // all config entry types, e.g. ServiceDefaults, ServiceResolver, etc, since
 // they share the same reconcile behaviour.
@@ -79,15 +107,42 @@ type ConfigEntryController struct {
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
 	CrossNSACLPolicy string
+
+	// Recorder records a Kubernetes event on the resource when it fails to
+	// sync. If it's nil, no events are recorded.
+	Recorder record.EventRecorder
+
+	// Shards, if set, limits the controllers to the resources in the
+	// namespaces of the shards that this replica owns so that all the
+	// replicas reconcile resources at the same time.
+	Shards *sharding.Coordinator
 }
 
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
 // ReconcileEntry reconciles an update to a resource. CRD-specific controller's
 // call this function because it handles reconciliation of config entries
 // generically.
 // CRD-specific controller should pass themselves in as updater since we
 // need to call back into their own update methods to ensure they update their
 // internal state.
-func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
+// The reconcile is traced with a span that the Kubernetes and Consul calls
+// of the reconcile are children of.
+func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "ConfigEntryController.Reconcile",
+		attribute.String("k8s.namespace", req.Namespace),
+		attribute.String("k8s.name", req.Name),
+		attribute.String("consul.config_entry.kind", configEntry.KubeKind()))
+	defer func() {
+		span.SetAttributes(
+			attribute.Bool("reconcile.requeue", result.Requeue || err != nil),
+			attribute.Int64("reconcile.requeue_after_ms", result.RequeueAfter.Milliseconds()))
+		tracing.End(span, err)
+	}()
+	return r.reconcileEntry(ctx, crdCtrl, req, configEntry)
+}
+
+func (r *ConfigEntryController) reconcileEntry(ctx context.Context, crdCtrl Controller, req ctrl.Request, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
 	logger := crdCtrl.Logger(req.NamespacedName)
 	err := crdCtrl.Get(ctx, req.NamespacedName, configEntry)
 	if k8serr.IsNotFound(err) {
@@ -114,9 +169,9 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		if containsString(configEntry.GetFinalizers(), FinalizerName) {
 			logger.Info("deletion event")
 			// Check to see if consul has config entry with the same name
-			entry, _, err := r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
+			entry, _, err := r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), (&capi.QueryOptions{
 				Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-			})
+			}).WithContext(ctx))
 
 			// Ignore the error where the config entry isn't found in Consul.
 			// It is indicative of desired state.
@@ -125,9 +180,9 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 			} else if err == nil {
 				// Only delete the resource from Consul if it is owned by our datacenter.
 				if entry.GetMeta()[common.DatacenterKey] == r.DatacenterName {
-					_, err := r.ConsulClient.ConfigEntries().Delete(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.WriteOptions{
+					_, err := r.ConsulClient.ConfigEntries().Delete(configEntry.ConsulKind(), configEntry.ConsulName(), (&capi.WriteOptions{
 						Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-					})
+					}).WithContext(ctx))
 					if err != nil {
 						return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 							fmt.Errorf("deleting config entry from consul: %w", err))
@@ -137,6 +192,11 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 					logger.Info("config entry in Consul was created in another datacenter - skipping delete from Consul", "external-datacenter", entry.GetMeta()[common.DatacenterKey])
 				}
 			}
+			if syncer, ok := crdCtrl.(ConsulResourceSyncer); ok {
+				if err := syncer.DeleteConsulResources(ctx, configEntry); err != nil {
+					return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError, err)
+				}
+			}
 			// remove our finalizer from the list and update it.
 			configEntry.RemoveFinalizer(FinalizerName)
 			if err := crdCtrl.Update(ctx, configEntry); err != nil {
@@ -149,10 +209,25 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		return ctrl.Result{}, nil
 	}
 
+	// Don't write the config entry until the resources it references exist.
+	// The resource is requeued so that it's written once they do.
+	if validator, ok := crdCtrl.(ReferenceValidator); ok {
+		if err := validator.ValidateReferences(ctx, configEntry); err != nil {
+			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ReferenceNotFoundError, err)
+		}
+	}
+	configEntry.SetCondition(string(v1alpha1.ConditionValidated), corev1.ConditionTrue, "", "")
+
+	if syncer, ok := crdCtrl.(ConsulResourceSyncer); ok {
+		if err := syncer.SyncConsulResources(ctx, configEntry); err != nil {
+			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError, err)
+		}
+	}
+
 	// Check to see if consul has config entry with the same name
-	entry, _, err := r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
+	entry, _, err := r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), (&capi.QueryOptions{
 		Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-	})
+	}).WithContext(ctx))
 	// If a config entry with this name does not exist
 	if isNotFoundErr(err) {
 		logger.Info("config entry not found in consul")
@@ -167,20 +242,20 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 					fmt.Errorf("creating consul namespace %q: %w", consulNS, err))
 			}
 			if created {
-				logger.Info("consul namespace created", "ns", consulNS)
+				logger.Info("consul namespace created", "consul-namespace", consulNS)
 			}
 		}
 
 		// Create the config entry
-		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, &capi.WriteOptions{
+		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, (&capi.WriteOptions{
 			Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-		})
+		}).WithContext(ctx))
 		if err != nil {
 			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("writing config entry to consul: %w", err))
 		}
 		logger.Info("config entry created", "request-time", writeMeta.RequestTime)
-		return r.syncSuccessful(ctx, crdCtrl, configEntry)
+		return r.syncWritten(ctx, logger, crdCtrl, configEntry, consulEntry)
 	}
 
 	// If there is an error when trying to get the config entry from the api server,
@@ -220,39 +295,40 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		}
 
 		logger.Info("config entry does not match consul", "modify-index", entry.GetModifyIndex())
-		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, &capi.WriteOptions{
+		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, (&capi.WriteOptions{
 			Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-		})
+		}).WithContext(ctx))
 		if err != nil {
 			return r.syncUnknownWithError(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
 		logger.Info("config entry updated", "request-time", writeMeta.RequestTime)
-		return r.syncSuccessful(ctx, crdCtrl, configEntry)
+		return r.syncWritten(ctx, logger, crdCtrl, configEntry, consulEntry)
 	} else if requiresMigration && entry.GetMeta()[common.DatacenterKey] != r.DatacenterName {
 		// If we get here then we're doing a migration and the entry in Consul
 		// matches the entry in Kubernetes. We just need to update the metadata
 		// of the entry in Consul to say that it's now managed by Kubernetes.
 		logger.Info("migrating config entry to be managed by Kubernetes")
-		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, &capi.WriteOptions{
+		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, (&capi.WriteOptions{
 			Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
-		})
+		}).WithContext(ctx))
 		if err != nil {
 			return r.syncUnknownWithError(ctx, logger, crdCtrl, configEntry, ConsulAgentError,
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
 		logger.Info("config entry migrated", "request-time", writeMeta.RequestTime)
-		return r.syncSuccessful(ctx, crdCtrl, configEntry)
-	} else if configEntry.SyncedConditionStatus() != corev1.ConditionTrue {
-		return r.syncSuccessful(ctx, crdCtrl, configEntry)
+		return r.syncWritten(ctx, logger, crdCtrl, configEntry, consulEntry)
+	} else if !syncedStatus(configEntry, entry.GetModifyIndex()) {
+		return r.syncSuccessful(ctx, crdCtrl, configEntry, entry.GetModifyIndex())
 	}
 
 	return ctrl.Result{}, nil
 }
 
 // setupWithManager sets up the controller manager for the given resource
-// with our default options.
-func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler) error {
+// with our default options. If shards is set, only the resources of the
+// shards that this replica owns are reconciled.
+func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler, shards *sharding.Coordinator) error {
 	options := controller.Options{
 		// Taken from https://github.com/kubernetes/client-go/blob/master/util/workqueue/default_rate_limiters.go#L39
 		// and modified from a starting backoff of 5ms and max of 1000s to a
@@ -275,10 +351,13 @@ func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler recon
 		),
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	blder, err := shards.Watch(ctrl.NewControllerManagedBy(mgr).
 		For(resource).
-		WithOptions(options).
-		Complete(reconciler)
+		WithOptions(options), mgr, resource)
+	if err != nil {
+		return err
+	}
+	return blder.Complete(shards.Reconciler(reconciler))
 }
 
 func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, namespace string, globalResource bool) string {
@@ -303,6 +382,14 @@ func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, na
 
 func (r *ConfigEntryController) syncFailed(ctx context.Context, logger logr.Logger, updater Controller, configEntry common.ConfigEntryResource, errType string, err error) (ctrl.Result, error) {
 	configEntry.SetSyncedCondition(corev1.ConditionFalse, errType, err.Error())
+	configEntry.SetCondition(string(v1alpha1.ConditionSyncedToConsul), corev1.ConditionFalse, errType, err.Error())
+	switch errType {
+	case ReferenceNotFoundError:
+		configEntry.SetCondition(string(v1alpha1.ConditionValidated), corev1.ConditionFalse, errType, err.Error())
+	case ExternallyManagedConfigError, MigrationFailedError:
+		configEntry.SetCondition(string(v1alpha1.ConditionInConflict), corev1.ConditionTrue, errType, err.Error())
+	}
+	r.recordFailure(configEntry, errType, err)
 	if updateErr := updater.UpdateStatus(ctx, configEntry); updateErr != nil {
 		// Log the original error here because we are returning the updateErr.
 		// Otherwise the original error would be lost.
@@ -312,8 +399,29 @@ func (r *ConfigEntryController) syncFailed(ctx context.Context, logger logr.Logg
 	return ctrl.Result{}, err
 }
 
-func (r *ConfigEntryController) syncSuccessful(ctx context.Context, updater Controller, configEntry common.ConfigEntryResource) (ctrl.Result, error) {
+// syncWritten updates the status of the resource once its config entry has
+// been written to Consul. The config entry is read back to get its modify
+// index since writes don't return it.
+func (r *ConfigEntryController) syncWritten(ctx context.Context, logger logr.Logger, updater Controller, configEntry common.ConfigEntryResource, consulEntry capi.ConfigEntry) (ctrl.Result, error) {
+	index := configEntry.GetConsulIndex()
+	entry, _, err := r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), (&capi.QueryOptions{
+		Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
+	}).WithContext(ctx))
+	if err != nil {
+		// The index is updated on the next resync.
+		logger.Error(err, "reading config entry after writing it to consul")
+	} else {
+		index = entry.GetModifyIndex()
+	}
+	return r.syncSuccessful(ctx, updater, configEntry, index)
+}
+
+func (r *ConfigEntryController) syncSuccessful(ctx context.Context, updater Controller, configEntry common.ConfigEntryResource, index uint64) (ctrl.Result, error) {
 	configEntry.SetSyncedCondition(corev1.ConditionTrue, "", "")
+	configEntry.SetCondition(string(v1alpha1.ConditionValidated), corev1.ConditionTrue, "", "")
+	configEntry.SetCondition(string(v1alpha1.ConditionSyncedToConsul), corev1.ConditionTrue, "", "")
+	configEntry.SetCondition(string(v1alpha1.ConditionInConflict), corev1.ConditionFalse, "", "")
+	configEntry.SetConsulIndex(index)
 	timeNow := metav1.NewTime(time.Now())
 	configEntry.SetLastSyncedTime(&timeNow)
 	return ctrl.Result{}, updater.UpdateStatus(ctx, configEntry)
@@ -332,6 +440,8 @@ func (r *ConfigEntryController) syncUnknownWithError(ctx context.Context,
 	err error) (ctrl.Result, error) {
 
 	configEntry.SetSyncedCondition(corev1.ConditionUnknown, errType, err.Error())
+	configEntry.SetCondition(string(v1alpha1.ConditionSyncedToConsul), corev1.ConditionUnknown, errType, err.Error())
+	r.recordFailure(configEntry, errType, err)
 	if updateErr := updater.UpdateStatus(ctx, configEntry); updateErr != nil {
 		// Log the original error here because we are returning the updateErr.
 		// Otherwise the original error would be lost.
@@ -341,6 +451,26 @@ func (r *ConfigEntryController) syncUnknownWithError(ctx context.Context,
 	return ctrl.Result{}, err
 }
 
+// recordFailure records a warning event on the resource for the error.
+func (r *ConfigEntryController) recordFailure(configEntry common.ConfigEntryResource, errType string, err error) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(configEntry, corev1.EventTypeWarning, errType, err.Error())
+}
+
+// syncedStatus returns true if the status of the resource already reflects
+// that it's synced with the config entry at the given modify index. Resources
+// that were last synced before the Validated, SyncedToConsul and InConflict
+// conditions were added get them on their next resync.
+func syncedStatus(configEntry common.ConfigEntryResource, index uint64) bool {
+	return configEntry.SyncedConditionStatus() == corev1.ConditionTrue &&
+		configEntry.ConditionStatus(string(v1alpha1.ConditionValidated)) == corev1.ConditionTrue &&
+		configEntry.ConditionStatus(string(v1alpha1.ConditionSyncedToConsul)) == corev1.ConditionTrue &&
+		configEntry.ConditionStatus(string(v1alpha1.ConditionInConflict)) == corev1.ConditionFalse &&
+		configEntry.GetConsulIndex() == index
+}
+
 // nonMatchingMigrationError returns an error that indicates the migration failed
 // because the config entries did not match.
 func (r *ConfigEntryController) nonMatchingMigrationError(kubeEntry common.ConfigEntryResource, consulEntry capi.ConfigEntry) error {
@@ -19,6 +19,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -160,6 +161,40 @@ func TestConfigEntryControllers_createsConfigEntry(t *testing.T) {
 				require.True(t, mesh.TransparentProxy.MeshDestinationsOnly)
 			},
 		},
+		{
+			kubeKind:   "JWTProvider",
+			consulKind: capi.JWTProvider,
+			configEntryResource: &v1alpha1.JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-jwt-provider",
+					Namespace: kubeNS,
+				},
+				Spec: v1alpha1.JWTProviderSpec{
+					Issuer: "test-issuer",
+					JSONWebKeySet: &v1alpha1.JSONWebKeySet{
+						Remote: &v1alpha1.RemoteJWKS{
+							URI: "https://jwks.example.com",
+						},
+					},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &JWTProviderController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+			compare: func(t *testing.T, consulEntry capi.ConfigEntry) {
+				jwtProvider, ok := consulEntry.(*capi.JWTProviderConfigEntry)
+				require.True(t, ok, "cast error")
+				require.Equal(t, "test-issuer", jwtProvider.Issuer)
+				require.Equal(t, "https://jwks.example.com", jwtProvider.JSONWebKeySet.Remote.URI)
+			},
+		},
 		{
 			kubeKind:   "ServiceRouter",
 			consulKind: capi.ServiceRouter,
@@ -612,6 +647,43 @@ func TestConfigEntryControllers_updatesConfigEntry(t *testing.T) {
 				require.False(t, meshConfigEntry.TransparentProxy.MeshDestinationsOnly)
 			},
 		},
+		{
+			kubeKind:   "JWTProvider",
+			consulKind: capi.JWTProvider,
+			configEntryResource: &v1alpha1.JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-jwt-provider",
+					Namespace: kubeNS,
+				},
+				Spec: v1alpha1.JWTProviderSpec{
+					Issuer: "test-issuer",
+					JSONWebKeySet: &v1alpha1.JSONWebKeySet{
+						Remote: &v1alpha1.RemoteJWKS{
+							URI: "https://jwks.example.com",
+						},
+					},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &JWTProviderController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+			updateF: func(resource common.ConfigEntryResource) {
+				jwtProvider := resource.(*v1alpha1.JWTProvider)
+				jwtProvider.Spec.Issuer = "other-issuer"
+			},
+			compare: func(t *testing.T, consulEntry capi.ConfigEntry) {
+				jwtProvider, ok := consulEntry.(*capi.JWTProviderConfigEntry)
+				require.True(t, ok, "cast error")
+				require.Equal(t, "other-issuer", jwtProvider.Issuer)
+			},
+		},
 		{
 			kubeKind:   "ServiceSplitter",
 			consulKind: capi.ServiceSplitter,
@@ -1069,6 +1141,36 @@ func TestConfigEntryControllers_deletesConfigEntry(t *testing.T) {
 				}
 			},
 		},
+		{
+			kubeKind:   "JWTProvider",
+			consulKind: capi.JWTProvider,
+			configEntryResourceWithDeletion: &v1alpha1.JWTProvider{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "test-jwt-provider",
+					Namespace:         kubeNS,
+					DeletionTimestamp: &metav1.Time{Time: time.Now()},
+					Finalizers:        []string{FinalizerName},
+				},
+				Spec: v1alpha1.JWTProviderSpec{
+					Issuer: "test-issuer",
+					JSONWebKeySet: &v1alpha1.JSONWebKeySet{
+						Remote: &v1alpha1.RemoteJWKS{
+							URI: "https://jwks.example.com",
+						},
+					},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &JWTProviderController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+		},
 		{
 			kubeKind:   "ServiceRouter",
 			consulKind: capi.ServiceRouter,
@@ -1366,12 +1468,14 @@ func TestConfigEntryControllers_errorUpdatesSyncStatus(t *testing.T) {
 		Address: "incorrect-address",
 	})
 	req.NoError(err)
+	recorder := record.NewFakeRecorder(1)
 	reconciler := &ServiceDefaultsController{
 		Client: fakeClient,
 		Log:    logrtest.TestLogger{T: t},
 		ConfigEntryController: &ConfigEntryController{
 			ConsulClient:   consulClient,
 			DatacenterName: datacenterName,
+			Recorder:       recorder,
 		},
 	}
 
@@ -1396,6 +1500,14 @@ func TestConfigEntryControllers_errorUpdatesSyncStatus(t *testing.T) {
 	req.Equal(corev1.ConditionFalse, status)
 	req.Equal("ConsulAgentError", reason)
 	req.Contains(errMsg, expErr)
+	syncedToConsul := svcDefaults.GetCondition(v1alpha1.ConditionSyncedToConsul)
+	req.Equal(corev1.ConditionFalse, syncedToConsul.Status)
+	req.Equal("ConsulAgentError", syncedToConsul.Reason)
+	req.Equal(corev1.ConditionTrue, svcDefaults.ConditionStatus(string(v1alpha1.ConditionValidated)))
+
+	// Check that a warning event was recorded.
+	req.Len(recorder.Events, 1)
+	req.Contains(<-recorder.Events, "Warning ConsulAgentError "+expErr)
 }
 
 // Test that if the config entry hasn't changed in Consul but our resource
@@ -1465,6 +1577,12 @@ func TestConfigEntryControllers_setsSyncedToTrue(t *testing.T) {
 	err = fakeClient.Get(ctx, namespacedName, svcDefaults)
 	req.NoError(err)
 	req.Equal(corev1.ConditionTrue, svcDefaults.SyncedConditionStatus())
+	req.Equal(corev1.ConditionTrue, svcDefaults.ConditionStatus(string(v1alpha1.ConditionValidated)))
+	req.Equal(corev1.ConditionTrue, svcDefaults.ConditionStatus(string(v1alpha1.ConditionSyncedToConsul)))
+	req.Equal(corev1.ConditionFalse, svcDefaults.ConditionStatus(string(v1alpha1.ConditionInConflict)))
+	entry, _, err := consulClient.ConfigEntries().Get(capi.ServiceDefaults, svcDefaults.ConsulName(), nil)
+	req.NoError(err)
+	req.Equal(entry.GetModifyIndex(), svcDefaults.Status.ConsulIndex)
 }
 
 // Test that if the config entry exists in Consul but is not managed by the
@@ -1560,6 +1678,10 @@ func TestConfigEntryControllers_doesNotCreateUnownedConfigEntry(t *testing.T) {
 				req.Equal(corev1.ConditionFalse, status)
 				req.Equal("ExternallyManagedConfigError", reason)
 				req.Equal(errMsg, c.expErr)
+				inConflict := svcDefaults.GetCondition(v1alpha1.ConditionInConflict)
+				req.Equal(corev1.ConditionTrue, inConflict.Status)
+				req.Equal("ExternallyManagedConfigError", inConflict.Reason)
+				req.Equal(corev1.ConditionFalse, svcDefaults.ConditionStatus(string(v1alpha1.ConditionSyncedToConsul)))
 			}
 		})
 	}
@@ -0,0 +1,107 @@
+//go:build enterprise
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/controller"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Control plane request limits are only supported in Consul Enterprise, so
+// the controller is tested here rather than in the configentry_controller
+// tests.
+
+// Test that the request limits are written to Consul, and that the status
+// reports when the servers reject them.
+func TestControlPlaneRequestLimitController(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+
+	requestLimit := &v1alpha1.ControlPlaneRequestLimit{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "limits",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.ControlPlaneRequestLimitSpec{
+			Mode: "permissive",
+			ReadWriteRatesConfig: v1alpha1.ReadWriteRatesConfig{
+				ReadRate:  100,
+				WriteRate: 100,
+			},
+			KV: &v1alpha1.ReadWriteRatesConfig{
+				ReadRate:  50,
+				WriteRate: 10,
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, requestLimit)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(requestLimit).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+	})
+	req.NoError(err)
+
+	r := &controller.ControlPlaneRequestLimitController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		Scheme: s,
+		ConfigEntryController: &controller.ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: "datacenter",
+		},
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: requestLimit.Namespace,
+		Name:      requestLimit.KubernetesName(),
+	}
+	reconcile := func() (corev1.ConditionStatus, string, error) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+		var updated v1alpha1.ControlPlaneRequestLimit
+		req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+		status, reason, _ := updated.SyncedCondition()
+		return status, reason, err
+	}
+
+	status, _, err := reconcile()
+	req.NoError(err)
+	req.Equal(corev1.ConditionTrue, status)
+	entry, _, err := consulClient.ConfigEntries().Get(capi.RateLimitIPConfig, "limits", nil)
+	req.NoError(err)
+	limits, ok := entry.(*capi.RateLimitIPConfigEntry)
+	req.True(ok)
+	req.Equal("permissive", limits.Mode)
+	req.Equal(float64(100), limits.ReadRate)
+	req.Equal(&capi.ReadWriteRatesConfig{ReadRate: 50, WriteRate: 10}, limits.KV)
+
+	// The webhook rejects invalid modes, but the status must still report
+	// that the servers rejected the request limits if one gets through.
+	var updated v1alpha1.ControlPlaneRequestLimit
+	req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+	updated.Spec.Mode = "invalid"
+	req.NoError(fakeClient.Update(ctx, &updated))
+
+	status, reason, err := reconcile()
+	req.Error(err)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(controller.ConsulAgentError, reason)
+}
@@ -2,8 +2,11 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,13 +31,57 @@ func (r *ExportedServicesController) Reconcile(ctx context.Context, req ctrl.Req
 }
 
 func (r *ExportedServicesController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ExportedServicesController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
 	return r.Status().Update(ctx, obj, opts...)
 }
 
+// ValidateReferences implements ReferenceValidator. It checks that the
+// exported services are registered in Consul and that the peers they're
+// exported to exist. Wildcard services and namespaces aren't checked.
+func (r *ExportedServicesController) ValidateReferences(ctx context.Context, configEntry common.ConfigEntryResource) error {
+	exports, ok := configEntry.(*consulv1alpha1.ExportedServices)
+	if !ok {
+		return nil
+	}
+	consulClient := r.ConfigEntryController.ConsulClient
+
+	checkedPeers := make(map[string]bool)
+	for _, svc := range exports.Spec.Services {
+		if svc.Name != common.WildcardNamespace && svc.Namespace != common.WildcardNamespace {
+			opts := &capi.QueryOptions{}
+			if r.ConfigEntryController.EnableConsulNamespaces {
+				opts.Namespace = svc.Namespace
+			}
+			opts = opts.WithContext(ctx)
+			instances, _, err := consulClient.Catalog().Service(svc.Name, "", opts)
+			if err != nil {
+				return fmt.Errorf("checking if service %q exists: %w", svc.Name, err)
+			}
+			if len(instances) == 0 {
+				return fmt.Errorf("service %q not found in Consul", svc.Name)
+			}
+		}
+
+		for _, consumer := range svc.Consumers {
+			if consumer.Peer == "" || checkedPeers[consumer.Peer] {
+				continue
+			}
+			peering, _, err := consulClient.Peerings().Read(ctx, consumer.Peer, nil)
+			if err != nil {
+				return fmt.Errorf("checking if peer %q exists: %w", consumer.Peer, err)
+			}
+			if peering == nil || peering.State == capi.PeeringStateDeleting {
+				return fmt.Errorf("peer %q not found", consumer.Peer)
+			}
+			checkedPeers[consumer.Peer] = true
+		}
+	}
+	return nil
+}
+
 func (r *ExportedServicesController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ExportedServices{}, r, r.ConfigEntryController.Shards)
 }
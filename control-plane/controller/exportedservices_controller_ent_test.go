@@ -5,6 +5,7 @@ package controller_test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -102,6 +103,7 @@ func TestExportedServicesController_createsExportedServices(tt *testing.T) {
 				Address: consul.HTTPAddr,
 			})
 			req.NoError(err)
+			registerExportedService(t, consulClient, "frontend", "front")
 
 			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(exportedServices).Build()
 
@@ -222,6 +224,8 @@ func TestExportedServicesController_updatesExportedServices(tt *testing.T) {
 				Address: consul.HTTPAddr,
 			})
 			req.NoError(err)
+			registerExportedService(t, consulClient, "frontend", "front")
+			registerExportedService(t, consulClient, "backend", "front")
 
 			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(exportedServices).Build()
 
@@ -418,3 +422,22 @@ func TestExportedServicesController_deletesExportedServices(tt *testing.T) {
 		})
 	}
 }
+
+// registerExportedService registers an instance of the service in the Consul
+// namespace so that it can be exported.
+func registerExportedService(t *testing.T, consulClient *capi.Client, name, namespace string) {
+	t.Helper()
+	_, _, err := consulClient.Namespaces().Create(&capi.Namespace{Name: namespace}, nil)
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		require.NoError(t, err)
+	}
+	_, err = consulClient.Catalog().Register(&capi.CatalogRegistration{
+		Node:    "node",
+		Address: "127.0.0.1",
+		Service: &capi.AgentService{
+			Service:   name,
+			Namespace: namespace,
+		},
+	}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Test that services are only exported to peers once the services and the
+// peers exist, and that the status reports why they aren't exported yet.
+func TestExportedServicesController_validatesReferences(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+
+	exportedServices := &v1alpha1.ExportedServices{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.DefaultConsulPartition,
+			Namespace: "default",
+		},
+		Spec: v1alpha1.ExportedServicesSpec{
+			Services: []v1alpha1.ExportedService{
+				{
+					Name:      "frontend",
+					Consumers: []v1alpha1.ServiceConsumer{{Peer: "other"}},
+				},
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, exportedServices)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(exportedServices).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+	})
+	req.NoError(err)
+
+	r := &ExportedServicesController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		Scheme: s,
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: datacenterName,
+		},
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: exportedServices.Namespace,
+		Name:      exportedServices.KubernetesName(),
+	}
+	reconcile := func() (corev1.ConditionStatus, string, string, error) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+		var updated v1alpha1.ExportedServices
+		req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+		status, reason, message := updated.SyncedCondition()
+		return status, reason, message, err
+	}
+
+	// The service isn't registered yet.
+	status, reason, message, err := reconcile()
+	req.EqualError(err, `service "frontend" not found in Consul`)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(ReferenceNotFoundError, reason)
+	req.Equal(`service "frontend" not found in Consul`, message)
+
+	_, err = consulClient.Catalog().Register(&capi.CatalogRegistration{
+		Node:    "node",
+		Address: "127.0.0.1",
+		Service: &capi.AgentService{Service: "frontend"},
+	}, nil)
+	req.NoError(err)
+
+	// The peer doesn't exist yet.
+	status, reason, message, err = reconcile()
+	req.EqualError(err, `peer "other" not found`)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(ReferenceNotFoundError, reason)
+	req.Equal(`peer "other" not found`, message)
+	_, _, err = consulClient.ConfigEntries().Get(capi.ExportedServices, common.DefaultConsulPartition, nil)
+	req.True(isNotFoundErr(err))
+
+	_, _, err = consulClient.Peerings().GenerateToken(ctx, capi.PeeringGenerateTokenRequest{PeerName: "other"}, nil)
+	req.NoError(err)
+
+	status, _, _, err = reconcile()
+	req.NoError(err)
+	req.Equal(corev1.ConditionTrue, status)
+	entry, _, err := consulClient.ConfigEntries().Get(capi.ExportedServices, common.DefaultConsulPartition, nil)
+	req.NoError(err)
+	exports, ok := entry.(*capi.ExportedServicesConfigEntry)
+	req.True(ok)
+	req.Equal([]capi.ServiceConsumer{{Peer: "other"}}, exports.Services[0].Consumers)
+}
@@ -28,7 +28,7 @@ func (r *IngressGatewayController) Reconcile(ctx context.Context, req ctrl.Reque
 }
 
 func (r *IngressGatewayController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *IngressGatewayController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *IngressGatewayController) UpdateStatus(ctx context.Context, obj client.
 }
 
 func (r *IngressGatewayController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.IngressGateway{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.IngressGateway{}, r, r.ConfigEntryController.Shards)
 }
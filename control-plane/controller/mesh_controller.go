@@ -28,7 +28,7 @@ func (r *MeshController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 }
 
 func (r *MeshController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *MeshController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *MeshController) UpdateStatus(ctx context.Context, obj client.Object, op
 }
 
 func (r *MeshController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.Mesh{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.Mesh{}, r, r.ConfigEntryController.Shards)
 }
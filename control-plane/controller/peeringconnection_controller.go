@@ -0,0 +1,462 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/sharding"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+const (
+	// ConditionPeeringActive specifies that the peering is active in Consul,
+	// i.e. that the datacenters are connected and exchanging data.
+	ConditionPeeringActive = "PeeringActive"
+
+	ValidationError        = "ValidationError"
+	PeeringTokenError      = "PeeringTokenError"
+	ExportedServicesError  = "ExportedServicesError"
+	PeeringNotActiveReason = "PeeringNotActive"
+
+	// peeringHealthInterval is how often the state of a peering is read from
+	// Consul to update the status of its PeeringConnection.
+	peeringHealthInterval = 30 * time.Second
+)
+
+// PeeringConnectionController reconciles PeeringConnection resources. The
+// acceptor generates a peering token and writes it to a secret, and the
+// dialer reads the token, from another cluster if needed, and establishes
+// the peering with it. Both sides export the services of the resource to
+// the peer.
+type PeeringConnectionController struct {
+	client.Client
+	Log          logr.Logger
+	Scheme       *runtime.Scheme
+	ConsulClient *capi.Client
+
+	// APIReader reads secrets from the API server instead of the cache of
+	// the manager, so that the controller doesn't watch the secrets of the
+	// cluster. It defaults to the client of the controller.
+	APIReader client.Reader
+
+	// Partition is the Consul admin partition that the controller manages.
+	// The services of the PeeringConnections are added to the
+	// ExportedServices resource of this partition. If it's empty, the
+	// default partition is used.
+	Partition string
+
+	// RemoteClient returns a client of the cluster of the kubeconfig. It
+	// defaults to a client that uses the scheme of the controller and is
+	// only overridden in tests.
+	RemoteClient func(kubeconfig []byte) (client.Client, error)
+
+	// Shards, if set, limits the controller to the resources in the
+	// namespaces of the shards that this replica owns.
+	Shards *sharding.Coordinator
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=peeringconnections,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=peeringconnections/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+
+func (r *PeeringConnectionController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("namespace", req.Namespace, "resource", req.Name)
+
+	var conn consulv1alpha1.PeeringConnection
+	if err := r.Get(ctx, req.NamespacedName, &conn); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "retrieving resource")
+		return ctrl.Result{}, err
+	}
+
+	if !conn.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !containsString(conn.Finalizers, FinalizerName) {
+			return ctrl.Result{}, nil
+		}
+		logger.Info("deletion event")
+		if err := r.syncExportedServices(ctx, &conn, nil); err != nil {
+			return ctrl.Result{}, err
+		}
+		if _, err := r.ConsulClient.Peerings().Delete(ctx, conn.ConsulPeerName(), nil); err != nil {
+			return ctrl.Result{}, fmt.Errorf("deleting peering %q: %w", conn.ConsulPeerName(), err)
+		}
+		logger.Info("deleted peering from Consul", "peer", conn.ConsulPeerName())
+		controllerutil.RemoveFinalizer(&conn, FinalizerName)
+		if err := r.Update(ctx, &conn); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("finalizer removed")
+		return ctrl.Result{}, nil
+	}
+
+	if !containsString(conn.Finalizers, FinalizerName) {
+		controllerutil.AddFinalizer(&conn, FinalizerName)
+		if err := r.Update(ctx, &conn); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// An invalid resource isn't retried until it's changed.
+	if err := conn.Validate(); err != nil {
+		conn.Status.SetCondition(string(consulv1alpha1.ConditionSynced), corev1.ConditionFalse, ValidationError, err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, &conn)
+	}
+
+	var tokenHash string
+	var err error
+	if conn.Spec.Role == consulv1alpha1.PeeringRoleAcceptor {
+		tokenHash, err = r.syncAcceptor(ctx, &conn)
+	} else {
+		tokenHash, err = r.syncDialer(ctx, &conn)
+	}
+	if err != nil {
+		return ctrl.Result{}, r.syncFailed(ctx, logger, &conn, PeeringTokenError, err)
+	}
+	if tokenHash == "" {
+		// The dialer waits until the acceptor has written the token.
+		conn.Status.SetCondition(string(consulv1alpha1.ConditionSynced), corev1.ConditionFalse, PeeringTokenError,
+			fmt.Sprintf("peering token not found in secret %q", conn.Spec.TokenSecret.Name))
+		return ctrl.Result{RequeueAfter: peeringHealthInterval}, r.Status().Update(ctx, &conn)
+	}
+	conn.Status.TokenHash = tokenHash
+
+	if err := r.syncExportedServices(ctx, &conn, conn.Spec.ExportedServices); err != nil {
+		return ctrl.Result{}, r.syncFailed(ctx, logger, &conn, ExportedServicesError, err)
+	}
+
+	peering, _, err := r.ConsulClient.Peerings().Read(ctx, conn.ConsulPeerName(), nil)
+	if err != nil {
+		return ctrl.Result{}, r.syncFailed(ctx, logger, &conn, ConsulAgentError, err)
+	}
+	r.setPeeringStatus(&conn, peering)
+	conn.Status.SetCondition(string(consulv1alpha1.ConditionSynced), corev1.ConditionTrue, "", "")
+	now := metav1.Now()
+	conn.Status.LastSyncedTime = &now
+	if err := r.Status().Update(ctx, &conn); err != nil {
+		return ctrl.Result{}, err
+	}
+	// The peering is read again periodically so that the status reflects
+	// its health.
+	return ctrl.Result{RequeueAfter: peeringHealthInterval}, nil
+}
+
+func (r *PeeringConnectionController) SetupWithManager(mgr ctrl.Manager) error {
+	return setupWithManager(mgr, &consulv1alpha1.PeeringConnection{}, r, r.Shards)
+}
+
+// syncAcceptor generates a peering token and writes it to the token secret,
+// unless the secret already has the token that the peering was generated
+// with. It returns the hash of the token. A secret that exists but isn't
+// controlled by conn is never written to.
+func (r *PeeringConnectionController) syncAcceptor(ctx context.Context, conn *consulv1alpha1.PeeringConnection) (string, error) {
+	secret := &corev1.Secret{}
+	name := types.NamespacedName{Namespace: conn.Namespace, Name: conn.Spec.TokenSecret.Name}
+	err := r.apiReader().Get(ctx, name, secret)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("retrieving secret %q: %w", name.Name, err)
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(secret, conn) {
+		return "", fmt.Errorf("secret %q isn't controlled by PeeringConnection %q", name.Name, conn.Name)
+	}
+
+	peering, _, err := r.ConsulClient.Peerings().Read(ctx, conn.ConsulPeerName(), nil)
+	if err != nil {
+		return "", fmt.Errorf("reading peering %q: %w", conn.ConsulPeerName(), err)
+	}
+	if exists && peering != nil && peering.State != capi.PeeringStateDeleting {
+		if hash := tokenHash(secret.Data[conn.TokenKey()]); hash != "" && hash == conn.Status.TokenHash {
+			return hash, nil
+		}
+	}
+
+	resp, _, err := r.ConsulClient.Peerings().GenerateToken(ctx, capi.PeeringGenerateTokenRequest{
+		PeerName:                conn.ConsulPeerName(),
+		Meta:                    map[string]string{common.SourceKey: common.SourceValue},
+		ServerExternalAddresses: conn.Spec.ServerExternalAddresses,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("generating peering token for %q: %w", conn.ConsulPeerName(), err)
+	}
+
+	if !exists {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: name.Namespace,
+			},
+		}
+		// The secret is garbage collected with the PeeringConnection.
+		if err := controllerutil.SetControllerReference(conn, secret, r.Scheme); err != nil {
+			return "", err
+		}
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data[conn.TokenKey()] = []byte(resp.PeeringToken)
+	if exists {
+		err = r.Update(ctx, secret)
+	} else {
+		err = r.Create(ctx, secret)
+	}
+	if err != nil {
+		return "", fmt.Errorf("writing peering token to secret %q: %w", name.Name, err)
+	}
+	return tokenHash([]byte(resp.PeeringToken)), nil
+}
+
+// syncDialer establishes the peering with the token of the token secret if
+// the peering doesn't exist or the token has changed. It returns the hash of
+// the token, or an empty string if the token doesn't exist yet.
+func (r *PeeringConnectionController) syncDialer(ctx context.Context, conn *consulv1alpha1.PeeringConnection) (string, error) {
+	token, err := r.dialerToken(ctx, conn)
+	if err != nil || len(token) == 0 {
+		return "", err
+	}
+	hash := tokenHash(token)
+
+	peering, _, err := r.ConsulClient.Peerings().Read(ctx, conn.ConsulPeerName(), nil)
+	if err != nil {
+		return "", fmt.Errorf("reading peering %q: %w", conn.ConsulPeerName(), err)
+	}
+	if peering != nil && peering.State != capi.PeeringStateDeleting && hash == conn.Status.TokenHash {
+		return hash, nil
+	}
+
+	_, _, err = r.ConsulClient.Peerings().Establish(ctx, capi.PeeringEstablishRequest{
+		PeerName:     conn.ConsulPeerName(),
+		PeeringToken: string(token),
+		Meta:         map[string]string{common.SourceKey: common.SourceValue},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("establishing peering %q: %w", conn.ConsulPeerName(), err)
+	}
+	r.Log.Info("established peering", "namespace", conn.Namespace, "resource", conn.Name, "peer", conn.ConsulPeerName())
+	return hash, nil
+}
+
+// dialerToken returns the peering token of the dialer. If a remote cluster
+// is configured, the token secret is read from it with the kubeconfig of the
+// kubeconfig secret.
+func (r *PeeringConnectionController) dialerToken(ctx context.Context, conn *consulv1alpha1.PeeringConnection) ([]byte, error) {
+	reader := r.apiReader()
+	namespace := conn.Namespace
+	if conn.Spec.Remote != nil {
+		kubeconfigSecret := &corev1.Secret{}
+		name := types.NamespacedName{Namespace: conn.Namespace, Name: conn.Spec.Remote.KubeconfigSecret.Name}
+		if err := reader.Get(ctx, name, kubeconfigSecret); err != nil {
+			return nil, fmt.Errorf("retrieving kubeconfig secret %q: %w", name.Name, err)
+		}
+		kubeconfig, ok := kubeconfigSecret.Data[conn.KubeconfigKey()]
+		if !ok {
+			return nil, fmt.Errorf("kubeconfig secret %q has no key %q", name.Name, conn.KubeconfigKey())
+		}
+		remoteClient, err := r.remoteClient(kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("creating client of the remote cluster: %w", err)
+		}
+		reader = remoteClient
+		namespace = conn.RemoteNamespace()
+	}
+
+	secret := &corev1.Secret{}
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: conn.Spec.TokenSecret.Name}, secret)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retrieving secret %q: %w", conn.Spec.TokenSecret.Name, err)
+	}
+	return secret.Data[conn.TokenKey()], nil
+}
+
+func (r *PeeringConnectionController) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func (r *PeeringConnectionController) remoteClient(kubeconfig []byte) (client.Client, error) {
+	if r.RemoteClient != nil {
+		return r.RemoteClient(kubeconfig)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: r.Scheme})
+}
+
+// syncExportedServices makes the services that are exported to the peer in
+// the ExportedServices resource of the partition match services. The
+// resource is created if it doesn't exist and deleted if no services are
+// exported anymore.
+func (r *PeeringConnectionController) syncExportedServices(ctx context.Context, conn *consulv1alpha1.PeeringConnection, services []consulv1alpha1.PeeringExportedService) error {
+	partition := r.Partition
+	if partition == "" {
+		partition = common.DefaultConsulPartition
+	}
+
+	// The ExportedServices resource can be in any namespace, so the
+	// existing one is looked up across namespaces.
+	var list consulv1alpha1.ExportedServicesList
+	if err := r.List(ctx, &list); err != nil {
+		return fmt.Errorf("listing ExportedServices: %w", err)
+	}
+	var exported *consulv1alpha1.ExportedServices
+	for i := range list.Items {
+		if list.Items[i].Name == partition {
+			exported = &list.Items[i]
+			break
+		}
+	}
+
+	if exported == nil {
+		if len(services) == 0 {
+			return nil
+		}
+		exported = &consulv1alpha1.ExportedServices{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      partition,
+				Namespace: conn.Namespace,
+			},
+			Spec: consulv1alpha1.ExportedServicesSpec{
+				Services: setPeerConsumers(nil, conn.ConsulPeerName(), services),
+			},
+		}
+		if err := r.Create(ctx, exported); err != nil {
+			return fmt.Errorf("creating ExportedServices %q: %w", partition, err)
+		}
+		return nil
+	}
+
+	updated := setPeerConsumers(exported.Spec.Services, conn.ConsulPeerName(), services)
+	if len(updated) == 0 {
+		if err := r.Delete(ctx, exported); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("deleting ExportedServices %q: %w", partition, err)
+		}
+		return nil
+	}
+	if exportedServicesEqual(exported.Spec.Services, updated) {
+		return nil
+	}
+	exported.Spec.Services = updated
+	if err := r.Update(ctx, exported); err != nil {
+		return fmt.Errorf("updating ExportedServices %q: %w", partition, err)
+	}
+	return nil
+}
+
+func (r *PeeringConnectionController) setPeeringStatus(conn *consulv1alpha1.PeeringConnection, peering *capi.Peering) {
+	if peering == nil {
+		conn.Status.State = ""
+		conn.Status.PeerID = ""
+		conn.Status.SetCondition(ConditionPeeringActive, corev1.ConditionFalse, PeeringNotActiveReason, "peering not found in Consul")
+		return
+	}
+	conn.Status.State = string(peering.State)
+	conn.Status.PeerID = peering.ID
+	if peering.State == capi.PeeringStateActive {
+		conn.Status.SetCondition(ConditionPeeringActive, corev1.ConditionTrue, "", "")
+		return
+	}
+	conn.Status.SetCondition(ConditionPeeringActive, corev1.ConditionFalse, PeeringNotActiveReason,
+		fmt.Sprintf("peering is %s", peering.State))
+}
+
+func (r *PeeringConnectionController) syncFailed(ctx context.Context, logger logr.Logger, conn *consulv1alpha1.PeeringConnection, reason string, err error) error {
+	conn.Status.SetCondition(string(consulv1alpha1.ConditionSynced), corev1.ConditionFalse, reason, err.Error())
+	if updateErr := r.Status().Update(ctx, conn); updateErr != nil {
+		logger.Error(updateErr, "failed to update status")
+		return updateErr
+	}
+	return err
+}
+
+// setPeerConsumers returns exported with the peer as a consumer of exactly
+// the given services. Services without consumers are removed.
+func setPeerConsumers(exported []consulv1alpha1.ExportedService, peer string, services []consulv1alpha1.PeeringExportedService) []consulv1alpha1.ExportedService {
+	desired := make(map[consulv1alpha1.PeeringExportedService]bool)
+	for _, svc := range services {
+		desired[svc] = true
+	}
+
+	var result []consulv1alpha1.ExportedService
+	for _, svc := range exported {
+		key := consulv1alpha1.PeeringExportedService{Name: svc.Name, Namespace: svc.Namespace}
+		var consumers []consulv1alpha1.ServiceConsumer
+		for _, consumer := range svc.Consumers {
+			if consumer.Peer != peer {
+				consumers = append(consumers, consumer)
+			}
+		}
+		if desired[key] {
+			consumers = append(consumers, consulv1alpha1.ServiceConsumer{Peer: peer})
+			delete(desired, key)
+		}
+		if len(consumers) == 0 {
+			continue
+		}
+		svc.Consumers = consumers
+		result = append(result, svc)
+	}
+	// The services that aren't exported yet are appended in the order of
+	// the PeeringConnection.
+	for _, svc := range services {
+		if !desired[svc] {
+			continue
+		}
+		result = append(result, consulv1alpha1.ExportedService{
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Consumers: []consulv1alpha1.ServiceConsumer{{Peer: peer}},
+		})
+		delete(desired, svc)
+	}
+	return result
+}
+
+func exportedServicesEqual(a, b []consulv1alpha1.ExportedService) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Namespace != b[i].Namespace || len(a[i].Consumers) != len(b[i].Consumers) {
+			return false
+		}
+		for j := range a[i].Consumers {
+			if a[i].Consumers[j] != b[i].Consumers[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func tokenHash(token []byte) string {
+	if len(token) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Test that the acceptor generates a peering token, that the dialer reads
+// it from the cluster of the acceptor and establishes the peering, that the
+// services are exported to the peer and that the peering is deleted with
+// the resources.
+func TestPeeringConnectionController_acceptorAndDialer(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+	s := peeringScheme(t)
+
+	acceptor := &v1alpha1.PeeringConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "dc2", Namespace: "default"},
+		Spec: v1alpha1.PeeringConnectionSpec{
+			Role:             v1alpha1.PeeringRoleAcceptor,
+			TokenSecret:      v1alpha1.PeeringSecretRef{Name: "dc2-peering-token"},
+			ExportedServices: []v1alpha1.PeeringExportedService{{Name: "backend"}},
+		},
+	}
+	dialer := &v1alpha1.PeeringConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "dc1", Namespace: "default"},
+		Spec: v1alpha1.PeeringConnectionSpec{
+			Role:        v1alpha1.PeeringRoleDialer,
+			TokenSecret: v1alpha1.PeeringSecretRef{Name: "dc2-peering-token"},
+			Remote: &v1alpha1.PeeringRemote{
+				KubeconfigSecret: v1alpha1.PeeringSecretRef{Name: "dc1-kubeconfig"},
+			},
+		},
+	}
+	kubeconfig := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "dc1-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{v1alpha1.DefaultKubeconfigKey: []byte("kubeconfig")},
+	}
+	acceptorClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(acceptor).Build()
+	dialerClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(dialer, kubeconfig).Build()
+
+	acceptorConsul := peeringConsulClient(t, "dc1")
+	dialerConsul := peeringConsulClient(t, "dc2")
+
+	acceptorController := &PeeringConnectionController{
+		Client:       acceptorClient,
+		Log:          logrtest.TestLogger{T: t},
+		Scheme:       s,
+		ConsulClient: acceptorConsul,
+	}
+	dialerController := &PeeringConnectionController{
+		Client:       dialerClient,
+		Log:          logrtest.TestLogger{T: t},
+		Scheme:       s,
+		ConsulClient: dialerConsul,
+		RemoteClient: func(config []byte) (client.Client, error) {
+			req.Equal("kubeconfig", string(config))
+			return acceptorClient, nil
+		},
+	}
+	acceptorName := types.NamespacedName{Namespace: "default", Name: "dc2"}
+	dialerName := types.NamespacedName{Namespace: "default", Name: "dc1"}
+
+	_, err := acceptorController.Reconcile(ctx, ctrl.Request{NamespacedName: acceptorName})
+	req.NoError(err)
+	var secret corev1.Secret
+	req.NoError(acceptorClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "dc2-peering-token"}, &secret))
+	token := secret.Data[v1alpha1.DefaultPeeringTokenKey]
+	req.NotEmpty(token)
+	req.Len(secret.OwnerReferences, 1)
+
+	// Reconciling again doesn't generate a new token.
+	_, err = acceptorController.Reconcile(ctx, ctrl.Request{NamespacedName: acceptorName})
+	req.NoError(err)
+	req.NoError(acceptorClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "dc2-peering-token"}, &secret))
+	req.Equal(token, secret.Data[v1alpha1.DefaultPeeringTokenKey])
+
+	var exported v1alpha1.ExportedServices
+	req.NoError(acceptorClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "default"}, &exported))
+	req.Equal([]v1alpha1.ExportedService{
+		{Name: "backend", Consumers: []v1alpha1.ServiceConsumer{{Peer: "dc2"}}},
+	}, exported.Spec.Services)
+
+	_, err = dialerController.Reconcile(ctx, ctrl.Request{NamespacedName: dialerName})
+	req.NoError(err)
+	var updated v1alpha1.PeeringConnection
+	req.NoError(dialerClient.Get(ctx, dialerName, &updated))
+	req.Equal(corev1.ConditionTrue, updated.Status.ConditionStatus(string(v1alpha1.ConditionSynced)))
+	req.Equal(tokenHash(token), updated.Status.TokenHash)
+	req.NotEmpty(updated.Status.PeerID)
+
+	retry.Run(t, func(r *retry.R) {
+		peering, _, err := dialerConsul.Peerings().Read(ctx, "dc1", nil)
+		require.NoError(r, err)
+		require.NotNil(r, peering)
+		require.Equal(r, capi.PeeringStateActive, peering.State)
+	})
+	_, err = acceptorController.Reconcile(ctx, ctrl.Request{NamespacedName: acceptorName})
+	req.NoError(err)
+	req.NoError(acceptorClient.Get(ctx, acceptorName, &updated))
+	req.Equal(string(capi.PeeringStateActive), updated.Status.State)
+	req.Equal(corev1.ConditionTrue, updated.Status.ConditionStatus(ConditionPeeringActive))
+
+	// Deleting the acceptor deletes the peering and the exported services.
+	req.NoError(acceptorClient.Get(ctx, acceptorName, &updated))
+	updated.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	req.NoError(acceptorClient.Update(ctx, &updated))
+	_, err = acceptorController.Reconcile(ctx, ctrl.Request{NamespacedName: acceptorName})
+	req.NoError(err)
+	req.NoError(acceptorClient.Get(ctx, acceptorName, &updated))
+	req.Empty(updated.Finalizers)
+	err = acceptorClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "default"}, &exported)
+	req.Error(err)
+	retry.Run(t, func(r *retry.R) {
+		peering, _, err := acceptorConsul.Peerings().Read(ctx, "dc2", nil)
+		require.NoError(r, err)
+		require.Nil(r, peering)
+	})
+}
+
+// Test that the dialer waits for the token and that an invalid resource
+// isn't synced.
+func TestPeeringConnectionController_notSynced(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := peeringScheme(t)
+
+	cases := map[string]struct {
+		conn      *v1alpha1.PeeringConnection
+		secrets   []runtime.Object
+		expReason string
+		expMsg    string
+	}{
+		"invalid": {
+			conn: &v1alpha1.PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "default"},
+				Spec: v1alpha1.PeeringConnectionSpec{
+					Role:        "listener",
+					TokenSecret: v1alpha1.PeeringSecretRef{Name: "token"},
+				},
+			},
+			expReason: ValidationError,
+			expMsg:    `spec.role: Unsupported value: "listener"`,
+		},
+		"no kubeconfig secret": {
+			conn: &v1alpha1.PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "default"},
+				Spec: v1alpha1.PeeringConnectionSpec{
+					Role:        v1alpha1.PeeringRoleDialer,
+					TokenSecret: v1alpha1.PeeringSecretRef{Name: "token"},
+					Remote: &v1alpha1.PeeringRemote{
+						KubeconfigSecret: v1alpha1.PeeringSecretRef{Name: "kubeconfig"},
+					},
+				},
+			},
+			expReason: PeeringTokenError,
+			expMsg:    `retrieving kubeconfig secret "kubeconfig"`,
+		},
+		"no token": {
+			conn: &v1alpha1.PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "default"},
+				Spec: v1alpha1.PeeringConnectionSpec{
+					Role:        v1alpha1.PeeringRoleDialer,
+					TokenSecret: v1alpha1.PeeringSecretRef{Name: "token"},
+				},
+			},
+			expReason: PeeringTokenError,
+			expMsg:    `peering token not found in secret "token"`,
+		},
+		"token secret not controlled by the resource": {
+			conn: &v1alpha1.PeeringConnection{
+				ObjectMeta: metav1.ObjectMeta{Name: "peer", Namespace: "default"},
+				Spec: v1alpha1.PeeringConnectionSpec{
+					Role:        v1alpha1.PeeringRoleAcceptor,
+					TokenSecret: v1alpha1.PeeringSecretRef{Name: "token"},
+				},
+			},
+			secrets: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+				Data:       map[string][]byte{"other": []byte("data")},
+			}},
+			expReason: PeeringTokenError,
+			expMsg:    `secret "token" isn't controlled by PeeringConnection "peer"`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(append(c.secrets, c.conn)...).Build()
+			r := &PeeringConnectionController{
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Log:       logrtest.TestLogger{T: t},
+				Scheme:    s,
+			}
+			namespacedName := types.NamespacedName{Namespace: "default", Name: "peer"}
+			_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+
+			var updated v1alpha1.PeeringConnection
+			require.NoError(t, fakeClient.Get(ctx, namespacedName, &updated))
+			cond := updated.Status.GetCondition(v1alpha1.ConditionSynced)
+			require.NotNil(t, cond)
+			require.Equal(t, corev1.ConditionFalse, cond.Status)
+			require.Equal(t, c.expReason, cond.Reason)
+			require.Contains(t, cond.Message, c.expMsg)
+			require.Equal(t, []string{FinalizerName}, updated.Finalizers)
+
+			// Secrets that aren't controlled by the resource aren't changed.
+			for _, obj := range c.secrets {
+				secret := obj.(*corev1.Secret)
+				var current corev1.Secret
+				require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &current))
+				require.Equal(t, secret.Data, current.Data)
+			}
+		})
+	}
+}
+
+func TestSetPeerConsumers(t *testing.T) {
+	t.Parallel()
+	exported := []v1alpha1.ExportedService{
+		{Name: "frontend", Consumers: []v1alpha1.ServiceConsumer{{Partition: "part-1"}, {Peer: "dc2"}}},
+		{Name: "backend", Consumers: []v1alpha1.ServiceConsumer{{Peer: "dc2"}}},
+	}
+
+	updated := setPeerConsumers(exported, "dc2", []v1alpha1.PeeringExportedService{{Name: "frontend"}, {Name: "api", Namespace: "ns1"}})
+	require.Equal(t, []v1alpha1.ExportedService{
+		{Name: "frontend", Consumers: []v1alpha1.ServiceConsumer{{Partition: "part-1"}, {Peer: "dc2"}}},
+		{Name: "api", Namespace: "ns1", Consumers: []v1alpha1.ServiceConsumer{{Peer: "dc2"}}},
+	}, updated)
+	require.True(t, exportedServicesEqual(updated, setPeerConsumers(updated, "dc2", []v1alpha1.PeeringExportedService{{Name: "frontend"}, {Name: "api", Namespace: "ns1"}})))
+
+	// The consumers of other peers and partitions are kept.
+	require.Equal(t, []v1alpha1.ExportedService{
+		{Name: "frontend", Consumers: []v1alpha1.ServiceConsumer{{Partition: "part-1"}}},
+	}, setPeerConsumers(updated, "dc2", nil))
+}
+
+func peeringScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	return s
+}
+
+func peeringConsulClient(t *testing.T, datacenter string) *capi.Client {
+	t.Helper()
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.Datacenter = datacenter
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = consul.Stop()
+	})
+	consul.WaitForLeader(t)
+	consulClient, err := capi.NewClient(&capi.Config{Address: consul.HTTPAddr})
+	require.NoError(t, err)
+	return consulClient
+}
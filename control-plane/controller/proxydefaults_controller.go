@@ -28,7 +28,7 @@ func (r *ProxyDefaultsController) Reconcile(ctx context.Context, req ctrl.Reques
 }
 
 func (r *ProxyDefaultsController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ProxyDefaultsController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *ProxyDefaultsController) UpdateStatus(ctx context.Context, obj client.O
 }
 
 func (r *ProxyDefaultsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ProxyDefaults{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ProxyDefaults{}, r, r.ConfigEntryController.Shards)
 }
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+// SamenessGroupController reconciles a SamenessGroup object.
+type SamenessGroupController struct {
+	client.Client
+	Log                   logr.Logger
+	Scheme                *runtime.Scheme
+	ConfigEntryController *ConfigEntryController
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=samenessgroups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=samenessgroups/status,verbs=get;update;patch
+
+func (r *SamenessGroupController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.ConfigEntryController.ReconcileEntry(ctx, r, req, &consulv1alpha1.SamenessGroup{})
+}
+
+func (r *SamenessGroupController) Logger(name types.NamespacedName) logr.Logger {
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
+}
+
+func (r *SamenessGroupController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return r.Status().Update(ctx, obj, opts...)
+}
+
+// ValidateReferences implements ReferenceValidator. It checks that the
+// partitions and peers that are members of the sameness group exist.
+func (r *SamenessGroupController) ValidateReferences(ctx context.Context, configEntry common.ConfigEntryResource) error {
+	samenessGroup, ok := configEntry.(*consulv1alpha1.SamenessGroup)
+	if !ok {
+		return nil
+	}
+	consulClient := r.ConfigEntryController.ConsulClient
+
+	for _, member := range samenessGroup.Spec.Members {
+		if member.Partition != "" {
+			partition, _, err := consulClient.Partitions().Read(ctx, member.Partition, nil)
+			if err != nil {
+				return fmt.Errorf("checking if partition %q exists: %w", member.Partition, err)
+			}
+			if partition == nil || partition.DeletedAt != nil {
+				return fmt.Errorf("partition %q not found", member.Partition)
+			}
+			continue
+		}
+		peering, _, err := consulClient.Peerings().Read(ctx, member.Peer, nil)
+		if err != nil {
+			return fmt.Errorf("checking if peer %q exists: %w", member.Peer, err)
+		}
+		if peering == nil || peering.State == capi.PeeringStateDeleting {
+			return fmt.Errorf("peer %q not found", member.Peer)
+		}
+	}
+	return nil
+}
+
+func (r *SamenessGroupController) SetupWithManager(mgr ctrl.Manager) error {
+	return setupWithManager(mgr, &consulv1alpha1.SamenessGroup{}, r, r.ConfigEntryController.Shards)
+}
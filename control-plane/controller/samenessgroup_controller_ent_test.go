@@ -0,0 +1,108 @@
+//go:build enterprise
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/controller"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Like ExportedServices, sameness groups are only supported in Consul
+// Enterprise, so the controller is tested here rather than in the
+// configentry_controller tests.
+
+// Test that a sameness group is only written once its member partitions and
+// peers exist, and that the status reports why it isn't synced yet.
+func TestSamenessGroupController_validatesReferences(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+
+	samenessGroup := &v1alpha1.SamenessGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "group",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.SamenessGroupSpec{
+			IncludeLocal: true,
+			Members: []v1alpha1.SamenessGroupMember{
+				{Partition: "other"},
+				{Peer: "peer"},
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, samenessGroup)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(samenessGroup).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+	})
+	req.NoError(err)
+
+	r := &controller.SamenessGroupController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		Scheme: s,
+		ConfigEntryController: &controller.ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: "datacenter",
+		},
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: samenessGroup.Namespace,
+		Name:      samenessGroup.KubernetesName(),
+	}
+	reconcile := func() (corev1.ConditionStatus, string, error) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+		var updated v1alpha1.SamenessGroup
+		req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+		status, _, message := updated.SyncedCondition()
+		return status, message, err
+	}
+
+	// The partition doesn't exist yet.
+	status, message, err := reconcile()
+	req.EqualError(err, `partition "other" not found`)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(`partition "other" not found`, message)
+
+	_, _, err = consulClient.Partitions().Create(ctx, &capi.Partition{Name: "other"}, nil)
+	req.NoError(err)
+
+	// The peer doesn't exist yet.
+	status, message, err = reconcile()
+	req.EqualError(err, `peer "peer" not found`)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(`peer "peer" not found`, message)
+
+	_, _, err = consulClient.Peerings().GenerateToken(ctx, capi.PeeringGenerateTokenRequest{PeerName: "peer"}, nil)
+	req.NoError(err)
+
+	status, _, err = reconcile()
+	req.NoError(err)
+	req.Equal(corev1.ConditionTrue, status)
+	entry, _, err := consulClient.ConfigEntries().Get(capi.SamenessGroup, "group", nil)
+	req.NoError(err)
+	group, ok := entry.(*capi.SamenessGroupConfigEntry)
+	req.True(ok)
+	req.True(group.IncludeLocal)
+	req.Equal([]capi.SamenessGroupMember{{Partition: "other"}, {Peer: "peer"}}, group.Members)
+}
@@ -28,7 +28,7 @@ func (r *ServiceDefaultsController) Reconcile(ctx context.Context, req ctrl.Requ
 }
 
 func (r *ServiceDefaultsController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ServiceDefaultsController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *ServiceDefaultsController) UpdateStatus(ctx context.Context, obj client
 }
 
 func (r *ServiceDefaultsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceDefaults{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ServiceDefaults{}, r, r.ConfigEntryController.Shards)
 }
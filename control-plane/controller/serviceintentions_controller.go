@@ -2,8 +2,11 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,13 +31,33 @@ func (r *ServiceIntentionsController) Reconcile(ctx context.Context, req ctrl.Re
 }
 
 func (r *ServiceIntentionsController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ServiceIntentionsController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
 	return r.Status().Update(ctx, obj, opts...)
 }
 
+// ValidateReferences implements ReferenceValidator. It checks that the JWT
+// providers that the intentions reference exist, since Consul rejects
+// intentions that reference a missing provider.
+func (r *ServiceIntentionsController) ValidateReferences(_ context.Context, configEntry common.ConfigEntryResource) error {
+	svcIntentions, ok := configEntry.(*consulv1alpha1.ServiceIntentions)
+	if !ok {
+		return nil
+	}
+	for _, name := range svcIntentions.JWTProviderNames() {
+		_, _, err := r.ConfigEntryController.ConsulClient.ConfigEntries().Get(capi.JWTProvider, name, nil)
+		if isNotFoundErr(err) {
+			return fmt.Errorf("JWT provider %q not found", name)
+		}
+		if err != nil {
+			return fmt.Errorf("checking if JWT provider %q exists: %w", name, err)
+		}
+	}
+	return nil
+}
+
 func (r *ServiceIntentionsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceIntentions{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ServiceIntentions{}, r, r.ConfigEntryController.Shards)
 }
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Test that intentions are only synced once the JWT providers they reference
+// exist, and that the status reports why they aren't synced yet.
+func TestServiceIntentionsController_validatesReferences(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+
+	svcIntentions := &v1alpha1.ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.ServiceIntentionsSpec{
+			Destination: v1alpha1.Destination{
+				Name: "backend",
+			},
+			Sources: v1alpha1.SourceIntentions{
+				{
+					Name:   "frontend",
+					Action: "allow",
+				},
+			},
+			JWT: &v1alpha1.IntentionJWTRequirement{
+				Providers: []*v1alpha1.IntentionJWTProvider{{Name: "okta"}},
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, svcIntentions)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(svcIntentions).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+	})
+	req.NoError(err)
+
+	r := &ServiceIntentionsController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		Scheme: s,
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: datacenterName,
+		},
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: svcIntentions.Namespace,
+		Name:      svcIntentions.KubernetesName(),
+	}
+	reconcile := func() (corev1.ConditionStatus, string, string, error) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+		var updated v1alpha1.ServiceIntentions
+		req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+		status, reason, message := updated.SyncedCondition()
+		return status, reason, message, err
+	}
+
+	// The JWT provider doesn't exist yet.
+	status, reason, message, err := reconcile()
+	req.EqualError(err, `JWT provider "okta" not found`)
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(ReferenceNotFoundError, reason)
+	req.Equal(`JWT provider "okta" not found`, message)
+	_, _, err = consulClient.ConfigEntries().Get(capi.ServiceIntentions, "backend", nil)
+	req.True(isNotFoundErr(err))
+
+	_, _, err = consulClient.ConfigEntries().Set(&capi.JWTProviderConfigEntry{
+		Kind: capi.JWTProvider,
+		Name: "okta",
+		JSONWebKeySet: &capi.JSONWebKeySet{
+			Remote: &capi.RemoteJWKS{URI: "https://jwks.example.com"},
+		},
+	}, nil)
+	req.NoError(err)
+
+	status, _, _, err = reconcile()
+	req.NoError(err)
+	req.Equal(corev1.ConditionTrue, status)
+	entry, _, err := consulClient.ConfigEntries().Get(capi.ServiceIntentions, "backend", nil)
+	req.NoError(err)
+	intentions, ok := entry.(*capi.ServiceIntentionsConfigEntry)
+	req.True(ok)
+	req.Equal("okta", intentions.JWT.Providers[0].Name)
+}
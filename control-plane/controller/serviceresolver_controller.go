@@ -28,7 +28,7 @@ func (r *ServiceResolverController) Reconcile(ctx context.Context, req ctrl.Requ
 }
 
 func (r *ServiceResolverController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ServiceResolverController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *ServiceResolverController) UpdateStatus(ctx context.Context, obj client
 }
 
 func (r *ServiceResolverController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceResolver{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ServiceResolver{}, r, r.ConfigEntryController.Shards)
 }
@@ -28,7 +28,7 @@ func (r *ServiceRouterController) Reconcile(ctx context.Context, req ctrl.Reques
 }
 
 func (r *ServiceRouterController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ServiceRouterController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *ServiceRouterController) UpdateStatus(ctx context.Context, obj client.O
 }
 
 func (r *ServiceRouterController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceRouter{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ServiceRouter{}, r, r.ConfigEntryController.Shards)
 }
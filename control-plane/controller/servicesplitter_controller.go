@@ -28,7 +28,7 @@ func (r *ServiceSplitterController) Reconcile(ctx context.Context, req ctrl.Requ
 }
 
 func (r *ServiceSplitterController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *ServiceSplitterController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
@@ -36,5 +36,5 @@ func (r *ServiceSplitterController) UpdateStatus(ctx context.Context, obj client
 }
 
 func (r *ServiceSplitterController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceSplitter{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.ServiceSplitter{}, r, r.ConfigEntryController.Shards)
 }
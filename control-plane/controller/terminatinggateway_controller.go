@@ -2,8 +2,13 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	capi "github.com/hashicorp/consul/api"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -12,12 +17,31 @@ import (
 	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 )
 
+const (
+	// TerminatingGatewayMetaKey is the key in the meta of the external
+	// services of a terminating gateway, and of the node they're registered
+	// on, that records the name of the gateway.
+	TerminatingGatewayMetaKey = "consul.hashicorp.com/terminating-gateway"
+
+	// externalServicePolicySuffix is the suffix of the names of the ACL
+	// policies that give a terminating gateway service:write on its external
+	// services.
+	externalServicePolicySuffix = "-write-policy"
+)
+
 // TerminatingGatewayController is the controller for TerminatingGateway resources.
 type TerminatingGatewayController struct {
 	client.Client
 	Log                   logr.Logger
 	Scheme                *runtime.Scheme
 	ConfigEntryController *ConfigEntryController
+
+	// ACLRolePrefix is the prefix of the ACL roles that server-acl-init
+	// creates for terminating gateways, i.e. the role of gateway "foo" is
+	// "<ACLRolePrefix>-foo-acl-role". If it's set, the role of each gateway
+	// is given service:write on the gateway's external services. If it's
+	// empty, ACL policies aren't managed.
+	ACLRolePrefix string
 }
 
 // +kubebuilder:rbac:groups=consul.hashicorp.com,resources=terminatinggateways,verbs=get;list;watch;create;update;patch;delete
@@ -28,13 +52,250 @@ func (r *TerminatingGatewayController) Reconcile(ctx context.Context, req ctrl.R
 }
 
 func (r *TerminatingGatewayController) Logger(name types.NamespacedName) logr.Logger {
-	return r.Log.WithValues("request", name)
+	return r.Log.WithValues("namespace", name.Namespace, "resource", name.Name)
 }
 
 func (r *TerminatingGatewayController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
 	return r.Status().Update(ctx, obj, opts...)
 }
 
+// SyncConsulResources implements ConsulResourceSyncer. It registers the
+// external services of the gateway in Consul's catalog, deregisters the ones
+// that were removed from the gateway and, if ACLs are managed, gives the
+// gateway's ACL role service:write on them.
+func (r *TerminatingGatewayController) SyncConsulResources(ctx context.Context, configEntry common.ConfigEntryResource) error {
+	gateway, ok := configEntry.(*consulv1alpha1.TerminatingGateway)
+	if !ok {
+		return nil
+	}
+	return r.syncExternalServices(ctx, gateway, gateway.Spec.ExternalServices)
+}
+
+// DeleteConsulResources implements ConsulResourceSyncer. It deregisters the
+// external services of the gateway and deletes their ACL policies.
+func (r *TerminatingGatewayController) DeleteConsulResources(ctx context.Context, configEntry common.ConfigEntryResource) error {
+	gateway, ok := configEntry.(*consulv1alpha1.TerminatingGateway)
+	if !ok {
+		return nil
+	}
+	return r.syncExternalServices(ctx, gateway, nil)
+}
+
 func (r *TerminatingGatewayController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r)
+	return setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r, r.ConfigEntryController.Shards)
+}
+
+// syncExternalServices makes the external services registered for the
+// gateway, and their ACL policies, match services.
+func (r *TerminatingGatewayController) syncExternalServices(ctx context.Context, gateway *consulv1alpha1.TerminatingGateway, services []consulv1alpha1.ExternalService) error {
+	consulClient := r.ConfigEntryController.ConsulClient
+	node := externalServicesNodeName(gateway)
+	meta := map[string]string{
+		common.SourceKey:          common.SourceValue,
+		TerminatingGatewayMetaKey: gateway.ConsulName(),
+	}
+
+	desired := make(map[string]bool)
+	for _, svc := range services {
+		namespace := r.externalServiceNamespace(svc)
+		if r.ConfigEntryController.EnableConsulNamespaces {
+			if _, err := namespaces.EnsureExists(consulClient, namespace, r.ConfigEntryController.CrossNSACLPolicy); err != nil {
+				return fmt.Errorf("creating consul namespace %q: %w", namespace, err)
+			}
+		}
+		registration := &capi.CatalogRegistration{
+			Node:     node,
+			Address:  "127.0.0.1",
+			NodeMeta: meta,
+			Service: &capi.AgentService{
+				ID:        svc.Name,
+				Service:   svc.Name,
+				Address:   svc.Hostname,
+				Port:      svc.Port,
+				Meta:      meta,
+				Namespace: namespace,
+			},
+		}
+		if _, err := consulClient.Catalog().Register(registration, (&capi.WriteOptions{}).WithContext(ctx)); err != nil {
+			return fmt.Errorf("registering external service %q: %w", svc.Name, err)
+		}
+		desired[namespace+"/"+svc.Name] = true
+	}
+
+	opts := &capi.QueryOptions{}
+	if r.ConfigEntryController.EnableConsulNamespaces {
+		opts.Namespace = common.WildcardNamespace
+	}
+	registered, _, err := consulClient.Catalog().NodeServiceList(node, opts.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("listing external services: %w", err)
+	}
+	if registered != nil {
+		for _, svc := range registered.Services {
+			if desired[svc.Namespace+"/"+svc.Service] || svc.Meta[TerminatingGatewayMetaKey] != gateway.ConsulName() {
+				continue
+			}
+			_, err := consulClient.Catalog().Deregister(&capi.CatalogDeregistration{
+				Node:      node,
+				ServiceID: svc.ID,
+				Namespace: svc.Namespace,
+			}, (&capi.WriteOptions{}).WithContext(ctx))
+			if err != nil {
+				return fmt.Errorf("deregistering external service %q: %w", svc.Service, err)
+			}
+		}
+		if len(desired) == 0 {
+			_, err := consulClient.Catalog().Deregister(&capi.CatalogDeregistration{Node: node}, (&capi.WriteOptions{}).WithContext(ctx))
+			if err != nil {
+				return fmt.Errorf("deregistering node %q: %w", node, err)
+			}
+		}
+	}
+
+	if r.ACLRolePrefix == "" {
+		return nil
+	}
+	return r.syncACLPolicies(ctx, gateway, services)
+}
+
+// syncACLPolicies makes the policies attached to the gateway's ACL role that
+// give it service:write on its external services match services.
+func (r *TerminatingGatewayController) syncACLPolicies(ctx context.Context, gateway *consulv1alpha1.TerminatingGateway, services []consulv1alpha1.ExternalService) error {
+	consulClient := r.ConfigEntryController.ConsulClient
+	role, err := r.gatewayACLRole(ctx, gateway)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		if len(services) == 0 {
+			return nil
+		}
+		return fmt.Errorf("ACL role of terminating gateway %q not found", gateway.ConsulName())
+	}
+
+	desired := make(map[string]bool)
+	for _, svc := range services {
+		policy := &capi.ACLPolicy{
+			Name:        r.externalServicePolicyName(gateway, svc),
+			Description: fmt.Sprintf("Write policy for external service %q of terminating gateway %q", svc.Name, gateway.ConsulName()),
+			Rules:       r.externalServiceRules(svc),
+		}
+		existing, _, err := consulClient.ACL().PolicyReadByName(policy.Name, (&capi.QueryOptions{}).WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("reading ACL policy %q: %w", policy.Name, err)
+		}
+		if existing == nil {
+			_, _, err = consulClient.ACL().PolicyCreate(policy, (&capi.WriteOptions{}).WithContext(ctx))
+		} else if existing.Rules != policy.Rules {
+			policy.ID = existing.ID
+			_, _, err = consulClient.ACL().PolicyUpdate(policy, (&capi.WriteOptions{}).WithContext(ctx))
+		}
+		if err != nil {
+			return fmt.Errorf("writing ACL policy %q: %w", policy.Name, err)
+		}
+		desired[policy.Name] = true
+	}
+
+	var links []*capi.ACLRolePolicyLink
+	var stale []string
+	linked := make(map[string]bool)
+	for _, link := range role.Policies {
+		if r.isExternalServicePolicy(gateway, link.Name) && !desired[link.Name] {
+			stale = append(stale, link.Name)
+			continue
+		}
+		links = append(links, link)
+		linked[link.Name] = true
+	}
+	for _, svc := range services {
+		if name := r.externalServicePolicyName(gateway, svc); !linked[name] {
+			links = append(links, &capi.ACLRolePolicyLink{Name: name})
+			linked[name] = true
+		}
+	}
+	if len(stale) > 0 || len(links) != len(role.Policies) {
+		role.Policies = links
+		if _, _, err := consulClient.ACL().RoleUpdate(role, (&capi.WriteOptions{}).WithContext(ctx)); err != nil {
+			return fmt.Errorf("updating ACL role %q: %w", role.Name, err)
+		}
+	}
+
+	// Policies are only deleted once they've been detached from the role
+	// so that the role never references a policy that doesn't exist.
+	for _, name := range stale {
+		policy, _, err := consulClient.ACL().PolicyReadByName(name, (&capi.QueryOptions{}).WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("reading ACL policy %q: %w", name, err)
+		}
+		if policy == nil {
+			continue
+		}
+		if _, err := consulClient.ACL().PolicyDelete(policy.ID, (&capi.WriteOptions{}).WithContext(ctx)); err != nil {
+			return fmt.Errorf("deleting ACL policy %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// gatewayACLRole returns the ACL role that server-acl-init created for the
+// gateway, or nil if it doesn't exist. In secondary datacenters the role
+// name is suffixed with the datacenter.
+func (r *TerminatingGatewayController) gatewayACLRole(ctx context.Context, gateway *consulv1alpha1.TerminatingGateway) (*capi.ACLRole, error) {
+	name := fmt.Sprintf("%s-%s-acl-role", r.ACLRolePrefix, gateway.ConsulName())
+	names := []string{name}
+	if r.ConfigEntryController.DatacenterName != "" {
+		names = append(names, fmt.Sprintf("%s-%s", name, r.ConfigEntryController.DatacenterName))
+	}
+	for _, name := range names {
+		role, _, err := r.ConfigEntryController.ConsulClient.ACL().RoleReadByName(name, (&capi.QueryOptions{}).WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("reading ACL role %q: %w", name, err)
+		}
+		if role != nil {
+			return role, nil
+		}
+	}
+	return nil, nil
+}
+
+// externalServicePolicyName returns the name of the ACL policy that gives
+// the gateway service:write on the external service.
+func (r *TerminatingGatewayController) externalServicePolicyName(gateway *consulv1alpha1.TerminatingGateway, svc consulv1alpha1.ExternalService) string {
+	if r.ConfigEntryController.EnableConsulNamespaces {
+		return fmt.Sprintf("%s-%s-%s%s", gateway.ConsulName(), r.externalServiceNamespace(svc), svc.Name, externalServicePolicySuffix)
+	}
+	return fmt.Sprintf("%s-%s%s", gateway.ConsulName(), svc.Name, externalServicePolicySuffix)
+}
+
+// isExternalServicePolicy returns true if the policy with the given name
+// could have been created for an external service of the gateway.
+func (r *TerminatingGatewayController) isExternalServicePolicy(gateway *consulv1alpha1.TerminatingGateway, name string) bool {
+	return strings.HasPrefix(name, gateway.ConsulName()+"-") && strings.HasSuffix(name, externalServicePolicySuffix)
+}
+
+func (r *TerminatingGatewayController) externalServiceRules(svc consulv1alpha1.ExternalService) string {
+	rules := fmt.Sprintf("service %q {\n  policy = \"write\"\n}", svc.Name)
+	if r.ConfigEntryController.EnableConsulNamespaces {
+		rules = fmt.Sprintf("namespace %q {\n  %s\n}", r.externalServiceNamespace(svc),
+			strings.ReplaceAll(rules, "\n", "\n  "))
+	}
+	return rules
+}
+
+// externalServiceNamespace returns the Consul namespace that the external
+// service is registered in.
+func (r *TerminatingGatewayController) externalServiceNamespace(svc consulv1alpha1.ExternalService) string {
+	if !r.ConfigEntryController.EnableConsulNamespaces {
+		return ""
+	}
+	if svc.Namespace == "" {
+		return common.DefaultConsulNamespace
+	}
+	return svc.Namespace
+}
+
+// externalServicesNodeName returns the name of the Consul node that the
+// external services of the gateway are registered on.
+func externalServicesNodeName(gateway *consulv1alpha1.TerminatingGateway) string {
+	return fmt.Sprintf("%s-external-services", gateway.ConsulName())
 }
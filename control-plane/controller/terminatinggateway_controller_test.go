@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Test that the external services of a terminating gateway are registered,
+// linked to the gateway and written to the gateway's ACL role, and that they
+// are cleaned up when they're removed from the gateway or it's deleted.
+func TestTerminatingGatewayController_externalServices(t *testing.T) {
+	t.Parallel()
+	req := require.New(t)
+	ctx := context.Background()
+	adminToken := "123e4567-e89b-12d3-a456-426614174000"
+
+	gateway := &v1alpha1.TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "terminating-gateway",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.TerminatingGatewaySpec{
+			ExternalServices: []v1alpha1.ExternalService{
+				{
+					Name:     "example",
+					Hostname: "api.example.com",
+					Port:     443,
+					CASecret: "example-ca",
+				},
+			},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, gateway)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(gateway).Build()
+
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.ACL.Enabled = true
+		c.ACL.Tokens.InitialManagement = adminToken
+	})
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForLeader(t)
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+		Token:   adminToken,
+	})
+	req.NoError(err)
+
+	// The role and its policy are normally created by server-acl-init.
+	_, _, err = consulClient.ACL().PolicyCreate(&capi.ACLPolicy{
+		Name:  "consul-terminating-gateway-policy",
+		Rules: `service "terminating-gateway" { policy = "write" }`,
+	}, nil)
+	req.NoError(err)
+	_, _, err = consulClient.ACL().RoleCreate(&capi.ACLRole{
+		Name:     "consul-terminating-gateway-acl-role",
+		Policies: []*capi.ACLRolePolicyLink{{Name: "consul-terminating-gateway-policy"}},
+	}, nil)
+	req.NoError(err)
+
+	r := &TerminatingGatewayController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+		Scheme: s,
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: datacenterName,
+		},
+		ACLRolePrefix: "consul",
+	}
+	namespacedName := types.NamespacedName{
+		Namespace: gateway.Namespace,
+		Name:      gateway.KubernetesName(),
+	}
+	reconcile := func() {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+		req.NoError(err)
+	}
+	rolePolicies := func() []string {
+		role, _, err := consulClient.ACL().RoleReadByName("consul-terminating-gateway-acl-role", nil)
+		req.NoError(err)
+		var names []string
+		for _, link := range role.Policies {
+			names = append(names, link.Name)
+		}
+		return names
+	}
+
+	reconcile()
+	var updated v1alpha1.TerminatingGateway
+	req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+	req.Equal(corev1.ConditionTrue, updated.SyncedConditionStatus())
+
+	instances, _, err := consulClient.Catalog().Service("example", "", nil)
+	req.NoError(err)
+	req.Len(instances, 1)
+	req.Equal("terminating-gateway-external-services", instances[0].Node)
+	req.Equal("api.example.com", instances[0].ServiceAddress)
+	req.Equal(443, instances[0].ServicePort)
+	req.Equal("terminating-gateway", instances[0].ServiceMeta[TerminatingGatewayMetaKey])
+
+	entry, _, err := consulClient.ConfigEntries().Get(capi.TerminatingGateway, "terminating-gateway", nil)
+	req.NoError(err)
+	terminatingGateway, ok := entry.(*capi.TerminatingGatewayConfigEntry)
+	req.True(ok)
+	req.Equal([]capi.LinkedService{
+		{
+			Name:   "example",
+			CAFile: "/consul/external-ca/example-ca/ca.crt",
+		},
+	}, terminatingGateway.Services)
+
+	req.Equal([]string{"consul-terminating-gateway-policy", "terminating-gateway-example-write-policy"}, rolePolicies())
+	policy, _, err := consulClient.ACL().PolicyReadByName("terminating-gateway-example-write-policy", nil)
+	req.NoError(err)
+	req.Equal("service \"example\" {\n  policy = \"write\"\n}", policy.Rules)
+
+	// Replace the external service with another one.
+	updated.Spec.ExternalServices = []v1alpha1.ExternalService{
+		{
+			Name:     "other",
+			Hostname: "10.0.0.1",
+			Port:     8080,
+		},
+	}
+	req.NoError(fakeClient.Update(ctx, &updated))
+	reconcile()
+
+	instances, _, err = consulClient.Catalog().Service("example", "", nil)
+	req.NoError(err)
+	req.Empty(instances)
+	instances, _, err = consulClient.Catalog().Service("other", "", nil)
+	req.NoError(err)
+	req.Len(instances, 1)
+	req.Equal([]string{"consul-terminating-gateway-policy", "terminating-gateway-other-write-policy"}, rolePolicies())
+	policy, _, err = consulClient.ACL().PolicyReadByName("terminating-gateway-example-write-policy", nil)
+	req.NoError(err)
+	req.Nil(policy)
+
+	// Delete the gateway.
+	req.NoError(fakeClient.Get(ctx, namespacedName, &updated))
+	updated.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	req.NoError(fakeClient.Update(ctx, &updated))
+	reconcile()
+
+	node, _, err := consulClient.Catalog().Node("terminating-gateway-external-services", nil)
+	req.NoError(err)
+	req.Nil(node)
+	req.Equal([]string{"consul-terminating-gateway-policy"}, rolePolicies())
+	policy, _, err = consulClient.ACL().PolicyReadByName("terminating-gateway-other-write-policy", nil)
+	req.NoError(err)
+	req.Nil(policy)
+}
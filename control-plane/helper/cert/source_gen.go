@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/x509"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -14,10 +15,16 @@ import (
 // a CA will be generated. On subsequent calls, the same CA will be used to
 // create a new certificate when the expiry is near. To create a new CA, a
 // new GenSource must be allocated.
+//
+// If CACert and CAKey are set, leaf certificates are signed by that CA
+// instead of a generated one.
 type GenSource struct {
 	Name  string   // Name is used as part of the common name
 	Hosts []string // Hosts is the list of hosts to make the leaf valid for
 
+	CACert string // CACert is the PEM-encoded CA certificate to sign leaves with
+	CAKey  string // CAKey is the PEM-encoded private key of CACert
+
 	// Expiry is the duration that a certificate is valid for. This
 	// defaults to 24 hours.
 	Expiry time.Duration
@@ -103,6 +110,10 @@ func (s *GenSource) expiryWithin() time.Duration {
 }
 
 func (s *GenSource) generateCA() error {
+	if s.CACert != "" || s.CAKey != "" {
+		return s.loadCA()
+	}
+
 	// generate the CA
 	signer, _, caCertPem, caCertTemplate, err := GenerateCA(s.Name + " CA")
 	if err != nil {
@@ -114,3 +125,20 @@ func (s *GenSource) generateCA() error {
 
 	return nil
 }
+
+// loadCA uses the CA from CACert and CAKey instead of generating one.
+func (s *GenSource) loadCA() error {
+	caCertTemplate, err := ParseCert([]byte(s.CACert))
+	if err != nil {
+		return fmt.Errorf("parsing CA certificate: %s", err)
+	}
+	signer, err := ParseSigner(s.CAKey)
+	if err != nil {
+		return fmt.Errorf("parsing CA key: %s", err)
+	}
+	s.caSigner = signer
+	s.caCert = s.CACert
+	s.caCertTemplate = caCertTemplate
+
+	return nil
+}
@@ -65,6 +65,38 @@ func TestGenSource_expiry(t *testing.T) {
 	testBundleVerify(t, &bundle)
 }
 
+// Test that leaf certificates are signed by the provided CA.
+func TestGenSource_providedCA(t *testing.T) {
+	t.Parallel()
+
+	if !hasOpenSSL {
+		t.Skip("openssl not found")
+		return
+	}
+
+	_, caKey, caCert, _, err := GenerateCA("Provided CA")
+	require.NoError(t, err)
+
+	source := testGenSource()
+	source.CACert = caCert
+	source.CAKey = caKey
+	bundle, err := source.Certificate(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, caCert, string(bundle.CACert))
+	testBundleVerify(t, &bundle)
+}
+
+// Test that an invalid provided CA is an error.
+func TestGenSource_invalidProvidedCA(t *testing.T) {
+	t.Parallel()
+
+	source := testGenSource()
+	source.CACert = "not a certificate"
+	source.CAKey = "not a key"
+	_, err := source.Certificate(context.Background(), nil)
+	require.EqualError(t, err, "parsing CA certificate: no PEM-encoded data found")
+}
+
 func testGenSource() *GenSource {
 	return &GenSource{
 		Name:  "Test",
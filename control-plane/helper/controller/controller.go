@@ -22,7 +22,10 @@ type Controller struct {
 	Log      hclog.Logger
 	Resource Resource
 
-	informer cache.SharedIndexInformer
+	// informerLock guards informer, which is read by the health checks
+	// while Run sets it.
+	informerLock sync.RWMutex
+	informer     cache.SharedIndexInformer
 }
 
 // Event is something that occurred to the resources we're watching.
@@ -48,7 +51,9 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 
 	// Create an informer so we can keep track of all service changes.
 	informer := c.Resource.Informer()
+	c.informerLock.Lock()
 	c.informer = informer
+	c.informerLock.Unlock()
 
 	// Create a queue for storing items to process from the informer.
 	var queueOnce sync.Once
@@ -64,14 +69,14 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 			// convert the resource object into a key (in this case
 			// we are just doing it in the format of 'namespace/name')
 			key, err := cache.MetaNamespaceKeyFunc(obj)
-			c.Log.Debug("queue", "op", "add", "key", key)
+			c.Log.With(ResourceLogArgs(key)...).Debug("queue", "op", "add")
 			if err == nil {
 				queue.Add(Event{Key: key, Obj: obj})
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(newObj)
-			c.Log.Debug("queue", "op", "update", "key", key)
+			c.Log.With(ResourceLogArgs(key)...).Debug("queue", "op", "update")
 			if err == nil {
 				queue.Add(Event{Key: key, Obj: newObj})
 			}
@@ -136,6 +141,8 @@ func (c *Controller) Run(stopCh <-chan struct{}) {
 
 // HasSynced implements cache.Controller.
 func (c *Controller) HasSynced() bool {
+	c.informerLock.RLock()
+	defer c.informerLock.RUnlock()
 	if c.informer == nil {
 		return false
 	}
@@ -145,6 +152,8 @@ func (c *Controller) HasSynced() bool {
 
 // LastSyncResourceVersion implements cache.Controller.
 func (c *Controller) LastSyncResourceVersion() string {
+	c.informerLock.RLock()
+	defer c.informerLock.RUnlock()
 	if c.informer == nil {
 		return ""
 	}
@@ -176,7 +185,7 @@ func (c *Controller) processSingle(
 
 	// If we got the item successfully, call the proper method
 	if err == nil {
-		c.Log.Debug("processing object", "key", key, "exists", exists)
+		c.Log.With(ResourceLogArgs(key)...).Debug("processing object", "exists", exists)
 		c.Log.Trace("processing object", "object", item)
 		if !exists {
 			// In the case of deletes, the item is no longer in the cache so
@@ -193,10 +202,10 @@ func (c *Controller) processSingle(
 
 	if err != nil {
 		if queue.NumRequeues(event) < 5 {
-			c.Log.Error("failed processing item, retrying", "key", key, "error", err)
+			c.Log.With(ResourceLogArgs(key)...).Error("failed processing item, retrying", "error", err)
 			queue.AddRateLimited(rawEvent)
 		} else {
-			c.Log.Error("failed processing item, no more retries", "key", key, "error", err)
+			c.Log.With(ResourceLogArgs(key)...).Error("failed processing item, no more retries", "error", err)
 			queue.Forget(rawEvent)
 			utilruntime.HandleError(err)
 		}
@@ -211,7 +220,7 @@ func (c *Controller) processSingle(
 func (c *Controller) informerDeleteHandler(queue workqueue.RateLimitingInterface) func(obj interface{}) {
 	return func(obj interface{}) {
 		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
-		c.Log.Debug("queue", "op", "delete", "key", key)
+		c.Log.With(ResourceLogArgs(key)...).Debug("queue", "op", "delete")
 		if err == nil {
 			// obj might be of type `cache.DeletedFinalStateUnknown`
 			// in which case we need to extract the object from
@@ -224,3 +233,14 @@ func (c *Controller) informerDeleteHandler(queue workqueue.RateLimitingInterface
 		}
 	}
 }
+
+// ResourceLogArgs returns the log arguments of the resource with the given
+// <namespace>/<name> key, so that the logs about a resource have the same
+// namespace and resource fields across components.
+func ResourceLogArgs(key string) []interface{} {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return []interface{}{"resource", key}
+	}
+	return []interface{}{"namespace", namespace, "resource", name}
+}
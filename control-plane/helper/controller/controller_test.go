@@ -312,3 +312,9 @@ func testResource(client kubernetes.Interface) (Resource, map[string]interface{}
 		},
 	), m, deleted, &lock
 }
+
+func TestResourceLogArgs(t *testing.T) {
+	require.Equal(t, []interface{}{"namespace", "default", "resource", "foo"}, ResourceLogArgs("default/foo"))
+	require.Equal(t, []interface{}{"namespace", "", "resource", "foo"}, ResourceLogArgs("foo"))
+	require.Equal(t, []interface{}{"resource", "a/b/c"}, ResourceLogArgs("a/b/c"))
+}
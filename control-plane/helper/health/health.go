@@ -0,0 +1,133 @@
+// Package health serves the /healthz and /readyz endpoints of the
+// control-plane components and provides the checks of their dependencies.
+//
+// The endpoints are served in the same format as the health probes of
+// controller-runtime managers: /healthz and /readyz aggregate their checks,
+// /healthz/<check> and /readyz/<check> run a single check, and ?verbose lists
+// the results of the checks.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	capi "github.com/hashicorp/consul/api"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// LivenessPath is the path of the liveness endpoint. Its checks fail
+	// only if the component can't recover without a restart.
+	LivenessPath = "/healthz"
+	// ReadinessPath is the path of the readiness endpoint. Its checks fail
+	// while a dependency of the component is unavailable.
+	ReadinessPath = "/readyz"
+
+	// cacheSyncTimeout is how long the informer cache checks wait for the
+	// caches to sync.
+	cacheSyncTimeout = time.Second
+)
+
+// Register registers the liveness and readiness endpoints with the given
+// checks, which are keyed by their names, on mux.
+func Register(mux *http.ServeMux, liveness, readiness map[string]healthz.Checker) {
+	for path, checks := range map[string]map[string]healthz.Checker{
+		LivenessPath:  liveness,
+		ReadinessPath: readiness,
+	} {
+		handler := http.StripPrefix(path, &healthz.Handler{Checks: checks})
+		mux.Handle(path, handler)
+		mux.Handle(path+"/", handler)
+	}
+}
+
+// AddToManager adds the liveness and readiness checks of the controllers of
+// mgr, whose Consul client is client with config, to the health probes of
+// mgr. The manager must be created with a HealthProbeBindAddress to serve
+// them. The informer caches are only checked for readiness since their first
+// sync in a large cluster can take longer than the liveness probe allows.
+func AddToManager(mgr manager.Manager, client *capi.Client, config *capi.Config) error {
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	readiness := map[string]healthz.Checker{
+		"informers": CacheSynced(mgr.GetCache()),
+	}
+	AddConsulChecks(readiness, client, config)
+	for name, check := range readiness {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddConsulChecks adds the checks of the Consul dependency of a component
+// to checks: that the Consul servers are reachable through client and, if
+// config has an ACL token, that the token is valid. The token isn't checked
+// without one since the anonymous token can't be read.
+func AddConsulChecks(checks map[string]healthz.Checker, client *capi.Client, config *capi.Config) {
+	checks["consul"] = ConsulReachable(client)
+	if config.Token != "" || config.TokenFile != "" {
+		checks["acl-token"] = ACLTokenValid(client)
+	}
+}
+
+// ConsulReachable checks that the Consul servers are reachable through
+// client and have a leader.
+func ConsulReachable(client *capi.Client) healthz.Checker {
+	return func(req *http.Request) error {
+		leader, err := client.Status().LeaderWithQueryOptions((&capi.QueryOptions{}).WithContext(req.Context()))
+		if err != nil {
+			return fmt.Errorf("getting the Consul leader: %s", err)
+		}
+		if leader == "" {
+			return errors.New("the Consul servers don't have a leader")
+		}
+		return nil
+	}
+}
+
+// ACLTokenValid checks that the ACL token of client exists. It passes if
+// ACLs are disabled.
+func ACLTokenValid(client *capi.Client) healthz.Checker {
+	return func(req *http.Request) error {
+		_, _, err := client.ACL().TokenReadSelf((&capi.QueryOptions{}).WithContext(req.Context()))
+		if err != nil && !strings.Contains(err.Error(), "ACL support disabled") {
+			return fmt.Errorf("reading the ACL token: %s", err)
+		}
+		return nil
+	}
+}
+
+// CacheSynced checks that the informer caches of a controller-runtime
+// manager have synced.
+func CacheSynced(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("the informer caches haven't synced")
+		}
+		return nil
+	}
+}
+
+// InformersSynced checks that the given informers have synced.
+func InformersSynced(synced ...toolscache.InformerSynced) healthz.Checker {
+	return func(_ *http.Request) error {
+		for _, s := range synced {
+			if !s() {
+				return errors.New("the informers haven't synced")
+			}
+		}
+		return nil
+	}
+}
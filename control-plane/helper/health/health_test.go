@@ -0,0 +1,198 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	Register(mux,
+		map[string]healthz.Checker{
+			"ping": healthz.Ping,
+		},
+		map[string]healthz.Checker{
+			"ping":   healthz.Ping,
+			"consul": func(_ *http.Request) error { return errors.New("unreachable") },
+		})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cases := map[string]struct {
+		path       string
+		statusCode int
+	}{
+		"liveness": {
+			path:       "/healthz",
+			statusCode: http.StatusOK,
+		},
+		"readiness aggregates the checks": {
+			path:       "/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+		"single passing check": {
+			path:       "/readyz/ping",
+			statusCode: http.StatusOK,
+		},
+		"single failing check": {
+			path:       "/readyz/consul",
+			statusCode: http.StatusInternalServerError,
+		},
+		"excluded check": {
+			path:       "/readyz?exclude=consul",
+			statusCode: http.StatusOK,
+		},
+		"unknown check": {
+			path:       "/healthz/consul",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + c.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, c.statusCode, resp.StatusCode)
+		})
+	}
+}
+
+// consulClient returns a client of a fake Consul agent that responds to
+// path with statusCode and body.
+func consulClient(t *testing.T, path string, statusCode int, body string) *capi.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(statusCode)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client, err := capi.NewClient(&capi.Config{Address: server.URL})
+	require.NoError(t, err)
+	return client
+}
+
+func TestConsulReachable(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		statusCode int
+		body       string
+		expErr     string
+	}{
+		"leader": {
+			statusCode: http.StatusOK,
+			body:       `"10.0.0.1:8300"`,
+		},
+		"no leader": {
+			statusCode: http.StatusOK,
+			body:       `""`,
+			expErr:     "the Consul servers don't have a leader",
+		},
+		"error": {
+			statusCode: http.StatusInternalServerError,
+			body:       "No cluster leader",
+			expErr:     "getting the Consul leader",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := consulClient(t, "/v1/status/leader", c.statusCode, c.body)
+			err := ConsulReachable(client)(httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+			}
+		})
+	}
+}
+
+func TestACLTokenValid(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		statusCode int
+		body       string
+		expErr     bool
+	}{
+		"valid token": {
+			statusCode: http.StatusOK,
+			body:       `{"AccessorID": "6a1253d2-1785-24fd-91c2-f8e78c745511"}`,
+		},
+		"ACLs disabled": {
+			statusCode: http.StatusUnauthorized,
+			body:       "ACL support disabled",
+		},
+		"token not found": {
+			statusCode: http.StatusForbidden,
+			body:       "ACL not found",
+			expErr:     true,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := consulClient(t, "/v1/acl/token/self", c.statusCode, c.body)
+			err := ACLTokenValid(client)(httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+			if c.expErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAddConsulChecks(t *testing.T) {
+	t.Parallel()
+	client, err := capi.NewClient(capi.DefaultConfig())
+	require.NoError(t, err)
+
+	checks := map[string]healthz.Checker{}
+	AddConsulChecks(checks, client, &capi.Config{})
+	require.Contains(t, checks, "consul")
+	require.NotContains(t, checks, "acl-token")
+
+	checks = map[string]healthz.Checker{}
+	AddConsulChecks(checks, client, &capi.Config{TokenFile: "/consul/login/acl-token"})
+	require.Contains(t, checks, "consul")
+	require.Contains(t, checks, "acl-token")
+}
+
+func TestInformersSynced(t *testing.T) {
+	t.Parallel()
+	synced := func() bool { return true }
+	notSynced := func() bool { return false }
+
+	require.NoError(t, InformersSynced()(nil))
+	require.NoError(t, InformersSynced(synced, synced)(nil))
+	require.Error(t, InformersSynced(synced, notSynced)(nil))
+}
+
+func TestRegister_Verbose(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	Register(mux, map[string]healthz.Checker{"ping": healthz.Ping}, map[string]healthz.Checker{"ping": healthz.Ping})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/healthz?verbose")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "[+]ping ok")
+}
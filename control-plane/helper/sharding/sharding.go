@@ -0,0 +1,622 @@
+// Package sharding distributes the work of a controller among all of its
+// replicas instead of running it on a single leader.
+//
+// The work is split into a fixed number of shards, and the objects of a
+// Kubernetes namespace always belong to the same shard. Each shard is owned
+// by at most one replica at a time through a coordination.k8s.io Lease, and
+// each replica also renews a membership Lease so that the replicas can agree
+// on how many shards each of them should own. A replica that owns more than
+// its share releases the extra shards, and the other replicas acquire the
+// shards that are released or whose owner stopped renewing them. A Lease of
+// another replica counts as expired once it hasn't changed for its duration
+// on the clock of the replica that observes it, so that the replicas don't
+// depend on their clocks being in sync. When a replica acquires a shard, the
+// objects of the shard are reconciled so that the work that was missed
+// during the handoff isn't lost.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// LabelGroup is the label of the Leases of a Coordinator with its name.
+	LabelGroup = "consul.hashicorp.com/shard-group"
+	// LabelRole is the label of the Leases of a Coordinator with whether
+	// they're the Lease of a shard or of a replica.
+	LabelRole = "consul.hashicorp.com/shard-role"
+
+	roleShard  = "shard"
+	roleMember = "member"
+
+	// DefaultLeaseDuration is how long a shard is owned by a replica after it
+	// last renewed it, if LeaseDuration isn't set.
+	DefaultLeaseDuration = 15 * time.Second
+	// DefaultRenewInterval is how often a replica renews its Leases and
+	// rebalances the shards, if RenewInterval isn't set.
+	DefaultRenewInterval = 5 * time.Second
+)
+
+// Coordinator acquires, renews and releases the shards that the replica
+// owns. It's a manager.Runnable that runs on all the replicas.
+type Coordinator struct {
+	// Clientset is used to read and write the Leases.
+	Clientset kubernetes.Interface
+	// Namespace is the namespace of the Leases.
+	Namespace string
+	// Name is the prefix of the names of the Leases. Coordinators with the
+	// same name share the same shards.
+	Name string
+	// Identity is the unique identity of the replica, e.g. its pod name.
+	Identity string
+	// Shards is the number of shards. All the replicas must use the same
+	// number of shards.
+	Shards int
+	// LeaseDuration is how long a shard is owned by a replica after it last
+	// renewed it. A replica stops reconciling a shard once two thirds of it
+	// have passed without a renewal, so that it stops before another replica
+	// can acquire the shard.
+	LeaseDuration time.Duration
+	// RenewInterval is how often the Leases are renewed and the shards
+	// rebalanced. It must be less than a third of LeaseDuration.
+	RenewInterval time.Duration
+	Log           logr.Logger
+
+	mu sync.RWMutex
+	// owned are the shards that the replica owns, with the time until which
+	// the replica reconciles their objects.
+	owned map[int]time.Time
+	// onAcquire are called with the shards that the replica acquired.
+	onAcquire []func(ctx context.Context, shards map[int]bool)
+	// inflight are the numbers of reconciles of each shard that are running.
+	inflight map[int]int
+	// idle is closed, and then reset, when the last reconcile of a shard
+	// returns.
+	idle chan struct{}
+
+	// observed are the Leases of the other replicas as the replica last saw
+	// them change. It's only used by sync.
+	observed map[string]observedLease
+	// now returns the current time. It's time.Now outside of tests.
+	now func() time.Time
+}
+
+// Shard returns the shard of the objects in namespace.
+func (c *Coordinator) Shard(namespace string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(c.Shards))
+}
+
+// Owns returns true if the replica reconciles the objects in namespace. A
+// nil Coordinator owns all the namespaces.
+func (c *Coordinator) Owns(namespace string) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	deadline, ok := c.owned[c.Shard(namespace)]
+	return ok && c.clock().Before(deadline)
+}
+
+// OwnedShards returns the shards that the replica owns, in order.
+func (c *Coordinator) OwnedShards() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var shards []int
+	for shard, deadline := range c.owned {
+		if c.clock().Before(deadline) {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// Reconciler wraps reconciler so that it only reconciles the requests of the
+// namespaces that the replica owns. A shard isn't released until the
+// reconciles of its requests that are running have returned. A nil
+// Coordinator returns reconciler.
+func (c *Coordinator) Reconciler(reconciler reconcile.Reconciler) reconcile.Reconciler {
+	if c == nil {
+		return reconciler
+	}
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		shard := c.Shard(req.Namespace)
+		if !c.begin(shard) {
+			return reconcile.Result{}, nil
+		}
+		defer c.end(shard)
+		return reconciler.Reconcile(ctx, req)
+	})
+}
+
+// begin records that a reconcile of shard is running if the replica owns
+// shard, and returns whether it does.
+func (c *Coordinator) begin(shard int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline, ok := c.owned[shard]
+	if !ok || !c.clock().Before(deadline) {
+		return false
+	}
+	if c.inflight == nil {
+		c.inflight = make(map[int]int)
+	}
+	c.inflight[shard]++
+	return true
+}
+
+// end records that a reconcile of shard returned.
+func (c *Coordinator) end(shard int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight[shard]--
+	if c.inflight[shard] > 0 {
+		return
+	}
+	delete(c.inflight, shard)
+	if c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// busy returns true if reconciles of shard are running.
+func (c *Coordinator) busy(shard int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.inflight[shard] > 0
+}
+
+// waitIdle waits until no reconciles of shard are running or ctx is done.
+// The replica must have disowned shard first so that no new reconciles
+// begin.
+func (c *Coordinator) waitIdle(ctx context.Context, shard int) error {
+	for {
+		c.mu.Lock()
+		if c.inflight[shard] == 0 {
+			c.mu.Unlock()
+			return nil
+		}
+		if c.idle == nil {
+			c.idle = make(chan struct{})
+		}
+		idle := c.idle
+		c.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Watch adds a watch to blder so that the objects of the kind of obj are
+// reconciled when the replica acquires their shard. A nil Coordinator
+// returns blder.
+func (c *Coordinator) Watch(blder *builder.Builder, mgr manager.Manager, obj client.Object) (*builder.Builder, error) {
+	if c == nil {
+		return blder, nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	gvk.Kind += "List"
+	newList, err := mgr.GetScheme().New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := newList.(client.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", gvk)
+	}
+
+	events := make(chan event.GenericEvent)
+	reader := mgr.GetClient()
+	c.mu.Lock()
+	c.onAcquire = append(c.onAcquire, func(ctx context.Context, shards map[int]bool) {
+		l := list.DeepCopyObject().(client.ObjectList)
+		if err := reader.List(ctx, l); err != nil {
+			c.Log.Error(err, "unable to list the objects of the acquired shards", "kind", gvk.Kind)
+			return
+		}
+		items, err := meta.ExtractList(l)
+		if err != nil {
+			c.Log.Error(err, "unable to list the objects of the acquired shards", "kind", gvk.Kind)
+			return
+		}
+		for _, item := range items {
+			o, ok := item.(client.Object)
+			if !ok || !shards[c.Shard(o.GetNamespace())] {
+				continue
+			}
+			select {
+			case events <- event.GenericEvent{Object: o}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+	c.mu.Unlock()
+	return blder.Watches(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{}), nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable so that the
+// Coordinator runs on all the replicas.
+func (c *Coordinator) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable. It rebalances the shards every
+// RenewInterval until ctx is done, and then releases the shards and the
+// membership of the replica so that the other replicas can take them over
+// right away.
+func (c *Coordinator) Start(ctx context.Context) error {
+	if c.Shards <= 0 {
+		return fmt.Errorf("the number of shards must be greater than 0")
+	}
+	ticker := time.NewTicker(c.renewInterval())
+	defer ticker.Stop()
+	for {
+		if err := c.sync(ctx); err != nil {
+			c.Log.Error(err, "unable to rebalance the shards")
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), c.renewInterval())
+			defer cancel()
+			c.release(releaseCtx)
+			return nil
+		}
+	}
+}
+
+// sync renews the membership of the replica and then renews, releases and
+// acquires shards so that the replica owns its share of them.
+func (c *Coordinator) sync(ctx context.Context) error {
+	now := c.clock()
+	if err := c.renewMember(ctx, now); err != nil {
+		return fmt.Errorf("renewing the membership lease: %w", err)
+	}
+	leases, err := c.Clientset.CoordinationV1().Leases(c.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{LabelGroup: c.Name}).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("listing leases: %w", err)
+	}
+	c.observe(leases.Items, now)
+
+	members := 0
+	shardLeases := make(map[string]coordinationv1.Lease)
+	for _, lease := range leases.Items {
+		switch lease.Labels[LabelRole] {
+		case roleMember:
+			if lease.Name == c.memberLeaseName() {
+				members++
+			} else if c.expired(lease, now) {
+				// The replica crashed without deleting its membership.
+				c.deleteMember(ctx, lease)
+			} else {
+				members++
+			}
+		case roleShard:
+			shardLeases[lease.Name] = lease
+		}
+	}
+	if members == 0 {
+		// The lease of this replica was just written.
+		members = 1
+	}
+	share := (c.Shards + members - 1) / members
+
+	owned := make(map[int]bool)
+	// Renew the shards that the replica owns, up to its share, and release
+	// the rest so that the replicas that joined can acquire them.
+	for shard := 0; shard < c.Shards; shard++ {
+		lease, ok := shardLeases[c.shardLeaseName(shard)]
+		if !ok || holder(lease) != c.Identity {
+			continue
+		}
+		if len(owned) >= share {
+			c.disown(shard)
+			if c.busy(shard) {
+				// The lease is renewed until the reconciles of the shard
+				// that are running return, so that another replica doesn't
+				// reconcile the shard at the same time.
+				if err := c.renewShard(ctx, lease, now); err != nil {
+					c.Log.Error(err, "unable to renew shard", "shard", shard)
+				}
+				continue
+			}
+			if err := c.releaseShard(ctx, lease); err != nil {
+				c.Log.Error(err, "unable to release shard", "shard", shard)
+			} else {
+				c.Log.Info("released shard", "shard", shard)
+			}
+			continue
+		}
+		if err := c.renewShard(ctx, lease, now); err != nil {
+			c.disown(shard)
+			c.Log.Error(err, "unable to renew shard", "shard", shard)
+			continue
+		}
+		owned[shard] = true
+	}
+
+	// Acquire the shards that are free or whose owner stopped renewing them,
+	// up to the share of the replica.
+	acquired := make(map[int]bool)
+	for shard := 0; shard < c.Shards && len(owned) < share; shard++ {
+		if owned[shard] {
+			continue
+		}
+		lease, ok := shardLeases[c.shardLeaseName(shard)]
+		if ok && holder(lease) == c.Identity {
+			// The shard is being released.
+			continue
+		}
+		if ok && holder(lease) != "" && !c.expired(lease, now) {
+			continue
+		}
+		if err := c.acquireShard(ctx, shard, lease, ok, now); err != nil {
+			// Another replica acquired it first.
+			if !k8serrors.IsConflict(err) && !k8serrors.IsAlreadyExists(err) {
+				c.Log.Error(err, "unable to acquire shard", "shard", shard)
+			}
+			continue
+		}
+		c.Log.Info("acquired shard", "shard", shard)
+		owned[shard] = true
+		acquired[shard] = true
+	}
+
+	c.mu.Lock()
+	deadline := now.Add(c.leaseDuration() * 2 / 3)
+	c.owned = make(map[int]time.Time, len(owned))
+	for shard := range owned {
+		c.owned[shard] = deadline
+	}
+	onAcquire := c.onAcquire
+	c.mu.Unlock()
+
+	if len(acquired) > 0 {
+		for _, f := range onAcquire {
+			go f(ctx, acquired)
+		}
+	}
+	return nil
+}
+
+// release releases the shards that the replica owns and deletes its
+// membership lease. A shard whose reconciles are still running when ctx is
+// done isn't released, and is taken over by another replica once its lease
+// expires.
+func (c *Coordinator) release(ctx context.Context) {
+	c.mu.Lock()
+	c.owned = nil
+	c.mu.Unlock()
+
+	leases, err := c.Clientset.CoordinationV1().Leases(c.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{LabelGroup: c.Name, LabelRole: roleShard}).String(),
+	})
+	if err != nil {
+		c.Log.Error(err, "unable to release the shards")
+	} else {
+		for _, lease := range leases.Items {
+			if holder(lease) != c.Identity {
+				continue
+			}
+			shard, err := strconv.Atoi(strings.TrimPrefix(lease.Name, c.Name+"-shard-"))
+			if err == nil {
+				if err := c.waitIdle(ctx, shard); err != nil {
+					c.Log.Error(err, "reconciles of shard are still running, not releasing it", "shard", shard)
+					continue
+				}
+			}
+			if err := c.releaseShard(ctx, lease); err != nil {
+				c.Log.Error(err, "unable to release shard", "lease", lease.Name)
+			}
+		}
+	}
+	err = c.Clientset.CoordinationV1().Leases(c.Namespace).Delete(ctx, c.memberLeaseName(), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		c.Log.Error(err, "unable to delete the membership lease")
+	}
+}
+
+func (c *Coordinator) renewMember(ctx context.Context, now time.Time) error {
+	leases := c.Clientset.CoordinationV1().Leases(c.Namespace)
+	lease, err := leases.Get(ctx, c.memberLeaseName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, c.newLease(c.memberLeaseName(), roleMember, now), metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	lease.Spec = c.newLease(lease.Name, roleMember, now).Spec
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// deleteMember deletes the membership lease of a replica that stopped
+// renewing it, unless it was renewed since it was listed.
+func (c *Coordinator) deleteMember(ctx context.Context, lease coordinationv1.Lease) {
+	err := c.Clientset.CoordinationV1().Leases(c.Namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsConflict(err) {
+		c.Log.Error(err, "unable to delete expired membership lease", "lease", lease.Name)
+		return
+	}
+	delete(c.observed, lease.Name)
+	if err == nil {
+		c.Log.Info("deleted expired membership lease", "lease", lease.Name)
+	}
+}
+
+func (c *Coordinator) renewShard(ctx context.Context, lease coordinationv1.Lease, now time.Time) error {
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: now}
+	_, err := c.Clientset.CoordinationV1().Leases(c.Namespace).Update(ctx, &lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *Coordinator) releaseShard(ctx context.Context, lease coordinationv1.Lease) error {
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	_, err := c.Clientset.CoordinationV1().Leases(c.Namespace).Update(ctx, &lease, metav1.UpdateOptions{})
+	return err
+}
+
+// acquireShard creates the lease of shard, or updates lease if exists is
+// true. The update fails with a conflict if another replica updated the
+// lease since it was listed.
+func (c *Coordinator) acquireShard(ctx context.Context, shard int, lease coordinationv1.Lease, exists bool, now time.Time) error {
+	leases := c.Clientset.CoordinationV1().Leases(c.Namespace)
+	acquired := c.newLease(c.shardLeaseName(shard), roleShard, now)
+	if !exists {
+		_, err := leases.Create(ctx, acquired, metav1.CreateOptions{})
+		return err
+	}
+	transitions := int32(1)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions += *lease.Spec.LeaseTransitions
+	}
+	lease.Spec = acquired.Spec
+	lease.Spec.LeaseTransitions = &transitions
+	_, err := leases.Update(ctx, &lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *Coordinator) newLease(name, role string, now time.Time) *coordinationv1.Lease {
+	identity := c.Identity
+	duration := int32(c.leaseDuration().Seconds())
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.Namespace,
+			Labels: map[string]string{
+				LabelGroup: c.Name,
+				LabelRole:  role,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &metav1.MicroTime{Time: now},
+			RenewTime:            &metav1.MicroTime{Time: now},
+		},
+	}
+}
+
+func (c *Coordinator) disown(shard int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.owned, shard)
+}
+
+func (c *Coordinator) shardLeaseName(shard int) string {
+	return c.Name + "-shard-" + strconv.Itoa(shard)
+}
+
+func (c *Coordinator) memberLeaseName() string {
+	return c.Name + "-member-" + c.Identity
+}
+
+func (c *Coordinator) leaseDuration() time.Duration {
+	if c.LeaseDuration > 0 {
+		return c.LeaseDuration
+	}
+	return DefaultLeaseDuration
+}
+
+func (c *Coordinator) renewInterval() time.Duration {
+	if c.RenewInterval > 0 {
+		return c.RenewInterval
+	}
+	return DefaultRenewInterval
+}
+
+func (c *Coordinator) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func holder(lease coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// observedLease is a Lease of another replica as the replica last saw it
+// change.
+type observedLease struct {
+	resourceVersion string
+	renewTime       time.Time
+	// observedAt is when the replica saw the change, on its own clock.
+	observedAt time.Time
+}
+
+// observe records the leases whose resource version or renew time changed
+// since they were last listed, and forgets the leases that were deleted.
+func (c *Coordinator) observe(leases []coordinationv1.Lease, now time.Time) {
+	observed := make(map[string]observedLease, len(leases))
+	for _, lease := range leases {
+		var renewTime time.Time
+		if lease.Spec.RenewTime != nil {
+			renewTime = lease.Spec.RenewTime.Time
+		}
+		o, ok := c.observed[lease.Name]
+		if !ok || o.resourceVersion != lease.ResourceVersion || !o.renewTime.Equal(renewTime) {
+			o = observedLease{resourceVersion: lease.ResourceVersion, renewTime: renewTime, observedAt: now}
+		}
+		observed[lease.Name] = o
+	}
+	c.observed = observed
+}
+
+// expired returns true if the holder of lease stopped renewing it, i.e. if
+// the lease hasn't changed for its duration since the replica saw it change.
+// The renew time that the holder wrote isn't compared with the clock of the
+// replica since the clocks of the nodes can drift apart.
+func (c *Coordinator) expired(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	o, ok := c.observed[lease.Name]
+	if !ok {
+		return false
+	}
+	return o.observedAt.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
@@ -0,0 +1,204 @@
+package sharding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Test that the shards are distributed among the replicas as they join and
+// leave, and that the shards of a replica that stopped renewing them are
+// taken over.
+func TestCoordinator_rebalance(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	replica1 := testCoordinator(t, clientset, "replica-1", clock)
+	replica2 := testCoordinator(t, clientset, "replica-2", clock)
+	replica3 := testCoordinator(t, clientset, "replica-3", clock)
+
+	// A single replica owns all the shards.
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1, 2, 3}, replica1.OwnedShards())
+
+	// A replica that joins waits for the shards to be released.
+	require.NoError(t, replica2.sync(ctx))
+	require.Empty(t, replica2.OwnedShards())
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1}, replica1.OwnedShards())
+	require.NoError(t, replica2.sync(ctx))
+	require.Equal(t, []int{2, 3}, replica2.OwnedShards())
+
+	// The replicas stop reconciling the shards that they don't renew.
+	now = now.Add(11 * time.Second)
+	require.Empty(t, replica1.OwnedShards())
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1}, replica1.OwnedShards())
+
+	// The shards of a replica that stopped are taken over once its leases
+	// expire, 15 seconds after replica-3 first saw them, even though
+	// replica-2 last renewed them earlier than that.
+	require.NoError(t, replica3.sync(ctx))
+	require.Empty(t, replica3.OwnedShards())
+	now = now.Add(5 * time.Second)
+	require.NoError(t, replica1.sync(ctx))
+	require.NoError(t, replica3.sync(ctx))
+	require.Empty(t, replica3.OwnedShards())
+	now = now.Add(11 * time.Second)
+	require.NoError(t, replica1.sync(ctx))
+	require.NoError(t, replica3.sync(ctx))
+	require.Equal(t, []int{0, 1}, replica1.OwnedShards())
+	require.Equal(t, []int{2, 3}, replica3.OwnedShards())
+
+	// The membership lease of replica-2 is deleted once it expired.
+	_, err := clientset.CoordinationV1().Leases("default").Get(ctx, "consul-endpoints-member-replica-2", metav1.GetOptions{})
+	require.True(t, k8serrors.IsNotFound(err))
+
+	// The shards of a replica that's shut down are released right away.
+	replica3.release(ctx)
+	require.Empty(t, replica3.OwnedShards())
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1, 2, 3}, replica1.OwnedShards())
+	_, err = clientset.CoordinationV1().Leases("default").Get(ctx, "consul-endpoints-member-replica-3", metav1.GetOptions{})
+	require.Error(t, err)
+}
+
+// Test that a replica whose clock is ahead doesn't take over the shards of a
+// replica that keeps renewing them.
+func TestCoordinator_clockDrift(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	now := time.Now()
+	replica1 := testCoordinator(t, clientset, "replica-1", func() time.Time { return now })
+	replica2 := testCoordinator(t, clientset, "replica-2", func() time.Time { return now.Add(time.Minute) })
+
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1, 2, 3}, replica1.OwnedShards())
+	require.NoError(t, replica2.sync(ctx))
+	require.Empty(t, replica2.OwnedShards())
+	require.NoError(t, replica1.sync(ctx))
+	require.NoError(t, replica2.sync(ctx))
+
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []int{0, 1}, replica1.OwnedShards())
+		require.Equal(t, []int{2, 3}, replica2.OwnedShards())
+		now = now.Add(5 * time.Second)
+		require.NoError(t, replica1.sync(ctx))
+		require.NoError(t, replica2.sync(ctx))
+	}
+}
+
+// Test that a shard isn't released while its reconciles are running.
+func TestCoordinator_drainsBeforeRelease(t *testing.T) {
+	ctx := context.Background()
+	clientset := fake.NewSimpleClientset()
+	now := time.Now()
+	clock := func() time.Time { return now }
+	replica1 := testCoordinator(t, clientset, "replica-1", clock)
+	replica2 := testCoordinator(t, clientset, "replica-2", clock)
+	replica1.Shards = 2
+	replica2.Shards = 2
+	namespace := ""
+	for _, ns := range []string{"ns-1", "ns-2", "ns-3", "ns-4"} {
+		if replica1.Shard(ns) == 1 {
+			namespace = ns
+		}
+	}
+	require.NotEmpty(t, namespace)
+
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0, 1}, replica1.OwnedShards())
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan struct{})
+	reconciler := replica1.Reconciler(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		close(started)
+		<-finish
+		return reconcile.Result{}, nil
+	}))
+	go func() {
+		defer close(done)
+		req := reconcile.Request{}
+		req.Namespace = namespace
+		_, _ = reconciler.Reconcile(ctx, req)
+	}()
+	<-started
+
+	// replica-1 stops reconciling shard 1 but keeps its lease.
+	require.NoError(t, replica2.sync(ctx))
+	require.NoError(t, replica1.sync(ctx))
+	require.Equal(t, []int{0}, replica1.OwnedShards())
+	require.NoError(t, replica2.sync(ctx))
+	require.Empty(t, replica2.OwnedShards())
+
+	close(finish)
+	<-done
+	require.NoError(t, replica1.sync(ctx))
+	require.NoError(t, replica2.sync(ctx))
+	require.Equal(t, []int{0}, replica1.OwnedShards())
+	require.Equal(t, []int{1}, replica2.OwnedShards())
+}
+
+func TestCoordinator_Reconciler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := testCoordinator(t, fake.NewSimpleClientset(), "replica-1", func() time.Time { return now })
+	c.Shards = 2
+
+	var reconciled []string
+	reconciler := c.Reconciler(reconcile.Func(func(_ context.Context, req reconcile.Request) (reconcile.Result, error) {
+		reconciled = append(reconciled, req.Namespace)
+		return reconcile.Result{}, nil
+	}))
+	var owned, notOwned string
+	for _, ns := range []string{"ns-1", "ns-2", "ns-3", "ns-4"} {
+		if c.Shard(ns) == 0 {
+			owned = ns
+		} else {
+			notOwned = ns
+		}
+	}
+	require.NotEmpty(t, owned)
+	require.NotEmpty(t, notOwned)
+
+	// Nothing is reconciled before the shards are acquired.
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{})
+	require.NoError(t, err)
+	require.Empty(t, reconciled)
+
+	c.owned = map[int]time.Time{0: now.Add(time.Second)}
+	for _, ns := range []string{owned, notOwned} {
+		req := reconcile.Request{}
+		req.Namespace = ns
+		_, err := reconciler.Reconcile(ctx, req)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{owned}, reconciled)
+
+	// A nil Coordinator reconciles everything.
+	var nilCoordinator *Coordinator
+	require.True(t, nilCoordinator.Owns(notOwned))
+}
+
+func testCoordinator(t *testing.T, clientset kubernetes.Interface, identity string, clock func() time.Time) *Coordinator {
+	return &Coordinator{
+		Clientset: clientset,
+		Namespace: "default",
+		Name:      "consul-endpoints",
+		Identity:  identity,
+		Shards:    4,
+		Log:       logrtest.TestLogger{T: t},
+		now:       clock,
+	}
+}
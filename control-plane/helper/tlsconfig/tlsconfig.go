@@ -0,0 +1,78 @@
+// Package tlsconfig parses the TLS versions and cipher suites that are shared
+// by Consul, Envoy and the webhook servers, and applies them to Go servers.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// versions are the TLS versions in the format that Consul and Envoy use.
+var versions = map[string]uint16{
+	"TLSv1_0": tls.VersionTLS10,
+	"TLSv1_1": tls.VersionTLS11,
+	"TLSv1_2": tls.VersionTLS12,
+	"TLSv1_3": tls.VersionTLS13,
+}
+
+// Config is the minimum TLS version and the TLS cipher suites of a server.
+// The zero value leaves both to Go's defaults.
+type Config struct {
+	MinVersion   uint16
+	CipherSuites []uint16
+}
+
+// Parse parses a TLS version such as "TLSv1_2" and a comma-separated list
+// of IANA cipher suite names. Both can be empty. Cipher suites can't be
+// configured for TLS 1.3.
+func Parse(minVersion, cipherSuites string) (Config, error) {
+	var cfg Config
+	if minVersion != "" {
+		v, ok := versions[minVersion]
+		if !ok {
+			return Config{}, fmt.Errorf("invalid TLS version %q: must be one of %s", minVersion, strings.Join(versionNames(), ", "))
+		}
+		cfg.MinVersion = v
+	}
+
+	if cipherSuites == "" {
+		return cfg, nil
+	}
+	if cfg.MinVersion == tls.VersionTLS13 {
+		return Config{}, fmt.Errorf("cipher suites can't be configured for TLS 1.3")
+	}
+	ids := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids[suite.Name] = suite.ID
+	}
+	for _, name := range strings.Split(cipherSuites, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := ids[name]
+		if !ok {
+			return Config{}, fmt.Errorf("unsupported TLS cipher suite %q", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
+
+// Apply sets the minimum version and cipher suites of c on tlsConfig.
+func (c Config) Apply(tlsConfig *tls.Config) {
+	if c.MinVersion != 0 {
+		tlsConfig.MinVersion = c.MinVersion
+	}
+	if len(c.CipherSuites) > 0 {
+		tlsConfig.CipherSuites = c.CipherSuites
+	}
+}
+
+func versionNames() []string {
+	var names []string
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
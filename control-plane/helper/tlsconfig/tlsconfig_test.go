@@ -0,0 +1,70 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		minVersion   string
+		cipherSuites string
+		exp          Config
+		expErr       string
+	}{
+		"empty": {},
+		"min version": {
+			minVersion: "TLSv1_2",
+			exp:        Config{MinVersion: tls.VersionTLS12},
+		},
+		"min version and cipher suites": {
+			minVersion:   "TLSv1_2",
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			exp: Config{
+				MinVersion:   tls.VersionTLS12,
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+			},
+		},
+		"insecure cipher suite": {
+			cipherSuites: "TLS_RSA_WITH_RC4_128_SHA",
+			exp:          Config{CipherSuites: []uint16{tls.TLS_RSA_WITH_RC4_128_SHA}},
+		},
+		"invalid min version": {
+			minVersion: "1.2",
+			expErr:     `invalid TLS version "1.2": must be one of TLSv1_0, TLSv1_1, TLSv1_2, TLSv1_3`,
+		},
+		"cipher suites with TLS 1.3": {
+			minVersion:   "TLSv1_3",
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			expErr:       "cipher suites can't be configured for TLS 1.3",
+		},
+		"unsupported cipher suite": {
+			cipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,foo",
+			expErr:       `unsupported TLS cipher suite "foo"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Parse(c.minVersion, c.cipherSuites)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, cfg)
+		})
+	}
+}
+
+func TestConfig_Apply(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS10}
+	Config{}.Apply(tlsConfig)
+	require.Equal(t, uint16(tls.VersionTLS10), tlsConfig.MinVersion)
+	require.Nil(t, tlsConfig.CipherSuites)
+
+	Config{MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}}.Apply(tlsConfig)
+	require.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites)
+}
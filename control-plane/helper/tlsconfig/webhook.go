@@ -0,0 +1,82 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var log = logf.Log.WithName("webhook-server")
+
+// WebhookServer is a controller-runtime webhook server that is served with
+// the TLS minimum version and cipher suites of Config, which the webhook
+// server of controller-runtime doesn't support. Webhooks are registered on the
+// embedded server, and the WebhookServer is added to the manager in its place.
+type WebhookServer struct {
+	*webhook.Server
+	Config Config
+}
+
+// Start implements manager.Runnable. It serves the webhooks of the embedded
+// server until ctx is cancelled, and reloads the certificate and key in its
+// CertDir when they change.
+func (s *WebhookServer) Start(ctx context.Context) error {
+	certName, keyName := s.CertName, s.KeyName
+	if certName == "" {
+		certName = "tls.crt"
+	}
+	if keyName == "" {
+		keyName = "tls.key"
+	}
+	certWatcher, err := certwatcher.New(filepath.Join(s.CertDir, certName), filepath.Join(s.CertDir, keyName))
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			log.Error(err, "certificate watcher error")
+		}
+	}()
+
+	cfg := &tls.Config{
+		NextProtos:     []string{"h2"},
+		GetCertificate: certWatcher.GetCertificate,
+	}
+	s.Config.Apply(cfg)
+
+	port := s.Port
+	if port <= 0 {
+		port = webhook.DefaultPort
+	}
+	listener, err := tls.Listen("tcp", net.JoinHostPort(s.Host, strconv.Itoa(port)), cfg)
+	if err != nil {
+		return err
+	}
+
+	// Registering a path sets up the mux of the embedded server.
+	if s.WebhookMux == nil {
+		s.WebhookMux = http.NewServeMux()
+	}
+	srv := &http.Server{Handler: s.WebhookMux}
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Error(err, "error shutting down the webhook server")
+		}
+		close(idleConnsClosed)
+	}()
+
+	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	<-idleConnsClosed
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package tracing traces the reconciles of the controllers of consul-k8s
+// with OpenTelemetry and exports the traces to an OTLP receiver.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// instrumentationName is the name of the tracer of the spans.
+	instrumentationName = "github.com/hashicorp/consul-k8s/control-plane"
+
+	// ShutdownTimeout is how long the commands wait for the remaining spans
+	// to be exported when they exit.
+	ShutdownTimeout = 5 * time.Second
+)
+
+// Setup configures the global tracer provider to export the spans of service
+// to the OTLP gRPC receiver at address, e.g. "otel-collector:4317". The
+// returned function flushes the spans that haven't been exported yet and
+// shuts down the exporter.
+func Setup(ctx context.Context, service, address string) (func(context.Context) error, error) {
+	exporter, err := otlp.NewExporter(ctx, otlpgrpc.NewDriver(
+		otlpgrpc.WithInsecure(),
+		otlpgrpc.WithEndpoint(address),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating the OTLP exporter for %q: %s", address, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(service))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span that is a child of the span in ctx, if any, and
+// returns it with a context that carries it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, and records err as its status if it isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport returns a RoundTripper that traces the requests made with base
+// as spans that are children of the span in the context of the request.
+// Requests without a span in their context aren't traced, so that the
+// Consul API calls made outside of reconciles don't create a trace each.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx,
+		fmt.Sprintf("Consul %s %s", req.Method, req.URL.Path),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPTargetKey.String(req.URL.Path),
+			semconv.NetPeerNameKey.String(req.URL.Host),
+		))
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+	span.End()
+	return resp, nil
+}
@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// recordSpans sets the global tracer provider to one that records the ended
+// spans for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func attributes(span *sdktrace.SpanSnapshot) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// Test that the requests made within a span are traced as its children.
+func TestTransport(t *testing.T) {
+	cases := map[string]struct {
+		statusCode int
+		expStatus  codes.Code
+	}{
+		"success": {
+			statusCode: http.StatusOK,
+			expStatus:  codes.Unset,
+		},
+		"error": {
+			statusCode: http.StatusInternalServerError,
+			expStatus:  codes.Error,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			exporter := recordSpans(t)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(c.statusCode)
+			}))
+			defer server.Close()
+			client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+			ctx, parent := Start(context.Background(), "reconcile")
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL+"/v1/agent/service/register", nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			End(parent, nil)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 2)
+			child := spans[0]
+			require.Equal(t, "Consul PUT /v1/agent/service/register", child.Name)
+			require.Equal(t, parent.SpanContext().SpanID(), child.Parent.SpanID())
+			require.Equal(t, c.expStatus, child.StatusCode)
+			attrs := attributes(child)
+			require.Equal(t, http.MethodPut, attrs[semconv.HTTPMethodKey].AsString())
+			require.Equal(t, "/v1/agent/service/register", attrs[semconv.HTTPTargetKey].AsString())
+			require.Equal(t, int64(c.statusCode), attrs[semconv.HTTPStatusCodeKey].AsInt64())
+		})
+	}
+}
+
+// Test that the requests made outside of a span aren't traced.
+func TestTransport_NoSpan(t *testing.T) {
+	exporter := recordSpans(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL + "/v1/agent/self")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Empty(t, exporter.GetSpans())
+}
+
+// Test that the errors of the requests are recorded on their spans.
+func TestTransport_RequestError(t *testing.T) {
+	exporter := recordSpans(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	server.Close()
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	ctx, parent := Start(context.Background(), "reconcile")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/agent/self", nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	require.Error(t, err)
+	End(parent, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		require.Equal(t, codes.Error, span.StatusCode)
+		require.Len(t, span.MessageEvents, 1)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	exporter := recordSpans(t)
+	_, span := Start(context.Background(), "reconcile", attribute.String("k8s.name", "web"))
+	End(span, errors.New("failure"))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, codes.Error, spans[0].StatusCode)
+	require.Equal(t, "failure", spans[0].StatusMessage)
+	require.Equal(t, "web", attributes(spans[0])["k8s.name"].AsString())
+}
@@ -0,0 +1,186 @@
+// Package webhookaudit records an audit trail of the admission decisions
+// of the webhooks of consul-k8s.
+package webhookaudit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// Stdout is the sink path that writes the audit records to stdout.
+	Stdout = "stdout"
+
+	// DecisionAllowed is the decision of a request that was allowed
+	// without changes to the resource.
+	DecisionAllowed = "allowed"
+	// DecisionMutated is the decision of a request that was allowed with
+	// patches to the resource.
+	DecisionMutated = "mutated"
+	// DecisionDenied is the decision of a request that was rejected.
+	DecisionDenied = "denied"
+)
+
+// Record is the audit record of an admission decision.
+type Record struct {
+	Timestamp time.Time `json:"@timestamp"`
+	// Webhook is the name of the webhook that made the decision,
+	// e.g. connect-injector or servicedefaults.
+	Webhook string `json:"webhook"`
+	UID     string `json:"uid"`
+	// User and Groups are the user that made the request.
+	User      string   `json:"user"`
+	Groups    []string `json:"groups,omitempty"`
+	Operation string   `json:"operation"`
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	// Resource is the name of the resource, or its generateName if the
+	// name is generated by the Kubernetes API server.
+	Resource string `json:"resource,omitempty"`
+	DryRun   bool   `json:"dryRun,omitempty"`
+	// Decision is one of DecisionAllowed, DecisionMutated or DecisionDenied.
+	Decision string `json:"decision"`
+	Code     int32  `json:"code,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Patches summarizes the mutation of the resource with the operation
+	// and the path of each JSON patch, without the values so that secrets
+	// aren't recorded.
+	Patches []string `json:"patches,omitempty"`
+}
+
+// Handler is an admission.Handler that writes an audit Record of every
+// decision of the handler it wraps to Sink.
+type Handler struct {
+	// Handler is the handler whose decisions are recorded.
+	Handler admission.Handler
+	// Webhook is the name of the webhook in the records.
+	Webhook string
+	// Sink is where the records are written to, one JSON record per line.
+	Sink io.Writer
+	// Log is used to log the errors writing the records.
+	Log logr.Logger
+
+	// clock is the time of the records, and is overridden in tests.
+	clock func() time.Time
+}
+
+// Handle handles req with the wrapped handler and records its decision.
+func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	resp := h.Handler.Handle(ctx, req)
+	if err := h.record(req, resp); err != nil {
+		h.Log.Error(err, "failed to write the audit record", "webhook", h.Webhook, "uid", req.UID)
+	}
+	return resp
+}
+
+// InjectDecoder injects the decoder of the webhook into the wrapped handler.
+func (h *Handler) InjectDecoder(d *admission.Decoder) error {
+	_, err := admission.InjectDecoderInto(d, h.Handler)
+	return err
+}
+
+// InjectFunc injects the fields of the webhook into the wrapped handler.
+func (h *Handler) InjectFunc(f inject.Func) error {
+	return f(h.Handler)
+}
+
+func (h *Handler) record(req admission.Request, resp admission.Response) error {
+	now := time.Now
+	if h.clock != nil {
+		now = h.clock
+	}
+	r := Record{
+		Timestamp: now().UTC(),
+		Webhook:   h.Webhook,
+		UID:       string(req.UID),
+		User:      req.UserInfo.Username,
+		Groups:    req.UserInfo.Groups,
+		Operation: string(req.Operation),
+		Kind:      req.Kind.Kind,
+		Namespace: req.Namespace,
+		Resource:  resourceName(req),
+		DryRun:    req.DryRun != nil && *req.DryRun,
+		Decision:  DecisionAllowed,
+	}
+	if !resp.Allowed {
+		r.Decision = DecisionDenied
+	} else if len(resp.Patches) > 0 {
+		r.Decision = DecisionMutated
+	}
+	if resp.Result != nil {
+		r.Code = resp.Result.Code
+		// Allowed and Denied responses set the reason, and Errored
+		// responses the message.
+		r.Reason = string(resp.Result.Reason)
+		if r.Reason == "" {
+			r.Reason = resp.Result.Message
+		}
+	}
+	for _, p := range resp.Patches {
+		r.Patches = append(r.Patches, fmt.Sprintf("%s %s", p.Operation, p.Path))
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return writeLine(h.Sink, line)
+}
+
+// resourceName returns the name of the resource of req, which for creates
+// may only be set in the metadata of the object, or its generateName.
+func resourceName(req admission.Request) string {
+	if req.Name != "" {
+		return req.Name
+	}
+	raw := req.Object.Raw
+	if len(raw) == 0 {
+		raw = req.OldObject.Raw
+	}
+	var obj struct {
+		Metadata struct {
+			Name         string `json:"name"`
+			GenerateName string `json:"generateName"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return ""
+	}
+	if obj.Metadata.Name != "" {
+		return obj.Metadata.Name
+	}
+	return obj.Metadata.GenerateName
+}
+
+// sinkMu serializes the writes to the sinks so that the records of
+// concurrent requests aren't interleaved.
+var sinkMu sync.Mutex
+
+func writeLine(w io.Writer, line []byte) error {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	_, err := w.Write(append(line, '\n'))
+	return err
+}
+
+// OpenSink returns the sink of the given path: stdout if it is Stdout and
+// otherwise the file at path, which the records are appended to.
+func OpenSink(path string) (io.Writer, error) {
+	if path == Stdout {
+		return os.Stdout, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log %q: %s", path, err)
+	}
+	return f, nil
+}
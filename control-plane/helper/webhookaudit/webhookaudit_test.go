@@ -0,0 +1,222 @@
+package webhookaudit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestHandler_Handle(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]struct {
+		Request  admission.Request
+		Response admission.Response
+		Expected Record
+	}{
+		"allowed": {
+			Request:  request("UPDATE", "web", nil),
+			Response: admission.Allowed("valid"),
+			Expected: Record{
+				Operation: "UPDATE",
+				Resource:  "web",
+				Decision:  DecisionAllowed,
+				Code:      http.StatusOK,
+				Reason:    "valid",
+			},
+		},
+		"mutated": {
+			Request: request("CREATE", "web", nil),
+			Response: admission.Patched("",
+				jsonpatch.NewOperation("add", "/metadata/annotations", map[string]string{"secret": "value"}),
+				jsonpatch.NewOperation("replace", "/spec/containers/0/image", "image"),
+			),
+			Expected: Record{
+				Operation: "CREATE",
+				Resource:  "web",
+				Decision:  DecisionMutated,
+				Code:      http.StatusOK,
+				Patches:   []string{"add /metadata/annotations", "replace /spec/containers/0/image"},
+			},
+		},
+		"denied": {
+			Request:  request("CREATE", "web", nil),
+			Response: admission.Denied("servicedefaults resource already defined"),
+			Expected: Record{
+				Operation: "CREATE",
+				Resource:  "web",
+				Decision:  DecisionDenied,
+				Code:      http.StatusForbidden,
+				Reason:    "servicedefaults resource already defined",
+			},
+		},
+		"errored": {
+			Request:  request("CREATE", "web", nil),
+			Response: admission.Errored(http.StatusBadRequest, errors.New("could not unmarshal request")),
+			Expected: Record{
+				Operation: "CREATE",
+				Resource:  "web",
+				Decision:  DecisionDenied,
+				Code:      http.StatusBadRequest,
+				Reason:    "could not unmarshal request",
+			},
+		},
+		"name from the object": {
+			Request:  request("CREATE", "", []byte(`{"metadata":{"name":"web-1"}}`)),
+			Response: admission.Allowed(""),
+			Expected: Record{
+				Operation: "CREATE",
+				Resource:  "web-1",
+				Decision:  DecisionAllowed,
+				Code:      http.StatusOK,
+			},
+		},
+		"generateName from the object": {
+			Request:  request("CREATE", "", []byte(`{"metadata":{"generateName":"web-"}}`)),
+			Response: admission.Allowed(""),
+			Expected: Record{
+				Operation: "CREATE",
+				Resource:  "web-",
+				Decision:  DecisionAllowed,
+				Code:      http.StatusOK,
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var sink bytes.Buffer
+			h := &Handler{
+				Handler: admission.HandlerFunc(func(context.Context, admission.Request) admission.Response {
+					return c.Response
+				}),
+				Webhook: "connect-injector",
+				Sink:    &sink,
+				Log:     logrtest.TestLogger{T: t},
+				clock:   func() time.Time { return now },
+			}
+			resp := h.Handle(context.Background(), c.Request)
+			require.Equal(t, c.Response, resp)
+
+			var actual Record
+			require.NoError(t, json.Unmarshal(sink.Bytes(), &actual))
+			expected := c.Expected
+			expected.Timestamp = now
+			expected.Webhook = "connect-injector"
+			expected.UID = "uid"
+			expected.User = "system:serviceaccount:default:deployer"
+			expected.Groups = []string{"system:serviceaccounts"}
+			expected.Kind = "Pod"
+			expected.Namespace = "default"
+			require.Equal(t, expected, actual)
+		})
+	}
+}
+
+// Test that every record is written on its own line.
+func TestHandler_HandleLines(t *testing.T) {
+	var sink bytes.Buffer
+	h := &Handler{
+		Handler: admission.HandlerFunc(func(context.Context, admission.Request) admission.Response {
+			return admission.Allowed("")
+		}),
+		Webhook: "servicedefaults",
+		Sink:    &sink,
+		Log:     logrtest.TestLogger{T: t},
+	}
+	h.Handle(context.Background(), request("CREATE", "web", nil))
+	h.Handle(context.Background(), request("DELETE", "web", nil))
+
+	lines := bytes.Split(bytes.TrimSuffix(sink.Bytes(), []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var r Record
+		require.NoError(t, json.Unmarshal(line, &r))
+		require.Equal(t, "servicedefaults", r.Webhook)
+	}
+}
+
+func TestHandler_InjectDecoder(t *testing.T) {
+	inner := &decoderHandler{}
+	h := &Handler{Handler: inner}
+	decoder, err := admission.NewDecoder(runtime.NewScheme())
+	require.NoError(t, err)
+	require.NoError(t, h.InjectDecoder(decoder))
+	require.Equal(t, decoder, inner.decoder)
+}
+
+func TestOpenSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := OpenSink(path)
+	require.NoError(t, err)
+	h := &Handler{
+		Handler: admission.HandlerFunc(func(context.Context, admission.Request) admission.Response {
+			return admission.Allowed("")
+		}),
+		Webhook: "servicedefaults",
+		Sink:    sink,
+		Log:     logrtest.TestLogger{T: t},
+	}
+	h.Handle(context.Background(), request("CREATE", "web", nil))
+
+	// The records are appended to the existing file.
+	sink, err = OpenSink(path)
+	require.NoError(t, err)
+	h.Sink = sink
+	h.Handle(context.Background(), request("DELETE", "web", nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, bytes.Count(data, []byte("\n")))
+}
+
+func TestOpenSink_Stdout(t *testing.T) {
+	sink, err := OpenSink(Stdout)
+	require.NoError(t, err)
+	require.Equal(t, os.Stdout, sink)
+}
+
+func request(operation, name string, object []byte) admission.Request {
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Name:      name,
+			Namespace: "default",
+			Operation: admissionv1.Operation(operation),
+			UserInfo: authenticationv1.UserInfo{
+				Username: "system:serviceaccount:default:deployer",
+				Groups:   []string{"system:serviceaccounts"},
+			},
+			Object: runtime.RawExtension{Raw: object},
+		},
+	}
+}
+
+// decoderHandler is a handler that records the decoder it's injected with.
+type decoderHandler struct {
+	decoder *admission.Decoder
+}
+
+func (h *decoderHandler) Handle(context.Context, admission.Request) admission.Response {
+	return admission.Allowed("")
+}
+
+func (h *decoderHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
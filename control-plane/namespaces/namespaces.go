@@ -17,11 +17,20 @@ const (
 // it will create it and set crossNSACLPolicy as a policy default.
 // Boolean return value indicates if the namespace was created by this call.
 func EnsureExists(client *capi.Client, ns string, crossNSAClPolicy string) (bool, error) {
+	return EnsureExistsInPartition(client, ns, "", crossNSAClPolicy)
+}
+
+// EnsureExistsInPartition ensures a Consul namespace with name ns exists in the
+// admin partition with name partition. If partition is empty, the partition of
+// the client is used. It otherwise behaves like EnsureExists.
+func EnsureExistsInPartition(client *capi.Client, ns string, partition string, crossNSAClPolicy string) (bool, error) {
 	if ns == WildcardNamespace || ns == DefaultNamespace {
 		return false, nil
 	}
+	opts := &capi.QueryOptions{Partition: partition}
+
 	// Check if the Consul namespace exists.
-	namespaceInfo, _, err := client.Namespaces().Read(ns, nil)
+	namespaceInfo, _, err := client.Namespaces().Read(ns, opts)
 	if err != nil {
 		return false, err
 	}
@@ -46,9 +55,10 @@ func EnsureExists(client *capi.Client, ns string, crossNSAClPolicy string) (bool
 		Description: "Auto-generated by consul-k8s",
 		ACLs:        &aclConfig,
 		Meta:        map[string]string{"external-source": "kubernetes"},
+		Partition:   partition,
 	}
 
-	_, _, err = client.Namespaces().Create(&consulNamespace, nil)
+	_, _, err = client.Namespaces().Create(&consulNamespace, &capi.WriteOptions{Partition: partition})
 	return true, err
 }
 
@@ -3,6 +3,7 @@
 package namespaces
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -156,6 +157,44 @@ func TestEnsureExists_CreatesNS(tt *testing.T) {
 	}
 }
 
+// Test that the namespace is created in the given partition.
+func TestEnsureExistsInPartition_CreatesNS(t *testing.T) {
+	req := require.New(t)
+	ns := "ns"
+	partition := "team"
+
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	req.NoError(err)
+	defer consul.Stop()
+	consul.WaitForLeader(t)
+
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: consul.HTTPAddr,
+	})
+	req.NoError(err)
+
+	_, _, err = consulClient.Partitions().Create(context.Background(), &capi.Partition{Name: partition}, nil)
+	req.NoError(err)
+
+	created, err := EnsureExistsInPartition(consulClient, ns, partition, "")
+	req.NoError(err)
+	req.True(created)
+
+	// Ensure it was created in the partition and not the default partition.
+	cNS, _, err := consulClient.Namespaces().Read(ns, &capi.QueryOptions{Partition: partition})
+	req.NoError(err)
+	req.NotNil(cNS)
+	req.Equal(partition, cNS.Partition)
+	cNS, _, err = consulClient.Namespaces().Read(ns, nil)
+	req.NoError(err)
+	req.Nil(cNS)
+
+	// Ensure it isn't created again.
+	created, err = EnsureExistsInPartition(consulClient, ns, partition, "")
+	req.NoError(err)
+	req.False(created)
+}
+
 func TestConsulNamespace(t *testing.T) {
 	cases := map[string]struct {
 		kubeNS                 string
@@ -34,9 +34,10 @@ const (
 type Command struct {
 	UI cli.Ui
 
-	flags *flag.FlagSet
-	k8s   *flags.K8SFlags
-	http  *flags.HTTPFlags
+	flags  *flag.FlagSet
+	k8s    *flags.K8SFlags
+	http   *flags.HTTPFlags
+	awsIAM *flags.AWSIAMLoginFlags
 
 	flagSecretName        string
 	flagInitType          string
@@ -103,8 +104,10 @@ func (c *Command) init() {
 
 	c.k8s = &flags.K8SFlags{}
 	c.http = &flags.HTTPFlags{}
+	c.awsIAM = &flags.AWSIAMLoginFlags{}
 	flags.Merge(c.flags, c.k8s.Flags())
 	flags.Merge(c.flags, c.http.Flags())
+	flags.Merge(c.flags, c.awsIAM.Flags())
 	c.help = flags.Usage(help, c.flags)
 }
 
@@ -154,7 +157,7 @@ func (c *Command) Run(args []string) int {
 
 	// Set up logging.
 	if c.logger == nil {
-		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		c.logger, err = common.Logger("acl-init", c.flagLogLevel, c.flagLogJSON)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 1
@@ -197,6 +200,7 @@ func (c *Command) Run(args []string) int {
 			Meta: map[string]string{
 				"component": c.flagComponentName,
 			},
+			AWSIAM: c.awsIAM.LoginParams(),
 		}
 		secret, err = common.ConsulLogin(c.consulClient, loginParams, c.logger)
 		if err != nil {
@@ -274,6 +278,9 @@ func (c *Command) validateFlags() error {
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+	if c.awsIAM.Login() && c.flagACLAuthMethod == "" {
+		return errors.New("-acl-auth-method must be set if -aws-iam-login is true")
+	}
 
 	return nil
 }
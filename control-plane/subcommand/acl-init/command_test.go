@@ -36,6 +36,10 @@ func TestRun_FlagValidation(t *testing.T) {
 			flags:  []string{},
 			expErr: "-consul-api-timeout must be set to a value greater than 0",
 		},
+		{
+			flags:  []string{"-consul-api-timeout=5s", "-aws-iam-login"},
+			expErr: "-acl-auth-method must be set if -aws-iam-login is true",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expErr, func(t *testing.T) {
@@ -0,0 +1,460 @@
+package aclloginaudit
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// loginTokensAnnotation is set on service accounts to the number of
+	// their login tokens with -annotate-service-accounts.
+	loginTokensAnnotation = "consul.hashicorp.com/acl-login-tokens"
+
+	// lastLoginAnnotation is set on service accounts to the time of their
+	// last login with -annotate-service-accounts.
+	lastLoginAnnotation = "consul.hashicorp.com/acl-last-login"
+
+	// missingRolesAnnotation is set on service accounts to the roles that
+	// binding rules bind them to but that don't exist with
+	// -annotate-service-accounts.
+	missingRolesAnnotation = "consul.hashicorp.com/acl-missing-roles"
+
+	// loginDescriptionPrefix is the prefix of the description Consul gives
+	// tokens created by a login. It's followed by the login metadata as JSON.
+	loginDescriptionPrefix = "token created via login: "
+
+	// unknownServiceAccount is the service account of login tokens whose
+	// service account can't be determined.
+	unknownServiceAccount = "unknown"
+
+	defaultNamespace  = "default"
+	wildcardNamespace = "*"
+)
+
+var (
+	selectorNameRe      = regexp.MustCompile(`serviceaccount\.name\s*==\s*"([^"]*)"`)
+	selectorNamespaceRe = regexp.MustCompile(`serviceaccount\.namespace\s*==\s*"([^"]*)"`)
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	k8s   *flags.K8SFlags
+
+	flagAuthMethods             []string
+	flagK8sNamespace            string
+	flagEnableNamespaces        bool
+	flagAnnotateServiceAccounts bool
+	flagCheckInterval           time.Duration
+	flagListen                  string
+
+	flagLogLevel string
+	flagLogJSON  bool
+
+	k8sClient    kubernetes.Interface
+	consulClient *api.Client
+
+	log     hclog.Logger
+	metrics *metrics
+	sigCh   chan os.Signal
+	once    sync.Once
+	ctx     context.Context
+	help    string
+}
+
+// serviceAccount is a Kubernetes service account that logs in to Consul.
+type serviceAccount struct {
+	namespace string
+	name      string
+}
+
+// audit is the result of auditing the logins of a service account.
+type audit struct {
+	loginTokens  int
+	lastLogin    time.Time
+	missingRoles []string
+}
+
+// init is run once to set up usage documentation for flags.
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false, "Enable or disable JSON output format for logging.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAuthMethods), "auth-method",
+		"Name of a Kubernetes auth method whose logins are audited. May be specified multiple times. "+
+			"[Enterprise Only] If the auth method is in a Consul namespace other than the default, "+
+			"specify the value in the form <AuthMethodName>.<ConsulNamespace>.")
+	c.flags.StringVar(&c.flagK8sNamespace, "k8s-namespace", "",
+		"Name of the Kubernetes namespace of the service accounts of binding rules that don't select a namespace, "+
+			"e.g. the service accounts of Consul components.")
+	c.flags.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
+		"[Enterprise Only] Enables Consul Enterprise namespaces. The login tokens are then listed "+
+			"in all namespaces, since with namespace mirroring they're created in the namespaces of the service accounts.")
+	c.flags.BoolVar(&c.flagAnnotateServiceAccounts, "annotate-service-accounts", false,
+		"Toggle for recording the number of login tokens, the time of the last login and missing roles "+
+			"in annotations of the service accounts.")
+	c.flags.DurationVar(&c.flagCheckInterval, "check-interval", 5*time.Minute,
+		"How often to audit the logins.")
+	c.flags.StringVar(&c.flagListen, "listen", ":8080", "Address to serve metrics on.")
+
+	c.http = &flags.HTTPFlags{}
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.http.Flags())
+	flags.Merge(c.flags, c.k8s.Flags())
+
+	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+}
+
+// Run periodically audits which service accounts have logged in to the
+// Kubernetes auth methods and which binding rules bind service accounts to
+// roles that don't exist.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to validate flags: %v", err))
+		return 1
+	}
+
+	var err error
+	c.log, err = common.Logger("acl-login-audit", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	if c.consulClient == nil {
+		c.consulClient, err = c.http.APIClient()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+			return 1
+		}
+	}
+
+	c.metrics = newMetrics()
+	registry := prometheus.NewRegistry()
+	if err := c.metrics.register(registry); err != nil {
+		c.UI.Error(fmt.Sprintf("Error registering metrics: %s", err))
+		return 1
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, mux); err != nil {
+			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		}
+	}()
+
+	ticker := time.NewTicker(c.flagCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			c.metrics.errors.Inc()
+			c.log.Error("failed to audit ACL logins", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// reconcile audits the logins of every auth method and updates the metrics
+// and, with -annotate-service-accounts, the service accounts.
+func (c *Command) reconcile() error {
+	c.metrics.loginTokens.Reset()
+	c.metrics.lastLogin.Reset()
+	c.metrics.missingRoles.Reset()
+
+	audits := make(map[serviceAccount]*audit)
+	var errs []error
+	for _, authMethod := range c.flagAuthMethods {
+		if err := c.auditAuthMethod(authMethod, audits); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.flagAnnotateServiceAccounts {
+		for sa, a := range audits {
+			if err := c.annotateServiceAccount(sa, a); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d errors: %v", len(errs), errs)
+	}
+	return nil
+}
+
+// auditAuthMethod adds the logins of the service accounts to authMethod and
+// the roles that its binding rules bind them to but that don't exist to
+// audits.
+func (c *Command) auditAuthMethod(authMethod string, audits map[serviceAccount]*audit) error {
+	name, namespace := authMethod, ""
+	if i := strings.LastIndex(authMethod, "."); i != -1 {
+		name, namespace = authMethod[:i], authMethod[i+1:]
+	}
+	opts := &api.QueryOptions{Namespace: namespace}
+
+	roles, _, err := c.consulClient.ACL().RoleList(opts)
+	if err != nil {
+		return fmt.Errorf("listing roles: %s", err)
+	}
+	roleNames := make(map[string]bool)
+	for _, role := range roles {
+		roleNames[role.Name] = true
+	}
+
+	bindingRules, _, err := c.consulClient.ACL().BindingRuleList(name, opts)
+	if err != nil {
+		return fmt.Errorf("listing binding rules of auth method %q: %s", authMethod, err)
+	}
+	// roleServiceAccounts maps roles to the service account that binding
+	// rules bind to them, so that the tokens of components, which only have
+	// roles, can be attributed to their service accounts.
+	roleServiceAccounts := make(map[string]serviceAccount)
+	for _, rule := range bindingRules {
+		// Role names that are interpolated from the identity can't be checked.
+		if rule.BindType != api.BindingRuleBindTypeRole || strings.Contains(rule.BindName, "${") {
+			continue
+		}
+		sa, ok := c.selectorServiceAccount(rule.Selector)
+		if ok {
+			roleServiceAccounts[rule.BindName] = sa
+		}
+		if roleNames[rule.BindName] {
+			continue
+		}
+		c.log.Warn("binding rule binds a role that doesn't exist", "auth-method", authMethod,
+			"binding-rule", rule.ID, "role", rule.BindName, "service-account", sa.name)
+		c.metrics.missingRoles.WithLabelValues(authMethod, sa.namespace, sa.name, rule.BindName).Set(1)
+		if ok {
+			a := auditOf(audits, sa)
+			a.missingRoles = append(a.missingRoles, rule.BindName)
+		}
+	}
+
+	tokenOpts := opts
+	if c.flagEnableNamespaces {
+		tokenOpts = &api.QueryOptions{Namespace: wildcardNamespace}
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+	}
+	tokens, _, err := c.consulClient.ACL().TokenList(tokenOpts)
+	if err != nil {
+		return fmt.Errorf("listing tokens: %s", err)
+	}
+	logins := make(map[serviceAccount]*audit)
+	for _, token := range tokens {
+		if token.AuthMethod != name || (c.flagEnableNamespaces && token.AuthMethodNamespace != namespace) {
+			continue
+		}
+		a := auditOf(logins, c.tokenServiceAccount(token, roleServiceAccounts))
+		a.loginTokens++
+		if token.CreateTime.After(a.lastLogin) {
+			a.lastLogin = token.CreateTime
+		}
+	}
+	for sa, login := range logins {
+		c.metrics.loginTokens.WithLabelValues(authMethod, sa.namespace, sa.name).Set(float64(login.loginTokens))
+		c.metrics.lastLogin.WithLabelValues(authMethod, sa.namespace, sa.name).Set(float64(login.lastLogin.Unix()))
+		if sa.name == unknownServiceAccount {
+			continue
+		}
+		a := auditOf(audits, sa)
+		a.loginTokens += login.loginTokens
+		if login.lastLogin.After(a.lastLogin) {
+			a.lastLogin = login.lastLogin
+		}
+	}
+	return nil
+}
+
+// tokenServiceAccount returns the service account that logged in to create
+// token. Connect services log in with the name of their pod in the login
+// metadata, and components with roles that their service account is bound to.
+func (c *Command) tokenServiceAccount(token *api.ACLTokenListEntry, roleServiceAccounts map[string]serviceAccount) serviceAccount {
+	var meta map[string]string
+	if strings.HasPrefix(token.Description, loginDescriptionPrefix) {
+		_ = json.Unmarshal([]byte(strings.TrimPrefix(token.Description, loginDescriptionPrefix)), &meta)
+	}
+	if podName := strings.SplitN(meta["pod"], "/", 2); len(podName) == 2 {
+		pod, err := c.k8sClient.CoreV1().Pods(podName[0]).Get(c.ctx, podName[1], metav1.GetOptions{})
+		if err == nil {
+			return serviceAccount{namespace: pod.Namespace, name: pod.Spec.ServiceAccountName}
+		}
+		// The pod is gone without logging out. Connect services have a
+		// service identity named after their service account.
+		if k8serrors.IsNotFound(err) && len(token.ServiceIdentities) == 1 {
+			return serviceAccount{namespace: podName[0], name: token.ServiceIdentities[0].ServiceName}
+		}
+	}
+	for _, role := range token.Roles {
+		if sa, ok := roleServiceAccounts[role.Name]; ok {
+			return sa
+		}
+	}
+	return serviceAccount{name: unknownServiceAccount}
+}
+
+// selectorServiceAccount returns the service account that a binding rule
+// selector selects by name, in -k8s-namespace if it doesn't select a
+// namespace.
+func (c *Command) selectorServiceAccount(selector string) (serviceAccount, bool) {
+	name := selectorNameRe.FindStringSubmatch(selector)
+	if name == nil {
+		return serviceAccount{}, false
+	}
+	sa := serviceAccount{namespace: c.flagK8sNamespace, name: name[1]}
+	if namespace := selectorNamespaceRe.FindStringSubmatch(selector); namespace != nil {
+		sa.namespace = namespace[1]
+	}
+	return sa, true
+}
+
+// annotateServiceAccount records a in the annotations of sa.
+func (c *Command) annotateServiceAccount(sa serviceAccount, a *audit) error {
+	if sa.namespace == "" {
+		return nil
+	}
+	annotations := map[string]interface{}{
+		loginTokensAnnotation:  strconv.Itoa(a.loginTokens),
+		lastLoginAnnotation:    nil,
+		missingRolesAnnotation: nil,
+	}
+	if !a.lastLogin.IsZero() {
+		annotations[lastLoginAnnotation] = a.lastLogin.UTC().Format(time.RFC3339)
+	}
+	if len(a.missingRoles) > 0 {
+		sort.Strings(a.missingRoles)
+		annotations[missingRolesAnnotation] = strings.Join(a.missingRoles, ",")
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.k8sClient.CoreV1().ServiceAccounts(sa.namespace).Patch(c.ctx, sa.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("annotating service account %s/%s: %s", sa.namespace, sa.name, err)
+	}
+	return nil
+}
+
+func auditOf(audits map[serviceAccount]*audit, sa serviceAccount) *audit {
+	a, ok := audits[sa]
+	if !ok {
+		a = &audit{}
+		audits[sa] = a
+	}
+	return a
+}
+
+// Help returns the command's help text.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+// Synopsis returns a one-line synopsis of the command.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// validateFlags ensures that all required flags are set.
+func (c *Command) validateFlags() error {
+	if len(c.flagAuthMethods) == 0 {
+		return fmt.Errorf("-auth-method must be set")
+	}
+
+	if c.flagCheckInterval <= 0 {
+		return fmt.Errorf("-check-interval must be greater than 0")
+	}
+
+	if c.http.ConsulAPITimeout() <= 0 {
+		return fmt.Errorf("-consul-api-timeout must be set to a value greater than 0")
+	}
+
+	return nil
+}
+
+const synopsis = "Periodically audit the logins of service accounts to Kubernetes auth methods."
+const help = `
+Usage: consul-k8s-control-plane acl-login-audit [options]
+
+  Audits which Kubernetes service accounts have ACL tokens from logging in
+  to the auth methods and which binding rules of the auth methods bind
+  service accounts to roles that don't exist. The results are served as
+  Prometheus metrics and, with -annotate-service-accounts, recorded in
+  the consul.hashicorp.com/acl-login-tokens, consul.hashicorp.com/acl-last-login
+  and consul.hashicorp.com/acl-missing-roles annotations of the service accounts.
+`
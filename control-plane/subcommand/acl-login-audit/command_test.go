@@ -0,0 +1,226 @@
+package aclloginaudit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	namespace            = "default"
+	authMethod           = "consul-k8s-auth-method"
+	componentAuthMethod  = "consul-k8s-component-auth-method"
+	syncCatalogRole      = "consul-sync-catalog-acl-role"
+	missingRole          = "consul-controller-acl-role"
+	syncCatalogSA        = "consul-sync-catalog"
+	controllerSA         = "consul-controller"
+	serviceSA            = "web"
+	servicePod           = "web-abcde"
+	loginMetaDescription = loginDescriptionPrefix + `{"pod":"default/web-abcde"}`
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-auth-method must be set",
+		},
+		{
+			flags:  []string{"-auth-method", authMethod, "-check-interval", "0s"},
+			expErr: "-check-interval must be greater than 0",
+		},
+		{
+			flags:  []string{"-auth-method", authMethod},
+			expErr: "-consul-api-timeout must be set to a value greater than 0",
+		},
+		{
+			flags:  []string{"-auth-method", authMethod, "-consul-api-timeout", "5s", "-log-level", "oak"},
+			expErr: "unknown log level",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	cmd, k8s := testCommand(t, true)
+
+	require.NoError(t, cmd.reconcile())
+
+	// A Connect service is attributed to the service account of its pod.
+	require.Equal(t, float64(2), testutil.ToFloat64(cmd.metrics.loginTokens.WithLabelValues(authMethod, namespace, serviceSA)))
+	require.Equal(t, float64(lastLogin.Unix()), testutil.ToFloat64(cmd.metrics.lastLogin.WithLabelValues(authMethod, namespace, serviceSA)))
+
+	// A component is attributed to the service account bound to its role.
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.loginTokens.WithLabelValues(componentAuthMethod, namespace, syncCatalogSA)))
+
+	// Tokens of other auth methods aren't counted.
+	require.Equal(t, float64(0), testutil.ToFloat64(cmd.metrics.loginTokens.WithLabelValues(componentAuthMethod, namespace, serviceSA)))
+
+	// Binding rules of roles that don't exist are reported.
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.missingRoles.WithLabelValues(componentAuthMethod, namespace, controllerSA, missingRole)))
+	require.Equal(t, float64(0), testutil.ToFloat64(cmd.metrics.missingRoles.WithLabelValues(componentAuthMethod, namespace, syncCatalogSA, syncCatalogRole)))
+
+	sa, err := k8s.CoreV1().ServiceAccounts(namespace).Get(context.Background(), serviceSA, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "2", sa.Annotations[loginTokensAnnotation])
+	require.Equal(t, lastLogin.UTC().Format(time.RFC3339), sa.Annotations[lastLoginAnnotation])
+	require.NotContains(t, sa.Annotations, missingRolesAnnotation)
+
+	sa, err = k8s.CoreV1().ServiceAccounts(namespace).Get(context.Background(), controllerSA, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "0", sa.Annotations[loginTokensAnnotation])
+	require.Equal(t, missingRole, sa.Annotations[missingRolesAnnotation])
+}
+
+// Test that service accounts aren't annotated without -annotate-service-accounts.
+func TestReconcile_noAnnotations(t *testing.T) {
+	t.Parallel()
+	cmd, k8s := testCommand(t, false)
+
+	require.NoError(t, cmd.reconcile())
+
+	sa, err := k8s.CoreV1().ServiceAccounts(namespace).Get(context.Background(), serviceSA, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, sa.Annotations)
+}
+
+// Test that with namespaces, tokens are listed in all namespaces but only
+// the logins to the auth method's namespace are counted.
+func TestReconcile_namespaces(t *testing.T) {
+	t.Parallel()
+	cmd, _ := testCommand(t, false)
+	cmd.flagEnableNamespaces = true
+
+	require.NoError(t, cmd.reconcile())
+
+	require.Equal(t, float64(2), testutil.ToFloat64(cmd.metrics.loginTokens.WithLabelValues(authMethod, namespace, serviceSA)))
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.loginTokens.WithLabelValues(componentAuthMethod, namespace, syncCatalogSA)))
+}
+
+var lastLogin = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testCommand(t *testing.T, annotate bool) (*Command, *fake.Clientset) {
+	t.Helper()
+
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch r.URL.Path {
+		case "/v1/acl/roles":
+			resp = []*api.ACLRole{{Name: syncCatalogRole}}
+		case "/v1/acl/binding-rules":
+			if r.URL.Query().Get("authmethod") != componentAuthMethod {
+				resp = []*api.ACLBindingRule{}
+				break
+			}
+			resp = []*api.ACLBindingRule{
+				{
+					ID:       "sync-catalog",
+					Selector: `serviceaccount.name=="consul-sync-catalog"`,
+					BindType: api.BindingRuleBindTypeRole,
+					BindName: syncCatalogRole,
+				},
+				{
+					ID:       "controller",
+					Selector: `serviceaccount.name=="consul-controller"`,
+					BindType: api.BindingRuleBindTypeRole,
+					BindName: missingRole,
+				},
+			}
+		case "/v1/acl/tokens":
+			resp = []*api.ACLTokenListEntry{
+				{
+					AuthMethod:        authMethod,
+					Description:       loginMetaDescription,
+					ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: serviceSA}},
+					CreateTime:        lastLogin.Add(-time.Hour),
+				},
+				{
+					AuthMethod:        authMethod,
+					Description:       loginMetaDescription,
+					ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: serviceSA}},
+					CreateTime:        lastLogin,
+				},
+				{
+					AuthMethod:  componentAuthMethod,
+					Description: loginDescriptionPrefix + `{"component":"sync-catalog"}`,
+					Roles:       []*api.ACLTokenRoleLink{{Name: syncCatalogRole}},
+					CreateTime:  lastLogin,
+				},
+				{
+					Description: "bootstrap token",
+				},
+			}
+			if r.URL.Query().Get("ns") == wildcardNamespace {
+				for _, token := range resp.([]*api.ACLTokenListEntry) {
+					token.AuthMethodNamespace = defaultNamespace
+				}
+				// A login to an auth method of the same name in another namespace.
+				resp = append(resp.([]*api.ACLTokenListEntry), &api.ACLTokenListEntry{
+					AuthMethod:          authMethod,
+					AuthMethodNamespace: "other",
+					Description:         loginMetaDescription,
+					CreateTime:          lastLogin,
+				})
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(consul.Close)
+
+	consulClient, err := api.NewClient(&api.Config{Address: consul.URL})
+	require.NoError(t, err)
+
+	k8s := fake.NewSimpleClientset(
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: servicePod, Namespace: namespace},
+			Spec:       v1.PodSpec{ServiceAccountName: serviceSA},
+		},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceSA, Namespace: namespace}},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: syncCatalogSA, Namespace: namespace}},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: controllerSA, Namespace: namespace}},
+	)
+
+	cmd := &Command{
+		UI:                          cli.NewMockUi(),
+		k8sClient:                   k8s,
+		consulClient:                consulClient,
+		flagAuthMethods:             []string{authMethod, componentAuthMethod},
+		flagK8sNamespace:            namespace,
+		flagAnnotateServiceAccounts: annotate,
+		log:                         hclog.NewNullLogger(),
+		metrics:                     newMetrics(),
+		ctx:                         context.Background(),
+	}
+	return cmd, k8s
+}
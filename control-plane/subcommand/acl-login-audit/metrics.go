@@ -0,0 +1,66 @@
+package aclloginaudit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "consul_k8s"
+	metricsSubsystem = "acl_login_audit"
+)
+
+// metrics are the Prometheus metrics of the ACL login audit.
+type metrics struct {
+	// loginTokens is the number of ACL tokens of each service account that
+	// were created by logging in to an auth method.
+	loginTokens *prometheus.GaugeVec
+
+	// lastLogin is the time of the last login of each service account.
+	lastLogin *prometheus.GaugeVec
+
+	// missingRoles is 1 for each binding rule that binds a service account
+	// to a role that doesn't exist.
+	missingRoles *prometheus.GaugeVec
+
+	// errors is the number of failed audits.
+	errors prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	labels := []string{"auth_method", "namespace", "service_account"}
+	return &metrics{
+		loginTokens: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "login_tokens",
+			Help:      "Number of ACL tokens of a service account created by logging in to an auth method.",
+		}, labels),
+		lastLogin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "last_login_timestamp_seconds",
+			Help:      "Time of the last login of a service account to an auth method.",
+		}, labels),
+		missingRoles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "missing_role_binding_rules",
+			Help:      "Binding rules of an auth method that bind a service account to a role that doesn't exist.",
+		}, append(labels, "role")),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "errors_total",
+			Help:      "Number of failed ACL login audits.",
+		}),
+	}
+}
+
+func (m *metrics) register(registry prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.loginTokens, m.lastLogin, m.missingRoles, m.errors} {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package acltokenrotate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// rotatedAtAnnotation is set on the pod template of a deployment to the
+	// time of the last rotation, which restarts its pods like
+	// `kubectl rollout restart`.
+	rotatedAtAnnotation = "consul.hashicorp.com/acl-token-rotated-at"
+
+	// pausedAnnotation pauses rotation of a deployment when it is set to
+	// "true" on the deployment.
+	pausedAnnotation = "consul.hashicorp.com/acl-token-rotation-paused"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	k8s   *flags.K8SFlags
+
+	flagNamespace string
+	flagSelector  string
+
+	flagRotationPeriod time.Duration
+	flagCheckInterval  time.Duration
+	flagListen         string
+
+	flagLogLevel string
+	flagLogJSON  bool
+
+	k8sClient kubernetes.Interface
+
+	log     hclog.Logger
+	metrics *metrics
+	sigCh   chan os.Signal
+	once    sync.Once
+	ctx     context.Context
+	help    string
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+}
+
+// init is run once to set up usage documentation for flags.
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false, "Enable or disable JSON output format for logging.")
+	c.flags.StringVar(&c.flagNamespace, "namespace", "", "Name of Kubernetes namespace of the component deployments.")
+	c.flags.StringVar(&c.flagSelector, "selector", "",
+		"Label selector of the deployments whose ACL tokens are rotated.")
+	c.flags.DurationVar(&c.flagRotationPeriod, "rotation-period", 7*24*time.Hour,
+		"How long an ACL token is used before it's rotated.")
+	c.flags.DurationVar(&c.flagCheckInterval, "check-interval", 5*time.Minute,
+		"How often to check whether ACL tokens are due for rotation.")
+	c.flags.StringVar(&c.flagListen, "listen", ":8080", "Address to serve metrics on.")
+
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.k8s.Flags())
+
+	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+}
+
+// Run periodically rotates the ACL tokens of the component deployments by
+// restarting their pods, which log in with the component auth method when
+// they start and log out when they stop.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to validate flags: %v", err))
+		return 1
+	}
+
+	var err error
+	c.log, err = common.Logger("acl-token-rotate", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	c.metrics = newMetrics()
+	registry := prometheus.NewRegistry()
+	if err := c.metrics.register(registry); err != nil {
+		c.UI.Error(fmt.Sprintf("Error registering metrics: %s", err))
+		return 1
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, mux); err != nil {
+			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		}
+	}()
+
+	ticker := time.NewTicker(c.flagCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			c.metrics.errors.Inc()
+			c.log.Error("failed to rotate ACL tokens", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// reconcile restarts every deployment whose oldest pod, and therefore ACL
+// token, is older than the rotation period.
+func (c *Command) reconcile() error {
+	deployments, err := c.k8sClient.AppsV1().Deployments(c.flagNamespace).List(c.ctx, metav1.ListOptions{LabelSelector: c.flagSelector})
+	if err != nil {
+		return fmt.Errorf("listing deployments: %s", err)
+	}
+
+	var errs []error
+	for i := range deployments.Items {
+		if err := c.reconcileDeployment(&deployments.Items[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d deployments failed: %v", len(errs), len(deployments.Items), errs)
+	}
+	return nil
+}
+
+func (c *Command) reconcileDeployment(deployment *appsv1.Deployment) error {
+	name := deployment.Name
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("parsing selector of deployment %q: %s", name, err)
+	}
+	pods, err := c.k8sClient.CoreV1().Pods(c.flagNamespace).List(c.ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return fmt.Errorf("listing pods of deployment %q: %s", name, err)
+	}
+	var oldest time.Time
+	for _, pod := range pods.Items {
+		if oldest.IsZero() || pod.CreationTimestamp.Time.Before(oldest) {
+			oldest = pod.CreationTimestamp.Time
+		}
+	}
+	if oldest.IsZero() {
+		c.metrics.tokenAge.DeleteLabelValues(name)
+		return nil
+	}
+	age := c.now().Sub(oldest)
+	c.metrics.tokenAge.WithLabelValues(name).Set(age.Seconds())
+
+	if age < c.flagRotationPeriod {
+		return nil
+	}
+	if deployment.Annotations[pausedAnnotation] == "true" {
+		c.log.Debug("ACL token rotation is paused", "deployment", name)
+		return nil
+	}
+	// Pods of a rollout that is still in progress aren't restarted again.
+	if deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.UpdatedReplicas < deployment.Status.Replicas {
+		c.log.Debug("waiting for rollout to rotate ACL tokens", "deployment", name)
+		return nil
+	}
+
+	c.log.Info("rotating ACL tokens", "deployment", name, "token-age", age)
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, rotatedAtAnnotation, c.now().UTC().Format(time.RFC3339))
+	if _, err := c.k8sClient.AppsV1().Deployments(c.flagNamespace).Patch(c.ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("restarting deployment %q: %s", name, err)
+	}
+	c.metrics.rotations.WithLabelValues(name).Inc()
+	return nil
+}
+
+// Help returns the command's help text.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+// Synopsis returns a one-line synopsis of the command.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// validateFlags ensures that all required flags are set.
+func (c *Command) validateFlags() error {
+	if c.flagNamespace == "" {
+		return fmt.Errorf("-namespace must be set")
+	}
+
+	if c.flagSelector == "" {
+		return fmt.Errorf("-selector must be set")
+	}
+
+	if c.flagRotationPeriod <= 0 {
+		return fmt.Errorf("-rotation-period must be greater than 0")
+	}
+
+	if c.flagCheckInterval <= 0 {
+		return fmt.Errorf("-check-interval must be greater than 0")
+	}
+
+	return nil
+}
+
+const synopsis = "Periodically rotate the ACL tokens of components."
+const help = `
+Usage: consul-k8s-control-plane acl-token-rotate [options]
+
+  Rotates the ACL tokens of the deployments matching the selector once
+  they are older than the rotation period. Components log in with the
+  component auth method when their pods start and log out when they stop,
+  so the deployments are restarted to re-issue their tokens.
+  Rotation of a deployment is paused while it has the annotation
+  consul.hashicorp.com/acl-token-rotation-paused=true.
+`
@@ -0,0 +1,171 @@
+package acltokenrotate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	namespace  = "default"
+	deployment = "consul-sync-catalog"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-namespace must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default"},
+			expErr: "-selector must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-selector", "release=consul", "-rotation-period", "0s"},
+			expErr: "-rotation-period must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-selector", "release=consul", "-check-interval", "0s"},
+			expErr: "-check-interval must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-selector", "release=consul", "-log-level", "oak"},
+			expErr: "unknown log level",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		podAge      time.Duration
+		annotations map[string]string
+		status      appsv1.DeploymentStatus
+		expRotated  bool
+	}{
+		"token older than the rotation period": {
+			podAge:     8 * 24 * time.Hour,
+			expRotated: true,
+		},
+		"token newer than the rotation period": {
+			podAge: time.Hour,
+		},
+		"paused": {
+			podAge:      8 * 24 * time.Hour,
+			annotations: map[string]string{pausedAnnotation: "true"},
+		},
+		"rollout in progress": {
+			podAge: 8 * 24 * time.Hour,
+			status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			cmd, k8s := testCommand(t, c.podAge, c.annotations, c.status)
+
+			require.NoError(t, cmd.reconcile())
+
+			dep, err := k8s.AppsV1().Deployments(namespace).Get(context.Background(), deployment, metav1.GetOptions{})
+			require.NoError(t, err)
+			rotatedAt, ok := dep.Spec.Template.Annotations[rotatedAtAnnotation]
+			require.Equal(t, c.expRotated, ok)
+			if c.expRotated {
+				parsed, err := time.Parse(time.RFC3339, rotatedAt)
+				require.NoError(t, err)
+				require.WithinDuration(t, time.Now(), parsed, time.Minute)
+				require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.rotations.WithLabelValues(deployment)))
+			}
+			require.InDelta(t, c.podAge.Seconds(), testutil.ToFloat64(cmd.metrics.tokenAge.WithLabelValues(deployment)), 60)
+		})
+	}
+}
+
+// Test that deployments that don't match the selector aren't restarted.
+func TestReconcile_selector(t *testing.T) {
+	t.Parallel()
+	cmd, k8s := testCommand(t, 8*24*time.Hour, nil, appsv1.DeploymentStatus{})
+	cmd.flagSelector = "component=controller"
+
+	require.NoError(t, cmd.reconcile())
+
+	dep, err := k8s.AppsV1().Deployments(namespace).Get(context.Background(), deployment, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, dep.Spec.Template.Annotations, rotatedAtAnnotation)
+}
+
+func testCommand(t *testing.T, podAge time.Duration, annotations map[string]string, status appsv1.DeploymentStatus) (*Command, *fake.Clientset) {
+	t.Helper()
+	labels := map[string]string{"app": "consul", "component": "sync-catalog"}
+	k8s := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        deployment,
+				Namespace:   namespace,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: v1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				},
+			},
+			Status: status,
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              deployment + "-abcde",
+				Namespace:         namespace,
+				Labels:            labels,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-podAge)),
+			},
+		},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "other",
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-100 * 24 * time.Hour)),
+			},
+		},
+	)
+
+	cmd := &Command{
+		UI:                 cli.NewMockUi(),
+		k8sClient:          k8s,
+		flagNamespace:      namespace,
+		flagSelector:       "app=consul",
+		flagRotationPeriod: 7 * 24 * time.Hour,
+		log:                hclog.NewNullLogger(),
+		metrics:            newMetrics(),
+		ctx:                context.Background(),
+		now:                time.Now,
+	}
+	return cmd, k8s
+}
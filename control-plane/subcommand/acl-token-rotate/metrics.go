@@ -0,0 +1,54 @@
+package acltokenrotate
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "consul_k8s"
+	metricsSubsystem = "acl_token_rotation"
+)
+
+// metrics are the Prometheus metrics of ACL token rotation.
+type metrics struct {
+	// rotations is the number of rotations of each deployment.
+	rotations *prometheus.CounterVec
+
+	// errors is the number of failed checks or rotations.
+	errors prometheus.Counter
+
+	// tokenAge is the age of the oldest ACL token of each deployment.
+	tokenAge *prometheus.GaugeVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rotations_total",
+			Help:      "Number of ACL token rotations of a deployment.",
+		}, []string{"deployment"}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "errors_total",
+			Help:      "Number of failed ACL token rotations or checks.",
+		}),
+		tokenAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "token_age_seconds",
+			Help:      "Age of the oldest ACL token of a deployment.",
+		}, []string{"deployment"}),
+	}
+}
+
+func (m *metrics) register(registry prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.rotations, m.errors, m.tokenAge} {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// defaultAWSSTSRegion is the region used to sign requests to STS if none
+	// is configured. Requests in this region are sent to the global STS
+	// endpoint, which is the default endpoint of Consul's AWS IAM auth method.
+	defaultAWSSTSRegion = "us-east-1"
+
+	// awsIAMServerIDHeaderName is the header Consul's AWS IAM auth method
+	// compares to its ServerIDHeaderValue.
+	awsIAMServerIDHeaderName = "X-Consul-IAM-ServerID"
+)
+
+// AWSIAMLoginParams are parameters used to log in to Consul with an AWS IAM
+// auth method.
+type AWSIAMLoginParams struct {
+	// STSRegion is the region to sign the sts:GetCallerIdentity request for.
+	// Defaults to us-east-1.
+	STSRegion string
+	// STSEndpoint is the URL of the STS endpoint. It must match the
+	// STSEndpoint of the auth method. Defaults to the endpoint of STSRegion.
+	STSEndpoint string
+	// ServerIDHeaderValue is sent in the X-Consul-IAM-ServerID header. It
+	// must be set if the auth method has a ServerIDHeaderValue.
+	ServerIDHeaderValue string
+}
+
+// awsIAMBearerToken returns the bearer token to log in with an AWS IAM auth
+// method: an sts:GetCallerIdentity request signed with the AWS credentials
+// found in the environment, e.g. the IAM role of an EKS service account,
+// which the Consul servers send to STS to verify the identity of the caller.
+// The logic of this is taken from the `consul login` command.
+func awsIAMBearerToken(params *AWSIAMLoginParams) (string, error) {
+	cfg := aws.NewConfig().WithRegion(defaultAWSSTSRegion)
+	if params.STSRegion != "" {
+		cfg = cfg.WithRegion(params.STSRegion)
+	}
+	if params.STSEndpoint != "" {
+		cfg = cfg.WithEndpoint(params.STSEndpoint)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return "", fmt.Errorf("unable to create AWS session: %s", err)
+	}
+
+	req, _ := sts.New(sess).GetCallerIdentityRequest(nil)
+	if params.ServerIDHeaderValue != "" {
+		req.HTTPRequest.Header.Add(awsIAMServerIDHeaderName, params.ServerIDHeaderValue)
+	}
+	if err := req.Sign(); err != nil {
+		return "", fmt.Errorf("unable to sign sts:GetCallerIdentity request: %s", err)
+	}
+	headers, err := json.Marshal(req.HTTPRequest.Header)
+	if err != nil {
+		return "", err
+	}
+	body, err := ioutil.ReadAll(req.HTTPRequest.Body)
+	if err != nil {
+		return "", err
+	}
+
+	bearerToken, err := json.Marshal(map[string]string{
+		"iam_http_request_method": req.HTTPRequest.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(req.HTTPRequest.URL.String())),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+		"iam_request_body":        base64.StdEncoding.EncodeToString(body),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(bearerToken), nil
+}
@@ -2,6 +2,8 @@
 package common
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -42,7 +44,8 @@ const (
 )
 
 // Logger returns an hclog instance with log level set and JSON logging enabled/disabled, or an error if level is invalid.
-func Logger(level string, jsonLogging bool) (hclog.Logger, error) {
+// Every log line has a component field with the name of the consul-k8s component, e.g. sync-catalog.
+func Logger(component, level string, jsonLogging bool) (hclog.Logger, error) {
 	parsedLevel := hclog.LevelFromString(level)
 	if parsedLevel == hclog.NoLevel {
 		return nil, fmt.Errorf("unknown log level: %s", level)
@@ -51,11 +54,13 @@ func Logger(level string, jsonLogging bool) (hclog.Logger, error) {
 		JSONFormat: jsonLogging,
 		Level:      parsedLevel,
 		Output:     os.Stderr,
-	}), nil
+	}).With("component", component), nil
 }
 
 // ZapLogger returns a logr.Logger instance with log level set and JSON logging enabled/disabled, or an error if the level is invalid.
-func ZapLogger(level string, jsonLogging bool) (logr.Logger, error) {
+// Like Logger, every log line has a component field, and JSON log lines have the same keys as the ones of Logger so that
+// the logs of all consul-k8s components can be parsed the same way.
+func ZapLogger(component, level string, jsonLogging bool) (logr.Logger, error) {
 	var zapLevel zapcore.Level
 	// It is possible that a user passes in "trace" from global.logLevel, until we standardize on one logging framework
 	// we will assume they meant debug here and not fail.
@@ -66,9 +71,21 @@ func ZapLogger(level string, jsonLogging bool) (logr.Logger, error) {
 		return nil, fmt.Errorf("unknown log level %q: %s", level, err.Error())
 	}
 	if jsonLogging {
-		return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.JSONEncoder()), nil
+		return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.JSONEncoder(hclogEncoderConfig)).WithValues("component", component), nil
 	}
-	return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.ConsoleEncoder()), nil
+	return zap.New(zap.UseDevMode(false), zap.Level(zapLevel), zap.ConsoleEncoder()).WithValues("component", component), nil
+}
+
+// hclogEncoderConfig sets the keys and the time format of the JSON log lines
+// to the ones of hclog.
+func hclogEncoderConfig(c *zapcore.EncoderConfig) {
+	c.TimeKey = "@timestamp"
+	c.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02T15:04:05.000000Z07:00")
+	c.LevelKey = "@level"
+	c.EncodeLevel = zapcore.LowercaseLevelEncoder
+	c.MessageKey = "@message"
+	c.NameKey = "@module"
+	c.CallerKey = "@caller"
 }
 
 // ValidateUnprivilegedPort converts flags representing ports into integer and validates
@@ -95,10 +112,16 @@ type LoginParams struct {
 	Namespace string
 	// BearerTokenFile is the file where the bearer token is stored.
 	BearerTokenFile string
+	// AWSIAM, if set, logs in with an AWS IAM auth method using the AWS
+	// credentials of the pod instead of the bearer token in BearerTokenFile.
+	AWSIAM *AWSIAMLoginParams
 	// TokenSinkFile is the file where to write the token received from Consul.
 	TokenSinkFile string
 	// Meta is the metadata to set on the token.
 	Meta map[string]string
+	// OnLoginFailure, if set, is called with the error of every failed
+	// login attempt, including the ones that are retried.
+	OnLoginFailure func(err error)
 
 	// numRetries is only used in tests to make them run faster.
 	numRetries uint64
@@ -107,14 +130,10 @@ type LoginParams struct {
 // ConsulLogin issues an ACL().Login to Consul and writes out the token to tokenSinkFile.
 // The logic of this is taken from the `consul login` command.
 func ConsulLogin(client *api.Client, params LoginParams, log hclog.Logger) (string, error) {
-	// Read the bearerTokenFile.
-	data, err := ioutil.ReadFile(params.BearerTokenFile)
+	// Read the bearerTokenFile or generate the AWS IAM bearer token.
+	bearerToken, err := params.bearerToken()
 	if err != nil {
-		return "", fmt.Errorf("unable to read bearer token file: %v, err: %v", params.BearerTokenFile, err)
-	}
-	bearerToken := strings.TrimSpace(string(data))
-	if bearerToken == "" {
-		return "", fmt.Errorf("no bearer token found in %q", params.BearerTokenFile)
+		return "", err
 	}
 
 	if params.numRetries == 0 {
@@ -122,6 +141,13 @@ func ConsulLogin(client *api.Client, params LoginParams, log hclog.Logger) (stri
 	}
 	var token *api.ACLToken
 	err = backoff.Retry(func() error {
+		// Re-read the bearer token on every attempt because projected service account
+		// tokens are rotated by the kubelet and the token we read previously may
+		// have expired in the meantime. Signed AWS IAM requests expire as well.
+		if rotated, err := params.bearerToken(); err == nil {
+			bearerToken = rotated
+		}
+
 		// Do the login.
 		req := &api.ACLLoginParams{
 			AuthMethod:  params.AuthMethod,
@@ -134,6 +160,9 @@ func ConsulLogin(client *api.Client, params LoginParams, log hclog.Logger) (stri
 		token, _, err = client.ACL().Login(req, &api.WriteOptions{Namespace: params.Namespace, Datacenter: params.Datacenter})
 		if err != nil {
 			log.Error("unable to login", "error", err)
+			if params.OnLoginFailure != nil {
+				params.OnLoginFailure(err)
+			}
 			return fmt.Errorf("error logging in: %s", err)
 		}
 		if params.TokenSinkFile != "" {
@@ -198,6 +227,29 @@ func ConsulLogin(client *api.Client, params LoginParams, log hclog.Logger) (stri
 	return token.SecretID, nil
 }
 
+// bearerToken returns the bearer token to log in with: the AWS IAM bearer
+// token if AWSIAM is set, and otherwise the token in BearerTokenFile.
+func (p LoginParams) bearerToken() (string, error) {
+	if p.AWSIAM != nil {
+		return awsIAMBearerToken(p.AWSIAM)
+	}
+	return readBearerToken(p.BearerTokenFile)
+}
+
+// readBearerToken reads the bearer token from bearerTokenFile and returns an error
+// if the file cannot be read or is empty.
+func readBearerToken(bearerTokenFile string) (string, error) {
+	data, err := ioutil.ReadFile(bearerTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read bearer token file: %v, err: %v", bearerTokenFile, err)
+	}
+	bearerToken := strings.TrimSpace(string(data))
+	if bearerToken == "" {
+		return "", fmt.Errorf("no bearer token found in %q", bearerTokenFile)
+	}
+	return bearerToken, nil
+}
+
 // WriteFileWithPerms will write payload as the contents of the outputFile and set permissions after writing the contents. This function is necessary since using ioutil.WriteFile() alone will create the new file with the requested permissions prior to actually writing the file, so you can't set read-only permissions.
 func WriteFileWithPerms(outputFile, payload string, mode os.FileMode) error {
 	// os.WriteFile truncates existing files and overwrites them, but only if they are writable.
@@ -221,3 +273,22 @@ func GetResolvedServerAddresses(serverAddresses []string, providers map[string]d
 	}
 	return godiscover.ConsulServerAddresses(serverAddresses[0], providers, logger)
 }
+
+// GenerateGossipKey generates a random 32 byte gossip encryption key returned
+// as a base64 encoded string.
+func GenerateGossipKey() (string, error) {
+	// This code was copied from Consul's Keygen command:
+	// https://github.com/hashicorp/consul/blob/d652cc86e3d0322102c2b5e9026c6a60f36c17a5/command/keygen/keygen.go
+
+	key := make([]byte, 32)
+	n, err := rand.Reader.Read(key)
+
+	if err != nil {
+		return "", fmt.Errorf("error reading random data: %s", err)
+	}
+	if n != 32 {
+		return "", fmt.Errorf("couldn't read enough entropy")
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
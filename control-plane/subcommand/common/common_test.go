@@ -1,6 +1,9 @@
 package common
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -8,6 +11,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,26 +21,54 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLogger_InvalidLogLevel(t *testing.T) {
-	_, err := Logger("invalid", false)
+	_, err := Logger("test", "invalid", false)
 	require.EqualError(t, err, "unknown log level: invalid")
 }
 
 func TestZapLogger_InvalidLogLevel(t *testing.T) {
-	_, err := ZapLogger("invalid", false)
+	_, err := ZapLogger("test", "invalid", false)
 	require.EqualError(t, err, "unknown log level \"invalid\": unrecognized level: \"invalid\"")
 }
 
 // ZapLogger should convert "trace" log level to "debug".
 func TestZapLogger_TraceLogLevel(t *testing.T) {
-	_, err := ZapLogger("trace", false)
+	_, err := ZapLogger("test", "trace", false)
 	require.NoError(t, err)
 }
 
+// The JSON log lines of ZapLogger have the same keys as the ones of Logger.
+func TestZapLogger_JSONKeys(t *testing.T) {
+	var hclogOutput bytes.Buffer
+	hclog.New(&hclog.LoggerOptions{JSONFormat: true, Output: &hclogOutput}).Named("module").Info("message", "component", "test")
+
+	var zapOutput bytes.Buffer
+	encoderConfig := zap.NewProductionEncoderConfig()
+	hclogEncoderConfig(&encoderConfig)
+	zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(&zapOutput), zapcore.InfoLevel)).
+		Named("module").Info("message", zap.String("component", "test"))
+
+	var hclogLine, zapLine map[string]interface{}
+	require.NoError(t, json.Unmarshal(hclogOutput.Bytes(), &hclogLine))
+	require.NoError(t, json.Unmarshal(zapOutput.Bytes(), &zapLine))
+
+	for _, k := range []string{"@level", "@message", "@module", "component"} {
+		require.Equal(t, hclogLine[k], zapLine[k], k)
+	}
+	timestampLayout := "2006-01-02T15:04:05.000000Z07:00"
+	_, err := time.Parse(timestampLayout, hclogLine["@timestamp"].(string))
+	require.NoError(t, err)
+	_, err = time.Parse(timestampLayout, zapLine["@timestamp"].(string))
+	require.NoError(t, err)
+	require.Len(t, zapLine, len(hclogLine))
+}
+
 func TestLogger(t *testing.T) {
-	lgr, err := Logger("debug", false)
+	lgr, err := Logger("test", "debug", false)
 	require.NoError(t, err)
 	require.NotNil(t, lgr)
 	require.True(t, lgr.IsDebug())
@@ -59,7 +91,7 @@ func TestConsulLogin(t *testing.T) {
 	tokenFile := WriteTempFile(t, "")
 
 	// This is a common.Logger.
-	log, err := Logger("INFO", false)
+	log, err := Logger("test", "INFO", false)
 	require.NoError(t, err)
 	client := startMockServer(t)
 	params := LoginParams{
@@ -81,11 +113,12 @@ func TestConsulLogin_Retries(t *testing.T) {
 	t.Parallel()
 
 	numLoginCalls := 0
+	numLoginFailures := 0
 	bearerTokenFile := WriteTempFile(t, "foo")
 	tokenFile := WriteTempFile(t, "")
 
 	// This is a common.Logger.
-	log, err := Logger("INFO", false)
+	log, err := Logger("test", "INFO", false)
 	require.NoError(t, err)
 	// Start the Consul server.
 	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -114,16 +147,134 @@ func TestConsulLogin_Retries(t *testing.T) {
 		Datacenter:      "dc1",
 		BearerTokenFile: bearerTokenFile,
 		TokenSinkFile:   tokenFile,
+		OnLoginFailure:  func(error) { numLoginFailures++ },
 	}
 	_, err = ConsulLogin(client, params, log)
 	require.NoError(t, err)
 	require.Equal(t, 2, numLoginCalls)
+	require.Equal(t, 1, numLoginFailures)
 	// Validate that the token file was written to disk.
 	data, err := ioutil.ReadFile(tokenFile)
 	require.NoError(t, err)
 	require.Equal(t, string(data), "b78d37c7-0ca7-5f4d-99ee-6d9975ce4586")
 }
 
+// TestConsulLogin_RotatedBearerToken tests that the bearer token is re-read on every
+// retry so that a token rotated by the kubelet is picked up.
+func TestConsulLogin_RotatedBearerToken(t *testing.T) {
+	t.Parallel()
+
+	// The handler runs in the server's goroutines, so it records what it
+	// receives and the test asserts on it once the login returns.
+	var lock sync.Mutex
+	var bearerTokens []string
+	var handlerErrs []error
+	bearerTokenFile := WriteTempFile(t, "foo")
+	tokenFile := WriteTempFile(t, "")
+
+	// This is a common.Logger.
+	log, err := Logger("test", "INFO", false)
+	require.NoError(t, err)
+	// Start the Consul server.
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r != nil && r.URL.Path == "/v1/acl/login" && r.Method == "POST" {
+			lock.Lock()
+			defer lock.Unlock()
+			var loginParams api.ACLLoginParams
+			if err := json.NewDecoder(r.Body).Decode(&loginParams); err != nil {
+				handlerErrs = append(handlerErrs, err)
+				w.WriteHeader(400)
+				return
+			}
+			bearerTokens = append(bearerTokens, loginParams.BearerToken)
+			if len(bearerTokens) == 1 {
+				// Simulate the kubelet rotating the token after the first failed login.
+				if err := ioutil.WriteFile(bearerTokenFile, []byte("bar"), 0600); err != nil {
+					handlerErrs = append(handlerErrs, err)
+				}
+				w.WriteHeader(500)
+			} else {
+				w.Write([]byte(testLoginResponse))
+			}
+		}
+		if r != nil && r.URL.Path == "/v1/acl/token/self" && r.Method == "GET" {
+			w.Write([]byte(testLoginResponse))
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+
+	serverURL, err := url.Parse(consulServer.URL)
+	require.NoError(t, err)
+	client, err := api.NewClient(&api.Config{Address: serverURL.String()})
+	require.NoError(t, err)
+	params := LoginParams{
+		AuthMethod:      testAuthMethod,
+		BearerTokenFile: bearerTokenFile,
+		TokenSinkFile:   tokenFile,
+	}
+	_, err = ConsulLogin(client, params, log)
+	require.NoError(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Empty(t, handlerErrs)
+	require.Equal(t, []string{"foo", "bar"}, bearerTokens)
+}
+
+// TestConsulLogin_AWSIAM tests that logging in with AWS IAM sends a signed
+// sts:GetCallerIdentity request as the bearer token.
+func TestConsulLogin_AWSIAM(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	tokenFile := WriteTempFile(t, "")
+
+	// This is a common.Logger.
+	log, err := Logger("test", "INFO", false)
+	require.NoError(t, err)
+	var bearerToken string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r != nil && r.URL.Path == "/v1/acl/login" && r.Method == "POST" {
+			var loginParams api.ACLLoginParams
+			if err := json.NewDecoder(r.Body).Decode(&loginParams); err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			bearerToken = loginParams.BearerToken
+			w.Write([]byte(testLoginResponse))
+		}
+		if r != nil && r.URL.Path == "/v1/acl/token/self" && r.Method == "GET" {
+			w.Write([]byte(testLoginResponse))
+		}
+	}))
+	t.Cleanup(consulServer.Close)
+
+	client, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+	params := LoginParams{
+		AuthMethod:    testAuthMethod,
+		TokenSinkFile: tokenFile,
+		AWSIAM:        &AWSIAMLoginParams{ServerIDHeaderValue: "consul.example.com"},
+	}
+	_, err = ConsulLogin(client, params, log)
+	require.NoError(t, err)
+
+	var loginData map[string]string
+	require.NoError(t, json.Unmarshal([]byte(bearerToken), &loginData))
+	require.Equal(t, "POST", loginData["iam_http_request_method"])
+	decode := func(key string) string {
+		value, err := base64.StdEncoding.DecodeString(loginData[key])
+		require.NoError(t, err)
+		return string(value)
+	}
+	require.Equal(t, "https://sts.amazonaws.com/", decode("iam_request_url"))
+	require.Equal(t, "Action=GetCallerIdentity&Version=2011-06-15", decode("iam_request_body"))
+	var headers http.Header
+	require.NoError(t, json.Unmarshal([]byte(decode("iam_request_headers")), &headers))
+	require.Equal(t, "consul.example.com", headers.Get("X-Consul-IAM-ServerID"))
+	require.Contains(t, headers.Get("Authorization"), "Credential=AKIAEXAMPLE/")
+	require.Contains(t, headers.Get("Authorization"), "x-consul-iam-serverid")
+}
+
 // TestConsulLogin_TokenNotReplicated tests that if we can't read the token in stale consistency mode
 // we return an error.
 func TestConsulLogin_TokenNotReplicated(t *testing.T) {
@@ -133,7 +284,7 @@ func TestConsulLogin_TokenNotReplicated(t *testing.T) {
 	tokenFile := WriteTempFile(t, "")
 
 	// This is a common.Logger.
-	log, err := Logger("INFO", false)
+	log, err := Logger("test", "INFO", false)
 	require.NoError(t, err)
 	// Start the Consul server.
 	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -192,7 +343,7 @@ func TestConsulLogin_TokenFileUnwritable(t *testing.T) {
 	bearerTokenFile := WriteTempFile(t, "foo")
 	client := startMockServer(t)
 	// This is a common.Logger.
-	log, err := Logger("INFO", false)
+	log, err := Logger("test", "INFO", false)
 	require.NoError(err)
 	randFileName := fmt.Sprintf("/foo/%d/%d", rand.Int(), rand.Int())
 	params := LoginParams{
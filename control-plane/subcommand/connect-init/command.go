@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sync"
 	"time"
@@ -44,14 +45,18 @@ type Command struct {
 	flagACLTokenSink                   string // Location to write the output token. Default is defaultTokenSinkFile.
 	flagProxyIDFile                    string // Location to write the output proxyID. Default is defaultProxyIDFile.
 	flagMultiPort                      bool
+	flagMetricsFile                    string // Location to write the metrics of connect-init to, if set.
+	flagTerminationMessageFile         string // Location to write the reason connect-init failed to, if set.
 	serviceRegistrationPollingAttempts uint64 // Number of times to poll for this service to be registered.
 
 	flagSet *flag.FlagSet
 	http    *flags.HTTPFlags
+	awsIAM  *flags.AWSIAMLoginFlags
 
-	once   sync.Once
-	help   string
-	logger hclog.Logger
+	once    sync.Once
+	help    string
+	logger  hclog.Logger
+	metrics *initMetrics
 }
 
 func (c *Command) init() {
@@ -67,6 +72,12 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagACLTokenSink, "acl-token-sink", defaultTokenSinkFile, "File name where where ACL token should be saved.")
 	c.flagSet.StringVar(&c.flagProxyIDFile, "proxy-id-file", defaultProxyIDFile, "File name where proxy's Consul service ID should be saved.")
 	c.flagSet.BoolVar(&c.flagMultiPort, "multiport", false, "If the pod is a multi port pod.")
+	c.flagSet.StringVar(&c.flagMetricsFile, "metrics-file", "",
+		"If set, the duration, retries and login failures of connect-init and the time it took to get the leaf "+
+			"certificate of the service are written to this file in the Prometheus text format.")
+	c.flagSet.StringVar(&c.flagTerminationMessageFile, "termination-message-file", "",
+		"If set, the reason connect-init failed is written to this file. Kubernetes reports it as the "+
+			"termination message of the container when it's the container's terminationMessagePath.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -78,12 +89,15 @@ func (c *Command) init() {
 	}
 
 	c.http = &flags.HTTPFlags{}
+	c.awsIAM = &flags.AWSIAMLoginFlags{}
 	flags.Merge(c.flagSet, c.http.Flags())
+	flags.Merge(c.flagSet, c.awsIAM.Flags())
 	c.help = flags.Usage(help, c.flagSet)
 }
 
-func (c *Command) Run(args []string) int {
+func (c *Command) Run(args []string) (exitCode int) {
 	var err error
+	start := time.Now()
 	c.once.Do(c.init)
 
 	if err := c.flagSet.Parse(args); err != nil {
@@ -98,12 +112,22 @@ func (c *Command) Run(args []string) int {
 	// Set up logging.
 	if c.logger == nil {
 		var err error
-		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		c.logger, err = common.Logger("connect-init", c.flagLogLevel, c.flagLogJSON)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 1
 		}
 	}
+
+	if c.flagMetricsFile != "" {
+		c.metrics = &initMetrics{service: c.flagServiceName, start: start}
+		defer func() {
+			if err := c.metrics.write(c.flagMetricsFile, exitCode == 0); err != nil {
+				c.logger.Error("Unable to write metrics to file", "error", err)
+			}
+		}()
+	}
+
 	cfg := api.DefaultConfig()
 	cfg.Namespace = c.flagConsulServiceNamespace
 	c.http.MergeOntoConfig(cfg)
@@ -123,16 +147,28 @@ func (c *Command) Run(args []string) int {
 			BearerTokenFile: c.flagBearerTokenFile,
 			TokenSinkFile:   c.flagACLTokenSink,
 			Meta:            loginMeta,
+			AWSIAM:          c.awsIAM.LoginParams(),
 		}
+		if c.metrics != nil {
+			loginParams.OnLoginFailure = func(error) { c.metrics.loginFailures++ }
+		}
+		loginStart := time.Now()
 		token, err := common.ConsulLogin(consulClient, loginParams, c.logger)
+		if c.metrics != nil {
+			c.metrics.loginDuration = time.Since(loginStart)
+		}
 		if err != nil {
-			if c.flagServiceAccountName == "default" {
+			message := fmt.Sprintf("Unable to log in to Consul with the %s auth method: %s.", c.flagACLAuthMethod, err)
+			if c.flagServiceAccountName == "default" && !c.awsIAM.Login() {
 				c.logger.Warn("The service account name for this Pod is \"default\"." +
 					" In default installations this is not a supported service account name." +
 					" The service account name must match the name of the Kubernetes Service" +
 					" or the consul.hashicorp.com/connect-service annotation.")
+				message += " The \"default\" service account of the pod isn't supported; its name must match" +
+					" the name of the Kubernetes Service or the consul.hashicorp.com/connect-service annotation."
 			}
 			c.logger.Error("unable to complete login", "error", err)
+			c.writeTerminationMessage(message)
 			return 1
 		}
 		cfg.Token = token
@@ -140,7 +176,7 @@ func (c *Command) Run(args []string) int {
 
 	// Now wait for the service to be registered. Do this by querying the Agent for a service
 	// which maps to this pod+namespace.
-	var proxyID string
+	var proxyID, serviceName string
 	registrationRetryCount := 0
 	var errServiceNameMismatch error
 	// We need a new client so that we can use the ACL token that was fetched during login to do the next bit,
@@ -199,6 +235,8 @@ func (c *Command) Run(args []string) int {
 			if svc.Kind == api.ServiceKindConnectProxy {
 				// This is the proxy service ID.
 				proxyID = svc.ID
+			} else {
+				serviceName = svc.Service
 			}
 		}
 
@@ -210,12 +248,23 @@ func (c *Command) Run(args []string) int {
 		}
 		return nil
 	}, backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), c.serviceRegistrationPollingAttempts))
+	if c.metrics != nil {
+		c.metrics.registrationRetries = registrationRetryCount - 1
+		if c.metrics.service == "" {
+			c.metrics.service = serviceName
+		}
+	}
 	if err != nil {
 		c.logger.Error("Timed out waiting for service registration", "error", err)
+		c.writeTerminationMessage(fmt.Sprintf("Timed out waiting for the services of the pod to be registered with Consul after %d attempts: %s."+
+			" Check that a Kubernetes Service selects the pod and that the connect injector is running.", registrationRetryCount, err))
 		return 1
 	}
 	if errServiceNameMismatch != nil {
 		c.logger.Error(errServiceNameMismatch.Error())
+		c.writeTerminationMessage(fmt.Sprintf("The service account of the pod doesn't match its Consul service: %s."+
+			" The service account name must match the name of the Kubernetes Service or the consul.hashicorp.com/connect-service annotation.",
+			errServiceNameMismatch))
 		return 1
 	}
 	// Write the proxy ID to the shared volume so `consul connect envoy` can use it for bootstrapping.
@@ -224,10 +273,31 @@ func (c *Command) Run(args []string) int {
 		c.logger.Error("Unable to write proxy ID to file", "error", err)
 		return 1
 	}
+	if c.metrics != nil {
+		// Getting the leaf certificate also caches it in the agent so that
+		// Envoy doesn't wait for it to be signed when it starts.
+		leafCertStart := time.Now()
+		if _, _, err := consulClient.Agent().ConnectCALeaf(serviceName, nil); err != nil {
+			c.logger.Warn("Unable to get the leaf certificate of the service", "service", serviceName, "error", err)
+		} else {
+			c.metrics.leafCertDuration = time.Since(leafCertStart)
+		}
+	}
 	c.logger.Info("Connect initialization completed")
 	return 0
 }
 
+// writeTerminationMessage writes message, the reason connect-init failed,
+// to the termination message file if it's set.
+func (c *Command) writeTerminationMessage(message string) {
+	if c.flagTerminationMessageFile == "" {
+		return
+	}
+	if err := ioutil.WriteFile(c.flagTerminationMessageFile, []byte(message), 0644); err != nil {
+		c.logger.Error("Unable to write the termination message", "error", err)
+	}
+}
+
 func (c *Command) validateFlags() error {
 	if c.flagPodName == "" {
 		return errors.New("-pod-name must be set")
@@ -238,6 +308,9 @@ func (c *Command) validateFlags() error {
 	if c.flagACLAuthMethod != "" && c.flagServiceAccountName == "" {
 		return errors.New("-service-account-name must be set when ACLs are enabled")
 	}
+	if c.awsIAM.Login() && c.flagACLAuthMethod == "" {
+		return errors.New("-acl-auth-method must be set if -aws-iam-login is true")
+	}
 
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
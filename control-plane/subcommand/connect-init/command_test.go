@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -41,6 +42,13 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-acl-auth-method", test.AuthMethod},
 			expErr: "-service-account-name must be set when ACLs are enabled",
 		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-aws-iam-login"},
+			expErr: "-acl-auth-method must be set if -aws-iam-login is true",
+		},
 		{
 			flags: []string{
 				"-pod-name", testPodName,
@@ -501,6 +509,7 @@ func TestRun_ServicePollingErrors(t *testing.T) {
 			t.Cleanup(func() {
 				os.Remove(proxyFile)
 			})
+			terminationMessageFile := filepath.Join(t.TempDir(), "termination-log")
 
 			// Start Consul server.
 			server, err := testutil.NewTestServerConfigT(t, nil)
@@ -526,10 +535,16 @@ func TestRun_ServicePollingErrors(t *testing.T) {
 				"-pod-namespace", testPodNamespace,
 				"-proxy-id-file", proxyFile,
 				"-consul-api-timeout", "5s",
+				"-termination-message-file", terminationMessageFile,
 			}
 
 			code := cmd.Run(flags)
 			require.Equal(t, 1, code)
+
+			// The reason connect-init failed is written to the termination message file.
+			message, err := ioutil.ReadFile(terminationMessageFile)
+			require.NoError(t, err)
+			require.Contains(t, string(message), "Timed out waiting for the services of the pod to be registered with Consul after 2 attempts")
 		})
 	}
 }
@@ -0,0 +1,82 @@
+package connectinit
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	metricsNamespace = "consul_k8s"
+	metricsSubsystem = "connect_init"
+)
+
+// initMetrics are the metrics of a run of connect-init. connect-init exits
+// before Prometheus could scrape it, so they are written to a file on the
+// shared volume of the pod instead, which the consul-sidecar serves.
+type initMetrics struct {
+	// service is the name of the service of the pod.
+	service string
+	// start is the time connect-init started.
+	start time.Time
+
+	loginDuration       time.Duration
+	loginFailures       int
+	registrationRetries int
+	leafCertDuration    time.Duration
+}
+
+// write writes the metrics to path in the Prometheus text format. success is
+// whether connect-init completed successfully.
+func (m *initMetrics) write(path string, success bool) error {
+	labels := prometheus.Labels{"service": m.service}
+	gauge := func(name, help string, value float64) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Subsystem:   metricsSubsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: labels,
+		})
+		g.Set(value)
+		return g
+	}
+	successValue := 0.0
+	if success {
+		successValue = 1
+	}
+
+	registry := prometheus.NewRegistry()
+	collectors := []prometheus.Collector{
+		gauge("success", "Whether connect-init completed successfully.", successValue),
+		gauge("duration_seconds", "Time it took connect-init to complete.", time.Since(m.start).Seconds()),
+		gauge("login_duration_seconds", "Time it took to log in to the auth method, including retries.", m.loginDuration.Seconds()),
+		gauge("login_failures", "Number of failed attempts to log in to the auth method.", float64(m.loginFailures)),
+		gauge("service_registration_retries", "Number of times the registration of the service was polled before it was found.", float64(m.registrationRetries)),
+	}
+	if m.leafCertDuration > 0 {
+		collectors = append(collectors,
+			gauge("leaf_cert_duration_seconds", "Time it took to get the leaf certificate of the service from the Consul agent.", m.leafCertDuration.Seconds()))
+	}
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, f := range families {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return common.WriteFileWithPerms(path, buf.String(), 0444)
+}
@@ -0,0 +1,84 @@
+package connectinit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitMetrics_Write(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		leafCertDuration time.Duration
+		success          bool
+	}{
+		"success": {
+			leafCertDuration: 250 * time.Millisecond,
+			success:          true,
+		},
+		"failure": {
+			success: false,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "connect-init-metrics.prom")
+			m := &initMetrics{
+				service:             "web",
+				start:               time.Now().Add(-3 * time.Second),
+				loginDuration:       2 * time.Second,
+				loginFailures:       1,
+				registrationRetries: 4,
+				leafCertDuration:    c.leafCertDuration,
+			}
+			require.NoError(t, m.write(path, c.success))
+
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(f)
+			require.NoError(t, err)
+
+			value := func(name string) float64 {
+				family, ok := families[name]
+				require.True(t, ok, "metric %s not found", name)
+				require.Len(t, family.Metric, 1)
+				require.Equal(t, "service", family.Metric[0].Label[0].GetName())
+				require.Equal(t, "web", family.Metric[0].Label[0].GetValue())
+				return family.Metric[0].GetGauge().GetValue()
+			}
+			if c.success {
+				require.Equal(t, 1.0, value("consul_k8s_connect_init_success"))
+				require.Equal(t, 0.25, value("consul_k8s_connect_init_leaf_cert_duration_seconds"))
+			} else {
+				require.Equal(t, 0.0, value("consul_k8s_connect_init_success"))
+				require.NotContains(t, families, "consul_k8s_connect_init_leaf_cert_duration_seconds")
+			}
+			require.GreaterOrEqual(t, value("consul_k8s_connect_init_duration_seconds"), 3.0)
+			require.Equal(t, 2.0, value("consul_k8s_connect_init_login_duration_seconds"))
+			require.Equal(t, 1.0, value("consul_k8s_connect_init_login_failures"))
+			require.Equal(t, 4.0, value("consul_k8s_connect_init_service_registration_retries"))
+		})
+	}
+}
+
+// Test that the metrics of a previous run of connect-init in a restarted
+// init container are overwritten.
+func TestInitMetrics_WriteOverwrites(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "connect-init-metrics.prom")
+	m := &initMetrics{service: "web", start: time.Now()}
+	require.NoError(t, m.write(path, false))
+	require.NoError(t, m.write(path, true))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `consul_k8s_connect_init_success{service="web"} 1`)
+}
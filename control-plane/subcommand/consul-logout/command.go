@@ -59,7 +59,7 @@ func (c *Command) Run(args []string) int {
 	}
 
 	if c.logger == nil {
-		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		c.logger, err = common.Logger("consul-logout", c.flagLogLevel, c.flagLogJSON)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 1
@@ -1,6 +1,7 @@
 package consulsidecar
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
@@ -47,8 +48,14 @@ type Command struct {
 	flagServiceMetricsPort   string
 	flagServiceMetricsPath   string
 
+	// Flags to configure the lifecycle metrics
+	flagEnableLifecycleMetrics bool
+	flagLifecycleMetricsPort   string
+	flagLifecycleMetricsDir    string
+
 	envoyMetricsGetter   metricsGetter
 	serviceMetricsGetter metricsGetter
+	lifecycleMetrics     *lifecycleMetrics
 
 	consulCommand []string
 
@@ -86,6 +93,15 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagMergedMetricsPort, "merged-metrics-port", "20100", "Port to serve merged Envoy and application metrics. Defaults to 20100.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPort, "service-metrics-port", "0", "Port where application metrics are being served. Defaults to 0.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPath, "service-metrics-path", "/metrics", "Path where application metrics are being served. Defaults to /metrics.")
+
+	// The lifecycle metrics are served on -lifecycle-metrics-port if it is
+	// set, and otherwise merged into the metrics of the merged metrics server.
+	c.flagSet.BoolVar(&c.flagEnableLifecycleMetrics, "enable-lifecycle-metrics", false,
+		"Enables consul sidecar to serve the metrics of connect-init and its own restarts. Defaults to false.")
+	c.flagSet.StringVar(&c.flagLifecycleMetricsPort, "lifecycle-metrics-port", "",
+		"Port to serve the lifecycle metrics on. If not set, they are served by the merged metrics server.")
+	c.flagSet.StringVar(&c.flagLifecycleMetricsDir, "lifecycle-metrics-dir", "/consul/connect-inject",
+		"Directory of the metrics files of connect-init. Defaults to /consul/connect-inject.")
 	c.help = flags.Usage(help, c.flagSet)
 	c.http = &flags.HTTPFlags{}
 	flags.Merge(c.flagSet, c.http.Flags())
@@ -117,7 +133,7 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	logger, err := common.Logger("consul-sidecar", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -134,8 +150,18 @@ func (c *Command) Run(args []string) int {
 		"merged-metrics-port", c.flagMergedMetricsPort,
 		"service-metrics-port", c.flagServiceMetricsPort,
 		"service-metrics-path", c.flagServiceMetricsPath,
+		"enable-lifecycle-metrics", c.flagEnableLifecycleMetrics,
+		"lifecycle-metrics-port", c.flagLifecycleMetricsPort,
 	)
 
+	if c.flagEnableLifecycleMetrics {
+		c.lifecycleMetrics, err = newLifecycleMetrics(c.flagLifecycleMetricsDir)
+		if err != nil {
+			c.logger.Error("Unable to set up lifecycle metrics", "err", err)
+			return 1
+		}
+	}
+
 	// signalCtx that we pass in to the main work loop, signal handling is handled in another thread
 	// due to the length of time it can take for the cmd to complete causing synchronization issues
 	// on shutdown. Also passing a context in so that it can interrupt the cmd and exit cleanly.
@@ -165,6 +191,22 @@ func (c *Command) Run(args []string) int {
 		}()
 	}
 
+	// If the lifecycle metrics have their own port, run a server for them as
+	// well. Unlike the merged metrics server, Prometheus scrapes it directly.
+	var lifecycleServer *http.Server
+	if c.flagEnableLifecycleMetrics && c.flagLifecycleMetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", c.lifecycleMetricsHandler)
+		lifecycleServer = &http.Server{Addr: fmt.Sprintf("0.0.0.0:%s", c.flagLifecycleMetricsPort), Handler: mux}
+
+		c.logger.Info("Running lifecycle metrics server.")
+		go func() {
+			if err := lifecycleServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				srvExitCh <- err
+			}
+		}()
+	}
+
 	// The work loop for re-registering the service. We continually re-register
 	// our service every syncPeriod. Consul is smart enough to know when the
 	// service hasn't changed and so won't update any indices. This means we
@@ -208,6 +250,10 @@ func (c *Command) Run(args []string) int {
 			c.logger.Info("Attempting to shut down metrics server.")
 			c.shutdownMetricsServer(server)
 		}
+		if lifecycleServer != nil {
+			c.logger.Info("Attempting to shut down lifecycle metrics server.")
+			c.shutdownMetricsServer(lifecycleServer)
+		}
 		return 0
 	case err := <-srvExitCh:
 		c.logger.Error(fmt.Sprintf("Metrics server error: %v", err))
@@ -298,6 +344,16 @@ func (c *Command) mergedMetricsHandler(rw http.ResponseWriter, _ *http.Request)
 	}
 	writeResponse(rw, envoyMetricsBody, "envoy metrics", c.logger)
 
+	// Merge the lifecycle metrics as well unless they have their own port.
+	if c.lifecycleMetrics != nil && c.flagLifecycleMetricsPort == "" {
+		var lifecycleMetricsBody bytes.Buffer
+		if err := c.lifecycleMetrics.write(&lifecycleMetricsBody); err != nil {
+			c.logger.Error("Error gathering lifecycle metrics", "err", err)
+		} else {
+			writeResponse(rw, lifecycleMetricsBody.Bytes(), "lifecycle metrics", c.logger)
+		}
+	}
+
 	serviceMetricsAddr := fmt.Sprintf("http://127.0.0.1:%s%s", c.flagServiceMetricsPort, c.flagServiceMetricsPath)
 	serviceMetrics, err := c.serviceMetricsGetter.Get(serviceMetricsAddr)
 	if err != nil {
@@ -342,8 +398,18 @@ func writeResponse(rw http.ResponseWriter, resp []byte, respName string, logger
 
 // validateFlags validates the flags.
 func (c *Command) validateFlags() error {
-	if !c.flagEnableServiceRegistration && !c.flagEnableMetricsMerging {
-		return errors.New("at least one of -enable-service-registration or -enable-metrics-merging must be true")
+	if !c.flagEnableServiceRegistration && !c.flagEnableMetricsMerging && !c.flagEnableLifecycleMetrics {
+		return errors.New("at least one of -enable-service-registration, -enable-metrics-merging or -enable-lifecycle-metrics must be true")
+	}
+	if c.flagEnableLifecycleMetrics {
+		if c.flagLifecycleMetricsPort == "" && !c.flagEnableMetricsMerging {
+			return errors.New("-lifecycle-metrics-port must be set if -enable-metrics-merging is false")
+		}
+		if c.flagLifecycleMetricsPort != "" {
+			if err := common.ValidateUnprivilegedPort("-lifecycle-metrics-port", c.flagLifecycleMetricsPort); err != nil {
+				return err
+			}
+		}
 	}
 	if c.flagEnableServiceRegistration {
 		if c.flagSyncPeriod == 0 {
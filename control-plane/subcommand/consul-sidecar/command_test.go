@@ -373,7 +373,22 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-enable-service-registration=false",
 				"-enable-metrics-merging=false",
 			},
-			ExpErr: " at least one of -enable-service-registration or -enable-metrics-merging must be true",
+			ExpErr: " at least one of -enable-service-registration, -enable-metrics-merging or -enable-lifecycle-metrics must be true",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-lifecycle-metrics=true",
+			},
+			ExpErr: "-lifecycle-metrics-port must be set if -enable-metrics-merging is false",
+		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-lifecycle-metrics=true",
+				"-lifecycle-metrics-port=80",
+			},
+			ExpErr: "-lifecycle-metrics-port value of 80 is not in the unprivileged port range 1024-65535",
 		},
 		{
 			Flags: []string{
@@ -0,0 +1,144 @@
+package consulsidecar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// connectInitMetricsGlob matches the files in the lifecycle metrics
+	// directory that the connect-init containers write their metrics to.
+	connectInitMetricsGlob = "connect-init-metrics*.prom"
+	// startsFile is the file in the lifecycle metrics directory that the
+	// consul-sidecar counts its starts in, so that its restarts can be counted
+	// across restarts of the container.
+	startsFile = "consul-sidecar-starts"
+)
+
+// lifecycleMetrics are the metrics of the lifecycle of the pod: the ones that
+// connect-init wrote to the shared volume of the pod before it exited, and
+// the number of restarts of the consul-sidecar.
+type lifecycleMetrics struct {
+	dir      string
+	registry *prometheus.Registry
+}
+
+// newLifecycleMetrics records the start of the consul-sidecar in dir and
+// returns the lifecycle metrics of the pod whose shared volume is dir.
+func newLifecycleMetrics(dir string) (*lifecycleMetrics, error) {
+	path := filepath.Join(dir, startsFile)
+	starts := 0
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if starts, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", path, err)
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(strconv.Itoa(starts+1)), 0644); err != nil {
+		return nil, err
+	}
+
+	restarts := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "consul_k8s",
+		Subsystem: "consul_sidecar",
+		Name:      "restarts_total",
+		Help:      "Number of times the consul-sidecar container was restarted.",
+	})
+	restarts.Add(float64(starts))
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(restarts); err != nil {
+		return nil, err
+	}
+	return &lifecycleMetrics{dir: dir, registry: registry}, nil
+}
+
+// gather returns the metric families of the consul-sidecar and of the
+// connect-init containers, whose metrics are merged into the same families
+// for multi port pods.
+func (m *lifecycleMetrics) gather() ([]*dto.MetricFamily, error) {
+	gathered, err := m.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	families := make(map[string]*dto.MetricFamily)
+	for _, f := range gathered {
+		families[f.GetName()] = f
+	}
+
+	files, err := filepath.Glob(filepath.Join(m.dir, connectInitMetricsGlob))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var parser expfmt.TextParser
+		parsed, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", file, err)
+		}
+		for name, f := range parsed {
+			if existing, ok := families[name]; ok {
+				existing.Metric = append(existing.Metric, f.Metric...)
+			} else {
+				families[name] = f
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		result = append(result, families[name])
+	}
+	return result, nil
+}
+
+// write writes the metrics to w in the Prometheus text format.
+func (m *lifecycleMetrics) write(w io.Writer) error {
+	families, err := m.gather()
+	if err != nil {
+		return err
+	}
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, f := range families {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lifecycleMetricsHandler serves the lifecycle metrics. It's used when they
+// aren't merged into the metrics of the merged metrics server.
+func (c *Command) lifecycleMetricsHandler(rw http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if err := c.lifecycleMetrics.write(&buf); err != nil {
+		c.logger.Error("Error gathering lifecycle metrics", "err", err)
+		http.Error(rw, fmt.Sprintf("Error gathering lifecycle metrics: %s", err), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", string(expfmt.FmtText))
+	writeResponse(rw, buf.Bytes(), "lifecycle metrics", c.logger)
+}
@@ -0,0 +1,143 @@
+package consulsidecar
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+const connectInitMetrics = `# HELP consul_k8s_connect_init_duration_seconds Time it took connect-init to complete.
+# TYPE consul_k8s_connect_init_duration_seconds gauge
+consul_k8s_connect_init_duration_seconds{service="%s"} 1.5
+`
+
+// Test that the restarts of the consul-sidecar are counted across the
+// restarts of the container.
+func TestLifecycleMetrics_Restarts(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	for i := 0; i < 3; i++ {
+		m, err := newLifecycleMetrics(dir)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, m.write(&buf))
+		require.Contains(t, buf.String(), fmt.Sprintf("consul_k8s_consul_sidecar_restarts_total %d\n", i))
+	}
+}
+
+// Test that the metrics of the connect-init containers of multi port pods
+// are merged into the same metric families.
+func TestLifecycleMetrics_ConnectInitMetrics(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, svc := range []string{"web", "web-admin"} {
+		file := filepath.Join(dir, fmt.Sprintf("connect-init-metrics-%s.prom", svc))
+		require.NoError(t, ioutil.WriteFile(file, []byte(fmt.Sprintf(connectInitMetrics, svc)), 0644))
+	}
+
+	m, err := newLifecycleMetrics(dir)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, m.write(&buf))
+	require.Equal(t, `# HELP consul_k8s_connect_init_duration_seconds Time it took connect-init to complete.
+# TYPE consul_k8s_connect_init_duration_seconds gauge
+consul_k8s_connect_init_duration_seconds{service="web-admin"} 1.5
+consul_k8s_connect_init_duration_seconds{service="web"} 1.5
+# HELP consul_k8s_consul_sidecar_restarts_total Number of times the consul-sidecar container was restarted.
+# TYPE consul_k8s_consul_sidecar_restarts_total counter
+consul_k8s_consul_sidecar_restarts_total 0
+`, buf.String())
+}
+
+func TestLifecycleMetrics_InvalidConnectInitMetrics(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "connect-init-metrics.prom"), []byte("not metrics"), 0644))
+
+	m, err := newLifecycleMetrics(dir)
+	require.NoError(t, err)
+	require.Error(t, m.write(&bytes.Buffer{}))
+}
+
+// Test that the lifecycle metrics are merged into the metrics of the merged
+// metrics server if they don't have their own port.
+func TestMergedMetricsServer_LifecycleMetrics(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "connect-init-metrics.prom"), []byte(fmt.Sprintf(connectInitMetrics, "web")), 0644))
+	lifecycleMetrics, err := newLifecycleMetrics(dir)
+	require.NoError(t, err)
+
+	randomPorts := freeport.GetN(t, 2)
+	cmd := Command{
+		UI:                       cli.NewMockUi(),
+		flagEnableMetricsMerging: true,
+		flagMergedMetricsPort:    fmt.Sprint(randomPorts[0]),
+		flagServiceMetricsPort:   fmt.Sprint(randomPorts[1]),
+		flagServiceMetricsPath:   "/metrics",
+		logger:                   hclog.Default(),
+		envoyMetricsGetter:       &mockEnvoyMetricsGetter{respStatusCode: 200},
+		serviceMetricsGetter:     &mockServiceMetricsGetter{respStatusCode: 200},
+		lifecycleMetrics:         lifecycleMetrics,
+	}
+	server := cmd.createMergedMetricsServer()
+	go func() {
+		_ = server.ListenAndServe()
+	}()
+	defer server.Close()
+
+	retry.Run(t, func(r *retry.R) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/stats/prometheus", randomPorts[0]))
+		require.NoError(r, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(r, err)
+		require.True(r, strings.HasPrefix(string(body), "envoy metrics\n"), string(body))
+		require.Contains(r, string(body), `consul_k8s_connect_init_duration_seconds{service="web"} 1.5`)
+		require.Contains(r, string(body), "consul_k8s_consul_sidecar_restarts_total 0")
+		require.True(r, strings.HasSuffix(string(body), "service metrics\nconsul_merged_service_metrics_success 1\n"), string(body))
+	})
+}
+
+// Test that the lifecycle metrics are served on -lifecycle-metrics-port.
+func TestRun_LifecycleMetricsPort(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "connect-init-metrics.prom"), []byte(fmt.Sprintf(connectInitMetrics, "web")), 0644))
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui}
+	randomPorts := freeport.GetN(t, 1)
+	exitChan := runCommandAsynchronously(&cmd, []string{
+		"-enable-service-registration=false",
+		"-enable-lifecycle-metrics=true",
+		"-lifecycle-metrics-port", fmt.Sprint(randomPorts[0]),
+		"-lifecycle-metrics-dir", dir,
+	})
+
+	retry.Run(t, func(r *retry.R) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", randomPorts[0]))
+		require.NoError(r, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(r, err)
+		require.Equal(r, http.StatusOK, resp.StatusCode)
+		require.Contains(r, string(body), `consul_k8s_connect_init_duration_seconds{service="web"} 1.5`)
+	})
+
+	cmd.interrupt()
+	select {
+	case exitCode := <-exitChan:
+		require.Equal(t, 0, exitCode, ui.ErrorWriter.String())
+	case <-time.After(metricsServerShutdownTimeout + 100*time.Millisecond):
+		require.Fail(t, "timeout waiting for command to exit")
+	}
+}
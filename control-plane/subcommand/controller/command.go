@@ -1,15 +1,26 @@
 package controller
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
 	"github.com/hashicorp/consul-k8s/control-plane/controller"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/health"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/sharding"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tlsconfig"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tracing"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/webhookaudit"
 	cmdCommon "github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
@@ -17,10 +28,12 @@ import (
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 type Command struct {
@@ -29,12 +42,17 @@ type Command struct {
 	flagSet   *flag.FlagSet
 	httpFlags *flags.HTTPFlags
 
-	flagWebhookTLSCertDir    string
-	flagEnableLeaderElection bool
-	flagEnableWebhooks       bool
-	flagDatacenter           string
-	flagLogLevel             string
-	flagLogJSON              bool
+	flagWebhookTLSCertDir                  string
+	flagEnableLeaderElection               bool
+	flagEnableWebhooks                     bool
+	flagEnableWebhookConsulStateValidation bool
+	flagDatacenter                         string
+	flagLogLevel                           string
+	flagLogJSON                            bool
+	flagTLSMinVersion                      string
+	flagTLSCipherSuites                    string
+	flagWebhookAuditLog                    string
+	flagTracingOTLPAddress                 string
 
 	// Flags to support Consul Enterprise namespaces.
 	flagEnableNamespaces           bool
@@ -43,6 +61,16 @@ type Command struct {
 	flagNSMirroringPrefix          string
 	flagCrossNSACLPolicy           string
 
+	flagTerminatingGatewayACLRolePrefix string
+	flagEnablePeering                   bool
+
+	// Flags to share the work among the replicas.
+	flagShards              int
+	flagShardLeaseName      string
+	flagShardLeaseNamespace string
+
+	tlsConfig tlsconfig.Config
+
 	once sync.Once
 	help string
 }
@@ -77,10 +105,40 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagCrossNSACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
+	c.flagSet.StringVar(&c.flagTerminatingGatewayACLRolePrefix, "terminating-gateway-acl-role-prefix", "",
+		"Prefix of the ACL roles created for terminating gateways by server-acl-init. If set, the ACL role of "+
+			"a terminating gateway is given service:write on the external services of its TerminatingGateway resource. "+
+			"Only necessary if ACLs are enabled.")
+	c.flagSet.IntVar(&c.flagShards, "shards", 0,
+		"Number of shards that the resources are split into, by their namespace, among the replicas. "+
+			"If it's greater than 0, all the replicas reconcile the resources of the shards that they own "+
+			"instead of only the leader. It must be the same on all the replicas.")
+	c.flagSet.StringVar(&c.flagShardLeaseName, "shard-lease-name", "consul-controller",
+		"Prefix of the names of the Leases of the shards and replicas if -shards is set.")
+	c.flagSet.StringVar(&c.flagShardLeaseNamespace, "shard-lease-namespace", "default",
+		"Namespace of the Leases of the shards and replicas if -shards is set.")
+	c.flagSet.BoolVar(&c.flagEnablePeering, "enable-peering", false,
+		"Enable the PeeringConnection controller, which generates peering tokens, establishes cluster peerings "+
+			"and exports services to the peers. Requires Consul v1.13+.")
 	c.flagSet.StringVar(&c.flagWebhookTLSCertDir, "webhook-tls-cert-dir", "",
 		"Directory that contains the TLS cert and key required for the webhook. The cert and key files must be named 'tls.crt' and 'tls.key' respectively.")
 	c.flagSet.BoolVar(&c.flagEnableWebhooks, "enable-webhooks", true,
 		"Enable webhooks. Disable when running locally since Kube API server won't be able to route to local server.")
+	c.flagSet.StringVar(&c.flagTLSMinVersion, "tls-min-version", "",
+		"Minimum TLS version of the webhook server, and the minimum TLS version that Mesh and IngressGateway "+
+			"resources are defaulted to and validated against. One of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3.")
+	c.flagSet.StringVar(&c.flagTLSCipherSuites, "tls-cipher-suites", "",
+		"Comma-separated list of the IANA names of the TLS cipher suites of the webhook server, and of the cipher "+
+			"suites that Mesh and IngressGateway resources are defaulted to and validated against. Can't be set with TLSv1_3.")
+	c.flagSet.BoolVar(&c.flagEnableWebhookConsulStateValidation, "enable-webhook-consul-state-validation", false,
+		"Enable validating resources against the config entries in Consul in the webhooks, e.g. rejecting a "+
+			"ServiceRouter for a service whose protocol isn't an L7 protocol.")
+	c.flagSet.StringVar(&c.flagWebhookAuditLog, "webhook-audit-log", "",
+		fmt.Sprintf("If set, an audit record of every admission decision of the webhooks is written to this file, "+
+			"or to stdout if it is %q.", webhookaudit.Stdout))
+	c.flagSet.StringVar(&c.flagTracingOTLPAddress, "tracing-otlp-address", "",
+		"Address of an OTLP gRPC receiver, in the form <host>:<port>, that the spans of the reconciles of the config entry controllers are exported to, "+
+			"e.g. the OTLP receiver of an OpenTelemetry Collector.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
 		fmt.Sprintf("Log verbosity level. Supported values (in order of detail) are "+
 			"%q, %q, %q, and %q.", zapcore.DebugLevel.String(), zapcore.InfoLevel.String(), zapcore.WarnLevel.String(), zapcore.ErrorLevel.String()))
@@ -104,7 +162,7 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	zapLogger, err := cmdCommon.ZapLogger(c.flagLogLevel, c.flagLogJSON)
+	zapLogger, err := cmdCommon.ZapLogger("controller", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error setting up logging: %s", err.Error()))
 		return 1
@@ -112,18 +170,60 @@ func (c *Command) Run(args []string) int {
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:           scheme,
-		Port:             9443,
-		LeaderElection:   c.flagEnableLeaderElection,
-		LeaderElectionID: "consul.hashicorp.com",
-		Logger:           zapLogger,
+	if c.flagTracingOTLPAddress != "" {
+		shutdownTracing, err := tracing.Setup(context.Background(), "consul-k8s-controller", c.flagTracingOTLPAddress)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			return 1
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), tracing.ShutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				setupLog.Error(err, "unable to export the remaining spans")
+			}
+		}()
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                 scheme,
+		LeaderElection:         c.flagEnableLeaderElection,
+		LeaderElectionID:       "consul.hashicorp.com",
+		Logger:                 zapLogger,
+		HealthProbeBindAddress: "0.0.0.0:9445",
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		return 1
 	}
 
+	var shards *sharding.Coordinator
+	if c.flagShards > 0 {
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to create Kubernetes client")
+			return 1
+		}
+		identity, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get the identity of the replica")
+			return 1
+		}
+		shards = &sharding.Coordinator{
+			Clientset: clientset,
+			Namespace: c.flagShardLeaseNamespace,
+			Name:      c.flagShardLeaseName,
+			Identity:  identity,
+			Shards:    c.flagShards,
+			Log:       ctrl.Log.WithName("sharding"),
+		}
+		if err := mgr.Add(shards); err != nil {
+			setupLog.Error(err, "unable to add the shard coordinator to manager")
+			return 1
+		}
+	}
+
 	cfg := api.DefaultConfig()
 	c.httpFlags.MergeOntoConfig(cfg)
 	consulClient, err := consul.NewClient(cfg, c.httpFlags.ConsulAPITimeout())
@@ -131,6 +231,10 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "connecting to Consul agent")
 		return 1
 	}
+	if err := health.AddToManager(mgr, consulClient, cfg); err != nil {
+		setupLog.Error(err, "unable to create health checks")
+		return 1
+	}
 
 	partitionsEnabled := c.httpFlags.Partition() != ""
 	consulMeta := common.ConsulMeta{
@@ -140,6 +244,12 @@ func (c *Command) Run(args []string) int {
 		DestinationNamespace: c.flagConsulDestinationNamespace,
 		Mirroring:            c.flagEnableNSMirroring,
 		Prefix:               c.flagNSMirroringPrefix,
+		TLSMinVersion:        c.flagTLSMinVersion,
+	}
+	if c.flagTLSCipherSuites != "" {
+		for _, suite := range strings.Split(c.flagTLSCipherSuites, ",") {
+			consulMeta.TLSCipherSuites = append(consulMeta.TLSCipherSuites, strings.TrimSpace(suite))
+		}
 	}
 
 	configEntryReconciler := &controller.ConfigEntryController{
@@ -150,6 +260,8 @@ func (c *Command) Run(args []string) int {
 		EnableNSMirroring:          c.flagEnableNSMirroring,
 		NSMirroringPrefix:          c.flagNSMirroringPrefix,
 		CrossNSACLPolicy:           c.flagCrossNSACLPolicy,
+		Recorder:                   mgr.GetEventRecorderFor("consul-controller"),
+		Shards:                     shards,
 	}
 	if err = (&controller.ServiceDefaultsController{
 		ConfigEntryController: configEntryReconciler,
@@ -196,6 +308,33 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "unable to create controller", "controller", common.ExportedServices)
 		return 1
 	}
+	if err = (&controller.SamenessGroupController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.SamenessGroup),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.SamenessGroup)
+		return 1
+	}
+	if err = (&controller.JWTProviderController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.JWTProvider),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.JWTProvider)
+		return 1
+	}
+	if err = (&controller.ControlPlaneRequestLimitController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.ControlPlaneRequestLimit),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.ControlPlaneRequestLimit)
+		return 1
+	}
 	if err = (&controller.ServiceRouterController{
 		ConfigEntryController: configEntryReconciler,
 		Client:                mgr.GetClient(),
@@ -237,87 +376,157 @@ func (c *Command) Run(args []string) int {
 		Client:                mgr.GetClient(),
 		Log:                   ctrl.Log.WithName("controller").WithName(common.TerminatingGateway),
 		Scheme:                mgr.GetScheme(),
+		ACLRolePrefix:         c.flagTerminatingGatewayACLRolePrefix,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", common.TerminatingGateway)
 		return 1
 	}
+	if c.flagEnablePeering {
+		if err = (&controller.PeeringConnectionController{
+			Client:       mgr.GetClient(),
+			APIReader:    mgr.GetAPIReader(),
+			Log:          ctrl.Log.WithName("controller").WithName(common.PeeringConnection),
+			Scheme:       mgr.GetScheme(),
+			ConsulClient: consulClient,
+			Partition:    c.httpFlags.Partition(),
+			Shards:       shards,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", common.PeeringConnection)
+			return 1
+		}
+	}
 
 	if c.flagEnableWebhooks {
 		// This webhook server sets up a Cert Watcher on the CertDir. This watches for file changes and updates the webhook certificates
 		// automatically when new certificates are available.
-		mgr.GetWebhookServer().CertDir = c.flagWebhookTLSCertDir
+		hookServer := &webhook.Server{
+			Port:    9443,
+			CertDir: c.flagWebhookTLSCertDir,
+		}
+
+		var auditSink io.Writer
+		if c.flagWebhookAuditLog != "" {
+			auditSink, err = webhookaudit.OpenSink(c.flagWebhookAuditLog)
+			if err != nil {
+				setupLog.Error(err, "unable to open the webhook audit log")
+				return 1
+			}
+		}
+		// audit records the decisions of the webhook of the given kind if
+		// -webhook-audit-log is set.
+		audit := func(kind string, handler admission.Handler) admission.Handler {
+			if auditSink == nil {
+				return handler
+			}
+			return &webhookaudit.Handler{
+				Handler: handler,
+				Webhook: kind,
+				Sink:    auditSink,
+				Log:     ctrl.Log.WithName("webhooks").WithName("audit"),
+			}
+		}
 
 		// Note: The path here should be identical to the one on the kubebuilder
 		// annotation in each webhook file.
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicedefaults",
-			&webhook.Admission{Handler: &v1alpha1.ServiceDefaultsWebhook{
-				Client:       mgr.GetClient(),
-				ConsulClient: consulClient,
-				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ServiceDefaults),
-				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-serviceresolver",
-			&webhook.Admission{Handler: &v1alpha1.ServiceResolverWebhook{
+		hookServer.Register("/mutate-v1alpha1-servicedefaults",
+			&webhook.Admission{Handler: audit(common.ServiceDefaults, &v1alpha1.ServiceDefaultsWebhook{
+				Client:                      mgr.GetClient(),
+				ConsulClient:                consulClient,
+				Logger:                      ctrl.Log.WithName("webhooks").WithName(common.ServiceDefaults),
+				ConsulMeta:                  consulMeta,
+				EnableConsulStateValidation: c.flagEnableWebhookConsulStateValidation,
+			})})
+		hookServer.Register("/mutate-v1alpha1-serviceresolver",
+			&webhook.Admission{Handler: audit(common.ServiceResolver, &v1alpha1.ServiceResolverWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ServiceResolver),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-proxydefaults",
-			&webhook.Admission{Handler: &v1alpha1.ProxyDefaultsWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-proxydefaults",
+			&webhook.Admission{Handler: audit(common.ProxyDefaults, &v1alpha1.ProxyDefaultsWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ProxyDefaults),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-mesh",
-			&webhook.Admission{Handler: &v1alpha1.MeshWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-mesh",
+			&webhook.Admission{Handler: audit(common.Mesh, &v1alpha1.MeshWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.Mesh),
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-exportedservices",
-			&webhook.Admission{Handler: &v1alpha1.ExportedServicesWebhook{
+				ConsulMeta:   consulMeta,
+			})})
+		hookServer.Register("/mutate-v1alpha1-exportedservices",
+			&webhook.Admission{Handler: audit(common.ExportedServices, &v1alpha1.ExportedServicesWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ExportedServices),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicerouter",
-			&webhook.Admission{Handler: &v1alpha1.ServiceRouterWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-samenessgroup",
+			&webhook.Admission{Handler: audit(common.SamenessGroup, &v1alpha1.SamenessGroupWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
-				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ServiceRouter),
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.SamenessGroup),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-servicesplitter",
-			&webhook.Admission{Handler: &v1alpha1.ServiceSplitterWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-jwtprovider",
+			&webhook.Admission{Handler: audit(common.JWTProvider, &v1alpha1.JWTProviderWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
-				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ServiceSplitter),
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.JWTProvider),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-serviceintentions",
-			&webhook.Admission{Handler: &v1alpha1.ServiceIntentionsWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-controlplanerequestlimit",
+			&webhook.Admission{Handler: audit(common.ControlPlaneRequestLimit, &v1alpha1.ControlPlaneRequestLimitWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
-				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ServiceIntentions),
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.ControlPlaneRequestLimit),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-ingressgateway",
-			&webhook.Admission{Handler: &v1alpha1.IngressGatewayWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-servicerouter",
+			&webhook.Admission{Handler: audit(common.ServiceRouter, &v1alpha1.ServiceRouterWebhook{
+				Client:                      mgr.GetClient(),
+				ConsulClient:                consulClient,
+				Logger:                      ctrl.Log.WithName("webhooks").WithName(common.ServiceRouter),
+				ConsulMeta:                  consulMeta,
+				EnableConsulStateValidation: c.flagEnableWebhookConsulStateValidation,
+			})})
+		hookServer.Register("/mutate-v1alpha1-servicesplitter",
+			&webhook.Admission{Handler: audit(common.ServiceSplitter, &v1alpha1.ServiceSplitterWebhook{
+				Client:                      mgr.GetClient(),
+				ConsulClient:                consulClient,
+				Logger:                      ctrl.Log.WithName("webhooks").WithName(common.ServiceSplitter),
+				ConsulMeta:                  consulMeta,
+				EnableConsulStateValidation: c.flagEnableWebhookConsulStateValidation,
+			})})
+		hookServer.Register("/mutate-v1alpha1-serviceintentions",
+			&webhook.Admission{Handler: audit(common.ServiceIntentions, &v1alpha1.ServiceIntentionsWebhook{
+				Client:                      mgr.GetClient(),
+				ConsulClient:                consulClient,
+				Logger:                      ctrl.Log.WithName("webhooks").WithName(common.ServiceIntentions),
+				ConsulMeta:                  consulMeta,
+				EnableConsulStateValidation: c.flagEnableWebhookConsulStateValidation,
+			})})
+		hookServer.Register("/mutate-v1alpha1-ingressgateway",
+			&webhook.Admission{Handler: audit(common.IngressGateway, &v1alpha1.IngressGatewayWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.IngressGateway),
 				ConsulMeta:   consulMeta,
-			}})
-		mgr.GetWebhookServer().Register("/mutate-v1alpha1-terminatinggateway",
-			&webhook.Admission{Handler: &v1alpha1.TerminatingGatewayWebhook{
+			})})
+		hookServer.Register("/mutate-v1alpha1-terminatinggateway",
+			&webhook.Admission{Handler: audit(common.TerminatingGateway, &v1alpha1.TerminatingGatewayWebhook{
 				Client:       mgr.GetClient(),
 				ConsulClient: consulClient,
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.TerminatingGateway),
 				ConsulMeta:   consulMeta,
-			}})
+			})})
+		if err := mgr.Add(&tlsconfig.WebhookServer{Server: hookServer, Config: c.tlsConfig}); err != nil {
+			setupLog.Error(err, "unable to add webhook server to manager")
+			return 1
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -342,6 +551,27 @@ func (c *Command) validateFlags() error {
 	if c.httpFlags.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+	if c.flagTracingOTLPAddress != "" {
+		_, port, err := net.SplitHostPort(c.flagTracingOTLPAddress)
+		if err == nil {
+			_, err = strconv.Atoi(port)
+		}
+		if err != nil {
+			return fmt.Errorf("Invalid arguments: -tracing-otlp-address must be of the form <host>:<port>: %s", err)
+		}
+	}
+
+	if c.flagShards < 0 {
+		return errors.New("Invalid arguments: -shards must not be negative")
+	}
+	if c.flagShards > 0 && c.flagEnableLeaderElection {
+		return errors.New("Invalid arguments: -enable-leader-election can't be set with -shards")
+	}
+	tlsConfig, err := tlsconfig.Parse(c.flagTLSMinVersion, c.flagTLSCipherSuites)
+	if err != nil {
+		return fmt.Errorf("Invalid arguments: %w", err)
+	}
+	c.tlsConfig = tlsConfig
 
 	return nil
 }
@@ -35,6 +35,31 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-consul-api-timeout", "5s", "-log-level", "invalid"},
 			expErr: `unknown log level "invalid": unrecognized level: "invalid"`,
 		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo",
+				"-consul-api-timeout", "5s", "-tls-min-version", "1.2"},
+			expErr: `invalid TLS version "1.2"`,
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo",
+				"-consul-api-timeout", "5s", "-tls-min-version", "TLSv1_3", "-tls-cipher-suites", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			expErr: "cipher suites can't be configured for TLS 1.3",
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo",
+				"-consul-api-timeout", "5s", "-tracing-otlp-address", "otel-collector"},
+			expErr: "-tracing-otlp-address must be of the form <host>:<port>",
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo",
+				"-consul-api-timeout", "5s", "-shards", "-1"},
+			expErr: "-shards must not be negative",
+		},
+		{
+			flags: []string{"-webhook-tls-cert-dir", "/foo", "-datacenter", "foo",
+				"-consul-api-timeout", "5s", "-shards", "16", "-enable-leader-election"},
+			expErr: "-enable-leader-election can't be set with -shards",
+		},
 	}
 
 	for _, c := range cases {
@@ -7,8 +7,13 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -62,12 +67,23 @@ type Command struct {
 	flagLogJSON                bool
 	flagMeshGatewayServiceName string
 
-	k8sClient    kubernetes.Interface
-	consulClient *api.Client
+	// Flags to keep the secret up to date and export it to other Kubernetes
+	// clusters.
+	flagSyncInterval          time.Duration
+	flagExportKubeconfigFiles []string
+	flagExportNamespace       string
 
-	once sync.Once
-	help string
-	ctx  context.Context
+	k8sClient        kubernetes.Interface
+	exportK8sClients []kubernetes.Interface
+	consulClient     *api.Client
+
+	// datacenter is the name of the primary datacenter.
+	datacenter string
+
+	once  sync.Once
+	help  string
+	ctx   context.Context
+	sigCh chan os.Signal
 }
 
 func (c *Command) init() {
@@ -88,6 +104,14 @@ func (c *Command) init() {
 		"Name of Kubernetes namespace where Consul is deployed.")
 	c.flags.StringVar(&c.flagMeshGatewayServiceName, "mesh-gateway-service-name", "",
 		"Name of the mesh gateway service registered into Consul.")
+	c.flags.DurationVar(&c.flagSyncInterval, "sync-interval", 0,
+		"If set, the command keeps running and updates the secret with this interval when the data it's "+
+			"created from changes, e.g. after the CA or gossip encryption key are rotated.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExportKubeconfigFiles), "export-kubeconfig-file",
+		"Path to a kubeconfig file of a Kubernetes cluster of a secondary datacenter that the secret is also "+
+			"created and updated in. May be specified multiple times.")
+	c.flags.StringVar(&c.flagExportNamespace, "export-namespace", "",
+		"Name of the Kubernetes namespace that the secret is exported to. Defaults to -k8s-namespace.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -99,11 +123,20 @@ func (c *Command) init() {
 	flags.Merge(c.flags, c.http.Flags())
 	flags.Merge(c.flags, c.k8s.Flags())
 	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
 }
 
 // Run creates a Kubernetes secret with data needed by secondary datacenters
 // in order to federate with the primary. It's assumed this is running in the
-// primary datacenter.
+// primary datacenter. If -sync-interval is set, it keeps the secret up to date
+// with its sources, e.g. after the CA or gossip encryption key is rotated.
 func (c *Command) Run(args []string) int {
 	c.once.Do(c.init)
 
@@ -112,7 +145,7 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	logger, err := common.Logger("create-federation-secret", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -122,53 +155,12 @@ func (c *Command) Run(args []string) int {
 		c.ctx = context.Background()
 	}
 
-	// The initial secret struct. We will be filling in its data map
-	// as we continue.
-	federationSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-federation", c.flagResourcePrefix),
-			Namespace: c.flagK8sNamespace,
-			Labels:    map[string]string{common.CLILabelKey: common.CLILabelValue},
-		},
-		Type: "Opaque",
-		Data: make(map[string][]byte),
-	}
-
-	// Add gossip encryption key if it exists.
-	if c.flagGossipKeyFile != "" {
-		logger.Info("Retrieving gossip encryption key data")
-		gossipKey, err := ioutil.ReadFile(c.flagGossipKeyFile)
-		if err != nil {
-			c.UI.Error(fmt.Sprintf("Error reading gossip encryption key file: %s", err))
-			return 1
-		}
-		if len(gossipKey) == 0 {
-			c.UI.Error(fmt.Sprintf("gossip key file %q was empty", c.flagGossipKeyFile))
-			return 1
-		}
-		federationSecret.Data[fedSecretGossipKey] = gossipKey
-		logger.Info("Gossip encryption key retrieved successfully")
-	}
-
-	// Add server CA cert.
-	logger.Info("Retrieving server CA cert data")
-	caCert, err := ioutil.ReadFile(c.flagServerCACertFile)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error reading server CA cert file: %s", err))
-		return 1
-	}
-	federationSecret.Data[fedSecretCACertKey] = caCert
-	logger.Info("Server CA cert retrieved successfully")
-
-	// Add server CA key.
-	logger.Info("Retrieving server CA key data")
-	caKey, err := ioutil.ReadFile(c.flagServerCAKeyFile)
+	// The data of the secret. We will be filling it in as we continue.
+	data, err := c.fileData(logger)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error reading server CA key file: %s", err))
+		c.UI.Error(err.Error())
 		return 1
 	}
-	federationSecret.Data[fedSecretCAKeyKey] = caKey
-	logger.Info("Server CA key retrieved successfully")
 
 	// Create the Kubernetes clientset.
 	if c.k8sClient == nil {
@@ -184,6 +176,23 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	// Create the clientsets of the Kubernetes clusters the secret is exported to.
+	if c.exportK8sClients == nil {
+		for _, kubeconfig := range c.flagExportKubeconfigFiles {
+			k8sCfg, err := subcommand.K8SConfig(kubeconfig)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth from %q: %s", kubeconfig, err))
+				return 1
+			}
+			client, err := kubernetes.NewForConfig(k8sCfg)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client for %q: %s", kubeconfig, err))
+				return 1
+			}
+			c.exportK8sClients = append(c.exportK8sClients, client)
+		}
+	}
+
 	// Add replication token.
 	var replicationToken []byte
 	if c.flagExportReplicationToken {
@@ -193,7 +202,7 @@ func (c *Command) Run(args []string) int {
 			logger.Error("error retrieving replication token", "err", err)
 			return 1
 		}
-		federationSecret.Data[fedSecretReplicationTokenKey] = replicationToken
+		data[fedSecretReplicationTokenKey] = replicationToken
 	}
 
 	// Set up Consul client because we need to make calls to Consul to retrieve
@@ -221,41 +230,183 @@ func (c *Command) Run(args []string) int {
 	// Get the datacenter's name. We assume this is the primary datacenter
 	// because users should only be running this in the primary datacenter.
 	logger.Info("Retrieving datacenter name from Consul")
-	datacenter := c.consulDatacenter(logger)
+	c.datacenter = c.consulDatacenter(logger)
 	logger.Info("Successfully retrieved datacenter name")
 
+	if err := c.addServerCfg(logger, data); err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+
+	// Now create the Kubernetes secrets.
+	if err := c.writeSecrets(logger, data); err != nil {
+		logger.Error("Error creating/updating federation secret", "err", err)
+		return 1
+	}
+	if c.flagSyncInterval == 0 {
+		return 0
+	}
+
+	// Keep the secrets up to date until we're shut down.
+	ticker := time.NewTicker(c.flagSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+
+		data, err := c.secretData(logger)
+		if err != nil {
+			logger.Error("Error retrieving federation secret data", "err", err)
+			continue
+		}
+		if err := c.writeSecrets(logger, data); err != nil {
+			logger.Error("Error creating/updating federation secret", "err", err)
+		}
+	}
+}
+
+// secretData returns the current data of the federation secret.
+func (c *Command) secretData(logger hclog.Logger) (map[string][]byte, error) {
+	data, err := c.fileData(logger)
+	if err != nil {
+		return nil, err
+	}
+	if c.flagExportReplicationToken {
+		replicationToken, err := c.replicationToken(logger)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving replication token: %s", err)
+		}
+		data[fedSecretReplicationTokenKey] = replicationToken
+	}
+	if err := c.addServerCfg(logger, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fileData returns the data of the federation secret that is read from files:
+// the gossip encryption key if it exists and the server CA cert and key.
+func (c *Command) fileData(logger hclog.Logger) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+
+	// Add gossip encryption key if it exists.
+	if c.flagGossipKeyFile != "" {
+		logger.Info("Retrieving gossip encryption key data")
+		gossipKey, err := ioutil.ReadFile(c.flagGossipKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading gossip encryption key file: %s", err)
+		}
+		if len(gossipKey) == 0 {
+			return nil, fmt.Errorf("gossip key file %q was empty", c.flagGossipKeyFile)
+		}
+		data[fedSecretGossipKey] = gossipKey
+		logger.Info("Gossip encryption key retrieved successfully")
+	}
+
+	// Add server CA cert.
+	logger.Info("Retrieving server CA cert data")
+	caCert, err := ioutil.ReadFile(c.flagServerCACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading server CA cert file: %s", err)
+	}
+	data[fedSecretCACertKey] = caCert
+	logger.Info("Server CA cert retrieved successfully")
+
+	// Add server CA key.
+	logger.Info("Retrieving server CA key data")
+	caKey, err := ioutil.ReadFile(c.flagServerCAKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading server CA key file: %s", err)
+	}
+	data[fedSecretCAKeyKey] = caKey
+	logger.Info("Server CA key retrieved successfully")
+	return data, nil
+}
+
+// addServerCfg adds the server config, which contains the datacenter and
+// mesh gateway addresses, to data.
+func (c *Command) addServerCfg(logger hclog.Logger, data map[string][]byte) error {
 	// Get the mesh gateway addresses.
 	logger.Info("Retrieving mesh gateway addresses from Consul")
 	meshGWAddrs, err := c.meshGatewayAddrs(logger)
 	if err != nil {
-		logger.Error("Error looking up mesh gateways", "err", err)
-		return 1
+		return fmt.Errorf("Error looking up mesh gateways: %s", err)
 	}
 	logger.Info("Found mesh gateway addresses", "addrs", strings.Join(meshGWAddrs, ","))
 
 	// Generate a JSON config from the datacenter and mesh gateway addresses
 	// that can be set as a config file by Consul servers in secondary datacenters.
-	serverCfg, err := c.serverCfg(datacenter, meshGWAddrs)
+	serverCfg, err := c.serverCfg(c.datacenter, meshGWAddrs)
 	if err != nil {
-		logger.Error("Unable to create server config json", "err", err)
-		return 1
+		return fmt.Errorf("Unable to create server config json: %s", err)
 	}
-	federationSecret.Data[fedSecretServerConfigKey] = serverCfg
+	data[fedSecretServerConfigKey] = serverCfg
+	return nil
+}
 
-	// Now create the Kubernetes secret.
-	logger.Info("Creating/updating Kubernetes secret", "name", federationSecret.ObjectMeta.Name, "ns", c.flagK8sNamespace)
-	_, err = c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Create(c.ctx, federationSecret, metav1.CreateOptions{})
-	if k8serrors.IsAlreadyExists(err) {
-		logger.Info("Secret already exists, updating instead")
-		_, err = c.k8sClient.CoreV1().Secrets(c.flagK8sNamespace).Update(c.ctx, federationSecret, metav1.UpdateOptions{})
+// writeSecrets creates or updates the federation secret in this Kubernetes
+// cluster and in the clusters it's exported to.
+func (c *Command) writeSecrets(logger hclog.Logger, data map[string][]byte) error {
+	if err := c.writeSecret(logger, c.k8sClient, c.flagK8sNamespace, data); err != nil {
+		return err
+	}
+	exportNamespace := c.flagExportNamespace
+	if exportNamespace == "" {
+		exportNamespace = c.flagK8sNamespace
+	}
+	for i, client := range c.exportK8sClients {
+		if err := c.writeSecret(logger, client, exportNamespace, data); err != nil {
+			return fmt.Errorf("exporting secret to %s: %s", c.exportName(i), err)
+		}
+	}
+	return nil
+}
+
+// writeSecret creates or updates the federation secret in namespace unless
+// it already has this data.
+func (c *Command) writeSecret(logger hclog.Logger, client kubernetes.Interface, namespace string, data map[string][]byte) error {
+	federationSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-federation", c.flagResourcePrefix),
+			Namespace: namespace,
+			Labels:    map[string]string{common.CLILabelKey: common.CLILabelValue},
+		},
+		Type: "Opaque",
+		Data: data,
 	}
 
+	existing, err := client.CoreV1().Secrets(namespace).Get(c.ctx, federationSecret.Name, metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		logger.Info("Creating Kubernetes secret", "name", federationSecret.Name, "ns", namespace)
+		_, err = client.CoreV1().Secrets(namespace).Create(c.ctx, federationSecret, metav1.CreateOptions{})
+	case err != nil:
+		return err
+	case reflect.DeepEqual(existing.Data, data):
+		logger.Info("Secret is up to date", "name", federationSecret.Name, "ns", namespace)
+		return nil
+	default:
+		logger.Info("Secret already exists, updating instead")
+		_, err = client.CoreV1().Secrets(namespace).Update(c.ctx, federationSecret, metav1.UpdateOptions{})
+	}
 	if err != nil {
-		logger.Error("Error creating/updating federation secret", "err", err)
-		return 1
+		return err
+	}
+	logger.Info("Successfully created/updated federation secret", "name", federationSecret.Name, "ns", namespace)
+	return nil
+}
+
+// exportName returns a name for the i-th cluster the secret is exported to
+// for errors.
+func (c *Command) exportName(i int) string {
+	if i < len(c.flagExportKubeconfigFiles) {
+		return fmt.Sprintf("%q", c.flagExportKubeconfigFiles[i])
 	}
-	logger.Info("Successfully created/updated federation secret", "name", federationSecret.ObjectMeta.Name, "ns", c.flagK8sNamespace)
-	return 0
+	return fmt.Sprintf("cluster %d", i)
 }
 
 func (c *Command) validateFlags(args []string) error {
@@ -286,6 +437,9 @@ func (c *Command) validateFlags(args []string) error {
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+	if c.flagSyncInterval < 0 {
+		return errors.New("-sync-interval must not be negative")
+	}
 	return nil
 }
 
@@ -368,6 +522,8 @@ func (c *Command) meshGatewayAddrs(logger hclog.Logger) ([]string, error) {
 	for addr := range meshGatewayAddrs {
 		uniqMeshGatewayAddrs = append(uniqMeshGatewayAddrs, addr)
 	}
+	// Sort the addresses so that the server config only changes when they do.
+	sort.Strings(uniqMeshGatewayAddrs)
 	return uniqMeshGatewayAddrs, nil
 }
 
@@ -453,4 +609,9 @@ Usage: consul-k8s-control-plane create-federation-secret [options]
   datacenter to federate with the primary. This command should only be run in the
   primary datacenter.
 
+  If -sync-interval is set, the command keeps running and updates the secret when
+  its data changes, e.g. after the CA is rotated. With -export-kubeconfig-file,
+  the secret is also created and updated in the Kubernetes clusters of secondary
+  datacenters so that they pick up the changes.
+
 `
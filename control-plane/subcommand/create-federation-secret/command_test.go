@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -70,6 +71,19 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			expErr: "-consul-api-timeout must be set to a value greater than 0",
 		},
+		{
+			flags: []string{
+				"-resource-prefix=prefix",
+				"-k8s-namespace=default",
+				"-server-ca-cert-file=file",
+				"-server-ca-key-file=file",
+				"-ca-file", f.Name(),
+				"-mesh-gateway-service-name=name",
+				"-consul-api-timeout=5s",
+				"-sync-interval=-1s",
+			},
+			expErr: "-sync-interval must not be negative",
+		},
 		{
 			flags: []string{
 				"-resource-prefix=prefix",
@@ -963,6 +977,104 @@ func TestRun_UpdatesSecret(t *testing.T) {
 	}
 }
 
+// Test that with -sync-interval the secret is kept up to date in this cluster
+// and in the clusters it's exported to.
+func TestRun_SyncInterval(t *testing.T) {
+	t.Parallel()
+
+	k8s := fake.NewSimpleClientset()
+	exportK8s := fake.NewSimpleClientset()
+
+	// Set up Consul server with TLS.
+	caFile, certFile, keyFile := test.GenerateServerCerts(t)
+	a, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.CAFile = caFile
+		c.CertFile = certFile
+		c.KeyFile = keyFile
+	})
+	require.NoError(t, err)
+	defer a.Stop()
+
+	// Create a mesh gateway instance.
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPSAddr,
+		Scheme:  "https",
+		TLSConfig: api.TLSConfig{
+			CAFile: caFile,
+		},
+	})
+	require.NoError(t, err)
+	registerMeshGW := func(ip string) {
+		err := client.Agent().ServiceRegister(&api.AgentServiceRegistration{
+			Name: "mesh-gateway",
+			TaggedAddresses: map[string]api.ServiceAddress{
+				"wan": {
+					Address: ip,
+					Port:    443,
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+	registerMeshGW("192.168.0.1")
+
+	// The CA cert is copied so that it can be rotated.
+	caCert, err := ioutil.ReadFile(caFile)
+	require.NoError(t, err)
+	caCertFile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(caCertFile.Name())
+	require.NoError(t, ioutil.WriteFile(caCertFile.Name(), caCert, 0600))
+
+	ui := cli.NewMockUi()
+	sigCh := make(chan os.Signal, 1)
+	cmd := Command{
+		UI:               ui,
+		k8sClient:        k8s,
+		exportK8sClients: []kubernetes.Interface{exportK8s},
+		sigCh:            sigCh,
+	}
+	exitCh := make(chan int, 1)
+	go func() {
+		exitCh <- cmd.Run([]string{
+			"-resource-prefix=prefix",
+			"-k8s-namespace=default",
+			"-mesh-gateway-service-name=mesh-gateway",
+			"-ca-file", caFile,
+			"-server-ca-cert-file", caCertFile.Name(),
+			"-server-ca-key-file", keyFile,
+			"-http-addr", fmt.Sprintf("https://%s", a.HTTPSAddr),
+			"-consul-api-timeout=5s",
+			"-sync-interval=100ms",
+			"-export-namespace=consul",
+		})
+	}()
+	defer func() {
+		sigCh <- os.Interrupt
+		require.Equal(t, 0, <-exitCh, ui.ErrorWriter.String())
+	}()
+
+	requireSecret := func(r *retry.R, expCACert, expMeshGWIP string) {
+		for ns, client := range map[string]kubernetes.Interface{"default": k8s, "consul": exportK8s} {
+			secret, err := client.CoreV1().Secrets(ns).Get(context.Background(), "prefix-federation", metav1.GetOptions{})
+			require.NoError(r, err)
+			require.Equal(r, expCACert, string(secret.Data["caCert"]))
+			expCfg := fmt.Sprintf(`{"primary_datacenter":"dc1","primary_gateways":["%s:443"]}`, expMeshGWIP)
+			require.Equal(r, expCfg, string(secret.Data["serverConfigJSON"]))
+		}
+	}
+	retry.Run(t, func(r *retry.R) {
+		requireSecret(r, string(caCert), "192.168.0.1")
+	})
+
+	// Rotate the CA cert and change the mesh gateway address.
+	require.NoError(t, ioutil.WriteFile(caCertFile.Name(), []byte("new-ca-cert"), 0600))
+	registerMeshGW("127.0.0.1")
+	retry.Run(t, func(r *retry.R) {
+		requireSecret(r, "new-ca-cert", "127.0.0.1")
+	})
+}
+
 // Test that if the Consul client isn't up yet we will retry until it is.
 func TestRun_ConsulClientDelay(t *testing.T) {
 	t.Parallel()
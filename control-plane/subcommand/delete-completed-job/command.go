@@ -107,7 +107,7 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
-	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	logger, err := common.Logger("delete-completed-job", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -0,0 +1,341 @@
+package dnsforward
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	k8s   *flags.K8SFlags
+
+	flagNamespace          string
+	flagDNSService         string
+	flagDomain             string
+	flagClusterDNS         string
+	flagConfigMapName      string
+	flagConfigMapNamespace string
+	flagCheckInterval      time.Duration
+	flagUninstall          bool
+	flagDeployment         string
+	flagTimeout            time.Duration
+
+	flagLogLevel string
+	flagLogJSON  bool
+
+	k8sClient kubernetes.Interface
+
+	log   hclog.Logger
+	sigCh chan os.Signal
+	once  sync.Once
+	ctx   context.Context
+	help  string
+
+	// pollInterval is how often the deployment is checked while it's scaled
+	// down. It is overridden in tests.
+	pollInterval time.Duration
+}
+
+// init is run once to set up usage documentation for flags.
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false, "Enable or disable JSON output format for logging.")
+	c.flags.StringVar(&c.flagNamespace, "namespace", "", "Name of Kubernetes namespace of the Consul DNS service.")
+	c.flags.StringVar(&c.flagDNSService, "dns-service", "", "Name of the Kubernetes service of Consul DNS.")
+	c.flags.StringVar(&c.flagDomain, "domain", "consul", "The Consul DNS domain that is forwarded to the Consul DNS service.")
+	c.flags.StringVar(&c.flagClusterDNS, "cluster-dns", clusterDNSCoreDNS,
+		fmt.Sprintf("The DNS server of the cluster, either %q or %q.", clusterDNSCoreDNS, clusterDNSKubeDNS))
+	c.flags.StringVar(&c.flagConfigMapName, "configmap-name", "",
+		"Name of the ConfigMap of the DNS server of the cluster. Defaults to \"coredns\" for CoreDNS and \"kube-dns\" for kube-dns.")
+	c.flags.StringVar(&c.flagConfigMapNamespace, "configmap-namespace", "kube-system",
+		"Name of Kubernetes namespace of the ConfigMap of the DNS server of the cluster.")
+	c.flags.DurationVar(&c.flagCheckInterval, "check-interval", 30*time.Second,
+		"How often to check that the domain is forwarded to the current cluster IP of the Consul DNS service.")
+	c.flags.BoolVar(&c.flagUninstall, "uninstall", false,
+		"Remove the forwarding of the domain from the ConfigMap and exit.")
+	c.flags.StringVar(&c.flagDeployment, "deployment", "",
+		"Name of the deployment that manages the forwarding. With -uninstall, it's scaled down "+
+			"first so that it doesn't add the forwarding back.")
+	c.flags.DurationVar(&c.flagTimeout, "timeout", 2*time.Minute,
+		"How long to wait for the deployment to scale down with -uninstall.")
+
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.k8s.Flags())
+
+	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+	if c.pollInterval == 0 {
+		c.pollInterval = time.Second
+	}
+}
+
+// Run keeps the domain forwarded to the cluster IP of the Consul DNS service
+// in the ConfigMap of the DNS server of the cluster, or removes the
+// forwarding with -uninstall.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to validate flags: %v", err))
+		return 1
+	}
+	if c.flagConfigMapName == "" {
+		c.flagConfigMapName = c.flagClusterDNS
+	}
+
+	var err error
+	c.log, err = common.Logger("dns-forward", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	if c.flagUninstall {
+		if err := c.uninstall(); err != nil {
+			c.UI.Error(fmt.Sprintf("Error removing the forwarding of %s: %s", c.flagDomain, err))
+			return 1
+		}
+		return 0
+	}
+
+	ticker := time.NewTicker(c.flagCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			c.log.Error("failed to forward domain", "domain", c.flagDomain, "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// reconcile forwards the domain to the current cluster IP of the Consul DNS
+// service. The forwarding is added back when the ConfigMap is overwritten,
+// e.g. by an upgrade of the cluster.
+func (c *Command) reconcile() error {
+	service, err := c.k8sClient.CoreV1().Services(c.flagNamespace).Get(c.ctx, c.flagDNSService, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting service %q: %s", c.flagDNSService, err)
+	}
+	ip := service.Spec.ClusterIP
+	if ip == "" || ip == corev1.ClusterIPNone {
+		return fmt.Errorf("service %q has no cluster IP", c.flagDNSService)
+	}
+
+	configMap, err := c.k8sClient.CoreV1().ConfigMaps(c.flagConfigMapNamespace).Get(c.ctx, c.flagConfigMapName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) && c.flagClusterDNS == clusterDNSKubeDNS {
+		// The ConfigMap of kube-dns is optional.
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: c.flagConfigMapName, Namespace: c.flagConfigMapNamespace},
+		}
+		configMap.Data = map[string]string{}
+		if configMap.Data[stubDomainsKey], err = setStubDomain("", c.flagDomain, ip); err != nil {
+			return err
+		}
+		if _, err := c.k8sClient.CoreV1().ConfigMaps(c.flagConfigMapNamespace).Create(c.ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating ConfigMap %q: %s", c.flagConfigMapName, err)
+		}
+		c.log.Info("forwarding domain", "domain", c.flagDomain, "ip", ip)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("getting ConfigMap %q: %s", c.flagConfigMapName, err)
+	}
+
+	key, value := c.configKey(), configMap.Data[c.configKey()]
+	var updated string
+	if c.flagClusterDNS == clusterDNSCoreDNS {
+		if value == "" {
+			return fmt.Errorf("ConfigMap %q has no %s", c.flagConfigMapName, corefileKey)
+		}
+		updated, err = setCorefileForwarding(value, c.flagDomain, ip)
+	} else {
+		updated, err = setStubDomain(value, c.flagDomain, ip)
+	}
+	if err != nil {
+		return fmt.Errorf("updating ConfigMap %q: %s", c.flagConfigMapName, err)
+	}
+	if updated == value {
+		return nil
+	}
+	return c.update(configMap, key, updated, "forwarding domain", "ip", ip)
+}
+
+// uninstall scales down the deployment, if any, and removes the forwarding
+// of the domain.
+func (c *Command) uninstall() error {
+	if c.flagDeployment != "" {
+		deployments := c.k8sClient.AppsV1().Deployments(c.flagNamespace)
+		_, err := deployments.Patch(c.ctx, c.flagDeployment, types.MergePatchType, []byte(`{"spec":{"replicas":0}}`), metav1.PatchOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("scaling down deployment %q: %s", c.flagDeployment, err)
+		}
+		if err == nil {
+			err = wait.PollImmediate(c.pollInterval, c.flagTimeout, func() (bool, error) {
+				deployment, err := deployments.Get(c.ctx, c.flagDeployment, metav1.GetOptions{})
+				if k8serrors.IsNotFound(err) {
+					return true, nil
+				} else if err != nil {
+					return false, err
+				}
+				return deployment.Status.Replicas == 0, nil
+			})
+			if err != nil {
+				return fmt.Errorf("waiting for deployment %q to scale down: %s", c.flagDeployment, err)
+			}
+		}
+	}
+
+	configMap, err := c.k8sClient.CoreV1().ConfigMaps(c.flagConfigMapNamespace).Get(c.ctx, c.flagConfigMapName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("getting ConfigMap %q: %s", c.flagConfigMapName, err)
+	}
+	key, value := c.configKey(), configMap.Data[c.configKey()]
+	var updated string
+	if c.flagClusterDNS == clusterDNSCoreDNS {
+		updated, err = removeCorefileForwarding(value, c.flagDomain)
+	} else {
+		updated, err = removeStubDomain(value, c.flagDomain)
+	}
+	if err != nil {
+		return fmt.Errorf("updating ConfigMap %q: %s", c.flagConfigMapName, err)
+	}
+	if updated == value {
+		return nil
+	}
+	return c.update(configMap, key, updated, "removed forwarding of domain")
+}
+
+// update sets the key of the ConfigMap to value. The update fails if the
+// ConfigMap has changed since it was read, and is retried on the next check.
+func (c *Command) update(configMap *corev1.ConfigMap, key, value, msg string, args ...interface{}) error {
+	configMap = configMap.DeepCopy()
+	if value == "" {
+		delete(configMap.Data, key)
+	} else {
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = value
+	}
+	if _, err := c.k8sClient.CoreV1().ConfigMaps(c.flagConfigMapNamespace).Update(c.ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating ConfigMap %q: %s", c.flagConfigMapName, err)
+	}
+	c.log.Info(msg, append([]interface{}{"domain", c.flagDomain, "configmap", c.flagConfigMapName}, args...)...)
+	return nil
+}
+
+func (c *Command) configKey() string {
+	if c.flagClusterDNS == clusterDNSCoreDNS {
+		return corefileKey
+	}
+	return stubDomainsKey
+}
+
+// Help returns the command's help text.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+// Synopsis returns a one-line synopsis of the command.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// validateFlags ensures that all required flags are set.
+func (c *Command) validateFlags() error {
+	if c.flagNamespace == "" {
+		return fmt.Errorf("-namespace must be set")
+	}
+
+	if c.flagDNSService == "" && !c.flagUninstall {
+		return fmt.Errorf("-dns-service must be set")
+	}
+
+	if c.flagDomain == "" {
+		return fmt.Errorf("-domain must be set")
+	}
+
+	if c.flagClusterDNS != clusterDNSCoreDNS && c.flagClusterDNS != clusterDNSKubeDNS {
+		return fmt.Errorf("-cluster-dns must be %q or %q", clusterDNSCoreDNS, clusterDNSKubeDNS)
+	}
+
+	if c.flagCheckInterval <= 0 {
+		return fmt.Errorf("-check-interval must be greater than 0")
+	}
+
+	return nil
+}
+
+const synopsis = "Forward the Consul DNS domain from the DNS server of the cluster."
+const help = `
+Usage: consul-k8s-control-plane dns-forward [options]
+
+  Keeps the Consul DNS domain forwarded to the cluster IP of the Consul DNS
+  service in the ConfigMap of CoreDNS, as a server block of the Corefile,
+  or of kube-dns, as a stub domain. The forwarding is updated when the
+  cluster IP changes and added back when the ConfigMap is overwritten.
+  CoreDNS picks up the change with its reload plugin.
+
+  With -uninstall, the forwarding is removed instead.
+`
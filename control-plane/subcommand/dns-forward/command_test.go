@@ -0,0 +1,214 @@
+package dnsforward
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	namespace  = "default"
+	dnsService = "consul-dns"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-namespace must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default"},
+			expErr: "-dns-service must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-dns-service", dnsService, "-domain", ""},
+			expErr: "-domain must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-dns-service", dnsService, "-cluster-dns", "bind"},
+			expErr: `-cluster-dns must be "coredns" or "kube-dns"`,
+		},
+		{
+			flags:  []string{"-namespace", "default", "-dns-service", dnsService, "-check-interval", "0s"},
+			expErr: "-check-interval must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-dns-service", dnsService, "-log-level", "oak"},
+			expErr: "unknown log level",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestReconcile_CoreDNS(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset(service("10.0.0.53"), configMap("coredns", corefileKey, corefile))
+	cmd := command(k8s, clusterDNSCoreDNS)
+
+	require.NoError(t, cmd.reconcile())
+	require.Contains(t, data(t, k8s, "coredns", corefileKey), "forward . 10.0.0.53\n")
+
+	// The forwarding follows the cluster IP of the service.
+	_, err := k8s.CoreV1().Services(namespace).Update(context.Background(), service("10.0.0.54"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cmd.reconcile())
+	require.Contains(t, data(t, k8s, "coredns", corefileKey), "forward . 10.0.0.54\n")
+	require.NotContains(t, data(t, k8s, "coredns", corefileKey), "10.0.0.53")
+
+	// The forwarding is added back when the ConfigMap is overwritten.
+	_, err = k8s.CoreV1().ConfigMaps("kube-system").Update(context.Background(), configMap("coredns", corefileKey, corefile), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cmd.reconcile())
+	require.Contains(t, data(t, k8s, "coredns", corefileKey), "forward . 10.0.0.54\n")
+}
+
+func TestReconcile_KubeDNS(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset(service("10.0.0.53"))
+	cmd := command(k8s, clusterDNSKubeDNS)
+
+	// The ConfigMap is created if kube-dns doesn't have one.
+	require.NoError(t, cmd.reconcile())
+	require.JSONEq(t, `{"consul": ["10.0.0.53"]}`, data(t, k8s, "kube-dns", stubDomainsKey))
+
+	_, err := k8s.CoreV1().ConfigMaps("kube-system").Update(context.Background(),
+		configMap("kube-dns", stubDomainsKey, `{"acme.local": ["1.2.3.4"]}`), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cmd.reconcile())
+	require.JSONEq(t, `{"acme.local": ["1.2.3.4"], "consul": ["10.0.0.53"]}`, data(t, k8s, "kube-dns", stubDomainsKey))
+}
+
+func TestReconcile_Errors(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		objects []runtime.Object
+		expErr  string
+	}{
+		"no service": {
+			objects: []runtime.Object{configMap("coredns", corefileKey, corefile)},
+			expErr:  `getting service "consul-dns"`,
+		},
+		"headless service": {
+			objects: []runtime.Object{service(corev1.ClusterIPNone), configMap("coredns", corefileKey, corefile)},
+			expErr:  `service "consul-dns" has no cluster IP`,
+		},
+		"no ConfigMap": {
+			objects: []runtime.Object{service("10.0.0.53")},
+			expErr:  `getting ConfigMap "coredns"`,
+		},
+		"no Corefile": {
+			objects: []runtime.Object{service("10.0.0.53"), configMap("coredns", "other", "")},
+			expErr:  `ConfigMap "coredns" has no Corefile`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			cmd := command(fake.NewSimpleClientset(c.objects...), clusterDNSCoreDNS)
+			err := cmd.reconcile()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.expErr)
+		})
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	t.Parallel()
+	replicas := int32(1)
+	k8s := fake.NewSimpleClientset(
+		service("10.0.0.53"),
+		configMap("coredns", corefileKey, corefile),
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "consul-dns-forward", Namespace: namespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		},
+	)
+	cmd := command(k8s, clusterDNSCoreDNS)
+	require.NoError(t, cmd.reconcile())
+
+	cmd.flagDeployment = "consul-dns-forward"
+	require.NoError(t, cmd.uninstall())
+	require.Equal(t, corefile, data(t, k8s, "coredns", corefileKey))
+
+	deployment, err := k8s.AppsV1().Deployments(namespace).Get(context.Background(), "consul-dns-forward", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), *deployment.Spec.Replicas)
+
+	// Uninstalling again is a no-op.
+	require.NoError(t, cmd.uninstall())
+	require.Equal(t, corefile, data(t, k8s, "coredns", corefileKey))
+}
+
+func TestUninstall_KubeDNS(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset(configMap("kube-dns", stubDomainsKey, `{"consul": ["10.0.0.53"]}`))
+	cmd := command(k8s, clusterDNSKubeDNS)
+
+	require.NoError(t, cmd.uninstall())
+	configMap, err := k8s.CoreV1().ConfigMaps("kube-system").Get(context.Background(), "kube-dns", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, configMap.Data, stubDomainsKey)
+}
+
+func command(k8s *fake.Clientset, clusterDNS string) *Command {
+	return &Command{
+		UI:                     cli.NewMockUi(),
+		k8sClient:              k8s,
+		flagNamespace:          namespace,
+		flagDNSService:         dnsService,
+		flagDomain:             "consul",
+		flagClusterDNS:         clusterDNS,
+		flagConfigMapName:      clusterDNS,
+		flagConfigMapNamespace: "kube-system",
+		flagTimeout:            time.Second,
+		pollInterval:           10 * time.Millisecond,
+		log:                    hclog.NewNullLogger(),
+		ctx:                    context.Background(),
+	}
+}
+
+func service(ip string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: dnsService, Namespace: namespace},
+		Spec:       corev1.ServiceSpec{ClusterIP: ip},
+	}
+}
+
+func configMap(name, key, value string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+		Data:       map[string]string{key: value},
+	}
+}
+
+func data(t *testing.T, k8s *fake.Clientset, name, key string) string {
+	t.Helper()
+	configMap, err := k8s.CoreV1().ConfigMaps("kube-system").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return configMap.Data[key]
+}
@@ -0,0 +1,114 @@
+package dnsforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	clusterDNSCoreDNS = "coredns"
+	clusterDNSKubeDNS = "kube-dns"
+
+	// corefileKey and stubDomainsKey are the keys of the ConfigMaps of CoreDNS
+	// and kube-dns that configure the forwarding.
+	corefileKey    = "Corefile"
+	stubDomainsKey = "stubDomains"
+)
+
+// beginMarker and endMarker delimit the server block of the domain that is
+// managed in the Corefile so that it can be updated and removed.
+func beginMarker(domain string) string {
+	return fmt.Sprintf("# BEGIN consul-k8s forwarding of %s", domain)
+}
+
+func endMarker(domain string) string {
+	return fmt.Sprintf("# END consul-k8s forwarding of %s", domain)
+}
+
+// corefileBlock returns the server block of the Corefile that forwards the
+// domain to ip.
+func corefileBlock(domain, ip string) string {
+	return fmt.Sprintf(`%s
+%s:53 {
+    errors
+    cache 30
+    forward . %s
+}
+%s`, beginMarker(domain), domain, ip, endMarker(domain))
+}
+
+// setCorefileForwarding returns corefile with the domain forwarded to ip.
+// The managed server block is replaced if it exists and appended otherwise.
+func setCorefileForwarding(corefile, domain, ip string) (string, error) {
+	rest, err := removeCorefileForwarding(corefile, domain)
+	if err != nil {
+		return "", err
+	}
+	rest = strings.TrimRight(rest, "\n")
+	if rest != "" {
+		rest += "\n"
+	}
+	return rest + corefileBlock(domain, ip) + "\n", nil
+}
+
+// removeCorefileForwarding returns corefile without the managed server block
+// of the domain.
+func removeCorefileForwarding(corefile, domain string) (string, error) {
+	begin := strings.Index(corefile, beginMarker(domain))
+	if begin == -1 {
+		return corefile, nil
+	}
+	end := strings.Index(corefile[begin:], endMarker(domain))
+	if end == -1 {
+		return "", fmt.Errorf("Corefile has %q without %q", beginMarker(domain), endMarker(domain))
+	}
+	end += begin + len(endMarker(domain))
+	if end < len(corefile) && corefile[end] == '\n' {
+		end++
+	}
+	return corefile[:begin] + corefile[end:], nil
+}
+
+// setStubDomain returns the stubDomains of kube-dns with the domain forwarded
+// to ip.
+func setStubDomain(stubDomains, domain, ip string) (string, error) {
+	domains, err := parseStubDomains(stubDomains)
+	if err != nil {
+		return "", err
+	}
+	domains[domain] = []string{ip}
+	encoded, err := json.Marshal(domains)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// removeStubDomain returns the stubDomains of kube-dns without the domain.
+func removeStubDomain(stubDomains, domain string) (string, error) {
+	domains, err := parseStubDomains(stubDomains)
+	if err != nil {
+		return "", err
+	}
+	delete(domains, domain)
+	if len(domains) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(domains)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func parseStubDomains(stubDomains string) (map[string][]string, error) {
+	domains := make(map[string][]string)
+	if strings.TrimSpace(stubDomains) == "" {
+		return domains, nil
+	}
+	if err := json.Unmarshal([]byte(stubDomains), &domains); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", stubDomainsKey, err)
+	}
+	return domains, nil
+}
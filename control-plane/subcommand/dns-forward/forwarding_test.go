@@ -0,0 +1,83 @@
+package dnsforward
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const corefile = `.:53 {
+    errors
+    kubernetes cluster.local in-addr.arpa ip6.arpa
+    forward . /etc/resolv.conf
+    reload
+}
+`
+
+func TestSetCorefileForwarding(t *testing.T) {
+	t.Parallel()
+	expected := corefile + `# BEGIN consul-k8s forwarding of consul
+consul:53 {
+    errors
+    cache 30
+    forward . 10.0.0.53
+}
+# END consul-k8s forwarding of consul
+`
+	updated, err := setCorefileForwarding(corefile, "consul", "10.0.0.53")
+	require.NoError(t, err)
+	require.Equal(t, expected, updated)
+
+	// Setting the same IP doesn't change the Corefile.
+	again, err := setCorefileForwarding(updated, "consul", "10.0.0.53")
+	require.NoError(t, err)
+	require.Equal(t, updated, again)
+
+	// A new IP replaces the server block.
+	changed, err := setCorefileForwarding(updated, "consul", "10.0.0.54")
+	require.NoError(t, err)
+	require.Contains(t, changed, "forward . 10.0.0.54\n")
+	require.NotContains(t, changed, "10.0.0.53")
+
+	removed, err := removeCorefileForwarding(changed, "consul")
+	require.NoError(t, err)
+	require.Equal(t, corefile, removed)
+}
+
+func TestSetCorefileForwarding_otherDomain(t *testing.T) {
+	t.Parallel()
+	updated, err := setCorefileForwarding(corefile, "consul", "10.0.0.53")
+	require.NoError(t, err)
+	updated, err = setCorefileForwarding(updated, "dc2.consul", "10.0.0.54")
+	require.NoError(t, err)
+
+	removed, err := removeCorefileForwarding(updated, "dc2.consul")
+	require.NoError(t, err)
+	require.Contains(t, removed, "consul:53 {")
+	require.NotContains(t, removed, "dc2.consul")
+}
+
+func TestRemoveCorefileForwarding_missingEnd(t *testing.T) {
+	t.Parallel()
+	_, err := removeCorefileForwarding(corefile+"# BEGIN consul-k8s forwarding of consul\n", "consul")
+	require.EqualError(t, err, `Corefile has "# BEGIN consul-k8s forwarding of consul" without "# END consul-k8s forwarding of consul"`)
+}
+
+func TestStubDomain(t *testing.T) {
+	t.Parallel()
+	updated, err := setStubDomain(`{"acme.local": ["1.2.3.4"]}`, "consul", "10.0.0.53")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"acme.local": ["1.2.3.4"], "consul": ["10.0.0.53"]}`, updated)
+
+	removed, err := removeStubDomain(updated, "consul")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"acme.local": ["1.2.3.4"]}`, removed)
+
+	removed, err = removeStubDomain(`{"consul": ["10.0.0.53"]}`, "consul")
+	require.NoError(t, err)
+	require.Equal(t, "", removed)
+
+	_, err = setStubDomain("{", "consul", "10.0.0.53")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parsing stubDomains")
+}
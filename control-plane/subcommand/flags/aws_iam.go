@@ -0,0 +1,47 @@
+package flags
+
+import (
+	"flag"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+)
+
+// AWSIAMLoginFlags are flags used to log in to Consul with an AWS IAM auth
+// method instead of a Kubernetes service account token.
+type AWSIAMLoginFlags struct {
+	login               bool
+	stsRegion           string
+	stsEndpoint         string
+	serverIDHeaderValue string
+}
+
+func (f *AWSIAMLoginFlags) Flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.BoolVar(&f.login, "aws-iam-login", false,
+		"Log in with the AWS credentials of the pod, e.g. the IAM role of its service account on EKS, "+
+			"instead of the Kubernetes service account token. The auth method must be an AWS IAM auth method.")
+	fs.StringVar(&f.stsRegion, "aws-sts-region", "",
+		"Region to sign the sts:GetCallerIdentity request for with -aws-iam-login. Defaults to us-east-1.")
+	fs.StringVar(&f.stsEndpoint, "aws-sts-endpoint", "",
+		"URL of the STS endpoint with -aws-iam-login. Must match the STSEndpoint of the auth method.")
+	fs.StringVar(&f.serverIDHeaderValue, "aws-iam-server-id-header-value", "",
+		"Value of the X-Consul-IAM-ServerID header with -aws-iam-login. Must match the ServerIDHeaderValue of the auth method.")
+	return fs
+}
+
+func (f *AWSIAMLoginFlags) Login() bool {
+	return f.login
+}
+
+// LoginParams returns the parameters to log in with an AWS IAM auth method,
+// or nil if -aws-iam-login isn't set.
+func (f *AWSIAMLoginFlags) LoginParams() *common.AWSIAMLoginParams {
+	if !f.login {
+		return nil
+	}
+	return &common.AWSIAMLoginParams{
+		STSRegion:           f.stsRegion,
+		STSEndpoint:         f.stsEndpoint,
+		ServerIDHeaderValue: f.serverIDHeaderValue,
+	}
+}
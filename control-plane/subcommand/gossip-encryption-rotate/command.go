@@ -0,0 +1,384 @@
+package gossipencryptionrotate
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// rotatedAtAnnotation is set on the secret to the time of the last
+	// rotation so that the schedule survives restarts.
+	rotatedAtAnnotation = "consul.hashicorp.com/gossip-key-rotated-at"
+
+	// pausedAnnotation pauses rotation when it is set to "true" on the secret.
+	pausedAnnotation = "consul.hashicorp.com/gossip-key-rotation-paused"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	http  *flags.HTTPFlags
+	k8s   *flags.K8SFlags
+
+	// These flags determine the Kubernetes secret that holds the gossip key.
+	flagNamespace  string
+	flagSecretName string
+	flagSecretKey  string
+
+	flagRotationPeriod time.Duration
+	flagCheckInterval  time.Duration
+	flagListen         string
+
+	flagLogLevel string
+	flagLogJSON  bool
+
+	k8sClient    kubernetes.Interface
+	consulClient *api.Client
+
+	log     hclog.Logger
+	metrics *metrics
+	sigCh   chan os.Signal
+	once    sync.Once
+	ctx     context.Context
+	help    string
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+}
+
+// init is run once to set up usage documentation for flags.
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false, "Enable or disable JSON output format for logging.")
+	c.flags.StringVar(&c.flagNamespace, "namespace", "", "Name of Kubernetes namespace of the gossip encryption key secret.")
+	c.flags.StringVar(&c.flagSecretName, "secret-name", "", "Name of the secret that holds the gossip encryption key.")
+	c.flags.StringVar(&c.flagSecretKey, "secret-key", "key", "Key of the gossip encryption key in the secret.")
+	c.flags.DurationVar(&c.flagRotationPeriod, "rotation-period", 90*24*time.Hour,
+		"How long a gossip encryption key is used before it's rotated.")
+	c.flags.DurationVar(&c.flagCheckInterval, "check-interval", 5*time.Minute,
+		"How often to check whether the gossip encryption key is due for rotation.")
+	c.flags.StringVar(&c.flagListen, "listen", ":8080", "Address to serve metrics on.")
+
+	c.http = &flags.HTTPFlags{}
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.http.Flags())
+	flags.Merge(c.flags, c.k8s.Flags())
+
+	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+}
+
+// Run periodically rotates the gossip encryption key of the cluster and
+// updates the Kubernetes secret that it is read from.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to validate flags: %v", err))
+		return 1
+	}
+
+	var err error
+	c.log, err = common.Logger("gossip-encryption-rotate", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	if c.consulClient == nil {
+		c.consulClient, err = c.http.APIClient()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error connecting to Consul agent: %s", err))
+			return 1
+		}
+	}
+
+	c.metrics = newMetrics()
+	registry := prometheus.NewRegistry()
+	if err := c.metrics.register(registry); err != nil {
+		c.UI.Error(fmt.Sprintf("Error registering metrics: %s", err))
+		return 1
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, mux); err != nil {
+			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		}
+	}()
+
+	ticker := time.NewTicker(c.flagCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			c.metrics.errors.Inc()
+			c.log.Error("failed to rotate gossip encryption key", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// reconcile rotates the gossip encryption key if it is older than the
+// rotation period. The new key is stored in the secret as the pending key
+// before it's installed, so that a rotation that is interrupted is resumed
+// with the same key instead of installing another one. Once the new key is
+// primary, only the key it replaced is removed from the keyring.
+func (c *Command) reconcile() error {
+	secret, err := c.k8sClient.CoreV1().Secrets(c.flagNamespace).Get(c.ctx, c.flagSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting secret %q: %s", c.flagSecretName, err)
+	}
+	key := string(secret.Data[c.flagSecretKey])
+	if key == "" {
+		return fmt.Errorf("secret %q has no key %q", c.flagSecretName, c.flagSecretKey)
+	}
+
+	rotatedAt := secret.CreationTimestamp.Time
+	if v, ok := secret.Annotations[rotatedAtAnnotation]; ok {
+		if rotatedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("parsing annotation %s of secret %q: %s", rotatedAtAnnotation, c.flagSecretName, err)
+		}
+		c.metrics.lastRotation.Set(float64(rotatedAt.Unix()))
+	}
+
+	if secret.Annotations[pausedAnnotation] == "true" {
+		c.metrics.paused.Set(1)
+		c.log.Debug("gossip encryption key rotation is paused", "secret", c.flagSecretName)
+		return nil
+	}
+	c.metrics.paused.Set(0)
+
+	keyring, err := c.consulClient.Operator().KeyringList(nil)
+	if err != nil {
+		return fmt.Errorf("listing gossip keyring: %s", err)
+	}
+	c.metrics.keys.Set(float64(len(installedKeys(keyring))))
+
+	newKey := string(secret.Data[c.pendingSecretKey()])
+	if newKey != "" {
+		c.log.Info("resuming gossip encryption key rotation", "secret", c.flagSecretName)
+	} else {
+		// The key that the last rotation replaced is removed before the
+		// next rotation can start so that it isn't forgotten.
+		if len(secret.Data[c.previousSecretKey()]) > 0 || c.now().Sub(rotatedAt) < c.flagRotationPeriod {
+			return c.removePreviousKey(secret, keyring)
+		}
+
+		c.log.Info("rotating gossip encryption key", "secret", c.flagSecretName, "last-rotation", rotatedAt)
+		if newKey, err = common.GenerateGossipKey(); err != nil {
+			return fmt.Errorf("generating gossip encryption key: %s", err)
+		}
+		secret.Data[c.pendingSecretKey()] = []byte(newKey)
+		if secret, err = c.k8sClient.CoreV1().Secrets(c.flagNamespace).Update(c.ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("updating secret %q: %s", c.flagSecretName, err)
+		}
+	}
+
+	if _, ok := installedKeys(keyring)[newKey]; !ok {
+		if err := c.consulClient.Operator().KeyringInstall(newKey, nil); err != nil {
+			return fmt.Errorf("installing gossip encryption key: %s", err)
+		}
+	}
+	if !isPrimary(keyring, newKey) {
+		if err := c.consulClient.Operator().KeyringUse(newKey, nil); err != nil {
+			return fmt.Errorf("using gossip encryption key: %s", err)
+		}
+	}
+
+	// The old key stays installed until the secret is updated, so agents that
+	// start in between with the old key from the secret can still join.
+	now := c.now()
+	secret.Data[c.flagSecretKey] = []byte(newKey)
+	secret.Data[c.previousSecretKey()] = []byte(key)
+	delete(secret.Data, c.pendingSecretKey())
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[rotatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	if secret, err = c.k8sClient.CoreV1().Secrets(c.flagNamespace).Update(c.ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating secret %q: %s", c.flagSecretName, err)
+	}
+	c.metrics.rotations.Inc()
+	c.metrics.lastRotation.Set(float64(now.Unix()))
+	c.log.Info("rotated gossip encryption key", "secret", c.flagSecretName)
+
+	keyring, err = c.consulClient.Operator().KeyringList(nil)
+	if err != nil {
+		return fmt.Errorf("listing gossip keyring: %s", err)
+	}
+	return c.removePreviousKey(secret, keyring)
+}
+
+// removePreviousKey removes the key that the last rotation replaced from the
+// keyring once the key of the secret is primary, and then forgets it. Keys
+// that weren't rotated out by this command, such as ones an operator
+// installed, are left alone.
+func (c *Command) removePreviousKey(secret *corev1.Secret, keyring []*api.KeyringResponse) error {
+	previous := string(secret.Data[c.previousSecretKey()])
+	if previous == "" || !isPrimary(keyring, string(secret.Data[c.flagSecretKey])) {
+		return nil
+	}
+
+	installed := installedKeys(keyring)
+	if _, ok := installed[previous]; ok && previous != string(secret.Data[c.flagSecretKey]) {
+		if err := c.consulClient.Operator().KeyringRemove(previous, nil); err != nil {
+			return fmt.Errorf("removing gossip encryption key: %s", err)
+		}
+		delete(installed, previous)
+		c.log.Info("removed old gossip encryption key")
+	}
+	c.metrics.keys.Set(float64(len(installed)))
+
+	delete(secret.Data, c.previousSecretKey())
+	if _, err := c.k8sClient.CoreV1().Secrets(c.flagNamespace).Update(c.ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating secret %q: %s", c.flagSecretName, err)
+	}
+	return nil
+}
+
+// pendingSecretKey is the key of the secret that holds a new gossip
+// encryption key while it's being rotated in.
+func (c *Command) pendingSecretKey() string {
+	return c.flagSecretKey + "-pending"
+}
+
+// previousSecretKey is the key of the secret that holds the gossip encryption
+// key that the last rotation replaced until it's removed from the keyring.
+func (c *Command) previousSecretKey() string {
+	return c.flagSecretKey + "-previous"
+}
+
+// installedKeys returns the keys that are installed in any of the gossip pools.
+func installedKeys(keyring []*api.KeyringResponse) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, r := range keyring {
+		for k := range r.Keys {
+			keys[k] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// isPrimary returns whether key is the primary key of every node in every
+// gossip pool. Agents that don't report their primary keys are never
+// considered to use key.
+func isPrimary(keyring []*api.KeyringResponse, key string) bool {
+	if len(keyring) == 0 {
+		return false
+	}
+	for _, r := range keyring {
+		if r.PrimaryKeys[key] == 0 || r.PrimaryKeys[key] != r.NumNodes {
+			return false
+		}
+	}
+	return true
+}
+
+// Help returns the command's help text.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+// Synopsis returns a one-line synopsis of the command.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// validateFlags ensures that all required flags are set.
+func (c *Command) validateFlags() error {
+	if c.flagNamespace == "" {
+		return fmt.Errorf("-namespace must be set")
+	}
+
+	if c.flagSecretName == "" {
+		return fmt.Errorf("-secret-name must be set")
+	}
+
+	if c.flagRotationPeriod <= 0 {
+		return fmt.Errorf("-rotation-period must be greater than 0")
+	}
+
+	if c.flagCheckInterval <= 0 {
+		return fmt.Errorf("-check-interval must be greater than 0")
+	}
+
+	return nil
+}
+
+const synopsis = "Periodically rotate the gossip encryption key."
+const help = `
+Usage: consul-k8s-control-plane gossip-encryption-rotate [options]
+
+  Rotates the gossip encryption key of the cluster once it is older than
+  the rotation period. The new key is stored in the Kubernetes secret under
+  <secret-key>-pending, installed and made primary on all agents, and then
+  replaces the key of the secret. The key it replaced is kept under
+  <secret-key>-previous until it is removed from the keyring. Other keys
+  of the keyring are left alone.
+  Rotation is paused while the secret has the annotation
+  consul.hashicorp.com/gossip-key-rotation-paused=true.
+`
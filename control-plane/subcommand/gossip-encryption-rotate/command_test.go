@@ -0,0 +1,331 @@
+package gossipencryptionrotate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	namespace  = "default"
+	secretName = "gossip-key"
+	oldKey     = "H3/rYzwoeOQ1rP/CSdHOVDfNsjZE/ECk1UZbPHv4vZQ="
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-namespace must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default"},
+			expErr: "-secret-name must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-secret-name", "my-secret", "-rotation-period", "0s"},
+			expErr: "-rotation-period must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-secret-name", "my-secret", "-check-interval", "0s"},
+			expErr: "-check-interval must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-secret-name", "my-secret", "-log-level", "oak"},
+			expErr: "unknown log level",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+// Test that a key that is older than the rotation period is replaced in the
+// keyring and in the secret.
+func TestReconcile_rotatesExpiredKey(t *testing.T) {
+	t.Parallel()
+	keyring := newFakeKeyring(oldKey)
+	cmd, k8s := testCommand(t, keyring, time.Now().Add(-100*24*time.Hour), nil)
+
+	require.NoError(t, cmd.reconcile())
+
+	secret, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	newKey := string(secret.Data["key"])
+	require.NotEqual(t, oldKey, newKey)
+	require.Equal(t, []string{newKey}, keyring.keys())
+	require.Equal(t, newKey, keyring.primary)
+	rotatedAt, err := time.Parse(time.RFC3339, secret.Annotations[rotatedAtAnnotation])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), rotatedAt, time.Minute)
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.rotations))
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.keys))
+	require.NotContains(t, secret.Data, "key-pending")
+	require.NotContains(t, secret.Data, "key-previous")
+
+	// The new key isn't rotated again.
+	require.NoError(t, cmd.reconcile())
+	secret, err = k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, newKey, string(secret.Data["key"]))
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.rotations))
+}
+
+func TestReconcile_keepsCurrentKey(t *testing.T) {
+	t.Parallel()
+	keyring := newFakeKeyring(oldKey)
+	cmd, k8s := testCommand(t, keyring, time.Now().Add(-24*time.Hour), nil)
+
+	require.NoError(t, cmd.reconcile())
+
+	secret, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, oldKey, string(secret.Data["key"]))
+	require.Equal(t, []string{oldKey}, keyring.keys())
+	require.Equal(t, float64(0), testutil.ToFloat64(cmd.metrics.rotations))
+}
+
+func TestReconcile_paused(t *testing.T) {
+	t.Parallel()
+	keyring := newFakeKeyring(oldKey)
+	cmd, k8s := testCommand(t, keyring, time.Now().Add(-100*24*time.Hour), map[string]string{pausedAnnotation: "true"})
+
+	require.NoError(t, cmd.reconcile())
+
+	secret, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, oldKey, string(secret.Data["key"]))
+	require.Equal(t, []string{oldKey}, keyring.keys())
+	require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.paused))
+}
+
+// Test that only the key that the last rotation replaced is removed once the
+// key in the secret is primary, and that keys an operator installed are kept.
+func TestReconcile_removesOnlyPreviousKey(t *testing.T) {
+	t.Parallel()
+	keyring := newFakeKeyring(oldKey)
+	keyring.installed["other"] = struct{}{}
+	keyring.installed["previous"] = struct{}{}
+	keyring.primary = "other"
+	cmd, k8s := testCommand(t, keyring, time.Now().Add(-time.Hour), nil)
+	secret, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data["key-previous"] = []byte("previous")
+	_, err = k8s.CoreV1().Secrets(namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Keys aren't removed while another key is primary.
+	require.NoError(t, cmd.reconcile())
+	require.ElementsMatch(t, []string{oldKey, "other", "previous"}, keyring.keys())
+
+	keyring.primary = oldKey
+	require.NoError(t, cmd.reconcile())
+	require.ElementsMatch(t, []string{oldKey, "other"}, keyring.keys())
+	secret, err = k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, secret.Data, "key-previous")
+
+	require.NoError(t, cmd.reconcile())
+	require.ElementsMatch(t, []string{oldKey, "other"}, keyring.keys())
+}
+
+// Test that a rotation that fails after the new key was stored in the secret
+// is resumed with the same key instead of installing another one.
+func TestReconcile_resumesInterruptedRotation(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		// fail makes the first rotation fail after the new key was stored
+		// as the pending key and returns a function that undoes it.
+		fail func(*fakeKeyring, *fake.Clientset) func()
+	}{
+		"using the key fails": {
+			fail: func(keyring *fakeKeyring, _ *fake.Clientset) func() {
+				keyring.failUse = true
+				return func() { keyring.failUse = false }
+			},
+		},
+		"updating the secret fails": {
+			fail: func(_ *fakeKeyring, k8s *fake.Clientset) func() {
+				updates := 0
+				failing := true
+				k8s.PrependReactor("update", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					updates++
+					if failing && updates > 1 {
+						return true, nil, errors.New("update failed")
+					}
+					return false, nil, nil
+				})
+				return func() { failing = false }
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			keyring := newFakeKeyring(oldKey)
+			cmd, k8s := testCommand(t, keyring, time.Now().Add(-100*24*time.Hour), nil)
+			undo := c.fail(keyring, k8s)
+
+			require.Error(t, cmd.reconcile())
+			secret, err := k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, oldKey, string(secret.Data["key"]))
+			pending := string(secret.Data["key-pending"])
+			require.NotEmpty(t, pending)
+			require.ElementsMatch(t, []string{oldKey, pending}, keyring.keys())
+
+			// Failing again doesn't install more keys.
+			require.Error(t, cmd.reconcile())
+			require.ElementsMatch(t, []string{oldKey, pending}, keyring.keys())
+
+			undo()
+			require.NoError(t, cmd.reconcile())
+			secret, err = k8s.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, pending, string(secret.Data["key"]))
+			require.NotContains(t, secret.Data, "key-pending")
+			require.Equal(t, []string{pending}, keyring.keys())
+			require.Equal(t, pending, keyring.primary)
+			require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.rotations))
+		})
+	}
+}
+
+func testCommand(t *testing.T, keyring *fakeKeyring, rotatedAt time.Time, annotations map[string]string) (*Command, *fake.Clientset) {
+	t.Helper()
+	server := httptest.NewServer(keyring)
+	t.Cleanup(server.Close)
+	consulClient, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[rotatedAtAnnotation] = rotatedAt.UTC().Format(time.RFC3339)
+	k8s := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secretName,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Data: map[string][]byte{
+			"key": []byte(oldKey),
+		},
+	})
+
+	cmd := &Command{
+		UI:                 cli.NewMockUi(),
+		k8sClient:          k8s,
+		consulClient:       consulClient,
+		flagNamespace:      namespace,
+		flagSecretName:     secretName,
+		flagSecretKey:      "key",
+		flagRotationPeriod: 90 * 24 * time.Hour,
+		log:                hclog.NewNullLogger(),
+		metrics:            newMetrics(),
+		ctx:                context.Background(),
+		now:                time.Now,
+	}
+	return cmd, k8s
+}
+
+// fakeKeyring serves the keyring endpoints of the Consul API for a single
+// agent.
+type fakeKeyring struct {
+	mu        sync.Mutex
+	installed map[string]struct{}
+	primary   string
+
+	// failUse makes requests to change the primary key fail.
+	failUse bool
+}
+
+func newFakeKeyring(key string) *fakeKeyring {
+	return &fakeKeyring{installed: map[string]struct{}{key: {}}, primary: key}
+}
+
+func (f *fakeKeyring) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.installed {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (f *fakeKeyring) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/v1/operator/keyring" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		resp := &api.KeyringResponse{
+			Datacenter:  "dc1",
+			Keys:        map[string]int{},
+			PrimaryKeys: map[string]int{f.primary: 1},
+			NumNodes:    1,
+		}
+		for k := range f.installed {
+			resp.Keys[k] = 1
+		}
+		_ = json.NewEncoder(w).Encode([]*api.KeyringResponse{resp})
+		return
+	}
+
+	var req struct{ Key string }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_, installed := f.installed[req.Key]
+	switch r.Method {
+	case http.MethodPost:
+		f.installed[req.Key] = struct{}{}
+	case http.MethodPut:
+		if !installed || f.failUse {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.primary = req.Key
+	case http.MethodDelete:
+		if req.Key == f.primary {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		delete(f.installed, req.Key)
+	}
+}
@@ -0,0 +1,72 @@
+package gossipencryptionrotate
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "consul_k8s"
+	metricsSubsystem = "gossip_key_rotation"
+)
+
+// metrics are the Prometheus metrics of gossip encryption key rotation.
+type metrics struct {
+	// rotations is the number of completed rotations.
+	rotations prometheus.Counter
+
+	// errors is the number of failed rotations or keyring checks.
+	errors prometheus.Counter
+
+	// lastRotation is the Unix time of the last completed rotation.
+	lastRotation prometheus.Gauge
+
+	// paused is 1 while rotation is paused through the secret's annotation.
+	paused prometheus.Gauge
+
+	// keys is the number of keys in the keyring of the cluster.
+	keys prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		rotations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rotations_total",
+			Help:      "Number of completed gossip encryption key rotations.",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "errors_total",
+			Help:      "Number of failed gossip encryption key rotations or keyring checks.",
+		}),
+		lastRotation: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "last_rotation_timestamp_seconds",
+			Help:      "Unix time of the last completed gossip encryption key rotation.",
+		}),
+		paused: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "paused",
+			Help:      "Whether gossip encryption key rotation is paused.",
+		}),
+		keys: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "keyring_keys",
+			Help:      "Number of keys installed in the gossip keyring.",
+		}),
+	}
+}
+
+func (m *metrics) register(registry prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.rotations, m.errors, m.lastRotation, m.paused, m.keys} {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,13 +6,22 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	connectinject "github.com/hashicorp/consul-k8s/control-plane/connect-inject"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/health"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/sharding"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tlsconfig"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/tracing"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/webhookaudit"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
@@ -29,6 +38,7 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 type Command struct {
@@ -76,6 +86,21 @@ type Command struct {
 	flagDefaultMergedMetricsPort    string
 	flagDefaultPrometheusScrapePort string
 	flagDefaultPrometheusScrapePath string
+	flagEnableLifecycleMetrics      bool
+	flagLifecycleMetricsPort        string
+
+	// Tracing settings.
+	flagTracingZipkinAddress string
+	flagTracingOTLPAddress   string
+
+	// Datadog settings.
+	flagEnableDatadog              bool
+	flagDatadogDogStatsDSocketPath string
+	flagDatadogDogStatsDPort       int
+	flagDatadogEnv                 string
+
+	// Audit settings.
+	flagWebhookAuditLog string
 
 	// Consul sidecar resource settings.
 	flagDefaultConsulSidecarCPULimit      string
@@ -93,17 +118,49 @@ type Command struct {
 	flagDefaultEnableTransparentProxy          bool
 	flagTransparentProxyDefaultOverwriteProbes bool
 
+	// Locality flags.
+	flagEnableLocality bool
+
+	// Metadata flags.
+	flagCopyMetadata []string
+
+	// Flags to share the work among the replicas.
+	flagShards         int
+	flagShardLeaseName string
+
 	// Consul DNS flags.
 	flagEnableConsulDNS bool
 	flagResourcePrefix  string
 
-	flagEnableOpenShift bool
+	// IP family flags.
+	flagEnableIPv6           bool
+	flagRegistrationIPFamily string
+	flagListenerIPFamily     string
+
+	flagEnableOpenShift             bool
+	flagEnableRestrictedPodSecurity bool
+	flagEnableJobWatcher            bool
+
+	// Projected service account token flags.
+	flagEnableProjectedServiceAccountToken     bool
+	flagProjectedServiceAccountTokenExpiration time.Duration
+
+	// AWS IAM login flags.
+	flagEnableAWSIAMLogin         bool
+	flagAWSSTSRegion              string
+	flagAWSSTSEndpoint            string
+	flagAWSIAMServerIDHeaderValue string
+
+	// TLS settings of the webhook server.
+	flagTLSMinVersion   string
+	flagTLSCipherSuites string
 
 	flagSet *flag.FlagSet
 	http    *flags.HTTPFlags
 
 	consulClient *api.Client
 	clientset    kubernetes.Interface
+	tlsConfig    tlsconfig.Config
 
 	once sync.Once
 	help string
@@ -166,17 +223,61 @@ func (c *Command) init() {
 		"Enable transparent proxy mode for all Consul service mesh applications by default.")
 	c.flagSet.BoolVar(&c.flagTransparentProxyDefaultOverwriteProbes, "transparent-proxy-default-overwrite-probes", true,
 		"Overwrite Kubernetes probes to point to Envoy by default when in Transparent Proxy mode.")
+	c.flagSet.BoolVar(&c.flagEnableLocality, "enable-locality", false,
+		"Register service instances with the region and zone of the Kubernetes node of their pod. Requires Consul 1.17+.")
+	c.flagSet.IntVar(&c.flagShards, "shards", 0,
+		"Number of shards that the Endpoints are split into, by their namespace, among the replicas. "+
+			"If it's greater than 0, all the replicas register the service instances of the shards that they own "+
+			"instead of only the leader. It must be the same on all the replicas.")
+	c.flagSet.StringVar(&c.flagShardLeaseName, "shard-lease-name", "consul-endpoints-controller",
+		"Prefix of the names of the Leases of the shards and replicas in the release namespace if -shards is set.")
+	c.flagSet.Var((*flags.AppendSliceValue)(&c.flagCopyMetadata), "copy-metadata",
+		"Rule of the form <source>:<name>[=<key template>] to copy a label of the Kubernetes node of a pod, or a "+
+			"label or annotation of the pod, into the meta of its service instances. The source is one of node-label, "+
+			"pod-label or pod-annotation, and a name that ends with * copies all the labels or annotations with that prefix. "+
+			"The key template is a Go template with {{ .Name }}, the name of the label or annotation, and {{ .Key }}, "+
+			"the name without its prefix, which is the default. May be specified multiple times.")
 	c.flagSet.BoolVar(&c.flagEnableConsulDNS, "enable-consul-dns", false,
 		"Enables Consul DNS lookup for services in the mesh.")
 	c.flagSet.StringVar(&c.flagResourcePrefix, "resource-prefix", "",
 		"Release prefix of the Consul installation used to determine Consul DNS Service name.")
+	c.flagSet.BoolVar(&c.flagEnableIPv6, "enable-ipv6", false,
+		"Also applies the transparent proxy traffic redirection rules for IPv6 traffic on IPv6-only and dual-stack clusters.")
+	c.flagSet.StringVar(&c.flagRegistrationIPFamily, "registration-ip-family", "",
+		"Address family of the pod IPs that service instances are registered with on dual-stack clusters, "+
+			"either \"IPv4\" or \"IPv6\". Defaults to the family of the primary pod IP.")
+	c.flagSet.StringVar(&c.flagListenerIPFamily, "listener-ip-family", "",
+		"Address family of the local addresses that the sidecar proxies listen on, either \"IPv4\" or \"IPv6\". Defaults to IPv4.")
 	c.flagSet.BoolVar(&c.flagEnableOpenShift, "enable-openshift", false,
 		"Indicates that the command runs in an OpenShift cluster.")
+	c.flagSet.BoolVar(&c.flagEnableRestrictedPodSecurity, "enable-restricted-pod-security", false,
+		"Configures the injected containers to comply with the restricted Pod Security Standard. "+
+			"Pods that enable transparent proxy are rejected.")
+	c.flagSet.BoolVar(&c.flagEnableJobWatcher, "enable-job-watcher", false,
+		"Add a container to Job pods that shuts down the Envoy sidecar once the Job's containers have completed. "+
+			"The service accounts of Jobs must be allowed to get pods.")
+	c.flagSet.BoolVar(&c.flagEnableProjectedServiceAccountToken, "enable-projected-service-account-token", false,
+		"Log in to the ACL auth method with a projected service account token instead of the default service account token.")
+	c.flagSet.DurationVar(&c.flagProjectedServiceAccountTokenExpiration, "projected-service-account-token-expiration", time.Hour,
+		"Requested lifetime of the projected service account token. Must be at least 10m.")
+	c.flagSet.BoolVar(&c.flagEnableAWSIAMLogin, "enable-aws-iam-login", false,
+		"Log in to the ACL auth method, which must be an AWS IAM auth method, with the AWS credentials of the pods "+
+			"instead of their service account tokens.")
+	c.flagSet.StringVar(&c.flagAWSSTSRegion, "aws-sts-region", "",
+		"Region to sign the sts:GetCallerIdentity request for with -enable-aws-iam-login. Defaults to us-east-1.")
+	c.flagSet.StringVar(&c.flagAWSSTSEndpoint, "aws-sts-endpoint", "",
+		"URL of the STS endpoint with -enable-aws-iam-login. Must match the STSEndpoint of the auth method.")
+	c.flagSet.StringVar(&c.flagAWSIAMServerIDHeaderValue, "aws-iam-server-id-header-value", "",
+		"Value of the X-Consul-IAM-ServerID header with -enable-aws-iam-login. Must match the ServerIDHeaderValue of the auth method.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
 		fmt.Sprintf("Log verbosity level. Supported values (in order of detail) are "+
 			"%q, %q, %q, and %q.", zapcore.DebugLevel.String(), zapcore.InfoLevel.String(), zapcore.WarnLevel.String(), zapcore.ErrorLevel.String()))
 	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flagSet.StringVar(&c.flagTLSMinVersion, "tls-min-version", "",
+		"Minimum TLS version of the webhook server. One of TLSv1_0, TLSv1_1, TLSv1_2 or TLSv1_3.")
+	c.flagSet.StringVar(&c.flagTLSCipherSuites, "tls-cipher-suites", "",
+		"Comma-separated list of the IANA names of the TLS cipher suites of the webhook server. Can't be set with TLSv1_3.")
 
 	// Proxy sidecar resource setting flags.
 	c.flagSet.StringVar(&c.flagDefaultSidecarProxyCPURequest, "default-sidecar-proxy-cpu-request", "", "Default sidecar proxy CPU request.")
@@ -190,6 +291,34 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagDefaultMergedMetricsPort, "default-merged-metrics-port", "20100", "Default port for merged metrics endpoint on the consul-sidecar.")
 	c.flagSet.StringVar(&c.flagDefaultPrometheusScrapePort, "default-prometheus-scrape-port", "20200", "Default port where Prometheus scrapes connect metrics from.")
 	c.flagSet.StringVar(&c.flagDefaultPrometheusScrapePath, "default-prometheus-scrape-path", "/metrics", "Default path where Prometheus scrapes connect metrics from.")
+	c.flagSet.BoolVar(&c.flagEnableLifecycleMetrics, "enable-lifecycle-metrics", false,
+		"Enables the metrics of connect-init and the consul-sidecar restarts of connect-injected pods. "+
+			"They are merged into the merged metrics of pods with metrics merging, and otherwise served on -lifecycle-metrics-port.")
+	c.flagSet.StringVar(&c.flagLifecycleMetricsPort, "lifecycle-metrics-port", "20300", "Port of the consul-sidecar that serves the lifecycle metrics of pods without metrics merging.")
+
+	// Tracing setting flags.
+	c.flagSet.StringVar(&c.flagTracingZipkinAddress, "tracing-zipkin-address", "",
+		"Address of a Zipkin collector, in the form <host>:<port>, that the Envoy sidecars send their traces to, "+
+			"e.g. the Zipkin receiver of an OpenTelemetry Collector.")
+	c.flagSet.StringVar(&c.flagTracingOTLPAddress, "tracing-otlp-address", "",
+		"Address of an OTLP gRPC receiver, in the form <host>:<port>, that the spans of the reconciles of the endpoints controller are exported to, "+
+			"e.g. the OTLP receiver of an OpenTelemetry Collector.")
+
+	// Datadog setting flags.
+	c.flagSet.BoolVar(&c.flagEnableDatadog, "enable-datadog", false,
+		"Enables sending the metrics of the Envoy sidecars to the DogStatsD server of the Datadog Agent on their node "+
+			"and adding the labels of Datadog unified service tagging to the injected pods.")
+	c.flagSet.StringVar(&c.flagDatadogDogStatsDSocketPath, "datadog-dogstatsd-socket-path", "",
+		"Path of the DogStatsD Unix domain socket on the nodes. If empty, the metrics are sent over UDP to the IP of the node.")
+	c.flagSet.IntVar(&c.flagDatadogDogStatsDPort, "datadog-dogstatsd-port", 8125,
+		"UDP port of DogStatsD on the nodes. Only used if -datadog-dogstatsd-socket-path is empty.")
+	c.flagSet.StringVar(&c.flagDatadogEnv, "datadog-env", "",
+		"Datadog env tag of the injected pods that don't have the tags.datadoghq.com/env label.")
+
+	// Audit setting flags.
+	c.flagSet.StringVar(&c.flagWebhookAuditLog, "webhook-audit-log", "",
+		fmt.Sprintf("If set, an audit record of every admission decision of the webhook is written to this file, "+
+			"or to stdout if it is %q.", webhookaudit.Stdout))
 
 	// Init container resource setting flags.
 	c.flagSet.StringVar(&c.flagInitContainerCPURequest, "init-container-cpu-request", "50m", "Init container CPU request.")
@@ -281,6 +410,21 @@ func (c *Command) Run(args []string) int {
 		c.UI.Error(err.Error())
 		return 1
 	}
+	err = common.ValidateUnprivilegedPort("-lifecycle-metrics-port", c.flagLifecycleMetricsPort)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	var metadataRules []connectinject.MetadataRule
+	for _, raw := range c.flagCopyMetadata {
+		rule, err := connectinject.ParseMetadataRule(raw)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("-copy-metadata is invalid: %s", err))
+			return 1
+		}
+		metadataRules = append(metadataRules, rule)
+	}
 
 	// Validate resource request/limit flags and parse into corev1.ResourceRequirements
 	initResources, consulSidecarResources, err := c.parseAndValidateResourceFlags()
@@ -349,7 +493,7 @@ func (c *Command) Run(args []string) int {
 	allowK8sNamespaces := flags.ToSet(c.flagAllowK8sNamespacesList)
 	denyK8sNamespaces := flags.ToSet(c.flagDenyK8sNamespacesList)
 
-	zapLogger, err := common.ZapLogger(c.flagLogLevel, c.flagLogJSON)
+	zapLogger, err := common.ZapLogger("inject-connect", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error setting up logging: %s", err.Error()))
 		return 1
@@ -357,6 +501,21 @@ func (c *Command) Run(args []string) int {
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
+	if c.flagTracingOTLPAddress != "" {
+		shutdownTracing, err := tracing.Setup(ctx, "consul-k8s-connect-injector", c.flagTracingOTLPAddress)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			return 1
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), tracing.ShutdownTimeout)
+			defer cancel()
+			if err := shutdownTracing(shutdownCtx); err != nil {
+				setupLog.Error(err, "unable to export the remaining spans")
+			}
+		}()
+	}
+
 	listenSplits := strings.SplitN(c.flagListen, ":", 2)
 	if len(listenSplits) < 2 {
 		c.UI.Error(fmt.Sprintf("missing port in address: %s", c.flagListen))
@@ -370,10 +529,8 @@ func (c *Command) Run(args []string) int {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		LeaderElection:         true,
+		LeaderElection:         c.flagShards == 0,
 		LeaderElectionID:       "consul-controller-lock",
-		Host:                   listenSplits[0],
-		Port:                   port,
 		Logger:                 zapLogger,
 		MetricsBindAddress:     "0.0.0.0:9444",
 		HealthProbeBindAddress: "0.0.0.0:9445",
@@ -383,12 +540,43 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
+	var shards *sharding.Coordinator
+	if c.flagShards > 0 {
+		identity, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get the identity of the replica")
+			return 1
+		}
+		shards = &sharding.Coordinator{
+			Clientset: c.clientset,
+			Namespace: c.flagReleaseNamespace,
+			Name:      c.flagShardLeaseName,
+			Identity:  identity,
+			Shards:    c.flagShards,
+			Log:       ctrl.Log.WithName("sharding"),
+		}
+		if err := mgr.Add(shards); err != nil {
+			setupLog.Error(err, "unable to add the shard coordinator to manager")
+			return 1
+		}
+	}
+
 	metricsConfig := connectinject.MetricsConfig{
 		DefaultEnableMetrics:        c.flagDefaultEnableMetrics,
 		DefaultEnableMetricsMerging: c.flagDefaultEnableMetricsMerging,
 		DefaultMergedMetricsPort:    c.flagDefaultMergedMetricsPort,
 		DefaultPrometheusScrapePort: c.flagDefaultPrometheusScrapePort,
 		DefaultPrometheusScrapePath: c.flagDefaultPrometheusScrapePath,
+		EnableLifecycleMetrics:      c.flagEnableLifecycleMetrics,
+		LifecycleMetricsPort:        c.flagLifecycleMetricsPort,
+	}
+
+	datadogConfig := connectinject.DatadogConfig{
+		Enabled:             c.flagEnableDatadog,
+		DogStatsDSocketPath: c.flagDatadogDogStatsDSocketPath,
+		DogStatsDPort:       c.flagDatadogDogStatsDPort,
+		Env:                 c.flagDatadogEnv,
+		EnableIPv6:          c.flagEnableIPv6,
 	}
 
 	if err = (&connectinject.EndpointsController{
@@ -399,6 +587,8 @@ func (c *Command) Run(args []string) int {
 		AllowK8sNamespacesSet:      allowK8sNamespaces,
 		DenyK8sNamespacesSet:       denyK8sNamespaces,
 		MetricsConfig:              metricsConfig,
+		Datadog:                    datadogConfig,
+		TracingZipkinAddress:       c.flagTracingZipkinAddress,
 		ConsulClientCfg:            cfg,
 		EnableConsulPartitions:     c.flagEnablePartitions,
 		EnableConsulNamespaces:     c.flagEnableNamespaces,
@@ -408,8 +598,14 @@ func (c *Command) Run(args []string) int {
 		CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
 		EnableTransparentProxy:     c.flagDefaultEnableTransparentProxy,
 		TProxyOverwriteProbes:      c.flagTransparentProxyDefaultOverwriteProbes,
+		EnableLocality:             c.flagEnableLocality,
+		MetadataRules:              metadataRules,
+		RegistrationIPFamily:       corev1.IPFamily(c.flagRegistrationIPFamily),
+		ListenerIPFamily:           corev1.IPFamily(c.flagListenerIPFamily),
+		Shards:                     shards,
 		AuthMethod:                 c.flagACLAuthMethod,
 		Log:                        ctrl.Log.WithName("controller").WithName("endpoints"),
+		Recorder:                   mgr.GetEventRecorderFor("consul-connect-injector"),
 		Scheme:                     mgr.GetScheme(),
 		ReleaseName:                c.flagReleaseName,
 		ReleaseNamespace:           c.flagReleaseNamespace,
@@ -424,45 +620,81 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "unable to create readiness check", "controller", connectinject.EndpointsController{})
 		return 1
 	}
+	if err = health.AddToManager(mgr, c.consulClient, cfg); err != nil {
+		setupLog.Error(err, "unable to create health checks")
+		return 1
+	}
 
-	mgr.GetWebhookServer().CertDir = c.flagCertDir
-
-	mgr.GetWebhookServer().Register("/mutate",
-		&webhook.Admission{Handler: &connectinject.Handler{
-			Clientset:                     c.clientset,
-			ConsulClient:                  c.consulClient,
-			ImageConsul:                   c.flagConsulImage,
-			ImageEnvoy:                    c.flagEnvoyImage,
-			EnvoyExtraArgs:                c.flagEnvoyExtraArgs,
-			ImageConsulK8S:                c.flagConsulK8sImage,
-			RequireAnnotation:             !c.flagDefaultInject,
-			AuthMethod:                    c.flagACLAuthMethod,
-			ConsulCACert:                  string(consulCACert),
-			DefaultProxyCPURequest:        sidecarProxyCPURequest,
-			DefaultProxyCPULimit:          sidecarProxyCPULimit,
-			DefaultProxyMemoryRequest:     sidecarProxyMemoryRequest,
-			DefaultProxyMemoryLimit:       sidecarProxyMemoryLimit,
-			MetricsConfig:                 metricsConfig,
-			InitContainerResources:        initResources,
-			DefaultConsulSidecarResources: consulSidecarResources,
-			ConsulPartition:               c.http.Partition(),
-			AllowK8sNamespacesSet:         allowK8sNamespaces,
-			DenyK8sNamespacesSet:          denyK8sNamespaces,
-			EnableNamespaces:              c.flagEnableNamespaces,
-			ConsulDestinationNamespace:    c.flagConsulDestinationNamespace,
-			EnableK8SNSMirroring:          c.flagEnableK8SNSMirroring,
-			K8SNSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-			CrossNamespaceACLPolicy:       c.flagCrossNamespaceACLPolicy,
-			EnableTransparentProxy:        c.flagDefaultEnableTransparentProxy,
-			TProxyOverwriteProbes:         c.flagTransparentProxyDefaultOverwriteProbes,
-			EnableConsulDNS:               c.flagEnableConsulDNS,
-			ResourcePrefix:                c.flagResourcePrefix,
-			EnableOpenShift:               c.flagEnableOpenShift,
-			Log:                           ctrl.Log.WithName("handler").WithName("connect"),
-			LogLevel:                      c.flagLogLevel,
-			LogJSON:                       c.flagLogJSON,
-			ConsulAPITimeout:              c.http.ConsulAPITimeout(),
-		}})
+	hookServer := &webhook.Server{
+		Host:    listenSplits[0],
+		Port:    port,
+		CertDir: c.flagCertDir,
+	}
+	var handler admission.Handler = &connectinject.Handler{
+		Clientset:                              c.clientset,
+		ConsulClient:                           c.consulClient,
+		ImageConsul:                            c.flagConsulImage,
+		ImageEnvoy:                             c.flagEnvoyImage,
+		EnvoyExtraArgs:                         c.flagEnvoyExtraArgs,
+		ImageConsulK8S:                         c.flagConsulK8sImage,
+		RequireAnnotation:                      !c.flagDefaultInject,
+		AuthMethod:                             c.flagACLAuthMethod,
+		ConsulCACert:                           string(consulCACert),
+		DefaultProxyCPURequest:                 sidecarProxyCPURequest,
+		DefaultProxyCPULimit:                   sidecarProxyCPULimit,
+		DefaultProxyMemoryRequest:              sidecarProxyMemoryRequest,
+		DefaultProxyMemoryLimit:                sidecarProxyMemoryLimit,
+		MetricsConfig:                          metricsConfig,
+		Datadog:                                datadogConfig,
+		InitContainerResources:                 initResources,
+		DefaultConsulSidecarResources:          consulSidecarResources,
+		ConsulPartition:                        c.http.Partition(),
+		AllowK8sNamespacesSet:                  allowK8sNamespaces,
+		DenyK8sNamespacesSet:                   denyK8sNamespaces,
+		EnableNamespaces:                       c.flagEnableNamespaces,
+		ConsulDestinationNamespace:             c.flagConsulDestinationNamespace,
+		EnableK8SNSMirroring:                   c.flagEnableK8SNSMirroring,
+		K8SNSMirroringPrefix:                   c.flagK8SNSMirroringPrefix,
+		CrossNamespaceACLPolicy:                c.flagCrossNamespaceACLPolicy,
+		EnableTransparentProxy:                 c.flagDefaultEnableTransparentProxy,
+		TProxyOverwriteProbes:                  c.flagTransparentProxyDefaultOverwriteProbes,
+		EnableConsulDNS:                        c.flagEnableConsulDNS,
+		ResourcePrefix:                         c.flagResourcePrefix,
+		EnableOpenShift:                        c.flagEnableOpenShift,
+		EnableIPv6:                             c.flagEnableIPv6,
+		ListenerIPFamily:                       corev1.IPFamily(c.flagListenerIPFamily),
+		EnableRestrictedPodSecurity:            c.flagEnableRestrictedPodSecurity,
+		EnableJobWatcher:                       c.flagEnableJobWatcher,
+		EnableProjectedServiceAccountToken:     c.flagEnableProjectedServiceAccountToken,
+		ProjectedServiceAccountTokenExpiration: c.flagProjectedServiceAccountTokenExpiration,
+		EnableAWSIAMLogin:                      c.flagEnableAWSIAMLogin,
+		AWSSTSRegion:                           c.flagAWSSTSRegion,
+		AWSSTSEndpoint:                         c.flagAWSSTSEndpoint,
+		AWSIAMServerIDHeaderValue:              c.flagAWSIAMServerIDHeaderValue,
+		Log:                                    ctrl.Log.WithName("handler").WithName("connect"),
+		EventRecorder:                          mgr.GetEventRecorderFor("consul-connect-injector"),
+		LogLevel:                               c.flagLogLevel,
+		LogJSON:                                c.flagLogJSON,
+		ConsulAPITimeout:                       c.http.ConsulAPITimeout(),
+	}
+	if c.flagWebhookAuditLog != "" {
+		sink, err := webhookaudit.OpenSink(c.flagWebhookAuditLog)
+		if err != nil {
+			setupLog.Error(err, "unable to open the webhook audit log")
+			return 1
+		}
+		handler = &webhookaudit.Handler{
+			Handler: handler,
+			Webhook: "connect-injector",
+			Sink:    sink,
+			Log:     ctrl.Log.WithName("handler").WithName("audit"),
+		}
+	}
+	hookServer.Register("/mutate", &webhook.Admission{Handler: handler})
+	if err := mgr.Add(&tlsconfig.WebhookServer{Server: hookServer, Config: c.tlsConfig}); err != nil {
+		setupLog.Error(err, "unable to add webhook server to manager")
+		return 1
+	}
 
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
@@ -501,8 +733,86 @@ func (c *Command) validateFlags() error {
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+
+	if c.flagShards < 0 {
+		return errors.New("-shards must not be negative")
+	}
+
+	if err := c.validateIPFamily("-registration-ip-family", c.flagRegistrationIPFamily); err != nil {
+		return err
+	}
+	if err := c.validateIPFamily("-listener-ip-family", c.flagListenerIPFamily); err != nil {
+		return err
+	}
+
+	if c.flagEnableRestrictedPodSecurity && c.flagDefaultEnableTransparentProxy {
+		return errors.New("-default-enable-transparent-proxy must be false if -enable-restricted-pod-security is true")
+	}
+
+	if c.flagEnableRestrictedPodSecurity && c.flagDatadogDogStatsDSocketPath != "" {
+		return errors.New("-datadog-dogstatsd-socket-path can't be set if -enable-restricted-pod-security is true " +
+			"because the socket is mounted from a hostPath volume")
+	}
+
+	if c.flagEnableAWSIAMLogin && c.flagACLAuthMethod == "" {
+		return errors.New("-acl-auth-method must be set if -enable-aws-iam-login is true")
+	}
+
+	// Kubernetes rejects projected service account tokens that expire in less than 10 minutes.
+	if c.flagEnableProjectedServiceAccountToken && c.flagProjectedServiceAccountTokenExpiration < 10*time.Minute {
+		return errors.New("-projected-service-account-token-expiration must be at least 10m")
+	}
+
+	if c.flagTracingZipkinAddress != "" {
+		_, port, err := net.SplitHostPort(c.flagTracingZipkinAddress)
+		if err == nil {
+			_, err = strconv.Atoi(port)
+		}
+		if err != nil {
+			return fmt.Errorf("-tracing-zipkin-address must be of the form <host>:<port>: %s", err)
+		}
+	}
+
+	if c.flagTracingOTLPAddress != "" {
+		_, port, err := net.SplitHostPort(c.flagTracingOTLPAddress)
+		if err == nil {
+			_, err = strconv.Atoi(port)
+		}
+		if err != nil {
+			return fmt.Errorf("-tracing-otlp-address must be of the form <host>:<port>: %s", err)
+		}
+	}
+
+	if c.flagEnableDatadog && c.flagDatadogDogStatsDSocketPath == "" && (c.flagDatadogDogStatsDPort < 1 || c.flagDatadogDogStatsDPort > 65535) {
+		return errors.New("-datadog-dogstatsd-port must be a valid port")
+	}
+	if c.flagDatadogDogStatsDSocketPath != "" && !filepath.IsAbs(c.flagDatadogDogStatsDSocketPath) {
+		return errors.New("-datadog-dogstatsd-socket-path must be an absolute path")
+	}
+
+	tlsConfig, err := tlsconfig.Parse(c.flagTLSMinVersion, c.flagTLSCipherSuites)
+	if err != nil {
+		return err
+	}
+	c.tlsConfig = tlsConfig
 	return nil
 }
+
+// validateIPFamily returns an error if family, the value of the flag, isn't an
+// address family or is IPv6 without IPv6 being enabled.
+func (c *Command) validateIPFamily(flagName, family string) error {
+	switch corev1.IPFamily(family) {
+	case "", corev1.IPv4Protocol:
+		return nil
+	case corev1.IPv6Protocol:
+		if !c.flagEnableIPv6 {
+			return fmt.Errorf("-enable-ipv6 must be true if %s is %q", flagName, family)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s must be one of %q or %q", flagName, corev1.IPv4Protocol, corev1.IPv6Protocol)
+	}
+}
 func (c *Command) parseAndValidateResourceFlags() (corev1.ResourceRequirements, corev1.ResourceRequirements, error) {
 	// Init container
 	var initContainerCPULimit, initContainerCPURequest, initContainerMemoryLimit, initContainerMemoryRequest resource.Quantity
@@ -61,6 +61,72 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-consul-api-timeout", "5s", "-partition", "default"},
 			expErr: "-enable-partitions must be set to 'true' if -partition-name is set",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-projected-service-account-token", "-projected-service-account-token-expiration", "5m"},
+			expErr: "-projected-service-account-token-expiration must be at least 10m",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-tracing-zipkin-address", "otel-collector"},
+			expErr: "-tracing-zipkin-address must be of the form <host>:<port>",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-tracing-zipkin-address", "otel-collector:zipkin"},
+			expErr: "-tracing-zipkin-address must be of the form <host>:<port>",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-tracing-otlp-address", "otel-collector"},
+			expErr: "-tracing-otlp-address must be of the form <host>:<port>",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-datadog", "-datadog-dogstatsd-port", "0"},
+			expErr: "-datadog-dogstatsd-port must be a valid port",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-datadog", "-datadog-dogstatsd-socket-path", "dsd.socket"},
+			expErr: "-datadog-dogstatsd-socket-path must be an absolute path",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-tls-cipher-suites", "foo"},
+			expErr: `unsupported TLS cipher suite "foo"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-shards", "-1"},
+			expErr: "-shards must not be negative",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-registration-ip-family", "ipv6"},
+			expErr: `-registration-ip-family must be one of "IPv4" or "IPv6"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-listener-ip-family", "IPv6"},
+			expErr: `-enable-ipv6 must be true if -listener-ip-family is "IPv6"`,
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-restricted-pod-security"},
+			expErr: "-default-enable-transparent-proxy must be false if -enable-restricted-pod-security is true",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-restricted-pod-security", "-default-enable-transparent-proxy=false",
+				"-datadog-dogstatsd-socket-path", "/var/run/datadog/dsd.socket"},
+			expErr: "-datadog-dogstatsd-socket-path can't be set if -enable-restricted-pod-security is true because the socket is mounted from a hostPath volume",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-copy-metadata", "node-annotation:foo"},
+			expErr: `-copy-metadata is invalid: metadata rule "node-annotation:foo" has an invalid source "node-annotation"`,
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
 				"-consul-api-timeout", "5s", "-default-sidecar-proxy-cpu-limit=unparseable"},
@@ -0,0 +1,241 @@
+package jobwatcher
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+)
+
+// envoyShutdownAttempts is how many times we'll try to shut down Envoy before giving up.
+const envoyShutdownAttempts = 5
+
+// Command is the command for shutting down the Envoy sidecar of a Job pod
+// once the Job's containers have completed.
+type Command struct {
+	UI cli.Ui
+
+	flags              *flag.FlagSet
+	k8s                *flags.K8SFlags
+	flagPodName        string
+	flagPodNamespace   string
+	flagContainers     []string
+	flagEnvoyAdminAddr string
+	flagLogLevel       string
+	flagLogJSON        bool
+
+	once      sync.Once
+	help      string
+	k8sClient kubernetes.Interface
+	logger    hclog.Logger
+
+	// retryDuration is how often we'll check whether the containers have completed.
+	retryDuration time.Duration
+
+	sigCh chan os.Signal
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.k8s = &flags.K8SFlags{}
+	c.flags.StringVar(&c.flagPodName, "pod-name", "", "Name of the pod.")
+	c.flags.StringVar(&c.flagPodNamespace, "pod-namespace", "", "Name of the pod namespace.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagContainers), "container",
+		"Name of a container that must complete before Envoy is shut down. May be specified multiple times.")
+	c.flags.StringVar(&c.flagEnvoyAdminAddr, "envoy-admin-addr", "127.0.0.1:19000",
+		"Address of the Envoy admin API. Defaults to 127.0.0.1:19000.")
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+	flags.Merge(c.flags, c.k8s.Flags())
+	c.help = flags.Usage(help, c.flags)
+
+	// Default retry to 2s. This is exposed for setting in tests.
+	if c.retryDuration == 0 {
+		c.retryDuration = 2 * time.Second
+	}
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+}
+
+// Run waits for the containers of the Job pod to complete and then shuts down
+// the Envoy sidecar so that the pod can complete.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if c.flagPodName == "" {
+		c.UI.Error("-pod-name must be set")
+		return 1
+	}
+	if c.flagPodNamespace == "" {
+		c.UI.Error("-pod-namespace must be set")
+		return 1
+	}
+	if len(c.flagContainers) == 0 {
+		c.UI.Error("-container must be set at least once")
+		return 1
+	}
+
+	// c.k8sClient might already be set in a test.
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	var err error
+	c.logger, err = common.Logger("job-watcher", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	go func() {
+		select {
+		case sig := <-c.sigCh:
+			c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+			cancelFunc()
+		case <-ctx.Done():
+		}
+	}()
+
+	c.logger.Info("waiting for containers to complete", "containers", c.flagContainers)
+	for {
+		pod, err := c.k8sClient.CoreV1().Pods(c.flagPodNamespace).Get(ctx, c.flagPodName, metav1.GetOptions{})
+		if k8serrors.IsForbidden(err) {
+			// Fail so that the missing permission shows up in the pod's status instead of the
+			// pod silently never completing because Envoy keeps running.
+			c.logger.Error("not allowed to get the pod, so Envoy will not be shut down when the containers complete; "+
+				"allow the pod's service account to get pods or disable the job watcher and shut down Envoy "+
+				"from the Job's containers", "name", c.flagPodName, "namespace", c.flagPodNamespace, "err", err)
+			return 1
+		}
+		if err != nil {
+			c.logger.Error("unable to get pod", "name", c.flagPodName, "err", err)
+		} else if containersCompleted(pod, c.flagContainers) {
+			break
+		}
+
+		select {
+		case <-time.After(c.retryDuration):
+			continue
+		case <-ctx.Done():
+			return 0
+		}
+	}
+
+	c.logger.Info("containers have completed, shutting down Envoy")
+	for i := 1; ; i++ {
+		err := c.shutdownEnvoy(ctx)
+		if err == nil {
+			c.logger.Info("Envoy has been shut down")
+			return 0
+		}
+		if i == envoyShutdownAttempts {
+			// Envoy may have already been shut down by the Job's containers.
+			c.logger.Warn("unable to shut down Envoy, giving up", "err", err)
+			return 0
+		}
+		c.logger.Error("unable to shut down Envoy", "err", err)
+
+		select {
+		case <-time.After(c.retryDuration):
+		case <-ctx.Done():
+			return 0
+		}
+	}
+}
+
+// containersCompleted returns true if all of the containers of the pod have
+// completed and won't be restarted.
+func containersCompleted(pod *corev1.Pod, containers []string) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+
+	statuses := make(map[string]corev1.ContainerStatus)
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+	for _, name := range containers {
+		status, ok := statuses[name]
+		if !ok || status.State.Terminated == nil {
+			return false
+		}
+		// Containers that fail are restarted unless the restart policy is Never.
+		if status.State.Terminated.ExitCode != 0 && pod.Spec.RestartPolicy != corev1.RestartPolicyNever {
+			return false
+		}
+	}
+	return true
+}
+
+// shutdownEnvoy asks Envoy to shut down through its admin API.
+func (c *Command) shutdownEnvoy(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/quitquitquit", c.flagEnvoyAdminAddr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Shut down the Envoy sidecar of a Job pod when the Job completes."
+const help = `
+Usage: consul-k8s-control-plane job-watcher [options]
+
+  Waits for the containers of a Job pod to complete and then shuts down
+  the pod's Envoy sidecar so that the pod can complete. This command
+  expects to be run as a sidecar and to be injected by the mutating webhook.
+`
@@ -0,0 +1,277 @@
+package jobwatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		args   []string
+		expErr string
+	}{
+		{
+			[]string{},
+			"-pod-name must be set",
+		},
+		{
+			[]string{"-pod-name=job"},
+			"-pod-namespace must be set",
+		},
+		{
+			[]string{"-pod-name=job", "-pod-namespace=default"},
+			"-container must be set at least once",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:        ui,
+				k8sClient: fake.NewSimpleClientset(),
+			}
+			responseCode := cmd.Run(c.args)
+			require.Equal(t, 1, responseCode)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun_ShutsDownEnvoyWhenContainersComplete(t *testing.T) {
+	t.Parallel()
+	var shutdowns int32
+	envoy := envoyAdminServer(t, &shutdowns)
+
+	pod := jobPod()
+	k8s := fake.NewSimpleClientset(pod)
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:            ui,
+		k8sClient:     k8s,
+		retryDuration: 10 * time.Millisecond,
+	}
+
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-pod-name", pod.Name,
+		"-pod-namespace", pod.Namespace,
+		"-container", "job",
+		"-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://"),
+	})
+
+	// Envoy must not be shut down while the container is running.
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(0), atomic.LoadInt32(&shutdowns))
+
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{ExitCode: 0},
+	}
+	_, err := k8s.CoreV1().Pods(pod.Namespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	select {
+	case code := <-exitCh:
+		require.Equal(t, 0, code, ui.ErrorWriter.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not exit after the containers completed")
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&shutdowns))
+}
+
+func TestRun_FailsWhenForbidden(t *testing.T) {
+	t.Parallel()
+	var shutdowns int32
+	envoy := envoyAdminServer(t, &shutdowns)
+
+	k8s := fake.NewSimpleClientset()
+	k8s.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "job", nil)
+	})
+	cmd := Command{
+		UI:            cli.NewMockUi(),
+		k8sClient:     k8s,
+		retryDuration: 10 * time.Millisecond,
+	}
+	code := cmd.Run([]string{
+		"-pod-name", "job",
+		"-pod-namespace", "default",
+		"-container", "job",
+		"-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://"),
+	})
+	require.Equal(t, 1, code)
+	require.Equal(t, int32(0), atomic.LoadInt32(&shutdowns))
+}
+
+func TestRun_ExitsOnSignal(t *testing.T) {
+	t.Parallel()
+	var shutdowns int32
+	envoy := envoyAdminServer(t, &shutdowns)
+
+	pod := jobPod()
+	cmd := Command{
+		UI:            cli.NewMockUi(),
+		k8sClient:     fake.NewSimpleClientset(pod),
+		retryDuration: 10 * time.Millisecond,
+	}
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-pod-name", pod.Name,
+		"-pod-namespace", pod.Namespace,
+		"-container", "job",
+		"-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://"),
+	})
+	cmd.sendSignal(syscall.SIGTERM)
+
+	select {
+	case code := <-exitCh:
+		require.Equal(t, 0, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not exit after receiving a signal")
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&shutdowns))
+}
+
+func TestContainersCompleted(t *testing.T) {
+	t.Parallel()
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	succeeded := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}
+	failed := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}
+
+	cases := map[string]struct {
+		phase         corev1.PodPhase
+		restartPolicy corev1.RestartPolicy
+		containers    []string
+		states        map[string]corev1.ContainerState
+		exp           bool
+	}{
+		"running": {
+			restartPolicy: corev1.RestartPolicyNever,
+			containers:    []string{"a"},
+			states:        map[string]corev1.ContainerState{"a": running},
+			exp:           false,
+		},
+		"no status": {
+			restartPolicy: corev1.RestartPolicyNever,
+			containers:    []string{"a"},
+			states:        map[string]corev1.ContainerState{},
+			exp:           false,
+		},
+		"one of two succeeded": {
+			restartPolicy: corev1.RestartPolicyNever,
+			containers:    []string{"a", "b"},
+			states:        map[string]corev1.ContainerState{"a": succeeded, "b": running},
+			exp:           false,
+		},
+		"all succeeded": {
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			containers:    []string{"a", "b"},
+			states:        map[string]corev1.ContainerState{"a": succeeded, "b": succeeded},
+			exp:           true,
+		},
+		"failed and never restarted": {
+			restartPolicy: corev1.RestartPolicyNever,
+			containers:    []string{"a", "b"},
+			states:        map[string]corev1.ContainerState{"a": failed, "b": succeeded},
+			exp:           true,
+		},
+		"failed and restarted on failure": {
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			containers:    []string{"a", "b"},
+			states:        map[string]corev1.ContainerState{"a": failed, "b": succeeded},
+			exp:           false,
+		},
+		"pod failed": {
+			phase:         corev1.PodFailed,
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			containers:    []string{"a"},
+			states:        map[string]corev1.ContainerState{"a": running},
+			exp:           true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{RestartPolicy: c.restartPolicy},
+				Status: corev1.PodStatus{
+					Phase: c.phase,
+				},
+			}
+			for containerName, state := range c.states {
+				pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+					Name:  containerName,
+					State: state,
+				})
+			}
+			require.Equal(t, c.exp, containersCompleted(pod, c.containers))
+		})
+	}
+}
+
+func jobPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "job",
+			Namespace: "default",
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "job",
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+				{
+					Name:  "envoy-sidecar",
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+}
+
+// envoyAdminServer returns a server that counts the requests to shut down Envoy.
+func envoyAdminServer(t *testing.T, shutdowns *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/quitquitquit" {
+			atomic.AddInt32(shutdowns, 1)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runCommandAsynchronously starts the command and returns a channel that the
+// command sends its exit code to when it's finished.
+func runCommandAsynchronously(cmd *Command, args []string) chan int {
+	// We have to run cmd.init() to ensure that the channel the command is
+	// using to watch for os interrupts is initialized before sendSignal is called.
+	cmd.init()
+	exitChan := make(chan int, 1)
+	go func() {
+		exitChan <- cmd.Run(args)
+	}()
+	return exitChan
+}
+
+func (c *Command) sendSignal(sig os.Signal) {
+	c.sigCh <- sig
+}
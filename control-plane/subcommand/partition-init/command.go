@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -110,7 +111,7 @@ func (c *Command) Run(args []string) int {
 	defer cancel()
 
 	var err error
-	c.log, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+	c.log, err = common.Logger("partition-init", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -152,6 +153,14 @@ func (c *Command) Run(args []string) int {
 				c.log.Info("Successfully created Admin Partition", "name", c.flagPartitionName)
 				return 0
 			}
+			// Servers without Admin Partitions don't have the partition
+			// endpoints, so retrying won't help.
+			var statusErr api.StatusError
+			if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+				c.UI.Error(fmt.Sprintf("Error creating partition %q: the Consul servers don't support Admin Partitions, "+
+					"which require Consul Enterprise 1.11+: %s", c.flagPartitionName, err))
+				return 1
+			}
 			c.log.Error("Error creating partition", "name", c.flagPartitionName, "error", err.Error())
 		} else {
 			c.log.Info("Admin Partition already exists", "name", c.flagPartitionName)
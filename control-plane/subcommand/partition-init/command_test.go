@@ -0,0 +1,42 @@
+package partition_init
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+// Servers that don't support Admin Partitions fail the command without
+// retrying until the timeout.
+func TestRun_PartitionsNotSupported(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+	}
+	cmd.init()
+	args := []string{
+		"-server-address=" + serverURL.Hostname(),
+		"-server-port=" + serverURL.Port(),
+		"-partition-name", "test-partition",
+		"-consul-api-timeout", "5s",
+		"-timeout", "1m",
+	}
+
+	responseCode := cmd.Run(args)
+
+	require.Equal(t, 1, responseCode)
+	require.Contains(t, ui.ErrorWriter.String(),
+		`Error creating partition "test-partition": the Consul servers don't support Admin Partitions, which require Consul Enterprise 1.11+`)
+}
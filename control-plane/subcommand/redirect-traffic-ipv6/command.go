@@ -0,0 +1,264 @@
+package redirecttrafficipv6
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Command applies the transparent proxy traffic redirection rules for IPv6
+// traffic with ip6tables. The rules are the same as the ones that
+// `consul connect redirect-traffic` applies for IPv4 traffic with iptables.
+type Command struct {
+	UI cli.Ui
+
+	flags                    *flag.FlagSet
+	http                     *flags.HTTPFlags
+	flagProxyID              string
+	flagProxyUID             string
+	flagConsulDNSIP          string
+	flagConsulNamespace      string
+	flagExcludeInboundPorts  []string
+	flagExcludeOutboundPorts []string
+	flagExcludeOutboundCIDRs []string
+	flagExcludeUIDs          []string
+	flagLogLevel             string
+	flagLogJSON              bool
+
+	once   sync.Once
+	help   string
+	logger hclog.Logger
+
+	// iptablesProvider applies the rules. It's only set in tests.
+	iptablesProvider iptables.Provider
+}
+
+// trafficRedirectProxyConfig are the fields of the proxy service config that
+// affect the redirection rules.
+type trafficRedirectProxyConfig struct {
+	BindPort           int    `mapstructure:"bind_port"`
+	PrometheusBindAddr string `mapstructure:"envoy_prometheus_bind_addr"`
+	StatsBindAddr      string `mapstructure:"envoy_stats_bind_addr"`
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.StringVar(&c.flagProxyID, "proxy-id", "", "The ID of the proxy service.")
+	c.flags.StringVar(&c.flagProxyUID, "proxy-uid", "", "The user ID of the proxy to exclude from traffic redirection.")
+	c.flags.StringVar(&c.flagConsulDNSIP, "consul-dns-ip", "", "The IPv6 address of the Consul DNS Service to redirect DNS queries to.")
+	c.flags.StringVar(&c.flagConsulNamespace, "namespace", "", "[Enterprise Only] The Consul namespace of the proxy service.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExcludeInboundPorts), "exclude-inbound-port",
+		"Inbound port to exclude from traffic redirection. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExcludeOutboundPorts), "exclude-outbound-port",
+		"Outbound port to exclude from traffic redirection. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExcludeOutboundCIDRs), "exclude-outbound-cidr",
+		"Outbound IPv6 CIDR to exclude from traffic redirection. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExcludeUIDs), "exclude-uid",
+		"Additional user ID to exclude from traffic redirection. May be specified multiple times.")
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.http.Flags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if c.flagProxyID == "" {
+		c.UI.Error("-proxy-id must be set")
+		return 1
+	}
+	if c.flagProxyUID == "" {
+		c.UI.Error("-proxy-uid must be set")
+		return 1
+	}
+	if c.http.ConsulAPITimeout() <= 0 {
+		c.UI.Error("-consul-api-timeout must be set to a value greater than 0")
+		return 1
+	}
+	if c.flagConsulDNSIP != "" && !isIPv6(c.flagConsulDNSIP) {
+		c.UI.Error(fmt.Sprintf("-consul-dns-ip %q must be an IPv6 address", c.flagConsulDNSIP))
+		return 1
+	}
+	for _, cidr := range c.flagExcludeOutboundCIDRs {
+		if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() != nil {
+			c.UI.Error(fmt.Sprintf("-exclude-outbound-cidr %q must be an IPv6 CIDR", cidr))
+			return 1
+		}
+	}
+
+	var err error
+	c.logger, err = common.Logger("redirect-traffic-ipv6", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	cfg := api.DefaultConfig()
+	c.http.MergeOntoConfig(cfg)
+	if c.flagConsulNamespace != "" {
+		cfg.Namespace = c.flagConsulNamespace
+	}
+	consulClient, err := consul.NewClient(cfg, c.http.ConsulAPITimeout())
+	if err != nil {
+		c.logger.Error("Unable to get client connection", "error", err)
+		return 1
+	}
+
+	iptablesCfg, err := c.iptablesConfig(consulClient)
+	if err != nil {
+		c.logger.Error("Unable to generate the traffic redirection rules", "error", err)
+		return 1
+	}
+	if err := iptables.Setup(iptablesCfg); err != nil {
+		c.logger.Error("Unable to apply the traffic redirection rules", "error", err)
+		return 1
+	}
+	c.logger.Info("Successfully applied the IPv6 traffic redirection rules")
+	return 0
+}
+
+// iptablesConfig returns the configuration of the redirection rules for the
+// proxy service, which is looked up from the Consul agent the same way that
+// `consul connect redirect-traffic` does.
+func (c *Command) iptablesConfig(consulClient *api.Client) (iptables.Config, error) {
+	cfg := iptables.Config{
+		ConsulDNSIP:          c.flagConsulDNSIP,
+		ProxyUserID:          c.flagProxyUID,
+		ExcludeInboundPorts:  c.flagExcludeInboundPorts,
+		ExcludeOutboundPorts: c.flagExcludeOutboundPorts,
+		ExcludeOutboundCIDRs: c.flagExcludeOutboundCIDRs,
+		ExcludeUIDs:          c.flagExcludeUIDs,
+		IptablesProvider:     c.iptablesProvider,
+	}
+	if cfg.IptablesProvider == nil {
+		cfg.IptablesProvider = &ip6tablesExecutor{}
+	}
+
+	svc, _, err := consulClient.Agent().Service(c.flagProxyID, nil)
+	if err != nil {
+		return iptables.Config{}, fmt.Errorf("failed to fetch proxy service %q from Consul: %w", c.flagProxyID, err)
+	}
+	if svc.Kind != api.ServiceKindConnectProxy || svc.Proxy == nil {
+		return iptables.Config{}, fmt.Errorf("service %q is not a connect proxy", c.flagProxyID)
+	}
+
+	cfg.ProxyInboundPort = svc.Port
+	var proxyCfg trafficRedirectProxyConfig
+	if err := mapstructure.WeakDecode(svc.Proxy.Config, &proxyCfg); err != nil {
+		return iptables.Config{}, fmt.Errorf("failed to parse the config of proxy service %q: %w", c.flagProxyID, err)
+	}
+	if proxyCfg.BindPort != 0 {
+		cfg.ProxyInboundPort = proxyCfg.BindPort
+	}
+	if svc.Proxy.TransparentProxy != nil && svc.Proxy.TransparentProxy.OutboundListenerPort != 0 {
+		cfg.ProxyOutboundPort = svc.Proxy.TransparentProxy.OutboundListenerPort
+	}
+
+	// The metrics listeners and the listeners of the exposed paths are reached
+	// directly rather than through the inbound listener.
+	for _, addr := range []string{proxyCfg.PrometheusBindAddr, proxyCfg.StatsBindAddr} {
+		if addr == "" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return iptables.Config{}, fmt.Errorf("failed to parse the metrics bind address %q: %w", addr, err)
+		}
+		cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, port)
+	}
+	for _, path := range svc.Proxy.Expose.Paths {
+		if path.ListenerPort != 0 {
+			cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(path.ListenerPort))
+		}
+	}
+	return cfg, nil
+}
+
+// ip6tablesExecutor is an iptables.Provider that applies the rules with ip6tables.
+type ip6tablesExecutor struct {
+	commands []*exec.Cmd
+}
+
+// AddRule adds the rule that iptables.Setup generates for iptables as an
+// ip6tables rule. The only IPv4 address in the rules, which is the loopback
+// address that outbound traffic isn't redirected for, is replaced with the
+// IPv6 loopback address.
+func (e *ip6tablesExecutor) AddRule(_ string, args ...string) {
+	e.commands = append(e.commands, exec.Command("ip6tables", ip6tablesArgs(args)...))
+}
+
+func (e *ip6tablesExecutor) ApplyRules() error {
+	if _, err := exec.LookPath("ip6tables"); err != nil {
+		return err
+	}
+	for _, cmd := range e.commands {
+		var cmdOutput bytes.Buffer
+		cmd.Stdout = &cmdOutput
+		cmd.Stderr = &cmdOutput
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run command: %s, err: %v, output: %s", cmd.String(), err, cmdOutput.String())
+		}
+	}
+	return nil
+}
+
+func (e *ip6tablesExecutor) Rules() []string {
+	var rules []string
+	for _, cmd := range e.commands {
+		rules = append(rules, cmd.String())
+	}
+	return rules
+}
+
+func ip6tablesArgs(args []string) []string {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "127.0.0.1/32" {
+			arg = "::1/128"
+		}
+		translated[i] = arg
+	}
+	return translated
+}
+
+func isIPv6(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() == nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Apply the transparent proxy traffic redirection rules with ip6tables."
+const help = `
+Usage: consul-k8s-control-plane redirect-traffic-ipv6 [options]
+
+  Applies the transparent proxy traffic redirection rules for IPv6 traffic
+  with ip6tables on IPv6-only and dual-stack clusters.
+  Not intended for stand-alone use.
+`
@@ -0,0 +1,159 @@
+package redirecttrafficipv6
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-proxy-id must be set",
+		},
+		{
+			flags:  []string{"-proxy-id", "web-sidecar-proxy"},
+			expErr: "-proxy-uid must be set",
+		},
+		{
+			flags:  []string{"-proxy-id", "web-sidecar-proxy", "-proxy-uid", "5995"},
+			expErr: "-consul-api-timeout must be set to a value greater than 0",
+		},
+		{
+			flags: []string{"-proxy-id", "web-sidecar-proxy", "-proxy-uid", "5995", "-consul-api-timeout", "5s",
+				"-consul-dns-ip", "10.0.0.10"},
+			expErr: "-consul-dns-ip \"10.0.0.10\" must be an IPv6 address",
+		},
+		{
+			flags: []string{"-proxy-id", "web-sidecar-proxy", "-proxy-uid", "5995", "-consul-api-timeout", "5s",
+				"-exclude-outbound-cidr", "10.0.0.0/8"},
+			expErr: "-exclude-outbound-cidr \"10.0.0.0/8\" must be an IPv6 CIDR",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			exitCode := cmd.Run(c.flags)
+			require.Equal(t, 1, exitCode, ui.ErrorWriter.String())
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+// Test that the rules are generated from the proxy service registered with the agent.
+func TestRun_AppliesRulesForProxyService(t *testing.T) {
+	t.Parallel()
+	proxyService := api.AgentService{
+		Kind:    api.ServiceKindConnectProxy,
+		ID:      "web-sidecar-proxy",
+		Service: "web-sidecar-proxy",
+		Port:    20000,
+		Proxy: &api.AgentServiceConnectProxyConfig{
+			DestinationServiceName: "web",
+			Mode:                   api.ProxyModeTransparent,
+			TransparentProxy:       &api.TransparentProxyConfig{OutboundListenerPort: 15002},
+			Config: map[string]interface{}{
+				"envoy_prometheus_bind_addr": "[::]:20200",
+			},
+			Expose: api.ExposeConfig{
+				Paths: []api.ExposePath{{ListenerPort: 20400, LocalPathPort: 8080, Path: "/health"}},
+			},
+		},
+	}
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/agent/service/web-sidecar-proxy" && r.Method == "GET" {
+			require.NoError(t, json.NewEncoder(w).Encode(proxyService))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer consulServer.Close()
+
+	ui := cli.NewMockUi()
+	provider := &fakeIptablesProvider{}
+	cmd := Command{
+		UI:               ui,
+		iptablesProvider: provider,
+	}
+	code := cmd.Run([]string{
+		"-http-addr", consulServer.URL,
+		"-consul-api-timeout", "5s",
+		"-proxy-id", "web-sidecar-proxy",
+		"-proxy-uid", "5995",
+		"-consul-dns-ip", "fd00::10",
+		"-exclude-outbound-cidr", "fd00:1::/64",
+	})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+	require.True(t, provider.applied)
+
+	rules := strings.Join(provider.rules, "\n")
+	require.Contains(t, rules, "-A CONSUL_PROXY_IN_REDIRECT -p tcp -j REDIRECT --to-port 20000")
+	require.Contains(t, rules, "-A CONSUL_PROXY_REDIRECT -p tcp -j REDIRECT --to-port 15002")
+	require.Contains(t, rules, "-A CONSUL_DNS_REDIRECT -p udp --dport 53 -j DNAT --to-destination fd00::10")
+	require.Contains(t, rules, "-I CONSUL_PROXY_OUTPUT -d fd00:1::/64 -j RETURN")
+	require.Contains(t, rules, "-I CONSUL_PROXY_INBOUND -p tcp --dport 20200 -j RETURN")
+	require.Contains(t, rules, "-I CONSUL_PROXY_INBOUND -p tcp --dport 20400 -j RETURN")
+}
+
+func TestRun_ProxyServiceNotFound(t *testing.T) {
+	t.Parallel()
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer consulServer.Close()
+
+	ui := cli.NewMockUi()
+	provider := &fakeIptablesProvider{}
+	cmd := Command{
+		UI:               ui,
+		iptablesProvider: provider,
+	}
+	code := cmd.Run([]string{
+		"-http-addr", consulServer.URL,
+		"-consul-api-timeout", "5s",
+		"-proxy-id", "web-sidecar-proxy",
+		"-proxy-uid", "5995",
+	})
+	require.Equal(t, 1, code)
+	require.False(t, provider.applied)
+}
+
+func TestIP6tablesExecutor_Rules(t *testing.T) {
+	e := &ip6tablesExecutor{}
+	e.AddRule("iptables", "-t", "nat", "-A", "CONSUL_PROXY_OUTPUT", "-d", "127.0.0.1/32", "-j", "RETURN")
+	require.Len(t, e.Rules(), 1)
+	require.True(t, strings.HasSuffix(e.Rules()[0], "ip6tables -t nat -A CONSUL_PROXY_OUTPUT -d ::1/128 -j RETURN"))
+}
+
+type fakeIptablesProvider struct {
+	rules   []string
+	applied bool
+}
+
+func (f *fakeIptablesProvider) AddRule(name string, args ...string) {
+	f.rules = append(f.rules, strings.Join(append([]string{name}, args...), " "))
+}
+
+func (f *fakeIptablesProvider) ApplyRules() error {
+	f.applied = true
+	return nil
+}
+
+func (f *fakeIptablesProvider) Rules() []string {
+	return f.rules
+}
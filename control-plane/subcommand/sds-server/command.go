@@ -0,0 +1,267 @@
+package sdsserver
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	secretv3 "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Command is the command for serving Kubernetes TLS secrets to Envoy over
+// the secret discovery service (SDS), so that gateways can use certificates
+// that are stored in Kubernetes, such as the ones issued by cert-manager.
+type Command struct {
+	UI cli.Ui
+
+	flags         *flag.FlagSet
+	k8s           *flags.K8SFlags
+	flagNamespace string
+	flagSecrets   []string
+	flagListen    string
+	flagLogLevel  string
+	flagLogJSON   bool
+
+	once      sync.Once
+	help      string
+	k8sClient kubernetes.Interface
+	logger    hclog.Logger
+
+	// resyncPeriod is how often the secrets are listed again in addition to
+	// being watched. This is exposed for setting in tests.
+	resyncPeriod time.Duration
+
+	sigCh chan os.Signal
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.k8s = &flags.K8SFlags{}
+	c.flags.StringVar(&c.flagNamespace, "k8s-namespace", "", "Kubernetes namespace of the secrets.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagSecrets), "secret",
+		"Name of a Kubernetes TLS secret to serve. The SDS resource name of the certificate is the name "+
+			"of the secret. May be specified multiple times.")
+	c.flags.StringVar(&c.flagListen, "listen", "127.0.0.1:20300",
+		"Address to serve SDS on. Defaults to 127.0.0.1:20300.")
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+	flags.Merge(c.flags, c.k8s.Flags())
+	c.help = flags.Usage(help, c.flags)
+
+	if c.resyncPeriod == 0 {
+		c.resyncPeriod = 10 * time.Minute
+	}
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+}
+
+// Run watches the secrets and serves them over SDS until it's interrupted.
+// Envoy is sent the new certificate whenever a secret is updated, e.g. when
+// cert-manager renews it.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if c.flagNamespace == "" {
+		c.UI.Error("-k8s-namespace must be set")
+		return 1
+	}
+	if len(c.flagSecrets) == 0 {
+		c.UI.Error("-secret must be set at least once")
+		return 1
+	}
+
+	// c.k8sClient might already be set in a test.
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	var err error
+	c.logger, err = common.Logger("sds-server", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", c.flagListen)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error listening on %s: %s", c.flagListen, err))
+		return 1
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	// The same certificates are served to every Envoy that connects, so a
+	// linear cache is used rather than a snapshot per Envoy node.
+	secrets := cachev3.NewLinearCache(resourcev3.SecretType)
+	for _, name := range c.flagSecrets {
+		go c.watchSecret(ctx, secrets, name)
+	}
+
+	grpcServer := grpc.NewServer()
+	secretv3.RegisterSecretDiscoveryServiceServer(grpcServer, serverv3.NewServer(ctx, secrets, nil))
+	serveErrCh := make(chan error, 1)
+	go func() {
+		serveErrCh <- grpcServer.Serve(listener)
+	}()
+	c.logger.Info("serving SDS", "address", listener.Addr().String(), "secrets", c.flagSecrets)
+
+	select {
+	case sig := <-c.sigCh:
+		c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+		grpcServer.Stop()
+		return 0
+	case err := <-serveErrCh:
+		c.UI.Error(fmt.Sprintf("Error serving SDS: %s", err))
+		return 1
+	}
+}
+
+// watchSecret keeps the certificate of the secret with the given name up to
+// date in the cache until the context is cancelled. Only the named secret is
+// listed and watched so that the service account can be limited to the
+// secrets it serves.
+func (c *Command) watchSecret(ctx context.Context, secrets *cachev3.LinearCache, name string) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return c.k8sClient.CoreV1().Secrets(c.flagNamespace).List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return c.k8sClient.CoreV1().Secrets(c.flagNamespace).Watch(ctx, options)
+		},
+	}
+	update := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok || secret.Name != name {
+			return
+		}
+		cert, err := tlsSecret(secret)
+		if err != nil {
+			c.logger.Error("unable to serve secret", "name", name, "err", err)
+			c.deleteSecret(secrets, name)
+			return
+		}
+		if err := secrets.UpdateResource(name, cert); err != nil {
+			c.logger.Error("unable to update secret", "name", name, "err", err)
+			return
+		}
+		c.logger.Info("serving certificate", "name", name, "resource-version", secret.ResourceVersion)
+	}
+	_, controller := cache.NewInformer(listWatch, &corev1.Secret{}, c.resyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc: update,
+		UpdateFunc: func(_, newObj interface{}) {
+			update(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if secret, ok := obj.(*corev1.Secret); ok && secret.Name == name {
+				c.logger.Info("secret deleted", "name", name)
+				c.deleteSecret(secrets, name)
+			}
+		},
+	})
+	controller.Run(ctx.Done())
+}
+
+// deleteSecret stops serving the certificate of the secret. Envoy keeps
+// using the certificate it was last sent.
+func (c *Command) deleteSecret(secrets *cachev3.LinearCache, name string) {
+	if err := secrets.DeleteResource(name); err != nil {
+		c.logger.Error("unable to delete secret", "name", name, "err", err)
+	}
+}
+
+// tlsSecret returns the Envoy TLS certificate of the Kubernetes secret.
+func tlsSecret(secret *corev1.Secret) (*tlsv3.Secret, error) {
+	if secret.Type != corev1.SecretTypeTLS {
+		return nil, fmt.Errorf("secret is of type %q, not %q", secret.Type, corev1.SecretTypeTLS)
+	}
+	cert := secret.Data[corev1.TLSCertKey]
+	key := secret.Data[corev1.TLSPrivateKeyKey]
+	if len(cert) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("secret must have %s and %s", corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	return &tlsv3.Secret{
+		Name: secret.Name,
+		Type: &tlsv3.Secret_TlsCertificate{
+			TlsCertificate: &tlsv3.TlsCertificate{
+				CertificateChain: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: cert},
+				},
+				PrivateKey: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: key},
+				},
+			},
+		},
+	}, nil
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Serve Kubernetes TLS secrets to Envoy over SDS."
+const help = `
+Usage: consul-k8s-control-plane sds-server [options]
+
+  Serves the Kubernetes TLS secrets specified by -secret in namespace
+  -k8s-namespace to Envoy over the secret discovery service (SDS).
+  Ingress gateways reference a certificate with the name of its secret
+  as the SDS resource name. Envoy is sent the new certificate whenever
+  a secret is updated.
+`
@@ -0,0 +1,193 @@
+package sdsserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	secretv3 "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		args   []string
+		expErr string
+	}{
+		{
+			[]string{},
+			"-k8s-namespace must be set",
+		},
+		{
+			[]string{"-k8s-namespace=default"},
+			"-secret must be set at least once",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:        ui,
+				k8sClient: fake.NewSimpleClientset(),
+			}
+			responseCode := cmd.Run(c.args)
+			require.Equal(t, 1, responseCode)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+// Test that the certificate of a secret is served, and that the new
+// certificate is sent when the secret is updated.
+func TestRun_ServesSecrets(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset(tlsK8SSecret("ingress-cert", "cert-1"))
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:           ui,
+		k8sClient:    k8s,
+		resyncPeriod: time.Second,
+		sigCh:        make(chan os.Signal, 1),
+	}
+	address := fmt.Sprintf("127.0.0.1:%d", freeport.GetN(t, 1)[0])
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-k8s-namespace=default",
+		"-secret=ingress-cert",
+		"-listen", address,
+	})
+	defer stopCommand(t, &cmd, exitCh)
+
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var stream secretv3.SecretDiscoveryService_StreamSecretsClient
+	require.Eventually(t, func() bool {
+		stream, err = secretv3.NewSecretDiscoveryServiceClient(conn).StreamSecrets(ctx)
+		if err != nil {
+			return false
+		}
+		err = stream.Send(&discoveryv3.DiscoveryRequest{
+			TypeUrl:       resourcev3.SecretType,
+			ResourceNames: []string{"ingress-cert"},
+		})
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "cert-1", servedCertificate(t, resp))
+
+	_, err = k8s.CoreV1().Secrets("default").Update(context.Background(), tlsK8SSecret("ingress-cert", "cert-2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Acknowledge the first certificate so that the next one is sent.
+	require.NoError(t, stream.Send(&discoveryv3.DiscoveryRequest{
+		TypeUrl:       resourcev3.SecretType,
+		ResourceNames: []string{"ingress-cert"},
+		VersionInfo:   resp.VersionInfo,
+		ResponseNonce: resp.Nonce,
+	}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "cert-2", servedCertificate(t, resp))
+}
+
+func TestTLSSecret(t *testing.T) {
+	cases := map[string]struct {
+		secret *corev1.Secret
+		expErr string
+	}{
+		"valid": {
+			secret: tlsK8SSecret("cert", "cert"),
+		},
+		"not a TLS secret": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cert"},
+				Type:       corev1.SecretTypeOpaque,
+			},
+			expErr: `secret is of type "Opaque", not "kubernetes.io/tls"`,
+		},
+		"no private key": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cert"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey: []byte("cert"),
+				},
+			},
+			expErr: "secret must have tls.crt and tls.key",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret, err := tlsSecret(c.secret)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "cert", secret.Name)
+			require.Equal(t, []byte("cert"), secret.GetTlsCertificate().CertificateChain.GetInlineBytes())
+			require.Equal(t, []byte("key"), secret.GetTlsCertificate().PrivateKey.GetInlineBytes())
+		})
+	}
+}
+
+// servedCertificate returns the certificate chain of the single secret in the
+// response.
+func servedCertificate(t *testing.T, resp *discoveryv3.DiscoveryResponse) string {
+	require.Len(t, resp.Resources, 1)
+	var secret tlsv3.Secret
+	require.NoError(t, resp.Resources[0].UnmarshalTo(&secret))
+	require.Equal(t, "ingress-cert", secret.Name)
+	return string(secret.GetTlsCertificate().CertificateChain.GetInlineBytes())
+}
+
+func tlsK8SSecret(name, cert string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte(cert),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		},
+	}
+}
+
+// This function starts the command asynchronously and returns a non-blocking chan.
+// When finished, the command will send its exit code to the channel.
+// Note that it's the responsibility of the caller to terminate the command by calling stopCommand,
+// otherwise it can run forever.
+func runCommandAsynchronously(cmd *Command, args []string) chan int {
+	exitChan := make(chan int, 1)
+	go func() {
+		exitChan <- cmd.Run(args)
+	}()
+	return exitChan
+}
+
+func stopCommand(t *testing.T, cmd *Command, exitChan chan int) {
+	if len(exitChan) == 0 {
+		cmd.sigCh <- syscall.SIGINT
+	}
+	c := <-exitChan
+	require.Equal(t, 0, c, string(cmd.UI.(*cli.MockUi).ErrorWriter.Bytes()))
+}
@@ -0,0 +1,202 @@
+package serveraclinit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// authMethodConfig is the configuration of an OIDC or JWT auth method in the
+// file passed with -auth-methods-config-file.
+type authMethodConfig struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	DisplayName   string `json:"displayName"`
+	Description   string `json:"description"`
+	MaxTokenTTL   string `json:"maxTokenTTL"`
+	TokenLocality string `json:"tokenLocality"`
+
+	// Config is the type-specific configuration of the auth method, with
+	// the keys documented by Consul, e.g. OIDCDiscoveryURL.
+	Config map[string]interface{} `json:"config"`
+
+	// OIDCClientSecretFile is the file containing the OIDC client secret,
+	// which is set as Config.OIDCClientSecret so it isn't stored in the
+	// config file.
+	OIDCClientSecretFile string `json:"oidcClientSecretFile"`
+
+	BindingRules []bindingRuleConfig `json:"bindingRules"`
+}
+
+// bindingRuleConfig is the configuration of a binding rule of an auth method.
+type bindingRuleConfig struct {
+	Description string                  `json:"description"`
+	Selector    string                  `json:"selector"`
+	BindType    api.BindingRuleBindType `json:"bindType"`
+	BindName    string                  `json:"bindName"`
+}
+
+// loadAuthMethodsConfig reads and validates the auth methods in file.
+func loadAuthMethodsConfig(file string) ([]authMethodConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading auth methods config file %q: %s", file, err)
+	}
+	var methods []authMethodConfig
+	if err := json.Unmarshal(data, &methods); err != nil {
+		return nil, fmt.Errorf("parsing auth methods config file %q: %s", file, err)
+	}
+
+	names := make(map[string]bool)
+	for i, m := range methods {
+		if m.Name == "" {
+			return nil, fmt.Errorf("auth method %d has no name", i)
+		}
+		if names[m.Name] {
+			return nil, fmt.Errorf("auth method %q is configured more than once", m.Name)
+		}
+		names[m.Name] = true
+		if m.Type != "oidc" && m.Type != "jwt" {
+			return nil, fmt.Errorf("auth method %q has type %q: only oidc and jwt auth methods can be configured", m.Name, m.Type)
+		}
+		if m.MaxTokenTTL != "" {
+			if _, err := time.ParseDuration(m.MaxTokenTTL); err != nil {
+				return nil, fmt.Errorf("auth method %q has invalid maxTokenTTL %q: %s", m.Name, m.MaxTokenTTL, err)
+			}
+		}
+		if m.TokenLocality != "" && m.TokenLocality != "local" && m.TokenLocality != "global" {
+			return nil, fmt.Errorf("auth method %q has tokenLocality %q: must be local or global", m.Name, m.TokenLocality)
+		}
+		for j, r := range m.BindingRules {
+			if r.BindName == "" {
+				return nil, fmt.Errorf("binding rule %d of auth method %q has no bindName", j, m.Name)
+			}
+			switch r.BindType {
+			case "", api.BindingRuleBindTypeService, api.BindingRuleBindTypeRole:
+			default:
+				return nil, fmt.Errorf("binding rule %d of auth method %q has invalid bindType %q", j, m.Name, r.BindType)
+			}
+		}
+		if m.OIDCClientSecretFile != "" {
+			if m.Type != "oidc" {
+				return nil, fmt.Errorf("auth method %q has an OIDC client secret but is of type %q", m.Name, m.Type)
+			}
+			secret, err := ioutil.ReadFile(m.OIDCClientSecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading OIDC client secret of auth method %q: %s", m.Name, err)
+			}
+			if methods[i].Config == nil {
+				methods[i].Config = make(map[string]interface{})
+			}
+			methods[i].Config["OIDCClientSecret"] = strings.TrimSpace(string(secret))
+		}
+	}
+	return methods, nil
+}
+
+// configureAuthMethods creates or updates the configured auth methods and
+// makes their binding rules match the configuration, deleting binding rules
+// that aren't configured. Auth methods that issue global tokens are written
+// to the primary datacenter.
+func (c *Command) configureAuthMethods(consulClient *api.Client, methods []authMethodConfig, primaryDC string) error {
+	for _, m := range methods {
+		authMethod := api.ACLAuthMethod{
+			Name:          m.Name,
+			Type:          m.Type,
+			DisplayName:   m.DisplayName,
+			Description:   m.Description,
+			TokenLocality: m.TokenLocality,
+			Config:        m.Config,
+		}
+		if m.MaxTokenTTL != "" {
+			// The TTL was validated when the config was loaded.
+			authMethod.MaxTokenTTL, _ = time.ParseDuration(m.MaxTokenTTL)
+		}
+		writeOptions := &api.WriteOptions{}
+		queryOptions := &api.QueryOptions{}
+		if m.TokenLocality == "global" {
+			writeOptions.Datacenter = primaryDC
+			queryOptions.Datacenter = primaryDC
+		}
+		if err := c.createAuthMethod(consulClient, &authMethod, writeOptions); err != nil {
+			return err
+		}
+		if err := c.syncBindingRules(consulClient, m, queryOptions, writeOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncBindingRules updates the existing binding rules of the auth method that
+// match a configured rule's description, bind type and bind name, creates the
+// rest of the configured rules and deletes the existing rules that aren't
+// configured.
+func (c *Command) syncBindingRules(consulClient *api.Client, m authMethodConfig, queryOptions *api.QueryOptions, writeOptions *api.WriteOptions) error {
+	var existingRules []*api.ACLBindingRule
+	err := c.untilSucceeds(fmt.Sprintf("listing binding rules for auth method %s", m.Name),
+		func() error {
+			var err error
+			existingRules, _, err = consulClient.ACL().BindingRuleList(m.Name, queryOptions)
+			return err
+		})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range m.BindingRules {
+		abr := &api.ACLBindingRule{
+			Description: r.Description,
+			AuthMethod:  m.Name,
+			Selector:    r.Selector,
+			BindType:    r.BindType,
+			BindName:    r.BindName,
+		}
+		if abr.BindType == "" {
+			abr.BindType = api.BindingRuleBindTypeRole
+		}
+		for i, existing := range existingRules {
+			if existing != nil && existing.Description == abr.Description && existing.BindType == abr.BindType && existing.BindName == abr.BindName {
+				abr.ID = existing.ID
+				existingRules[i] = nil
+				break
+			}
+		}
+		if abr.ID == "" {
+			err = c.untilSucceeds(fmt.Sprintf("creating acl binding rule for %s", m.Name),
+				func() error {
+					_, _, err := consulClient.ACL().BindingRuleCreate(abr, writeOptions)
+					return err
+				})
+		} else {
+			err = c.untilSucceeds(fmt.Sprintf("updating acl binding rule for %s", m.Name),
+				func() error {
+					_, _, err := consulClient.ACL().BindingRuleUpdate(abr, writeOptions)
+					return err
+				})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, existing := range existingRules {
+		if existing == nil {
+			continue
+		}
+		id := existing.ID
+		err = c.untilSucceeds(fmt.Sprintf("deleting acl binding rule %s for %s", id, m.Name),
+			func() error {
+				_, err := consulClient.ACL().BindingRuleDelete(id, writeOptions)
+				return err
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package serveraclinit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTPublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEdQnmiQinJg1VrJHOU0B6/EP6cZSF
+5bDVgUvPoLBOAqymldF84ddykP8mYjgmGZhcuxJNfTQBSdeW79D5sRIggA==
+-----END PUBLIC KEY-----`
+
+// Test that the configured auth methods are created and their binding rules
+// are kept in sync with the config file.
+func TestRun_AuthMethods(t *testing.T) {
+	t.Parallel()
+
+	k8s, testSvr := completeSetup(t)
+	defer testSvr.Stop()
+	setUpK8sServiceAccount(t, k8s, ns)
+
+	configFile := filepath.Join(t.TempDir(), "auth-methods.json")
+	run := func(config string) {
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0600))
+		ui := cli.NewMockUi()
+		cmd := Command{
+			UI:        ui,
+			clientset: k8s,
+		}
+		responseCode := cmd.Run([]string{
+			"-timeout=1m",
+			"-k8s-namespace=" + ns,
+			"-server-address", strings.Split(testSvr.HTTPAddr, ":")[0],
+			"-server-port", strings.Split(testSvr.HTTPAddr, ":")[1],
+			"-resource-prefix=" + resourcePrefix,
+			"-consul-api-timeout", "5s",
+			"-auth-methods-config-file=" + configFile,
+		})
+		require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+	}
+
+	pubKeys := `["` + strings.ReplaceAll(testJWTPublicKey, "\n", `\n`) + `"]`
+	run(`[{
+		"name": "ci",
+		"type": "jwt",
+		"maxTokenTTL": "10m",
+		"config": {"JWTValidationPubKeys": ` + pubKeys + `, "BoundIssuer": "ci"},
+		"bindingRules": [
+			{"description": "deploy", "bindType": "role", "bindName": "deployer", "selector": "value.project==web"},
+			{"description": "read", "bindName": "reader"}
+		]
+	}]`)
+
+	consul, err := api.NewClient(&api.Config{
+		Address: testSvr.HTTPAddr,
+		Token:   getBootToken(t, k8s, resourcePrefix, ns),
+	})
+	require.NoError(t, err)
+	authMethod, _, err := consul.ACL().AuthMethodRead("ci", nil)
+	require.NoError(t, err)
+	require.Equal(t, "jwt", authMethod.Type)
+	require.Equal(t, 10*time.Minute, authMethod.MaxTokenTTL)
+	require.Equal(t, "ci", authMethod.Config["BoundIssuer"])
+	rules, _, err := consul.ACL().BindingRuleList("ci", nil)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	var deployRuleID string
+	for _, r := range rules {
+		if r.Description == "deploy" {
+			deployRuleID = r.ID
+		}
+	}
+	require.NotEmpty(t, deployRuleID)
+
+	// The deploy rule is updated and the read rule, which was removed from
+	// the config, is deleted.
+	run(`[{
+		"name": "ci",
+		"type": "jwt",
+		"config": {"JWTValidationPubKeys": ` + pubKeys + `, "BoundIssuer": "ci"},
+		"bindingRules": [
+			{"description": "deploy", "bindType": "role", "bindName": "deployer", "selector": "value.project==api"}
+		]
+	}]`)
+	rules, _, err = consul.ACL().BindingRuleList("ci", nil)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, deployRuleID, rules[0].ID)
+	require.Equal(t, "value.project==api", rules[0].Selector)
+}
+
+func TestLoadAuthMethodsConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "client-secret")
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("secret\n"), 0600))
+
+	cases := map[string]struct {
+		config string
+		expErr string
+	}{
+		"valid": {
+			config: `[{"name": "okta", "type": "oidc", "oidcClientSecretFile": "` + secretFile + `",
+				"bindingRules": [{"bindType": "role", "bindName": "admin"}]}]`,
+		},
+		"no name": {
+			config: `[{"type": "oidc"}]`,
+			expErr: "auth method 0 has no name",
+		},
+		"duplicate name": {
+			config: `[{"name": "ci", "type": "jwt"}, {"name": "ci", "type": "jwt"}]`,
+			expErr: `auth method "ci" is configured more than once`,
+		},
+		"kubernetes type": {
+			config: `[{"name": "k8s", "type": "kubernetes"}]`,
+			expErr: `auth method "k8s" has type "kubernetes": only oidc and jwt auth methods can be configured`,
+		},
+		"invalid max token TTL": {
+			config: `[{"name": "ci", "type": "jwt", "maxTokenTTL": "10"}]`,
+			expErr: `auth method "ci" has invalid maxTokenTTL "10"`,
+		},
+		"invalid token locality": {
+			config: `[{"name": "ci", "type": "jwt", "tokenLocality": "remote"}]`,
+			expErr: `auth method "ci" has tokenLocality "remote": must be local or global`,
+		},
+		"binding rule without bind name": {
+			config: `[{"name": "ci", "type": "jwt", "bindingRules": [{"bindType": "role"}]}]`,
+			expErr: `binding rule 0 of auth method "ci" has no bindName`,
+		},
+		"invalid bind type": {
+			config: `[{"name": "ci", "type": "jwt", "bindingRules": [{"bindType": "token", "bindName": "foo"}]}]`,
+			expErr: `binding rule 0 of auth method "ci" has invalid bindType "token"`,
+		},
+		"client secret for jwt": {
+			config: `[{"name": "ci", "type": "jwt", "oidcClientSecretFile": "` + secretFile + `"}]`,
+			expErr: `auth method "ci" has an OIDC client secret but is of type "jwt"`,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			configFile, err := ioutil.TempFile(dir, "")
+			require.NoError(t, err)
+			defer os.Remove(configFile.Name())
+			_, err = configFile.WriteString(c.config)
+			require.NoError(t, err)
+
+			methods, err := loadAuthMethodsConfig(configFile.Name())
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, methods, 1)
+			require.Equal(t, "secret", methods[0].Config["OIDCClientSecret"])
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package serveraclinit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// configureAWSIAMComponentAuthMethods sets up the AWS IAM auth methods that
+// Consul components can log in to instead of the Kubernetes component auth
+// methods. Binding rules are added to them for the same roles as to the
+// Kubernetes auth method they replace, selecting the IAM role named after the
+// component's service account.
+func (c *Command) configureAWSIAMComponentAuthMethods(consulClient *api.Client, localComponentAuthMethodName, globalComponentAuthMethodName, consulDC, primaryDC string, primary bool) error {
+	c.awsIAMComponentAuthMethods = make(map[string]string)
+
+	localAuthMethod := c.awsIAMAuthMethodTmpl(c.withPrefix("aws-iam-component-auth-method"))
+	if err := c.createAuthMethod(consulClient, &localAuthMethod, &api.WriteOptions{}); err != nil {
+		return err
+	}
+	c.awsIAMComponentAuthMethods[localComponentAuthMethodName] = localAuthMethod.Name
+
+	if !primary {
+		globalAuthMethod := c.awsIAMAuthMethodTmpl(fmt.Sprintf("%s-%s", localAuthMethod.Name, consulDC))
+		globalAuthMethod.TokenLocality = "global"
+		if err := c.createAuthMethod(consulClient, &globalAuthMethod, &api.WriteOptions{Datacenter: primaryDC}); err != nil {
+			return err
+		}
+		c.awsIAMComponentAuthMethods[globalComponentAuthMethodName] = globalAuthMethod.Name
+	}
+	return nil
+}
+
+// configureAWSIAMConnectInjectAuthMethod sets up an AWS IAM auth method that
+// connect-injected services can log in to with the IAM role of their pod. The
+// service identity of the token is the name of the IAM role.
+func (c *Command) configureAWSIAMConnectInjectAuthMethod(consulClient *api.Client, authMethodName string) error {
+	authMethod := c.awsIAMAuthMethodTmpl(authMethodName)
+
+	// The auth method is created in the same namespace as the Kubernetes
+	// connect inject auth method, which also ensures that namespace exists.
+	writeOptions := &api.WriteOptions{}
+	if c.flagEnableNamespaces && !c.flagEnableInjectK8SNSMirroring {
+		writeOptions.Namespace = c.flagConsulInjectDestinationNamespace
+	}
+	if err := c.createAuthMethod(consulClient, &authMethod, writeOptions); err != nil {
+		return err
+	}
+
+	abr := api.ACLBindingRule{
+		Description: "AWS IAM binding rule",
+		AuthMethod:  authMethodName,
+		BindType:    api.BindingRuleBindTypeService,
+		BindName:    "${entity_name}",
+	}
+	return c.createConnectBindingRule(consulClient, authMethodName, &abr)
+}
+
+// awsIAMAuthMethodTmpl returns an AWS IAM auth method configured with the
+// -aws-iam-auth-method flags.
+func (c *Command) awsIAMAuthMethodTmpl(authMethodName string) api.ACLAuthMethod {
+	config := map[string]interface{}{
+		"BoundIAMPrincipalARNs": c.flagAWSIAMBoundARNs,
+	}
+	if c.flagAWSIAMServerIDHeaderValue != "" {
+		config["ServerIDHeaderValue"] = c.flagAWSIAMServerIDHeaderValue
+	}
+	if c.flagAWSIAMSTSEndpoint != "" {
+		config["STSEndpoint"] = c.flagAWSIAMSTSEndpoint
+	}
+	if c.flagAWSIAMSTSRegion != "" {
+		config["STSRegion"] = c.flagAWSIAMSTSRegion
+	}
+	return api.ACLAuthMethod{
+		Name:        authMethodName,
+		Description: "AWS IAM Auth Method",
+		Type:        "aws-iam",
+		Config:      config,
+	}
+}
@@ -0,0 +1,65 @@
+package serveraclinit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the AWS IAM auth methods are created with binding rules for the
+// component roles and connect-injected services.
+func TestRun_AWSIAMAuthMethods(t *testing.T) {
+	t.Parallel()
+
+	k8s, testSvr := completeSetup(t)
+	setUpK8sServiceAccount(t, k8s, ns)
+	defer testSvr.Stop()
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: k8s,
+	}
+	responseCode := cmd.Run([]string{
+		"-timeout=1m",
+		"-k8s-namespace=" + ns,
+		"-server-address", strings.Split(testSvr.HTTPAddr, ":")[0],
+		"-server-port", strings.Split(testSvr.HTTPAddr, ":")[1],
+		"-resource-prefix=" + resourcePrefix,
+		"-consul-api-timeout", "5s",
+		"-connect-inject",
+		"-aws-iam-auth-method-bound-arn=arn:aws:iam::123456789012:role/*",
+		"-aws-iam-auth-method-server-id-header-value=consul.example.com",
+		"-aws-iam-connect-inject",
+	})
+	require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+
+	consulClient, err := api.NewClient(&api.Config{
+		Address: testSvr.HTTPAddr,
+		Token:   getBootToken(t, k8s, resourcePrefix, ns),
+	})
+	require.NoError(t, err)
+
+	authMethod, _, err := consulClient.ACL().AuthMethodRead(resourcePrefix+"-aws-iam-component-auth-method", nil)
+	require.NoError(t, err)
+	require.NotNil(t, authMethod)
+	require.Equal(t, "aws-iam", authMethod.Type)
+	require.Equal(t, "consul.example.com", authMethod.Config["ServerIDHeaderValue"])
+	rules, _, err := consulClient.ACL().BindingRuleList(authMethod.Name, nil)
+	require.NoError(t, err)
+	var selectors []string
+	for _, r := range rules {
+		selectors = append(selectors, r.Selector)
+	}
+	require.Contains(t, selectors, `entity_name=="`+resourcePrefix+`-client"`)
+	require.Contains(t, selectors, `entity_name=="`+resourcePrefix+`-connect-injector"`)
+
+	rules, _, err = consulClient.ACL().BindingRuleList(resourcePrefix+"-aws-iam-auth-method", nil)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, api.BindingRuleBindTypeService, rules[0].BindType)
+	require.Equal(t, "${entity_name}", rules[0].BindName)
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"regexp"
 	"strings"
 	"sync"
@@ -37,6 +38,12 @@ type Command struct {
 
 	flagAllowDNS bool
 
+	// Flags to configure the anonymous token policy.
+	flagAnonymousTokenServicePrefixes []string
+	flagAnonymousTokenNamespaces      []string
+	flagAnonymousTokenPartitions      []string
+	flagAnonymousTokenPolicyFile      string
+
 	flagSetServerTokens bool
 
 	flagClient bool
@@ -54,6 +61,25 @@ type Command struct {
 
 	flagSnapshotAgent bool
 
+	flagGossipKeyRotation bool
+
+	flagACLLoginAudit bool
+
+	// Flags to configure the TTLs of the Connect CA certificates.
+	flagConnectCALeafCertTTL         time.Duration
+	flagConnectCAIntermediateCertTTL time.Duration
+	flagConnectCARootCertTTL         time.Duration
+
+	// Flag to configure OIDC and JWT auth methods.
+	flagAuthMethodsConfigFile string
+
+	// Flags to configure AWS IAM auth methods.
+	flagAWSIAMBoundARNs           []string
+	flagAWSIAMServerIDHeaderValue string
+	flagAWSIAMSTSEndpoint         string
+	flagAWSIAMSTSRegion           string
+	flagAWSIAMConnectInject       bool
+
 	flagMeshGateway             bool
 	flagIngressGatewayNames     []string
 	flagTerminatingGatewayNames []string
@@ -61,12 +87,13 @@ type Command struct {
 	flagAPIGatewayController bool
 
 	// Flags to configure Consul connection.
-	flagServerAddresses     []string
-	flagServerPort          uint
-	flagConsulCACert        string
-	flagConsulTLSServerName string
-	flagUseHTTPS            bool
-	flagConsulAPITimeout    time.Duration
+	flagServerAddresses      []string
+	flagServerPort           uint
+	flagConsulCACert         string
+	flagConsulTLSServerName  string
+	flagServerTLSServerNames map[string]string
+	flagUseHTTPS             bool
+	flagConsulAPITimeout     time.Duration
 
 	// Flags for ACL replication.
 	flagCreateACLReplicationToken bool
@@ -77,6 +104,9 @@ type Command struct {
 	flagPartitionName      string // name of the Admin Partition
 	flagPartitionTokenFile string
 
+	// Flags to support peering.
+	flagEnablePeering bool // true if cluster peering is enabled
+
 	// Flags to support namespaces.
 	flagEnableNamespaces                 bool   // Use namespacing on all components
 	flagConsulSyncDestinationNamespace   string // Consul namespace to register all catalog sync services into if not mirroring
@@ -89,6 +119,14 @@ type Command struct {
 	// Flag to support a custom bootstrap token.
 	flagBootstrapTokenFile string
 
+	// Flags to store the bootstrap token in Vault.
+	flagVaultAddress                 string
+	flagVaultTokenFile               string
+	flagVaultCACert                  string
+	flagBootstrapTokenVaultReadPath  string
+	flagBootstrapTokenVaultWritePath string
+	flagBootstrapTokenVaultKey       string
+
 	flagLogLevel string
 	flagLogJSON  bool
 	flagTimeout  time.Duration
@@ -100,6 +138,17 @@ type Command struct {
 
 	clientset kubernetes.Interface
 
+	// vault stores the bootstrap token if -bootstrap-token-vault-read-path is set.
+	vault *vaultKV
+
+	// awsIAMComponentAuthMethods maps the name of a Kubernetes component auth
+	// method to the AWS IAM auth method that gets the same binding rules.
+	awsIAMComponentAuthMethods map[string]string
+
+	// anonymousTokenPolicyRules are the rules of the anonymous token policy
+	// read from -anonymous-token-policy-file.
+	anonymousTokenPolicyRules string
+
 	// ctx is cancelled when the command timeout is reached.
 	ctx           context.Context
 	retryDuration time.Duration
@@ -124,6 +173,18 @@ func (c *Command) init() {
 
 	c.flags.BoolVar(&c.flagAllowDNS, "allow-dns", false,
 		"Toggle for updating the anonymous token to allow DNS queries to work")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAnonymousTokenServicePrefixes), "anonymous-token-service-prefix",
+		"Prefix of the names of the services the anonymous token can read. May be specified multiple times. "+
+			"Defaults to all services.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAnonymousTokenNamespaces), "anonymous-token-namespace",
+		"[Enterprise Only] Consul namespace whose services the anonymous token can read. May be specified multiple times. "+
+			"Defaults to all namespaces.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAnonymousTokenPartitions), "anonymous-token-partition",
+		"[Enterprise Only] Admin partition whose services the anonymous token can read. May be specified multiple times. "+
+			"Defaults to all partitions.")
+	c.flags.StringVar(&c.flagAnonymousTokenPolicyFile, "anonymous-token-policy-file", "",
+		"Path to a file with the HCL rules of the anonymous token policy. If set, the rules replace the "+
+			"rules server-acl-init generates for the anonymous token.")
 	c.flags.BoolVar(&c.flagClient, "client", true,
 		"Toggle for creating a client agent token. Default is true.")
 
@@ -148,6 +209,32 @@ func (c *Command) init() {
 		"Toggle for creating a token for the enterprise license job.")
 	c.flags.BoolVar(&c.flagSnapshotAgent, "snapshot-agent", false,
 		"[Enterprise Only] Toggle for configuring ACL login for the snapshot agent.")
+	c.flags.BoolVar(&c.flagGossipKeyRotation, "gossip-encryption-rotate", false,
+		"Toggle for configuring ACL login for the gossip encryption key rotation.")
+	c.flags.BoolVar(&c.flagACLLoginAudit, "acl-login-audit", false,
+		"Toggle for configuring ACL login for the ACL login audit.")
+	c.flags.DurationVar(&c.flagConnectCALeafCertTTL, "connect-ca-leaf-cert-ttl", 0,
+		"TTL of the leaf certificates issued by the Connect CA. If set, the Connect CA configuration is updated to it.")
+	c.flags.DurationVar(&c.flagConnectCAIntermediateCertTTL, "connect-ca-intermediate-cert-ttl", 0,
+		"TTL of the intermediate certificates of the Connect CA. If set, the Connect CA configuration is updated to it.")
+	c.flags.DurationVar(&c.flagConnectCARootCertTTL, "connect-ca-root-cert-ttl", 0,
+		"TTL of the root certificates generated by the Connect CA. If set, the Connect CA configuration is updated to it.")
+	c.flags.StringVar(&c.flagAuthMethodsConfigFile, "auth-methods-config-file", "",
+		"Path to a JSON file with a list of OIDC and JWT auth methods and their binding rules. "+
+			"The auth methods are created or updated and their binding rules are kept in sync with the file.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAWSIAMBoundARNs), "aws-iam-auth-method-bound-arn",
+		"ARN of an IAM principal allowed to log in to the AWS IAM auth methods. May be specified multiple times. "+
+			"If set, an AWS IAM auth method is created that components can log in to with the IAM role named "+
+			"after their service account instead of the Kubernetes component auth method.")
+	c.flags.StringVar(&c.flagAWSIAMServerIDHeaderValue, "aws-iam-auth-method-server-id-header-value", "",
+		"Value of the X-Consul-IAM-ServerID header that the AWS IAM auth methods require in login requests.")
+	c.flags.StringVar(&c.flagAWSIAMSTSEndpoint, "aws-iam-auth-method-sts-endpoint", "",
+		"URL of the STS endpoint the AWS IAM auth methods send login requests to. Defaults to https://sts.amazonaws.com.")
+	c.flags.StringVar(&c.flagAWSIAMSTSRegion, "aws-iam-auth-method-sts-region", "",
+		"Region of the STS endpoint of the AWS IAM auth methods.")
+	c.flags.BoolVar(&c.flagAWSIAMConnectInject, "aws-iam-connect-inject", false,
+		"Toggle for creating an AWS IAM auth method that connect-injected services can log in to with the "+
+			"IAM role of their pod. The name of the IAM role must be the name of the service.")
 	c.flags.BoolVar(&c.flagMeshGateway, "mesh-gateway", false,
 		"Toggle for configuring ACL login for the mesh gateway.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagIngressGatewayNames), "ingress-gateway-name",
@@ -162,13 +249,16 @@ func (c *Command) init() {
 		"Toggle for configuring ACL login for the API gateway controller.")
 
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagServerAddresses), "server-address",
-		"The IP, DNS name or the cloud auto-join string of the Consul server(s). If providing IPs or DNS names, may be specified multiple times. "+
-			"At least one value is required.")
-	c.flags.UintVar(&c.flagServerPort, "server-port", 8500, "The HTTP or HTTPS port of the Consul server. Defaults to 8500.")
+		"The IP, DNS name or the cloud auto-join string of the Consul server(s). If providing IPs or DNS names, may be specified multiple times "+
+			"and may include a port, e.g. consul.example.com:443, to use instead of -server-port. At least one value is required.")
+	c.flags.UintVar(&c.flagServerPort, "server-port", 8500, "The HTTP or HTTPS port of the Consul servers whose -server-address doesn't include a port. Defaults to 8500.")
 	c.flags.StringVar(&c.flagConsulCACert, "consul-ca-cert", "",
 		"Path to the PEM-encoded CA certificate of the Consul cluster.")
 	c.flags.StringVar(&c.flagConsulTLSServerName, "consul-tls-server-name", "",
 		"The server name to set as the SNI header when sending HTTPS requests to Consul.")
+	c.flags.Var((*flags.FlagMapValue)(&c.flagServerTLSServerNames), "server-tls-server-name",
+		"The server name to set as the SNI header when sending HTTPS requests to a Consul server, in the form "+
+			"<server-address>=<server name>, instead of -consul-tls-server-name. May be specified multiple times.")
 	c.flags.BoolVar(&c.flagUseHTTPS, "use-https", false,
 		"Toggle for using HTTPS for all API calls to Consul.")
 
@@ -178,6 +268,8 @@ func (c *Command) init() {
 		"[Enterprise Only] Name of the Admin Partition")
 	c.flags.StringVar(&c.flagPartitionTokenFile, "partition-token-file", "",
 		"[Enterprise Only] Path to file containing ACL token to be used in non-default partitions.")
+	c.flags.BoolVar(&c.flagEnablePeering, "enable-peering", false,
+		"Enables cluster peering. Requires Consul 1.13+.")
 
 	c.flags.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
 		"[Enterprise Only] Enables namespaces, in either a single Consul namespace or mirrored [Enterprise only feature]")
@@ -209,6 +301,21 @@ func (c *Command) init() {
 		"Path to file containing ACL token for creating policies and tokens. This token must have 'acl:write' permissions."+
 			"When provided, servers will not be bootstrapped and their policies and tokens will not be updated.")
 
+	c.flags.StringVar(&c.flagBootstrapTokenVaultReadPath, "bootstrap-token-vault-read-path", "",
+		"Path of a Vault KV version 2 secret, e.g. consul/data/bootstrap-token, to read the bootstrap token from. "+
+			"When set, the bootstrap token is stored in Vault instead of a Kubernetes secret, and is read from Vault "+
+			"if ACLs are already bootstrapped.")
+	c.flags.StringVar(&c.flagBootstrapTokenVaultWritePath, "bootstrap-token-vault-write-path", "",
+		"Path of the Vault KV version 2 secret to write the bootstrap token to. Defaults to -bootstrap-token-vault-read-path.")
+	c.flags.StringVar(&c.flagBootstrapTokenVaultKey, "bootstrap-token-vault-key", "token",
+		"Key of the bootstrap token in the Vault secret.")
+	c.flags.StringVar(&c.flagVaultAddress, "vault-address", "",
+		"Address of the Vault server to store the bootstrap token in.")
+	c.flags.StringVar(&c.flagVaultTokenFile, "vault-token-file", "",
+		"Path to file containing the Vault token to read and write the bootstrap token with.")
+	c.flags.StringVar(&c.flagVaultCACert, "vault-ca-cert", "",
+		"Path to the PEM-encoded CA certificate of the Vault server.")
+
 	c.flags.DurationVar(&c.flagTimeout, "timeout", 10*time.Minute,
 		"How long we'll try to bootstrap ACLs for before timing out, e.g. 1ms, 2s, 3m")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
@@ -276,6 +383,25 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	var authMethods []authMethodConfig
+	if c.flagAuthMethodsConfigFile != "" {
+		var err error
+		authMethods, err = loadAuthMethodsConfig(c.flagAuthMethodsConfigFile)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.flagAnonymousTokenPolicyFile != "" {
+		rules, err := ioutil.ReadFile(c.flagAnonymousTokenPolicyFile)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("unable to read anonymous token policy from file %q: %s", c.flagAnonymousTokenPolicyFile, err))
+			return 1
+		}
+		c.anonymousTokenPolicyRules = string(rules)
+	}
+
 	var providedBootstrapToken string
 	if c.flagBootstrapTokenFile != "" {
 		var err error
@@ -292,7 +418,7 @@ func (c *Command) Run(args []string) int {
 	defer cancel()
 
 	var err error
-	c.log, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+	c.log, err = common.Logger("server-acl-init", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -306,6 +432,17 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	if c.flagBootstrapTokenVaultReadPath != "" && c.vault == nil {
+		c.vault, err = newVaultKV(c.flagVaultAddress, c.flagVaultTokenFile, c.flagVaultCACert)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("Error initializing Vault client: %s", err))
+			return 1
+		}
+	}
+	if c.flagBootstrapTokenVaultWritePath == "" {
+		c.flagBootstrapTokenVaultWritePath = c.flagBootstrapTokenVaultReadPath
+	}
+
 	serverAddresses, err := common.GetResolvedServerAddresses(c.flagServerAddresses, c.providers, c.log)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Unable to discover any Consul addresses from %q: %s", c.flagServerAddresses[0], err))
@@ -331,6 +468,12 @@ func (c *Command) Run(args []string) int {
 		if providedBootstrapToken != "" {
 			c.log.Info("Using provided bootstrap token")
 			bootstrapToken = providedBootstrapToken
+		} else if c.vault != nil {
+			bootstrapToken, err = c.getVaultBootstrapToken()
+			if err != nil {
+				c.log.Error(fmt.Sprintf("Unexpected error reading the bootstrap token from Vault: %s", err))
+				return 1
+			}
 		} else {
 			bootTokenSecretName = c.withPrefix("bootstrap-acl-token")
 			bootstrapToken, err = c.getBootstrapToken(bootTokenSecretName)
@@ -348,15 +491,12 @@ func (c *Command) Run(args []string) int {
 	}
 
 	// For all of the next operations we'll need a Consul client.
-	serverAddr := fmt.Sprintf("%s:%d", serverAddresses[0], c.flagServerPort)
+	serverAddr := c.serverAddress(serverAddresses[0])
 	clientConfig := api.DefaultConfig()
 	clientConfig.Address = serverAddr
 	clientConfig.Scheme = scheme
 	clientConfig.Token = bootstrapToken
-	clientConfig.TLSConfig = api.TLSConfig{
-		Address: c.flagConsulTLSServerName,
-		CAFile:  c.flagConsulCACert,
-	}
+	clientConfig.TLSConfig = c.serverTLSConfig(serverAddresses[0])
 
 	if c.flagEnablePartitions {
 		clientConfig.Partition = c.flagPartitionName
@@ -374,6 +514,16 @@ func (c *Command) Run(args []string) int {
 	c.log.Info("Current datacenter", "datacenter", consulDC, "primaryDC", primaryDC)
 	primary := consulDC == primaryDC
 
+	// The policies, roles and binding rules of the components are created in
+	// the partition of the install, which must be created by partition-init
+	// first in non-default partitions.
+	if c.flagEnablePartitions && c.flagPartitionName != consulDefaultPartition {
+		if err := c.verifyPartitionExists(consulClient); err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
 	if c.flagEnablePartitions && c.flagPartitionName == consulDefaultPartition && primary {
 		// Partition token is local because only the Primary datacenter can have Admin Partitions.
 		if c.flagPartitionTokenFile != "" {
@@ -454,6 +604,14 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	if len(c.flagAWSIAMBoundARNs) > 0 {
+		err = c.configureAWSIAMComponentAuthMethods(consulClient, localComponentAuthMethodName, globalComponentAuthMethodName, consulDC, primaryDC, primary)
+		if err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
 	if c.flagClient {
 		agentRules, err := c.agentRules()
 		if err != nil {
@@ -527,6 +685,14 @@ func (c *Command) Run(args []string) int {
 			return 1
 		}
 
+		if c.flagAWSIAMConnectInject {
+			err = c.configureAWSIAMConnectInjectAuthMethod(consulClient, c.withPrefix("aws-iam-auth-method"))
+			if err != nil {
+				c.log.Error(err.Error())
+				return 1
+			}
+		}
+
 		// The endpoints controller needs an ACL token always.
 		injectRules, err := c.injectRules()
 		if err != nil {
@@ -570,8 +736,48 @@ func (c *Command) Run(args []string) int {
 	}
 
 	if c.flagSnapshotAgent {
+		rules, err := c.snapshotAgentRules()
+		if err != nil {
+			c.log.Error("Error templating snapshot agent rules", "err", err)
+			return 1
+		}
 		serviceAccountName := c.withPrefix("snapshot-agent")
-		if err := c.createACLPolicyRoleAndBindingRule("snapshot-agent", snapshotAgentRules, consulDC, primaryDC, localPolicy, primary, localComponentAuthMethodName, serviceAccountName, consulClient); err != nil {
+		if err := c.createACLPolicyRoleAndBindingRule("snapshot-agent", rules, consulDC, primaryDC, localPolicy, primary, localComponentAuthMethodName, serviceAccountName, consulClient); err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.flagGossipKeyRotation {
+		serviceAccountName := c.withPrefix("gossip-encryption-rotate")
+		if err := c.createACLPolicyRoleAndBindingRule("gossip-encryption-rotate", gossipKeyRotationRules, consulDC, primaryDC, localPolicy, primary, localComponentAuthMethodName, serviceAccountName, consulClient); err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.flagACLLoginAudit {
+		rules, err := c.aclLoginAuditRules()
+		if err != nil {
+			c.log.Error("Error templating ACL login audit rules", "err", err)
+			return 1
+		}
+		serviceAccountName := c.withPrefix("acl-login-audit")
+		if err := c.createACLPolicyRoleAndBindingRule("acl-login-audit", rules, consulDC, primaryDC, localPolicy, primary, localComponentAuthMethodName, serviceAccountName, consulClient); err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.flagConnectCALeafCertTTL > 0 || c.flagConnectCAIntermediateCertTTL > 0 || c.flagConnectCARootCertTTL > 0 {
+		if err := c.configureConnectCATTLs(consulClient); err != nil {
+			c.log.Error(err.Error())
+			return 1
+		}
+	}
+
+	if len(authMethods) > 0 {
+		if err := c.configureAuthMethods(consulClient, authMethods, primaryDC); err != nil {
 			c.log.Error(err.Error())
 			return 1
 		}
@@ -604,7 +810,8 @@ func (c *Command) Run(args []string) int {
 		if !primary {
 			authMethodName = globalComponentAuthMethodName
 		}
-		err = c.createACLPolicyRoleAndBindingRule("mesh-gateway", rules, consulDC, primaryDC, globalPolicy, primary, authMethodName, serviceAccountName, consulClient)
+		err = c.createACLPolicyRoleAndBindingRule("mesh-gateway", rules, consulDC, primaryDC, globalPolicy, primary, authMethodName, serviceAccountName, consulClient,
+			&api.ACLServiceIdentity{ServiceName: "mesh-gateway"})
 		if err != nil {
 			c.log.Error(err.Error())
 			return 1
@@ -613,13 +820,14 @@ func (c *Command) Run(args []string) int {
 
 	if len(c.flagIngressGatewayNames) > 0 {
 		params := ConfigureGatewayParams{
-			GatewayType:    "ingress",
-			GatewayNames:   c.flagIngressGatewayNames,
-			AuthMethodName: localComponentAuthMethodName,
-			RulesGenerator: c.ingressGatewayRules,
-			ConsulDC:       consulDC,
-			PrimaryDC:      primaryDC,
-			Primary:        primary,
+			GatewayType:     "ingress",
+			GatewayNames:    c.flagIngressGatewayNames,
+			AuthMethodName:  localComponentAuthMethodName,
+			RulesGenerator:  c.ingressGatewayRules,
+			ConsulDC:        consulDC,
+			PrimaryDC:       primaryDC,
+			Primary:         primary,
+			ServiceIdentity: true,
 		}
 		err := c.configureGateway(params, consulClient)
 		if err != nil {
@@ -691,6 +899,21 @@ func (c *Command) Run(args []string) int {
 	return 0
 }
 
+// verifyPartitionExists waits until the partition of the install exists.
+func (c *Command) verifyPartitionExists(consulClient *api.Client) error {
+	return c.untilSucceeds(fmt.Sprintf("checking that partition %s exists", c.flagPartitionName),
+		func() error {
+			partition, _, err := consulClient.Partitions().Read(c.ctx, c.flagPartitionName, nil)
+			if err != nil {
+				return err
+			}
+			if partition == nil {
+				return fmt.Errorf("partition %q does not exist: it must be created, e.g. by the partition-init job, before ACLs can be configured in it", c.flagPartitionName)
+			}
+			return nil
+		})
+}
+
 // configureGlobalComponentAuthMethod sets up an AuthMethod in the primary datacenter,
 // that the Consul components will use to issue global ACL tokens with.
 func (c *Command) configureGlobalComponentAuthMethod(consulClient *api.Client, authMethodName, primaryDC string) error {
@@ -746,6 +969,9 @@ type ConfigureGatewayParams struct {
 	PrimaryDC string
 	//Primary specifies whether the ConsulDC is the Primary Data Center
 	Primary bool
+	//ServiceIdentity specifies whether gateways are granted a service identity instead of a policy
+	//generated by RulesGenerator when namespaces are disabled
+	ServiceIdentity bool
 }
 
 func (c *Command) configureGateway(gatewayParams ConfigureGatewayParams, consulClient *api.Client) error {
@@ -786,6 +1012,25 @@ func (c *Command) configureGateway(gatewayParams ConfigureGatewayParams, consulC
 			return errors.New(errMessage)
 		}
 
+		// The names in the Helm chart are specified by users and so may not contain
+		// the words "ingress-gateway" or "terminating-gateway". We need to create unique names for tokens
+		// across all gateway types and so must suffix with either `-ingress-gateway` of `-terminating-gateway`.
+		serviceAccountName := c.withPrefix(name)
+
+		// Without namespaces, a service identity grants exactly the rules of
+		// the gateway, so no policy is needed.
+		if gatewayParams.ServiceIdentity && !c.flagEnableNamespaces {
+			err := c.createACLPolicyRoleAndBindingRule(serviceAccountName, "",
+				gatewayParams.ConsulDC, gatewayParams.PrimaryDC, localPolicy,
+				gatewayParams.Primary, gatewayParams.AuthMethodName, serviceAccountName, consulClient,
+				&api.ACLServiceIdentity{ServiceName: name})
+			if err != nil {
+				c.log.Error(err.Error())
+				return err
+			}
+			continue
+		}
+
 		// Define the gateway rules
 		rules, err := gatewayParams.RulesGenerator(name, namespace)
 		if err != nil {
@@ -797,10 +1042,6 @@ func (c *Command) configureGateway(gatewayParams ConfigureGatewayParams, consulC
 			return errors.New(errMessage)
 		}
 
-		// The names in the Helm chart are specified by users and so may not contain
-		// the words "ingress-gateway" or "terminating-gateway". We need to create unique names for tokens
-		// across all gateway types and so must suffix with either `-ingress-gateway` of `-terminating-gateway`.
-		serviceAccountName := c.withPrefix(name)
 		err = c.createACLPolicyRoleAndBindingRule(serviceAccountName, rules,
 			gatewayParams.ConsulDC, gatewayParams.PrimaryDC, localPolicy,
 			gatewayParams.Primary, gatewayParams.AuthMethodName, serviceAccountName, consulClient)
@@ -830,6 +1071,20 @@ func (c *Command) getBootstrapToken(secretName string) (string, error) {
 	return string(token), nil
 }
 
+// getVaultBootstrapToken returns the existing bootstrap token if there is one
+// by reading it from Vault. If there is no bootstrap token yet, then it returns
+// an empty string (not an error).
+func (c *Command) getVaultBootstrapToken() (string, error) {
+	var token string
+	err := c.untilSucceeds(fmt.Sprintf("reading bootstrap token from Vault secret %q", c.flagBootstrapTokenVaultReadPath),
+		func() error {
+			var err error
+			token, err = c.vault.read(c.flagBootstrapTokenVaultReadPath, c.flagBootstrapTokenVaultKey)
+			return err
+		})
+	return token, err
+}
+
 func (c *Command) configureKubeClient() error {
 	config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
 	if err != nil {
@@ -865,6 +1120,28 @@ func (c *Command) untilSucceeds(opName string, op func() error) error {
 	return nil
 }
 
+// serverAddress returns the address to connect to for a -server-address,
+// which is the -server-port of the host unless it includes a port.
+func (c *Command) serverAddress(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return fmt.Sprintf("%s:%d", host, c.flagServerPort)
+}
+
+// serverTLSConfig returns the TLS config to connect to a -server-address
+// with, which uses its -server-tls-server-name if it's set.
+func (c *Command) serverTLSConfig(host string) api.TLSConfig {
+	serverName := c.flagConsulTLSServerName
+	if name, ok := c.flagServerTLSServerNames[host]; ok {
+		serverName = name
+	}
+	return api.TLSConfig{
+		Address: serverName,
+		CAFile:  c.flagConsulCACert,
+	}
+}
+
 // withPrefix returns the name of resource with the correct prefix based
 // on the -resource-prefix flag.
 func (c *Command) withPrefix(resource string) string {
@@ -975,6 +1252,41 @@ func (c *Command) validateFlags() error {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
 
+	if c.flagConnectCALeafCertTTL < 0 || c.flagConnectCAIntermediateCertTTL < 0 || c.flagConnectCARootCertTTL < 0 {
+		return errors.New("-connect-ca-leaf-cert-ttl, -connect-ca-intermediate-cert-ttl and -connect-ca-root-cert-ttl must not be negative")
+	}
+
+	if c.flagBootstrapTokenVaultReadPath != "" {
+		if c.flagBootstrapTokenFile != "" {
+			return errors.New("-bootstrap-token-vault-read-path can't be set with -bootstrap-token-file")
+		}
+		if c.flagVaultAddress == "" || c.flagVaultTokenFile == "" {
+			return errors.New("-vault-address and -vault-token-file must be set if -bootstrap-token-vault-read-path is set")
+		}
+	} else if c.flagBootstrapTokenVaultWritePath != "" {
+		return errors.New("-bootstrap-token-vault-read-path must be set if -bootstrap-token-vault-write-path is set")
+	}
+
+	if c.flagAWSIAMConnectInject {
+		if len(c.flagAWSIAMBoundARNs) == 0 {
+			return errors.New("-aws-iam-auth-method-bound-arn must be set if -aws-iam-connect-inject is true")
+		}
+		if c.flagEnableNamespaces && c.flagEnableInjectK8SNSMirroring {
+			return errors.New("-aws-iam-connect-inject is not supported with -enable-inject-k8s-namespace-mirroring")
+		}
+	}
+
+	if c.flagAnonymousTokenPolicyFile != "" &&
+		(len(c.flagAnonymousTokenServicePrefixes) > 0 || len(c.flagAnonymousTokenNamespaces) > 0 || len(c.flagAnonymousTokenPartitions) > 0) {
+		return errors.New("-anonymous-token-policy-file can't be set with -anonymous-token-service-prefix, -anonymous-token-namespace or -anonymous-token-partition")
+	}
+	if len(c.flagAnonymousTokenNamespaces) > 0 && !c.flagEnableNamespaces {
+		return errors.New("-enable-namespaces must be 'true' if -anonymous-token-namespace is set")
+	}
+	if len(c.flagAnonymousTokenPartitions) > 0 && !c.flagEnablePartitions {
+		return errors.New("-enable-partitions must be 'true' if -anonymous-token-partition is set")
+	}
+
 	return nil
 }
 
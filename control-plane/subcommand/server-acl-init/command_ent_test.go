@@ -338,13 +338,13 @@ func TestRun_ACLPolicyUpdates(t *testing.T) {
 				"mesh-gateway-policy",
 				"snapshot-agent-policy",
 				"enterprise-license-token",
-				resourcePrefix + "-igw-policy",
-				resourcePrefix + "-anotherigw-policy",
 				resourcePrefix + "-tgw-policy",
 				resourcePrefix + "-anothertgw-policy",
 				"connect-inject-policy",
 				"controller-policy",
 			}
+			// The ingress gateways are granted service identities instead of
+			// policies without namespaces.
 			policies, _, err := consul.ACL().PolicyList(nil)
 			require.NoError(err)
 
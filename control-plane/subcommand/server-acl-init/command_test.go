@@ -64,6 +64,72 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-resource-prefix=prefix"},
 			ExpErr: "-consul-api-timeout must be set to a value greater than 0",
 		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-connect-ca-leaf-cert-ttl=-1h"},
+			ExpErr: "-connect-ca-leaf-cert-ttl, -connect-ca-intermediate-cert-ttl and -connect-ca-root-cert-ttl must not be negative",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-bootstrap-token-vault-read-path=consul/data/bootstrap-token"},
+			ExpErr: "-vault-address and -vault-token-file must be set if -bootstrap-token-vault-read-path is set",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-bootstrap-token-vault-write-path=consul/data/bootstrap-token"},
+			ExpErr: "-bootstrap-token-vault-read-path must be set if -bootstrap-token-vault-write-path is set",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-aws-iam-connect-inject"},
+			ExpErr: "-aws-iam-auth-method-bound-arn must be set if -aws-iam-connect-inject is true",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-anonymous-token-policy-file=/notexist",
+				"-anonymous-token-service-prefix=web"},
+			ExpErr: "-anonymous-token-policy-file can't be set with -anonymous-token-service-prefix, -anonymous-token-namespace or -anonymous-token-partition",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-anonymous-token-namespace=ns"},
+			ExpErr: "-enable-namespaces must be 'true' if -anonymous-token-namespace is set",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-enable-namespaces",
+				"-anonymous-token-partition=default"},
+			ExpErr: "-enable-partitions must be 'true' if -anonymous-token-partition is set",
+		},
+		{
+			Flags: []string{
+				"-server-address=localhost",
+				"-resource-prefix=prefix",
+				"-consul-api-timeout=5s",
+				"-anonymous-token-policy-file=/notexist"},
+			ExpErr: "unable to read anonymous token policy from file \"/notexist\": open /notexist: no such file or directory",
+		},
 		{
 			Flags: []string{
 				"-acl-replication-token-file=/notexist",
@@ -1646,6 +1712,83 @@ func TestRun_AlreadyBootstrapped(t *testing.T) {
 	}
 }
 
+// Test that ACLs are only configured in a non-default partition once the
+// partition exists.
+func TestRun_PartitionMustExist(t *testing.T) {
+	t.Parallel()
+	cases := map[string]bool{
+		"partition exists":         true,
+		"partition does not exist": false,
+	}
+	for name, partitionExists := range cases {
+		partitionExists := partitionExists
+		t.Run(name, func(t *testing.T) {
+			k8s := fake.NewSimpleClientset()
+			setUpK8sServiceAccount(t, k8s, ns)
+
+			var policyPartitions []string
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/agent/self":
+					fmt.Fprintln(w, `{"Config": {"Datacenter": "dc1", "PrimaryDatacenter": "dc1"}}`)
+				case "/v1/partition/part-1":
+					if !partitionExists {
+						w.WriteHeader(404)
+						return
+					}
+					fmt.Fprintln(w, `{"Name": "part-1"}`)
+				case "/v1/acl/policy":
+					policyPartitions = append(policyPartitions, r.URL.Query().Get("partition"))
+					fmt.Fprintln(w, `{}`)
+				case "/v1/acl/tokens", "/v1/acl/binding-rules":
+					fmt.Fprintln(w, `[]`)
+				case "/v1/acl/role/name/release-name-consul-client-acl-role":
+					w.WriteHeader(404)
+				default:
+					fmt.Fprintln(w, `{}`)
+				}
+			}))
+			defer consulServer.Close()
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+
+			bootTokenFile, err := ioutil.TempFile("", "")
+			require.NoError(t, err)
+			defer os.Remove(bootTokenFile.Name())
+			_, err = bootTokenFile.WriteString("partition-token")
+			require.NoError(t, err)
+
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:        ui,
+				clientset: k8s,
+			}
+			responseCode := cmd.Run([]string{
+				"-timeout=500ms",
+				"-resource-prefix=" + resourcePrefix,
+				"-k8s-namespace=" + ns,
+				"-server-address=" + serverURL.Hostname(),
+				"-server-port=" + serverURL.Port(),
+				"-consul-api-timeout", "5s",
+				"-bootstrap-token-file", bootTokenFile.Name(),
+				"-set-server-tokens=false",
+				"-enable-partitions",
+				"-partition=part-1",
+			})
+			if !partitionExists {
+				require.Equal(t, 1, responseCode)
+				require.Empty(t, policyPartitions)
+				return
+			}
+			require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+			require.NotEmpty(t, policyPartitions)
+			for _, partition := range policyPartitions {
+				require.Equal(t, "part-1", partition)
+			}
+		})
+	}
+}
+
 // Test if there is an old bootstrap Secret and the server token exists
 // that we don't try and recreate the token.
 func TestRun_AlreadyBootstrapped_ServerTokenExists(t *testing.T) {
@@ -1884,6 +2027,68 @@ func TestRun_HTTPS(t *testing.T) {
 	require.True(t, ok)
 }
 
+// Test that the port and the TLS server name of a server address are used
+// instead of -server-port and -consul-tls-server-name.
+func TestRun_HTTPS_ServerAddressPortAndTLSServerName(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset()
+	setUpK8sServiceAccount(t, k8s, ns)
+
+	caFile, certFile, keyFile := test.GenerateServerCerts(t)
+
+	srv, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.ACL.Enabled = true
+
+		c.CAFile = caFile
+		c.CertFile = certFile
+		c.KeyFile = keyFile
+	})
+	require.NoError(t, err)
+	defer srv.Stop()
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: k8s,
+	}
+
+	responseCode := cmd.Run([]string{
+		"-timeout=1m",
+		"-resource-prefix=" + resourcePrefix,
+		"-k8s-namespace=" + ns,
+		"-use-https",
+		"-consul-tls-server-name", "wrong.example.com",
+		"-server-tls-server-name", srv.HTTPSAddr + "=server.dc1.consul",
+		"-consul-ca-cert", caFile,
+		"-server-address=" + srv.HTTPSAddr,
+		"-server-port=1",
+		"-consul-api-timeout", "5s",
+	})
+	require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+
+	tokenSecret, err := k8s.CoreV1().Secrets(ns).Get(context.Background(), resourcePrefix+"-bootstrap-acl-token", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, tokenSecret)
+}
+
+func TestServerAddressAndTLSConfig(t *testing.T) {
+	cmd := Command{
+		flagServerPort:          8501,
+		flagConsulCACert:        "/ca.crt",
+		flagConsulTLSServerName: "server.dc1.consul",
+		flagServerTLSServerNames: map[string]string{
+			"lb.example.com:443": "lb.example.com",
+		},
+	}
+
+	require.Equal(t, "consul.example.com:8501", cmd.serverAddress("consul.example.com"))
+	require.Equal(t, "lb.example.com:443", cmd.serverAddress("lb.example.com:443"))
+	require.Equal(t, "[::1]:443", cmd.serverAddress("[::1]:443"))
+
+	require.Equal(t, api.TLSConfig{Address: "server.dc1.consul", CAFile: "/ca.crt"}, cmd.serverTLSConfig("consul.example.com"))
+	require.Equal(t, api.TLSConfig{Address: "lb.example.com", CAFile: "/ca.crt"}, cmd.serverTLSConfig("lb.example.com:443"))
+}
+
 // Test that the ACL replication token created from the primary DC can be used
 // for replication in the secondary DC.
 func TestRun_ACLReplicationTokenValid(t *testing.T) {
@@ -2103,10 +2308,11 @@ func TestRun_PoliciesAndBindingRulesForACLLogin_PrimaryDatacenter(t *testing.T)
 	t.Parallel()
 
 	cases := []struct {
-		TestName    string
-		TokenFlags  []string
-		PolicyNames []string
-		Roles       []string
+		TestName          string
+		TokenFlags        []string
+		PolicyNames       []string
+		ServiceIdentities []string
+		Roles             []string
 	}{
 		{
 			TestName:    "Controller",
@@ -2139,10 +2345,23 @@ func TestRun_PoliciesAndBindingRulesForACLLogin_PrimaryDatacenter(t *testing.T)
 			Roles:       []string{resourcePrefix + "-snapshot-agent-acl-role"},
 		},
 		{
-			TestName:    "Mesh Gateway",
-			TokenFlags:  []string{"-mesh-gateway"},
-			PolicyNames: []string{"mesh-gateway-policy"},
-			Roles:       []string{resourcePrefix + "-mesh-gateway-acl-role"},
+			TestName:    "Gossip Encryption Rotate",
+			TokenFlags:  []string{"-gossip-encryption-rotate"},
+			PolicyNames: []string{"gossip-encryption-rotate-policy"},
+			Roles:       []string{resourcePrefix + "-gossip-encryption-rotate-acl-role"},
+		},
+		{
+			TestName:    "ACL Login Audit",
+			TokenFlags:  []string{"-acl-login-audit"},
+			PolicyNames: []string{"acl-login-audit-policy"},
+			Roles:       []string{resourcePrefix + "-acl-login-audit-acl-role"},
+		},
+		{
+			TestName:          "Mesh Gateway",
+			TokenFlags:        []string{"-mesh-gateway"},
+			PolicyNames:       []string{"mesh-gateway-policy"},
+			ServiceIdentities: []string{"mesh-gateway"},
+			Roles:             []string{resourcePrefix + "-mesh-gateway-acl-role"},
 		},
 		{
 			TestName:    "Client",
@@ -2167,9 +2386,7 @@ func TestRun_PoliciesAndBindingRulesForACLLogin_PrimaryDatacenter(t *testing.T)
 			TokenFlags: []string{"-ingress-gateway-name=ingress",
 				"-ingress-gateway-name=gateway",
 				"-ingress-gateway-name=another-gateway"},
-			PolicyNames: []string{resourcePrefix + "-ingress-policy",
-				resourcePrefix + "-gateway-policy",
-				resourcePrefix + "-another-gateway-policy"},
+			ServiceIdentities: []string{"ingress", "gateway", "another-gateway"},
 			Roles: []string{resourcePrefix + "-ingress-acl-role",
 				resourcePrefix + "-gateway-acl-role",
 				resourcePrefix + "-another-gateway-acl-role"},
@@ -2208,31 +2425,41 @@ func TestRun_PoliciesAndBindingRulesForACLLogin_PrimaryDatacenter(t *testing.T)
 
 			// Check that the Role exists + has correct Policy and is associated with a BindingRule.
 			for i := range c.Roles {
-				// Check that the Policy exists.
-				policy, _, err := consul.ACL().PolicyReadByName(c.PolicyNames[i], &api.QueryOptions{})
-				require.NoError(t, err)
-				require.NotNil(t, policy)
-
 				// Check that the Role exists.
 				role, _, err := consul.ACL().RoleReadByName(c.Roles[i], &api.QueryOptions{})
 				require.NoError(t, err)
 				require.NotNil(t, role)
 
-				// Check that the Role references the Policy.
-				found := false
-				for j := range role.Policies {
-					if role.Policies[j].Name == policy.Name {
-						found = true
-						break
+				if len(c.PolicyNames) > 0 {
+					// Check that the Policy exists.
+					policy, _, err := consul.ACL().PolicyReadByName(c.PolicyNames[i], &api.QueryOptions{})
+					require.NoError(t, err)
+					require.NotNil(t, policy)
+
+					// Check that the Role references the Policy.
+					found := false
+					for j := range role.Policies {
+						if role.Policies[j].Name == policy.Name {
+							found = true
+							break
+						}
 					}
+					require.True(t, found)
+				} else {
+					require.Empty(t, role.Policies)
+				}
+
+				// Check that the Role has the service identity of the component.
+				if len(c.ServiceIdentities) > 0 {
+					require.Len(t, role.ServiceIdentities, 1)
+					require.Equal(t, c.ServiceIdentities[i], role.ServiceIdentities[0].ServiceName)
 				}
-				require.True(t, found)
 
 				// Check that there exists a BindingRule that references this Role.
 				rb, _, err := consul.ACL().BindingRuleList(fmt.Sprintf("%s-%s", resourcePrefix, componentAuthMethod), &api.QueryOptions{})
 				require.NoError(t, err)
 				require.NotNil(t, rb)
-				found = false
+				found := false
 				for j := range rb {
 					if rb[j].BindName == c.Roles[i] {
 						found = true
@@ -2260,11 +2487,12 @@ func TestRun_PoliciesAndBindingRulesACLLogin_SecondaryDatacenter(t *testing.T) {
 		primaryDatacenter   = "dc1"
 	)
 	cases := []struct {
-		TestName         string
-		TokenFlags       []string
-		PolicyNames      []string
-		Roles            []string
-		GlobalAuthMethod bool
+		TestName          string
+		TokenFlags        []string
+		PolicyNames       []string
+		ServiceIdentities []string
+		Roles             []string
+		GlobalAuthMethod  bool
 	}{
 		{
 			TestName:         "Controller",
@@ -2302,11 +2530,12 @@ func TestRun_PoliciesAndBindingRulesACLLogin_SecondaryDatacenter(t *testing.T) {
 			GlobalAuthMethod: false,
 		},
 		{
-			TestName:         "Mesh Gateway",
-			TokenFlags:       []string{"-mesh-gateway"},
-			PolicyNames:      []string{"mesh-gateway-policy-" + secondaryDatacenter},
-			Roles:            []string{resourcePrefix + "-mesh-gateway-acl-role-" + secondaryDatacenter},
-			GlobalAuthMethod: true,
+			TestName:          "Mesh Gateway",
+			TokenFlags:        []string{"-mesh-gateway"},
+			PolicyNames:       []string{"mesh-gateway-policy-" + secondaryDatacenter},
+			ServiceIdentities: []string{"mesh-gateway"},
+			Roles:             []string{resourcePrefix + "-mesh-gateway-acl-role-" + secondaryDatacenter},
+			GlobalAuthMethod:  true,
 		},
 		{
 			TestName:         "Client",
@@ -2333,9 +2562,7 @@ func TestRun_PoliciesAndBindingRulesACLLogin_SecondaryDatacenter(t *testing.T) {
 			TokenFlags: []string{"-ingress-gateway-name=ingress",
 				"-ingress-gateway-name=gateway",
 				"-ingress-gateway-name=another-gateway"},
-			PolicyNames: []string{resourcePrefix + "-ingress-policy-" + secondaryDatacenter,
-				resourcePrefix + "-gateway-policy-" + secondaryDatacenter,
-				resourcePrefix + "-another-gateway-policy-" + secondaryDatacenter},
+			ServiceIdentities: []string{"ingress", "gateway", "another-gateway"},
 			Roles: []string{resourcePrefix + "-ingress-acl-role-" + secondaryDatacenter,
 				resourcePrefix + "-gateway-acl-role-" + secondaryDatacenter,
 				resourcePrefix + "-another-gateway-acl-role-" + secondaryDatacenter},
@@ -2379,25 +2606,35 @@ func TestRun_PoliciesAndBindingRulesACLLogin_SecondaryDatacenter(t *testing.T) {
 			retry.Run(t, func(r *retry.R) {
 				// Check that the Role exists + has correct Policy and is associated with a BindingRule.
 				for i := range c.Roles {
-					// Check that the Policy exists.
-					policy, _, err := consul.ACL().PolicyReadByName(c.PolicyNames[i], &api.QueryOptions{Datacenter: primaryDatacenter})
-					require.NoError(r, err)
-					require.NotNil(r, policy)
-
 					// Check that the Role exists.
 					role, _, err := consul.ACL().RoleReadByName(c.Roles[i], &api.QueryOptions{Datacenter: datacenter})
 					require.NoError(r, err)
 					require.NotNil(r, role)
 
-					// Check that the Role references the Policy.
-					found := false
-					for j := range role.Policies {
-						if role.Policies[j].Name == policy.Name {
-							found = true
-							break
+					if len(c.PolicyNames) > 0 {
+						// Check that the Policy exists.
+						policy, _, err := consul.ACL().PolicyReadByName(c.PolicyNames[i], &api.QueryOptions{Datacenter: primaryDatacenter})
+						require.NoError(r, err)
+						require.NotNil(r, policy)
+
+						// Check that the Role references the Policy.
+						found := false
+						for j := range role.Policies {
+							if role.Policies[j].Name == policy.Name {
+								found = true
+								break
+							}
 						}
+						require.True(r, found)
+					} else {
+						require.Empty(r, role.Policies)
+					}
+
+					// Check that the Role has the service identity of the component.
+					if len(c.ServiceIdentities) > 0 {
+						require.Len(r, role.ServiceIdentities, 1)
+						require.Equal(r, c.ServiceIdentities[i], role.ServiceIdentities[0].ServiceName)
 					}
-					require.True(r, found)
 
 					// Check that there exists a BindingRule that references this Role.
 					authMethodName := fmt.Sprintf("%s-%s", resourcePrefix, componentAuthMethod)
@@ -2407,7 +2644,7 @@ func TestRun_PoliciesAndBindingRulesACLLogin_SecondaryDatacenter(t *testing.T) {
 					rb, _, err := consul.ACL().BindingRuleList(authMethodName, &api.QueryOptions{Datacenter: datacenter})
 					require.NoError(r, err)
 					require.NotNil(r, rb)
-					found = false
+					found := false
 					for j := range rb {
 						if rb[j].BindName == c.Roles[i] {
 							found = true
@@ -2464,6 +2701,18 @@ func TestRun_ValidateLoginToken_PrimaryDatacenter(t *testing.T) {
 			Roles:         []string{resourcePrefix + "-snapshot-agent-acl-role"},
 			GlobalToken:   false,
 		},
+		{
+			ComponentName: "gossip-encryption-rotate",
+			TokenFlags:    []string{"-gossip-encryption-rotate"},
+			Roles:         []string{resourcePrefix + "-gossip-encryption-rotate-acl-role"},
+			GlobalToken:   false,
+		},
+		{
+			ComponentName: "acl-login-audit",
+			TokenFlags:    []string{"-acl-login-audit"},
+			Roles:         []string{resourcePrefix + "-acl-login-audit-acl-role"},
+			GlobalToken:   false,
+		},
 		{
 			ComponentName: "mesh-gateway",
 			TokenFlags:    []string{"-mesh-gateway"},
@@ -0,0 +1,54 @@
+package serveraclinit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// configureConnectCATTLs sets the TTLs of the leaf, intermediate and root
+// certificates of the Connect CA that are set with flags. Consul only reads
+// the CA configuration from the server config when the cluster is bootstrapped,
+// so this applies changes to them to existing clusters.
+func (c *Command) configureConnectCATTLs(consulClient *api.Client) error {
+	ttls := map[string]time.Duration{
+		"LeafCertTTL":         c.flagConnectCALeafCertTTL,
+		"IntermediateCertTTL": c.flagConnectCAIntermediateCertTTL,
+		"RootCertTTL":         c.flagConnectCARootCertTTL,
+	}
+	return c.untilSucceeds("updating the Connect CA configuration - PUT /v1/connect/ca/configuration",
+		func() error {
+			caConfig, _, err := consulClient.Connect().CAGetConfig(nil)
+			if err != nil {
+				return err
+			}
+			if caConfig.Config == nil {
+				caConfig.Config = make(map[string]interface{})
+			}
+
+			changed := false
+			for key, ttl := range ttls {
+				if ttl == 0 || connectCATTL(caConfig.Config[key]) == ttl {
+					continue
+				}
+				caConfig.Config[key] = ttl.String()
+				changed = true
+			}
+			if !changed {
+				return nil
+			}
+			_, err = consulClient.Connect().CASetConfig(caConfig, nil)
+			return err
+		})
+}
+
+// connectCATTL returns the duration of a TTL in the Connect CA configuration,
+// or 0 if it isn't a valid duration.
+func connectCATTL(value interface{}) time.Duration {
+	ttl, err := time.ParseDuration(fmt.Sprint(value))
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
@@ -0,0 +1,55 @@
+package serveraclinit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the TTLs of the Connect CA certificates are updated in an
+// existing cluster.
+func TestRun_ConnectCATTLs(t *testing.T) {
+	t.Parallel()
+
+	k8s, testSvr := completeSetup(t)
+	defer testSvr.Stop()
+	setUpK8sServiceAccount(t, k8s, ns)
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:        ui,
+		clientset: k8s,
+	}
+	responseCode := cmd.Run([]string{
+		"-timeout=1m",
+		"-k8s-namespace=" + ns,
+		"-server-address", strings.Split(testSvr.HTTPAddr, ":")[0],
+		"-server-port", strings.Split(testSvr.HTTPAddr, ":")[1],
+		"-resource-prefix=" + resourcePrefix,
+		"-consul-api-timeout", "5s",
+		"-connect-ca-leaf-cert-ttl=12h",
+		"-connect-ca-root-cert-ttl=2000h",
+	})
+	require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+
+	consul, err := api.NewClient(&api.Config{
+		Address: testSvr.HTTPAddr,
+		Token:   getBootToken(t, k8s, resourcePrefix, ns),
+	})
+	require.NoError(t, err)
+	caConfig, _, err := consul.Connect().CAGetConfig(nil)
+	require.NoError(t, err)
+	require.Equal(t, 12*time.Hour, connectCATTL(caConfig.Config["LeafCertTTL"]))
+	require.Equal(t, 2000*time.Hour, connectCATTL(caConfig.Config["RootCertTTL"]))
+}
+
+func TestConnectCATTL(t *testing.T) {
+	require.Equal(t, 72*time.Hour, connectCATTL("72h"))
+	require.Equal(t, 72*time.Hour, connectCATTL("72h0m0s"))
+	require.Equal(t, time.Duration(0), connectCATTL(nil))
+	require.Equal(t, time.Duration(0), connectCATTL("foo"))
+}
@@ -13,45 +13,53 @@ import (
 // createACLPolicyRoleAndBindingRule will create the ACL Policy for the component
 // then create a set of ACLRole and ACLBindingRule which tie the component's serviceaccount
 // to the authMethod, allowing the serviceaccount to later be allowed to issue a Consul Login.
-func (c *Command) createACLPolicyRoleAndBindingRule(componentName, rules, dc, primaryDC string, global, primary bool, authMethodName, serviceAccountName string, client *api.Client) error {
-	// Create policy with the given rules.
-	policyName := fmt.Sprintf("%s-policy", componentName)
-	if c.flagFederation && !primary {
-		// If performing ACL replication, we must ensure policy names are
-		// globally unique so we append the datacenter name but only in secondary datacenters..
-		policyName += fmt.Sprintf("-%s", dc)
-	}
+// Service identities are attached to the role in addition to the policy, whose
+// creation is skipped if the identities already grant everything the component
+// needs and rules is empty.
+func (c *Command) createACLPolicyRoleAndBindingRule(componentName, rules, dc, primaryDC string, global, primary bool, authMethodName, serviceAccountName string, client *api.Client, serviceIdentities ...*api.ACLServiceIdentity) error {
 	var datacenters []string
 	if !global && dc != "" {
 		datacenters = append(datacenters, dc)
 	}
-	policyTmpl := api.ACLPolicy{
-		Name:        policyName,
-		Description: fmt.Sprintf("%s Token Policy", policyName),
-		Rules:       rules,
-		Datacenters: datacenters,
-	}
-	err := c.untilSucceeds(fmt.Sprintf("creating %s policy", policyTmpl.Name),
-		func() error {
-			return c.createOrUpdateACLPolicy(policyTmpl, client)
+
+	// Create an ACLRolePolicyLink list to attach to the ACLRole.
+	apl := []*api.ACLRolePolicyLink{}
+	if rules != "" {
+		// Create policy with the given rules.
+		policyName := fmt.Sprintf("%s-policy", componentName)
+		if c.flagFederation && !primary {
+			// If performing ACL replication, we must ensure policy names are
+			// globally unique so we append the datacenter name but only in secondary datacenters..
+			policyName += fmt.Sprintf("-%s", dc)
+		}
+		policyTmpl := api.ACLPolicy{
+			Name:        policyName,
+			Description: fmt.Sprintf("%s Token Policy", policyName),
+			Rules:       rules,
+			Datacenters: datacenters,
+		}
+		err := c.untilSucceeds(fmt.Sprintf("creating %s policy", policyTmpl.Name),
+			func() error {
+				return c.createOrUpdateACLPolicy(policyTmpl, client)
+			})
+		if err != nil {
+			return err
+		}
+		apl = append(apl, &api.ACLRolePolicyLink{
+			Name: policyName,
 		})
-	if err != nil {
-		return err
 	}
 
-	// Create an ACLRolePolicyLink list to attach to the ACLRole.
-	ap := &api.ACLRolePolicyLink{
-		Name: policyName,
+	for _, identity := range serviceIdentities {
+		identity.Datacenters = datacenters
 	}
-	apl := []*api.ACLRolePolicyLink{}
-	apl = append(apl, ap)
 
 	// Add the ACLRole and ACLBindingRule.
-	return c.addRoleAndBindingRule(client, serviceAccountName, authMethodName, apl, global, primary, primaryDC, dc)
+	return c.addRoleAndBindingRule(client, serviceAccountName, authMethodName, apl, serviceIdentities, global, primary, primaryDC, dc)
 }
 
 // addRoleAndBindingRule adds an ACLRole and ACLBindingRule which reference the authMethod.
-func (c *Command) addRoleAndBindingRule(client *api.Client, serviceAccountName string, authMethodName string, policies []*api.ACLRolePolicyLink, global, primary bool, primaryDC, dc string) error {
+func (c *Command) addRoleAndBindingRule(client *api.Client, serviceAccountName string, authMethodName string, policies []*api.ACLRolePolicyLink, serviceIdentities []*api.ACLServiceIdentity, global, primary bool, primaryDC, dc string) error {
 	// This is the ACLRole which will allow the component which uses the serviceaccount
 	// to be able to do a consul login.
 	aclRoleName := fmt.Sprintf("%s-acl-role", serviceAccountName)
@@ -61,9 +69,10 @@ func (c *Command) addRoleAndBindingRule(client *api.Client, serviceAccountName s
 		aclRoleName += fmt.Sprintf("-%s", dc)
 	}
 	role := &api.ACLRole{
-		Name:        aclRoleName,
-		Description: fmt.Sprintf("ACL Role for %s", serviceAccountName),
-		Policies:    policies,
+		Name:              aclRoleName,
+		Description:       fmt.Sprintf("ACL Role for %s", serviceAccountName),
+		Policies:          policies,
+		ServiceIdentities: serviceIdentities,
 	}
 	err := c.updateOrCreateACLRole(client, role)
 	if err != nil {
@@ -83,7 +92,24 @@ func (c *Command) addRoleAndBindingRule(client *api.Client, serviceAccountName s
 	if global && dc != primaryDC {
 		writeOptions.Datacenter = primaryDC
 	}
-	return c.createOrUpdateBindingRule(client, authMethodName, abr, &api.QueryOptions{}, writeOptions)
+	if err := c.createOrUpdateBindingRule(client, authMethodName, abr, &api.QueryOptions{}, writeOptions); err != nil {
+		return err
+	}
+
+	// Bind the IAM role named after the serviceaccount to the same ACLRole
+	// if components can also log in with AWS IAM.
+	awsIAMAuthMethodName, ok := c.awsIAMComponentAuthMethods[authMethodName]
+	if !ok {
+		return nil
+	}
+	awsIAMBindingRule := &api.ACLBindingRule{
+		Description: fmt.Sprintf("Binding Rule for %s", serviceAccountName),
+		AuthMethod:  awsIAMAuthMethodName,
+		Selector:    fmt.Sprintf("entity_name==%q", serviceAccountName),
+		BindType:    api.BindingRuleBindTypeRole,
+		BindName:    aclRoleName,
+	}
+	return c.createOrUpdateBindingRule(client, awsIAMAuthMethodName, awsIAMBindingRule, &api.QueryOptions{}, writeOptions)
 }
 
 // updateOrCreateACLRole will query to see if existing role is in place and update them
@@ -98,7 +124,10 @@ func (c *Command) updateOrCreateACLRole(client *api.Client, role *api.ACLRole) e
 				return err
 			}
 			if aclRole != nil {
-				_, _, err := client.ACL().RoleUpdate(aclRole, &api.WriteOptions{})
+				// Update the role so that changes to its policies and
+				// identities are applied to existing roles.
+				role.ID = aclRole.ID
+				_, _, err := client.ACL().RoleUpdate(role, &api.WriteOptions{})
 				if err != nil {
 					c.log.Error("unable to update role", err)
 					return err
@@ -305,49 +334,42 @@ func (c *Command) createOrUpdateACLPolicy(policy api.ACLPolicy, consulClient *ap
 	// Attempt to create the ACL policy.
 	_, _, err := consulClient.ACL().PolicyCreate(&policy, &api.WriteOptions{})
 
-	// With the introduction of Consul namespaces, if someone upgrades into a
-	// Consul version with namespace support or changes any of their namespace
-	// settings, the policies associated with their ACL tokens will need to be
-	// updated to be namespace aware.
-	// Allowing the Consul node name to be configurable also requires any sync
-	// policy to be updated in case the node name has changed.
+	// Existing policies are updated so that changes to the rules, e.g. when
+	// someone upgrades into a Consul version with namespace support, changes
+	// any of their namespace settings or the Consul node name of catalog sync,
+	// or when the rules of a component are narrowed, are applied to them.
 	if isPolicyExistsErr(err, policy.Name) {
-		if c.flagEnableNamespaces || c.flagSyncCatalog {
-			c.log.Info(fmt.Sprintf("Policy %q already exists, updating", policy.Name))
-
-			// The policy ID is required in any PolicyUpdate call, so first we need to
-			// get the existing policy to extract its ID.
-			existingPolicies, _, err := consulClient.ACL().PolicyList(&api.QueryOptions{})
-			if err != nil {
-				return err
-			}
+		c.log.Info(fmt.Sprintf("Policy %q already exists, updating", policy.Name))
 
-			// Find the policy that matches our name and description
-			// and that's the ID we need
-			for _, existingPolicy := range existingPolicies {
-				if existingPolicy.Name == policy.Name && existingPolicy.Description == policy.Description {
-					policy.ID = existingPolicy.ID
-				}
-			}
+		// The policy ID is required in any PolicyUpdate call, so first we need to
+		// get the existing policy to extract its ID.
+		existingPolicies, _, err := consulClient.ACL().PolicyList(&api.QueryOptions{})
+		if err != nil {
+			return err
+		}
 
-			// This shouldn't happen, because we're looking for a policy
-			// only after we've hit a `Policy already exists` error.
-			// The only time it might happen is if a user has manually created a policy
-			// with this name but used a different description. In this case,
-			// we don't want to overwrite the policy so we just error.
-			if policy.ID == "" {
-				return fmt.Errorf("policy found with name %q but not with expected description %q; "+
-					"if this policy was created manually it must be renamed to something else because this name is reserved by consul-k8s",
-					policy.Name, policy.Description)
+		// Find the policy that matches our name and description
+		// and that's the ID we need
+		for _, existingPolicy := range existingPolicies {
+			if existingPolicy.Name == policy.Name && existingPolicy.Description == policy.Description {
+				policy.ID = existingPolicy.ID
 			}
+		}
 
-			// Update the policy now that we've found its ID
-			_, _, err = consulClient.ACL().PolicyUpdate(&policy, &api.WriteOptions{})
-			return err
-		} else {
-			c.log.Info(fmt.Sprintf("Policy %q already exists, skipping update", policy.Name))
-			return nil
+		// This shouldn't happen, because we're looking for a policy
+		// only after we've hit a `Policy already exists` error.
+		// The only time it might happen is if a user has manually created a policy
+		// with this name but used a different description. In this case,
+		// we don't want to overwrite the policy so we just error.
+		if policy.ID == "" {
+			return fmt.Errorf("policy found with name %q but not with expected description %q; "+
+				"if this policy was created manually it must be renamed to something else because this name is reserved by consul-k8s",
+				policy.Name, policy.Description)
 		}
+
+		// Update the policy now that we've found its ID
+		_, _, err = consulClient.ACL().PolicyUpdate(&policy, &api.WriteOptions{})
+		return err
 	}
 	return err
 }
@@ -17,6 +17,11 @@ type rulesData struct {
 	InjectEnableNSMirroring bool
 	InjectNSMirroringPrefix string
 	SyncConsulNodeName      string
+	EnablePeering           bool
+
+	AnonymousTokenServicePrefixes []string
+	AnonymousTokenNamespaces      []string
+	AnonymousTokenPartitions      []string
 }
 
 type gatewayRulesData struct {
@@ -25,16 +30,7 @@ type gatewayRulesData struct {
 	GatewayNamespace string
 }
 
-const snapshotAgentRules = `acl = "write"
-key "consul-snapshot/lock" {
-   policy = "write"
-}
-session_prefix "" {
-   policy = "write"
-}
-service "consul-snapshot" {
-   policy = "write"
-}`
+const gossipKeyRotationRules = `keyring = "write"`
 
 // The enterprise license rules are acl="write" inside partitions as operator="write"
 // is unsupported in partitions.
@@ -55,6 +51,51 @@ partition_prefix "" {
   }
 }`
 
+func (c *Command) snapshotAgentRules() (string, error) {
+	snapshotAgentRulesTpl := `
+{{- if .EnablePartitions }}
+partition "{{ .PartitionName }}" {
+{{- end }}
+  acl = "write"
+  key "consul-snapshot/lock" {
+     policy = "write"
+  }
+  session_prefix "" {
+     policy = "write"
+  }
+  service "consul-snapshot" {
+     policy = "write"
+  }
+{{- if .EnablePartitions }}
+}
+{{- end }}
+`
+
+	return c.renderRules(snapshotAgentRulesTpl)
+}
+
+// The ACL login audit reads the roles, binding rules and tokens of the auth
+// methods, which may be in any namespace when namespace mirroring is enabled.
+func (c *Command) aclLoginAuditRules() (string, error) {
+	aclLoginAuditRulesTpl := `
+{{- if .EnablePartitions }}
+partition "{{ .PartitionName }}" {
+{{- end }}
+{{- if .EnableNamespaces }}
+  namespace_prefix "" {
+{{- end }}
+    acl = "read"
+{{- if .EnableNamespaces }}
+  }
+{{- end }}
+{{- if .EnablePartitions }}
+}
+{{- end }}
+`
+
+	return c.renderRules(aclLoginAuditRulesTpl)
+}
+
 func (c *Command) crossNamespaceRules() (string, error) {
 	crossNamespaceRulesTpl := `{{- if .EnablePartitions }}
 partition "{{ .PartitionName }}" {
@@ -114,24 +155,58 @@ func (c *Command) anonymousTokenRules() (string, error) {
 	// local ACL token is stripped and the request continues without
 	// ACL token. Thus the anonymous policy must
 	// allow reading all services.
+	// The services can be restricted to names with the prefixes in
+	// -anonymous-token-service-prefix and to the namespaces and partitions in
+	// -anonymous-token-namespace and -anonymous-token-partition, e.g. to only
+	// allow DNS lookups of some services, or the rules can be replaced by
+	// -anonymous-token-policy-file entirely. Cross-dc Consul Connect then only
+	// works for the services the anonymous token can read.
+	if c.anonymousTokenPolicyRules != "" {
+		return c.anonymousTokenPolicyRules, nil
+	}
+
 	anonTokenRulesTpl := `
-{{- if .EnablePartitions }}
-partition_prefix "" {
-{{- end }}
-{{- if .EnableNamespaces }}
-  namespace_prefix "" {
-{{- end }}
+{{- define "services" }}
     node_prefix "" {
        policy = "read"
     }
+{{- range .AnonymousTokenServicePrefixes }}
+    service_prefix "{{ . }}" {
+       policy = "read"
+    }
+{{- else }}
     service_prefix "" {
        policy = "read"
     }
+{{- end }}
+{{- end }}
+{{- define "namespaces" }}
 {{- if .EnableNamespaces }}
+{{- range .AnonymousTokenNamespaces }}
+  namespace "{{ . }}" {
+{{- template "services" $ }}
+  }
+{{- else }}
+  namespace_prefix "" {
+{{- template "services" . }}
   }
 {{- end }}
+{{- else }}
+{{- template "services" . }}
+{{- end }}
+{{- end }}
 {{- if .EnablePartitions }}
+{{- range .AnonymousTokenPartitions }}
+partition "{{ . }}" {
+{{- template "namespaces" $ }}
 }
+{{- else }}
+partition_prefix "" {
+{{- template "namespaces" . }}
+}
+{{- end }}
+{{- else }}
+{{- template "namespaces" . }}
 {{- end }}
 `
 
@@ -143,9 +218,12 @@ func (c *Command) apiGatewayControllerRules() (string, error) {
 partition "{{ .PartitionName }}" {
   mesh = "write"
   acl = "write"
-{{- else }}
+{{- else if .EnableNamespaces }}
 operator = "write"
 acl = "write"
+{{- else }}
+mesh = "write"
+acl = "write"
 {{- end }}
 {{- if .EnableNamespaces }}
 namespace_prefix "" {
@@ -174,27 +252,43 @@ func (c *Command) meshGatewayRules() (string, error) {
 	// Mesh gateways can only act as a proxy for services
 	// that its ACL token has access to. So, in the case of
 	// Consul namespaces, it needs access to all namespaces.
+	// The mesh-gateway service itself and reads in the default
+	// namespace are granted by the service identity of its role.
+	// With admin partitions, they also need to discover the
+	// mesh gateways of other partitions to route traffic to them,
+	// and with peering they need to read peerings to route
+	// traffic to and from peered clusters.
 	meshGatewayRulesTpl := `
+{{- if .EnablePartitions }}
+partition "{{ .PartitionName }}" {
+{{- end }}
+{{- if .EnablePeering }}
+  peering = "read"
+{{- end }}
   agent_prefix "" {
   	policy = "read"
   }
 {{- if .EnableNamespaces }}
-namespace "default" {
-{{- end }}
-  service "mesh-gateway" {
-     policy = "write"
-  }
-{{- if .EnableNamespaces }}
-}
 namespace_prefix "" {
-{{- end }}
   node_prefix "" {
   	policy = "read"
   }
   service_prefix "" {
      policy = "read"
   }
-{{- if .EnableNamespaces }}
+}
+{{- end }}
+{{- if .EnablePartitions }}
+}
+partition_prefix "" {
+  namespace "default" {
+    service "mesh-gateway" {
+      policy = "read"
+    }
+  }
+  node_prefix "" {
+    policy = "read"
+  }
 }
 {{- end }}
 `
@@ -379,16 +473,37 @@ partition "default" {
 // acl = "write" is required when creating namespace with a default policy.
 // Attaching a default ACL policy to a namespace requires acl = "write" in the
 // namespace that the policy is defined in, which in our case is "default".
+// Without namespaces, mesh = "write" is enough to write config entries.
+// node_prefix "" write is required to register the external services of
+// terminating gateways on their own nodes.
+// With peering, peering = "write" is required for the PeeringConnection
+// controller to generate peering tokens and establish peerings. Without
+// partitions, operator = "write" already grants it.
 func (c *Command) controllerRules() (string, error) {
 	controllerRules := `
 {{- if .EnablePartitions }}
 partition "{{ .PartitionName }}" {
   mesh = "write"
+{{- if .EnableNamespaces }}
   acl = "write"
+{{- end }}
+{{- if .EnablePeering }}
+  peering = "write"
 {{- else }}
+  peering = "read"
+{{- end }}
+{{- else if .EnableNamespaces }}
   operator = "write"
   acl = "write"
+{{- else }}
+  mesh = "write"
+{{- end }}
+{{- if and .EnablePeering (not .EnablePartitions) (not .EnableNamespaces) }}
+  peering = "write"
 {{- end }}
+  node_prefix "" {
+    policy = "write"
+  }
 {{- if .EnableNamespaces }}
 {{- if .InjectEnableNSMirroring }}
   namespace_prefix "{{ .InjectNSMirroringPrefix }}" {
@@ -405,6 +520,13 @@ partition "{{ .PartitionName }}" {
     }
 {{- if .EnableNamespaces }}
   }
+{{- if not (and .InjectEnableNSMirroring (eq .InjectNSMirroringPrefix "")) }}
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
+  }
+{{- end }}
 {{- end }}
 {{- if .EnablePartitions }}
 }
@@ -425,6 +547,11 @@ func (c *Command) rulesData() rulesData {
 		InjectEnableNSMirroring: c.flagEnableInjectK8SNSMirroring,
 		InjectNSMirroringPrefix: c.flagInjectK8SNSMirroringPrefix,
 		SyncConsulNodeName:      c.flagSyncConsulNodeName,
+		EnablePeering:           c.flagEnablePeering,
+
+		AnonymousTokenServicePrefixes: c.flagAnonymousTokenServicePrefixes,
+		AnonymousTokenNamespaces:      c.flagAnonymousTokenNamespaces,
+		AnonymousTokenPartitions:      c.flagAnonymousTokenPartitions,
 	}
 }
 
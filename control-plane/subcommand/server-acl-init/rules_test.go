@@ -80,6 +80,10 @@ func TestAnonymousTokenRules(t *testing.T) {
 		EnablePartitions bool
 		PartitionName    string
 		EnableNamespaces bool
+		ServicePrefixes  []string
+		Namespaces       []string
+		Partitions       []string
+		PolicyRules      string
 		Expected         string
 	}{
 		{
@@ -122,6 +126,53 @@ partition_prefix "" {
   }
 }`,
 		},
+		{
+			Name:            "Service prefixes are set",
+			ServicePrefixes: []string{"web", "api-"},
+			Expected: `
+    node_prefix "" {
+       policy = "read"
+    }
+    service_prefix "web" {
+       policy = "read"
+    }
+    service_prefix "api-" {
+       policy = "read"
+    }`,
+		},
+		{
+			Name:             "Namespaces and partitions are set",
+			EnablePartitions: true,
+			PartitionName:    "part-2",
+			EnableNamespaces: true,
+			ServicePrefixes:  []string{"web"},
+			Namespaces:       []string{"ns-1", "ns-2"},
+			Partitions:       []string{"default"},
+			Expected: `
+partition "default" {
+  namespace "ns-1" {
+    node_prefix "" {
+       policy = "read"
+    }
+    service_prefix "web" {
+       policy = "read"
+    }
+  }
+  namespace "ns-2" {
+    node_prefix "" {
+       policy = "read"
+    }
+    service_prefix "web" {
+       policy = "read"
+    }
+  }
+}`,
+		},
+		{
+			Name:        "Policy rules are set",
+			PolicyRules: `service "dns" { policy = "read" }`,
+			Expected:    `service "dns" { policy = "read" }`,
+		},
 	}
 
 	for _, tt := range cases {
@@ -130,6 +181,11 @@ partition_prefix "" {
 				flagEnablePartitions: tt.EnablePartitions,
 				flagPartitionName:    tt.PartitionName,
 				flagEnableNamespaces: tt.EnableNamespaces,
+
+				flagAnonymousTokenServicePrefixes: tt.ServicePrefixes,
+				flagAnonymousTokenNamespaces:      tt.Namespaces,
+				flagAnonymousTokenPartitions:      tt.Partitions,
+				anonymousTokenPolicyRules:         tt.PolicyRules,
 			}
 
 			rules, err := cmd.anonymousTokenRules()
@@ -149,7 +205,7 @@ func TestAPIGatewayControllerRules(t *testing.T) {
 		{
 			Name: "Namespaces are disabled",
 			Expected: `
-operator = "write"
+mesh = "write"
 acl = "write"
   service_prefix "" {
     policy = "write"
@@ -195,34 +251,55 @@ func TestMeshGatewayRules(t *testing.T) {
 	cases := []struct {
 		Name             string
 		EnableNamespaces bool
+		EnablePartitions bool
+		PartitionName    string
+		EnablePeering    bool
 		Expected         string
 	}{
 		{
 			Name: "Namespaces are disabled",
-			Expected: `agent_prefix "" {
+			Expected: `
+  agent_prefix "" {
+  	policy = "read"
+  }`,
+		},
+		{
+			Name:             "Namespaces are enabled",
+			EnableNamespaces: true,
+			Expected: `
+  agent_prefix "" {
   	policy = "read"
   }
-  service "mesh-gateway" {
-     policy = "write"
-  }
+namespace_prefix "" {
   node_prefix "" {
   	policy = "read"
   }
   service_prefix "" {
      policy = "read"
+  }
+}`,
+		},
+		{
+			Name:          "Peering is enabled",
+			EnablePeering: true,
+			Expected: `
+  peering = "read"
+  agent_prefix "" {
+  	policy = "read"
   }`,
 		},
 		{
-			Name:             "Namespaces are enabled",
+			Name:             "Partitions and peering are enabled",
 			EnableNamespaces: true,
-			Expected: `agent_prefix "" {
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			EnablePeering:    true,
+			Expected: `
+partition "part-1" {
+  peering = "read"
+  agent_prefix "" {
   	policy = "read"
   }
-namespace "default" {
-  service "mesh-gateway" {
-     policy = "write"
-  }
-}
 namespace_prefix "" {
   node_prefix "" {
   	policy = "read"
@@ -230,6 +307,17 @@ namespace_prefix "" {
   service_prefix "" {
      policy = "read"
   }
+}
+}
+partition_prefix "" {
+  namespace "default" {
+    service "mesh-gateway" {
+      policy = "read"
+    }
+  }
+  node_prefix "" {
+    policy = "read"
+  }
 }`,
 		},
 	}
@@ -238,6 +326,9 @@ namespace_prefix "" {
 		t.Run(tt.Name, func(t *testing.T) {
 			cmd := Command{
 				flagEnableNamespaces: tt.EnableNamespaces,
+				flagEnablePartitions: tt.EnablePartitions,
+				flagPartitionName:    tt.PartitionName,
+				flagEnablePeering:    tt.EnablePeering,
 			}
 
 			meshGatewayRules, err := cmd.meshGatewayRules()
@@ -1007,13 +1098,16 @@ func TestControllerRules(t *testing.T) {
 		DestConsulNS     string
 		Mirroring        bool
 		MirroringPrefix  string
+		EnablePeering    bool
 		Expected         string
 	}{
 		{
 			Name: "namespaces=disabled, partitions=disabled",
 			Expected: `
-  operator = "write"
-  acl = "write"
+  mesh = "write"
+  node_prefix "" {
+    policy = "write"
+  }
     service_prefix "" {
       policy = "write"
       intentions = "write"
@@ -1026,11 +1120,19 @@ func TestControllerRules(t *testing.T) {
 			Expected: `
   operator = "write"
   acl = "write"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace "consul" {
     service_prefix "" {
       policy = "write"
       intentions = "write"
     }
+  }
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
   }`,
 		},
 		{
@@ -1040,6 +1142,9 @@ func TestControllerRules(t *testing.T) {
 			Expected: `
   operator = "write"
   acl = "write"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace_prefix "" {
     service_prefix "" {
       policy = "write"
@@ -1055,11 +1160,19 @@ func TestControllerRules(t *testing.T) {
 			Expected: `
   operator = "write"
   acl = "write"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace_prefix "prefix-" {
     service_prefix "" {
       policy = "write"
       intentions = "write"
     }
+  }
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
   }`,
 		},
 		{
@@ -1072,6 +1185,10 @@ func TestControllerRules(t *testing.T) {
 partition "part-1" {
   mesh = "write"
   acl = "write"
+  peering = "read"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace "consul" {
     policy = "write"
     service_prefix "" {
@@ -1079,6 +1196,11 @@ partition "part-1" {
       intentions = "write"
     }
   }
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
+  }
 }`,
 		},
 		{
@@ -1091,6 +1213,10 @@ partition "part-1" {
 partition "part-1" {
   mesh = "write"
   acl = "write"
+  peering = "read"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace_prefix "" {
     policy = "write"
     service_prefix "" {
@@ -1111,6 +1237,10 @@ partition "part-1" {
 partition "part-1" {
   mesh = "write"
   acl = "write"
+  peering = "read"
+  node_prefix "" {
+    policy = "write"
+  }
   namespace_prefix "prefix-" {
     policy = "write"
     service_prefix "" {
@@ -1118,6 +1248,44 @@ partition "part-1" {
       intentions = "write"
     }
   }
+  namespace_prefix "" {
+    service_prefix "" {
+      policy = "read"
+    }
+  }
+}`,
+		},
+		{
+			Name:          "namespaces=disabled, partitions=disabled, peering=enabled",
+			EnablePeering: true,
+			Expected: `
+  mesh = "write"
+  peering = "write"
+  node_prefix "" {
+    policy = "write"
+  }
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }`,
+		},
+		{
+			Name:             "namespaces=disabled, partitions=enabled, peering=enabled",
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			EnablePeering:    true,
+			Expected: `
+partition "part-1" {
+  mesh = "write"
+  peering = "write"
+  node_prefix "" {
+    policy = "write"
+  }
+    policy = "write"
+    service_prefix "" {
+      policy = "write"
+      intentions = "write"
+    }
 }`,
 		},
 	}
@@ -1125,6 +1293,7 @@ partition "part-1" {
 	for _, tt := range cases {
 		t.Run(tt.Name, func(t *testing.T) {
 			cmd := Command{
+				flagEnablePeering:                    tt.EnablePeering,
 				flagEnableNamespaces:                 tt.EnableNamespaces,
 				flagConsulInjectDestinationNamespace: tt.DestConsulNS,
 				flagEnableInjectK8SNSMirroring:       tt.Mirroring,
@@ -1140,3 +1309,110 @@ partition "part-1" {
 		})
 	}
 }
+
+func TestSnapshotAgentRules(t *testing.T) {
+	cases := []struct {
+		Name             string
+		EnablePartitions bool
+		PartitionName    string
+		Expected         string
+	}{
+		{
+			Name: "Partitions are disabled",
+			Expected: `
+  acl = "write"
+  key "consul-snapshot/lock" {
+     policy = "write"
+  }
+  session_prefix "" {
+     policy = "write"
+  }
+  service "consul-snapshot" {
+     policy = "write"
+  }`,
+		},
+		{
+			Name:             "Partitions are enabled",
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			Expected: `
+partition "part-1" {
+  acl = "write"
+  key "consul-snapshot/lock" {
+     policy = "write"
+  }
+  session_prefix "" {
+     policy = "write"
+  }
+  service "consul-snapshot" {
+     policy = "write"
+  }
+}`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cmd := Command{
+				flagEnablePartitions: tt.EnablePartitions,
+				flagPartitionName:    tt.PartitionName,
+			}
+
+			snapshotAgentRules, err := cmd.snapshotAgentRules()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.Expected, snapshotAgentRules)
+		})
+	}
+}
+
+func TestACLLoginAuditRules(t *testing.T) {
+	cases := []struct {
+		Name             string
+		EnablePartitions bool
+		PartitionName    string
+		EnableNamespaces bool
+		Expected         string
+	}{
+		{
+			Name: "Namespaces and partitions are disabled",
+			Expected: `
+    acl = "read"`,
+		},
+		{
+			Name:             "Namespaces are enabled",
+			EnableNamespaces: true,
+			Expected: `
+  namespace_prefix "" {
+    acl = "read"
+  }`,
+		},
+		{
+			Name:             "Partitions are enabled",
+			EnablePartitions: true,
+			PartitionName:    "part-1",
+			EnableNamespaces: true,
+			Expected: `
+partition "part-1" {
+  namespace_prefix "" {
+    acl = "read"
+  }
+}`,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cmd := Command{
+				flagEnablePartitions: tt.EnablePartitions,
+				flagPartitionName:    tt.PartitionName,
+				flagEnableNamespaces: tt.EnableNamespaces,
+			}
+
+			aclLoginAuditRules, err := cmd.aclLoginAuditRules()
+
+			require.NoError(t, err)
+			require.Equal(t, tt.Expected, aclLoginAuditRules)
+		})
+	}
+}
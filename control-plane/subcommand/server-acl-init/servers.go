@@ -19,13 +19,14 @@ import (
 // If bootstrapToken is not empty then ACLs are already bootstrapped.
 func (c *Command) bootstrapServers(serverAddresses []string, bootstrapToken, bootTokenSecretName, scheme string) (string, error) {
 	// Pick the first server address to connect to for bootstrapping and set up connection.
-	firstServerAddr := fmt.Sprintf("%s:%d", serverAddresses[0], c.flagServerPort)
+	firstServerAddr := c.serverAddress(serverAddresses[0])
+	firstServerTLSConfig := c.serverTLSConfig(serverAddresses[0])
 
 	if bootstrapToken == "" {
 		c.log.Info("No bootstrap token from previous installation found, continuing on to bootstrapping")
 
 		var err error
-		bootstrapToken, err = c.bootstrapACLs(firstServerAddr, scheme, bootTokenSecretName)
+		bootstrapToken, err = c.bootstrapACLs(firstServerAddr, firstServerTLSConfig, scheme, bootTokenSecretName)
 		if err != nil {
 			return "", err
 		}
@@ -43,10 +44,7 @@ func (c *Command) bootstrapServers(serverAddresses []string, bootstrapToken, boo
 		clientConfig.Address = firstServerAddr
 		clientConfig.Scheme = scheme
 		clientConfig.Token = bootstrapToken
-		clientConfig.TLSConfig = api.TLSConfig{
-			Address: c.flagConsulTLSServerName,
-			CAFile:  c.flagConsulCACert,
-		}
+		clientConfig.TLSConfig = firstServerTLSConfig
 
 		consulClient, err := consul.NewClient(clientConfig,
 			c.flagConsulAPITimeout)
@@ -63,15 +61,12 @@ func (c *Command) bootstrapServers(serverAddresses []string, bootstrapToken, boo
 }
 
 // bootstrapACLs makes the ACL bootstrap API call and writes the bootstrap token
-// to a kube secret.
-func (c *Command) bootstrapACLs(firstServerAddr string, scheme string, bootTokenSecretName string) (string, error) {
+// to a kube secret, or to Vault if -bootstrap-token-vault-read-path is set.
+func (c *Command) bootstrapACLs(firstServerAddr string, tlsConfig api.TLSConfig, scheme string, bootTokenSecretName string) (string, error) {
 	clientConfig := api.DefaultConfig()
 	clientConfig.Address = firstServerAddr
 	clientConfig.Scheme = scheme
-	clientConfig.TLSConfig = api.TLSConfig{
-		Address: c.flagConsulTLSServerName,
-		CAFile:  c.flagConsulCACert,
-	}
+	clientConfig.TLSConfig = tlsConfig
 	// Exempting this particular use of the http client from using global.consulAPITimeout
 	// which defaults to 5 seconds.  In acceptance tests, we saw that the call
 	// to /v1/acl/bootstrap taking 5-7 seconds and when it does, the request times
@@ -103,6 +98,22 @@ func (c *Command) bootstrapACLs(firstServerAddr string, scheme string, bootToken
 
 			// Check if already bootstrapped.
 			if strings.Contains(err.Error(), "Unexpected response code: 403") {
+				if c.vault != nil {
+					// A previous run may have bootstrapped ACLs and stored the
+					// token in Vault since we last read it.
+					bootstrapToken, err = c.getVaultBootstrapToken()
+					if err != nil {
+						return err
+					}
+					if bootstrapToken != "" {
+						c.log.Info("ACLs already bootstrapped, using the bootstrap token from Vault")
+						return nil
+					}
+					unrecoverableErr = fmt.Errorf("ACLs already bootstrapped but the ACL token was not written to Vault secret %q."+
+						" We can't proceed because the bootstrap token is lost."+
+						" You must reset ACLs.", c.flagBootstrapTokenVaultReadPath)
+					return nil
+				}
 				unrecoverableErr = errors.New("ACLs already bootstrapped but the ACL token was not written to a Kubernetes secret." +
 					" We can't proceed because the bootstrap token is lost." +
 					" You must reset ACLs.")
@@ -123,6 +134,14 @@ func (c *Command) bootstrapACLs(firstServerAddr string, scheme string, bootToken
 		return "", err
 	}
 
+	if c.vault != nil {
+		err = c.untilSucceeds(fmt.Sprintf("writing bootstrap token to Vault secret %q", c.flagBootstrapTokenVaultWritePath),
+			func() error {
+				return c.vault.write(c.flagBootstrapTokenVaultWritePath, c.flagBootstrapTokenVaultKey, bootstrapToken)
+			})
+		return bootstrapToken, err
+	}
+
 	// Write bootstrap token to a Kubernetes secret.
 	err = c.untilSucceeds(fmt.Sprintf("writing bootstrap Secret %q", bootTokenSecretName),
 		func() error {
@@ -161,13 +180,10 @@ func (c *Command) setServerTokens(consulClient *api.Client, serverAddresses []st
 		// We create a new client for each server because we need to call each
 		// server specifically.
 		clientConfig := api.DefaultConfig()
-		clientConfig.Address = fmt.Sprintf("%s:%d", host, c.flagServerPort)
+		clientConfig.Address = c.serverAddress(host)
 		clientConfig.Scheme = scheme
 		clientConfig.Token = bootstrapToken
-		clientConfig.TLSConfig = api.TLSConfig{
-			Address: c.flagConsulTLSServerName,
-			CAFile:  c.flagConsulCACert,
-		}
+		clientConfig.TLSConfig = c.serverTLSConfig(host)
 
 		serverClient, err := consul.NewClient(clientConfig,
 			c.flagConsulAPITimeout)
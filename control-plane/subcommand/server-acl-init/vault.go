@@ -0,0 +1,112 @@
+package serveraclinit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// vaultKV reads and writes secrets of a Vault KV version 2 secrets engine
+// through the Vault HTTP API. Paths include the data/ segment of the engine,
+// e.g. consul/data/bootstrap-token, as they do in Vault agent templates.
+type vaultKV struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// newVaultKV returns a vaultKV for the Vault server at address that
+// authenticates with the token in tokenFile and verifies the server with the
+// PEM-encoded CA certificate in caFile, if it's set.
+func newVaultKV(address, tokenFile, caFile string) (*vaultKV, error) {
+	token, err := loadTokenFromFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading Vault CA certificate %q: %s", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in Vault CA certificate %q", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &vaultKV{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		client:  &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// read returns the value of key in the secret at path. It returns an empty
+// string if the secret or key doesn't exist.
+func (v *vaultKV) read(path, key string) (string, error) {
+	resp, err := v.do(http.MethodGet, path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", vaultError(resp, "reading", path)
+	}
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("decoding Vault secret %q: %s", path, err)
+	}
+	value, _ := secret.Data.Data[key].(string)
+	return value, nil
+}
+
+// write writes value to key of the secret at path, as a new version of the
+// secret.
+func (v *vaultKV) write(path, key, value string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]string{key: value},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := v.do(http.MethodPut, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return vaultError(resp, "writing", path)
+	}
+	return nil
+}
+
+func (v *vaultKV) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", v.address, strings.TrimPrefix(path, "/")), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return v.client.Do(req)
+}
+
+func vaultError(resp *http.Response, op, path string) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%s Vault secret %q: unexpected response code %d: %s", op, path, resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,209 @@
+package serveraclinit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	vaultToken     = "vault-token"
+	vaultTokenPath = "consul/data/bootstrap-token"
+)
+
+func TestVaultKV(t *testing.T) {
+	t.Parallel()
+	vault := newFakeVault()
+	server := httptest.NewServer(vault)
+	defer server.Close()
+	kv := testVaultKV(t, server.URL, vaultToken)
+
+	token, err := kv.read(vaultTokenPath, "token")
+	require.NoError(t, err)
+	require.Equal(t, "", token)
+
+	require.NoError(t, kv.write(vaultTokenPath, "token", "bootstrap-token"))
+	token, err = kv.read(vaultTokenPath, "token")
+	require.NoError(t, err)
+	require.Equal(t, "bootstrap-token", token)
+
+	token, err = kv.read(vaultTokenPath, "other-key")
+	require.NoError(t, err)
+	require.Equal(t, "", token)
+
+	kv = testVaultKV(t, server.URL, "wrong-token")
+	_, err = kv.read(vaultTokenPath, "token")
+	require.EqualError(t, err, `reading Vault secret "consul/data/bootstrap-token": unexpected response code 403: {"errors":["permission denied"]}`)
+}
+
+// Test that the bootstrap token is read from and written to Vault instead of
+// a Kubernetes secret with -bootstrap-token-vault-read-path.
+func TestRun_BootstrapTokenInVault(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		vaultToken       string
+		bootstrapAllowed bool
+		expBootstrap     bool
+		expToken         string
+		expFail          bool
+	}{
+		"not bootstrapped": {
+			bootstrapAllowed: true,
+			expBootstrap:     true,
+			expToken:         "new-token",
+		},
+		"token in Vault": {
+			vaultToken: "old-token",
+			expToken:   "old-token",
+		},
+		"already bootstrapped without a token in Vault": {
+			expBootstrap: true,
+			expFail:      true,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			k8s := fake.NewSimpleClientset()
+			setUpK8sServiceAccount(t, k8s, ns)
+
+			vault := newFakeVault()
+			if c.vaultToken != "" {
+				vault.secrets[vaultTokenPath] = map[string]interface{}{"token": c.vaultToken}
+			}
+			vaultServer := httptest.NewServer(vault)
+			defer vaultServer.Close()
+
+			var bootstrapped bool
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/acl/bootstrap":
+					bootstrapped = true
+					if !c.bootstrapAllowed {
+						w.WriteHeader(403)
+						fmt.Fprintln(w, "Permission denied: ACL bootstrap no longer allowed (reset index: 1)")
+						return
+					}
+					fmt.Fprintln(w, `{"SecretID": "new-token"}`)
+				case "/v1/agent/self":
+					fmt.Fprintln(w, `{"Config": {"Datacenter": "dc1", "PrimaryDatacenter": "dc1"}}`)
+				case "/v1/acl/tokens", "/v1/acl/binding-rules":
+					fmt.Fprintln(w, `[]`)
+				case "/v1/acl/role/name/release-name-consul-client-acl-role":
+					w.WriteHeader(404)
+				default:
+					fmt.Fprintln(w, `{}`)
+				}
+			}))
+			defer consulServer.Close()
+			serverURL, err := url.Parse(consulServer.URL)
+			require.NoError(t, err)
+
+			tokenFile, err := ioutil.TempFile("", "")
+			require.NoError(t, err)
+			defer os.Remove(tokenFile.Name())
+			_, err = tokenFile.WriteString(vaultToken)
+			require.NoError(t, err)
+
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI:        ui,
+				clientset: k8s,
+			}
+			responseCode := cmd.Run([]string{
+				"-timeout=500ms",
+				"-resource-prefix=" + resourcePrefix,
+				"-k8s-namespace=" + ns,
+				"-server-address=" + serverURL.Hostname(),
+				"-server-port=" + serverURL.Port(),
+				"-consul-api-timeout", "5s",
+				"-vault-address=" + vaultServer.URL,
+				"-vault-token-file=" + tokenFile.Name(),
+				"-bootstrap-token-vault-read-path=" + vaultTokenPath,
+			})
+			require.Equal(t, c.expBootstrap, bootstrapped)
+			if c.expFail {
+				require.Equal(t, 1, responseCode)
+				return
+			}
+			require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+
+			token, err := cmd.vault.read(vaultTokenPath, "token")
+			require.NoError(t, err)
+			require.Equal(t, c.expToken, token)
+
+			// The bootstrap token isn't stored in a Kubernetes secret.
+			_, err = k8s.CoreV1().Secrets(ns).Get(context.Background(), resourcePrefix+"-bootstrap-acl-token", metav1.GetOptions{})
+			require.Error(t, err)
+		})
+	}
+}
+
+func testVaultKV(t *testing.T, address, token string) *vaultKV {
+	t.Helper()
+	tokenFile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(tokenFile.Name()) })
+	_, err = tokenFile.WriteString(token)
+	require.NoError(t, err)
+
+	kv, err := newVaultKV(address, tokenFile.Name(), "")
+	require.NoError(t, err)
+	return kv
+}
+
+// fakeVault serves the secrets of a Vault KV version 2 secrets engine.
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]interface{}
+}
+
+func newFakeVault() *fakeVault {
+	return &fakeVault{secrets: map[string]map[string]interface{}{}}
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Vault-Token") != vaultToken {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors":["permission denied"]}`)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := f.secrets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	case http.MethodPut:
+		var req struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.secrets[path] = req.Data
+		fmt.Fprint(w, `{"data":{"version":1}}`)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
 	"time"
 
@@ -32,11 +35,16 @@ type Command struct {
 	flagServiceName      string
 	flagOutputFile       string
 	flagResolveHostnames bool
+	flagAnnotation       string
+	flagWatch            bool
+	flagWatchPeriod      time.Duration
+	flagServiceConfig    string
 	flagLogLevel         string
 	flagLogJSON          bool
 
 	retryDuration time.Duration
 	k8sClient     kubernetes.Interface
+	logger        hclog.Logger
 	once          sync.Once
 	help          string
 
@@ -53,6 +61,17 @@ func (c *Command) init() {
 		"Path to file to write load balancer address")
 	c.flags.BoolVar(&c.flagResolveHostnames, "resolve-hostnames", false,
 		"If true we will resolve any hostnames and use their first IP address")
+	c.flags.StringVar(&c.flagAnnotation, "annotation", "",
+		"If set, use the value of this annotation of the service as its address instead of "+
+			"the address that depends on the service type.")
+	c.flags.BoolVar(&c.flagWatch, "watch", false,
+		"If true, keep watching the service after writing its address and write it again "+
+			"whenever it changes.")
+	c.flags.DurationVar(&c.flagWatchPeriod, "watch-period", 10*time.Second,
+		"Time between checking if the address of the service changed when -watch is true.")
+	c.flags.StringVar(&c.flagServiceConfig, "service-config", "",
+		"Path to a service registration file whose wan tagged address is set to the address "+
+			"of the service. Supported only when -watch is true.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -88,7 +107,7 @@ func (c *Command) Run(args []string) int {
 	if c.retryDuration == 0 {
 		c.retryDuration = 1 * time.Second
 	}
-	logger, err := common.Logger(c.flagLogLevel, c.flagLogJSON)
+	logger, err := common.Logger("service-address", c.flagLogLevel, c.flagLogJSON)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -97,44 +116,15 @@ func (c *Command) Run(args []string) int {
 	if c.ctx == nil {
 		c.ctx = context.Background()
 	}
+	c.logger = logger
 
 	// Run until we get an address from the service.
 	var address string
 	var unretryableErr error
 	err = backoff.Retry(withErrLogger(logger, func() error {
-		svc, err := c.k8sClient.CoreV1().Services(c.flagNamespace).Get(c.ctx, c.flagServiceName, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("getting service %s: %s", c.flagServiceName, err)
-		}
-		switch svc.Spec.Type {
-		case v1.ServiceTypeClusterIP:
-			address = svc.Spec.ClusterIP
-			return nil
-		case v1.ServiceTypeNodePort:
-			unretryableErr = errors.New("services of type NodePort are not supported")
-			return nil
-		case v1.ServiceTypeExternalName:
-			unretryableErr = errors.New("services of type ExternalName are not supported")
-			return nil
-		case v1.ServiceTypeLoadBalancer:
-			for _, ingr := range svc.Status.LoadBalancer.Ingress {
-				if ingr.IP != "" {
-					address = ingr.IP
-					return nil
-				} else if ingr.Hostname != "" {
-					if c.flagResolveHostnames {
-						address, unretryableErr = resolveHostname(ingr.Hostname)
-					} else {
-						address = ingr.Hostname
-					}
-					return nil
-				}
-			}
-			return fmt.Errorf("service %s has no ingress IP or hostname", c.flagServiceName)
-		default:
-			unretryableErr = fmt.Errorf("unknown service type %q", svc.Spec.Type)
-			return nil
-		}
+		var err error
+		address, unretryableErr, err = c.serviceAddress()
+		return err
 	}), backoff.NewConstantBackOff(c.retryDuration))
 
 	if err != nil || unretryableErr != nil {
@@ -142,15 +132,100 @@ func (c *Command) Run(args []string) int {
 		return 1
 	}
 
-	// Write the address to file.
-	err = ioutil.WriteFile(c.flagOutputFile, []byte(address), 0600)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("Unable to write address to file: %s", err))
+	if err := c.writeAddress(address); err != nil {
+		c.UI.Error(err.Error())
 		return 1
 	}
+	if !c.flagWatch {
+		return 0
+	}
+
+	// Keep the address up to date, e.g. if the load balancer of the service
+	// is provisioned again with a different IP. Errors are only logged since
+	// the last address that was written is still the best one we know of.
+	for {
+		select {
+		case <-time.After(c.flagWatchPeriod):
+		case <-c.ctx.Done():
+			return 0
+		}
+		newAddress, unretryableErr, err := c.serviceAddress()
+		if err == nil {
+			err = unretryableErr
+		}
+		if err != nil {
+			logger.Error("unable to get service address", "err", err)
+			continue
+		}
+		if newAddress == address {
+			continue
+		}
+		logger.Info("service address changed", "old", address, "new", newAddress)
+		if err := c.writeAddress(newAddress); err != nil {
+			logger.Error(err.Error())
+			continue
+		}
+		address = newAddress
+	}
+}
+
+// serviceAddress returns the address of the service. The returned unretryable
+// error is set if the address can't be determined by retrying, e.g. because
+// the type of the service isn't supported, and the returned error is set if
+// the service doesn't have an address yet.
+func (c *Command) serviceAddress() (address string, unretryableErr error, err error) {
+	svc, err := c.k8sClient.CoreV1().Services(c.flagNamespace).Get(c.ctx, c.flagServiceName, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, fmt.Errorf("getting service %s: %s", c.flagServiceName, err)
+	}
+	if c.flagAnnotation != "" {
+		address = svc.Annotations[c.flagAnnotation]
+		if address == "" {
+			return "", nil, fmt.Errorf("service %s has no %s annotation", c.flagServiceName, c.flagAnnotation)
+		}
+		return address, nil, nil
+	}
+	switch svc.Spec.Type {
+	case v1.ServiceTypeClusterIP:
+		return svc.Spec.ClusterIP, nil, nil
+	case v1.ServiceTypeNodePort:
+		return "", errors.New("services of type NodePort are not supported"), nil
+	case v1.ServiceTypeExternalName:
+		return "", errors.New("services of type ExternalName are not supported"), nil
+	case v1.ServiceTypeLoadBalancer:
+		for _, ingr := range svc.Status.LoadBalancer.Ingress {
+			if ingr.IP != "" {
+				return ingr.IP, nil, nil
+			} else if ingr.Hostname != "" {
+				if c.flagResolveHostnames {
+					address, unretryableErr = resolveHostname(ingr.Hostname)
+					return address, unretryableErr, nil
+				}
+				return ingr.Hostname, nil, nil
+			}
+		}
+		return "", nil, fmt.Errorf("service %s has no ingress IP or hostname", c.flagServiceName)
+	default:
+		return "", fmt.Errorf("unknown service type %q", svc.Spec.Type), nil
+	}
+}
 
-	c.UI.Info(fmt.Sprintf("Address %q written to %s successfully", address, c.flagOutputFile))
-	return 0
+// writeAddress writes the address to -output-file and sets it as the wan
+// tagged address of -service-config if they are set.
+func (c *Command) writeAddress(address string) error {
+	if c.flagOutputFile != "" {
+		if err := ioutil.WriteFile(c.flagOutputFile, []byte(address), 0600); err != nil {
+			return fmt.Errorf("Unable to write address to file: %s", err)
+		}
+		c.UI.Info(fmt.Sprintf("Address %q written to %s successfully", address, c.flagOutputFile))
+	}
+	if c.flagServiceConfig != "" {
+		if err := setWANAddress(c.flagServiceConfig, address); err != nil {
+			return fmt.Errorf("Unable to write address to service config: %s", err)
+		}
+		c.UI.Info(fmt.Sprintf("Address %q written to %s successfully", address, c.flagServiceConfig))
+	}
+	return nil
 }
 
 func (c *Command) validateFlags(args []string) error {
@@ -166,12 +241,57 @@ func (c *Command) validateFlags(args []string) error {
 	if c.flagServiceName == "" {
 		return errors.New("-name must be set")
 	}
-	if c.flagOutputFile == "" {
+	if c.flagOutputFile == "" && c.flagServiceConfig == "" {
 		return errors.New("-output-file must be set")
 	}
+	if c.flagServiceConfig != "" && !c.flagWatch {
+		return errors.New("-service-config is supported only when -watch is true")
+	}
+	if c.flagWatch && c.flagWatchPeriod <= 0 {
+		return errors.New("-watch-period must be greater than 0")
+	}
 	return nil
 }
 
+// wanAddressRegexp matches the address of the wan tagged address of a service
+// registration, like the one written by the mesh gateway init container.
+var wanAddressRegexp = regexp.MustCompile(`wan\s*\{[^}]*?address\s*=\s*"([^"]*)"`)
+
+// setWANAddress sets the address of the wan tagged address of the service
+// registration file at path. The file is replaced rather than written in
+// place so that it's never read while it's only partially written.
+func setWANAddress(path, address string) error {
+	config, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	match := wanAddressRegexp.FindSubmatchIndex(config)
+	if match == nil {
+		return fmt.Errorf("%s has no wan tagged address", path)
+	}
+	var updated []byte
+	updated = append(updated, config[:match[2]]...)
+	updated = append(updated, address...)
+	updated = append(updated, config[match[3]:]...)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(updated); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 // resolveHostname returns the first ipv4 address for host.
 func resolveHostname(host string) (string, error) {
 	ips, err := net.LookupIP(host)
@@ -221,4 +341,10 @@ Usage: consul-k8s-control-plane service-address [options]
     NodePort - Not supported
     LoadBalancer - Load balancer's IP or hostname
     ExternalName - Not Supported
+  If -annotation is set, the value of that annotation of the service is
+  written instead, regardless of the service type.
+
+  If -watch is set, the command keeps running and writes the address
+  again whenever it changes, also setting it as the wan tagged address
+  of the service registration file -service-config.
 `
@@ -35,6 +35,14 @@ func TestRun_FlagValidation(t *testing.T) {
 			Flags:  []string{"-k8s-namespace=default", "-name=name"},
 			ExpErr: "-output-file must be set",
 		},
+		{
+			Flags:  []string{"-k8s-namespace=default", "-name=name", "-service-config=service.hcl"},
+			ExpErr: "-service-config is supported only when -watch is true",
+		},
+		{
+			Flags:  []string{"-k8s-namespace=default", "-name=name", "-output-file=address.txt", "-watch", "-watch-period=0s"},
+			ExpErr: "-watch-period must be greater than 0",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.ExpErr, func(t *testing.T) {
@@ -307,6 +315,134 @@ func TestRun_FileWrittenAfterRetry(t *testing.T) {
 	}
 }
 
+// Test that the annotation is used as the address regardless of the service
+// type, and that we retry until it is set.
+func TestRun_Annotation(t *testing.T) {
+	t.Parallel()
+	k8sNS := "default"
+	svcName := "service-name"
+	annotation := "consul.hashicorp.com/mesh-gateway-wan-address"
+	k8s := fake.NewSimpleClientset()
+	_, err := k8s.CoreV1().Services(k8sNS).Create(context.Background(), kubeNodePortSvc(svcName), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		svc := kubeNodePortSvc(svcName)
+		svc.Annotations = map[string]string{annotation: "gateway.example.com"}
+		_, err := k8s.CoreV1().Services(k8sNS).Update(context.Background(), svc, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}()
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:            ui,
+		k8sClient:     k8s,
+		retryDuration: 10 * time.Millisecond,
+	}
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	outputFile := filepath.Join(tmpDir, "address.txt")
+
+	responseCode := cmd.Run([]string{
+		"-k8s-namespace", k8sNS,
+		"-name", svcName,
+		"-annotation", annotation,
+		"-output-file", outputFile,
+	})
+	require.Equal(t, 0, responseCode, ui.ErrorWriter.String())
+	actAddressBytes, err := ioutil.ReadFile(outputFile)
+	require.NoError(t, err)
+	require.Equal(t, "gateway.example.com", string(actAddressBytes))
+}
+
+// Test that with -watch the output file and the wan tagged address of the
+// service config are updated when the address of the service changes.
+func TestRun_Watch(t *testing.T) {
+	t.Parallel()
+	k8sNS := "default"
+	svcName := "service-name"
+	k8s := fake.NewSimpleClientset()
+	_, err := k8s.CoreV1().Services(k8sNS).Create(context.Background(), kubeLoadBalancerSvc(svcName, "1.2.3.4", ""), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	outputFile := filepath.Join(tmpDir, "address.txt")
+	serviceConfig := filepath.Join(tmpDir, "service.hcl")
+	require.NoError(t, ioutil.WriteFile(serviceConfig, []byte(`service {
+  kind = "mesh-gateway"
+  tagged_addresses {
+    lan {
+      address = "10.0.0.1"
+      port = 8443
+    }
+    wan {
+      address = "1.2.3.4"
+      port = 443
+    }
+  }
+}
+`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:            ui,
+		k8sClient:     k8s,
+		retryDuration: 10 * time.Millisecond,
+		ctx:           ctx,
+	}
+	exitCh := make(chan int, 1)
+	go func() {
+		exitCh <- cmd.Run([]string{
+			"-k8s-namespace", k8sNS,
+			"-name", svcName,
+			"-output-file", outputFile,
+			"-service-config", serviceConfig,
+			"-watch",
+			"-watch-period", "10ms",
+		})
+	}()
+
+	_, err = k8s.CoreV1().Services(k8sNS).Update(context.Background(), kubeLoadBalancerSvc(svcName, "5.6.7.8", ""), metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		address, err := ioutil.ReadFile(outputFile)
+		return err == nil && string(address) == "5.6.7.8"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		config, err := ioutil.ReadFile(serviceConfig)
+		require.NoError(t, err)
+		return string(config) == `service {
+  kind = "mesh-gateway"
+  tagged_addresses {
+    lan {
+      address = "10.0.0.1"
+      port = 8443
+    }
+    wan {
+      address = "5.6.7.8"
+      port = 443
+    }
+  }
+}
+`
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case code := <-exitCh:
+		require.Equal(t, 0, code, ui.ErrorWriter.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not exit after the context was cancelled")
+	}
+}
+
 func kubeLoadBalancerSvc(name string, ip string, hostname string) *v1.Service {
 	return &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
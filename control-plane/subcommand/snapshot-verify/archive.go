@@ -0,0 +1,107 @@
+package snapshotverify
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The files of a snapshot archive. The archive is a gzipped tar file with the
+// Raft metadata of the snapshot, the state of the servers and the SHA-256
+// checksums of the two.
+const (
+	archiveMeta  = "meta.json"
+	archiveState = "state.bin"
+	archiveSums  = "SHA256SUMS"
+)
+
+// snapshotMeta is the Raft metadata of a snapshot.
+type snapshotMeta struct {
+	ID    string
+	Index uint64
+	Term  uint64
+}
+
+// verifyArchive reads the snapshot archive from r and checks that it has the
+// metadata and state of a snapshot that match their checksums, which is what
+// `consul snapshot inspect` does. It returns the metadata of the snapshot.
+func verifyArchive(r io.Reader) (snapshotMeta, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return snapshotMeta{}, fmt.Errorf("decompressing snapshot: %s", err)
+	}
+	defer gz.Close()
+
+	var meta snapshotMeta
+	var metaJSON, sums []byte
+	hashes := make(map[string]string)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return snapshotMeta{}, fmt.Errorf("reading snapshot archive: %s", err)
+		}
+
+		var buf bytes.Buffer
+		hash := sha256.New()
+		w := io.Writer(hash)
+		// Only the small files are kept in memory.
+		if header.Name == archiveMeta || header.Name == archiveSums {
+			w = io.MultiWriter(hash, &buf)
+		}
+		if _, err := io.Copy(w, archive); err != nil {
+			return snapshotMeta{}, fmt.Errorf("reading %s of snapshot archive: %s", header.Name, err)
+		}
+		hashes[header.Name] = hex.EncodeToString(hash.Sum(nil))
+		switch header.Name {
+		case archiveMeta:
+			metaJSON = buf.Bytes()
+		case archiveSums:
+			sums = buf.Bytes()
+		}
+	}
+
+	for _, name := range []string{archiveMeta, archiveState, archiveSums} {
+		if _, ok := hashes[name]; !ok {
+			return snapshotMeta{}, fmt.Errorf("snapshot archive has no %s", name)
+		}
+	}
+	expected, err := parseSums(sums)
+	if err != nil {
+		return snapshotMeta{}, err
+	}
+	for _, name := range []string{archiveMeta, archiveState} {
+		if expected[name] != hashes[name] {
+			return snapshotMeta{}, fmt.Errorf("checksum of %s of snapshot archive doesn't match: got %s, expected %s", name, hashes[name], expected[name])
+		}
+	}
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return snapshotMeta{}, fmt.Errorf("decoding %s of snapshot archive: %s", archiveMeta, err)
+	}
+	return meta, nil
+}
+
+// parseSums parses the SHA256SUMS file, which has a line with the checksum and
+// name of each file in the format of sha256sum.
+func parseSums(sums []byte) (map[string]string, error) {
+	expected := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in %s of snapshot archive: %q", archiveSums, scanner.Text())
+		}
+		expected[fields[1]] = fields[0]
+	}
+	return expected, scanner.Err()
+}
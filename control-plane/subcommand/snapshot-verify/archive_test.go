@@ -0,0 +1,98 @@
+package snapshotverify
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyArchive(t *testing.T) {
+	t.Parallel()
+	meta := []byte(`{"ID":"2-13-1645224847186","Index":13,"Term":2}`)
+	state := []byte("state")
+	cases := map[string]struct {
+		files  map[string][]byte
+		expErr string
+	}{
+		"valid": {
+			files: map[string][]byte{
+				archiveMeta:  meta,
+				archiveState: state,
+				archiveSums:  sums(meta, state),
+			},
+		},
+		"corrupted state": {
+			files: map[string][]byte{
+				archiveMeta:  meta,
+				archiveState: []byte("corrupted"),
+				archiveSums:  sums(meta, state),
+			},
+			expErr: "checksum of state.bin of snapshot archive doesn't match",
+		},
+		"no checksums": {
+			files: map[string][]byte{
+				archiveMeta:  meta,
+				archiveState: state,
+			},
+			expErr: "snapshot archive has no SHA256SUMS",
+		},
+		"invalid metadata": {
+			files: map[string][]byte{
+				archiveMeta:  []byte("{"),
+				archiveState: state,
+				archiveSums:  sums([]byte("{"), state),
+			},
+			expErr: "decoding meta.json of snapshot archive",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			got, err := verifyArchive(bytes.NewReader(testArchive(t, c.files)))
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, snapshotMeta{ID: "2-13-1645224847186", Index: 13, Term: 2}, got)
+		})
+	}
+}
+
+func TestVerifyArchive_notGzipped(t *testing.T) {
+	t.Parallel()
+	_, err := verifyArchive(bytes.NewReader([]byte("not a snapshot")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decompressing snapshot")
+}
+
+// sums returns the SHA256SUMS file of a snapshot archive with meta and state.
+func sums(meta, state []byte) []byte {
+	return []byte(fmt.Sprintf("%x  %s\n%x  %s\n", sha256.Sum256(meta), archiveMeta, sha256.Sum256(state), archiveState))
+}
+
+// testArchive returns a snapshot archive with files.
+func testArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for _, name := range []string{archiveMeta, archiveState, archiveSums} {
+		contents, ok := files[name]
+		if !ok {
+			continue
+		}
+		require.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents))}))
+		_, err := archive.Write(contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,382 @@
+package snapshotverify
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// The annotations of the snapshot agent deployment with the result of the
+	// last verification.
+	statusAnnotation     = "consul.hashicorp.com/snapshot-verification"
+	messageAnnotation    = "consul.hashicorp.com/snapshot-verification-message"
+	verifiedAtAnnotation = "consul.hashicorp.com/snapshot-verified-at"
+
+	statusSucceeded = "Succeeded"
+	statusFailed    = "Failed"
+
+	// The reasons of the events recorded on the snapshot agent deployment.
+	eventReasonVerified           = "SnapshotVerified"
+	eventReasonVerificationFailed = "SnapshotVerificationFailed"
+
+	// azureAccountKeyEnvVar is the environment variable with the key of the
+	// Azure storage account.
+	azureAccountKeyEnvVar = "AZURE_BLOB_ACCOUNT_KEY"
+)
+
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	k8s   *flags.K8SFlags
+
+	flagNamespace  string
+	flagDeployment string
+	flagInterval   time.Duration
+	flagListen     string
+
+	flagS3Bucket    string
+	flagS3Region    string
+	flagS3KeyPrefix string
+	flagS3Endpoint  string
+
+	flagGCSBucket string
+
+	flagAzureAccountName   string
+	flagAzureContainerName string
+
+	flagConsulBinary string
+
+	flagLogLevel string
+	flagLogJSON  bool
+
+	k8sClient kubernetes.Interface
+	recorder  record.EventRecorder
+
+	// store is the destination of the snapshots. It is set from the flags
+	// unless it's overridden in tests.
+	store store
+	// restore restores a snapshot into a temporary Consul server. It is nil
+	// if snapshots aren't restored.
+	restore func(ctx context.Context, snapshot io.Reader) (restoreResult, error)
+
+	log     hclog.Logger
+	metrics *metrics
+	sigCh   chan os.Signal
+	once    sync.Once
+	ctx     context.Context
+	help    string
+
+	// now returns the current time. It is overridden in tests.
+	now func() time.Time
+}
+
+// init is run once to set up usage documentation for flags.
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+
+	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagLogJSON, "log-json", false, "Enable or disable JSON output format for logging.")
+	c.flags.StringVar(&c.flagNamespace, "namespace", "", "Name of Kubernetes namespace of the snapshot agent deployment.")
+	c.flags.StringVar(&c.flagDeployment, "deployment", "",
+		"Name of the snapshot agent deployment, which the results of the verifications are recorded on.")
+	c.flags.DurationVar(&c.flagInterval, "interval", 24*time.Hour, "How often to verify the latest snapshot.")
+	c.flags.StringVar(&c.flagListen, "listen", ":8080", "Address to serve metrics on.")
+	c.flags.StringVar(&c.flagS3Bucket, "s3-bucket", "", "AWS S3 bucket of the snapshots.")
+	c.flags.StringVar(&c.flagS3Region, "s3-region", "", "Region of the AWS S3 bucket.")
+	c.flags.StringVar(&c.flagS3KeyPrefix, "s3-key-prefix", "consul", "Prefix of the keys of the snapshots in the AWS S3 bucket.")
+	c.flags.StringVar(&c.flagS3Endpoint, "s3-endpoint", "", "Endpoint of an S3 compatible storage.")
+	c.flags.StringVar(&c.flagGCSBucket, "gcs-bucket", "", "Google Cloud Storage bucket of the snapshots.")
+	c.flags.StringVar(&c.flagAzureAccountName, "azure-blob-account-name", "",
+		fmt.Sprintf("Azure storage account of the snapshots. Its key is read from the %s environment variable.", azureAccountKeyEnvVar))
+	c.flags.StringVar(&c.flagAzureContainerName, "azure-blob-container-name", "", "Azure Blob Storage container of the snapshots.")
+	c.flags.StringVar(&c.flagConsulBinary, "consul-binary", "",
+		"Path of the consul binary. If set, the latest snapshot is restored into a temporary Consul dev server "+
+			"in addition to verifying its checksums.")
+
+	c.k8s = &flags.K8SFlags{}
+	flags.Merge(c.flags, c.k8s.Flags())
+
+	c.help = flags.Usage(help, c.flags)
+
+	// Wait on an interrupt or terminate to exit. This channel must be initialized before
+	// Run() is called so that there are no race conditions where the channel
+	// is not defined.
+	if c.sigCh == nil {
+		c.sigCh = make(chan os.Signal, 1)
+		signal.Notify(c.sigCh, syscall.SIGINT, syscall.SIGTERM)
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+}
+
+// Run periodically downloads the latest snapshot of the snapshot agent and
+// verifies it, and records the result on the snapshot agent deployment.
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+
+	if err := c.flags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to parse args: %v", err))
+		return 1
+	}
+
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to validate flags: %v", err))
+		return 1
+	}
+
+	var err error
+	c.log, err = common.Logger("snapshot-verify", c.flagLogLevel, c.flagLogJSON)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+
+	if c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+	if c.recorder == nil {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.k8sClient.CoreV1().Events(c.flagNamespace)})
+		defer broadcaster.Shutdown()
+		c.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "consul-snapshot-verify"})
+	}
+
+	if c.store == nil {
+		c.store, err = c.newStore()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing snapshot store: %s", err))
+			return 1
+		}
+	}
+	if c.restore == nil && c.flagConsulBinary != "" {
+		r := &restorer{binary: c.flagConsulBinary, log: c.log}
+		c.restore = r.restore
+	}
+
+	c.metrics = newMetrics()
+	registry := prometheus.NewRegistry()
+	if err := c.metrics.register(registry); err != nil {
+		c.UI.Error(fmt.Sprintf("Error registering metrics: %s", err))
+		return 1
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, mux); err != nil {
+			c.UI.Error(fmt.Sprintf("Error listening: %s", err))
+		}
+	}()
+
+	ticker := time.NewTicker(c.flagInterval)
+	defer ticker.Stop()
+	for {
+		c.reconcile()
+
+		select {
+		case <-ticker.C:
+		case sig := <-c.sigCh:
+			c.log.Info(fmt.Sprintf("%s received, shutting down", sig))
+			return 0
+		}
+	}
+}
+
+// reconcile verifies the latest snapshot and records the result.
+func (c *Command) reconcile() {
+	message, err := c.verify()
+	if err != nil {
+		c.log.Error("failed to verify the latest snapshot", "err", err)
+		c.metrics.verifications.WithLabelValues("failure").Inc()
+		c.report(statusFailed, err.Error())
+		return
+	}
+	c.log.Info(message)
+	c.metrics.verifications.WithLabelValues("success").Inc()
+	c.metrics.lastSuccess.Set(float64(c.now().Unix()))
+	c.report(statusSucceeded, message)
+}
+
+// newStore returns the store of the snapshots of the flags.
+func (c *Command) newStore() (store, error) {
+	switch {
+	case c.flagS3Bucket != "":
+		return newS3Store(c.flagS3Region, c.flagS3Endpoint, c.flagS3Bucket, c.flagS3KeyPrefix)
+	case c.flagGCSBucket != "":
+		return newGCSStore(c.ctx, c.flagGCSBucket)
+	default:
+		return newAzureStore(c.flagAzureAccountName, os.Getenv(azureAccountKeyEnvVar), c.flagAzureContainerName)
+	}
+}
+
+// verify downloads the latest snapshot, verifies its checksums and restores
+// it if snapshots are restored. It returns a description of the verified
+// snapshot.
+func (c *Command) verify() (string, error) {
+	latest, err := c.store.latest(c.ctx)
+	if err != nil {
+		return "", err
+	}
+	age := c.now().Sub(latest.modified).Round(time.Second)
+	c.metrics.snapshotAge.Set(age.Seconds())
+	c.metrics.snapshotSize.Set(float64(latest.size))
+
+	file, err := ioutil.TempFile("", "consul-snapshot-")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if err := c.store.download(c.ctx, latest.name, file); err != nil {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	meta, err := verifyArchive(file)
+	if err != nil {
+		return "", fmt.Errorf("snapshot %s is invalid: %s", latest.name, err)
+	}
+	message := fmt.Sprintf("Verified snapshot %s saved %s ago at Raft index %d", latest.name, age, meta.Index)
+	if c.restore == nil {
+		return message + ".", nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	result, err := c.restore(c.ctx, file)
+	if err != nil {
+		return "", fmt.Errorf("snapshot %s can't be restored: %s", latest.name, err)
+	}
+	return fmt.Sprintf("%s and restored it into a temporary Consul server with %d nodes and %d services.",
+		message, result.nodes, result.services), nil
+}
+
+// report records the result of a verification with an event and annotations
+// on the snapshot agent deployment.
+func (c *Command) report(status, message string) {
+	deployment, err := c.k8sClient.AppsV1().Deployments(c.flagNamespace).Get(c.ctx, c.flagDeployment, metav1.GetOptions{})
+	if err != nil {
+		c.log.Error("failed to get snapshot agent deployment", "deployment", c.flagDeployment, "err", err)
+		return
+	}
+	if status == statusSucceeded {
+		c.recorder.Event(deployment, corev1.EventTypeNormal, eventReasonVerified, message)
+	} else {
+		c.recorder.Event(deployment, corev1.EventTypeWarning, eventReasonVerificationFailed, message)
+	}
+
+	annotations := map[string]string{
+		statusAnnotation:  status,
+		messageAnnotation: message,
+	}
+	if status == statusSucceeded {
+		annotations[verifiedAtAnnotation] = c.now().UTC().Format(time.RFC3339)
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		c.log.Error("failed to encode patch", "err", err)
+		return
+	}
+	if _, err := c.k8sClient.AppsV1().Deployments(c.flagNamespace).Patch(c.ctx, c.flagDeployment, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		c.log.Error("failed to record verification on snapshot agent deployment", "deployment", c.flagDeployment, "err", err)
+	}
+}
+
+// Help returns the command's help text.
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+// Synopsis returns a one-line synopsis of the command.
+func (c *Command) Synopsis() string {
+	return synopsis
+}
+
+// validateFlags ensures that all required flags are set.
+func (c *Command) validateFlags() error {
+	if c.flagNamespace == "" {
+		return fmt.Errorf("-namespace must be set")
+	}
+
+	if c.flagDeployment == "" {
+		return fmt.Errorf("-deployment must be set")
+	}
+
+	if c.flagInterval <= 0 {
+		return fmt.Errorf("-interval must be greater than 0")
+	}
+
+	destinations := 0
+	for _, bucket := range []string{c.flagS3Bucket, c.flagGCSBucket, c.flagAzureContainerName} {
+		if bucket != "" {
+			destinations++
+		}
+	}
+	if destinations != 1 {
+		return fmt.Errorf("exactly one of -s3-bucket, -gcs-bucket or -azure-blob-container-name must be set")
+	}
+
+	if c.flagAzureContainerName != "" && c.flagAzureAccountName == "" {
+		return fmt.Errorf("-azure-blob-account-name must be set with -azure-blob-container-name")
+	}
+
+	return nil
+}
+
+const synopsis = "Periodically verify the snapshots of the snapshot agent."
+const help = `
+Usage: consul-k8s-control-plane snapshot-verify [options]
+
+  Downloads the latest snapshot that the Consul snapshot agent saved to an
+  AWS S3 bucket, Google Cloud Storage bucket or Azure Blob Storage container
+  and verifies its checksums. With -consul-binary, the snapshot is also
+  restored into a temporary Consul dev server. The result is served as
+  Prometheus metrics and recorded with an event and annotations on the
+  snapshot agent deployment.
+`
@@ -0,0 +1,196 @@
+package snapshotverify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	namespace  = "default"
+	deployment = "consul-snapshot-agent"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "-namespace must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default"},
+			expErr: "-deployment must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-deployment", deployment, "-interval", "0s"},
+			expErr: "-interval must be greater than 0",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-deployment", deployment},
+			expErr: "exactly one of -s3-bucket, -gcs-bucket or -azure-blob-container-name must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-deployment", deployment, "-s3-bucket", "backups", "-gcs-bucket", "backups"},
+			expErr: "exactly one of -s3-bucket, -gcs-bucket or -azure-blob-container-name must be set",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-deployment", deployment, "-azure-blob-container-name", "backups"},
+			expErr: "-azure-blob-account-name must be set with -azure-blob-container-name",
+		},
+		{
+			flags:  []string{"-namespace", "default", "-deployment", deployment, "-s3-bucket", "backups", "-log-level", "oak"},
+			expErr: "unknown log level",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			code := cmd.Run(c.flags)
+			require.Equal(t, 1, code)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+	meta := []byte(`{"ID":"2-13-1645224847186","Index":13,"Term":2}`)
+	state := []byte("state")
+	valid := map[string][]byte{archiveMeta: meta, archiveState: state, archiveSums: sums(meta, state)}
+
+	cases := map[string]struct {
+		files      map[string][]byte
+		storeErr   error
+		restore    func(ctx context.Context, snapshot io.Reader) (restoreResult, error)
+		expStatus  string
+		expMessage string
+		expEvent   string
+	}{
+		"verified": {
+			files:      valid,
+			expStatus:  statusSucceeded,
+			expMessage: "Verified snapshot consul-1646132400000000000.snap saved 2h0m0s ago at Raft index 13.",
+			expEvent:   "Normal SnapshotVerified",
+		},
+		"restored": {
+			files: valid,
+			restore: func(_ context.Context, snapshot io.Reader) (restoreResult, error) {
+				// The whole snapshot is restored.
+				_, err := verifyArchive(snapshot)
+				return restoreResult{nodes: 3, services: 5}, err
+			},
+			expStatus: statusSucceeded,
+			expMessage: "Verified snapshot consul-1646132400000000000.snap saved 2h0m0s ago at Raft index 13 " +
+				"and restored it into a temporary Consul server with 3 nodes and 5 services.",
+			expEvent: "Normal SnapshotVerified",
+		},
+		"corrupted": {
+			files:     map[string][]byte{archiveMeta: meta, archiveState: []byte("corrupted"), archiveSums: sums(meta, state)},
+			expStatus: statusFailed,
+			expMessage: "snapshot consul-1646132400000000000.snap is invalid: " +
+				"checksum of state.bin of snapshot archive doesn't match",
+			expEvent: "Warning SnapshotVerificationFailed",
+		},
+		"restore failure": {
+			files: valid,
+			restore: func(context.Context, io.Reader) (restoreResult, error) {
+				return restoreResult{}, errors.New("restoring snapshot into temporary Consul server: 500")
+			},
+			expStatus:  statusFailed,
+			expMessage: "snapshot consul-1646132400000000000.snap can't be restored: restoring snapshot into temporary Consul server: 500",
+			expEvent:   "Warning SnapshotVerificationFailed",
+		},
+		"store failure": {
+			storeErr:   errors.New(`no snapshots found in S3 bucket "backups"`),
+			expStatus:  statusFailed,
+			expMessage: `no snapshots found in S3 bucket "backups"`,
+			expEvent:   "Warning SnapshotVerificationFailed",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2022, 3, 1, 13, 0, 0, 0, time.UTC)
+			k8s := fake.NewSimpleClientset(&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: deployment, Namespace: namespace},
+			})
+			recorder := record.NewFakeRecorder(1)
+			cmd := &Command{
+				UI:             cli.NewMockUi(),
+				k8sClient:      k8s,
+				recorder:       recorder,
+				flagNamespace:  namespace,
+				flagDeployment: deployment,
+				store: &fakeStore{
+					snapshot: snapshot{name: "consul-1646132400000000000.snap", modified: now.Add(-2 * time.Hour), size: 1024},
+					contents: testArchive(t, c.files),
+					err:      c.storeErr,
+				},
+				restore: c.restore,
+				log:     hclog.NewNullLogger(),
+				metrics: newMetrics(),
+				ctx:     context.Background(),
+				now:     func() time.Time { return now },
+			}
+
+			cmd.reconcile()
+
+			dep, err := k8s.AppsV1().Deployments(namespace).Get(context.Background(), deployment, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, c.expStatus, dep.Annotations[statusAnnotation])
+			require.Contains(t, dep.Annotations[messageAnnotation], c.expMessage)
+			require.Len(t, recorder.Events, 1)
+			require.Contains(t, <-recorder.Events, fmt.Sprintf("%s %s", c.expEvent, c.expMessage))
+
+			if c.expStatus == statusSucceeded {
+				require.Equal(t, "2022-03-01T13:00:00Z", dep.Annotations[verifiedAtAnnotation])
+				require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.verifications.WithLabelValues("success")))
+				require.Equal(t, float64(now.Unix()), testutil.ToFloat64(cmd.metrics.lastSuccess))
+			} else {
+				require.NotContains(t, dep.Annotations, verifiedAtAnnotation)
+				require.Equal(t, float64(1), testutil.ToFloat64(cmd.metrics.verifications.WithLabelValues("failure")))
+			}
+			if c.storeErr == nil {
+				require.Equal(t, float64(7200), testutil.ToFloat64(cmd.metrics.snapshotAge))
+				require.Equal(t, float64(1024), testutil.ToFloat64(cmd.metrics.snapshotSize))
+			}
+		})
+	}
+}
+
+// fakeStore has a single snapshot.
+type fakeStore struct {
+	snapshot snapshot
+	contents []byte
+	err      error
+}
+
+func (s *fakeStore) latest(context.Context) (snapshot, error) {
+	return s.snapshot, s.err
+}
+
+func (s *fakeStore) download(_ context.Context, _ string, w io.Writer) error {
+	_, err := io.Copy(w, bytes.NewReader(s.contents))
+	return err
+}
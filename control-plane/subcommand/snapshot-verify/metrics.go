@@ -0,0 +1,63 @@
+package snapshotverify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "consul_k8s"
+	metricsSubsystem = "snapshot_verification"
+)
+
+// metrics are the Prometheus metrics of snapshot verification.
+type metrics struct {
+	// verifications is the number of verifications by their result.
+	verifications *prometheus.CounterVec
+
+	// lastSuccess is the time of the last successful verification.
+	lastSuccess prometheus.Gauge
+
+	// snapshotAge is the age of the latest snapshot when it was verified.
+	snapshotAge prometheus.Gauge
+
+	// snapshotSize is the size of the latest snapshot.
+	snapshotSize prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "verifications_total",
+			Help:      "Number of verifications of the latest snapshot by their result, success or failure.",
+		}, []string{"result"}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful verification of the latest snapshot.",
+		}),
+		snapshotAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "snapshot_age_seconds",
+			Help:      "Age of the latest snapshot when it was last verified.",
+		}),
+		snapshotSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "snapshot_size_bytes",
+			Help:      "Size of the latest snapshot when it was last verified.",
+		}),
+	}
+}
+
+func (m *metrics) register(registry prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.verifications, m.lastSuccess, m.snapshotAge, m.snapshotSize} {
+		if err := registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
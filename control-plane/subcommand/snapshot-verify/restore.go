@@ -0,0 +1,115 @@
+package snapshotverify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// restoreHTTPAddr is the address of the HTTP API of the temporary Consul
+	// server that snapshots are restored into.
+	restoreHTTPAddr = "127.0.0.1:18500"
+
+	// restoreStartTimeout is how long the temporary Consul server has to
+	// elect itself leader.
+	restoreStartTimeout = time.Minute
+)
+
+// restoreResult is what a snapshot restored into a temporary server has.
+type restoreResult struct {
+	nodes    int
+	services int
+}
+
+// restorer restores snapshots into temporary Consul dev servers, which are
+// started with the consul binary and stopped once the snapshot was restored.
+type restorer struct {
+	binary string
+	log    hclog.Logger
+}
+
+// restore restores the snapshot read from r into a new Consul server and
+// returns how many nodes and services are in its catalog afterwards.
+func (r *restorer) restore(ctx context.Context, snapshot io.Reader) (restoreResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The dev server keeps its state in memory, so nothing is left behind.
+	cmd := exec.CommandContext(ctx, r.binary, "agent", "-dev",
+		"-bind=127.0.0.1",
+		"-client=127.0.0.1",
+		"-http-port=18500",
+		"-dns-port=-1",
+		"-grpc-port=-1",
+		"-server-port=18300",
+		"-serf-lan-port=18301",
+		"-serf-wan-port=18302",
+		"-log-level=warn")
+	logWriter := r.log.Named("consul").StandardWriter(&hclog.StandardLoggerOptions{InferLevels: true})
+	cmd.Stdout = logWriter
+	cmd.Stderr = logWriter
+	if err := cmd.Start(); err != nil {
+		return restoreResult{}, fmt.Errorf("starting temporary Consul server: %s", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+	}()
+
+	client, err := api.NewClient(&api.Config{Address: restoreHTTPAddr})
+	if err != nil {
+		return restoreResult{}, err
+	}
+	if err := waitForLeader(ctx, client, exited); err != nil {
+		return restoreResult{}, err
+	}
+
+	if err := client.Snapshot().Restore(nil, snapshot); err != nil {
+		return restoreResult{}, fmt.Errorf("restoring snapshot into temporary Consul server: %s", err)
+	}
+	nodes, _, err := client.Catalog().Nodes(nil)
+	if err != nil {
+		return restoreResult{}, fmt.Errorf("listing nodes of restored snapshot: %s", err)
+	}
+	services, _, err := client.Catalog().Services(nil)
+	if err != nil {
+		return restoreResult{}, fmt.Errorf("listing services of restored snapshot: %s", err)
+	}
+	return restoreResult{nodes: len(nodes), services: len(services)}, nil
+}
+
+// waitForLeader waits until the temporary server has elected itself leader.
+func waitForLeader(ctx context.Context, client *api.Client, exited <-chan struct{}) error {
+	timeout := time.After(restoreStartTimeout)
+	for {
+		if leader, err := client.Status().Leader(); err == nil && leader != "" {
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("temporary Consul server exited before it elected a leader")
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for temporary Consul server to elect a leader")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
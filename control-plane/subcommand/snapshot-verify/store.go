@@ -0,0 +1,296 @@
+package snapshotverify
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/oauth2/google"
+)
+
+// snapshotSuffix is the suffix of the names of the snapshots that the
+// snapshot agent saves.
+const snapshotSuffix = ".snap"
+
+// snapshot is a snapshot saved by the snapshot agent in a store.
+type snapshot struct {
+	name     string
+	modified time.Time
+	size     int64
+}
+
+// store is a destination of the snapshot agent.
+type store interface {
+	// latest returns the most recently saved snapshot. It returns an error if
+	// there are no snapshots.
+	latest(ctx context.Context) (snapshot, error)
+	// download writes the contents of the snapshot name to w.
+	download(ctx context.Context, name string, w io.Writer) error
+}
+
+// newer returns a if it was saved after b.
+func newer(a, b snapshot) snapshot {
+	if a.modified.After(b.modified) {
+		return a
+	}
+	return b
+}
+
+// errNoSnapshots is returned by stores without any snapshots.
+func errNoSnapshots(location string) error {
+	return fmt.Errorf("no snapshots found in %s", location)
+}
+
+// s3Store is an AWS S3 bucket. It authenticates with the AWS credential chain,
+// e.g. the IAM role of the service account or the AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY environment variables.
+type s3Store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func newS3Store(region, endpoint, bucket, prefix string) (*s3Store, error) {
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create AWS session: %s", err)
+	}
+	return &s3Store{client: s3.New(sess), bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *s3Store) latest(ctx context.Context) (snapshot, error) {
+	var latest snapshot
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(s.prefix)}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			name := aws.StringValue(object.Key)
+			if !strings.HasSuffix(name, snapshotSuffix) {
+				continue
+			}
+			latest = newer(snapshot{
+				name:     name,
+				modified: aws.TimeValue(object.LastModified),
+				size:     aws.Int64Value(object.Size),
+			}, latest)
+		}
+		return true
+	})
+	if err != nil {
+		return snapshot{}, fmt.Errorf("listing objects of S3 bucket %q: %s", s.bucket, err)
+	}
+	if latest.name == "" {
+		return snapshot{}, errNoSnapshots(fmt.Sprintf("S3 bucket %q", s.bucket))
+	}
+	return latest, nil
+}
+
+func (s *s3Store) download(ctx context.Context, name string, w io.Writer) error {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(name)})
+	if err != nil {
+		return fmt.Errorf("getting object %q of S3 bucket %q: %s", name, s.bucket, err)
+	}
+	defer output.Body.Close()
+	if _, err := io.Copy(w, output.Body); err != nil {
+		return fmt.Errorf("downloading object %q of S3 bucket %q: %s", name, s.bucket, err)
+	}
+	return nil
+}
+
+// gcsStore is a Google Cloud Storage bucket. It authenticates with the Google
+// application default credentials, e.g. the workload identity of the service
+// account or the key file at GOOGLE_APPLICATION_CREDENTIALS.
+type gcsStore struct {
+	client  *http.Client
+	baseURL string
+	bucket  string
+}
+
+func newGCSStore(ctx context.Context, bucket string) (*gcsStore, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_only")
+	if err != nil {
+		return nil, fmt.Errorf("unable to find Google credentials: %s", err)
+	}
+	return &gcsStore{client: client, baseURL: "https://storage.googleapis.com", bucket: bucket}, nil
+}
+
+func (s *gcsStore) latest(ctx context.Context) (snapshot, error) {
+	var latest snapshot
+	pageToken := ""
+	for {
+		query := url.Values{"fields": {"items(name,updated,size),nextPageToken"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page struct {
+			Items []struct {
+				Name    string    `json:"name"`
+				Updated time.Time `json:"updated"`
+				Size    string    `json:"size"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err := s.get(ctx, fmt.Sprintf("/storage/v1/b/%s/o?%s", url.PathEscape(s.bucket), query.Encode()), func(body io.Reader) error {
+			return json.NewDecoder(body).Decode(&page)
+		})
+		if err != nil {
+			return snapshot{}, fmt.Errorf("listing objects of GCS bucket %q: %s", s.bucket, err)
+		}
+		for _, item := range page.Items {
+			if !strings.HasSuffix(item.Name, snapshotSuffix) {
+				continue
+			}
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			latest = newer(snapshot{name: item.Name, modified: item.Updated, size: size}, latest)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	if latest.name == "" {
+		return snapshot{}, errNoSnapshots(fmt.Sprintf("GCS bucket %q", s.bucket))
+	}
+	return latest, nil
+}
+
+func (s *gcsStore) download(ctx context.Context, name string, w io.Writer) error {
+	err := s.get(ctx, fmt.Sprintf("/storage/v1/b/%s/o/%s?alt=media", url.PathEscape(s.bucket), url.PathEscape(name)), func(body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("downloading object %q of GCS bucket %q: %s", name, s.bucket, err)
+	}
+	return nil
+}
+
+func (s *gcsStore) get(ctx context.Context, path string, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	return do(s.client, req, read)
+}
+
+// azureAPIVersion is the version of the Azure Blob Storage REST API.
+const azureAPIVersion = "2019-12-12"
+
+// azureStore is an Azure Blob Storage container. It authenticates with the
+// key of the storage account.
+type azureStore struct {
+	client     *http.Client
+	authorizer *autorest.SharedKeyAuthorizer
+	baseURL    string
+	container  string
+}
+
+func newAzureStore(accountName, accountKey, container string) (*azureStore, error) {
+	authorizer, err := autorest.NewSharedKeyAuthorizer(accountName, accountKey, autorest.SharedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Azure storage account key: %s", err)
+	}
+	return &azureStore{
+		client:     http.DefaultClient,
+		authorizer: authorizer,
+		baseURL:    fmt.Sprintf("https://%s.blob.core.windows.net", accountName),
+		container:  container,
+	}, nil
+}
+
+func (s *azureStore) latest(ctx context.Context) (snapshot, error) {
+	var latest snapshot
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		var page struct {
+			Blobs []struct {
+				Name          string `xml:"Name"`
+				LastModified  string `xml:"Properties>Last-Modified"`
+				ContentLength int64  `xml:"Properties>Content-Length"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		err := s.get(ctx, fmt.Sprintf("/%s?%s", url.PathEscape(s.container), query.Encode()), func(body io.Reader) error {
+			return xml.NewDecoder(body).Decode(&page)
+		})
+		if err != nil {
+			return snapshot{}, fmt.Errorf("listing blobs of Azure container %q: %s", s.container, err)
+		}
+		for _, blob := range page.Blobs {
+			if !strings.HasSuffix(blob.Name, snapshotSuffix) {
+				continue
+			}
+			modified, _ := time.Parse(time.RFC1123, blob.LastModified)
+			latest = newer(snapshot{name: blob.Name, modified: modified, size: blob.ContentLength}, latest)
+		}
+		if page.NextMarker == "" {
+			break
+		}
+		marker = page.NextMarker
+	}
+	if latest.name == "" {
+		return snapshot{}, errNoSnapshots(fmt.Sprintf("Azure container %q", s.container))
+	}
+	return latest, nil
+}
+
+func (s *azureStore) download(ctx context.Context, name string, w io.Writer) error {
+	err := s.get(ctx, fmt.Sprintf("/%s/%s", url.PathEscape(s.container), (&url.URL{Path: name}).EscapedPath()), func(body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("downloading blob %q of Azure container %q: %s", name, s.container, err)
+	}
+	return nil
+}
+
+func (s *azureStore) get(ctx context.Context, path string, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req, err = autorest.Prepare(req, s.authorizer.WithAuthorization())
+	if err != nil {
+		return fmt.Errorf("signing request: %s", err)
+	}
+	return do(s.client, req, read)
+}
+
+// do sends req with client and reads the body of a successful response.
+func do(client *http.Client, req *http.Request, read func(io.Reader) error) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return read(resp.Body)
+}
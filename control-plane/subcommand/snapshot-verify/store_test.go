@@ -0,0 +1,133 @@
+package snapshotverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The stores return the most recently saved snapshot, ignoring other objects,
+// and download it.
+func TestStores(t *testing.T) {
+	older := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	cases := map[string]struct {
+		handler  http.HandlerFunc
+		newStore func(url string) (store, error)
+		expName  string
+	}{
+		"S3": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/backups" && r.URL.Query().Get("list-type") == "2":
+					require.Equal(t, "consul", r.URL.Query().Get("prefix"))
+					require.Contains(t, r.Header.Get("Authorization"), "Credential=access-key-id/")
+					fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Name>backups</Name><IsTruncated>false</IsTruncated>
+<Contents><Key>consul/consul-1646128800000000000.snap</Key><LastModified>%s</LastModified><Size>10</Size></Contents>
+<Contents><Key>consul/consul-1646132400000000000.snap</Key><LastModified>%s</LastModified><Size>12</Size></Contents>
+<Contents><Key>consul/notes.txt</Key><LastModified>%s</LastModified><Size>1</Size></Contents>
+</ListBucketResult>`, older.Format(time.RFC3339), newer.Format(time.RFC3339), newer.Add(time.Hour).Format(time.RFC3339))
+				case r.URL.Path == "/backups/consul/consul-1646132400000000000.snap":
+					w.Write([]byte("snapshot"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			newStore: func(url string) (store, error) {
+				return newS3Store("us-east-1", url, "backups", "consul")
+			},
+			expName: "consul/consul-1646132400000000000.snap",
+		},
+		"GCS": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/storage/v1/b/backups/o":
+					if r.URL.Query().Get("pageToken") == "" {
+						fmt.Fprintf(w, `{"items":[{"name":"consul-1646128800000000000.snap","updated":%q,"size":"10"}],"nextPageToken":"2"}`,
+							older.Format(time.RFC3339))
+						return
+					}
+					fmt.Fprintf(w, `{"items":[{"name":"consul-1646132400000000000.snap","updated":%q,"size":"12"},{"name":"notes.txt","updated":%q,"size":"1"}]}`,
+						newer.Format(time.RFC3339), newer.Add(time.Hour).Format(time.RFC3339))
+				case "/storage/v1/b/backups/o/consul-1646132400000000000.snap":
+					require.Equal(t, "media", r.URL.Query().Get("alt"))
+					w.Write([]byte("snapshot"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			newStore: func(url string) (store, error) {
+				return &gcsStore{client: http.DefaultClient, baseURL: url, bucket: "backups"}, nil
+			},
+			expName: "consul-1646132400000000000.snap",
+		},
+		"Azure": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Contains(t, r.Header.Get("Authorization"), "SharedKey account:")
+				switch {
+				case r.URL.Path == "/backups" && r.URL.Query().Get("comp") == "list":
+					fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+<Blob><Name>consul-1646128800000000000.snap</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>10</Content-Length></Properties></Blob>
+<Blob><Name>consul-1646132400000000000.snap</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>12</Content-Length></Properties></Blob>
+</Blobs><NextMarker /></EnumerationResults>`, older.Format(time.RFC1123), newer.Format(time.RFC1123))
+				case r.URL.Path == "/backups/consul-1646132400000000000.snap":
+					w.Write([]byte("snapshot"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+			newStore: func(url string) (store, error) {
+				s, err := newAzureStore("account", base64.StdEncoding.EncodeToString([]byte("key")), "backups")
+				if err != nil {
+					return nil, err
+				}
+				s.baseURL = url
+				return s, nil
+			},
+			expName: "consul-1646132400000000000.snap",
+		},
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "access-key-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-access-key")
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(c.handler)
+			defer server.Close()
+			s, err := c.newStore(server.URL)
+			require.NoError(t, err)
+
+			latest, err := s.latest(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, c.expName, latest.name)
+			require.True(t, newer.Equal(latest.modified), "modified %s", latest.modified)
+			require.Equal(t, int64(12), latest.size)
+
+			var buf bytes.Buffer
+			require.NoError(t, s.download(context.Background(), latest.name, &buf))
+			require.Equal(t, "snapshot", buf.String())
+
+			err = s.download(context.Background(), "consul-missing.snap", &buf)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestStores_noSnapshots(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	s := &gcsStore{client: http.DefaultClient, baseURL: server.URL, bucket: "backups"}
+	_, err := s.latest(context.Background())
+	require.EqualError(t, err, `no snapshots found in GCS bucket "backups"`)
+}
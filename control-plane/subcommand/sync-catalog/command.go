@@ -13,18 +13,26 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	catalogtoconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
 	catalogtok8s "github.com/hashicorp/consul-k8s/control-plane/catalog/to-k8s"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/health"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
 // Command is the command for syncing the K8S and Consul service
@@ -41,18 +49,38 @@ type Command struct {
 	flagConsulDomain          string
 	flagConsulK8STag          string
 	flagConsulNodeName        string
+	flagClusterName           string
+	flagAdoptUnownedServices  bool
 	flagK8SDefault            bool
 	flagK8SServicePrefix      string
 	flagConsulServicePrefix   string
 	flagK8SSourceNamespace    string
 	flagK8SWriteNamespace     string
 	flagConsulWritePeriod     time.Duration
+	flagConsulWriteBatchSize  int
+	flagConsulWriteRateLimit  float64
 	flagSyncClusterIPServices bool
 	flagSyncLBEndpoints       bool
 	flagNodePortSyncType      string
 	flagAddK8SNamespaceSuffix bool
 	flagLogLevel              string
 	flagLogJSON               bool
+	flagConflictPolicy        string
+	flagSyncPodAnnotations    bool
+
+	// Flags to support syncing ExternalName services
+	flagSyncExternalNameServices bool   // Sync ExternalName services as external services
+	flagConsulExternalNodeName   string // Consul node to register ExternalName services with
+
+	// Flags to sync the readiness of k8s endpoints to Consul health checks
+	flagSyncHealthChecks         bool          // Register a readiness health check with each synced service instance
+	flagHealthCheckName          string        // Name of the readiness health checks
+	flagHealthCheckCriticalAfter time.Duration // How long an instance must be not ready for before its check is critical
+
+	// Flags to filter which k8s services are synced
+	flagK8SServiceSelector    string   // Label selector that k8s services must match to be synced
+	flagIncludeK8SAnnotations []string // Annotation filters that k8s services must match one of to be synced
+	flagExcludeK8SAnnotations []string // Annotation filters that exclude k8s services from syncing (has precedence)
 
 	// Flags to support namespaces
 	flagEnableNamespaces           bool     // Use namespacing on all components
@@ -62,10 +90,19 @@ type Command struct {
 	flagEnableK8SNSMirroring       bool     // Enables mirroring of k8s namespaces into Consul
 	flagK8SNSMirroringPrefix       string   // Prefix added to Consul namespaces created when mirroring
 	flagCrossNamespaceACLPolicy    string   // The name of the ACL policy to add to every created namespace if ACLs are enabled
+	flagNamespaceMappingRules      []string // Rules that map k8s namespaces to Consul partitions and namespaces
 
 	consulClient *api.Client
 	clientset    kubernetes.Interface
 
+	conflictPolicy catalogtoconsul.ConflictPolicy
+
+	serviceSelector    labels.Selector
+	includeAnnotations []catalogtoconsul.AnnotationFilter
+	excludeAnnotations []catalogtoconsul.AnnotationFilter
+
+	namespaceMappingRules []catalogtoconsul.NamespaceMappingRule
+
 	once   sync.Once
 	sigCh  chan os.Signal
 	help   string
@@ -103,16 +140,50 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagConsulNodeName, "consul-node-name", "k8s-sync",
 		"The Consul node name to register for catalog sync. Defaults to k8s-sync. To be discoverable "+
 			"via DNS, the name should only contain alpha-numerics and dashes.")
+	c.flags.StringVar(&c.flagClusterName, "cluster-name", "",
+		"The name of the Kubernetes cluster, which must be unique among the clusters that sync services into the "+
+			"same Consul datacenter. If set, the cluster name is recorded in the node and service meta, is part of "+
+			"the service instance IDs and is appended to the Consul node names unless they are set explicitly, and "+
+			"only service instances synced from this cluster are deregistered.")
+	c.flags.BoolVar(&c.flagAdoptUnownedServices, "adopt-unowned-services", false,
+		"If true, service instances synced to Consul without a cluster name, including the ones on the Consul "+
+			"node names without the cluster name, are deregistered and synced again with -cluster-name. Set this "+
+			"in the cluster that synced services before -cluster-name was set to migrate its service instances. "+
+			"Requires -cluster-name.")
+	c.flags.StringVar(&c.flagConsulExternalNodeName, "consul-external-node-name", "k8s-sync-external",
+		"The Consul node name to register ExternalName services with when -sync-external-name-services is true. "+
+			"Defaults to k8s-sync-external. To be discoverable via DNS, the name should only contain alpha-numerics and dashes.")
 	c.flags.DurationVar(&c.flagConsulWritePeriod, "consul-write-interval", 30*time.Second,
 		"The interval to perform syncing operations creating Consul services, formatted "+
 			"as a time.Duration. All changes are merged and write calls are only made "+
 			"on this interval. Defaults to 30 seconds (30s).")
+	c.flags.IntVar(&c.flagConsulWriteBatchSize, "consul-write-batch-size", catalogtoconsul.DefaultConsulWriteBatchSize,
+		"The maximum number of service instances to register or deregister in Consul in a single write. "+
+			"Writes with more operations than a Consul transaction allows are split into several transactions. "+
+			fmt.Sprintf("Defaults to %d.", catalogtoconsul.DefaultConsulWriteBatchSize))
+	c.flags.Float64Var(&c.flagConsulWriteRateLimit, "consul-write-rate-limit", 0,
+		"The maximum number of write requests per second to make to Consul when syncing services to Consul. "+
+			"If 0, writes are not rate limited. Defaults to 0.")
 	c.flags.BoolVar(&c.flagSyncClusterIPServices, "sync-clusterip-services", true,
 		"If true, all valid ClusterIP services in K8S are synced by default. If false, "+
 			"ClusterIP services are not synced to Consul.")
 	c.flags.BoolVar(&c.flagSyncLBEndpoints, "sync-lb-services-endpoints", false,
 		"If true, LoadBalancer service endpoints instead of ingress addresses will be synced to Consul. If false, "+
 			"LoadBalancer endpoints are not synced to Consul.")
+	c.flags.BoolVar(&c.flagSyncExternalNameServices, "sync-external-name-services", false,
+		"If true, ExternalName services in K8S are synced to Consul as external services using the external "+
+			"name as their address. If false, ExternalName services are not synced to Consul.")
+	c.flags.BoolVar(&c.flagSyncHealthChecks, "sync-health-checks", false,
+		"If true, a health check reflecting the readiness of the K8S endpoint is registered with each synced "+
+			"service instance, and instances of endpoints that are not ready are registered with a failing check. "+
+			"If false, instances of endpoints that are not ready are not synced to Consul.")
+	c.flags.StringVar(&c.flagHealthCheckName, "health-check-name", catalogtoconsul.DefaultHealthCheckName,
+		"The name of the health checks registered when -sync-health-checks is true. Can be overridden per K8S "+
+			"service with the 'consul.hashicorp.com/service-health-check-name' annotation.")
+	c.flags.DurationVar(&c.flagHealthCheckCriticalAfter, "health-check-critical-after", 0,
+		"How long a service instance must be not ready for before its health check is critical. Until then, "+
+			"the health check is warning. Defaults to 0s, which makes the health check critical as soon as the "+
+			"instance is not ready.")
 	c.flags.StringVar(&c.flagNodePortSyncType, "node-port-sync-type", "ExternalOnly",
 		"Defines the type of sync for NodePort services. Valid options are ExternalOnly, "+
 			"InternalOnly and ExternalFirst.")
@@ -120,6 +191,14 @@ func (c *Command) init() {
 		"If true, Kubernetes namespace will be appended to service names synced to Consul separated by a dash. "+
 			"If false, no suffix will be appended to the service names in Consul. "+
 			"If the service name annotation is provided, the suffix is not appended.")
+	c.flags.StringVar(&c.flagConflictPolicy, "conflict-policy", string(catalogtoconsul.ConflictPolicyK8SWins),
+		"The policy for resolving conflicts between K8S services and Consul services of the same name. "+
+			"Valid options are k8s-wins, consul-wins, merge and error. Can be overridden per K8S service with "+
+			"the 'consul.hashicorp.com/service-sync-conflict-policy' annotation. Defaults to k8s-wins.")
+	c.flags.BoolVar(&c.flagSyncPodAnnotations, "sync-pod-annotations", false,
+		"If true, the meta, weights and tagged addresses annotations of the pods of K8S endpoints are set on "+
+			"the service instances of the endpoints, overriding the annotations of the service. This requires "+
+			"watching the pods of the namespaces that services are synced from.")
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -130,6 +209,15 @@ func (c *Command) init() {
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagDenyK8sNamespacesList), "deny-k8s-namespace",
 		"K8s namespaces to explicitly deny. Takes precedence over allow. May be specified multiple times.")
+	c.flags.StringVar(&c.flagK8SServiceSelector, "k8s-service-selector", "",
+		"A Kubernetes label selector that K8S services must match to be synced to Consul, "+
+			"e.g. 'team=payments,tier!=internal'. If this is not set then services are not filtered by labels.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagIncludeK8SAnnotations), "include-k8s-service-annotation",
+		"An annotation in the form '<key>' or '<key>=<value>'. If set, only K8S services that match at least "+
+			"one of these annotations are synced to Consul. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagExcludeK8SAnnotations), "exclude-k8s-service-annotation",
+		"An annotation in the form '<key>' or '<key>=<value>'. K8S services that match any of these annotations "+
+			"are not synced to Consul. Takes precedence over include. May be specified multiple times.")
 	c.flags.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
 		"[Enterprise Only] Enables namespaces, in either a single Consul namespace or mirrored.")
 	c.flags.StringVar(&c.flagConsulDestinationNamespace, "consul-destination-namespace", "default",
@@ -142,6 +230,12 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagCrossNamespaceACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagNamespaceMappingRules), "k8s-namespace-mapping-rule",
+		"[Enterprise Only] A rule in the form 'prefix:<prefix>=<partition>/<namespace>' or "+
+			"'regex:<regex>=<partition>/<namespace>' that maps the K8S namespaces it matches to a Consul admin "+
+			"partition and namespace. Either the partition or the namespace may be empty to use the default. "+
+			"The namespace of a regex rule may reference submatches, e.g. 'regex:^team-(.*)$=/${1}'. "+
+			"The first matching rule takes precedence over the other namespace flags. May be specified multiple times.")
 
 	c.http = &flags.HTTPFlags{}
 	c.k8s = &flags.K8SFlags{}
@@ -174,6 +268,7 @@ func (c *Command) Run(args []string) int {
 		c.UI.Error(err.Error())
 		return 1
 	}
+	consulNodeName, consulExternalNodeName := c.consulNodeNames()
 
 	// Create the k8s clientset
 	if c.clientset == nil {
@@ -203,7 +298,7 @@ func (c *Command) Run(args []string) int {
 	// Set up logging
 	if c.logger == nil {
 		var err error
-		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		c.logger, err = common.Logger("sync-catalog", c.flagLogLevel, c.flagLogJSON)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 1
@@ -220,12 +315,34 @@ func (c *Command) Run(args []string) int {
 	}
 	c.logger.Info("K8s namespace syncing configuration", "k8s namespaces allowed to be synced", allowSet,
 		"k8s namespaces denied from syncing", denySet)
+	if c.serviceSelector != nil || len(c.includeAnnotations) > 0 || len(c.excludeAnnotations) > 0 {
+		c.logger.Info("K8s service filtering configuration", "k8s service selector", c.flagK8SServiceSelector,
+			"k8s service annotations included", c.flagIncludeK8SAnnotations,
+			"k8s service annotations excluded", c.flagExcludeK8SAnnotations)
+	}
+
+	// Register the metrics of both sync directions, which are served by the
+	// listener with the health check.
+	syncMetrics := metrics.New()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	if err := syncMetrics.Register(registry); err != nil {
+		c.UI.Error(fmt.Sprintf("Error registering metrics: %s", err))
+		return 1
+	}
 
 	// Create the context we'll use to cancel everything
 	ctx, cancelF := context.WithCancel(context.Background())
 
+	// informersSynced are whether the informers of the sync directions have
+	// synced, reported by the health checks.
+	var informersSynced []toolscache.InformerSynced
+
 	// Start the K8S-to-Consul syncer
 	var toConsulCh chan struct{}
+	var serviceResource *catalogtoconsul.ServiceResource
 	if c.flagToConsul {
 		// If namespaces are enabled we need to use a new Consul API endpoint
 		// to list node services. This endpoint is only available in Consul
@@ -249,37 +366,65 @@ func (c *Command) Run(args []string) int {
 			CrossNamespaceACLPolicy:  c.flagCrossNamespaceACLPolicy,
 			SyncPeriod:               c.flagConsulWritePeriod,
 			ServicePollPeriod:        c.flagConsulWritePeriod * 2,
+			WriteBatchSize:           c.flagConsulWriteBatchSize,
+			WriteRateLimit:           rate.Limit(c.flagConsulWriteRateLimit),
 			ConsulK8STag:             c.flagConsulK8STag,
-			ConsulNodeName:           c.flagConsulNodeName,
+			ConsulNodeName:           consulNodeName,
+			ClusterName:              c.flagClusterName,
+			AdoptUnownedServices:     c.flagAdoptUnownedServices,
+			ConsulPartitions:         c.consulPartitions(),
 			ConsulNodeServicesClient: svcsClient,
+			Metrics:                  syncMetrics,
+		}
+		if c.flagSyncExternalNameServices {
+			syncer.ConsulExternalNodeName = consulExternalNodeName
+		}
+		if c.flagAdoptUnownedServices {
+			syncer.LegacyConsulNodeNames = c.legacyConsulNodeNames()
 		}
 		go syncer.Run(ctx)
 
 		// Build the controller and start it
+		serviceResource = &catalogtoconsul.ServiceResource{
+			Log:                        c.logger.Named("to-consul/source"),
+			Client:                     c.clientset,
+			Syncer:                     syncer,
+			Ctx:                        ctx,
+			AllowK8sNamespacesSet:      allowSet,
+			DenyK8sNamespacesSet:       denySet,
+			ServiceSelector:            c.serviceSelector,
+			IncludeAnnotations:         c.includeAnnotations,
+			ExcludeAnnotations:         c.excludeAnnotations,
+			ExplicitEnable:             !c.flagK8SDefault,
+			ClusterIPSync:              c.flagSyncClusterIPServices,
+			LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
+			NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
+			ConsulK8STag:               c.flagConsulK8STag,
+			ConsulServicePrefix:        c.flagConsulServicePrefix,
+			AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
+			EnableNamespaces:           c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
+			ConsulPartition:            c.http.Partition(),
+			NamespaceMappingRules:      c.namespaceMappingRules,
+			ConsulNodeName:             consulNodeName,
+			ClusterName:                c.flagClusterName,
+			SyncExternalNameServices:   c.flagSyncExternalNameServices,
+			ConsulExternalNodeName:     consulExternalNodeName,
+			ConflictPolicy:             c.conflictPolicy,
+			SyncPodAnnotations:         c.flagSyncPodAnnotations,
+
+			SyncHealthChecks:         c.flagSyncHealthChecks,
+			HealthCheckName:          c.flagHealthCheckName,
+			HealthCheckCriticalAfter: c.flagHealthCheckCriticalAfter,
+		}
 		ctl := &controller.Controller{
-			Log: c.logger.Named("to-consul/controller"),
-			Resource: &catalogtoconsul.ServiceResource{
-				Log:                        c.logger.Named("to-consul/source"),
-				Client:                     c.clientset,
-				Syncer:                     syncer,
-				Ctx:                        ctx,
-				AllowK8sNamespacesSet:      allowSet,
-				DenyK8sNamespacesSet:       denySet,
-				ExplicitEnable:             !c.flagK8SDefault,
-				ClusterIPSync:              c.flagSyncClusterIPServices,
-				LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
-				NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
-				ConsulK8STag:               c.flagConsulK8STag,
-				ConsulServicePrefix:        c.flagConsulServicePrefix,
-				AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
-				EnableNamespaces:           c.flagEnableNamespaces,
-				ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-				EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
-				K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
-				ConsulNodeName:             c.flagConsulNodeName,
-			},
+			Log:      c.logger.Named("to-consul/controller"),
+			Resource: serviceResource,
 		}
 
+		informersSynced = append(informersSynced, ctl.HasSynced)
 		toConsulCh = make(chan struct{})
 		go func() {
 			defer close(toConsulCh)
@@ -295,6 +440,7 @@ func (c *Command) Run(args []string) int {
 			Namespace: c.flagK8SWriteNamespace,
 			Log:       c.logger.Named("to-k8s/sink"),
 			Ctx:       ctx,
+			Metrics:   syncMetrics,
 		}
 
 		source := &catalogtok8s.Source{
@@ -305,6 +451,11 @@ func (c *Command) Run(args []string) int {
 			Log:          c.logger.Named("to-k8s/source"),
 			ConsulK8STag: c.flagConsulK8STag,
 		}
+		if serviceResource != nil {
+			// Don't sync Consul services back to K8S if they conflict with
+			// K8S services that are synced to Consul.
+			source.ExcludeServices = serviceResource.ConsulServicesExcludedFromK8S
+		}
 		go source.Run(ctx)
 
 		// Build the controller and start it
@@ -313,6 +464,7 @@ func (c *Command) Run(args []string) int {
 			Resource: sink,
 		}
 
+		informersSynced = append(informersSynced, ctl.HasSynced)
 		toK8SCh = make(chan struct{})
 		go func() {
 			defer close(toK8SCh)
@@ -320,10 +472,22 @@ func (c *Command) Run(args []string) int {
 		}()
 	}
 
-	// Start healthcheck handler
+	// Start healthcheck handler. /health/ready is kept for the probes of
+	// older charts.
+	liveness := map[string]healthz.Checker{
+		"ping": healthz.Ping,
+	}
+	readiness := map[string]healthz.Checker{
+		"informers": health.InformersSynced(informersSynced...),
+	}
+	consulConfig := api.DefaultConfig()
+	c.http.MergeOntoConfig(consulConfig)
+	health.AddConsulChecks(readiness, c.consulClient, consulConfig)
 	go func() {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/health/ready", c.handleReady)
+		health.Register(mux, liveness, readiness)
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 		var handler http.Handler = mux
 
 		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
@@ -391,26 +555,171 @@ func (c *Command) sendSignal(sig os.Signal) {
 	c.sigCh <- sig
 }
 
+// consulNodeNames returns the Consul node names to register services and
+// ExternalName services with. If -cluster-name is set, it is appended to the
+// node names that aren't set explicitly so that the services of each cluster
+// are registered on nodes of their own.
+func (c *Command) consulNodeNames() (string, string) {
+	nodeName, externalNodeName := c.flagConsulNodeName, c.flagConsulExternalNodeName
+	if c.flagClusterName == "" {
+		return nodeName, externalNodeName
+	}
+	explicit := make(map[string]bool)
+	c.flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if !explicit["consul-node-name"] {
+		nodeName = fmt.Sprintf("%s-%s", nodeName, c.flagClusterName)
+	}
+	if !explicit["consul-external-node-name"] {
+		externalNodeName = fmt.Sprintf("%s-%s", externalNodeName, c.flagClusterName)
+	}
+	return nodeName, externalNodeName
+}
+
+// legacyConsulNodeNames returns the Consul node names that services were
+// registered with before -cluster-name was set, if they differ from the
+// node names returned by consulNodeNames.
+func (c *Command) legacyConsulNodeNames() []string {
+	nodeName, externalNodeName := c.consulNodeNames()
+	var legacy []string
+	if nodeName != c.flagConsulNodeName {
+		legacy = append(legacy, c.flagConsulNodeName)
+	}
+	if c.flagSyncExternalNameServices && externalNodeName != c.flagConsulExternalNodeName {
+		legacy = append(legacy, c.flagConsulExternalNodeName)
+	}
+	return legacy
+}
+
 func (c *Command) validateFlags() error {
+	if c.flagClusterName != "" {
+		if err := validateNodeName("cluster-name", c.flagClusterName); err != nil {
+			return err
+		}
+	} else if c.flagAdoptUnownedServices {
+		return fmt.Errorf("-adopt-unowned-services requires -cluster-name to be set")
+	}
+
+	consulNodeName, consulExternalNodeName := c.consulNodeNames()
+	if err := validateNodeName("consul-node-name", consulNodeName); err != nil {
+		return err
+	}
+	if c.flagSyncExternalNameServices {
+		if err := validateNodeName("consul-external-node-name", consulExternalNodeName); err != nil {
+			return err
+		}
+		if consulExternalNodeName == consulNodeName {
+			return fmt.Errorf("-consul-external-node-name=%s is invalid: it must be different from -consul-node-name",
+				consulExternalNodeName)
+		}
+	}
+
+	if c.flagConsulWriteBatchSize < 1 {
+		return fmt.Errorf("-consul-write-batch-size=%d is invalid: it must be at least 1", c.flagConsulWriteBatchSize)
+	}
+	if c.flagConsulWriteRateLimit < 0 {
+		return fmt.Errorf("-consul-write-rate-limit=%v is invalid: it must not be negative", c.flagConsulWriteRateLimit)
+	}
+
+	if c.flagSyncHealthChecks {
+		if c.flagHealthCheckName == "" {
+			return fmt.Errorf("-health-check-name must be set when -sync-health-checks is true")
+		}
+		if c.flagHealthCheckCriticalAfter < 0 {
+			return fmt.Errorf("-health-check-critical-after=%s is invalid: it must not be negative",
+				c.flagHealthCheckCriticalAfter)
+		}
+	}
+
+	conflictPolicy, err := catalogtoconsul.ParseConflictPolicy(c.flagConflictPolicy)
+	if err != nil {
+		return fmt.Errorf("-conflict-policy is invalid: %s", err)
+	}
+	c.conflictPolicy = conflictPolicy
+
+	if c.flagK8SServiceSelector != "" {
+		selector, err := labels.Parse(c.flagK8SServiceSelector)
+		if err != nil {
+			return fmt.Errorf("-k8s-service-selector=%s is invalid: %s", c.flagK8SServiceSelector, err)
+		}
+		c.serviceSelector = selector
+	}
+	for _, raw := range c.flagIncludeK8SAnnotations {
+		filter, err := catalogtoconsul.ParseAnnotationFilter(raw)
+		if err != nil {
+			return fmt.Errorf("-include-k8s-service-annotation is invalid: %s", err)
+		}
+		c.includeAnnotations = append(c.includeAnnotations, filter)
+	}
+	for _, raw := range c.flagExcludeK8SAnnotations {
+		filter, err := catalogtoconsul.ParseAnnotationFilter(raw)
+		if err != nil {
+			return fmt.Errorf("-exclude-k8s-service-annotation is invalid: %s", err)
+		}
+		c.excludeAnnotations = append(c.excludeAnnotations, filter)
+	}
+
+	rules, err := catalogtoconsul.ParseNamespaceMappingRules(c.flagNamespaceMappingRules)
+	if err != nil {
+		return fmt.Errorf("-k8s-namespace-mapping-rule is invalid: %s", err)
+	}
+	for _, rule := range rules {
+		if rule.Namespace != "" && !c.flagEnableNamespaces {
+			return fmt.Errorf("-k8s-namespace-mapping-rule is invalid: namespaces must be enabled with " +
+				"-enable-namespaces to map to a namespace")
+		}
+		if rule.Partition != "" && c.http.Partition() == "" {
+			return fmt.Errorf("-k8s-namespace-mapping-rule is invalid: -partition must be set to map to a partition")
+		}
+	}
+	c.namespaceMappingRules = rules
+
+	return nil
+}
+
+// consulPartitions returns the Consul admin partitions that services can be
+// registered into: the partition set by -partition and the partitions of the
+// namespace mapping rules.
+func (c *Command) consulPartitions() []string {
+	partitions := []string{c.http.Partition()}
+	for _, rule := range c.namespaceMappingRules {
+		if rule.Partition == "" {
+			continue
+		}
+		var seen bool
+		for _, p := range partitions {
+			if p == rule.Partition {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			partitions = append(partitions, rule.Partition)
+		}
+	}
+	return partitions
+}
+
+// validateNodeName returns an error if the Consul node name set by the flag
+// flagName would not be discoverable via DNS.
+func validateNodeName(flagName, nodeName string) error {
 	// For the Consul node name to be discoverable via DNS, it must contain only
 	// dashes and alphanumeric characters. Length is also constrained.
 	// These restrictions match those defined in Consul's agent definition.
 	var invalidDnsRe = regexp.MustCompile(`[^A-Za-z0-9\\-]+`)
 	const maxDNSLabelLength = 63
 
-	if invalidDnsRe.MatchString(c.flagConsulNodeName) {
-		return fmt.Errorf("-consul-node-name=%s is invalid: node name will not be discoverable "+
+	if invalidDnsRe.MatchString(nodeName) {
+		return fmt.Errorf("-%s=%s is invalid: node name will not be discoverable "+
 			"via DNS due to invalid characters. Valid characters include all alpha-numerics and dashes",
-			c.flagConsulNodeName,
+			flagName, nodeName,
 		)
 	}
-	if len(c.flagConsulNodeName) > maxDNSLabelLength {
-		return fmt.Errorf("-consul-node-name=%s is invalid: node name will not be discoverable "+
+	if len(nodeName) > maxDNSLabelLength {
+		return fmt.Errorf("-%s=%s is invalid: node name will not be discoverable "+
 			"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
-			c.flagConsulNodeName,
+			flagName, nodeName,
 		)
 	}
-
 	return nil
 }
 
@@ -36,6 +36,72 @@ func TestRun_FlagValidation(t *testing.T) {
 			ExpErr: "-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TMPV-too-long is invalid: node name will not be discoverable " +
 				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
 		},
+		{
+			Flags: []string{"-sync-external-name-services", "-consul-external-node-name=Speci@l_Chars"},
+			ExpErr: "-consul-external-node-name=Speci@l_Chars is invalid: node name will not be discoverable " +
+				"via DNS due to invalid characters. Valid characters include all alpha-numerics and dashes",
+		},
+		{
+			Flags:  []string{"-sync-external-name-services", "-consul-external-node-name=k8s-sync"},
+			ExpErr: "-consul-external-node-name=k8s-sync is invalid: it must be different from -consul-node-name",
+		},
+		{
+			Flags:  []string{"-conflict-policy=k8s-loses"},
+			ExpErr: `-conflict-policy is invalid: invalid conflict policy "k8s-loses", must be one of k8s-wins, consul-wins, merge, error`,
+		},
+		{
+			Flags: []string{"-cluster-name=cluster_a"},
+			ExpErr: "-cluster-name=cluster_a is invalid: node name will not be discoverable " +
+				"via DNS due to invalid characters. Valid characters include all alpha-numerics and dashes",
+		},
+		{
+			Flags:  []string{"-consul-write-batch-size=0"},
+			ExpErr: "-consul-write-batch-size=0 is invalid: it must be at least 1",
+		},
+		{
+			Flags:  []string{"-consul-write-rate-limit=-1"},
+			ExpErr: "-consul-write-rate-limit=-1 is invalid: it must not be negative",
+		},
+		{
+			Flags:  []string{"-adopt-unowned-services"},
+			ExpErr: "-adopt-unowned-services requires -cluster-name to be set",
+		},
+		{
+			Flags:  []string{"-sync-health-checks", "-health-check-name="},
+			ExpErr: "-health-check-name must be set when -sync-health-checks is true",
+		},
+		{
+			Flags:  []string{"-sync-health-checks", "-health-check-critical-after=-1s"},
+			ExpErr: "-health-check-critical-after=-1s is invalid: it must not be negative",
+		},
+		{
+			Flags:  []string{"-k8s-service-selector=team in (payments"},
+			ExpErr: "-k8s-service-selector=team in (payments is invalid: ",
+		},
+		{
+			Flags:  []string{"-include-k8s-service-annotation==payments"},
+			ExpErr: `-include-k8s-service-annotation is invalid: annotation filter "=payments" is missing an annotation key`,
+		},
+		{
+			Flags:  []string{"-exclude-k8s-service-annotation=example.com/team="},
+			ExpErr: `-exclude-k8s-service-annotation is invalid: annotation filter "example.com/team=" is missing a value after '='`,
+		},
+		{
+			Flags:  []string{"-k8s-namespace-mapping-rule=glob:team-*=team/ns"},
+			ExpErr: `-k8s-namespace-mapping-rule is invalid: rule "glob:team-*=team/ns" must match with "prefix:" or "regex:"`,
+		},
+		{
+			Flags:  []string{"-enable-namespaces", "-partition=default", "-k8s-namespace-mapping-rule=regex:team-(=team/ns"},
+			ExpErr: `-k8s-namespace-mapping-rule is invalid: rule "regex:team-(=team/ns" has an invalid regex: `,
+		},
+		{
+			Flags:  []string{"-k8s-namespace-mapping-rule=prefix:team-=/ns"},
+			ExpErr: "-k8s-namespace-mapping-rule is invalid: namespaces must be enabled with -enable-namespaces to map to a namespace",
+		},
+		{
+			Flags:  []string{"-enable-namespaces", "-k8s-namespace-mapping-rule=prefix:team-=team/ns"},
+			ExpErr: "-k8s-namespace-mapping-rule is invalid: -partition must be set to map to a partition",
+		},
 	}
 
 	for _, c := range cases {
@@ -51,6 +117,63 @@ func TestRun_FlagValidation(t *testing.T) {
 	}
 }
 
+// Test that the cluster name is appended to the Consul node names unless
+// they are set explicitly.
+func TestConsulNodeNames(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		Flags               []string
+		ExpNodeName         string
+		ExpExternalNodeName string
+		ExpLegacyNodeNames  []string
+	}{
+		"no cluster name": {
+			Flags:               nil,
+			ExpNodeName:         "k8s-sync",
+			ExpExternalNodeName: "k8s-sync-external",
+		},
+		"cluster name": {
+			Flags:               []string{"-cluster-name=cluster-a"},
+			ExpNodeName:         "k8s-sync-cluster-a",
+			ExpExternalNodeName: "k8s-sync-external-cluster-a",
+			ExpLegacyNodeNames:  []string{"k8s-sync"},
+		},
+		"cluster name with ExternalName services": {
+			Flags:               []string{"-cluster-name=cluster-a", "-sync-external-name-services"},
+			ExpNodeName:         "k8s-sync-cluster-a",
+			ExpExternalNodeName: "k8s-sync-external-cluster-a",
+			ExpLegacyNodeNames:  []string{"k8s-sync", "k8s-sync-external"},
+		},
+		"cluster name and explicit node names": {
+			Flags:               []string{"-cluster-name=cluster-a", "-consul-node-name=sync-a", "-consul-external-node-name=external-a"},
+			ExpNodeName:         "sync-a",
+			ExpExternalNodeName: "external-a",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := Command{
+				UI: cli.NewMockUi(),
+			}
+			cmd.init()
+			require.NoError(t, cmd.flags.Parse(c.Flags))
+			require.NoError(t, cmd.validateFlags())
+			nodeName, externalNodeName := cmd.consulNodeNames()
+			require.Equal(t, c.ExpNodeName, nodeName)
+			require.Equal(t, c.ExpExternalNodeName, externalNodeName)
+			require.Equal(t, c.ExpLegacyNodeNames, cmd.legacyConsulNodeNames())
+
+			// Validating the flags must not change them.
+			require.NoError(t, cmd.validateFlags())
+			nodeName, externalNodeName = cmd.consulNodeNames()
+			require.Equal(t, c.ExpNodeName, nodeName)
+			require.Equal(t, c.ExpExternalNodeName, externalNodeName)
+		})
+	}
+}
+
 // Test that the default consul service is synced to k8s.
 func TestRun_Defaults_SyncsConsulServiceToK8s(t *testing.T) {
 	t.Parallel()
@@ -636,3 +759,47 @@ func lbService(name, lbIP string) *apiv1.Service {
 		},
 	}
 }
+
+// Test that the syncer watches the partition set by -partition and the
+// partitions of the namespace mapping rules.
+func TestConsulPartitions(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		Flags         []string
+		ExpPartitions []string
+	}{
+		"no partition": {
+			Flags:         nil,
+			ExpPartitions: []string{""},
+		},
+		"partition": {
+			Flags:         []string{"-partition=default"},
+			ExpPartitions: []string{"default"},
+		},
+		"partition and rules": {
+			Flags: []string{
+				"-partition=default",
+				"-enable-namespaces",
+				"-k8s-namespace-mapping-rule=prefix:team-a-=team-a",
+				"-k8s-namespace-mapping-rule=prefix:shared-=/shared",
+				"-k8s-namespace-mapping-rule=prefix:team-a2-=team-a/a2",
+				"-k8s-namespace-mapping-rule=prefix:infra-=default/infra",
+				"-k8s-namespace-mapping-rule=prefix:team-b-=team-b",
+			},
+			ExpPartitions: []string{"default", "team-a", "team-b"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cmd := Command{
+				UI: cli.NewMockUi(),
+			}
+			cmd.init()
+			require.NoError(t, cmd.flags.Parse(c.Flags))
+			require.NoError(t, cmd.validateFlags())
+			require.Equal(t, c.ExpPartitions, cmd.consulPartitions())
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/health"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
@@ -28,11 +30,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
 const (
 	defaultCertExpiry    = 24 * time.Hour
 	defaultRetryDuration = 1 * time.Second
+
+	// defaultSecretPollInterval is how often secrets that are maintained
+	// outside of webhook-cert-manager are checked for changes.
+	defaultSecretPollInterval = 10 * time.Second
 )
 
 type Command struct {
@@ -44,6 +51,7 @@ type Command struct {
 	flagConfigFile string
 	flagLogLevel   string
 	flagLogJSON    bool
+	flagListen     string
 
 	flagDeploymentName      string
 	flagDeploymentNamespace string
@@ -55,8 +63,11 @@ type Command struct {
 	sigCh  chan os.Signal
 	logger hclog.Logger
 
-	certExpiry *time.Duration // override default cert expiry of 24 hours if set (only set in tests)
-	source     cert.Source    // override default cert source of cert.GenSource if set (only in tests)
+	certExpiry         *time.Duration // override default cert expiry of 24 hours if set (only set in tests)
+	source             cert.Source    // override default cert source of cert.GenSource if set (only in tests)
+	secretPollInterval time.Duration  // override default secret poll interval of 10 seconds if set (only in tests)
+
+	status *reconcileStatus
 }
 
 func (c *Command) init() {
@@ -72,6 +83,8 @@ func (c *Command) init() {
 			"\"debug\", \"info\", \"warn\", and \"error\".")
 	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
+	c.flagSet.StringVar(&c.flagListen, "listen", ":8080",
+		"Address of the listener that serves the /healthz and /readyz health checks.")
 
 	c.k8s = &flags.K8SFlags{}
 	flags.Merge(c.flagSet, c.k8s.Flags())
@@ -128,7 +141,7 @@ func (c *Command) Run(args []string) int {
 
 	if c.logger == nil {
 		var err error
-		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		c.logger, err = common.Logger("webhook-cert-manager", c.flagLogLevel, c.flagLogJSON)
 		if err != nil {
 			c.UI.Error(err.Error())
 			return 1
@@ -166,11 +179,39 @@ func (c *Command) Run(args []string) int {
 	} else {
 		expiry = defaultCertExpiry
 	}
+	pollInterval := defaultSecretPollInterval
+	if c.secretPollInterval != 0 {
+		pollInterval = c.secretPollInterval
+	}
+	var webhooks []string
+	for _, config := range configs {
+		webhooks = append(webhooks, config.Name)
+	}
+	c.status = newReconcileStatus(webhooks)
+
 	var certSource cert.Source
 	for _, config := range configs {
-		if c.source != nil {
+		switch {
+		case c.source != nil:
 			certSource = c.source
-		} else {
+		case config.External:
+			certSource = &externalSecretSource{
+				clientset:       c.clientset,
+				secretName:      config.SecretName,
+				secretNamespace: config.SecretNamespace,
+				pollInterval:    pollInterval,
+			}
+		case config.CASecretName != "":
+			certSource = &caSecretSource{
+				clientset:       c.clientset,
+				secretName:      config.CASecretName,
+				secretNamespace: config.SecretNamespace,
+				pollInterval:    pollInterval,
+				name:            "Consul Webhook Certificates",
+				hosts:           config.TLSAutoHosts,
+				expiry:          expiry,
+			}
+		default:
 			certSource = &cert.GenSource{
 				Name:   "Consul Webhook Certificates",
 				Hosts:  config.TLSAutoHosts,
@@ -182,9 +223,26 @@ func (c *Command) Run(args []string) int {
 		certNotify := &cert.Notify{Source: certSource, Ch: certCh, WebhookConfigName: config.Name, SecretName: config.SecretName, SecretNamespace: config.SecretNamespace}
 		notifiers = append(notifiers, certNotify)
 		go certNotify.Start(ctx)
-		go c.certWatcher(ctx, certCh, c.clientset, c.logger)
+		go c.certWatcher(ctx, config.Name, certCh, c.clientset, config.External, c.logger)
 	}
 
+	// Serve the health checks of the reconciles of the certificates.
+	go func() {
+		mux := http.NewServeMux()
+		health.Register(mux,
+			map[string]healthz.Checker{
+				"ping":         healthz.Ping,
+				"certificates": c.status.liveness(defaultReconcileTimeout),
+			},
+			map[string]healthz.Checker{
+				"certificates": c.status.readiness,
+			})
+		c.logger.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
+		if err := http.ListenAndServe(c.flagListen, mux); err != nil {
+			c.logger.Error("Error listening", "err", err)
+		}
+	}()
+
 	// We define a signal handler for OS interrupts, and when an SIGINT or SIGTERM is received,
 	// we gracefully shut down, by first stopping our cert notifiers and then cancelling
 	// all the contexts that have been created by the process.
@@ -199,7 +257,9 @@ func (c *Command) Run(args []string) int {
 
 // certWatcher listens for a new MetaBundle on the ch channel for all webhooks and updates
 // MutatingWebhooksConfigs and Secrets when a new Bundle is available on the channel.
-func (c *Command) certWatcher(ctx context.Context, ch <-chan cert.MetaBundle, clientset kubernetes.Interface, log hclog.Logger) {
+// If external is true, the secret is maintained elsewhere and only the MutatingWebhooksConfigs are updated.
+// The outcome of each reconcile is recorded for the health checks of webhook.
+func (c *Command) certWatcher(ctx context.Context, webhook string, ch <-chan cert.MetaBundle, clientset kubernetes.Interface, external bool, log hclog.Logger) {
 	var bundle cert.MetaBundle
 	for {
 		select {
@@ -219,9 +279,15 @@ func (c *Command) certWatcher(ctx context.Context, ch <-chan cert.MetaBundle, cl
 			return
 		}
 
-		if err := c.reconcileCertificates(ctx, clientset, bundle, log); err != nil {
+		reconcile := c.reconcileCertificates
+		if external {
+			reconcile = c.reconcileWebhookConfig
+		}
+		err := reconcile(ctx, clientset, bundle, log)
+		if err != nil {
 			log.Error("failed to reconcile certificates", "err", err)
 		}
+		c.status.record(webhook, err)
 	}
 }
 
@@ -314,6 +380,22 @@ func (c *Command) reconcileCertificates(ctx context.Context, clientset kubernete
 	return nil
 }
 
+// reconcileWebhookConfig ensures the caBundles on the MutatingWebhookConfiguration have the latest CA certificate
+// from the MetaBundle without touching the secret. It is used when the secret is maintained outside of webhook-cert-manager.
+func (c *Command) reconcileWebhookConfig(ctx context.Context, clientset kubernetes.Interface, bundle cert.MetaBundle, log hclog.Logger) error {
+	if c.webhookUpdated(ctx, bundle, clientset) {
+		return nil
+	}
+
+	iterLog := log.With("mutatingwebhookconfig", bundle.WebhookConfigName, "secret", bundle.SecretName, "secretNS", bundle.SecretNamespace)
+	iterLog.Info("Updating webhook configuration with CA from the secret")
+	if err := c.updateWebhookConfig(ctx, bundle, clientset); err != nil {
+		iterLog.Error("Error updating webhook configuration", "err", err)
+		return err
+	}
+	return nil
+}
+
 // updateWebhookConfig iterates over every webhook on the specified webhook configuration and updates
 // their caBundle with the CA from the MetaBundle.
 func (c *Command) updateWebhookConfig(ctx context.Context, metaBundle cert.MetaBundle, clientset kubernetes.Interface) error {
@@ -365,6 +447,16 @@ type webhookConfig struct {
 	TLSAutoHosts    []string `json:"tlsAutoHosts,omitempty"`
 	SecretName      string   `json:"secretName,omitempty"`
 	SecretNamespace string   `json:"secretNamespace,omitempty"`
+
+	// CASecretName is the name of a Kubernetes TLS secret in SecretNamespace
+	// with the CA to issue leaf certificates from. If empty, a self-signed CA
+	// is generated.
+	CASecretName string `json:"caSecretName,omitempty"`
+
+	// External is true if the secret is maintained by another system. The
+	// secret must then have a ca.crt, which is kept in sync with the caBundles
+	// of the MutatingWebhookConfiguration.
+	External bool `json:"external,omitempty"`
 }
 
 func (c webhookConfig) validate(ctx context.Context, client kubernetes.Interface) error {
@@ -382,6 +474,9 @@ func (c webhookConfig) validate(ctx context.Context, client kubernetes.Interface
 	if c.SecretNamespace == "" {
 		err = multierror.Append(err, errors.New(`config.SecretNameSpace cannot be ""`))
 	}
+	if c.External && c.CASecretName != "" {
+		err = multierror.Append(err, errors.New(`config.CASecretName cannot be set if config.External is true`))
+	}
 
 	if err != nil {
 		err.ErrorFormat = func(errs []error) string {
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/webhook-cert-manager/mocks"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
@@ -667,6 +668,157 @@ func TestCertWatcher(t *testing.T) {
 	})
 }
 
+// Test that leaf certificates are issued from the CA in the CA secret, and
+// that a new leaf certificate is issued when that CA is rotated.
+func TestRun_CASecret(t *testing.T) {
+	t.Parallel()
+	deploymentName := "deployment"
+	deploymentNamespace := "deploy-ns"
+	webhookName := "webhookOne"
+
+	webhook := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: webhookName,
+		},
+		Webhooks: []admissionv1.MutatingWebhook{
+			{
+				Name: "webhook-under-test",
+			},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName,
+			Namespace: deploymentNamespace,
+		},
+	}
+	_, caKey, caCert, _, err := cert.GenerateCA("CA One")
+	require.NoError(t, err)
+
+	k8s := fake.NewSimpleClientset(webhook, deployment, testTLSSecret("ca-secret", caCert, caKey))
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:                 ui,
+		clientset:          k8s,
+		secretPollInterval: 100 * time.Millisecond,
+	}
+	cmd.init()
+
+	file, err := ioutil.TempFile("", "config.json")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte(configFileCASecret))
+	require.NoError(t, err)
+
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-config-file", file.Name(),
+		"-deployment-name", deploymentName,
+		"-deployment-namespace", deploymentNamespace,
+	})
+	defer stopCommand(t, &cmd, exitCh)
+
+	ctx := context.Background()
+	requireIssuedBy := func(caCert string) {
+		timer := &retry.Timer{Timeout: 5 * time.Second, Wait: 100 * time.Millisecond}
+		retry.RunWith(timer, t, func(r *retry.R) {
+			webhookConfig, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, webhookName, metav1.GetOptions{})
+			require.NoError(r, err)
+			require.Equal(r, caCert, string(webhookConfig.Webhooks[0].ClientConfig.CABundle))
+
+			secret, err := k8s.CoreV1().Secrets("default").Get(ctx, "secret-deploy-1", metav1.GetOptions{})
+			require.NoError(r, err)
+			leaf, err := cert.ParseCert(secret.Data[v1.TLSCertKey])
+			require.NoError(r, err)
+			ca, err := cert.ParseCert([]byte(caCert))
+			require.NoError(r, err)
+			require.NoError(r, leaf.CheckSignatureFrom(ca))
+		})
+	}
+	requireIssuedBy(caCert)
+
+	// Rotate the CA.
+	_, caKey, caCert, _, err = cert.GenerateCA("CA Two")
+	require.NoError(t, err)
+	_, err = k8s.CoreV1().Secrets("default").Update(ctx, testTLSSecret("ca-secret", caCert, caKey), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requireIssuedBy(caCert)
+}
+
+// Test that in external mode the secret is left alone and the caBundle follows
+// the ca.crt of the secret.
+func TestRun_ExternalSecret(t *testing.T) {
+	t.Parallel()
+	deploymentName := "deployment"
+	deploymentNamespace := "deploy-ns"
+	webhookName := "webhookOne"
+
+	webhook := &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: webhookName,
+		},
+		Webhooks: []admissionv1.MutatingWebhook{
+			{
+				Name: "webhook-under-test",
+			},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName,
+			Namespace: deploymentNamespace,
+		},
+	}
+	secret := testTLSSecret("secret-deploy-1", "cert-one", "key-one")
+	secret.Data[caCertKey] = []byte("ca-one")
+
+	k8s := fake.NewSimpleClientset(webhook, deployment, secret)
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:                 ui,
+		clientset:          k8s,
+		secretPollInterval: 100 * time.Millisecond,
+	}
+	cmd.init()
+
+	file, err := ioutil.TempFile("", "config.json")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte(configFileExternal))
+	require.NoError(t, err)
+
+	exitCh := runCommandAsynchronously(&cmd, []string{
+		"-config-file", file.Name(),
+		"-deployment-name", deploymentName,
+		"-deployment-namespace", deploymentNamespace,
+	})
+	defer stopCommand(t, &cmd, exitCh)
+
+	ctx := context.Background()
+	requireCABundle := func(caCert string) {
+		timer := &retry.Timer{Timeout: 5 * time.Second, Wait: 100 * time.Millisecond}
+		retry.RunWith(timer, t, func(r *retry.R) {
+			webhookConfig, err := k8s.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, webhookName, metav1.GetOptions{})
+			require.NoError(r, err)
+			require.Equal(r, caCert, string(webhookConfig.Webhooks[0].ClientConfig.CABundle))
+		})
+	}
+	requireCABundle("ca-one")
+
+	secret.Data[caCertKey] = []byte("ca-two")
+	_, err = k8s.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	requireCABundle("ca-two")
+
+	// The secret itself is never written.
+	current, err := k8s.CoreV1().Secrets("default").Get(ctx, "secret-deploy-1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "cert-one", string(current.Data[v1.TLSCertKey]))
+	require.Empty(t, current.OwnerReferences)
+	require.Empty(t, current.Labels)
+}
+
 func TestValidate(t *testing.T) {
 	t.Parallel()
 	webhook := &admissionv1.MutatingWebhookConfiguration{
@@ -721,6 +873,18 @@ func TestValidate(t *testing.T) {
 			clientset: client,
 			expErr:    `config.SecretNameSpace cannot be ""`,
 		},
+		"externalWithCASecret": {
+			config: webhookConfig{
+				Name:            "webhook-config-name",
+				TLSAutoHosts:    []string{"host-1", "host-2"},
+				SecretName:      "secret-name",
+				SecretNamespace: "default",
+				CASecretName:    "ca-secret",
+				External:        true,
+			},
+			clientset: client,
+			expErr:    `config.CASecretName cannot be set if config.External is true`,
+		},
 		"multi-error": {
 			config: webhookConfig{
 				Name:            "",
@@ -767,6 +931,20 @@ func stopCommand(t *testing.T, cmd *Command, exitChan chan int) {
 	require.Equal(t, 0, c, string(cmd.UI.(*cli.MockUi).ErrorWriter.Bytes()))
 }
 
+func testTLSSecret(name, certPEM, keyPEM string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       []byte(certPEM),
+			v1.TLSPrivateKeyKey: []byte(keyPEM),
+		},
+	}
+}
+
 const configFile = `[
   {
     "name": "webhookOne",
@@ -802,3 +980,24 @@ const configFileUpdates = `[
     "secretNamespace": "default"
   }
 ]`
+
+const configFileCASecret = `[
+  {
+    "name": "webhookOne",
+    "tlsAutoHosts": [
+      "foo"
+    ],
+    "secretName": "secret-deploy-1",
+    "secretNamespace": "default",
+    "caSecretName": "ca-secret"
+  }
+]`
+
+const configFileExternal = `[
+  {
+    "name": "webhookOne",
+    "secretName": "secret-deploy-1",
+    "secretNamespace": "default",
+    "external": true
+  }
+]`
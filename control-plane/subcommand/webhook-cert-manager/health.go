@@ -0,0 +1,94 @@
+package webhookcertmanager
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// defaultReconcileTimeout is how long the certificates of a webhook may not
+// have been reconciled for before the liveness check fails. They're
+// reconciled every defaultRetryDuration, so a longer gap means that their
+// watcher is stuck.
+const defaultReconcileTimeout = time.Minute
+
+// reconcileStatus records the outcome of the reconciles of the certificates
+// of each webhook for the health checks.
+type reconcileStatus struct {
+	lock sync.Mutex
+	// webhooks are the names of the webhooks whose certificates are managed.
+	webhooks []string
+	// started is when the certificates started being reconciled.
+	started time.Time
+	// reconciled is when the certificates of each webhook were last
+	// reconciled.
+	reconciled map[string]time.Time
+	// errs is the error of the last reconcile of each webhook, which is nil
+	// if it succeeded.
+	errs map[string]error
+	// now is the current time, and is overridden in tests.
+	now func() time.Time
+}
+
+func newReconcileStatus(webhooks []string) *reconcileStatus {
+	return &reconcileStatus{
+		webhooks:   webhooks,
+		started:    time.Now(),
+		reconciled: make(map[string]time.Time),
+		errs:       make(map[string]error),
+		now:        time.Now,
+	}
+}
+
+// record records a reconcile of the certificates of webhook that failed with
+// err, or succeeded if err is nil.
+func (s *reconcileStatus) record(webhook string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reconciled[webhook] = s.now()
+	s.errs[webhook] = err
+}
+
+// liveness is the liveness check. It fails if the certificates of a webhook
+// haven't been reconciled within timeout.
+func (s *reconcileStatus) liveness(timeout time.Duration) healthz.Checker {
+	return func(_ *http.Request) error {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		var stuck []string
+		for _, webhook := range s.webhooks {
+			last, ok := s.reconciled[webhook]
+			if !ok {
+				last = s.started
+			}
+			if s.now().Sub(last) > timeout {
+				stuck = append(stuck, webhook)
+			}
+		}
+		if len(stuck) > 0 {
+			return fmt.Errorf("the certificates of webhooks %s haven't been reconciled for %s", strings.Join(stuck, ", "), timeout)
+		}
+		return nil
+	}
+}
+
+// readiness is the readiness check. It fails until the certificates of every
+// webhook have been reconciled, and while their last reconcile failed.
+func (s *reconcileStatus) readiness(_ *http.Request) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var failed []string
+	for _, webhook := range s.webhooks {
+		if err, ok := s.errs[webhook]; !ok || err != nil {
+			failed = append(failed, webhook)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("the certificates of webhooks %s aren't reconciled", strings.Join(failed, ", "))
+	}
+	return nil
+}
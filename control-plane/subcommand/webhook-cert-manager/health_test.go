@@ -0,0 +1,54 @@
+package webhookcertmanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileStatus_Liveness(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	status := newReconcileStatus([]string{"connect-injector", "controller"})
+	status.started = now
+	status.now = func() time.Time { return now }
+	live := status.liveness(time.Minute)
+
+	// The watchers have a minute to reconcile the certificates once started.
+	require.NoError(t, live(nil))
+	now = now.Add(2 * time.Minute)
+	require.Error(t, live(nil))
+
+	// Failed reconciles still show that the watchers aren't stuck.
+	status.record("connect-injector", errors.New("failure"))
+	status.record("controller", nil)
+	require.NoError(t, live(nil))
+
+	now = now.Add(30 * time.Second)
+	status.record("connect-injector", nil)
+	now = now.Add(45 * time.Second)
+	err := live(nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "webhooks controller haven't been reconciled")
+}
+
+func TestReconcileStatus_Readiness(t *testing.T) {
+	t.Parallel()
+	status := newReconcileStatus([]string{"connect-injector", "controller"})
+
+	// Certificates that haven't been reconciled aren't ready.
+	require.Error(t, status.readiness(nil))
+
+	status.record("connect-injector", nil)
+	err := status.readiness(nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "webhooks controller aren't reconciled")
+
+	status.record("controller", nil)
+	require.NoError(t, status.readiness(nil))
+
+	status.record("controller", errors.New("failure"))
+	require.Error(t, status.readiness(nil))
+}
@@ -0,0 +1,119 @@
+package webhookcertmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// caCertKey is the key of the CA certificate in secrets that are maintained
+// outside of webhook-cert-manager, e.g. by cert-manager.
+const caCertKey = "ca.crt"
+
+// caSecretSource issues leaf certificates from a CA that is provided in a
+// Kubernetes TLS secret. The secret is polled so that a new leaf certificate is
+// issued as soon as the CA is rotated.
+type caSecretSource struct {
+	clientset       kubernetes.Interface
+	secretName      string
+	secretNamespace string
+	pollInterval    time.Duration
+
+	name   string
+	hosts  []string
+	expiry time.Duration
+
+	caCert []byte
+	gen    *cert.GenSource
+}
+
+// Certificate implements cert.Source.
+func (s *caSecretSource) Certificate(ctx context.Context, last *cert.Bundle) (cert.Bundle, error) {
+	for {
+		caCert, caKey, err := s.readCA(ctx)
+		if err != nil {
+			return cert.Bundle{}, err
+		}
+
+		// Issue a new leaf certificate straight away for a new CA.
+		if s.gen == nil || !bytes.Equal(caCert, s.caCert) {
+			s.caCert = caCert
+			s.gen = &cert.GenSource{
+				Name:   s.name,
+				Hosts:  s.hosts,
+				Expiry: s.expiry,
+				CACert: string(caCert),
+				CAKey:  string(caKey),
+			}
+			return s.gen.Certificate(ctx, nil)
+		}
+
+		// Otherwise wait for the leaf certificate to near its expiry, checking
+		// the CA again every poll interval.
+		pollCtx, cancel := context.WithTimeout(ctx, s.pollInterval)
+		bundle, err := s.gen.Certificate(pollCtx, last)
+		cancel()
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+			return bundle, err
+		}
+		if ctx.Err() != nil {
+			return cert.Bundle{}, ctx.Err()
+		}
+	}
+}
+
+func (s *caSecretSource) readCA(ctx context.Context) ([]byte, []byte, error) {
+	secret, err := s.clientset.CoreV1().Secrets(s.secretNamespace).Get(ctx, s.secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting CA secret %q: %s", s.secretName, err)
+	}
+	caCert, caKey := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if len(caCert) == 0 || len(caKey) == 0 {
+		return nil, nil, fmt.Errorf("CA secret %q must have %s and %s", s.secretName, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	return caCert, caKey, nil
+}
+
+// externalSecretSource reads the certificate of a webhook from a Kubernetes
+// secret that is maintained by another system. It never issues certificates
+// and returns a new bundle whenever the secret changes.
+type externalSecretSource struct {
+	clientset       kubernetes.Interface
+	secretName      string
+	secretNamespace string
+	pollInterval    time.Duration
+}
+
+// Certificate implements cert.Source.
+func (s *externalSecretSource) Certificate(ctx context.Context, last *cert.Bundle) (cert.Bundle, error) {
+	for {
+		secret, err := s.clientset.CoreV1().Secrets(s.secretNamespace).Get(ctx, s.secretName, metav1.GetOptions{})
+		if err != nil {
+			return cert.Bundle{}, fmt.Errorf("getting secret %q: %s", s.secretName, err)
+		}
+		bundle := cert.Bundle{
+			Cert:   secret.Data[corev1.TLSCertKey],
+			Key:    secret.Data[corev1.TLSPrivateKeyKey],
+			CACert: secret.Data[caCertKey],
+		}
+		if len(bundle.CACert) == 0 {
+			return cert.Bundle{}, fmt.Errorf("secret %q must have %s", s.secretName, caCertKey)
+		}
+		if last == nil || !last.Equal(&bundle) {
+			return bundle, nil
+		}
+
+		select {
+		case <-time.After(s.pollInterval):
+		case <-ctx.Done():
+			return cert.Bundle{}, ctx.Err()
+		}
+	}
+}